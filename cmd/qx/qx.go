@@ -13,6 +13,7 @@ import (
 	"github.com/Qitmeer/qitmeer/wallet"
 	"io/ioutil"
 	"os"
+	"runtime"
 	"strings"
 )
 
@@ -58,11 +59,14 @@ hash-difficulty :
 
 entropy (seed) & mnemoic & hd & ec 
     entropy               generate a cryptographically secure pseudorandom entropy (seed)
+    entropy-split         split an entropy (seed) into shares, a threshold of which recover it (Shamir's secret sharing)
+    entropy-combine       recover an entropy (seed) from a threshold of its shares
     hd-new                create a new HD(BIP32) private key from an entropy (seed)
     hd-to-ec              convert the HD (BIP32) format private/public key to a EC private/public key
     hd-to-public          derive the HD (BIP32) public key from a HD private key
     hd-decode             decode a HD (BIP32) private/public key serialization format
     hd-derive             Derive a child HD (BIP32) key from another HD public or private key.
+    hd-account            export the BIP44 account HD public key of an HD master private key, for watch-only wallets.
     mnemonic-new          create a mnemonic world-list (BIP39) from an entropy
     mnemonic-to-entropy   return back to the entropy (the random seed) from a mnemonic world list (BIP39)
     mnemonic-to-seed      convert a mnemonic world-list (BIP39) to its 512 bits seed 
@@ -71,9 +75,13 @@ entropy (seed) & mnemoic & hd & ec
     ec-to-wif             convert an EC private key to a WIF, associates with the compressed public key by default.
     wif-to-ec             convert a WIF private key to an EC private key.
     wif-to-public         derive the EC public key from a WIF private key. 
+    ec-to-bip38           encrypt an EC private key with a passphrase (BIP38).
+    bip38-to-ec           decrypt a BIP38 private key with its passphrase to an EC private key.
 
 addr & tx & sign
     ec-to-addr            convert an EC public key to a paymant address. default is qx address
+    vanity                search for an EC private key whose address starts with a prefix
+    multisig-addr         build an m-of-n multisig redeem script from EC public keys and its P2SH address
     tx-encode             encode a unsigned transaction.
     tx-decode             decode a transaction in base16 to json format.
     tx-sign               sign a transactions using a private key.
@@ -115,9 +123,12 @@ var showDetails bool
 var base58checkHasher string
 var base58checkCksumSize int
 var seedSize uint
+var entropyShares uint
+var entropyThreshold uint
 var hdVer qx.Bip32VersionFlag
 var hdHarden bool
 var hdIndex uint
+var hdAccount uint
 var derivePath qx.DerivePathFlag
 var mnemoicSeedPassphrase string
 var curve string
@@ -130,6 +141,11 @@ var txVersion qx.TxVersionFlag
 var txLockTime qx.TxLockTimeFlag
 var privateKey string
 var msgSignatureMode string
+var bip38Passphrase string
+var vanityIgnoreCase bool
+var vanityWorkers int
+var multisigRequired int
+var multisigSort bool
 
 func main() {
 
@@ -293,6 +309,18 @@ func main() {
 	}
 	entropyCmd.UintVar(&seedSize, "s", seed.DefaultSeedBytes*8, "The length in bits for a seed (entropy)")
 
+	entropySplitCmd := flag.NewFlagSet("entropy-split", flag.ExitOnError)
+	entropySplitCmd.Usage = func() {
+		cmdUsage(entropySplitCmd, "Usage: qx entropy-split [-n shares] [-t threshold] [entropy] \n")
+	}
+	entropySplitCmd.UintVar(&entropyShares, "n", 5, "The number of shares")
+	entropySplitCmd.UintVar(&entropyThreshold, "t", 3, "The number of shares needed to recover the entropy")
+
+	entropyCombineCmd := flag.NewFlagSet("entropy-combine", flag.ExitOnError)
+	entropyCombineCmd.Usage = func() {
+		cmdUsage(entropyCombineCmd, "Usage: qx entropy-combine [share] [share] ... \n")
+	}
+
 	// HD (BIP32)
 	hdNewCmd := flag.NewFlagSet("hd-new", flag.ExitOnError)
 	hdNewCmd.Usage = func() {
@@ -328,6 +356,13 @@ func main() {
 	hdDeriveCmd.Var(&derivePath, "p", "hd derive `path`. ex: m/44'/0'/0'/0")
 	hdDeriveCmd.Var(&hdVer, "v", "The HD(BIP32) `version` [mainnet|testnet|privnet|bip32]")
 
+	hdAccountCmd := flag.NewFlagSet("hd-account", flag.ExitOnError)
+	hdAccountCmd.Usage = func() {
+		cmdUsage(hdAccountCmd, "Usage: qx hd-account [-n network] [-a account] [hd_master_private_key] \n")
+	}
+	hdAccountCmd.UintVar(&hdAccount, "a", 0, "The BIP44 account `index`")
+	hdAccountCmd.StringVar(&network, "n", "testnet", "the network of the HD key, which sets the BIP44 coin type. (mainnet, testnet, privnet, mixnet)")
+
 	// Mnemonic (BIP39)
 	mnemonicNewCmd := flag.NewFlagSet("mnemonic-new", flag.ExitOnError)
 	mnemonicNewCmd.Usage = func() {
@@ -350,7 +385,7 @@ func main() {
 	ecNewCmd.Usage = func() {
 		cmdUsage(ecNewCmd, "Usage: qx ec-new [entropy]  \n")
 	}
-	ecNewCmd.StringVar(&curve, "c", "secp256k1", "the elliptic curve is using")
+	ecNewCmd.StringVar(&curve, "c", "secp256k1", "the elliptic curve is using, one of "+strings.Join(qx.SupportedCurves, ", "))
 
 	ecToPubCmd := flag.NewFlagSet("ec-to-public", flag.ExitOnError)
 	ecToPubCmd.Usage = func() {
@@ -376,6 +411,22 @@ func main() {
 	}
 	wifToPubCmd.BoolVar(&uncompressedPKFormat, "u", false, "using the uncompressed public key format")
 
+	// BIP38
+	ecToBip38Cmd := flag.NewFlagSet("ec-to-bip38", flag.ExitOnError)
+	ecToBip38Cmd.Usage = func() {
+		cmdUsage(ecToBip38Cmd, "Usage: qx ec-to-bip38 [-p passphrase] [-n network] [ec_private_key] \n")
+	}
+	ecToBip38Cmd.StringVar(&bip38Passphrase, "p", "", "the passphrase to encrypt the private key with")
+	ecToBip38Cmd.StringVar(&network, "n", "mainnet", "the network of the address the key is encrypted for. (mainnet, testnet, privnet, mixnet)")
+	ecToBip38Cmd.BoolVar(&uncompressedPKFormat, "u", false, "using the uncompressed public key format")
+
+	bip38ToEcCmd := flag.NewFlagSet("bip38-to-ec", flag.ExitOnError)
+	bip38ToEcCmd.Usage = func() {
+		cmdUsage(bip38ToEcCmd, "Usage: qx bip38-to-ec [-p passphrase] [-n network] [bip38_private_key] \n")
+	}
+	bip38ToEcCmd.StringVar(&bip38Passphrase, "p", "", "the passphrase the private key is encrypted with")
+	bip38ToEcCmd.StringVar(&network, "n", "mainnet", "the network of the address the key is encrypted for. (mainnet, testnet, privnet, mixnet)")
+
 	// Address
 	ecToAddrCmd := flag.NewFlagSet("ec-to-addr", flag.ExitOnError)
 	ecToAddrCmd.Usage = func() {
@@ -383,6 +434,22 @@ func main() {
 	}
 	ecToAddrCmd.Var(&base58checkVersion, "v", "base58check `version` [mainnet|testnet|privnet]")
 
+	vanityCmd := flag.NewFlagSet("vanity", flag.ExitOnError)
+	vanityCmd.Usage = func() {
+		cmdUsage(vanityCmd, "Usage: qx vanity [-n network] [-i] [-w workers] [prefix] \n")
+	}
+	vanityCmd.StringVar(&network, "n", "testnet", "the network of the address. (mainnet, testnet, privnet, mixnet)")
+	vanityCmd.BoolVar(&vanityIgnoreCase, "i", false, "match the prefix case-insensitively")
+	vanityCmd.IntVar(&vanityWorkers, "w", runtime.NumCPU(), "the number of workers generating keys")
+
+	multisigAddrCmd := flag.NewFlagSet("multisig-addr", flag.ExitOnError)
+	multisigAddrCmd.Usage = func() {
+		cmdUsage(multisigAddrCmd, "Usage: qx multisig-addr [-m required] [-n network] [-s] [ec_public_key] [ec_public_key] ... \n")
+	}
+	multisigAddrCmd.IntVar(&multisigRequired, "m", 1, "the number of signatures required")
+	multisigAddrCmd.StringVar(&network, "n", "testnet", "the network of the address. (mainnet, testnet, privnet, mixnet)")
+	multisigAddrCmd.BoolVar(&multisigSort, "s", false, "sort the public keys (BIP67)")
+
 	// Transaction
 	txDecodeCmd := flag.NewFlagSet("tx-decode", flag.ExitOnError)
 	txDecodeCmd.Usage = func() {
@@ -449,11 +516,14 @@ MEER is the 64 bit spend amount in qitmeer.`)
 		bitcion160Cmd,
 		hash160Cmd,
 		entropyCmd,
+		entropySplitCmd,
+		entropyCombineCmd,
 		hdNewCmd,
 		hdToPubCmd,
 		hdToEcCmd,
 		hdDecodeCmd,
 		hdDeriveCmd,
+		hdAccountCmd,
 		mnemonicNewCmd,
 		mnemonicToEntropyCmd,
 		mnemonicToSeedCmd,
@@ -462,7 +532,11 @@ MEER is the 64 bit spend amount in qitmeer.`)
 		ecToWifCmd,
 		wifToEcCmd,
 		wifToPubCmd,
+		ecToBip38Cmd,
+		bip38ToEcCmd,
 		ecToAddrCmd,
+		vanityCmd,
+		multisigAddrCmd,
 		txEncodeCmd,
 		txDecodeCmd,
 		txSignCmd,
@@ -975,6 +1049,54 @@ MEER is the 64 bit spend amount in qitmeer.`)
 		}
 	}
 
+	if entropySplitCmd.Parsed() {
+		stat, _ := os.Stdin.Stat()
+		var entropy string
+		if (stat.Mode() & os.ModeNamedPipe) == 0 {
+			if len(os.Args) == 2 || os.Args[2] == "help" || os.Args[2] == "--help" {
+				entropySplitCmd.Usage()
+				os.Exit(1)
+			}
+			entropy = os.Args[len(os.Args)-1]
+		} else { //try from STDIN
+			src, err := ioutil.ReadAll(os.Stdin)
+			if err != nil {
+				errExit(err)
+			}
+			entropy = strings.TrimSpace(string(src))
+		}
+		shares, err := qx.EntropySplit(entropy, int(entropyShares), int(entropyThreshold))
+		if err != nil {
+			qx.ErrExit(err)
+		}
+		for _, share := range shares {
+			fmt.Printf("%s\n", share)
+		}
+	}
+
+	if entropyCombineCmd.Parsed() {
+		stat, _ := os.Stdin.Stat()
+		var shares []string
+		if (stat.Mode() & os.ModeNamedPipe) == 0 {
+			if len(os.Args) == 2 || os.Args[2] == "help" || os.Args[2] == "--help" {
+				entropyCombineCmd.Usage()
+				os.Exit(1)
+			}
+			shares = entropyCombineCmd.Args()
+		} else { //try from STDIN
+			src, err := ioutil.ReadAll(os.Stdin)
+			if err != nil {
+				errExit(err)
+			}
+			shares = strings.Fields(string(src))
+		}
+		entropy, err := qx.EntropyCombine(shares)
+		if err != nil {
+			qx.ErrExit(err)
+		}
+		fmt.Printf("%s\n", entropy)
+	}
+
 	if hdNewCmd.Parsed() {
 		stat, _ := os.Stdin.Stat()
 		if (stat.Mode() & os.ModeNamedPipe) == 0 {
@@ -1065,6 +1187,24 @@ MEER is the 64 bit spend amount in qitmeer.`)
 		}
 	}
 
+	if hdAccountCmd.Parsed() {
+		stat, _ := os.Stdin.Stat()
+		if (stat.Mode() & os.ModeNamedPipe) == 0 {
+			if len(os.Args) == 2 || os.Args[2] == "help" || os.Args[2] == "--help" {
+				hdAccountCmd.Usage()
+			} else {
+				qx.HdAccountPublicKeySTDO(network, uint32(hdAccount), os.Args[len(os.Args)-1])
+			}
+		} else { //try from STDIN
+			src, err := ioutil.ReadAll(os.Stdin)
+			if err != nil {
+				errExit(err)
+			}
+			str := strings.TrimSpace(string(src))
+			qx.HdAccountPublicKeySTDO(network, uint32(hdAccount), str)
+		}
+	}
+
 	if mnemonicNewCmd.Parsed() {
 		stat, _ := os.Stdin.Stat()
 		if (stat.Mode() & os.ModeNamedPipe) == 0 {
@@ -1226,6 +1366,42 @@ MEER is the 64 bit spend amount in qitmeer.`)
 		}
 	}
 
+	if ecToBip38Cmd.Parsed() {
+		stat, _ := os.Stdin.Stat()
+		if (stat.Mode() & os.ModeNamedPipe) == 0 {
+			if len(os.Args) == 2 || os.Args[2] == "help" || os.Args[2] == "--help" {
+				ecToBip38Cmd.Usage()
+			} else {
+				qx.EcPrivateKeyToBip38STDO(network, uncompressedPKFormat, os.Args[len(os.Args)-1], bip38Passphrase)
+			}
+		} else { //try from STDIN
+			src, err := ioutil.ReadAll(os.Stdin)
+			if err != nil {
+				errExit(err)
+			}
+			str := strings.TrimSpace(string(src))
+			qx.EcPrivateKeyToBip38STDO(network, uncompressedPKFormat, str, bip38Passphrase)
+		}
+	}
+
+	if bip38ToEcCmd.Parsed() {
+		stat, _ := os.Stdin.Stat()
+		if (stat.Mode() & os.ModeNamedPipe) == 0 {
+			if len(os.Args) == 2 || os.Args[2] == "help" || os.Args[2] == "--help" {
+				bip38ToEcCmd.Usage()
+			} else {
+				qx.Bip38ToEcPrivateKeySTDO(network, os.Args[len(os.Args)-1], bip38Passphrase)
+			}
+		} else { //try from STDIN
+			src, err := ioutil.ReadAll(os.Stdin)
+			if err != nil {
+				errExit(err)
+			}
+			str := strings.TrimSpace(string(src))
+			qx.Bip38ToEcPrivateKeySTDO(network, str, bip38Passphrase)
+		}
+	}
+
 	if ecToAddrCmd.Parsed() {
 		stat, _ := os.Stdin.Stat()
 		if (stat.Mode() & os.ModeNamedPipe) == 0 {
@@ -1244,6 +1420,33 @@ MEER is the 64 bit spend amount in qitmeer.`)
 		}
 	}
 
+	if vanityCmd.Parsed() {
+		if len(os.Args) == 2 || os.Args[2] == "help" || os.Args[2] == "--help" {
+			vanityCmd.Usage()
+		} else {
+			qx.VanitySearchSTDO(network, os.Args[len(os.Args)-1], vanityIgnoreCase, vanityWorkers)
+		}
+	}
+
+	if multisigAddrCmd.Parsed() {
+		stat, _ := os.Stdin.Stat()
+		var pubkeys []string
+		if (stat.Mode() & os.ModeNamedPipe) == 0 {
+			if len(os.Args) == 2 || os.Args[2] == "help" || os.Args[2] == "--help" {
+				multisigAddrCmd.Usage()
+				os.Exit(1)
+			}
+			pubkeys = multisigAddrCmd.Args()
+		} else { //try from STDIN
+			src, err := ioutil.ReadAll(os.Stdin)
+			if err != nil {
+				errExit(err)
+			}
+			pubkeys = strings.Fields(string(src))
+		}
+		qx.MultisigAddressSTDO(network, multisigRequired, pubkeys, multisigSort)
+	}
+
 	if txDecodeCmd.Parsed() {
 		stat, _ := os.Stdin.Stat()
 		if (stat.Mode() & os.ModeNamedPipe) == 0 {
@@ -0,0 +1,107 @@
+// Copyright (c) 2017-2018 The qitmeer developers
+
+package marshal
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/json"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"github.com/Qitmeer/qitmeer/engine/txscript"
+	"github.com/Qitmeer/qitmeer/params"
+	"strconv"
+	"time"
+)
+
+// MarshalJsonBlockTemplate returns the decoded view of the passed block
+// template, with every transaction annotated by the fee and the signature
+// operations the template reports, and the coinbase broken down into the
+// reward of the miner, the tax and the commitments.  A solved block is
+// decoded as a template with only its Block set, which has no fees, no
+// signature operations and no subsidy.
+func MarshalJsonBlockTemplate(template *types.BlockTemplate,
+	params *params.Params) (*json.DecodeBlockTemplateResult, error) {
+
+	block := template.Block
+	if block == nil || len(block.Transactions) == 0 {
+		return nil, fmt.Errorf("block template has no block")
+	}
+	isTemplate := len(template.Fees) > 0
+	if isTemplate && (len(template.Fees) != len(block.Transactions) ||
+		len(template.SigOpCounts) != len(block.Transactions)) {
+		return nil, fmt.Errorf("block template has %d fees and %d sigop "+
+			"counts for %d transactions", len(template.Fees),
+			len(template.SigOpCounts), len(block.Transactions))
+	}
+
+	hashStrings := func(hashes []*hash.Hash) []string {
+		strs := make([]string, len(hashes))
+		for i, h := range hashes {
+			strs[i] = h.String()
+		}
+		return strs
+	}
+	head := &block.Header
+	result := &json.DecodeBlockTemplateResult{
+		Hash:         block.BlockHash().String(),
+		Version:      head.Version,
+		ParentRoot:   head.ParentRoot.String(),
+		TxRoot:       head.TxRoot.String(),
+		StateRoot:    head.StateRoot.String(),
+		Bits:         strconv.FormatUint(uint64(head.Difficulty), 16),
+		Timestamp:    head.Timestamp.Format(time.RFC3339),
+		Parents:      hashStrings(block.Parents),
+		Weight:       types.GetBlockWeight(block),
+		Height:       template.Height,
+		Blues:        template.Blues,
+		BlueSet:      hashStrings(template.BlueSet),
+		RedSet:       hashStrings(template.RedSet),
+		Transactions: make([]json.DecodeBlockTemplateTx, len(block.Transactions)),
+		Coinbase: json.DecodeBlockTemplateCoinbase{
+			Commitments: []string{},
+		},
+	}
+	if head.Pow != nil {
+		result.Pow = head.Pow.GetPowResult()
+	}
+
+	for i, tx := range block.Transactions {
+		var coinbaseAmount uint64
+		if tx.IsCoinBase() && len(tx.TxOut) > 0 {
+			coinbaseAmount = tx.TxOut[0].Amount
+		}
+		txr, err := MarshalJsonTransaction(tx, params, "", 0, coinbaseAmount)
+		if err != nil {
+			return nil, err
+		}
+		result.Transactions[i].TxRawResult = txr
+		if isTemplate {
+			result.Transactions[i].Fee = &template.Fees[i]
+			result.Transactions[i].SigOps = &template.SigOpCounts[i]
+		}
+	}
+
+	// The fees of the template are the negative of the first entry.
+	coinbase := &result.Coinbase
+	if isTemplate {
+		fees := -template.Fees[0]
+		coinbase.Subsidy = &template.Subsidy
+		coinbase.Fees = &fees
+	}
+	for _, txOut := range block.Transactions[0].TxOut {
+		switch {
+		case params.HasTax() &&
+			bytes.Equal(txOut.PkScript, params.OrganizationPkScript):
+			coinbase.Tax += txOut.Amount
+		case txscript.GetScriptClass(txscript.DefaultScriptVersion,
+			txOut.PkScript) == txscript.NullDataTy:
+			coinbase.Commitments = append(coinbase.Commitments,
+				hex.EncodeToString(txOut.PkScript))
+		default:
+			coinbase.Reward += txOut.Amount
+		}
+	}
+	return result, nil
+}
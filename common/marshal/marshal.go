@@ -31,14 +31,44 @@ func MessageToHex(msg message.Message) (string, error) {
 
 func MarshalJsonTx(tx *types.Tx, params *params.Params, blkHashStr string,
 	confirmations int64, coinbaseAmout uint64) (json.TxRawResult, error) {
+	txr, err := NewTxRawResult(tx, params, blkHashStr, confirmations, coinbaseAmout)
+	if err != nil {
+		return json.TxRawResult{}, err
+	}
+	return *txr, nil
+}
+
+// NewTxRawResult returns the raw transaction result of the passed transaction,
+// located in the passed block when it isn't empty.  Its Txid is the hash of
+// the transaction without witness and its TxHash the hash including the
+// witness, as returned by TxRawHashes.
+func NewTxRawResult(tx *types.Tx, params *params.Params, blkHashStr string,
+	confirmations int64, coinbaseAmout uint64) (*json.TxRawResult, error) {
 	if tx == nil {
-		return json.TxRawResult{}, errors.New("can't marshal nil transaction")
+		return nil, errors.New("can't marshal nil transaction")
 	}
 	txr, err := MarshalJsonTransaction(tx.Transaction(), params, blkHashStr, confirmations, coinbaseAmout)
-	if err == nil {
-		txr.Duplicate = tx.IsDuplicate
+	if err != nil {
+		return nil, err
 	}
-	return txr, err
+	txr.Duplicate = tx.IsDuplicate
+	return &txr, nil
+}
+
+// TxRawHashes returns the Txid and the TxHash of a raw transaction result of
+// the passed transaction.  The txid is the hash without witness, which
+// identifies the transaction, and the tx hash the hash of the full
+// serialization including the witness.  Both are the txid when the
+// transaction has no witness, which is when none of its inputs has a
+// signature script.
+func TxRawHashes(tx *types.Transaction) (string, string) {
+	txid := tx.TxHash().String()
+	for _, txIn := range tx.TxIn {
+		if len(txIn.SignScript) > 0 {
+			return txid, tx.TxHashFull().String()
+		}
+	}
+	return txid, txid
 }
 
 func MarshalJsonTransaction(tx *types.Transaction, params *params.Params, blkHashStr string,
@@ -48,10 +78,11 @@ func MarshalJsonTransaction(tx *types.Transaction, params *params.Params, blkHas
 	if err != nil {
 		return json.TxRawResult{}, err
 	}
+	txid, txHash := TxRawHashes(tx)
 	txr := json.TxRawResult{
 		Hex:      hexStr,
-		Txid:     tx.TxHash().String(),
-		TxHash:   tx.TxHashFull().String(),
+		Txid:     txid,
+		TxHash:   txHash,
 		Size:     int32(tx.SerializeSize()),
 		Version:  tx.Version,
 		LockTime: tx.LockTime,
@@ -97,36 +128,77 @@ func MarshJsonVin(tx *types.Transaction) []json.Vin {
 			Asm: disbuf,
 			Hex: hex.EncodeToString(txIn.SignScript),
 		}
+		vinEntry.Txinwitness = marshalJsonWitness(txIn.SignScript)
 	}
 	return vinList
 }
 
+// marshalJsonWitness returns the hex encoded items of the witness stack
+// pushed by the passed signature script, or nil when the script isn't push
+// only and so carries no witness stack.
+func marshalJsonWitness(signScript []byte) []string {
+	if len(signScript) == 0 || !txscript.IsPushOnlyScript(signScript) {
+		return nil
+	}
+	items, err := txscript.PushedData(signScript)
+	if err != nil {
+		return nil
+	}
+	witness := make([]string, len(items))
+	for i, item := range items {
+		witness[i] = hex.EncodeToString(item)
+	}
+	return witness
+}
+
+// ClassifyScript disassembles the passed public key script and extracts its
+// class, required signatures and the addresses it pays to, encoded for the
+// passed network.  Scripts which don't parse or match no standard form are
+// classified as nonstandard.  It doesn't need a running node, so offline tools
+// can use it as well.
+//
+// The addresses are always encoded in base58.  No network defines a bech32
+// prefix, none of the standard script types is a witness program, and
+// DecodeAddress only decodes base58, so a bech32 form would be an address
+// that no node or wallet accepts.
+//
+// TODO, emit the bech32 form of the addresses, per network, once the networks
+// define a bech32 prefix and DecodeAddress decodes it.
+func ClassifyScript(pkScript []byte, params *params.Params) json.ScriptPubKeyResult {
+	// The disassembled string will contain [error] inline if the
+	// script doesn't fully parse, so ignore the error here.
+	disbuf, _ := txscript.DisasmString(pkScript)
+
+	// Ignore the error here since an error means the script
+	// couldn't parse and there is no additional information
+	// about it anyways.
+	sc, addrs, reqSigs, _ := txscript.ExtractPkScriptAddrs(pkScript, params)
+	encodedAddrs := make([]string, len(addrs))
+	for i, addr := range addrs {
+		encodedAddrs[i] = addr.Encode()
+	}
+
+	return json.ScriptPubKeyResult{
+		Asm:       disbuf,
+		Hex:       hex.EncodeToString(pkScript),
+		ReqSigs:   int32(reqSigs),
+		Type:      sc.String(),
+		Addresses: encodedAddrs,
+	}
+}
+
 func MarshJsonVout(tx *types.Transaction, filterAddrMap map[string]struct{}, params *params.Params) []json.Vout {
 	voutList := make([]json.Vout, 0, len(tx.TxOut))
 	for _, v := range tx.TxOut {
-		// The disassembled string will contain [error] inline if the
-		// script doesn't fully parse, so ignore the error here.
-		disbuf, _ := txscript.DisasmString(v.PkScript)
-
-		// Ignore the error here since an error means the script
-		// couldn't parse and there is no additional information
-		// about it anyways.
-		sc, addrs, reqSigs, _ := txscript.ExtractPkScriptAddrs(
-			v.PkScript, params)
-		scriptClass := sc.String()
-
-		// Encode the addresses while checking if the address passes the
-		// filter when needed.
-		passesFilter := len(filterAddrMap) == 0
-		encodedAddrs := make([]string, len(addrs))
-		for j, addr := range addrs {
-			encodedAddr := addr.Encode()
-			encodedAddrs[j] = encodedAddr
+		spk := scriptCache.ScriptPubKey(v.PkScript, params)
 
+		// Check if any of the addresses passes the filter when needed.
+		passesFilter := len(filterAddrMap) == 0
+		for _, encodedAddr := range spk.Addresses {
 			// No need to check the map again if the filter already
 			// passes.
 			if passesFilter {
-				continue
+				break
 			}
 			if _, exists := filterAddrMap[encodedAddr]; exists {
 				passesFilter = true
@@ -138,13 +210,8 @@ func MarshJsonVout(tx *types.Transaction, filterAddrMap map[string]struct{}, par
 		}
 
 		var vout json.Vout
-		voutSPK := &vout.ScriptPubKey
 		vout.Amount = v.Amount
-		voutSPK.Addresses = encodedAddrs
-		voutSPK.Asm = disbuf
-		voutSPK.Hex = hex.EncodeToString(v.PkScript)
-		voutSPK.Type = scriptClass
-		voutSPK.ReqSigs = int32(reqSigs)
+		vout.ScriptPubKey = spk
 		voutList = append(voutList, vout)
 	}
 
@@ -0,0 +1,275 @@
+package marshal
+
+import (
+	"bytes"
+	"encoding/hex"
+	ejson "encoding/json"
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/address"
+	"github.com/Qitmeer/qitmeer/core/json"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"github.com/Qitmeer/qitmeer/crypto/ecc"
+	"github.com/Qitmeer/qitmeer/engine/txscript"
+	"github.com/Qitmeer/qitmeer/params"
+	"reflect"
+	"testing"
+)
+
+// TestMarshalJsonVinWitness ensures the witness stack of an input is
+// marshalled in order after the signature script and decodes back intact.
+func TestMarshalJsonVinWitness(t *testing.T) {
+	witness := [][]byte{
+		bytes.Repeat([]byte{0x30}, 71),
+		bytes.Repeat([]byte{0x02}, 33),
+		{0x51, 0xae},
+	}
+	builder := txscript.NewScriptBuilder()
+	for _, item := range witness {
+		builder.AddData(item)
+	}
+	signScript, err := builder.Script()
+	if err != nil {
+		t.Fatalf("Script: %v", err)
+	}
+
+	tx := types.NewTransaction()
+	tx.AddTxIn(&types.TxInput{
+		PreviousOut: *types.NewOutPoint(&hash.Hash{0x01}, 0),
+		Sequence:    types.MaxTxInSequenceNum,
+		SignScript:  signScript,
+	})
+	vins := MarshJsonVin(tx)
+	raw, err := ejson.Marshal(&vins[0])
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if bytes.Index(raw, []byte(`"scriptSig"`)) > bytes.Index(raw, []byte(`"txinwitness"`)) {
+		t.Errorf("unexpected field order: %s", raw)
+	}
+
+	var decoded json.Vin
+	if err := ejson.Unmarshal(raw, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	want := make([]string, len(witness))
+	for i, item := range witness {
+		want[i] = hex.EncodeToString(item)
+	}
+	if !reflect.DeepEqual(decoded.Txinwitness, want) {
+		t.Errorf("witness: got %v, want %v", decoded.Txinwitness, want)
+	}
+
+	// Coinbase inputs never report a witness.
+	coinbase := json.Vin{Coinbase: "00", Txinwitness: want}
+	raw, err = ejson.Marshal(&coinbase)
+	if err != nil {
+		t.Fatalf("Marshal coinbase: %v", err)
+	}
+	if bytes.Contains(raw, []byte("txinwitness")) {
+		t.Errorf("coinbase reports a witness: %s", raw)
+	}
+}
+
+// TestClassifyScript ensures each kind of public key script is classified
+// with the addresses it pays to encoded for the requested network.
+func TestClassifyScript(t *testing.T) {
+	scripts := testScriptMix(1)
+	hash160 := bytes.Repeat([]byte{1}, 20)
+	pkhAddr := func(p *params.Params) string {
+		addr, err := address.NewPubKeyHashAddress(hash160, p, ecc.ECDSA_Secp256k1)
+		if err != nil {
+			t.Fatalf("NewPubKeyHashAddress: %v", err)
+		}
+		return addr.Encode()
+	}
+	shAddr, err := address.NewAddressScriptHashFromHash(hash160, &params.MainNetParams)
+	if err != nil {
+		t.Fatalf("NewAddressScriptHashFromHash: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		pkScript  []byte
+		params    *params.Params
+		class     string
+		reqSigs   int32
+		addresses []string
+	}{
+		{"p2pkh", scripts[0], &params.MainNetParams, "pubkeyhash", 1,
+			[]string{pkhAddr(&params.MainNetParams)}},
+		{"p2pkh testnet", scripts[0], &params.TestNetParams, "pubkeyhash", 1,
+			[]string{pkhAddr(&params.TestNetParams)}},
+		{"p2sh", scripts[2], &params.MainNetParams, "scripthash", 1,
+			[]string{shAddr.Encode()}},
+		{"multisig", scripts[3], &params.MainNetParams, "multisig", 1, nil},
+		{"nulldata", scripts[4], &params.MainNetParams, "nulldata", 0,
+			[]string{}},
+		{"nonstandard", []byte{txscript.OP_TRUE}, &params.MainNetParams,
+			"nonstandard", 0, []string{}},
+	}
+	for _, test := range tests {
+		result := ClassifyScript(test.pkScript, test.params)
+		if result.Type != test.class {
+			t.Errorf("%s: type got %s, want %s", test.name, result.Type,
+				test.class)
+		}
+		if result.ReqSigs != test.reqSigs {
+			t.Errorf("%s: reqSigs got %d, want %d", test.name,
+				result.ReqSigs, test.reqSigs)
+		}
+		if result.Hex != hex.EncodeToString(test.pkScript) || result.Asm == "" {
+			t.Errorf("%s: unexpected script %s (%s)", test.name,
+				result.Hex, result.Asm)
+		}
+		// The pubkey addresses of multisig scripts are only counted.
+		if test.addresses == nil {
+			if len(result.Addresses) != 1 {
+				t.Errorf("%s: got %d addresses, want 1", test.name,
+					len(result.Addresses))
+			}
+			continue
+		}
+		if !reflect.DeepEqual(result.Addresses, test.addresses) {
+			t.Errorf("%s: addresses got %v, want %v", test.name,
+				result.Addresses, test.addresses)
+		}
+	}
+}
+
+// TestClassifyScriptAddressFormat ensures the addresses of each script type
+// use the base58 encoding of each network, since no script type has a bech32
+// form.
+func TestClassifyScriptAddressFormat(t *testing.T) {
+	scripts := testScriptMix(1)
+	pubKey, _ := hex.DecodeString("0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798")
+	p2pk, _ := txscript.NewScriptBuilder().AddData(pubKey).
+		AddOp(txscript.OP_CHECKSIG).Script()
+
+	tests := []struct {
+		name      string
+		pkScript  []byte
+		decodable bool
+	}{
+		{"p2pkh", scripts[0], true},
+		{"p2sh", scripts[2], true},
+		{"p2pk", p2pk, false},
+	}
+	nets := []*params.Params{&params.MainNetParams, &params.TestNetParams,
+		&params.PrivNetParams, &params.MixNetParams}
+	for _, p := range nets {
+		for _, test := range tests {
+			result := ClassifyScript(test.pkScript, p)
+			if len(result.Addresses) != 1 {
+				t.Fatalf("%s %s: got %d addresses, want 1", p.Name,
+					test.name, len(result.Addresses))
+			}
+			encoded := result.Addresses[0]
+			if encoded[:1] != p.NetworkAddressPrefix {
+				t.Errorf("%s %s: address %s lacks the network prefix %s",
+					p.Name, test.name, encoded, p.NetworkAddressPrefix)
+			}
+			if !test.decodable {
+				continue
+			}
+			addr, err := address.DecodeAddress(encoded)
+			if err != nil {
+				t.Errorf("%s %s: DecodeAddress(%s): %v", p.Name, test.name,
+					encoded, err)
+				continue
+			}
+			if addr.Encode() != encoded {
+				t.Errorf("%s %s: address %s decodes to %s", p.Name,
+					test.name, encoded, addr.Encode())
+			}
+		}
+	}
+}
+
+// TestMarshalJsonTransactionStates ensures the block and mempool fields of a
+// raw transaction result match the state of the transaction.
+func TestMarshalJsonTransactionStates(t *testing.T) {
+	tx := types.NewTransaction()
+	tx.AddTxIn(&types.TxInput{
+		PreviousOut: *types.NewOutPoint(&hash.Hash{0x01}, 0),
+		Sequence:    types.MaxTxInSequenceNum,
+	})
+	tx.AddTxOut(&types.TxOutput{Amount: 1, PkScript: testScriptMix(1)[0]})
+	blockHash := hash.Hash{0x02}.String()
+
+	tests := []struct {
+		name          string
+		blkHashStr    string
+		confirmations int64
+		inMempool     bool
+		present       []string
+		absent        []string
+	}{
+		{"confirmed", blockHash, 3, false,
+			[]string{`"blockhash":"` + blockHash + `"`, `"confirmations":3`},
+			[]string{`"inmempool"`}},
+		{"unconfirmed", "", 0, true,
+			[]string{`"inmempool":true`, `"confirmations":0`},
+			[]string{`"blockhash"`, `"blockorder"`}},
+		{"orphaned", "", 0, false,
+			[]string{`"confirmations":0`},
+			[]string{`"blockhash"`, `"blockorder"`, `"inmempool"`}},
+	}
+	for _, test := range tests {
+		txr, err := MarshalJsonTransaction(tx, &params.MainNetParams,
+			test.blkHashStr, test.confirmations, 0)
+		if err != nil {
+			t.Fatalf("%s: MarshalJsonTransaction: %v", test.name, err)
+		}
+		txr.InMempool = test.inMempool
+		raw, err := ejson.Marshal(&txr)
+		if err != nil {
+			t.Fatalf("%s: Marshal: %v", test.name, err)
+		}
+		for _, field := range test.present {
+			if !bytes.Contains(raw, []byte(field)) {
+				t.Errorf("%s: %s missing from %s", test.name, field, raw)
+			}
+		}
+		for _, field := range test.absent {
+			if bytes.Contains(raw, []byte(field)) {
+				t.Errorf("%s: unexpected %s in %s", test.name, field, raw)
+			}
+		}
+	}
+}
+
+// TestNewTxRawResultHashes ensures the txid and tx hash of a raw transaction
+// result are the hashes of the transaction without and with its witness.
+func TestNewTxRawResultHashes(t *testing.T) {
+	tx := types.NewTransaction()
+	tx.AddTxIn(&types.TxInput{
+		PreviousOut: *types.NewOutPoint(&hash.Hash{0x01}, 0),
+		Sequence:    types.MaxTxInSequenceNum,
+	})
+	tx.AddTxOut(&types.TxOutput{Amount: 1, PkScript: testScriptMix(1)[0]})
+
+	// Without witness, both hashes are the txid.
+	txr, err := NewTxRawResult(types.NewTx(tx), &params.MainNetParams, "", 0, 0)
+	if err != nil {
+		t.Fatalf("NewTxRawResult: %v", err)
+	}
+	if txid := tx.TxHash().String(); txr.Txid != txid || txr.TxHash != txid {
+		t.Errorf("txid %s and tx hash %s, want both %s", txr.Txid,
+			txr.TxHash, txid)
+	}
+
+	tx.TxIn[0].SignScript = bytes.Repeat([]byte{0x01}, 72)
+	txr, err = NewTxRawResult(types.NewTx(tx), &params.MainNetParams, "", 0, 0)
+	if err != nil {
+		t.Fatalf("NewTxRawResult: %v", err)
+	}
+	if txr.Txid == txr.TxHash {
+		t.Fatalf("witness tx has the same txid and tx hash %s", txr.Txid)
+	}
+	if want := tx.TxHash().String(); txr.Txid != want {
+		t.Errorf("txid %s, want %s", txr.Txid, want)
+	}
+	if want := tx.TxHashFull().String(); txr.TxHash != want {
+		t.Errorf("tx hash %s, want %s", txr.TxHash, want)
+	}
+}
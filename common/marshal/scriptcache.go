@@ -0,0 +1,141 @@
+// Copyright (c) 2017-2018 The qitmeer developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package marshal
+
+import (
+	"container/list"
+	"encoding/hex"
+	"github.com/Qitmeer/qitmeer/core/json"
+	"github.com/Qitmeer/qitmeer/core/protocol"
+	"github.com/Qitmeer/qitmeer/params"
+	"sync"
+)
+
+// defaultScriptCacheSize is the number of scripts the package wide cache
+// used to marshal transaction outputs holds.
+const defaultScriptCacheSize = 10000
+
+// scriptCache is the cache used to marshal transaction outputs.
+var scriptCache = NewScriptCache(defaultScriptCacheSize)
+
+// ScriptCacheHitRate returns the hit rate of the cache used to marshal
+// transaction outputs.
+func ScriptCacheHitRate() float64 {
+	return scriptCache.HitRate()
+}
+
+// scriptCacheKey identifies a script on a network, since the addresses it
+// pays to are encoded for the network.
+type scriptCacheKey struct {
+	net       protocol.Network
+	scriptHex string
+}
+
+// scriptCacheEntry is a cached script along with its key, so the least
+// recently used entry can be removed from the map when evicted.
+type scriptCacheEntry struct {
+	key    scriptCacheKey
+	result json.ScriptPubKeyResult
+}
+
+// ScriptCache provides a concurrency safe cache of the disassembly and the
+// extracted addresses of public key scripts, limited to a maximum number of
+// scripts with eviction of the least recently used one when the limit is
+// exceeded.  Scripts are immutable given their bytes, so entries never need to
+// be invalidated.
+type ScriptCache struct {
+	mtx     sync.Mutex
+	entries map[scriptCacheKey]*list.Element
+	lru     *list.List
+	limit   uint
+	hits    uint64
+	misses  uint64
+}
+
+// NewScriptCache returns a new script cache holding up to limit scripts.
+func NewScriptCache(limit uint) *ScriptCache {
+	return &ScriptCache{
+		entries: make(map[scriptCacheKey]*list.Element),
+		lru:     list.New(),
+		limit:   limit,
+	}
+}
+
+// ScriptPubKey returns the disassembly, class, required signatures and
+// addresses of the passed public key script for the passed network, using
+// the cached result when available.
+//
+// This function is safe for concurrent access.
+func (c *ScriptCache) ScriptPubKey(pkScript []byte, params *params.Params) json.ScriptPubKeyResult {
+	key := scriptCacheKey{net: params.Net, scriptHex: hex.EncodeToString(pkScript)}
+
+	c.mtx.Lock()
+	if node, exists := c.entries[key]; exists {
+		c.hits++
+		c.lru.MoveToFront(node)
+		result := node.Value.(*scriptCacheEntry).result
+		c.mtx.Unlock()
+		return copyScriptPubKey(result)
+	}
+	c.misses++
+	c.mtx.Unlock()
+
+	result := ClassifyScript(pkScript, params)
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	if c.limit == 0 {
+		return result
+	}
+	if _, exists := c.entries[key]; exists {
+		return result
+	}
+
+	// Evict the least recently used entry and reuse its list node if the
+	// new entry would exceed the size limit.
+	entry := &scriptCacheEntry{key: key, result: copyScriptPubKey(result)}
+	if uint(len(c.entries))+1 > c.limit {
+		node := c.lru.Back()
+		delete(c.entries, node.Value.(*scriptCacheEntry).key)
+		node.Value = entry
+		c.lru.MoveToFront(node)
+		c.entries[key] = node
+		return result
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	return result
+}
+
+// HitRate returns the share of lookups answered from the cache since it was
+// created.
+//
+// This function is safe for concurrent access.
+func (c *ScriptCache) HitRate() float64 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	total := c.hits + c.misses
+	if total == 0 {
+		return 0
+	}
+	return float64(c.hits) / float64(total)
+}
+
+// Len returns the number of cached scripts.
+//
+// This function is safe for concurrent access.
+func (c *ScriptCache) Len() int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return len(c.entries)
+}
+
+// copyScriptPubKey returns a copy of the passed result which doesn't share
+// its addresses, so callers can't modify a cached result.
+func copyScriptPubKey(result json.ScriptPubKeyResult) json.ScriptPubKeyResult {
+	addrs := make([]string, len(result.Addresses))
+	copy(addrs, result.Addresses)
+	result.Addresses = addrs
+	return result
+}
@@ -0,0 +1,90 @@
+package marshal
+
+import (
+	"bytes"
+	"encoding/hex"
+	"github.com/Qitmeer/qitmeer/engine/txscript"
+	"github.com/Qitmeer/qitmeer/params"
+	"reflect"
+	"testing"
+)
+
+// testScriptMix returns a mix of public key scripts as found in the outputs
+// of typical transactions, made unique by the passed index.
+func testScriptMix(index int) [][]byte {
+	hash160 := bytes.Repeat([]byte{byte(index)}, 20)
+	pubKey, _ := hex.DecodeString("0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798")
+
+	p2pkh, _ := txscript.NewScriptBuilder().AddOp(txscript.OP_DUP).
+		AddOp(txscript.OP_HASH160).AddData(hash160).
+		AddOp(txscript.OP_EQUALVERIFY).AddOp(txscript.OP_CHECKSIG).Script()
+	p2sh, _ := txscript.NewScriptBuilder().AddOp(txscript.OP_HASH160).
+		AddData(hash160).AddOp(txscript.OP_EQUAL).Script()
+	multiSig, _ := txscript.NewScriptBuilder().AddOp(txscript.OP_1).
+		AddData(pubKey).AddOp(txscript.OP_1).
+		AddOp(txscript.OP_CHECKMULTISIG).Script()
+	nullData, _ := txscript.NewScriptBuilder().AddOp(txscript.OP_RETURN).
+		AddData(hash160).Script()
+	return [][]byte{p2pkh, p2pkh, p2sh, multiSig, nullData}
+}
+
+// TestScriptCache ensures cached results match the uncached ones and the
+// least recently used scripts are evicted.
+func TestScriptCache(t *testing.T) {
+	c := NewScriptCache(3)
+	p := &params.MainNetParams
+	scripts := testScriptMix(1)
+	for _, script := range scripts {
+		want := ClassifyScript(script, p)
+		for i := 0; i < 2; i++ {
+			if got := c.ScriptPubKey(script, p); !reflect.DeepEqual(got, want) {
+				t.Fatalf("ScriptPubKey: got %v, want %v", got, want)
+			}
+		}
+	}
+	// Four distinct scripts were looked up twice, the duplicated one
+	// four times.
+	if got, want := c.HitRate(), 6.0/10; got != want {
+		t.Errorf("HitRate: got %v, want %v", got, want)
+	}
+	if c.Len() != 3 {
+		t.Fatalf("Len: got %d, want 3", c.Len())
+	}
+	key := scriptCacheKey{net: p.Net, scriptHex: hex.EncodeToString(scripts[0])}
+	if _, ok := c.entries[key]; ok {
+		t.Error("least recently used script wasn't evicted")
+	}
+
+	// Modifying a returned result leaves the cached one intact.
+	got := c.ScriptPubKey(scripts[2], p)
+	got.Addresses[0] = ""
+	if c.ScriptPubKey(scripts[2], p).Addresses[0] == "" {
+		t.Error("cached result was modified")
+	}
+}
+
+// BenchmarkScriptCache compares disassembling the outputs of a realistic
+// script mix on every call against reusing the cached results.
+func BenchmarkScriptCache(b *testing.B) {
+	var scripts [][]byte
+	for i := 0; i < 200; i++ {
+		scripts = append(scripts, testScriptMix(i)...)
+	}
+	p := &params.MainNetParams
+
+	b.Run("cold", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, script := range scripts {
+				ClassifyScript(script, p)
+			}
+		}
+	})
+	b.Run("warm", func(b *testing.B) {
+		c := NewScriptCache(defaultScriptCacheSize)
+		for i := 0; i < b.N; i++ {
+			for _, script := range scripts {
+				c.ScriptPubKey(script, p)
+			}
+		}
+	})
+}
@@ -22,6 +22,8 @@ type Config struct {
 	RPCPass            string   `short:"P" long:"rpcpass" default-mask:"-" description:"Password for RPC connections"`
 	RPCCert            string   `long:"rpccert" description:"File containing the certificate file"`
 	RPCKey             string   `long:"rpckey" description:"File containing the certificate key"`
+	RPCClientCA        string   `long:"rpcclientca" description:"File containing the CA certificates of the RPC clients, whose certificates are verified when presented"`
+	RPCClientAuth      bool     `long:"rpcclientauth" description:"Require the RPC clients to present a certificate signed by the rpcclientca"`
 	RPCMaxClients      int      `long:"rpcmaxclients" description:"Max number of RPC clients for standard connections"`
 	DisableRPC         bool     `long:"norpc" description:"Disable built-in RPC server -- NOTE: The RPC server is disabled by default if no rpcuser/rpcpass or rpclimituser/rpclimitpass is specified"`
 	DisableTLS         bool     `long:"notls" description:"Disable TLS for the RPC server -- NOTE: This is only allowed if the RPC server is bound to localhost"`
@@ -43,21 +45,57 @@ type Config struct {
 	DebugLevel         string   `short:"d" long:"debuglevel" description:"Logging level {trace, debug, info, warn, error, critical} "`
 	DebugPrintOrigins  bool     `long:"printorigin" description:"Print log debug location (file:line) "`
 	// MemPool Config
-	NoRelayPriority  bool    `long:"norelaypriority" description:"Do not require free or low-fee transactions to have high priority for relaying"`
-	FreeTxRelayLimit float64 `long:"limitfreerelay" description:"Limit relay of transactions with no transaction fee to the given amount in thousands of bytes per minute"`
-	AcceptNonStd     bool    `long:"acceptnonstd" description:"Accept and relay non-standard transactions to the network regardless of the default settings for the active network."`
-	MaxOrphanTxs     int     `long:"maxorphantx" description:"Max number of orphan transactions to keep in memory"`
-	MinTxFee         int64   `long:"mintxfee" description:"The minimum transaction fee in AtomMEER/kB."`
+	NoRelayPriority    bool          `long:"norelaypriority" description:"Do not require free or low-fee transactions to have high priority for relaying"`
+	FreeTxRelayLimit   float64       `long:"limitfreerelay" description:"Limit relay of transactions with no transaction fee to the given amount in thousands of bytes per minute"`
+	AcceptNonStd       bool          `long:"acceptnonstd" description:"Accept and relay non-standard transactions to the network regardless of the default settings for the active network."`
+	MaxOrphanTxs       int           `long:"maxorphantx" description:"Max number of orphan transactions to keep in memory"`
+	MaxOrphanTxBytes   int64         `long:"maxorphantxbytes" description:"Max total size in bytes of the orphan transactions to keep in memory (0 means unlimited)"`
+	OrphanTxExpiry     time.Duration `long:"orphantxexpiry" description:"Duration after which an orphan transaction is evicted (0 means never)"`
+	MinTxFee           int64         `long:"mintxfee" description:"The minimum transaction fee in AtomMEER/kB; cheaper transactions are rejected by the mempool and never mined"`
+	MaxAncestorCount   int           `long:"limitancestorcount" description:"Max number of transactions of a transaction and its unconfirmed ancestors in the mempool (0 means unlimited)"`
+	MaxAncestorSize    int64         `long:"limitancestorsize" description:"Max size in bytes of a transaction and its unconfirmed ancestors in the mempool (0 means unlimited)"`
+	MaxDescendantCount int           `long:"limitdescendantcount" description:"Max number of transactions of a transaction and its unconfirmed descendants in the mempool (0 means unlimited)"`
+	MaxDescendantSize  int64         `long:"limitdescendantsize" description:"Max size in bytes of a transaction and its unconfirmed descendants in the mempool (0 means unlimited)"`
+	MempoolExpiry      time.Duration `long:"mempoolexpiry" description:"Duration after which a transaction is evicted from the mempool (0, the default, means never)"`
+	NoPersistMempool   bool          `long:"nopersistmempool" description:"Do not save the mempool on shutdown and load it on startup"`
 	// Miner
-	Generate          bool     `long:"generate" description:"Generate (mine) coins using the CPU"`
-	MiningAddrs       []string `long:"miningaddr" description:"Add the specified payment address to the list of addresses to use for generated blocks -- At least one address is required if the generate option is set"`
-	MiningTimeOffset  int      `long:"miningtimeoffset" description:"Offset the mining timestamp of a block by this many seconds (positive values are in the past)"`
-	BlockMinSize      uint32   `long:"blockminsize" description:"Mininum block size in bytes to be used when creating a block"`
-	BlockMaxSize      uint32   `long:"blockmaxsize" description:"Maximum block size in bytes to be used when creating a block"`
-	BlockPrioritySize uint32   `long:"blockprioritysize" description:"Size in bytes for high-priority/low-fee transactions when creating a block"`
-	miningAddrs       []types.Address
+	Generate            bool     `long:"generate" description:"Generate (mine) coins using the CPU"`
+	MiningAddrs         []string `long:"miningaddr" description:"Add the specified payment address to the list of addresses to use for generated blocks -- At least one address is required if the generate option is set"`
+	MiningTimeOffset    int      `long:"miningtimeoffset" description:"Offset the mining timestamp of a block by this many seconds (positive values are in the past)"`
+	BlockMinSize        uint32   `long:"blockminsize" description:"Mininum block size in bytes to be used when creating a block"`
+	BlockMaxSize        uint32   `long:"blockmaxsize" description:"Maximum block size in bytes to be used when creating a block"`
+	BlockPrioritySize   uint32   `long:"blockprioritysize" description:"Size in bytes for high-priority/low-fee transactions when creating a block"`
+	CoinbaseReuseWindow uint64   `long:"coinbasereusewindow" description:"Warn when a block template pays to a coinbase address already paid to within this number of blocks (0 disables the tracking)"`
+	RejectCoinbaseReuse bool     `long:"rejectcoinbasereuse" description:"Fail the block templates reusing a coinbase address within the coinbasereusewindow instead of warning"`
+	TemplatePrevOuts    bool     `long:"templateprevouts" description:"Include the outputs spent by the transactions of the block templates, for stateless signers"`
+	BlockRejectNonStd   bool     `long:"blockrejectnonstd" description:"Skip the non-standard transactions when creating a block, such as the ones accepted with acceptnonstd"`
+	NoCoinbaseOpReturn  bool     `long:"nocoinbaseopreturn" description:"Omit the OP_RETURN outputs from the coinbase of the created blocks, for networks which don't expect them"`
+	RegtestMode         bool     `long:"regtestmode" description:"Let the created blocks spend immature coinbase outputs, for test scenarios; only the nodes of a network without coinbase maturity accept such blocks (not allowed on mainnet)"`
+	BlockMaxSigOps      int64    `long:"blockmaxsigops" description:"Max signature operation cost of the transactions selected for a block, below the consensus max (0 uses the consensus max)"`
+	BlockMaxParents     int      `long:"blockmaxparents" description:"Max number of tips used as parents of a created block, ranked by blue score (0 uses every valid tip)"`
+	BlockMaxPackageSize int      `long:"blockmaxpackagesize" description:"Max length, in transactions, of the chains of dependent mempool transactions included in a created block (0 means unlimited)"`
+	ExtraNonceSize      int      `long:"extranoncesize" description:"Number of bytes reserved for the extra nonce in the coinbase of the created blocks, which miners can roll (0 keeps the default extra nonce)"`
+	VerifyTemplates     bool     `long:"verifytemplates" description:"Serialize and parse back every created block template, to catch encoding regressions"`
+	miningAddrs         []types.Address
 	//WebSocket support
 	RPCMaxWebsockets int `long:"rpcmaxwebsockets" description:"Max number of RPC websocket connections"`
+	//RPC rate limiting
+	RPCRateLimits map[string]int `long:"rpcratelimit" description:"Max requests per second of an RPC method for each client, as method:limit (0 means unlimited)"`
+	//RPC method filtering
+	RPCAllowMethods []string `long:"rpcallow" description:"Add an RPC method which may be called, denying all the others"`
+	RPCDenyMethods  []string `long:"rpcdeny" description:"Add an RPC method which can't be called"`
+	//RPC request logging
+	RPCLogRequests bool     `long:"rpclogrequests" description:"Log each RPC request with its method, client, duration and status"`
+	RPCLogParams   bool     `long:"rpclogparams" description:"Also log the params of the RPC requests, except those of the methods taking private keys and of the rpclogredact methods"`
+	RPCLogRedact   []string `long:"rpclogredact" description:"Add an RPC method whose params are not logged"`
+	//RPC request limits
+	RPCMaxRequestSize int64         `long:"rpcmaxrequestsize" description:"Max size in bytes of an RPC request body"`
+	RPCRequestTimeout time.Duration `long:"rpcrequesttimeout" description:"Max duration of an RPC request, beyond which it is answered with a timeout error while a handler which ignores cancellation keeps running (0, the default, disables it)"`
+	//RPC batches
+	RPCBatchConcurrent bool `long:"rpcbatchconcurrent" description:"Handle the requests of an RPC batch concurrently, with one worker per processor, instead of in order"`
+	//RPC admin
+	RPCAdminListeners []string `long:"rpcadminlisten" description:"Add an interface/port to serve the RPC server health on (disabled by default)"`
+	RPCAdminProfile   bool     `long:"rpcadminprofile" description:"Serve the pprof profiling handlers on the RPC admin listeners, which default to localhost then"`
 	//P2P
 	BlocksOnly      bool     `long:"blocksonly" description:"Do not accept transactions from remote peers."`
 	MiningStateSync bool     `long:"miningstatesync" description:"Synchronizing the mining state with other nodes"`
@@ -8,12 +8,24 @@ import (
 )
 
 // IsForNetwork returns whether or not the address is associated with the
-// passed network.
-//TODO, other addr type and ec type check
+// passed network.  The pay-to-pubkey-hash addresses are checked against the
+// network identifier of their signature algorithm, and the pay-to-pubkey
+// addresses against the one of the pay-to-pubkey-hash addresses they encode
+// to.
 func IsForNetwork(addr types.Address, p *params.Params) bool {
 	switch addr := addr.(type) {
 	case *PubKeyHashAddress:
-		return addr.netID == p.PubKeyHashAddrID
+		return addr.netID == p.PubKeyHashAddrID ||
+			addr.netID == p.PKHEdwardsAddrID ||
+			addr.netID == p.PKHSchnorrAddrID
+	case *ScriptHashAddress:
+		return addr.netID == p.ScriptHashAddrID
+	case *SecpPubKeyAddress:
+		return addr.pubKeyHashID == p.PubKeyHashAddrID
+	case *EdwardsPubKeyAddress:
+		return addr.pubKeyHashID == p.PKHEdwardsAddrID
+	case *SecSchnorrPubKeyAddress:
+		return addr.pubKeyHashID == p.PKHSchnorrAddrID
 	}
 	return false
 }
@@ -730,7 +730,7 @@ func (b *BlockChain) connectDagChain(node *blockNode, block *types.SerializedBlo
 		view.SetViewpoints([]*hash.Hash{&node.hash})
 
 		stxos := []SpentTxOut{}
-		err := b.checkConnectBlock(node, block, view, &stxos)
+		err := b.checkConnectBlock(node, block, view, &stxos, b.params)
 		if err != nil {
 			node.Invalid(b)
 			stxos = []SpentTxOut{}
@@ -1061,7 +1061,7 @@ func (b *BlockChain) reorganizeChain(detachNodes BlockNodeList, attachNodes *lis
 		view := NewUtxoViewpoint()
 		view.SetViewpoints([]*hash.Hash{n.GetHash()})
 		stxos := []SpentTxOut{}
-		err = b.checkConnectBlock(n, block, view, &stxos)
+		err = b.checkConnectBlock(n, block, view, &stxos, b.params)
 		if err != nil {
 			n.Invalid(b)
 			stxos = []SpentTxOut{}
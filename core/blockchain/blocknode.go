@@ -184,20 +184,27 @@ func (node *blockNode) GetPowType() pow.PowType {
 func (node *blockNode) CalcPastMedianTime(b *BlockChain) time.Time {
 	// Create a slice of the previous few block timestamps used to calculate
 	// the median per the number defined by the constant medianTimeBlocks.
-	timestamps := make([]int64, medianTimeBlocks)
-	numNodes := 0
+	timestamps := make([]int64, 0, medianTimeBlocks)
 	iterNode := node
 	for i := 0; i < medianTimeBlocks && iterNode != nil; i++ {
-		timestamps[i] = iterNode.timestamp
-		numNodes++
+		timestamps = append(timestamps, iterNode.timestamp)
 
 		iterNode = iterNode.GetMainParent(b)
 	}
+	return PastMedianTime(timestamps)
+}
 
-	// Prune the slice to the actual number of available timestamps which
-	// will be fewer than desired near the beginning of the block chain
-	// and sort them.
-	timestamps = timestamps[:numNodes]
+// PastMedianTime returns the median time past of a main chain whose last
+// block timestamps, from the tip back, are the passed ones, as the consensus
+// rules compute it.  Only the first medianTimeBlocks timestamps are used.
+func PastMedianTime(timestamps []int64) time.Time {
+	// Copy the timestamps, which will be fewer than desired near the
+	// beginning of the block chain, and sort them.
+	if len(timestamps) > medianTimeBlocks {
+		timestamps = timestamps[:medianTimeBlocks]
+	}
+	numNodes := len(timestamps)
+	timestamps = append([]int64(nil), timestamps...)
 	sort.Sort(util.TimeSorter(timestamps))
 
 	// NOTE: The consensus rules incorrectly calculate the median for even
@@ -0,0 +1,98 @@
+package blockchain
+
+import (
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"github.com/Qitmeer/qitmeer/engine/txscript"
+	"testing"
+	"time"
+)
+
+// TestCalcSequenceLock ensures the sequence lock of a transaction is the
+// latest of the relative lock times of its inputs, from the height of the
+// blocks of their outputs, or the past median time for the outputs not in a
+// block yet.
+func TestCalcSequenceLock(t *testing.T) {
+	b, hashes := newDAGTestChain(t, 6)
+	medianTime := time.Unix(1577836800, 0)
+	b.stateSnapshot = &BestState{MedianTime: medianTime}
+
+	// The outputs of a funding transaction, in the blocks at heights 2 and
+	// 4, and not in a block yet.
+	pkScript := []byte{txscript.OP_TRUE}
+	view := NewUtxoViewpoint()
+	var prevOuts []*types.TxOutPoint
+	for i, blockHash := range []*hash.Hash{hashes[2], hashes[4], &hash.ZeroHash} {
+		funding := types.NewTransaction()
+		funding.AddTxIn(types.NewTxInput(types.NewOutPoint(&hash.Hash{0x01},
+			uint32(i)), nil))
+		funding.AddTxOut(types.NewTxOutput(100000, pkScript))
+		fundingTx := types.NewTx(funding)
+		view.AddTxOuts(fundingTx, blockHash)
+		prevOuts = append(prevOuts, types.NewOutPoint(fundingTx.Hash(), 0))
+	}
+
+	newSequence := func(isSeconds bool, lockTime uint32) uint32 {
+		sequence, err := LockTimeToSequence(isSeconds, lockTime)
+		if err != nil {
+			t.Fatalf("LockTimeToSequence: %v", err)
+		}
+		return sequence
+	}
+	spend := types.NewTransaction()
+	spend.Version = 2
+	sequences := []uint32{newSequence(false, 3), newSequence(false, 2),
+		newSequence(true, 1024)}
+	for i, prevOut := range prevOuts {
+		txIn := types.NewTxInput(prevOut, nil)
+		txIn.Sequence = sequences[i]
+		spend.AddTxIn(txIn)
+	}
+	spendTx := types.NewTx(spend)
+
+	lock, err := b.calcSequenceLock(nil, spendTx, view, true)
+	if err != nil {
+		t.Fatalf("calcSequenceLock: %v", err)
+	}
+	// The height locks are 2+3-1 and 4+2-1, the time lock 1024 seconds
+	// after the past median time.
+	if lock.BlockHeight != 5 {
+		t.Errorf("got lock height %d, want 5", lock.BlockHeight)
+	}
+	if want := medianTime.Unix() + 1024 - 1; lock.Time != want {
+		t.Errorf("got lock time %d, want %d", lock.Time, want)
+	}
+	if SequenceLockActive(lock, 5, medianTime.Add(time.Hour)) {
+		t.Error("lock active at its height")
+	}
+	if SequenceLockActive(lock, 6, medianTime.Add(1023*time.Second)) {
+		t.Error("lock active at its time")
+	}
+	if !SequenceLockActive(lock, 6, medianTime.Add(1024*time.Second)) {
+		t.Error("lock not active after its height and time")
+	}
+
+	// The inputs whose relative lock times are disabled aren't locked, nor
+	// are the transactions before version 2.
+	for i := range spend.TxIn {
+		spend.TxIn[i].Sequence = types.MaxTxInSequenceNum
+	}
+	lock, err = b.calcSequenceLock(nil, types.NewTx(spend), view, true)
+	if err != nil || lock.BlockHeight != -1 || lock.Time != -1 {
+		t.Errorf("disabled lock times: got %v, %v, want no lock", lock, err)
+	}
+	spend.Version = 1
+	spend.TxIn[0].Sequence = sequences[0]
+	lock, err = b.calcSequenceLock(nil, types.NewTx(spend), view, true)
+	if err != nil || lock.BlockHeight != -1 || lock.Time != -1 {
+		t.Errorf("version 1: got %v, %v, want no lock", lock, err)
+	}
+
+	// An input without output is an error.
+	spend.Version = 2
+	spend.TxIn[0].PreviousOut = *types.NewOutPoint(&hash.Hash{0x02}, 0)
+	_, err = b.calcSequenceLock(nil, types.NewTx(spend), view, true)
+	if rerr, ok := err.(RuleError); !ok || rerr.ErrorCode != ErrMissingTxOut {
+		t.Errorf("got %v, want ErrMissingTxOut", err)
+	}
+}
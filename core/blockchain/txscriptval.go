@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"math"
 	"runtime"
+	"sync"
 
 	"github.com/Qitmeer/qitmeer/core/types"
 	"github.com/Qitmeer/qitmeer/engine/txscript"
@@ -53,62 +54,65 @@ out:
 	for {
 		select {
 		case txVI := <-v.validateChan:
-			// Ensure the referenced input transaction is available.
-			txIn := txVI.txIn
-			utxo := v.utxoView.LookupEntry(txIn.PreviousOut)
-			if utxo == nil {
-				str := fmt.Sprintf("unable to find unspent "+
-					"output %v referenced from "+
-					"transaction %s:%d",
-					txIn.PreviousOut, txVI.tx.Hash(),
-					txVI.txInIndex)
-				err := ruleError(ErrMissingTxOut, str)
-				v.sendResult(err)
-				break out
-			}
-
-			// Ensure the referenced input transaction public key
-			// script is available.
-			pkScript := utxo.PkScript()
-			sigScript := txIn.SignScript
-			vm, err := txscript.NewEngine(pkScript, txVI.tx.Transaction(),
-				txVI.txInIndex, v.flags, txscript.DefaultScriptVersion, v.sigCache)
+			err := v.validateItem(txVI)
+			v.sendResult(err)
 			if err != nil {
-				str := fmt.Sprintf("failed to parse input "+
-					"%s:%d which references output %v - "+
-					"%v (input script "+
-					"bytes %x, prev output script bytes %x)",
-					txVI.tx.Hash(), txVI.txInIndex,
-					txIn.PreviousOut, err,
-					sigScript, pkScript)
-				err := ruleError(ErrScriptMalformed, str)
-				v.sendResult(err)
-				break out
-			}
-
-			// Execute the script pair.
-			if err := vm.Execute(); err != nil {
-				str := fmt.Sprintf("failed to validate input "+
-					"%s:%d which references output %v - "+
-					"%v (input script "+
-					"bytes %x, prev output script bytes %x)",
-					txVI.tx.Hash(), txVI.txInIndex,
-					txIn.PreviousOut, err,
-					sigScript, pkScript)
-				err := ruleError(ErrScriptValidation, str)
-				v.sendResult(err)
 				break out
 			}
 
-			// Validation succeeded.
-			v.sendResult(nil)
-
 		case <-v.quitChan:
 			break out
 		}
 	}
 }
 
+// validateItem validates the script pair of the passed transaction input.
+func (v *txValidator) validateItem(txVI *txValidateItem) error {
+	// Ensure the referenced input transaction is available.
+	txIn := txVI.txIn
+	utxo := v.utxoView.LookupEntry(txIn.PreviousOut)
+	if utxo == nil {
+		str := fmt.Sprintf("unable to find unspent "+
+			"output %v referenced from "+
+			"transaction %s:%d",
+			txIn.PreviousOut, txVI.tx.Hash(),
+			txVI.txInIndex)
+		return ruleError(ErrMissingTxOut, str)
+	}
+
+	// Ensure the referenced input transaction public key
+	// script is available.
+	pkScript := utxo.PkScript()
+	sigScript := txIn.SignScript
+	vm, err := txscript.NewEngine(pkScript, txVI.tx.Transaction(),
+		txVI.txInIndex, v.flags, txscript.DefaultScriptVersion, v.sigCache)
+	if err != nil {
+		str := fmt.Sprintf("failed to parse input "+
+			"%s:%d which references output %v - "+
+			"%v (input script "+
+			"bytes %x, prev output script bytes %x)",
+			txVI.tx.Hash(), txVI.txInIndex,
+			txIn.PreviousOut, err,
+			sigScript, pkScript)
+		return ruleError(ErrScriptMalformed, str)
+	}
+
+	// Execute the script pair.
+	if err := vm.Execute(); err != nil {
+		str := fmt.Sprintf("failed to validate input "+
+			"%s:%d which references output %v - "+
+			"%v (input script "+
+			"bytes %x, prev output script bytes %x)",
+			txVI.tx.Hash(), txVI.txInIndex,
+			txIn.PreviousOut, err,
+			sigScript, pkScript)
+		return ruleError(ErrScriptValidation, str)
+	}
+
+	// Validation succeeded.
+	return nil
+}
+
 // Validate validates the scripts for all of the passed transaction inputs using
 // multiple goroutines.
 func (v *txValidator) Validate(items []*txValidateItem) error {
@@ -206,6 +210,69 @@ func ValidateTransactionScripts(tx *types.Tx, utxoView *UtxoViewpoint, flags txs
 
 }
 
+// ValidateTransactionsScripts validates the scripts of the passed transactions
+// using one pool of runtime.NumCPU() goroutines for all of their inputs, unlike
+// ValidateTransactionScripts which starts a pool for each transaction.  Since
+// one invalid transaction doesn't stop the validation of the others, the
+// result of each transaction is returned, nil when its scripts are valid, or
+// else the error of its first invalid input.
+func ValidateTransactionsScripts(txs []*types.Tx, utxoView *UtxoViewpoint, flags txscript.ScriptFlags, sigCache *txscript.SigCache) []error {
+	// Collect all of the transaction inputs and required information for
+	// validation, along with the index of their transaction.
+	var txValItems []*txValidateItem
+	var txIndexes []int
+	for txIdx, tx := range txs {
+		for txInIdx, txIn := range tx.Transaction().TxIn {
+			// Skip coinbases.
+			if txIn.PreviousOut.OutIndex == math.MaxUint32 {
+				continue
+			}
+
+			txVI := &txValidateItem{
+				txInIndex: txInIdx,
+				txIn:      txIn,
+				tx:        tx,
+			}
+			txValItems = append(txValItems, txVI)
+			txIndexes = append(txIndexes, txIdx)
+		}
+	}
+
+	// Use one goroutine per processor core: the validation of each item is
+	// CPU bound and never blocks, so more goroutines wouldn't run faster.
+	maxGoRoutines := runtime.NumCPU()
+	if maxGoRoutines > len(txValItems) {
+		maxGoRoutines = len(txValItems)
+	}
+	v := newTxValidator(utxoView, flags, sigCache)
+	itemErrs := make([]error, len(txValItems))
+	items := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < maxGoRoutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range items {
+				itemErrs[idx] = v.validateItem(txValItems[idx])
+			}
+		}()
+	}
+	for idx := range txValItems {
+		items <- idx
+	}
+	close(items)
+	wg.Wait()
+
+	errs := make([]error, len(txs))
+	for idx, err := range itemErrs {
+		txIdx := txIndexes[idx]
+		if err != nil && errs[txIdx] == nil {
+			errs[txIdx] = err
+		}
+	}
+	return errs
+}
+
 // checkBlockScripts executes and validates the scripts for all transactions in
 // the passed block using multiple goroutines.
 // txTree = true is TxTreeRegular, txTree = false is TxTreeStake.
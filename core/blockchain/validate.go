@@ -97,9 +97,8 @@ func (b *BlockChain) checkBlockSanity(block *types.SerializedBlock, timeSource M
 	// checks.  The tree here and checks the merkle root
 	// after the following checks, but there is no reason not to check the
 	// merkle root matches here.
-	paMerkles := merkle.BuildParentsMerkleTreeStore(msgBlock.Parents)
-	paMerkleRoot := paMerkles[len(paMerkles)-1]
-	if !header.ParentRoot.IsEqual(paMerkleRoot) {
+	paMerkleRoot := merkle.ComputeParentsRoot(msgBlock.Parents)
+	if !header.ParentRoot.IsEqual(&paMerkleRoot) {
 		str := fmt.Sprintf("block parents merkle root is invalid - block "+
 			"header indicates %v, but calculated value is %v",
 			&header.ParentRoot, paMerkleRoot)
@@ -729,11 +728,15 @@ func (b *BlockChain) checkBlockHeaderContext(block *types.SerializedBlock, prevN
 // signature operations per block, invalid values in relation to the expected
 // block subsidy, or fail transaction script validation.
 //
+// The inputs of the transactions are checked against the passed parameters,
+// which are the chain parameters except for block templates built with
+// relaxed ones.
+//
 // The CheckConnectBlockTemplate function makes use of this function to perform
 // the bulk of its work.
 //
 // This function MUST be called with the chain state lock held (for writes).
-func (b *BlockChain) checkConnectBlock(node *blockNode, block *types.SerializedBlock, utxoView *UtxoViewpoint, stxos *[]SpentTxOut) error {
+func (b *BlockChain) checkConnectBlock(node *blockNode, block *types.SerializedBlock, utxoView *UtxoViewpoint, stxos *[]SpentTxOut, inputParams *params.Params) error {
 	// If the side chain blocks end up in the database, a call to
 	// CheckBlockSanity should be done here in case a previous version
 	// allowed a block that is no longer valid.  However, since the
@@ -783,7 +786,7 @@ func (b *BlockChain) checkConnectBlock(node *blockNode, block *types.SerializedB
 		return err
 	}
 
-	err = b.checkTransactionsAndConnect(node, block, b.subsidyCache, utxoView, stxos)
+	err = b.checkTransactionsAndConnect(node, block, b.subsidyCache, utxoView, stxos, inputParams)
 	if err != nil {
 		log.Trace("checkTransactionsAndConnect failed", "err", err)
 		return err
@@ -851,7 +854,7 @@ func (b *BlockChain) consensusScriptVerifyFlags(node *blockNode) (txscript.Scrip
 // transaction inputs for a transaction list given a predetermined TxStore.
 // After ensuring the transaction is valid, the transaction is connected to the
 // UTXO viewpoint.  TxTree true == Regular, false == Stake
-func (b *BlockChain) checkTransactionsAndConnect(node *blockNode, block *types.SerializedBlock, subsidyCache *SubsidyCache, utxoView *UtxoViewpoint, stxos *[]SpentTxOut) error {
+func (b *BlockChain) checkTransactionsAndConnect(node *blockNode, block *types.SerializedBlock, subsidyCache *SubsidyCache, utxoView *UtxoViewpoint, stxos *[]SpentTxOut, inputParams *params.Params) error {
 	transactions := block.Transactions()
 	totalSigOpCost := 0
 	for _, tx := range transactions {
@@ -874,7 +877,7 @@ func (b *BlockChain) checkTransactionsAndConnect(node *blockNode, block *types.S
 		if tx.IsDuplicate && !tx.Tx.IsCoinBase() {
 			continue
 		}
-		txFee, err := CheckTransactionInputs(tx, utxoView, b.params, b)
+		txFee, err := CheckTransactionInputs(tx, utxoView, inputParams, b)
 		if err != nil {
 			return err
 		}
@@ -1147,6 +1150,17 @@ func CheckTransactionInputs(tx *types.Tx, utxoView *UtxoViewpoint, chainParams *
 //
 // This function is safe for concurrent access.
 func (b *BlockChain) CheckConnectBlockTemplate(block *types.SerializedBlock) error {
+	return b.CheckConnectBlockTemplateWithParams(block, b.params)
+}
+
+// CheckConnectBlockTemplateWithParams is like CheckConnectBlockTemplate, but
+// checks the inputs of the transactions of the block against the passed
+// parameters instead of the chain ones, such as the ones without coinbase
+// maturity of a regtest mining policy.  The chain itself still rejects the
+// block when its inputs break the chain parameters.
+//
+// This function is safe for concurrent access.
+func (b *BlockChain) CheckConnectBlockTemplateWithParams(block *types.SerializedBlock, inputParams *params.Params) error {
 	b.ChainRLock()
 	defer b.ChainRUnlock()
 
@@ -1191,7 +1205,7 @@ func (b *BlockChain) CheckConnectBlockTemplate(block *types.SerializedBlock) err
 	if err != nil {
 		return err
 	}
-	err = b.checkConnectBlock(newNode, block, view, nil)
+	err = b.checkConnectBlock(newNode, block, view, nil, inputParams)
 	if err != nil {
 		return err
 	}
@@ -3,7 +3,12 @@ package blockchain
 import (
 	"bytes"
 	"encoding/hex"
+	"errors"
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/blockdag"
 	"github.com/Qitmeer/qitmeer/core/types"
+	"github.com/Qitmeer/qitmeer/database"
+	"github.com/Qitmeer/qitmeer/engine/txscript"
 	"github.com/Qitmeer/qitmeer/params"
 	"testing"
 )
@@ -65,3 +70,108 @@ func checkTransactionSanityForAllNet(tx *types.Transaction) error {
 	}
 	return nil
 }
+
+// dagTestDB is a database without any block, for the checks which only
+// fall back on it.
+type dagTestDB struct{}
+
+func (dagTestDB) Type() string { return "dagtest" }
+
+func (dagTestDB) View(fn func(tx database.Tx) error) error {
+	return errors.New("no database")
+}
+
+func (dagTestDB) Update(fn func(tx database.Tx) error) error {
+	return errors.New("no database")
+}
+
+func (dagTestDB) Close() error { return nil }
+
+// dagTestBlock is a block of the DAG of the chain tests.
+type dagTestBlock struct {
+	hash    hash.Hash
+	parents []uint
+}
+
+func (b *dagTestBlock) GetHash() *hash.Hash { return &b.hash }
+func (b *dagTestBlock) GetParents() []uint  { return b.parents }
+func (b *dagTestBlock) GetTimestamp() int64 { return 0 }
+func (b *dagTestBlock) GetWeight() uint64   { return 1 }
+
+// newDAGTestChain returns a chain of the passed number of blocks from the
+// genesis, on the privnet parameters, along with the hashes of its blocks by
+// height.  It has no database, so only the checks which don't need one
+// succeed.
+func newDAGTestChain(t *testing.T, chainLen int) (*BlockChain, []*hash.Hash) {
+	ids := make(map[hash.Hash]uint)
+	dag := &blockdag.BlockDAG{}
+	dag.Init("phantom", func(int64) int64 { return 1 }, -1,
+		func(h *hash.Hash) uint {
+			if id, ok := ids[*h]; ok {
+				return id
+			}
+			return blockdag.MaxId
+		})
+	var hashes []*hash.Hash
+	for i := 0; i < chainLen; i++ {
+		block := &dagTestBlock{hash: hash.Hash{byte(i + 1)}}
+		if i > 0 {
+			block.parents = []uint{uint(i - 1)}
+		}
+		_, ib := dag.AddBlock(block)
+		if ib == nil {
+			t.Fatalf("failed to add block %d", i)
+		}
+		ids[block.hash] = ib.GetID()
+		hashes = append(hashes, ib.GetHash())
+	}
+	b := &BlockChain{db: dagTestDB{}, bd: dag,
+		params: &params.PrivNetParams}
+	return b, hashes
+}
+
+// TestCheckTransactionInputsMaturity ensures the spend of a coinbase output is
+// rejected before the coinbase maturity of the passed parameters, and accepted
+// with the parameters without maturity of the regtest mining policy.
+func TestCheckTransactionInputsMaturity(t *testing.T) {
+	// A chain of blocks from the genesis, the coinbase being in the first
+	// block after it.
+	const chainLen = 6
+	b, hashes := newDAGTestChain(t, chainLen)
+
+	pkScript := []byte{txscript.OP_TRUE}
+	coinbase := types.NewTransaction()
+	coinbase.AddTxIn(types.NewTxInput(types.NewOutPoint(&hash.ZeroHash,
+		types.MaxPrevOutIndex), []byte{0x51, 0x51}))
+	coinbase.AddTxOut(types.NewTxOutput(100000, pkScript))
+	coinbaseTx := types.NewTx(coinbase)
+
+	spend := types.NewTransaction()
+	spend.AddTxIn(types.NewTxInput(types.NewOutPoint(coinbaseTx.Hash(), 0),
+		nil))
+	spend.AddTxOut(types.NewTxOutput(99000, pkScript))
+	spendTx := types.NewTx(spend)
+
+	view := NewUtxoViewpoint()
+	view.AddTxOuts(coinbaseTx, hashes[1])
+	view.SetViewpoints([]*hash.Hash{hashes[chainLen-1]})
+
+	// The coinbase is only chainLen-2 blocks deep.
+	if chainLen-2 >= int(params.PrivNetParams.CoinbaseMaturity) {
+		t.Fatal("the chain is too long for the coinbase to be immature")
+	}
+	_, err := CheckTransactionInputs(spendTx, view, &params.PrivNetParams, b)
+	if rerr, ok := err.(RuleError); !ok || rerr.ErrorCode != ErrImmatureSpend {
+		t.Fatalf("got %v, want ErrImmatureSpend", err)
+	}
+
+	relaxed := params.PrivNetParams
+	relaxed.CoinbaseMaturity = 0
+	fee, err := CheckTransactionInputs(spendTx, view, &relaxed, b)
+	if err != nil {
+		t.Fatalf("regtest spend rejected: %v", err)
+	}
+	if fee != 1000 {
+		t.Errorf("got fee %d, want 1000", fee)
+	}
+}
@@ -1152,6 +1152,39 @@ func (bd *BlockDAG) GetBlues(parents *IdSet) uint {
 	return bd.instance.GetBlues(parents)
 }
 
+// blueSetsAlgorithm is implemented by the consensus algorithms which can
+// report the colors of the blocks merged by a new block.
+type blueSetsAlgorithm interface {
+	GetBlueSets(parents *IdSet) (uint, *IdSet, *IdSet)
+}
+
+// GetBlueSets returns the same blue count as GetBlues along with the hashes of
+// the blue and red blocks a block with the given parents merges into the past
+// of its main parent.  The main parent is part of the blue set.  Both sets are
+// ordered by the order the blocks were added to this DAG, which may differ
+// between nodes.  The sets are empty for algorithms which don't color blocks.
+func (bd *BlockDAG) GetBlueSets(parents *IdSet) (uint, []*hash.Hash, []*hash.Hash) {
+	bd.stateLock.Lock()
+	defer bd.stateLock.Unlock()
+
+	instance, ok := bd.instance.(blueSetsAlgorithm)
+	if !ok {
+		return bd.instance.GetBlues(parents), nil, nil
+	}
+	blues, blueSet, redSet := instance.GetBlueSets(parents)
+	return blues, bd.getHashesByIds(blueSet), bd.getHashesByIds(redSet)
+}
+
+// getHashesByIds returns the hashes of the blocks in the passed set ordered
+// by id.
+func (bd *BlockDAG) getHashesByIds(ids *IdSet) []*hash.Hash {
+	hashes := make([]*hash.Hash, 0, ids.Size())
+	for _, id := range ids.SortList(false) {
+		hashes = append(hashes, bd.getBlockById(id).GetHash())
+	}
+	return hashes
+}
+
 // IsBlue
 func (bd *BlockDAG) IsBlue(id uint) bool {
 	bd.stateLock.Lock()
@@ -611,12 +611,35 @@ func (ph *Phantom) Load(dbTx database.Tx) error {
 }
 
 func (ph *Phantom) GetBlues(parents *IdSet) uint {
-	if parents == nil || parents.IsEmpty() {
+	pb := ph.colorVirtualBlock(parents)
+	if pb == nil {
 		return 0
 	}
+	return pb.blueNum
+}
+
+// GetBlueSets returns the blue count of a block with the given parents along
+// with the blocks it merges into the past of its main parent, split by color.
+// The main parent is part of the blue set.
+func (ph *Phantom) GetBlueSets(parents *IdSet) (uint, *IdSet, *IdSet) {
+	pb := ph.colorVirtualBlock(parents)
+	if pb == nil {
+		return 0, NewIdSet(), NewIdSet()
+	}
+	blueSet := pb.blueDiffAnticone.Clone()
+	blueSet.Add(pb.mainParent)
+	return pb.blueNum, blueSet, pb.redDiffAnticone.Clone()
+}
+
+// colorVirtualBlock returns a virtual block with the given parents whose
+// anticone was colored, or nil if any of the parents is unknown.
+func (ph *Phantom) colorVirtualBlock(parents *IdSet) *PhantomBlock {
+	if parents == nil || parents.IsEmpty() {
+		return nil
+	}
 	for k := range parents.GetMap() {
 		if !ph.bd.hasBlockById(k) {
-			return 0
+			return nil
 		}
 	}
 
@@ -636,7 +659,7 @@ func (ph *Phantom) GetBlues(parents *IdSet) uint {
 
 	ph.calculateBlueSet(pb, diffAnticone)
 
-	return pb.blueNum
+	return pb
 }
 
 func (ph *Phantom) IsBlue(id uint) bool {
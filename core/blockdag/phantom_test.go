@@ -2,6 +2,7 @@ package blockdag
 
 import (
 	"fmt"
+	"github.com/Qitmeer/qitmeer/common/hash"
 	"strconv"
 	"testing"
 )
@@ -277,3 +278,48 @@ func Test_GetBlockConcurrency(t *testing.T) {
 		t.Fatal()
 	}
 }
+
+func Test_ScoreTips(t *testing.T) {
+	ibd := InitBlockDAG(phantom, "PH_fig2-blocks")
+	if ibd == nil {
+		t.FailNow()
+	}
+	tips := bd.GetTips().List()
+	unknown := hash.Hash{0xff}
+	scores := ScoreTips(&bd, append(tips, &unknown))
+	if len(scores) != len(tips) {
+		t.Fatalf("got %d scores, want %d", len(scores), len(tips))
+	}
+
+	// The blue score of a tip is the blue count of a child of the tip,
+	// minus the tip itself.
+	for i, score := range scores {
+		ib := bd.GetBlock(score.Hash)
+		parents := NewIdSet()
+		parents.Add(ib.GetID())
+		if want := bd.GetBlues(parents) - 1; score.BlueScore != want {
+			t.Errorf("tip %s: got blue score %d, want %d",
+				getBlockTag(ib.GetID()), score.BlueScore, want)
+		}
+		if score.Height != ib.GetHeight() {
+			t.Errorf("tip %s: got height %d, want %d",
+				getBlockTag(ib.GetID()), score.Height, ib.GetHeight())
+		}
+		if i > 0 && score.BlueScore > scores[i-1].BlueScore {
+			t.Errorf("tip %s ranked after a lower blue score",
+				getBlockTag(ib.GetID()))
+		}
+	}
+
+	// The ranking doesn't depend on the order of the tips.
+	reversed := make([]*hash.Hash, len(tips))
+	for i, tip := range tips {
+		reversed[len(tips)-1-i] = tip
+	}
+	for i, score := range ScoreTips(&bd, reversed) {
+		if *score.Hash != *scores[i].Hash {
+			t.Errorf("reversed tip %d: got %v, want %v", i, score.Hash,
+				scores[i].Hash)
+		}
+	}
+}
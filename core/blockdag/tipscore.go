@@ -0,0 +1,53 @@
+package blockdag
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/Qitmeer/qitmeer/common/hash"
+)
+
+// TipScore is the quality of a tip of the DAG as a parent of the next block.
+type TipScore struct {
+	Hash *hash.Hash
+
+	// BlueScore is the number of blue blocks in the past of the tip, or
+	// zero for algorithms which don't color blocks.
+	BlueScore uint
+
+	// Height is the height of the tip on its main chain.
+	Height uint
+}
+
+// ScoreTips returns the scores of the passed tips of the DAG ranked from the
+// best parent: by decreasing blue score, then height, with ties broken by
+// hash so that every caller ranks the same tips the same way.  The tips
+// unknown to the DAG are left out.  The DAG isn't modified.
+func ScoreTips(bd *BlockDAG, tips []*hash.Hash) []TipScore {
+	bd.stateLock.Lock()
+	scores := make([]TipScore, 0, len(tips))
+	for _, h := range tips {
+		ib := bd.getBlock(h)
+		if ib == nil {
+			continue
+		}
+		score := TipScore{Hash: ib.GetHash(), Height: ib.GetHeight()}
+		if pb, ok := ib.(*PhantomBlock); ok {
+			score.BlueScore = pb.blueNum
+		}
+		scores = append(scores, score)
+	}
+	bd.stateLock.Unlock()
+
+	sort.Slice(scores, func(i, j int) bool {
+		si, sj := scores[i], scores[j]
+		if si.BlueScore != sj.BlueScore {
+			return si.BlueScore > sj.BlueScore
+		}
+		if si.Height != sj.Height {
+			return si.Height > sj.Height
+		}
+		return bytes.Compare(si.Hash[:], sj.Hash[:]) < 0
+	})
+	return scores
+}
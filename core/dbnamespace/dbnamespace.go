@@ -67,4 +67,8 @@ var (
 	// DagInfoBucketName is the name of the db bucket used to house the
 	// dag information
 	DagInfoBucketName = []byte("daginfo")
+
+	// FeeEstimatorKeyName is the name of the db key used to store the
+	// state of the fee estimator between runs.
+	FeeEstimatorKeyName = []byte("feeestimator")
 )
@@ -17,6 +17,17 @@ type PowDiffReference struct {
 	CuckarooMinDiff  uint64 `json:"cuckaroo_min_diff,omitempty"`
 	CuckaroomMinDiff uint64 `json:"cuckaroom_min_diff,omitempty"`
 	CuckatooMinDiff  uint64 `json:"cuckatoo_min_diff,omitempty"`
+
+	//cuckoo graph edge bits range and proof size
+	CuckarooMinEdgeBits  uint8 `json:"cuckaroo_min_edge_bits,omitempty"`
+	CuckarooMaxEdgeBits  uint8 `json:"cuckaroo_max_edge_bits,omitempty"`
+	CuckaroomMinEdgeBits uint8 `json:"cuckaroom_min_edge_bits,omitempty"`
+	CuckaroomMaxEdgeBits uint8 `json:"cuckaroom_max_edge_bits,omitempty"`
+	CuckatooMinEdgeBits  uint8 `json:"cuckatoo_min_edge_bits,omitempty"`
+	CuckatooMaxEdgeBits  uint8 `json:"cuckatoo_max_edge_bits,omitempty"`
+	CuckarooProofSize    int   `json:"cuckaroo_proof_size,omitempty"`
+	CuckaroomProofSize   int   `json:"cuckaroom_proof_size,omitempty"`
+	CuckatooProofSize    int   `json:"cuckatoo_proof_size,omitempty"`
 }
 
 //LL(getblocktemplate RPC) 2018-10-28
@@ -77,6 +88,8 @@ type GetBlockTemplateResult struct {
 	CurTime       int64                      `json:"curtime"`
 	Height        int64                      `json:"height"`
 	Blues         int64                      `json:"blues"`
+	BlueSet       []string                   `json:"blueset,omitempty"`
+	RedSet        []string                   `json:"redset,omitempty"`
 	PreviousHash  string                     `json:"previousblockhash"`
 	SigOpLimit    int64                      `json:"sigoplimit,omitempty"`
 	SizeLimit     int64                      `json:"sizelimit,omitempty"`
@@ -109,4 +122,61 @@ type GetBlockTemplateResult struct {
 	// Block proposal from BIP 0023.
 	Capabilities  []string `json:"capabilities,omitempty"`
 	RejectReasion string   `json:"reject-reason,omitempty"`
+
+	// TemplateID identifies the template to request a delta of the
+	// following templates against with getblocktemplatedelta.
+	TemplateID string `json:"templateid,omitempty"`
+}
+
+// BlockTemplateNotification models the data pushed to the subscribers of new
+// block templates.
+type BlockTemplateNotification struct {
+	Height           int64            `json:"height"`
+	Parents          []string         `json:"parents"`
+	PowDiffReference PowDiffReference `json:"pow_diff_reference"`
+}
+
+// DecodeBlockTemplateTx models a transaction of the decodeblocktemplate
+// command.  Fee and SigOps are the ones reported by the template, and are
+// absent when a block is decoded.
+type DecodeBlockTemplateTx struct {
+	TxRawResult
+	Fee    *int64 `json:"fee,omitempty"`
+	SigOps *int64 `json:"sigops,omitempty"`
+}
+
+// DecodeBlockTemplateCoinbase models the breakdown of the coinbase of the
+// decodeblocktemplate command.  Reward is paid to the miner outputs, Tax to
+// the organization and the null data outputs carry Commitments.  Subsidy and
+// Fees are the ones reported by the template, and are absent when a block is
+// decoded.
+type DecodeBlockTemplateCoinbase struct {
+	Subsidy     *int64   `json:"subsidy,omitempty"`
+	Fees        *int64   `json:"fees,omitempty"`
+	Reward      uint64   `json:"reward"`
+	Tax         uint64   `json:"tax"`
+	Commitments []string `json:"commitments"`
+}
+
+// DecodeBlockTemplateResult models the data returned from the
+// decodeblocktemplate command: a block template, or a solved block, with its
+// transactions decoded.  The fields of the template which don't belong to the
+// block are absent when a block is decoded.
+type DecodeBlockTemplateResult struct {
+	Hash         string                      `json:"hash"`
+	Version      uint32                      `json:"version"`
+	ParentRoot   string                      `json:"parentroot"`
+	TxRoot       string                      `json:"txroot"`
+	StateRoot    string                      `json:"stateroot"`
+	Bits         string                      `json:"bits"`
+	Timestamp    string                      `json:"timestamp"`
+	Pow          PowResult                   `json:"pow"`
+	Parents      []string                    `json:"parents"`
+	Weight       int                         `json:"weight"`
+	Height       uint64                      `json:"height,omitempty"`
+	Blues        int64                       `json:"blues,omitempty"`
+	BlueSet      []string                    `json:"blueset,omitempty"`
+	RedSet       []string                    `json:"redset,omitempty"`
+	Transactions []DecodeBlockTemplateTx     `json:"transactions"`
+	Coinbase     DecodeBlockTemplateCoinbase `json:"coinbase"`
 }
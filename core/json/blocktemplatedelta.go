@@ -0,0 +1,186 @@
+// Copyright (c) 2017-2018 The qitmeer developers
+
+package json
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// GetBlockTemplateDeltaTx models a transaction added by a block template
+// delta, at its index in the transactions of the template.
+type GetBlockTemplateDeltaTx struct {
+	Index int `json:"index"`
+	GetBlockTemplateResultTx
+}
+
+// GetBlockTemplateDeltaResult models the data returned from the
+// getblocktemplatedelta command: the changes of the template of TemplateID
+// from the template of BaseID previously served to the client.  The
+// transactions of the template are the ones of the base without the Removed
+// indices, into which the Added transactions are inserted at their indices.
+// The other fields replace the ones of the base, and the fields not carried by
+// the delta are the ones of the base.
+type GetBlockTemplateDeltaResult struct {
+	BaseID     string                    `json:"baseid"`
+	TemplateID string                    `json:"templateid"`
+	Removed    []int                     `json:"removed"`
+	Added      []GetBlockTemplateDeltaTx `json:"added"`
+
+	StateRoot        string                     `json:"stateroot"`
+	CurTime          int64                      `json:"curtime"`
+	MinTime          int64                      `json:"mintime,omitempty"`
+	MaxTime          int64                      `json:"maxtime,omitempty"`
+	PowDiffReference PowDiffReference           `json:"pow_diff_reference"`
+	CoinbaseAux      *GetBlockTemplateResultAux `json:"coinbaseaux,omitempty"`
+	CoinbaseTxn      *GetBlockTemplateResultTx  `json:"coinbasetxn,omitempty"`
+	CoinbaseValue    *uint64                    `json:"coinbasevalue,omitempty"`
+	SubmitOld        *bool                      `json:"submitold,omitempty"`
+}
+
+// deltaFixedFields returns the passed template without the fields carried by
+// the deltas, which are the fields a delta can't change.
+func deltaFixedFields(r *GetBlockTemplateResult) GetBlockTemplateResult {
+	fixed := *r
+	fixed.TemplateID = ""
+	fixed.Transactions = nil
+	fixed.StateRoot = ""
+	fixed.CurTime = 0
+	fixed.MinTime = 0
+	fixed.MaxTime = 0
+	fixed.PowDiffReference = PowDiffReference{}
+	fixed.CoinbaseAux = nil
+	fixed.CoinbaseTxn = nil
+	fixed.CoinbaseValue = nil
+	fixed.SubmitOld = nil
+	return fixed
+}
+
+// remapDepends returns the passed depends, which are 1-based indices of the
+// transactions of a base template, as the indices of the same transactions in
+// a template built from it.  The transactions not in the template are
+// dropped.
+func remapDepends(depends []int64, indices map[int]int) []int64 {
+	remapped := make([]int64, 0, len(depends))
+	for _, d := range depends {
+		if i, ok := indices[int(d)-1]; ok {
+			remapped = append(remapped, int64(i)+1)
+		}
+	}
+	return remapped
+}
+
+// TemplateDelta returns the delta of the template cur from the template base,
+// or nil when cur differs from base by a field the deltas don't carry, such as
+// when it's for other parents.
+func TemplateDelta(base, cur *GetBlockTemplateResult) *GetBlockTemplateDeltaResult {
+	if !reflect.DeepEqual(deltaFixedFields(base), deltaFixedFields(cur)) {
+		return nil
+	}
+	delta := &GetBlockTemplateDeltaResult{
+		BaseID:           base.TemplateID,
+		TemplateID:       cur.TemplateID,
+		Removed:          []int{},
+		Added:            []GetBlockTemplateDeltaTx{},
+		StateRoot:        cur.StateRoot,
+		CurTime:          cur.CurTime,
+		MinTime:          cur.MinTime,
+		MaxTime:          cur.MaxTime,
+		PowDiffReference: cur.PowDiffReference,
+		CoinbaseAux:      cur.CoinbaseAux,
+		CoinbaseTxn:      cur.CoinbaseTxn,
+		CoinbaseValue:    cur.CoinbaseValue,
+		SubmitOld:        cur.SubmitOld,
+	}
+
+	// The transactions of the base are kept in order.  The transactions of
+	// cur which are new, or out of the order of the kept ones, are added.
+	baseIndices := make(map[string]int, len(base.Transactions))
+	for i, tx := range base.Transactions {
+		baseIndices[tx.Hash] = i
+	}
+	indices := make(map[int]int, len(base.Transactions))
+	next := 0
+	for i, tx := range cur.Transactions {
+		if j, ok := baseIndices[tx.Hash]; ok && j >= next {
+			kept := base.Transactions[j]
+			kept.Depends = remapDepends(kept.Depends, indices)
+			if reflect.DeepEqual(kept, tx) {
+				for ; next < j; next++ {
+					delta.Removed = append(delta.Removed, next)
+				}
+				next = j + 1
+				indices[j] = i
+				continue
+			}
+		}
+		delta.Added = append(delta.Added, GetBlockTemplateDeltaTx{
+			Index:                    i,
+			GetBlockTemplateResultTx: tx,
+		})
+	}
+	for ; next < len(base.Transactions); next++ {
+		delta.Removed = append(delta.Removed, next)
+	}
+	return delta
+}
+
+// ApplyDelta returns the template the passed delta was computed for from the
+// template r it's a delta of.  The returned template shares the fields the
+// delta doesn't carry with r.
+func (r *GetBlockTemplateResult) ApplyDelta(delta *GetBlockTemplateDeltaResult) (*GetBlockTemplateResult, error) {
+	if delta.BaseID != r.TemplateID {
+		return nil, fmt.Errorf("delta of template %s applied to template %s",
+			delta.BaseID, r.TemplateID)
+	}
+	removed := make(map[int]struct{}, len(delta.Removed))
+	for _, i := range delta.Removed {
+		if i < 0 || i >= len(r.Transactions) {
+			return nil, fmt.Errorf("removed transaction %d out of the %d "+
+				"transactions of the template", i, len(r.Transactions))
+		}
+		removed[i] = struct{}{}
+	}
+	numTx := len(r.Transactions) - len(removed) + len(delta.Added)
+	transactions := make([]GetBlockTemplateResultTx, numTx)
+	added := make([]bool, numTx)
+	for k, tx := range delta.Added {
+		if tx.Index < 0 || tx.Index >= numTx ||
+			(k > 0 && tx.Index <= delta.Added[k-1].Index) {
+			return nil, fmt.Errorf("added transaction %s at invalid index %d",
+				tx.Hash, tx.Index)
+		}
+		transactions[tx.Index] = tx.GetBlockTemplateResultTx
+		added[tx.Index] = true
+	}
+
+	// The kept transactions fill the indices left in order.
+	indices := make(map[int]int, len(r.Transactions))
+	i := 0
+	for j, tx := range r.Transactions {
+		if _, ok := removed[j]; ok {
+			continue
+		}
+		for added[i] {
+			i++
+		}
+		tx.Depends = remapDepends(tx.Depends, indices)
+		transactions[i] = tx
+		indices[j] = i
+		i++
+	}
+
+	result := *r
+	result.TemplateID = delta.TemplateID
+	result.Transactions = transactions
+	result.StateRoot = delta.StateRoot
+	result.CurTime = delta.CurTime
+	result.MinTime = delta.MinTime
+	result.MaxTime = delta.MaxTime
+	result.PowDiffReference = delta.PowDiffReference
+	result.CoinbaseAux = delta.CoinbaseAux
+	result.CoinbaseTxn = delta.CoinbaseTxn
+	result.CoinbaseValue = delta.CoinbaseValue
+	result.SubmitOld = delta.SubmitOld
+	return &result, nil
+}
@@ -0,0 +1,111 @@
+package json
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// testTemplateTx returns a template transaction with the passed depends.
+func testTemplateTx(n int, depends ...int64) GetBlockTemplateResultTx {
+	return GetBlockTemplateResultTx{
+		Data:    fmt.Sprintf("%02x", n),
+		Hash:    fmt.Sprintf("%064x", n),
+		Depends: append([]int64{}, depends...),
+		Fee:     int64(n) * 100,
+		SigOps:  1,
+		Weight:  2000000,
+	}
+}
+
+// TestTemplateDelta ensures applying the delta of a template to its base
+// yields the template, once encoded, and that there's no delta of a template
+// for other parents.
+func TestTemplateDelta(t *testing.T) {
+	baseValue, curValue := uint64(5000000100), uint64(5000000900)
+	base := &GetBlockTemplateResult{
+		StateRoot:    fmt.Sprintf("%064x", 0),
+		CurTime:      1560000000,
+		Height:       10,
+		PreviousHash: fmt.Sprintf("%064x", 1),
+		Parents:      []GetBlockTemplateResultPt{{Hash: fmt.Sprintf("%064x", 1)}},
+		Transactions: []GetBlockTemplateResultTx{
+			testTemplateTx(1),
+			testTemplateTx(2, 1),
+			testTemplateTx(3),
+			testTemplateTx(4, 3),
+			testTemplateTx(5),
+		},
+		CoinbaseValue: &baseValue,
+		LongPollID:    "parents-10",
+		MinTime:       1559999000,
+		MaxTime:       1560007200,
+		TemplateID:    "base",
+	}
+	cur := *base
+	cur.CurTime = 1560000060
+	cur.MaxTime = 1560007260
+	cur.CoinbaseValue = &curValue
+	cur.PowDiffReference = PowDiffReference{Blake2bDBits: "1d00ffff"}
+	cur.TemplateID = "cur"
+	cur.Transactions = []GetBlockTemplateResultTx{
+		testTemplateTx(6),    // new
+		testTemplateTx(1),    // kept
+		testTemplateTx(3),    // kept, 2 is removed
+		testTemplateTx(7, 1), // new, depends on 6
+		testTemplateTx(4, 3), // kept, depends on 3
+		testTemplateTx(2, 2), // moved after 3, depends on 1
+	}
+
+	delta := TemplateDelta(base, &cur)
+	if delta == nil {
+		t.Fatalf("no delta of a template for the same parents")
+	}
+	if want := []int{1, 4}; !reflect.DeepEqual(delta.Removed, want) {
+		t.Errorf("got removed %v, want %v", delta.Removed, want)
+	}
+	var added []int
+	for _, tx := range delta.Added {
+		added = append(added, tx.Index)
+	}
+	if want := []int{0, 3, 5}; !reflect.DeepEqual(added, want) {
+		t.Errorf("got added %v, want %v", added, want)
+	}
+
+	// The delta is applied by the client to its decoded base.
+	decode := func(v interface{}, out interface{}) {
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("Marshal: %v", err)
+		}
+		if err := json.Unmarshal(encoded, out); err != nil {
+			t.Fatalf("Unmarshal: %v", err)
+		}
+	}
+	var clientBase, want GetBlockTemplateResult
+	var clientDelta GetBlockTemplateDeltaResult
+	decode(base, &clientBase)
+	decode(delta, &clientDelta)
+	decode(&cur, &want)
+	got, err := clientBase.ApplyDelta(&clientDelta)
+	if err != nil {
+		t.Fatalf("ApplyDelta: %v", err)
+	}
+	if !reflect.DeepEqual(got, &want) {
+		t.Errorf("got template %+v, want %+v", got, &want)
+	}
+
+	// The delta only applies to its base.
+	if _, err := want.ApplyDelta(&clientDelta); err == nil {
+		t.Errorf("delta applied to a template other than its base")
+	}
+
+	// A template for other parents is served in full.
+	other := cur
+	other.Height = 11
+	other.LongPollID = "parents-11"
+	if delta := TemplateDelta(base, &other); delta != nil {
+		t.Errorf("got delta %+v of a template for other parents", delta)
+	}
+}
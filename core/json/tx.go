@@ -2,9 +2,24 @@
 
 package json
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"io"
+)
 
 // TxRawResult models the data from the getrawtransaction command.
+//
+// The fields locating the transaction depend on its state:
+//   - confirmed: BlockHash and BlockOrder are those of the block holding the
+//     transaction, Confirmations counts the blocks confirming it and
+//     InMempool is false
+//   - unconfirmed: InMempool is true, Confirmations is 0 and BlockHash and
+//     BlockOrder are omitted
+//   - orphaned, which is neither in a block nor in the mempool, such as an
+//     orphan transaction: InMempool is false, Confirmations is 0 and
+//     BlockHash and BlockOrder are omitted
+//
+// A transaction which isn't found at all has no result.
 type TxRawResult struct {
 	Hex           string `json:"hex"`
 	Txid          string `json:"txid"`
@@ -20,20 +35,29 @@ type TxRawResult struct {
 	BlockOrder    uint64 `json:"blockorder,omitempty"`
 	TxIndex       uint32 `json:"txindex,omitempty"`
 	Confirmations int64  `json:"confirmations"`
+	InMempool     bool   `json:"inmempool,omitempty"`
 	Time          int64  `json:"time,omitempty"`
 	Blocktime     int64  `json:"blocktime,omitempty"`
 	Duplicate     bool   `json:"duplicate,omitempty"`
 }
 
+// MempoolTxNotification models the data pushed to the subscribers of new
+// mempool transactions.  Tx is only set for the verbose subscriptions.
+type MempoolTxNotification struct {
+	Txid string       `json:"txid"`
+	Tx   *TxRawResult `json:"tx,omitempty"`
+}
+
 // Vin models parts of the tx data.  It is defined separately since
 // getrawtransaction, decoderawtransaction, and searchrawtransaction use the
 // same structure.
 type Vin struct {
-	Coinbase  string     `json:"coinbase"`
-	Txid      string     `json:"txid"`
-	Vout      uint32     `json:"vout"`
-	Sequence  uint32     `json:"sequence"`
-	ScriptSig *ScriptSig `json:"scriptSig"`
+	Coinbase    string     `json:"coinbase"`
+	Txid        string     `json:"txid"`
+	Vout        uint32     `json:"vout"`
+	Sequence    uint32     `json:"sequence"`
+	ScriptSig   *ScriptSig `json:"scriptSig"`
+	Txinwitness []string   `json:"txinwitness,omitempty"`
 }
 
 // IsCoinBase returns a bool to show if a Vin is a Coinbase one or not.
@@ -55,15 +79,17 @@ func (v *Vin) MarshalJSON() ([]byte, error) {
 	}
 
 	txStruct := struct {
-		Txid      string     `json:"txid"`
-		Vout      uint32     `json:"vout"`
-		Sequence  uint32     `json:"sequence"`
-		ScriptSig *ScriptSig `json:"scriptSig"`
+		Txid        string     `json:"txid"`
+		Vout        uint32     `json:"vout"`
+		Sequence    uint32     `json:"sequence"`
+		ScriptSig   *ScriptSig `json:"scriptSig"`
+		Txinwitness []string   `json:"txinwitness,omitempty"`
 	}{
-		Txid:      v.Txid,
-		Vout:      v.Vout,
-		Sequence:  v.Sequence,
-		ScriptSig: v.ScriptSig,
+		Txid:        v.Txid,
+		Vout:        v.Vout,
+		Sequence:    v.Sequence,
+		ScriptSig:   v.ScriptSig,
+		Txinwitness: v.Txinwitness,
 	}
 	return json.Marshal(txStruct)
 }
@@ -95,12 +121,30 @@ type ScriptSig struct {
 
 // GetUtxoResult models the data from the GetUtxo command.
 type GetUtxoResult struct {
-	BestBlock     string             `json:"bestblock"`
-	Confirmations int64              `json:"confirmations"`
-	Amount        float64            `json:"amount"`
-	ScriptPubKey  ScriptPubKeyResult `json:"scriptPubKey"`
-	Version       int32              `json:"version"`
-	Coinbase      bool               `json:"coinbase"`
+	BestBlock      string             `json:"bestblock"`
+	Confirmations  int64              `json:"confirmations"`
+	Amount         float64            `json:"amount"`
+	ScriptPubKey   ScriptPubKeyResult `json:"scriptPubKey"`
+	Version        int32              `json:"version"`
+	Coinbase       bool               `json:"coinbase"`
+	Spendable      bool               `json:"spendable"`
+	MaturityHeight uint64             `json:"maturityheight"`
+}
+
+// GetMempoolEntryResult models the data from the getmempoolentry command.
+type GetMempoolEntryResult struct {
+	Size             int32    `json:"size"`
+	Fee              int64    `json:"fee"`
+	FeeRate          int64    `json:"feerate"`
+	Time             int64    `json:"time"`
+	Height           int64    `json:"height"`
+	StartingPriority float64  `json:"startingpriority"`
+	CurrentPriority  float64  `json:"currentpriority"`
+	AncestorCount    int      `json:"ancestorcount"`
+	AncestorSize     int64    `json:"ancestorsize"`
+	DescendantCount  int      `json:"descendantcount"`
+	DescendantSize   int64    `json:"descendantsize"`
+	Depends          []string `json:"depends"`
 }
 
 // GetRawTransactionsResult models the data from the getrawtransactions
@@ -121,6 +165,45 @@ type GetRawTransactionsResult struct {
 	Blocktime     int64        `json:"blocktime,omitempty"`
 }
 
+// GetRawTransactionsStream is a list of getrawtransactions results which can
+// be streamed by StreamJSON one element at a time, so that the encoding of a
+// large list is never held in memory as a whole.  It marshals as a plain
+// array of the results otherwise.
+type GetRawTransactionsStream []GetRawTransactionsResult
+
+// StreamJSON writes the results to w as a JSON array, encoding and flushing
+// them one by one.  The output is the same as the one of json.Marshal.
+func (s GetRawTransactionsStream) StreamJSON(w io.Writer) error {
+	flusher, _ := w.(interface{ Flush() })
+	sep := []byte{'['}
+	for i := range s {
+		b, err := json.Marshal(&s[i])
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(sep); err != nil {
+			return err
+		}
+		if _, err := w.Write(b); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		sep[0] = ','
+	}
+	if len(s) == 0 {
+		empty := "[]"
+		if s == nil {
+			empty = "null"
+		}
+		_, err := io.WriteString(w, empty)
+		return err
+	}
+	_, err := w.Write([]byte{']'})
+	return err
+}
+
 type VinPrevOut struct {
 	Coinbase  string     `json:"coinbase"`
 	Txid      string     `json:"txid"`
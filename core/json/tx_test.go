@@ -0,0 +1,84 @@
+package json
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"testing"
+)
+
+// testRawTransactions returns n getrawtransactions results of a typical size.
+func testRawTransactions(n int) GetRawTransactionsStream {
+	results := make(GetRawTransactionsStream, n)
+	for i := range results {
+		txid := fmt.Sprintf("%064x", i)
+		results[i] = GetRawTransactionsResult{
+			Hex:     string(bytes.Repeat([]byte{'a'}, 450)),
+			Txid:    txid,
+			Version: 1,
+			Vin: []VinPrevOut{{
+				Txid:      txid,
+				ScriptSig: &ScriptSig{Asm: "OP_DUP", Hex: "76"},
+				PrevOut:   &PrevOut{Addresses: []string{"Tmaddress"}, Value: 1},
+			}},
+			Vout: []Vout{{
+				Amount: 100,
+				ScriptPubKey: ScriptPubKeyResult{
+					Asm:       "OP_DUP OP_HASH160",
+					Type:      "pubkeyhash",
+					Addresses: []string{"Tmaddress"},
+				},
+			}},
+			BlockHash:     txid,
+			Confirmations: uint64(i),
+		}
+	}
+	return results
+}
+
+// TestGetRawTransactionsStream ensures streamed results are encoded as
+// json.Marshal encodes them.
+func TestGetRawTransactionsStream(t *testing.T) {
+	tests := []GetRawTransactionsStream{nil, {}, testRawTransactions(1),
+		testRawTransactions(3)}
+	for _, test := range tests {
+		want, err := json.Marshal(test)
+		if err != nil {
+			t.Fatalf("Marshal: %v", err)
+		}
+		var got bytes.Buffer
+		if err := test.StreamJSON(&got); err != nil {
+			t.Fatalf("StreamJSON: %v", err)
+		}
+		if !bytes.Equal(got.Bytes(), want) {
+			t.Errorf("StreamJSON: got %s, want %s", got.Bytes(), want)
+		}
+	}
+}
+
+// BenchmarkGetRawTransactionsBatch encodes 10k results at once.
+func BenchmarkGetRawTransactionsBatch(b *testing.B) {
+	results := testRawTransactions(10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := json.NewEncoder(ioutil.Discard).Encode(results); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkGetRawTransactionsStream streams 10k results.  Its bytes per
+// operation are spread over small allocations, unlike the batch encoding
+// which holds the whole array at once.
+func BenchmarkGetRawTransactionsStream(b *testing.B) {
+	results := testRawTransactions(10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := results.StreamJSON(ioutil.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
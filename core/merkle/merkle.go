@@ -212,6 +212,17 @@ func BuildParentsMerkleTreeStore(parents []*hash.Hash) []*hash.Hash {
 	return merkles
 }
 
+// ComputeParentsRoot returns the merkle root of the passed block parents,
+// which is the ParentRoot a block header with these parents commits to.  The
+// parents are hashed in the order they are passed, which must be the order of
+// the Parents of the block: the function doesn't sort them, and a block whose
+// parents are reordered has another root.  The root of no parents is the zero
+// hash.
+func ComputeParentsRoot(parents []*hash.Hash) hash.Hash {
+	paMerkles := BuildParentsMerkleTreeStore(parents)
+	return *paMerkles[len(paMerkles)-1]
+}
+
 func ValidateWitnessCommitment(blk *types.SerializedBlock) error {
 	if len(blk.Transactions()) == 0 {
 		str := "cannot validate witness commitment of block without " +
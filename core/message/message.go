@@ -9,12 +9,15 @@ package message
 import (
 	"bytes"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"github.com/Qitmeer/qitmeer/common/hash"
 	"github.com/Qitmeer/qitmeer/core/protocol"
 	s "github.com/Qitmeer/qitmeer/core/serialization"
 	"github.com/Qitmeer/qitmeer/core/types"
+	"github.com/Qitmeer/qitmeer/metrics"
 	"io"
+	"io/ioutil"
 	"unicode/utf8"
 )
 
@@ -34,6 +37,22 @@ const MaxMessagePayload = (1024 * 1024 * 32) // 32MB
 // MaxVarIntPayload is the maximum payload size for a variable length integer.
 const MaxVarIntPayload = 9
 
+// MaxUnknownMessagePayload is the maximum bytes the payload of a message of an
+// unknown command can be to be skipped, which is the max payload of a block.
+// Reading a message of an unknown command with a larger payload is an error.
+const MaxUnknownMessagePayload = types.MaxBlockPayload
+
+// ErrChecksum is returned by ReadMessage when the payload of a message doesn't
+// match the checksum of its header, as it was corrupted.
+var ErrChecksum = messageError("ReadMessage", "payload checksum failed")
+
+// errUnknownCommand is returned by readMessageN when the message of an unknown
+// command was skipped.
+var errUnknownCommand = errors.New("unknown command")
+
+// unknownMessageCounter counts the messages of unknown commands skipped.
+var unknownMessageCounter = metrics.NewRegisteredCounter("message/unknown", nil)
+
 //const MaxBlockPayload = 1000000 // Not actually 1MB which would be 1024 * 1024
 
 // Commands used in message headers which describe the type of message.
@@ -42,6 +61,7 @@ const (
 	CmdVerAck     = "verack"
 	CmdGetAddr    = "getaddr"
 	CmdAddr       = "addr"
+	CmdAddrV2     = "addrv2"
 	CmdReject     = "reject"
 	CmdPing       = "ping"
 	CmdPong       = "pong"
@@ -57,25 +77,34 @@ const (
 	CmdMiningState    = "miningstate"
 	CmdGetMiningState = "getminings"
 
-	CmdMemPool      = "mempool"
-	CmdGraphState   = "graphstate"
-	CmdSyncResult   = "syncresult"
-	CmdSyncDAG      = "syncdag"
-	CmdSyncPoint    = "syncpoint"
-	CmdSendHeaders  = "sendheaders"
-	CmdFeeFilter    = "feefilter"
-	CmdGetCFilter   = "getcfilter"
-	CmdGetCFHeaders = "getcfheaders"
-	CmdGetCFTypes   = "getcftypes"
-	CmdCFilter      = "cfilter"
-	CmdCFHeaders    = "cfheaders"
-	CmdCFTypes      = "cftypes"
+	CmdMemPool       = "mempool"
+	CmdGraphState    = "graphstate"
+	CmdSyncResult    = "syncresult"
+	CmdSyncDAG       = "syncdag"
+	CmdSyncPoint     = "syncpoint"
+	CmdCmpctBlock    = "cmpctblock"
+	CmdGetCmpctBlock = "getcmpctblk"
+	CmdSendHeaders   = "sendheaders"
+	CmdFeeFilter     = "feefilter"
+	CmdGetCFilter    = "getcfilter"
+	CmdGetCFHeaders  = "getcfheaders"
+	CmdGetCFTypes    = "getcftypes"
+	CmdCFilter       = "cfilter"
+	CmdCFHeaders     = "cfheaders"
+	CmdCFTypes       = "cftypes"
 )
 
 // Message is an interface that describes a qitmeer message.  A type that
 // implements Message has complete control over the representation of its data
 // and may therefore contain additional or fewer fields than those which
 // are used directly in the protocol encoded message.
+//
+// Decode, Encode and MaxPayloadLength are passed the protocol version
+// negotiated with the peer, so that the encoding of a message can change in a
+// later version while older peers still read the older encoding.  A message
+// added in a later version checks the version with requireVersion, and a
+// field added in a later version is only encoded and decoded from that
+// version, as the hash of the reject messages of RejectHashVersion.
 type Message interface {
 	Decode(io.Reader, uint32) error
 	Encode(io.Writer, uint32) error
@@ -83,6 +112,18 @@ type Message interface {
 	MaxPayloadLength(uint32) uint32
 }
 
+// requireVersion returns an error when the passed protocol version is before
+// the version which added the message of the passed command, to be returned by
+// the function f.
+func requireVersion(f string, command string, pver uint32, version uint32) error {
+	if pver < version {
+		str := fmt.Sprintf("%s message invalid for protocol version "+
+			"%d [min %d]", command, pver, version)
+		return messageError(f, str)
+	}
+	return nil
+}
+
 // makeEmptyMessage creates a message of the appropriate concrete type based
 // on the command.
 func makeEmptyMessage(command string) (Message, error) {
@@ -96,6 +137,8 @@ func makeEmptyMessage(command string) (Message, error) {
 		msg = &MsgGetAddr{}
 	case CmdAddr:
 		msg = &MsgAddr{}
+	case CmdAddrV2:
+		msg = &MsgAddrV2{}
 	case CmdPing:
 		msg = &MsgPing{}
 	case CmdPong:
@@ -132,6 +175,10 @@ func makeEmptyMessage(command string) (Message, error) {
 		msg = &MsgSyncDAG{}
 	case CmdSyncPoint:
 		msg = &MsgSyncPoint{}
+	case CmdCmpctBlock:
+		msg = &MsgCmpctBlock{}
+	case CmdGetCmpctBlock:
+		msg = &MsgGetCmpctBlock{}
 	/*
 		case CmdSendHeaders:
 			msg = &MsgSendHeaders{}
@@ -172,6 +219,15 @@ type messageHeader struct {
 	checksum [4]byte          // 4 bytes
 }
 
+// messageChecksum returns the checksum of the passed payload in the message
+// header, which is the first 4 bytes of its double hash.
+// TODO, add an abstract layer of hash func
+func messageChecksum(payload []byte) [4]byte {
+	var checksum [4]byte
+	copy(checksum[:], hash.DoubleHashB(payload)[0:4])
+	return checksum
+}
+
 // readMessageHeader reads a message header from r.
 func readMessageHeader(r io.Reader) (int, *messageHeader, error) {
 	// Since readElements doesn't return the amount of bytes read, attempt
@@ -264,9 +320,7 @@ func WriteMessageN(w io.Writer, msg Message, pver uint32, net protocol.Network)
 	hdr.command = cmd
 	hdr.length = uint32(lenp)
 
-	// TODO, add an abstract layer of hash func
-	// TODO, double sha256 or other crypto hash
-	copy(hdr.checksum[:], hash.DoubleHashB(payload)[0:4])
+	hdr.checksum = messageChecksum(payload)
 
 	// Encode the header for the message.  This is done to a buffer
 	// rather than directly to the writer since writeElements doesn't
@@ -302,7 +356,24 @@ func WriteMessage(w io.Writer, msg Message, pver uint32, net protocol.Network) e
 // bytes read in addition to the parsed Message and raw bytes which comprise the
 // message.  This function is the same as ReadMessage except it also returns the
 // number of bytes read.
+//
+// Messages of unknown commands are skipped, and the next message is read.
 func ReadMessageN(r io.Reader, pver uint32, net protocol.Network) (int, Message, []byte, error) {
+	totalBytes := 0
+	for {
+		n, msg, payload, err := readMessageN(r, pver, net)
+		totalBytes += n
+		if err == errUnknownCommand {
+			continue
+		}
+		return totalBytes, msg, payload, err
+	}
+}
+
+// readMessageN reads, validates, and parses the next Message from r, like
+// ReadMessageN, except that errUnknownCommand is returned when the payload of
+// an unknown command was skipped.
+func readMessageN(r io.Reader, pver uint32, net protocol.Network) (int, Message, []byte, error) {
 	totalBytes := 0
 	n, hdr, err := readMessageHeader(r)
 	totalBytes += n
@@ -335,11 +406,25 @@ func ReadMessageN(r io.Reader, pver uint32, net protocol.Network) (int, Message,
 	}
 
 	// Create struct of appropriate message type based on the command.
+	// The payload of an unknown command is skipped, so that peers of newer
+	// versions can send messages this version doesn't know.
 	msg, err := makeEmptyMessage(command)
 	if err != nil {
-		discardInput(r, hdr.length)
-		return totalBytes, nil, nil, messageError("ReadMessage",
-			err.Error())
+		if hdr.length > MaxUnknownMessagePayload {
+			discardInput(r, hdr.length)
+			str := fmt.Sprintf("payload exceeds max length - header "+
+				"indicates %v bytes, but max payload size for "+
+				"unknown command [%v] is %v.", hdr.length, command,
+				MaxUnknownMessagePayload)
+			return totalBytes, nil, nil, messageError("ReadMessage", str)
+		}
+		m, err := io.CopyN(ioutil.Discard, r, int64(hdr.length))
+		totalBytes += int(m)
+		if err != nil {
+			return totalBytes, nil, nil, err
+		}
+		unknownMessageCounter.Inc(1)
+		return totalBytes, nil, nil, errUnknownCommand
 	}
 
 	// Check for maximum length based on the message type as a malicious client
@@ -362,15 +447,10 @@ func ReadMessageN(r io.Reader, pver uint32, net protocol.Network) (int, Message,
 		return totalBytes, nil, nil, err
 	}
 
-	// Test checksum.
-	// TODO, add an abstract layer of hash func
-	// TODO, double sha256 or other crypto hash
-	checksum := hash.DoubleHashB(payload)[0:4]
-	if !bytes.Equal(checksum[:], hdr.checksum[:]) {
-		str := fmt.Sprintf("payload checksum failed - header "+
-			"indicates %v, but actual checksum is %v.",
-			hdr.checksum, checksum)
-		return totalBytes, nil, nil, messageError("ReadMessage", str)
+	// Test checksum, so that the payload is known to be intact before it's
+	// decoded.
+	if messageChecksum(payload) != hdr.checksum {
+		return totalBytes, nil, nil, ErrChecksum
 	}
 
 	// Unmarshal message.
@@ -0,0 +1,91 @@
+package message
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Qitmeer/qitmeer/core/protocol"
+	s "github.com/Qitmeer/qitmeer/core/serialization"
+)
+
+// testFrame returns a message frame of the passed command and payload, with
+// the length of the header being the passed one.
+func testFrame(command string, length uint32, payload []byte) []byte {
+	var cmd [CommandSize]byte
+	copy(cmd[:], command)
+	var buf bytes.Buffer
+	s.WriteElements(&buf, protocol.MainNet, cmd, length, messageChecksum(payload))
+	buf.Write(payload)
+	return buf.Bytes()
+}
+
+// TestReadMessageUnknownCommand ensures a message of an unknown command is
+// skipped and the next message read.
+func TestReadMessageUnknownCommand(t *testing.T) {
+	pver := protocol.ProtocolVersion
+	payload := bytes.Repeat([]byte{0x01}, 100)
+	var buf bytes.Buffer
+	buf.Write(testFrame("newcommand", uint32(len(payload)), payload))
+	if err := WriteMessage(&buf, NewMsgVerAck(), pver, protocol.MainNet); err != nil {
+		t.Fatalf("WriteMessage: %v", err)
+	}
+	total := buf.Len()
+
+	n, msg, _, err := ReadMessageN(&buf, pver, protocol.MainNet)
+	if err != nil {
+		t.Fatalf("ReadMessageN: %v", err)
+	}
+	if _, ok := msg.(*MsgVerAck); !ok {
+		t.Errorf("read a %T, want a *MsgVerAck", msg)
+	}
+	if n != total || buf.Len() != 0 {
+		t.Errorf("read %d bytes with %d left, want %d", n, buf.Len(), total)
+	}
+}
+
+// TestReadMessageUnknownCommandTooLarge ensures a message of an unknown
+// command with an implausibly large payload is still an error.
+func TestReadMessageUnknownCommandTooLarge(t *testing.T) {
+	pver := protocol.ProtocolVersion
+	var buf bytes.Buffer
+	buf.Write(testFrame("newcommand", MaxUnknownMessagePayload+1, nil))
+	if err := WriteMessage(&buf, NewMsgVerAck(), pver, protocol.MainNet); err != nil {
+		t.Fatalf("WriteMessage: %v", err)
+	}
+
+	_, _, err := ReadMessage(&buf, pver, protocol.MainNet)
+	if _, ok := err.(*MessageError); !ok {
+		t.Errorf("got error %v, want a *MessageError", err)
+	}
+}
+
+// TestReadMessageChecksum ensures a message is read only when its payload
+// matches the checksum of its header.
+func TestReadMessageChecksum(t *testing.T) {
+	pver := protocol.ProtocolVersion
+	var frame bytes.Buffer
+	if err := WriteMessage(&frame, NewMsgPing(0x0102030405060708), pver,
+		protocol.MainNet); err != nil {
+		t.Fatalf("WriteMessage: %v", err)
+	}
+	good := frame.Bytes()
+
+	msg, _, err := ReadMessage(bytes.NewReader(good), pver, protocol.MainNet)
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	if ping, ok := msg.(*MsgPing); !ok || ping.Nonce != 0x0102030405060708 {
+		t.Errorf("read %v, want the ping", msg)
+	}
+
+	for i := MessageHeaderSize; i < len(good); i++ {
+		corrupted := append([]byte{}, good...)
+		corrupted[i] ^= 0x01
+		_, _, err := ReadMessage(bytes.NewReader(corrupted), pver,
+			protocol.MainNet)
+		if err != ErrChecksum {
+			t.Errorf("payload byte %d flipped: got error %v, want %v",
+				i-MessageHeaderSize, err, ErrChecksum)
+		}
+	}
+}
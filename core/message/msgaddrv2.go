@@ -0,0 +1,141 @@
+// Copyright (c) 2017-2018 The qitmeer developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package message
+
+import (
+	"fmt"
+	"github.com/Qitmeer/qitmeer/core/protocol"
+	s "github.com/Qitmeer/qitmeer/core/serialization"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"io"
+)
+
+// MsgAddrV2 implements the Message interface and represents an addrv2
+// message, as in BIP155.  It is used like MsgAddr to provide a list of known
+// active peers, but of variable length addresses, so that they can be Tor v3
+// or I2P addresses in addition to the IP ones.  Each message is limited to
+// MaxAddrPerMsg addresses.
+//
+// This message was not added until protocol version AddrV2Version.
+type MsgAddrV2 struct {
+	AddrList []*types.NetAddressV2
+}
+
+// AddAddress adds a known active peer to the message.
+func (msg *MsgAddrV2) AddAddress(na *types.NetAddressV2) error {
+	if len(msg.AddrList)+1 > MaxAddrPerMsg {
+		str := fmt.Sprintf("too many addresses in message [max %v]",
+			MaxAddrPerMsg)
+		return messageError("MsgAddrV2.AddAddress", str)
+	}
+
+	msg.AddrList = append(msg.AddrList, na)
+	return nil
+}
+
+// AddAddresses adds multiple known active peers to the message.
+func (msg *MsgAddrV2) AddAddresses(netAddrs ...*types.NetAddressV2) error {
+	for _, na := range netAddrs {
+		err := msg.AddAddress(na)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClearAddresses removes all addresses from the message.
+func (msg *MsgAddrV2) ClearAddresses() {
+	msg.AddrList = []*types.NetAddressV2{}
+}
+
+// Decode decodes r into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgAddrV2) Decode(r io.Reader, pver uint32) error {
+	err := requireVersion("MsgAddrV2.Decode", CmdAddrV2, pver,
+		protocol.AddrV2Version)
+	if err != nil {
+		return err
+	}
+
+	count, err := s.ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+
+	// Limit to max addresses per message.
+	if count > MaxAddrPerMsg {
+		str := fmt.Sprintf("too many addresses for message "+
+			"[count %v, max %v]", count, MaxAddrPerMsg)
+		return messageError("MsgAddrV2.Decode", str)
+	}
+
+	addrList := make([]types.NetAddressV2, count)
+	msg.AddrList = make([]*types.NetAddressV2, 0, count)
+	for i := uint64(0); i < count; i++ {
+		na := &addrList[i]
+		err := types.ReadNetAddressV2(r, pver, na)
+		if err != nil {
+			return messageError("MsgAddrV2.Decode", err.Error())
+		}
+		msg.AddAddress(na)
+	}
+	return nil
+}
+
+// Encode encodes the receiver to w.
+// This is part of the Message interface implementation.
+func (msg *MsgAddrV2) Encode(w io.Writer, pver uint32) error {
+	err := requireVersion("MsgAddrV2.Encode", CmdAddrV2, pver,
+		protocol.AddrV2Version)
+	if err != nil {
+		return err
+	}
+
+	count := len(msg.AddrList)
+	if count > MaxAddrPerMsg {
+		str := fmt.Sprintf("too many addresses for message "+
+			"[count %v, max %v]", count, MaxAddrPerMsg)
+		return messageError("MsgAddrV2.Encode", str)
+	}
+
+	err = s.WriteVarInt(w, pver, uint64(count))
+	if err != nil {
+		return err
+	}
+
+	for _, na := range msg.AddrList {
+		err = types.WriteNetAddressV2(w, pver, na)
+		if err != nil {
+			return messageError("MsgAddrV2.Encode", err.Error())
+		}
+	}
+
+	return nil
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgAddrV2) Command() string {
+	return CmdAddrV2
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgAddrV2) MaxPayloadLength(pver uint32) uint32 {
+	if pver < protocol.AddrV2Version {
+		return 0
+	}
+	// Num addresses (varInt) + max allowed addresses.
+	return s.MaxVarIntPayload + (MaxAddrPerMsg * types.MaxNetAddressV2Payload(pver))
+}
+
+// NewMsgAddrV2 returns a new addrv2 message that conforms to the Message
+// interface.  See MsgAddrV2 for details.
+func NewMsgAddrV2() *MsgAddrV2 {
+	return &MsgAddrV2{
+		AddrList: make([]*types.NetAddressV2, 0, MaxAddrPerMsg),
+	}
+}
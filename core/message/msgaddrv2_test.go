@@ -0,0 +1,123 @@
+package message
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Qitmeer/qitmeer/core/protocol"
+	"github.com/Qitmeer/qitmeer/core/types"
+)
+
+func TestAddrV2RoundTrip(t *testing.T) {
+	pver := protocol.AddrV2Version
+	timestamp := time.Unix(1577836800, 0)
+	torV3 := bytes.Repeat([]byte{0xab}, 32)
+	i2p := bytes.Repeat([]byte{0xcd}, 32)
+	tests := []struct {
+		name     string
+		addrType types.NetAddressType
+		addr     []byte
+	}{
+		{"ipv4", types.IPv4Address, net.ParseIP("192.168.0.1").To4()},
+		{"ipv6", types.IPv6Address, net.ParseIP("2001:db8::1")},
+		{"torv3", types.TorV3Address, torV3},
+		{"i2p", types.I2PAddress, i2p},
+		{"unknown", types.NetAddressType(9), []byte{0x01, 0x02, 0x03}},
+	}
+
+	for _, test := range tests {
+		na, err := types.NewNetAddressV2(timestamp, protocol.Full,
+			test.addrType, test.addr, 18130)
+		if err != nil {
+			t.Errorf("%s: NewNetAddressV2: %v", test.name, err)
+			continue
+		}
+		msg := NewMsgAddrV2()
+		msg.AddAddress(na)
+
+		var buf bytes.Buffer
+		if err := WriteMessage(&buf, msg, pver, protocol.MainNet); err != nil {
+			t.Errorf("%s: WriteMessage: %v", test.name, err)
+			continue
+		}
+		read, _, err := ReadMessage(&buf, pver, protocol.MainNet)
+		if err != nil {
+			t.Errorf("%s: ReadMessage: %v", test.name, err)
+			continue
+		}
+		decoded, ok := read.(*MsgAddrV2)
+		if !ok || len(decoded.AddrList) != 1 {
+			t.Errorf("%s: read %v, want one address", test.name, read)
+			continue
+		}
+		if got := decoded.AddrList[0]; !reflect.DeepEqual(got, na) {
+			t.Errorf("%s: decoded %+v, want %+v", test.name, got, na)
+		}
+	}
+}
+
+func TestAddrV2Invalid(t *testing.T) {
+	pver := protocol.AddrV2Version
+	timestamp := time.Unix(1577836800, 0)
+
+	// The addresses of the known types must be of their size.
+	_, err := types.NewNetAddressV2(timestamp, 0, types.TorV3Address,
+		make([]byte, 16), 0)
+	if err == nil {
+		t.Errorf("NewNetAddressV2 of a 16 bytes tor v3 address succeeded")
+	}
+	msg := NewMsgAddrV2()
+	msg.AddAddress(&types.NetAddressV2{Type: types.IPv4Address,
+		Addr: make([]byte, 16)})
+	if err := msg.Encode(&bytes.Buffer{}, pver); err == nil {
+		t.Errorf("Encode of a 16 bytes ipv4 address succeeded")
+	}
+
+	// A decoded tor v3 address of the wrong size is rejected.
+	var buf bytes.Buffer
+	msg.AddrList[0].Type = types.TorV3Address
+	msg.AddrList[0].Addr = make([]byte, 32)
+	msg.Encode(&buf, pver)
+	payload := buf.Bytes()
+	// Count 1 byte + timestamp 4 bytes + services 8 bytes + type 1 byte.
+	payload[14] = 31
+	if err := (&MsgAddrV2{}).Decode(bytes.NewReader(payload), pver); err == nil {
+		t.Errorf("Decode of a 31 bytes tor v3 address succeeded")
+	}
+
+	// The message is not valid before AddrV2Version.
+	na := types.NewNetAddressV2IP(net.ParseIP("127.0.0.1"), 18130, 0)
+	msg = NewMsgAddrV2()
+	msg.AddAddress(na)
+	if err := msg.Encode(&bytes.Buffer{}, pver-1); err == nil {
+		t.Errorf("Encode for protocol version %d succeeded", pver-1)
+	}
+	buf.Reset()
+	msg.Encode(&buf, pver)
+	if err := (&MsgAddrV2{}).Decode(&buf, pver-1); err == nil {
+		t.Errorf("Decode for protocol version %d succeeded", pver-1)
+	}
+
+	// The count of addresses is bounded.
+	for i := 1; i < MaxAddrPerMsg; i++ {
+		msg.AddAddress(na)
+	}
+	if err := msg.AddAddress(na); err == nil {
+		t.Errorf("AddAddress of %d addresses succeeded", MaxAddrPerMsg+1)
+	}
+	buf.Reset()
+	if err := msg.Encode(&buf, pver); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if uint32(buf.Len()) > msg.MaxPayloadLength(pver) {
+		t.Errorf("payload of %d bytes, max %d", buf.Len(),
+			msg.MaxPayloadLength(pver))
+	}
+	msg.AddrList = append(msg.AddrList, na)
+	if err := msg.Encode(&bytes.Buffer{}, pver); err == nil {
+		t.Errorf("Encode of %d addresses succeeded", MaxAddrPerMsg+1)
+	}
+}
@@ -0,0 +1,266 @@
+// Copyright (c) 2017-2018 The qitmeer developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package message
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/Qitmeer/qitmeer/common/hash"
+	s "github.com/Qitmeer/qitmeer/core/serialization"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"github.com/Qitmeer/qitmeer/crypto/cuckoo/siphash"
+	"io"
+)
+
+// ShortTxIDSize is the number of bytes of a short transaction id of a compact
+// block.
+const ShortTxIDSize = 6
+
+// PrefilledTx is a transaction of a compact block sent in full, which the
+// receiver is not expected to have, such as the coinbase.
+type PrefilledTx struct {
+	// Index is the index of the transaction in the block.
+	Index uint32
+	Tx    *types.Transaction
+}
+
+// MsgCmpctBlock implements the Message interface and represents a compact
+// block message, as in BIP152.  It is used to relay a block with the short
+// ids of its transactions instead of the transactions, which the receiver
+// looks up in its mempool, and requests those it doesn't have.
+//
+// Unlike BIP152, the parents of the block are included as they are not in the
+// header.
+type MsgCmpctBlock struct {
+	Header    types.BlockHeader
+	Parents   []*hash.Hash
+	Nonce     uint64
+	ShortIDs  []uint64
+	Prefilled []*PrefilledTx
+}
+
+// SipHashKeys returns the siphash keys of the short transaction ids, which are
+// the first two little endian uint64 of the hash of the header followed by the
+// nonce.
+func (msg *MsgCmpctBlock) SipHashKeys() (uint64, uint64) {
+	var buf bytes.Buffer
+	msg.Header.Serialize(&buf)
+	s.WriteElements(&buf, msg.Nonce)
+	h := hash.HashB(buf.Bytes())
+	return binary.LittleEndian.Uint64(h[0:8]), binary.LittleEndian.Uint64(h[8:16])
+}
+
+// ShortTxID returns the short id of the passed transaction hash in the compact
+// block, the lower 6 bytes of its siphash.
+func (msg *MsgCmpctBlock) ShortTxID(txHash *hash.Hash) uint64 {
+	k0, k1 := msg.SipHashKeys()
+	return shortTxID(k0, k1, txHash)
+}
+
+func shortTxID(k0, k1 uint64, txHash *hash.Hash) uint64 {
+	return siphash.Hash(k0, k1, txHash[:]) & (1<<(8*ShortTxIDSize) - 1)
+}
+
+// Decode decodes r using the protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgCmpctBlock) Decode(r io.Reader, pver uint32) error {
+	err := msg.Header.Deserialize(r)
+	if err != nil {
+		return err
+	}
+
+	count, err := s.ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+	if count > types.MaxParentsPerBlock {
+		str := fmt.Sprintf("too many parents for message "+
+			"[count %v, max %v]", count, types.MaxParentsPerBlock)
+		return messageError("MsgCmpctBlock.Decode", str)
+	}
+	msg.Parents = make([]*hash.Hash, count)
+	for i := range msg.Parents {
+		msg.Parents[i] = &hash.Hash{}
+		err = s.ReadElements(r, msg.Parents[i])
+		if err != nil {
+			return err
+		}
+	}
+
+	err = s.ReadElements(r, &msg.Nonce)
+	if err != nil {
+		return err
+	}
+
+	count, err = s.ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+	maxTxs := types.MaxTxPerBlock(pver)
+	if count > maxTxs {
+		str := fmt.Sprintf("too many short ids for message "+
+			"[count %v, max %v]", count, maxTxs)
+		return messageError("MsgCmpctBlock.Decode", str)
+	}
+	msg.ShortIDs = make([]uint64, count)
+	var id [8]byte
+	for i := range msg.ShortIDs {
+		_, err = io.ReadFull(r, id[:ShortTxIDSize])
+		if err != nil {
+			return err
+		}
+		msg.ShortIDs[i] = binary.LittleEndian.Uint64(id[:])
+	}
+
+	count, err = s.ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+	if count+uint64(len(msg.ShortIDs)) > maxTxs {
+		str := fmt.Sprintf("too many prefilled transactions for message "+
+			"[count %v, max %v]", count, maxTxs-uint64(len(msg.ShortIDs)))
+		return messageError("MsgCmpctBlock.Decode", str)
+	}
+	// The indexes are encoded as the difference with the previous index
+	// minus one, as they are increasing.
+	msg.Prefilled = make([]*PrefilledTx, count)
+	next := uint64(0)
+	for i := range msg.Prefilled {
+		diff, err := s.ReadVarInt(r, pver)
+		if err != nil {
+			return err
+		}
+		index := next + diff
+		if index >= maxTxs {
+			str := fmt.Sprintf("prefilled transaction index %v out "+
+				"of range", index)
+			return messageError("MsgCmpctBlock.Decode", str)
+		}
+		tx := &types.Transaction{}
+		err = tx.Deserialize(r)
+		if err != nil {
+			return err
+		}
+		msg.Prefilled[i] = &PrefilledTx{Index: uint32(index), Tx: tx}
+		next = index + 1
+	}
+	return nil
+}
+
+// Encode encodes the receiver to w using the protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgCmpctBlock) Encode(w io.Writer, pver uint32) error {
+	if len(msg.Parents) > types.MaxParentsPerBlock {
+		str := fmt.Sprintf("too many parents for message "+
+			"[count %v, max %v]", len(msg.Parents), types.MaxParentsPerBlock)
+		return messageError("MsgCmpctBlock.Encode", str)
+	}
+	count := uint64(len(msg.ShortIDs) + len(msg.Prefilled))
+	if count > types.MaxTxPerBlock(pver) {
+		str := fmt.Sprintf("too many transactions for message "+
+			"[count %v, max %v]", count, types.MaxTxPerBlock(pver))
+		return messageError("MsgCmpctBlock.Encode", str)
+	}
+
+	err := msg.Header.Serialize(w)
+	if err != nil {
+		return err
+	}
+
+	err = s.WriteVarInt(w, pver, uint64(len(msg.Parents)))
+	if err != nil {
+		return err
+	}
+	for _, parent := range msg.Parents {
+		err = s.WriteElements(w, parent)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = s.WriteElements(w, msg.Nonce)
+	if err != nil {
+		return err
+	}
+
+	err = s.WriteVarInt(w, pver, uint64(len(msg.ShortIDs)))
+	if err != nil {
+		return err
+	}
+	var id [8]byte
+	for _, shortID := range msg.ShortIDs {
+		binary.LittleEndian.PutUint64(id[:], shortID)
+		_, err = w.Write(id[:ShortTxIDSize])
+		if err != nil {
+			return err
+		}
+	}
+
+	err = s.WriteVarInt(w, pver, uint64(len(msg.Prefilled)))
+	if err != nil {
+		return err
+	}
+	next := uint32(0)
+	for _, ptx := range msg.Prefilled {
+		if ptx.Index < next {
+			str := fmt.Sprintf("prefilled transaction index %v is not "+
+				"increasing", ptx.Index)
+			return messageError("MsgCmpctBlock.Encode", str)
+		}
+		err = s.WriteVarInt(w, pver, uint64(ptx.Index-next))
+		if err != nil {
+			return err
+		}
+		err = ptx.Tx.Encode(w, pver, types.TxSerializeFull)
+		if err != nil {
+			return err
+		}
+		next = ptx.Index + 1
+	}
+	return nil
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgCmpctBlock) Command() string {
+	return CmdCmpctBlock
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgCmpctBlock) MaxPayloadLength(pver uint32) uint32 {
+	// A compact block is at most the size of its block, since a short id
+	// is smaller than any transaction, and it has at most as many short
+	// ids as a block has transactions.
+	return types.MaxBlockPayload
+}
+
+func (msg *MsgCmpctBlock) String() string {
+	return fmt.Sprintf("Hash:%s ShortIDs:%d Prefilled:%d",
+		msg.Header.BlockHash(), len(msg.ShortIDs), len(msg.Prefilled))
+}
+
+// NewMsgCmpctBlock returns a new compact block message of the passed block
+// with the nonce keying its short ids, that conforms to the Message interface.
+// The coinbase is prefilled, as the receiver can't have it.
+func NewMsgCmpctBlock(block *types.Block, nonce uint64) *MsgCmpctBlock {
+	msg := &MsgCmpctBlock{
+		Header:  block.Header,
+		Parents: block.Parents,
+		Nonce:   nonce,
+	}
+	k0, k1 := msg.SipHashKeys()
+	for i, tx := range block.Transactions {
+		if i == 0 {
+			msg.Prefilled = append(msg.Prefilled,
+				&PrefilledTx{Index: 0, Tx: tx})
+			continue
+		}
+		txHash := tx.TxHash()
+		msg.ShortIDs = append(msg.ShortIDs, shortTxID(k0, k1, &txHash))
+	}
+	return msg
+}
@@ -0,0 +1,149 @@
+package message
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/protocol"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"github.com/Qitmeer/qitmeer/core/types/pow"
+)
+
+// testCmpctBlock returns a block of a coinbase and the passed number of other
+// transactions, with two parents.
+func testCmpctBlock(txs int) *types.Block {
+	block := &types.Block{
+		Header: types.BlockHeader{
+			Version:   1,
+			Timestamp: time.Unix(1577836800, 0),
+			Pow:       pow.GetInstance(pow.BLAKE2BD, 0, []byte{}),
+		},
+		Parents: []*hash.Hash{{0x01}, {0x02}},
+	}
+	for i := 0; i <= txs; i++ {
+		tx := types.NewTransaction()
+		tx.AddTxIn(types.NewTxInput(types.NewOutPoint(&hash.Hash{byte(i)},
+			uint32(i)), []byte{0x51}))
+		tx.AddTxOut(types.NewTxOutput(uint64(i+1)*1e8, []byte{0x51}))
+		block.Transactions = append(block.Transactions, tx)
+	}
+	return block
+}
+
+func TestCmpctBlockRoundTrip(t *testing.T) {
+	block := testCmpctBlock(3)
+	msg := NewMsgCmpctBlock(block, 0x0123456789abcdef)
+	if len(msg.ShortIDs) != 3 || len(msg.Prefilled) != 1 ||
+		msg.Prefilled[0].Tx != block.Transactions[0] {
+		t.Fatalf("got %d short ids and %d prefilled transactions, want 3 "+
+			"and the coinbase", len(msg.ShortIDs), len(msg.Prefilled))
+	}
+	for i, tx := range block.Transactions[1:] {
+		txHash := tx.TxHash()
+		id := msg.ShortTxID(&txHash)
+		if id != msg.ShortIDs[i] || id>>(8*ShortTxIDSize) != 0 {
+			t.Errorf("short id %d: got %x, want 6 bytes %x", i, msg.ShortIDs[i], id)
+		}
+	}
+
+	// Another nonce keys other short ids.
+	other := NewMsgCmpctBlock(block, 1)
+	if reflect.DeepEqual(other.ShortIDs, msg.ShortIDs) {
+		t.Errorf("short ids don't depend on the nonce")
+	}
+
+	var buf bytes.Buffer
+	if err := WriteMessage(&buf, msg, protocol.ProtocolVersion, protocol.MainNet); err != nil {
+		t.Fatalf("WriteMessage: %v", err)
+	}
+	read, _, err := ReadMessage(&buf, protocol.ProtocolVersion, protocol.MainNet)
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	decoded, ok := read.(*MsgCmpctBlock)
+	if !ok {
+		t.Fatalf("read a %T, want a *MsgCmpctBlock", read)
+	}
+	if decoded.Header.BlockHash() != block.Header.BlockHash() ||
+		!reflect.DeepEqual(decoded.Parents, block.Parents) ||
+		decoded.Nonce != msg.Nonce ||
+		!reflect.DeepEqual(decoded.ShortIDs, msg.ShortIDs) {
+		t.Errorf("decoded %v, want %v", decoded, msg)
+	}
+	if len(decoded.Prefilled) != 1 || decoded.Prefilled[0].Index != 0 ||
+		decoded.Prefilled[0].Tx.TxHash() != block.Transactions[0].TxHash() {
+		t.Errorf("decoded prefilled transactions %v", decoded.Prefilled)
+	}
+}
+
+// TestCmpctBlockPrefilledIndexes ensures the differentially encoded indexes of
+// the prefilled transactions decode to the same indexes.
+func TestCmpctBlockPrefilledIndexes(t *testing.T) {
+	block := testCmpctBlock(3)
+	msg := NewMsgCmpctBlock(block, 0)
+	msg.Prefilled = []*PrefilledTx{
+		{Index: 0, Tx: block.Transactions[0]},
+		{Index: 2, Tx: block.Transactions[2]},
+		{Index: 3, Tx: block.Transactions[3]},
+	}
+	msg.ShortIDs = msg.ShortIDs[:1]
+
+	var buf bytes.Buffer
+	if err := msg.Encode(&buf, protocol.ProtocolVersion); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	var decoded MsgCmpctBlock
+	if err := decoded.Decode(&buf, protocol.ProtocolVersion); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	for i, ptx := range decoded.Prefilled {
+		if ptx.Index != msg.Prefilled[i].Index {
+			t.Errorf("prefilled %d: got index %d, want %d", i,
+				ptx.Index, msg.Prefilled[i].Index)
+		}
+	}
+
+	// Decreasing indexes can't be encoded.
+	msg.Prefilled[1], msg.Prefilled[2] = msg.Prefilled[2], msg.Prefilled[1]
+	if err := msg.Encode(&bytes.Buffer{}, protocol.ProtocolVersion); err == nil {
+		t.Errorf("Encode of decreasing prefilled indexes succeeded")
+	}
+}
+
+// TestCmpctBlockMaxPayloadLength ensures a compact block with as many short ids
+// as a block can have transactions fits in the max payload, and that more
+// don't encode.
+func TestCmpctBlockMaxPayloadLength(t *testing.T) {
+	pver := protocol.ProtocolVersion
+	msg := NewMsgCmpctBlock(testCmpctBlock(1), 0)
+	maxTxs := int(types.MaxTxPerBlock(pver))
+	msg.ShortIDs = make([]uint64, maxTxs-len(msg.Prefilled))
+	for i := range msg.ShortIDs {
+		msg.ShortIDs[i] = uint64(i)
+	}
+
+	var buf bytes.Buffer
+	if err := msg.Encode(&buf, pver); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if uint32(buf.Len()) > msg.MaxPayloadLength(pver) {
+		t.Errorf("payload of %d bytes, max %d", buf.Len(),
+			msg.MaxPayloadLength(pver))
+	}
+	var decoded MsgCmpctBlock
+	if err := decoded.Decode(&buf, pver); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if len(decoded.ShortIDs) != len(msg.ShortIDs) {
+		t.Errorf("decoded %d short ids, want %d", len(decoded.ShortIDs),
+			len(msg.ShortIDs))
+	}
+
+	msg.ShortIDs = append(msg.ShortIDs, 0)
+	if err := msg.Encode(&bytes.Buffer{}, pver); err == nil {
+		t.Errorf("Encode of %d transactions succeeded", maxTxs+1)
+	}
+}
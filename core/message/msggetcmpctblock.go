@@ -0,0 +1,65 @@
+// Copyright (c) 2017-2018 The qitmeer developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package message
+
+import (
+	"fmt"
+	"github.com/Qitmeer/qitmeer/common/hash"
+	s "github.com/Qitmeer/qitmeer/core/serialization"
+	"io"
+)
+
+// CmpctBlockVersion is the latest version of the compact block protocol
+// supported, as requested by MsgGetCmpctBlock.
+const CmpctBlockVersion uint64 = 1
+
+// MsgGetCmpctBlock implements the Message interface and represents a request
+// for the compact block of the block with the passed hash, to which the peer
+// responds with a MsgCmpctBlock.
+//
+// The version is the compact block protocol version the requester expects.
+type MsgGetCmpctBlock struct {
+	Hash    hash.Hash
+	Version uint64
+}
+
+// Decode decodes r using the protocol encoding into the receiver.
+// This is part of the Message interface implementation.
+func (msg *MsgGetCmpctBlock) Decode(r io.Reader, pver uint32) error {
+	return s.ReadElements(r, &msg.Hash, &msg.Version)
+}
+
+// Encode encodes the receiver to w using the protocol encoding.
+// This is part of the Message interface implementation.
+func (msg *MsgGetCmpctBlock) Encode(w io.Writer, pver uint32) error {
+	return s.WriteElements(w, &msg.Hash, msg.Version)
+}
+
+// Command returns the protocol command string for the message.  This is part
+// of the Message interface implementation.
+func (msg *MsgGetCmpctBlock) Command() string {
+	return CmdGetCmpctBlock
+}
+
+// MaxPayloadLength returns the maximum length the payload can be for the
+// receiver.  This is part of the Message interface implementation.
+func (msg *MsgGetCmpctBlock) MaxPayloadLength(pver uint32) uint32 {
+	// Block hash + version 8 bytes.
+	return hash.HashSize + 8
+}
+
+func (msg *MsgGetCmpctBlock) String() string {
+	return fmt.Sprintf("Hash:%s Version:%d", msg.Hash, msg.Version)
+}
+
+// NewMsgGetCmpctBlock returns a new request of the compact block of the passed
+// block hash that conforms to the Message interface.  See MsgGetCmpctBlock for
+// details.
+func NewMsgGetCmpctBlock(blockHash *hash.Hash) *MsgGetCmpctBlock {
+	return &MsgGetCmpctBlock{
+		Hash:    *blockHash,
+		Version: CmpctBlockVersion,
+	}
+}
@@ -0,0 +1,67 @@
+package message
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/protocol"
+)
+
+func TestGetCmpctBlockCommand(t *testing.T) {
+	msg := NewMsgGetCmpctBlock(&hash.Hash{})
+	if cmd := msg.Command(); cmd != "getcmpctblk" || cmd != CmdGetCmpctBlock {
+		t.Errorf("got command %q, want %q", cmd, "getcmpctblk")
+	}
+	if len(CmdGetCmpctBlock) > CommandSize {
+		t.Errorf("command %q is longer than %d bytes", CmdGetCmpctBlock,
+			CommandSize)
+	}
+}
+
+func TestGetCmpctBlockRoundTrip(t *testing.T) {
+	pver := protocol.ProtocolVersion
+	blockHash := hash.Hash{0x01, 0x02, 0x03}
+	msg := NewMsgGetCmpctBlock(&blockHash)
+	if msg.Hash != blockHash || msg.Version != CmpctBlockVersion {
+		t.Fatalf("got %v, want hash %s version %d", msg, blockHash,
+			CmpctBlockVersion)
+	}
+
+	var buf bytes.Buffer
+	if err := msg.Encode(&buf, pver); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if uint32(buf.Len()) != msg.MaxPayloadLength(pver) {
+		t.Errorf("payload of %d bytes, want %d", buf.Len(),
+			msg.MaxPayloadLength(pver))
+	}
+	var decoded MsgGetCmpctBlock
+	if err := decoded.Decode(&buf, pver); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if decoded != *msg {
+		t.Errorf("decoded %v, want %v", &decoded, msg)
+	}
+
+	// Through the message framing.
+	buf.Reset()
+	if err := WriteMessage(&buf, msg, pver, protocol.MainNet); err != nil {
+		t.Fatalf("WriteMessage: %v", err)
+	}
+	read, _, err := ReadMessage(&buf, pver, protocol.MainNet)
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	if got, ok := read.(*MsgGetCmpctBlock); !ok || *got != *msg {
+		t.Errorf("read %v, want %v", read, msg)
+	}
+
+	// A truncated payload doesn't decode.
+	buf.Reset()
+	msg.Encode(&buf, pver)
+	truncated := bytes.NewReader(buf.Bytes()[:buf.Len()-1])
+	if err := decoded.Decode(truncated, pver); err == nil {
+		t.Errorf("Decode of a truncated payload succeeded")
+	}
+}
@@ -9,8 +9,10 @@ package message
 import (
 	"fmt"
 	"io"
+	"unicode/utf8"
 
 	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/protocol"
 	s "github.com/Qitmeer/qitmeer/core/serialization"
 )
 
@@ -46,6 +48,10 @@ var rejectCodeStrings = map[RejectCode]string{
 	RejectMaxInbound:      "REJECT_MAXINBOUND",
 }
 
+// MaxRejectReasonLen is the maximum allowed length for the reason of a reject
+// message from protocol version RejectReasonLenVersion.
+const MaxRejectReasonLen = 256
+
 // String returns the RejectCode in human-readable form.
 func (code RejectCode) String() string {
 	if s, ok := rejectCodeStrings[code]; ok {
@@ -69,23 +75,32 @@ type MsgReject struct {
 	Code RejectCode
 
 	// Reason is a human-readable string with specific details (over and
-	// above the reject code) about why the command was rejected.
+	// above the reject code) about why the command was rejected.  This has
+	// a max length of MaxRejectReasonLen from protocol version
+	// RejectReasonLenVersion.
 	Reason string
 
 	// Hash identifies a specific block or transaction that was rejected
-	// and therefore only applies the MsgBlock and MsgTx messages.
+	// and therefore only applies the MsgBlock and MsgTx messages before
+	// protocol version RejectHashVersion, and to any message from it.
 	Hash hash.Hash
 }
 
+// hasHash returns whether the hash is encoded for the passed protocol version.
+func (msg *MsgReject) hasHash(pver uint32) bool {
+	return msg.Cmd == CmdBlock || msg.Cmd == CmdTx ||
+		pver >= protocol.RejectHashVersion
+}
+
 // Decode decodes r encoding into the receiver.
 // This is part of the Message interface implementation.
 func (msg *MsgReject) Decode(r io.Reader, pver uint32) error {
 	// Command that was rejected.
-	cmd, err := s.ReadVarString(r, pver)
+	cmd, err := s.ReadVarBytes(r, pver, CommandSize, "rejected command")
 	if err != nil {
-		return err
+		return messageError("MsgReject.Decode", err.Error())
 	}
-	msg.Cmd = cmd
+	msg.Cmd = string(cmd)
 
 	// Code indicating why the command was rejected.
 	err = s.ReadElements(r, &msg.Code)
@@ -95,15 +110,26 @@ func (msg *MsgReject) Decode(r io.Reader, pver uint32) error {
 
 	// Human readable string with specific details (over and above the
 	// reject code above) about why the command was rejected.
-	reason, err := s.ReadVarString(r, pver)
-	if err != nil {
-		return err
+	// Its length is bounded from RejectReasonLenVersion.
+	if pver >= protocol.RejectReasonLenVersion {
+		reason, err := s.ReadVarBytes(r, pver, MaxRejectReasonLen,
+			"reject reason")
+		if err != nil {
+			return messageError("MsgReject.Decode", err.Error())
+		}
+		msg.Reason = string(reason)
+	} else {
+		reason, err := s.ReadVarString(r, pver)
+		if err != nil {
+			return err
+		}
+		msg.Reason = reason
 	}
-	msg.Reason = reason
 
 	// CmdBlock and CmdTx messages have an additional hash field that
-	// identifies the specific block or transaction.
-	if msg.Cmd == CmdBlock || msg.Cmd == CmdTx {
+	// identifies the specific block or transaction, as have all messages
+	// from RejectHashVersion.
+	if msg.hasHash(pver) {
 		err := s.ReadElements(r, &msg.Hash)
 		if err != nil {
 			return err
@@ -116,6 +142,18 @@ func (msg *MsgReject) Decode(r io.Reader, pver uint32) error {
 // Encode encodes the receiver to w.
 // This is part of the Message interface implementation.
 func (msg *MsgReject) Encode(w io.Writer, pver uint32) error {
+	if len(msg.Cmd) > CommandSize {
+		str := fmt.Sprintf("rejected command is too long [len %v, max %v]",
+			len(msg.Cmd), CommandSize)
+		return messageError("MsgReject.Encode", str)
+	}
+	if pver >= protocol.RejectReasonLenVersion &&
+		len(msg.Reason) > MaxRejectReasonLen {
+		str := fmt.Sprintf("reject reason is too long [len %v, max %v]",
+			len(msg.Reason), MaxRejectReasonLen)
+		return messageError("MsgReject.Encode", str)
+	}
+
 	// Command that was rejected.
 	err := s.WriteVarString(w, pver, msg.Cmd)
 	if err != nil {
@@ -136,8 +174,9 @@ func (msg *MsgReject) Encode(w io.Writer, pver uint32) error {
 	}
 
 	// CmdBlock and CmdTx messages have an additional hash field that
-	// identifies the specific block or transaction.
-	if msg.Cmd == CmdBlock || msg.Cmd == CmdTx {
+	// identifies the specific block or transaction, as have all messages
+	// from RejectHashVersion.
+	if msg.hasHash(pver) {
 		err := s.WriteElements(w, &msg.Hash)
 		if err != nil {
 			return err
@@ -156,17 +195,30 @@ func (msg *MsgReject) Command() string {
 // MaxPayloadLength returns the maximum length the payload can be for the
 // receiver.  This is part of the Message interface implementation.
 func (msg *MsgReject) MaxPayloadLength(pver uint32) uint32 {
-	// Unfortunately the protocol does not enforce a sane limit on the
-	// length of the reason, so the max payload is the overall maximum
-	// message payload.
-	// TODO, revisit the limit design
-	return uint32(MaxMessagePayload)
+	// The reason isn't bounded before RejectReasonLenVersion, so the max
+	// payload is the overall maximum message payload.
+	if pver < protocol.RejectReasonLenVersion {
+		return uint32(MaxMessagePayload)
+	}
+
+	// Command (varString) + code 1 byte + reason (varString) + hash.
+	return uint32(s.VarIntSerializeSize(CommandSize)) + CommandSize + 1 +
+		uint32(s.VarIntSerializeSize(MaxRejectReasonLen)) +
+		MaxRejectReasonLen + hash.HashSize
 }
 
 // NewMsgReject returns a new reject message that conforms to the Message
-// interface.
+// interface.  The reason is truncated to MaxRejectReasonLen, at the start of
+// a UTF-8 character.
 // See MsgReject for details.
 func NewMsgReject(command string, code RejectCode, reason string) *MsgReject {
+	if len(reason) > MaxRejectReasonLen {
+		end := MaxRejectReasonLen
+		for end > 0 && !utf8.RuneStart(reason[end]) {
+			end--
+		}
+		reason = reason[:end]
+	}
 	return &MsgReject{
 		Cmd:    command,
 		Code:   code,
@@ -0,0 +1,127 @@
+package message
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/protocol"
+)
+
+func TestRejectRoundTrip(t *testing.T) {
+	pver := protocol.ProtocolVersion
+	tests := []struct {
+		name string
+		msg  *MsgReject
+	}{
+		{"tx", &MsgReject{Cmd: CmdTx, Code: RejectInsufficientFee,
+			Reason: "insufficient fee", Hash: hash.Hash{0x01}}},
+		{"block", &MsgReject{Cmd: CmdBlock, Code: RejectInvalid,
+			Reason: "invalid block", Hash: hash.Hash{0x02}}},
+		{"no hash", NewMsgReject(CmdVersion, RejectDuplicate,
+			"duplicate version message")},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := WriteMessage(&buf, test.msg, pver, protocol.MainNet); err != nil {
+			t.Errorf("%s: WriteMessage: %v", test.name, err)
+			continue
+		}
+		read, _, err := ReadMessage(&buf, pver, protocol.MainNet)
+		if err != nil {
+			t.Errorf("%s: ReadMessage: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(read, test.msg) {
+			t.Errorf("%s: read %v, want %v", test.name, read, test.msg)
+		}
+	}
+}
+
+// TestRejectMaxPayloadLength ensures a reject message of the longest command
+// and reason fits in the max payload, and that a longer reason doesn't encode
+// nor decode.
+func TestRejectMaxPayloadLength(t *testing.T) {
+	pver := protocol.ProtocolVersion
+	msg := &MsgReject{
+		Cmd:    strings.Repeat("c", CommandSize),
+		Code:   RejectMalformed,
+		Reason: strings.Repeat("r", MaxRejectReasonLen),
+	}
+	var buf bytes.Buffer
+	if err := msg.Encode(&buf, pver); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	// The longest command doesn't have a hash.
+	if want := msg.MaxPayloadLength(pver) - hash.HashSize; uint32(buf.Len()) != want {
+		t.Errorf("payload of %d bytes, want %d", buf.Len(), want)
+	}
+	var decoded MsgReject
+	if err := decoded.Decode(&buf, pver); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if decoded != *msg {
+		t.Errorf("decoded %v, want %v", &decoded, msg)
+	}
+
+	msg.Reason += "r"
+	if err := msg.Encode(&bytes.Buffer{}, pver); err == nil {
+		t.Errorf("Encode of a %d bytes reason succeeded", len(msg.Reason))
+	}
+	buf.Reset()
+	msg.Cmd = CmdTx
+	msg.Reason = ""
+	msg.Encode(&buf, pver)
+	// Replace the empty reason with a too long one.
+	payload := buf.Bytes()
+	long := append([]byte{}, payload[:len(CmdTx)+2]...)
+	long = append(long, 0xfd, byte(MaxRejectReasonLen+1), byte((MaxRejectReasonLen+1)>>8))
+	long = append(long, strings.Repeat("r", MaxRejectReasonLen+1)...)
+	long = append(long, payload[len(CmdTx)+3:]...)
+	if err := decoded.Decode(bytes.NewReader(long), pver); err == nil {
+		t.Errorf("Decode of a %d bytes reason succeeded", MaxRejectReasonLen+1)
+	}
+
+	// NewMsgReject truncates the reason, at the start of a character.
+	msg = NewMsgReject(CmdTx, RejectInvalid, strings.Repeat("r", 2*MaxRejectReasonLen))
+	if len(msg.Reason) != MaxRejectReasonLen {
+		t.Errorf("got a reason of %d bytes, want %d", len(msg.Reason),
+			MaxRejectReasonLen)
+	}
+	msg = NewMsgReject(CmdTx, RejectInvalid, "r"+strings.Repeat("é", MaxRejectReasonLen))
+	if len(msg.Reason) != MaxRejectReasonLen-1 || !utf8.ValidString(msg.Reason) {
+		t.Errorf("got a reason of %d bytes %q, want %d valid UTF-8 "+
+			"bytes", len(msg.Reason), msg.Reason, MaxRejectReasonLen-1)
+	}
+}
+
+// TestRejectReasonBeforeLenVersion ensures the reason of the reject messages
+// isn't bounded before RejectReasonLenVersion.
+func TestRejectReasonBeforeLenVersion(t *testing.T) {
+	pver := protocol.RejectReasonLenVersion - 1
+	msg := &MsgReject{
+		Cmd:    CmdTx,
+		Code:   RejectInvalid,
+		Reason: strings.Repeat("r", 2*MaxRejectReasonLen),
+		Hash:   hash.Hash{0x01},
+	}
+	if got := msg.MaxPayloadLength(pver); got != MaxMessagePayload {
+		t.Errorf("max payload of %d bytes, want %d", got,
+			MaxMessagePayload)
+	}
+	var buf bytes.Buffer
+	if err := WriteMessage(&buf, msg, pver, protocol.MainNet); err != nil {
+		t.Fatalf("WriteMessage: %v", err)
+	}
+	read, _, err := ReadMessage(&buf, pver, protocol.MainNet)
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	if !reflect.DeepEqual(read, msg) {
+		t.Errorf("read %v, want %v", read, msg)
+	}
+}
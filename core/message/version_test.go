@@ -0,0 +1,118 @@
+package message
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/protocol"
+	"github.com/Qitmeer/qitmeer/core/types"
+)
+
+// testVersions are the protocol versions the messages are encoded with, from
+// the initial one to the latest one.
+var testVersions = []uint32{
+	protocol.InitialProcotolVersion,
+	protocol.AddrV2Version - 1,
+	protocol.AddrV2Version,
+	protocol.RejectHashVersion - 1,
+	protocol.RejectHashVersion,
+	protocol.RejectReasonLenVersion - 1,
+	protocol.RejectReasonLenVersion,
+	protocol.ProtocolVersion,
+}
+
+// TestCrossVersionRoundTrip encodes messages with each protocol version, and
+// ensures that they decode with the same version to the fields of that
+// version, and that those added in a later version don't encode before it.
+func TestCrossVersionRoundTrip(t *testing.T) {
+	addrV2 := NewMsgAddrV2()
+	addrV2.AddAddress(types.NewNetAddressV2IP(net.ParseIP("127.0.0.1"), 18130,
+		protocol.Full))
+	blockHash := hash.Hash{0x01}
+
+	tests := []struct {
+		name string
+		msg  Message
+		// added is the version which added the message.
+		added uint32
+		// want returns the message decoded with the passed version, or
+		// nil when it's the encoded one.
+		want func(pver uint32) Message
+	}{
+		{name: "verack", msg: NewMsgVerAck()},
+		{name: "getaddr", msg: NewMsgGetAddr()},
+		{name: "mempool", msg: NewMsgMemPool()},
+		{name: "ping", msg: NewMsgPing(1)},
+		{name: "pong", msg: NewMsgPong(1)},
+		{name: "getcmpctblock", msg: NewMsgGetCmpctBlock(&blockHash)},
+		{
+			name: "reject tx",
+			msg: &MsgReject{Cmd: CmdTx, Code: RejectDuplicate,
+				Reason: "duplicate", Hash: blockHash},
+		},
+		{
+			name: "reject getcmpctblock",
+			msg: &MsgReject{Cmd: CmdGetCmpctBlock, Code: RejectInvalid,
+				Reason: "unknown block", Hash: blockHash},
+			want: func(pver uint32) Message {
+				if pver >= protocol.RejectHashVersion {
+					return nil
+				}
+				return &MsgReject{Cmd: CmdGetCmpctBlock,
+					Code: RejectInvalid, Reason: "unknown block"}
+			},
+		},
+		{name: "addrv2", msg: addrV2, added: protocol.AddrV2Version},
+	}
+
+	for _, test := range tests {
+		for _, pver := range testVersions {
+			var buf bytes.Buffer
+			err := WriteMessage(&buf, test.msg, pver, protocol.MainNet)
+			if pver < test.added {
+				if _, ok := err.(*MessageError); !ok {
+					t.Errorf("%s: encode with version %d: got "+
+						"error %v, want a *MessageError",
+						test.name, pver, err)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s: encode with version %d: %v",
+					test.name, pver, err)
+				continue
+			}
+
+			decoded, _, err := ReadMessage(&buf, pver, protocol.MainNet)
+			if err != nil {
+				t.Errorf("%s: decode with version %d: %v",
+					test.name, pver, err)
+				continue
+			}
+			want := test.msg
+			if test.want != nil && test.want(pver) != nil {
+				want = test.want(pver)
+			}
+			if !reflect.DeepEqual(decoded, want) {
+				t.Errorf("%s: version %d decoded %v, want %v",
+					test.name, pver, decoded, want)
+			}
+		}
+	}
+}
+
+// TestRequireVersion ensures a message added in a version is only valid from
+// that version.
+func TestRequireVersion(t *testing.T) {
+	if err := requireVersion("f", CmdAddrV2, 21, 22); err == nil {
+		t.Errorf("requireVersion of version 21 for 22 succeeded")
+	}
+	for _, pver := range []uint32{22, 23} {
+		if err := requireVersion("f", CmdAddrV2, pver, 22); err != nil {
+			t.Errorf("requireVersion of version %d for 22: %v", pver, err)
+		}
+	}
+}
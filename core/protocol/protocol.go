@@ -16,7 +16,21 @@ const (
 	InitialProcotolVersion uint32 = 20
 
 	// ProtocolVersion is the latest protocol version this package supports.
-	ProtocolVersion uint32 = 21
+	ProtocolVersion uint32 = 24
+
+	// AddrV2Version is the protocol version which added the addrv2 message
+	// of variable length addresses.
+	AddrV2Version uint32 = 22
+
+	// RejectHashVersion is the protocol version which added the hash of the
+	// rejected object to the reject messages of any command, instead of only
+	// those of blocks and transactions.
+	RejectHashVersion uint32 = 23
+
+	// RejectReasonLenVersion is the protocol version which bounded the
+	// reason of the reject messages to MaxRejectReasonLen bytes, and so
+	// their max payload.
+	RejectReasonLenVersion uint32 = 24
 )
 
 // Network represents which qitmeer network a message belongs to.
@@ -1,5 +1,16 @@
 package types
 
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/Qitmeer/qitmeer/common/hash"
+)
+
 // this standard target use for miner to verify Their work
 // for different pow work diff
 // blake2bd on hash compare hash <= target
@@ -20,6 +31,19 @@ type PowDiffStandard struct {
 	CuckarooDiffScale  uint64
 	CuckatooDiffScale  uint64
 	CuckaroomDiffScale uint64
+
+	//cuckoo edge bits range the verification accepts
+	CuckarooMinEdgeBits  uint8
+	CuckarooMaxEdgeBits  uint8
+	CuckatooMinEdgeBits  uint8
+	CuckatooMaxEdgeBits  uint8
+	CuckaroomMinEdgeBits uint8
+	CuckaroomMaxEdgeBits uint8
+
+	//cuckoo cycle length, which is the number of nonces of the proof
+	CuckarooProofSize  int
+	CuckatooProofSize  int
+	CuckaroomProofSize int
 }
 
 // BlockTemplate houses a block that has yet to be solved along with additional
@@ -49,6 +73,38 @@ type BlockTemplate struct {
 	// the DAG
 	Blues int64
 
+	// BlueSet and RedSet are the blocks the block merges into the past of
+	// its main parent, split by their color.  The main parent is part of
+	// BlueSet.  Both are ordered by the order the node added the blocks to
+	// its DAG, which may differ between nodes, so they should be compared
+	// as sets.
+	BlueSet []*hash.Hash
+	RedSet  []*hash.Hash
+
+	// Subsidy is the block subsidy the coinbase pays to the miner, which
+	// excludes both the tax paid to the organization and the transaction
+	// fees.  The coinbase outputs don't encode the fees, which the
+	// consensus rules credit to the miner when the coinbase is spent;
+	// their total is the negative of the first entry in Fees, so the
+	// miner earns Subsidy - Fees[0] in all.
+	Subsidy int64
+
+	// ExtraNonceOffset and ExtraNonceSize locate the bytes reserved for
+	// the extra nonce in the signature script of the coinbase, which
+	// miners can overwrite without invalidating the height push.  Since
+	// the witness commitment of the coinbase covers its signature script,
+	// it has to be updated after rolling the extra nonce.  ExtraNonceSize
+	// is zero when no bytes were reserved, which is always the case when
+	// the coinbase was built outside of the node.
+	ExtraNonceOffset int
+	ExtraNonceSize   int
+
+	// CoinbaseCommitment is the commitment over the transactions of the
+	// block embedded in the OP_RETURN output of the coinbase, as computed
+	// by the CoinbaseCommitment function of the mining policy.  It is nil
+	// when the policy doesn't commit to the transactions.
+	CoinbaseCommitment []byte
+
 	// ValidPayAddress indicates whether or not the template coinbase pays
 	// to an address or is redeemable by anyone.  See the documentation on
 	// NewBlockTemplate for details on which this can be useful to generate
@@ -57,4 +113,302 @@ type BlockTemplate struct {
 
 	//pow diff standard
 	PowDiffData PowDiffStandard
+
+	// PrevOuts are the outputs spent by the transactions of the block
+	// other than the coinbase, keyed by the outpoint of the input spending
+	// them, as the template was validated against.  Stateless signers need
+	// them to compute signature hashes.  It is nil unless the mining policy
+	// includes them, since they bloat the template.
+	PrevOuts map[TxOutPoint]*TemplatePrevOut
+}
+
+// TemplatePrevOut is an output spent by a transaction of a block template.
+type TemplatePrevOut struct {
+	Amount   uint64
+	PkScript []byte
+}
+
+// hashTargetJSON is the JSON encoding of the target of a hash algorithm, as
+// its compact bits.
+type hashTargetJSON struct {
+	Bits string `json:"bits"`
+}
+
+// cuckooTargetJSON is the JSON encoding of the target of a cuckoo algorithm.
+type cuckooTargetJSON struct {
+	BaseDiff    uint64 `json:"base_diff"`
+	DiffScale   uint64 `json:"diff_scale"`
+	MinEdgeBits uint8  `json:"min_edge_bits"`
+	MaxEdgeBits uint8  `json:"max_edge_bits"`
+	ProofSize   int    `json:"proof_size"`
+}
+
+// powDiffStandardJSON is the JSON encoding of PowDiffStandard, keyed by
+// algorithm.
+type powDiffStandardJSON struct {
+	Blake2bD         hashTargetJSON   `json:"blake2bd"`
+	X16rv3           hashTargetJSON   `json:"x16rv3"`
+	X8r16            hashTargetJSON   `json:"x8r16"`
+	QitmeerKeccak256 hashTargetJSON   `json:"qitmeer_keccak256"`
+	Cuckaroo         cuckooTargetJSON `json:"cuckaroo"`
+	Cuckatoo         cuckooTargetJSON `json:"cuckatoo"`
+	Cuckaroom        cuckooTargetJSON `json:"cuckaroom"`
+}
+
+// MarshalJSON encodes the targets keyed by algorithm, with the compact bits
+// of the hash algorithms as hex strings.  The keys are part of the mining API
+// and must not change.
+func (pd PowDiffStandard) MarshalJSON() ([]byte, error) {
+	bits := func(compact uint32) hashTargetJSON {
+		return hashTargetJSON{Bits: fmt.Sprintf("%08x", compact)}
+	}
+	return json.Marshal(&powDiffStandardJSON{
+		Blake2bD:         bits(pd.Blake2bDTarget),
+		X16rv3:           bits(pd.X16rv3DTarget),
+		X8r16:            bits(pd.X8r16DTarget),
+		QitmeerKeccak256: bits(pd.QitmeerKeccak256Target),
+		Cuckaroo: cuckooTargetJSON{
+			BaseDiff:    pd.CuckarooBaseDiff,
+			DiffScale:   pd.CuckarooDiffScale,
+			MinEdgeBits: pd.CuckarooMinEdgeBits,
+			MaxEdgeBits: pd.CuckarooMaxEdgeBits,
+			ProofSize:   pd.CuckarooProofSize,
+		},
+		Cuckatoo: cuckooTargetJSON{
+			BaseDiff:    pd.CuckatooBaseDiff,
+			DiffScale:   pd.CuckatooDiffScale,
+			MinEdgeBits: pd.CuckatooMinEdgeBits,
+			MaxEdgeBits: pd.CuckatooMaxEdgeBits,
+			ProofSize:   pd.CuckatooProofSize,
+		},
+		Cuckaroom: cuckooTargetJSON{
+			BaseDiff:    pd.CuckaroomBaseDiff,
+			DiffScale:   pd.CuckaroomDiffScale,
+			MinEdgeBits: pd.CuckaroomMinEdgeBits,
+			MaxEdgeBits: pd.CuckaroomMaxEdgeBits,
+			ProofSize:   pd.CuckaroomProofSize,
+		},
+	})
+}
+
+// UnmarshalJSON decodes the targets encoded by MarshalJSON.
+func (pd *PowDiffStandard) UnmarshalJSON(data []byte) error {
+	var decoded powDiffStandardJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	bits := func(target hashTargetJSON) (uint32, error) {
+		compact, err := strconv.ParseUint(target.Bits, 16, 32)
+		if err != nil {
+			return 0, fmt.Errorf("invalid target bits %q: %v", target.Bits,
+				err)
+		}
+		return uint32(compact), nil
+	}
+	var err error
+	if pd.Blake2bDTarget, err = bits(decoded.Blake2bD); err != nil {
+		return err
+	}
+	if pd.X16rv3DTarget, err = bits(decoded.X16rv3); err != nil {
+		return err
+	}
+	if pd.X8r16DTarget, err = bits(decoded.X8r16); err != nil {
+		return err
+	}
+	if pd.QitmeerKeccak256Target, err = bits(decoded.QitmeerKeccak256); err != nil {
+		return err
+	}
+	pd.CuckarooBaseDiff = decoded.Cuckaroo.BaseDiff
+	pd.CuckarooDiffScale = decoded.Cuckaroo.DiffScale
+	pd.CuckarooMinEdgeBits = decoded.Cuckaroo.MinEdgeBits
+	pd.CuckarooMaxEdgeBits = decoded.Cuckaroo.MaxEdgeBits
+	pd.CuckarooProofSize = decoded.Cuckaroo.ProofSize
+	pd.CuckatooBaseDiff = decoded.Cuckatoo.BaseDiff
+	pd.CuckatooDiffScale = decoded.Cuckatoo.DiffScale
+	pd.CuckatooMinEdgeBits = decoded.Cuckatoo.MinEdgeBits
+	pd.CuckatooMaxEdgeBits = decoded.Cuckatoo.MaxEdgeBits
+	pd.CuckatooProofSize = decoded.Cuckatoo.ProofSize
+	pd.CuckaroomBaseDiff = decoded.Cuckaroom.BaseDiff
+	pd.CuckaroomDiffScale = decoded.Cuckaroom.DiffScale
+	pd.CuckaroomMinEdgeBits = decoded.Cuckaroom.MinEdgeBits
+	pd.CuckaroomMaxEdgeBits = decoded.Cuckaroom.MaxEdgeBits
+	pd.CuckaroomProofSize = decoded.Cuckaroom.ProofSize
+	return nil
+}
+
+// blockTemplateJSON is the JSON encoding of BlockTemplate.
+type blockTemplateJSON struct {
+	Block              string          `json:"block"`
+	Fees               []int64         `json:"fees"`
+	SigOpCounts        []int64         `json:"sigopcounts"`
+	Height             uint64          `json:"height"`
+	Blues              int64           `json:"blues"`
+	BlueSet            []string        `json:"blueset"`
+	RedSet             []string        `json:"redset"`
+	Subsidy            int64           `json:"subsidy"`
+	ExtraNonceOffset   int             `json:"extranonceoffset"`
+	ExtraNonceSize     int             `json:"extranoncesize"`
+	CoinbaseCommitment string          `json:"coinbasecommitment"`
+	ValidPayAddress    bool            `json:"validpayaddress"`
+	PowDiffData        PowDiffStandard `json:"powdiffdata"`
+	PrevOuts           []prevOutJSON   `json:"prevouts"`
+}
+
+// prevOutJSON is the JSON encoding of an entry of the prevouts of a template.
+type prevOutJSON struct {
+	TxID     string `json:"txid"`
+	Index    uint32 `json:"index"`
+	Amount   uint64 `json:"amount"`
+	PkScript string `json:"pkscript"`
+}
+
+// MarshalJSON encodes the template for the mining API, with the block as the
+// hex of its serialization.  Every field is always present, with empty lists
+// rather than nulls, so that the shape of the encoding doesn't depend on the
+// template.  The hashes are encoded as by their String method, and the
+// prevouts are ordered by outpoint.  The keys are part of the mining API and
+// must not change.
+func (bt *BlockTemplate) MarshalJSON() ([]byte, error) {
+	var block bytes.Buffer
+	if bt.Block != nil {
+		if err := bt.Block.Serialize(&block); err != nil {
+			return nil, err
+		}
+	}
+	hashStrings := func(hashes []*hash.Hash) []string {
+		strs := make([]string, len(hashes))
+		for i, h := range hashes {
+			strs[i] = h.String()
+		}
+		return strs
+	}
+	nonNilInts := func(ints []int64) []int64 {
+		if ints == nil {
+			return []int64{}
+		}
+		return ints
+	}
+	prevOuts := make([]prevOutJSON, 0, len(bt.PrevOuts))
+	for outpoint, prevOut := range bt.PrevOuts {
+		prevOuts = append(prevOuts, prevOutJSON{
+			TxID:     outpoint.Hash.String(),
+			Index:    outpoint.OutIndex,
+			Amount:   prevOut.Amount,
+			PkScript: hex.EncodeToString(prevOut.PkScript),
+		})
+	}
+	sort.Slice(prevOuts, func(i, j int) bool {
+		if prevOuts[i].TxID != prevOuts[j].TxID {
+			return prevOuts[i].TxID < prevOuts[j].TxID
+		}
+		return prevOuts[i].Index < prevOuts[j].Index
+	})
+	return json.Marshal(&blockTemplateJSON{
+		Block:              hex.EncodeToString(block.Bytes()),
+		Fees:               nonNilInts(bt.Fees),
+		SigOpCounts:        nonNilInts(bt.SigOpCounts),
+		Height:             bt.Height,
+		Blues:              bt.Blues,
+		BlueSet:            hashStrings(bt.BlueSet),
+		RedSet:             hashStrings(bt.RedSet),
+		Subsidy:            bt.Subsidy,
+		ExtraNonceOffset:   bt.ExtraNonceOffset,
+		ExtraNonceSize:     bt.ExtraNonceSize,
+		CoinbaseCommitment: hex.EncodeToString(bt.CoinbaseCommitment),
+		ValidPayAddress:    bt.ValidPayAddress,
+		PowDiffData:        bt.PowDiffData,
+		PrevOuts:           prevOuts,
+	})
+}
+
+// UnmarshalJSON decodes a template encoded by MarshalJSON, so that tools can
+// inspect the templates served by the mining API.  The empty lists of the
+// encoding are decoded as nil.
+func (bt *BlockTemplate) UnmarshalJSON(data []byte) error {
+	var decoded blockTemplateJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	var block *Block
+	if decoded.Block != "" {
+		blockBytes, err := hex.DecodeString(decoded.Block)
+		if err != nil {
+			return fmt.Errorf("invalid block hex: %v", err)
+		}
+		serialized, err := NewBlockFromBytes(blockBytes)
+		if err != nil {
+			return fmt.Errorf("invalid block: %v", err)
+		}
+		block = serialized.Block()
+	}
+	hashes := func(strs []string) ([]*hash.Hash, error) {
+		if len(strs) == 0 {
+			return nil, nil
+		}
+		hashes := make([]*hash.Hash, len(strs))
+		for i, str := range strs {
+			h, err := hash.NewHashFromStr(str)
+			if err != nil {
+				return nil, fmt.Errorf("invalid hash %q: %v", str, err)
+			}
+			hashes[i] = h
+		}
+		return hashes, nil
+	}
+	nilInts := func(ints []int64) []int64 {
+		if len(ints) == 0 {
+			return nil
+		}
+		return ints
+	}
+	blueSet, err := hashes(decoded.BlueSet)
+	if err != nil {
+		return err
+	}
+	redSet, err := hashes(decoded.RedSet)
+	if err != nil {
+		return err
+	}
+	var commitment []byte
+	if decoded.CoinbaseCommitment != "" {
+		commitment, err = hex.DecodeString(decoded.CoinbaseCommitment)
+		if err != nil {
+			return fmt.Errorf("invalid coinbase commitment hex: %v", err)
+		}
+	}
+	var prevOuts map[TxOutPoint]*TemplatePrevOut
+	if len(decoded.PrevOuts) > 0 {
+		prevOuts = make(map[TxOutPoint]*TemplatePrevOut, len(decoded.PrevOuts))
+	}
+	for _, prevOut := range decoded.PrevOuts {
+		txid, err := hash.NewHashFromStr(prevOut.TxID)
+		if err != nil {
+			return fmt.Errorf("invalid prevout txid %q: %v", prevOut.TxID,
+				err)
+		}
+		pkScript, err := hex.DecodeString(prevOut.PkScript)
+		if err != nil {
+			return fmt.Errorf("invalid prevout pkscript hex: %v", err)
+		}
+		prevOuts[TxOutPoint{Hash: *txid, OutIndex: prevOut.Index}] =
+			&TemplatePrevOut{Amount: prevOut.Amount, PkScript: pkScript}
+	}
+
+	*bt = BlockTemplate{
+		Block:              block,
+		Fees:               nilInts(decoded.Fees),
+		SigOpCounts:        nilInts(decoded.SigOpCounts),
+		Height:             decoded.Height,
+		Blues:              decoded.Blues,
+		BlueSet:            blueSet,
+		RedSet:             redSet,
+		Subsidy:            decoded.Subsidy,
+		ExtraNonceOffset:   decoded.ExtraNonceOffset,
+		ExtraNonceSize:     decoded.ExtraNonceSize,
+		CoinbaseCommitment: commitment,
+		ValidPayAddress:    decoded.ValidPayAddress,
+		PowDiffData:        decoded.PowDiffData,
+		PrevOuts:           prevOuts,
+	}
+	return nil
 }
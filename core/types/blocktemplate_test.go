@@ -0,0 +1,257 @@
+package types
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/types/pow"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files")
+
+// newTestBlockTemplate returns a template with every field populated.
+func newTestBlockTemplate() *BlockTemplate {
+	coinbase := NewTransaction()
+	coinbase.AddTxIn(&TxInput{
+		PreviousOut: *NewOutPoint(&hash.Hash{}, MaxPrevOutIndex),
+		Sequence:    MaxTxInSequenceNum,
+		SignScript:  []byte{0x51, 0x51},
+	})
+	coinbase.AddTxOut(NewTxOutput(5000000000, []byte{0x51}))
+	coinbase.Timestamp = time.Unix(1560000000, 0)
+
+	block := &Block{
+		Header: BlockHeader{
+			Version:    1,
+			ParentRoot: hash.Hash{0x01},
+			TxRoot:     hash.Hash{0x02},
+			StateRoot:  hash.Hash{0x03},
+			Difficulty: 0x1d00ffff,
+			Timestamp:  time.Unix(1560000000, 0),
+			Pow:        pow.GetInstance(pow.BLAKE2BD, 0, []byte{}),
+		},
+		Parents:      []*hash.Hash{{0x01}, {0x04}},
+		Transactions: []*Transaction{coinbase},
+	}
+	return &BlockTemplate{
+		Block:              block,
+		Fees:               []int64{-100},
+		SigOpCounts:        []int64{1},
+		Height:             10,
+		Blues:              2,
+		BlueSet:            []*hash.Hash{{0x01}},
+		RedSet:             []*hash.Hash{{0x04}},
+		Subsidy:            5000000000,
+		ExtraNonceOffset:   42,
+		ExtraNonceSize:     8,
+		CoinbaseCommitment: []byte{0xab, 0xcd},
+		ValidPayAddress:    true,
+		PowDiffData: PowDiffStandard{
+			Blake2bDTarget:         0x1d00ffff,
+			X16rv3DTarget:          0x1d00fffe,
+			X8r16DTarget:           0x1d00fffd,
+			QitmeerKeccak256Target: 0x1d00fffc,
+			CuckarooBaseDiff:       1,
+			CuckarooDiffScale:      2,
+			CuckarooMinEdgeBits:    24,
+			CuckarooMaxEdgeBits:    32,
+			CuckarooProofSize:      42,
+			CuckatooBaseDiff:       3,
+			CuckatooDiffScale:      4,
+			CuckatooMinEdgeBits:    29,
+			CuckatooMaxEdgeBits:    32,
+			CuckatooProofSize:      42,
+			CuckaroomBaseDiff:      5,
+			CuckaroomDiffScale:     6,
+			CuckaroomMinEdgeBits:   24,
+			CuckaroomMaxEdgeBits:   32,
+			CuckaroomProofSize:     42,
+		},
+		PrevOuts: map[TxOutPoint]*TemplatePrevOut{
+			{Hash: hash.Hash{0x05}, OutIndex: 1}: {
+				Amount:   100,
+				PkScript: []byte{0x51},
+			},
+			{Hash: hash.Hash{0x05}, OutIndex: 0}: {
+				Amount:   200,
+				PkScript: []byte{0x52},
+			},
+		},
+	}
+}
+
+// jsonShape returns the shape of a decoded JSON value: the objects with the
+// shapes of their values, the arrays with the shape of their first element,
+// and the names of the types of the other values.
+func jsonShape(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		shape := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			shape[key] = jsonShape(value)
+		}
+		return shape
+	case []interface{}:
+		if len(v) == 0 {
+			return []interface{}{}
+		}
+		return []interface{}{jsonShape(v[0])}
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "bool"
+	}
+	return "null"
+}
+
+// TestBlockTemplateJSON ensures the JSON encoding of the block templates keeps
+// the field names and shapes of the golden file, which the clients of the
+// mining API rely on, and encodes the values of the template.  Run the test
+// with -update to rewrite the golden file after an intended change.
+func TestBlockTemplateJSON(t *testing.T) {
+	template := newTestBlockTemplate()
+	encoded, err := json.Marshal(template)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	again, err := json.Marshal(template)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if !bytes.Equal(encoded, again) {
+		t.Fatalf("encoding isn't deterministic:\n%s\n%s", encoded, again)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	shape, err := json.MarshalIndent(jsonShape(decoded), "", "  ")
+	if err != nil {
+		t.Fatalf("MarshalIndent: %v", err)
+	}
+	shape = append(shape, '\n')
+	golden := filepath.Join("testdata", "blocktemplate.golden")
+	if *updateGolden {
+		if err := ioutil.WriteFile(golden, shape, 0644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if !bytes.Equal(shape, want) {
+		t.Errorf("encoding shape changed:\n%s\nwant:\n%s", shape, want)
+	}
+
+	// The block is encoded as its serialization.
+	blockBytes, err := hex.DecodeString(decoded["block"].(string))
+	if err != nil {
+		t.Fatalf("DecodeString: %v", err)
+	}
+	block, err := NewBlockFromBytes(blockBytes)
+	if err != nil {
+		t.Fatalf("NewBlockFromBytes: %v", err)
+	}
+	if got, want := *block.Hash(), template.Block.BlockHash(); got != want {
+		t.Errorf("got block %v, want %v", got, want)
+	}
+
+	powDiff := decoded["powdiffdata"].(map[string]interface{})
+	target := func(algo, key string) interface{} {
+		return powDiff[algo].(map[string]interface{})[key]
+	}
+	prevOut := func(i int, key string) interface{} {
+		prevOuts := decoded["prevouts"].([]interface{})
+		return prevOuts[i].(map[string]interface{})[key]
+	}
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"fee", decoded["fees"].([]interface{})[0], float64(-100)},
+		{"height", decoded["height"], float64(10)},
+		{"blue", decoded["blueset"].([]interface{})[0],
+			template.BlueSet[0].String()},
+		{"red", decoded["redset"].([]interface{})[0],
+			template.RedSet[0].String()},
+		{"commitment", decoded["coinbasecommitment"], "abcd"},
+		{"blake2bd bits", target("blake2bd", "bits"), "1d00ffff"},
+		{"qitmeer_keccak256 bits", target("qitmeer_keccak256", "bits"),
+			"1d00fffc"},
+		{"cuckaroo base diff", target("cuckaroo", "base_diff"), float64(1)},
+		{"cuckatoo base diff", target("cuckatoo", "base_diff"), float64(3)},
+		{"cuckaroom base diff", target("cuckaroom", "base_diff"), float64(5)},
+		{"first prevout index", prevOut(0, "index"), float64(0)},
+		{"first prevout amount", prevOut(0, "amount"), float64(200)},
+		{"second prevout pkscript", prevOut(1, "pkscript"), "51"},
+	}
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("got %s %v, want %v", test.name, test.got, test.want)
+		}
+	}
+
+	// An empty template keeps the shape of its lists.
+	encoded, err = json.Marshal(&BlockTemplate{})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	decoded = nil
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	for _, key := range []string{"fees", "sigopcounts", "blueset", "redset",
+		"prevouts"} {
+		if _, ok := decoded[key].([]interface{}); !ok {
+			t.Errorf("got %s %v, want a list", key, decoded[key])
+		}
+	}
+}
+
+// TestBlockTemplateJSONRoundTrip ensures decoding the JSON encoding of a
+// template gives back the template.
+func TestBlockTemplateJSONRoundTrip(t *testing.T) {
+	template := newTestBlockTemplate()
+	encoded, err := json.Marshal(template)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var decoded BlockTemplate
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	again, err := json.Marshal(&decoded)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if !bytes.Equal(encoded, again) {
+		t.Errorf("round trip changed the encoding:\n%s\n%s", encoded, again)
+	}
+
+	if got, want := decoded.Block.BlockHash(), template.Block.BlockHash(); got != want {
+		t.Errorf("got block %v, want %v", got, want)
+	}
+	decoded.Block = template.Block
+	if !reflect.DeepEqual(&decoded, template) {
+		t.Errorf("got template %+v, want %+v", &decoded, template)
+	}
+
+	// An invalid target doesn't decode.
+	invalid := bytes.Replace(encoded, []byte(`"1d00ffff"`), []byte(`"zz"`), 1)
+	if err := json.Unmarshal(invalid, &decoded); err == nil {
+		t.Errorf("decoded a template with invalid target bits")
+	}
+}
@@ -0,0 +1,206 @@
+// Copyright (c) 2017-2018 The qitmeer developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package types
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/Qitmeer/qitmeer/core/protocol"
+	s "github.com/Qitmeer/qitmeer/core/serialization"
+	"io"
+	"net"
+	"time"
+)
+
+// NetAddressType identifies the network of the address of a NetAddressV2.
+type NetAddressType uint8
+
+const (
+	// IPv4Address is an IPv4 address of 4 bytes.
+	IPv4Address NetAddressType = 1
+
+	// IPv6Address is an IPv6 address of 16 bytes.
+	IPv6Address NetAddressType = 2
+
+	// TorV3Address is the 32 bytes ed25519 public key of a Tor v3 onion
+	// service.
+	TorV3Address NetAddressType = 4
+
+	// I2PAddress is the 32 bytes sha256 hash of an I2P destination.
+	I2PAddress NetAddressType = 5
+)
+
+// MaxNetAddressV2Size is the max size of the address of a NetAddressV2,
+// including those of types unknown to this version.
+const MaxNetAddressV2Size = 512
+
+// netAddressSizes is the size of the address of the known types.
+var netAddressSizes = map[NetAddressType]int{
+	IPv4Address:  net.IPv4len,
+	IPv6Address:  net.IPv6len,
+	TorV3Address: 32,
+	I2PAddress:   32,
+}
+
+// String returns the NetAddressType in human-readable form.
+func (t NetAddressType) String() string {
+	switch t {
+	case IPv4Address:
+		return "IPv4"
+	case IPv6Address:
+		return "IPv6"
+	case TorV3Address:
+		return "TorV3"
+	case I2PAddress:
+		return "I2P"
+	}
+	return fmt.Sprintf("Unknown NetAddressType (%d)", uint8(t))
+}
+
+// MaxNetAddressV2Payload returns the max payload size for the NetAddressV2
+// based on the protocol version.
+func MaxNetAddressV2Payload(pver uint32) uint32 {
+	// Timestamp 4 bytes + services 8 bytes + type 1 byte.
+	plen := uint32(13)
+
+	// Address length (varInt) + max address size.
+	plen += uint32(s.VarIntSerializeSize(MaxNetAddressV2Size)) +
+		MaxNetAddressV2Size
+
+	// Port 2 bytes.
+	plen += 2
+
+	return plen
+}
+
+// NetAddressV2 is a NetAddress of a variable length address, so that it can
+// be of networks other than IP, such as Tor v3 and I2P.  The address is kept
+// as is for the types unknown to this version, so they can still be relayed.
+type NetAddressV2 struct {
+	// Last time the address was seen, encoded as a uint32 on the wire.
+	Timestamp time.Time
+
+	// Bitfield which identifies the services supported by the address.
+	Services protocol.ServiceFlag
+
+	// Type is the network of the address.
+	Type NetAddressType
+
+	// Addr is the address, its size being set by the type.
+	Addr []byte
+
+	// Port the peer is using, encoded in big endian on the wire.
+	Port uint16
+}
+
+// NewNetAddressV2 returns a new NetAddressV2 of the passed type and address.
+// It is an error for the address not to be of the size of its type.
+func NewNetAddressV2(timestamp time.Time, services protocol.ServiceFlag,
+	addrType NetAddressType, addr []byte, port uint16) (*NetAddressV2, error) {
+	err := checkNetAddressV2(addrType, len(addr))
+	if err != nil {
+		return nil, err
+	}
+	return &NetAddressV2{
+		Timestamp: time.Unix(timestamp.Unix(), 0),
+		Services:  services,
+		Type:      addrType,
+		Addr:      addr,
+		Port:      port,
+	}, nil
+}
+
+// NewNetAddressV2IP returns a new NetAddressV2 of the passed IP, which is an
+// IPv4 address when it can be represented as one.
+func NewNetAddressV2IP(ip net.IP, port uint16, services protocol.ServiceFlag) *NetAddressV2 {
+	na := &NetAddressV2{
+		Timestamp: time.Unix(time.Now().Unix(), 0),
+		Services:  services,
+		Type:      IPv6Address,
+		Addr:      ip.To16(),
+		Port:      port,
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		na.Type, na.Addr = IPv4Address, ip4
+	}
+	return na
+}
+
+// IP returns the IP of the address, or nil when it's not of an IP type.
+func (na *NetAddressV2) IP() net.IP {
+	if na.Type != IPv4Address && na.Type != IPv6Address {
+		return nil
+	}
+	return net.IP(na.Addr)
+}
+
+// checkNetAddressV2 returns an error when an address of the passed type
+// can't be of the passed size.
+func checkNetAddressV2(addrType NetAddressType, size int) error {
+	want, ok := netAddressSizes[addrType]
+	if !ok {
+		if size > MaxNetAddressV2Size {
+			return fmt.Errorf("%v of %d bytes is too large [max %d]",
+				addrType, size, MaxNetAddressV2Size)
+		}
+		return nil
+	}
+	if size != want {
+		return fmt.Errorf("%v address of %d bytes, want %d", addrType,
+			size, want)
+	}
+	return nil
+}
+
+// ReadNetAddressV2 reads an encoded NetAddressV2 from r.
+func ReadNetAddressV2(r io.Reader, pver uint32, na *NetAddressV2) error {
+	var addrType uint8
+	err := s.ReadElements(r, (*s.Uint32Time)(&na.Timestamp), &na.Services,
+		&addrType)
+	if err != nil {
+		return err
+	}
+	na.Type = NetAddressType(addrType)
+
+	size, err := s.ReadVarInt(r, pver)
+	if err != nil {
+		return err
+	}
+	// Check the size before reading to not allocate a forged one.
+	if size > MaxNetAddressV2Size {
+		return fmt.Errorf("%v of %d bytes is too large [max %d]",
+			na.Type, size, MaxNetAddressV2Size)
+	}
+	err = checkNetAddressV2(na.Type, int(size))
+	if err != nil {
+		return err
+	}
+	na.Addr = make([]byte, size)
+	_, err = io.ReadFull(r, na.Addr)
+	if err != nil {
+		return err
+	}
+
+	na.Port, err = s.BinarySerializer.Uint16(r, binary.BigEndian)
+	return err
+}
+
+// WriteNetAddressV2 serializes a NetAddressV2 to w.
+func WriteNetAddressV2(w io.Writer, pver uint32, na *NetAddressV2) error {
+	err := checkNetAddressV2(na.Type, len(na.Addr))
+	if err != nil {
+		return err
+	}
+	err = s.WriteElements(w, uint32(na.Timestamp.Unix()), na.Services,
+		uint8(na.Type))
+	if err != nil {
+		return err
+	}
+	err = s.WriteVarBytes(w, pver, na.Addr)
+	if err != nil {
+		return err
+	}
+	return binary.Write(w, binary.BigEndian, na.Port)
+}
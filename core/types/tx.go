@@ -185,6 +185,12 @@ func MaxTxPerTxTree(pver uint32) uint64 {
 	return ((MaxBlockPayload / minTxPayload) / 2) + 1
 }
 
+// MaxTxPerBlock returns the maximum number of transactions that could possibly
+// fit into a block for the given protocol version.
+func MaxTxPerBlock(pver uint32) uint64 {
+	return maxTxPerBlock
+}
+
 func (t *Transaction) GetInput() []Input {
 	txIns := make([]Input, len(t.TxIn))
 	for i, txIn := range t.TxIn {
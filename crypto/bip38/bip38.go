@@ -0,0 +1,219 @@
+// Copyright (c) 2017-2018 The qitmeer developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package bip38 implements the passphrase-protected private keys of BIP38,
+// the "6P..." encrypted form of a secp256k1 private key.
+//
+// The BIP38 spec can be found at
+// https://github.com/bitcoin/bips/blob/master/bip-0038.mediawiki
+//
+// The address hash of an encrypted key is over the address of its public key,
+// which depends on the network, so it is computed by an AddressFunc.
+package bip38
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/Qitmeer/qitmeer/common/encode/base58"
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/crypto/ecc"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// flagCompressed is set when the key is for a compressed public key.
+	flagCompressed = 0x20
+
+	// flagNonECMultiply is set on the keys encrypted without EC multiply.
+	flagNonECMultiply = 0xc0
+
+	// flagLotSequence is set on the EC multiplied keys whose owner entropy
+	// includes a lot and sequence number.
+	flagLotSequence = 0x04
+
+	// encryptedKeySize is the size of an encrypted key, prefix included.
+	encryptedKeySize = 39
+)
+
+var (
+	// prefixNonECMultiply and prefixECMultiply are the first two bytes of
+	// the encrypted keys of each mode.
+	prefixNonECMultiply = []byte{0x01, 0x42}
+	prefixECMultiply    = []byte{0x01, 0x43}
+
+	// ErrWrongPassphrase is returned by Decrypt when the passphrase doesn't
+	// decrypt the key to the address it was encrypted for.
+	ErrWrongPassphrase = errors.New("wrong passphrase")
+)
+
+// AddressFunc returns the address of the passed serialized public key, whose
+// hash checks the passphrase.
+type AddressFunc func(pubKey []byte) string
+
+// checksum is the double SHA256 checksum of base58 check encoding.
+var checksum = base58.DoubleHashChecksumFunc(hash.GetHasher(hash.SHA256), 4)
+
+// addressHash returns the first 4 bytes of the double SHA256 of the address of
+// the passed private key.
+func addressHash(privKey []byte, compressed bool, address AddressFunc) []byte {
+	_, pubKey := ecc.Secp256k1.PrivKeyFromBytes(privKey)
+	var serialized []byte
+	if compressed {
+		serialized = pubKey.SerializeCompressed()
+	} else {
+		serialized = pubKey.SerializeUncompressed()
+	}
+	return doubleSHA256([]byte(address(serialized)))[:4]
+}
+
+func doubleSHA256(b []byte) []byte {
+	first := sha256.Sum256(b)
+	second := sha256.Sum256(first[:])
+	return second[:]
+}
+
+func xor(a, b []byte) []byte {
+	result := make([]byte, len(a))
+	for i := range a {
+		result[i] = a[i] ^ b[i]
+	}
+	return result
+}
+
+// Encrypt encrypts the passed 32 bytes private key with the passphrase, in the
+// non-EC-multiply mode, for the compressed or uncompressed public key.
+func Encrypt(privKey []byte, passphrase string, compressed bool, address AddressFunc) (string, error) {
+	if len(privKey) != 32 {
+		return "", fmt.Errorf("private key of %d bytes, should be 32",
+			len(privKey))
+	}
+	salt := addressHash(privKey, compressed, address)
+	derived, err := scrypt.Key([]byte(passphrase), salt, 16384, 8, 8, 64)
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(derived[32:])
+	if err != nil {
+		return "", err
+	}
+	encrypted := make([]byte, 32)
+	block.Encrypt(encrypted[:16], xor(privKey[:16], derived[:16]))
+	block.Encrypt(encrypted[16:], xor(privKey[16:], derived[16:32]))
+
+	flag := byte(flagNonECMultiply)
+	if compressed {
+		flag |= flagCompressed
+	}
+	payload := append([]byte{flag}, salt...)
+	payload = append(payload, encrypted...)
+	return base58.CheckEncode(payload, prefixNonECMultiply, 4, checksum), nil
+}
+
+// Decrypt decrypts the passed encrypted key with the passphrase, in either
+// mode, and returns the private key and whether it is for a compressed public
+// key.  ErrWrongPassphrase is returned when the address hash of the decrypted
+// key doesn't match the encrypted one.
+func Decrypt(encryptedKey string, passphrase string, address AddressFunc) ([]byte, bool, error) {
+	payload, prefix, err := base58.CheckDecode(encryptedKey, 2, 4, checksum)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(payload)+len(prefix) != encryptedKeySize {
+		return nil, false, fmt.Errorf("encrypted key of %d bytes, should "+
+			"be %d", len(payload)+len(prefix), encryptedKeySize)
+	}
+	flag := payload[0]
+	compressed := flag&flagCompressed != 0
+	salt := payload[1:5]
+
+	var privKey []byte
+	switch {
+	case bytes.Equal(prefix, prefixNonECMultiply):
+		privKey, err = decryptNonECMultiply(payload[5:], salt, passphrase)
+	case bytes.Equal(prefix, prefixECMultiply):
+		privKey, err = decryptECMultiply(payload[5:], salt, flag, passphrase)
+	default:
+		return nil, false, fmt.Errorf("unknown encrypted key prefix %x",
+			prefix)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if !bytes.Equal(addressHash(privKey, compressed, address), salt) {
+		return nil, false, ErrWrongPassphrase
+	}
+	return privKey, compressed, nil
+}
+
+// decryptNonECMultiply decrypts the 32 bytes encrypted private key with the
+// passphrase and the address hash.
+func decryptNonECMultiply(encrypted, salt []byte, passphrase string) ([]byte, error) {
+	derived, err := scrypt.Key([]byte(passphrase), salt, 16384, 8, 8, 64)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(derived[32:])
+	if err != nil {
+		return nil, err
+	}
+	privKey := make([]byte, 32)
+	block.Decrypt(privKey[:16], encrypted[:16])
+	block.Decrypt(privKey[16:], encrypted[16:])
+	return xor(privKey, derived[:32]), nil
+}
+
+// decryptECMultiply decrypts the owner entropy and the encrypted parts of an
+// EC multiplied key with the passphrase and the address hash, and multiplies
+// the passphrase factor by the decrypted seed factor.
+func decryptECMultiply(encrypted, salt []byte, flag byte, passphrase string) ([]byte, error) {
+	ownerEntropy := encrypted[:8]
+	encryptedPart1 := encrypted[8:16]
+	encryptedPart2 := encrypted[16:32]
+
+	ownerSalt := ownerEntropy
+	if flag&flagLotSequence != 0 {
+		ownerSalt = ownerEntropy[:4]
+	}
+	passFactor, err := scrypt.Key([]byte(passphrase), ownerSalt, 16384, 8, 8, 32)
+	if err != nil {
+		return nil, err
+	}
+	if flag&flagLotSequence != 0 {
+		passFactor = doubleSHA256(append(passFactor, ownerEntropy...))
+	}
+	x, y := ecc.Secp256k1.ScalarBaseMult(passFactor)
+	passPoint := ecc.Secp256k1.NewPublicKey(x, y).SerializeCompressed()
+
+	derived, err := scrypt.Key(passPoint, append(append([]byte{}, salt...),
+		ownerEntropy...), 1024, 1, 1, 64)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(derived[32:])
+	if err != nil {
+		return nil, err
+	}
+	decrypted2 := make([]byte, 16)
+	block.Decrypt(decrypted2, encryptedPart2)
+	decrypted2 = xor(decrypted2, derived[16:32])
+	decrypted1 := make([]byte, 16)
+	block.Decrypt(decrypted1, append(append([]byte{}, encryptedPart1...),
+		decrypted2[:8]...))
+	decrypted1 = xor(decrypted1, derived[:16])
+	seedB := append(decrypted1, decrypted2[8:]...)
+
+	factorB := new(big.Int).SetBytes(doubleSHA256(seedB))
+	key := new(big.Int).SetBytes(passFactor)
+	key.Mul(key, factorB)
+	key.Mod(key, ecc.Secp256k1.GetN())
+	privKey := make([]byte, 32)
+	keyBytes := key.Bytes()
+	copy(privKey[32-len(keyBytes):], keyBytes)
+	return privKey, nil
+}
@@ -0,0 +1,106 @@
+package bip38
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/Qitmeer/qitmeer/common/encode/base58"
+	"github.com/Qitmeer/qitmeer/common/hash/btc"
+)
+
+// btcAddress returns the bitcoin mainnet address of a public key, which the
+// test vectors of the BIP38 spec are encrypted for.
+func btcAddress(pubKey []byte) string {
+	return base58.BtcCheckEncode(btc.Hash160(pubKey), 0x00)
+}
+
+func TestDecryptVectors(t *testing.T) {
+	tests := []struct {
+		name       string
+		encrypted  string
+		passphrase string
+		key        string
+		compressed bool
+	}{
+		{
+			name:       "no ec multiply, uncompressed",
+			encrypted:  "6PRVWUbkzzsbcVac2qwfssoUJAN1Xhrg6bNk8J7Nzm5H7kxEbn2Nh2ZoGg",
+			passphrase: "TestingOneTwoThree",
+			key:        "cbf4b9f70470856bb4f40f80b87edb90865997ffee6df315ab166d713af433a5",
+		},
+		{
+			name:       "no ec multiply, uncompressed 2",
+			encrypted:  "6PRNFFkZc2NZ6dJqFfhRoFNMR9Lnyj7dYGrzdgXXVMXcxoKTePPX1dWByq",
+			passphrase: "Satoshi",
+			key:        "09c2686880095b1a4c249ee3ac4eea8a014f11e6f986d0b5025ac1f39afbd9ae",
+		},
+		{
+			name:       "no ec multiply, compressed",
+			encrypted:  "6PYNKZ1EAgYgmQfmNVamxyXVWHzK5s6DGhwP4J5o44cvXdoY7sRzhtpUeo",
+			passphrase: "TestingOneTwoThree",
+			key:        "cbf4b9f70470856bb4f40f80b87edb90865997ffee6df315ab166d713af433a5",
+			compressed: true,
+		},
+		{
+			name:       "ec multiply, no lot/sequence",
+			encrypted:  "6PfQu77ygVyJLZjfvMLyhLMQbYnu5uguoJJ4kMCLqWwPEdfpwANVS76gTX",
+			passphrase: "TestingOneTwoThree",
+			key:        "a43a940577f4e97f5c4d39eb14ff083a98187c64ea7c99ef7ce460833959a519",
+		},
+		{
+			name:       "ec multiply, lot/sequence",
+			encrypted:  "6PgNBNNzDkKdhkT6uJntUXwwzQV8Rr2tZcbkDcuC9DZRsS6AtHts4Ypo1j",
+			passphrase: "MOLON LABE",
+			key:        "44ea95afbf138356a05ea32110dfd627232d0f2991ad221187be356f19fa8190",
+		},
+	}
+
+	for _, test := range tests {
+		key, compressed, err := Decrypt(test.encrypted, test.passphrase, btcAddress)
+		if err != nil {
+			t.Errorf("%s: Decrypt: %v", test.name, err)
+			continue
+		}
+		if hex.EncodeToString(key) != test.key || compressed != test.compressed {
+			t.Errorf("%s: got key %x compressed %v, want %s compressed %v",
+				test.name, key, compressed, test.key, test.compressed)
+		}
+	}
+}
+
+func TestEncryptVectors(t *testing.T) {
+	key, _ := hex.DecodeString("cbf4b9f70470856bb4f40f80b87edb90865997ffee6df315ab166d713af433a5")
+	for compressed, want := range map[bool]string{
+		false: "6PRVWUbkzzsbcVac2qwfssoUJAN1Xhrg6bNk8J7Nzm5H7kxEbn2Nh2ZoGg",
+		true:  "6PYNKZ1EAgYgmQfmNVamxyXVWHzK5s6DGhwP4J5o44cvXdoY7sRzhtpUeo",
+	} {
+		encrypted, err := Encrypt(key, "TestingOneTwoThree", compressed, btcAddress)
+		if err != nil {
+			t.Fatalf("Encrypt: %v", err)
+		}
+		if encrypted != want {
+			t.Errorf("compressed %v: got %s, want %s", compressed, encrypted, want)
+		}
+	}
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+	key, _ := hex.DecodeString("09c2686880095b1a4c249ee3ac4eea8a014f11e6f986d0b5025ac1f39afbd9ae")
+	encrypted, err := Encrypt(key, "passphrase", true, btcAddress)
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	decrypted, compressed, err := Decrypt(encrypted, "passphrase", btcAddress)
+	if err != nil {
+		t.Fatalf("Decrypt: %v", err)
+	}
+	if !bytes.Equal(decrypted, key) || !compressed {
+		t.Errorf("got key %x compressed %v, want %x compressed true",
+			decrypted, compressed, key)
+	}
+
+	if _, _, err := Decrypt(encrypted, "wrong", btcAddress); err != ErrWrongPassphrase {
+		t.Errorf("wrong passphrase: got %v, want %v", err, ErrWrongPassphrase)
+	}
+}
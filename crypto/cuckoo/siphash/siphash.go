@@ -256,3 +256,37 @@ func rotl(val uint64, shift uint8) uint64 {
 	num := (val << shift) | (val >> (64 - shift))
 	return num
 }
+
+// Hash returns the SipHash-2-4 of the passed bytes with the key k0, k1, which
+// unlike the cuckoo siphashes of a single nonce takes a message of any length.
+func Hash(k0, k1 uint64, p []byte) uint64 {
+	s := sipHash24{
+		k0 ^ 0x736f6d6570736575,
+		k1 ^ 0x646f72616e646f6d,
+		k0 ^ 0x6c7967656e657261,
+		k1 ^ 0x7465646279746573,
+	}
+	compress := func(m uint64) {
+		s.v3 ^= m
+		s.round(21)
+		s.round(21)
+		s.v0 ^= m
+	}
+	n := len(p)
+	for ; len(p) >= 8; p = p[8:] {
+		compress(binary.LittleEndian.Uint64(p))
+	}
+	// The last block is the remaining bytes with the length in its high
+	// byte.
+	last := uint64(n) << 56
+	for i, b := range p {
+		last |= uint64(b) << (8 * uint(i))
+	}
+	compress(last)
+
+	s.v2 ^= 0xff
+	for i := 0; i < 4; i++ {
+		s.round(21)
+	}
+	return s.digest()
+}
@@ -132,3 +132,23 @@ func BenchmarkSiphash16(b *testing.B) {
 		SiphashPRF8192(&v, &nonce, uorv, &ts)
 	}
 }
+
+// TestHash checks Hash against the test vectors of the SipHash paper, whose key
+// and messages are the bytes 0, 1, 2, ...
+func TestHash(t *testing.T) {
+	k0, k1 := uint64(0x0706050403020100), uint64(0x0f0e0d0c0b0a0908)
+	msg := make([]byte, 15)
+	for i := range msg {
+		msg[i] = byte(i)
+	}
+	tests := map[int]uint64{
+		0:  0x726fdb47dd0e0e31,
+		8:  0x93f5f5799a932462,
+		15: 0xa129ca6149be45e5,
+	}
+	for n, want := range tests {
+		if got := Hash(k0, k1, msg[:n]); got != want {
+			t.Errorf("Hash of %d bytes: got %x, want %x", n, got, want)
+		}
+	}
+}
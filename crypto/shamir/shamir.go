@@ -0,0 +1,154 @@
+// Copyright (c) 2017-2018 The qitmeer developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// Package shamir implements Shamir's secret sharing over GF(2^8), which splits
+// a secret into shares so that any threshold number of them recovers it while
+// fewer reveal nothing about it.
+//
+// Each byte of the secret is the constant term of its own random polynomial of
+// degree threshold-1.  A share is the evaluations of the polynomials at the
+// x coordinate of the share, followed by the x coordinate.
+package shamir
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+const (
+	// MinThreshold is the minimum number of shares needed to recover a
+	// secret, since a single share would be the secret itself.
+	MinThreshold = 2
+
+	// MaxShares is the maximum number of shares of a secret, which is the
+	// number of non-zero x coordinates of the field.
+	MaxShares = 255
+)
+
+var (
+	// expTable and logTable are the powers and the logarithms of the
+	// generator 3 of the multiplicative group of GF(2^8), reduced by the
+	// AES polynomial x^8 + x^4 + x^3 + x + 1.
+	expTable [255]byte
+	logTable [256]byte
+)
+
+func init() {
+	x := byte(1)
+	for i := 0; i < len(expTable); i++ {
+		expTable[i] = x
+		logTable[x] = byte(i)
+
+		// Multiply by the generator 3, which is x * 2 + x.
+		double := x << 1
+		if x&0x80 != 0 {
+			double ^= 0x1b
+		}
+		x ^= double
+	}
+}
+
+// mul multiplies two elements of GF(2^8).
+func mul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return expTable[(int(logTable[a])+int(logTable[b]))%255]
+}
+
+// div divides two elements of GF(2^8), b being non-zero.
+func div(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return expTable[(int(logTable[a])-int(logTable[b])+255)%255]
+}
+
+// evaluate returns the value of the polynomial of the passed coefficients, the
+// constant term first, at x.
+func evaluate(coefficients []byte, x byte) byte {
+	var y byte
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		y = mul(y, x) ^ coefficients[i]
+	}
+	return y
+}
+
+// Split splits the passed secret into the passed number of shares, any
+// threshold of which recover it with Combine.  Each share is one byte longer
+// than the secret.
+func Split(secret []byte, shares, threshold int) ([][]byte, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("cannot split an empty secret")
+	}
+	if threshold < MinThreshold || threshold > shares || shares > MaxShares {
+		return nil, fmt.Errorf("invalid threshold %d of %d shares, "+
+			"should be %d <= threshold <= shares <= %d", threshold,
+			shares, MinThreshold, MaxShares)
+	}
+
+	result := make([][]byte, shares)
+	for i := range result {
+		result[i] = make([]byte, len(secret)+1)
+		result[i][len(secret)] = byte(i + 1)
+	}
+	coefficients := make([]byte, threshold)
+	for i, b := range secret {
+		coefficients[0] = b
+		if _, err := rand.Read(coefficients[1:]); err != nil {
+			return nil, err
+		}
+		for _, share := range result {
+			share[i] = evaluate(coefficients, share[len(secret)])
+		}
+	}
+	return result, nil
+}
+
+// Combine recovers the secret of the passed shares, which must be at least the
+// threshold the secret was split with.  Fewer shares return a wrong secret,
+// which can't be told from the right one without another check.
+func Combine(shares [][]byte) ([]byte, error) {
+	if len(shares) < MinThreshold {
+		return nil, fmt.Errorf("%d shares can't recover a secret, at "+
+			"least %d are needed", len(shares), MinThreshold)
+	}
+	size := len(shares[0])
+	if size < 2 {
+		return nil, errors.New("share too short")
+	}
+	xs := make([]byte, len(shares))
+	seen := make(map[byte]struct{}, len(shares))
+	for i, share := range shares {
+		if len(share) != size {
+			return nil, errors.New("shares of different lengths")
+		}
+		x := share[size-1]
+		if x == 0 {
+			return nil, errors.New("share with a zero x coordinate")
+		}
+		if _, ok := seen[x]; ok {
+			return nil, fmt.Errorf("duplicate share %d", x)
+		}
+		seen[x] = struct{}{}
+		xs[i] = x
+	}
+
+	// Interpolate the polynomials at zero with the Lagrange basis, where
+	// subtracting is adding in GF(2^8).
+	secret := make([]byte, size-1)
+	for i, share := range shares {
+		basis := byte(1)
+		for j, x := range xs {
+			if j != i {
+				basis = mul(basis, div(x, x^xs[i]))
+			}
+		}
+		for k := range secret {
+			secret[k] ^= mul(share[k], basis)
+		}
+	}
+	return secret, nil
+}
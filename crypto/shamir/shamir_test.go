@@ -0,0 +1,76 @@
+package shamir
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestFieldInverse(t *testing.T) {
+	for a := 1; a < 256; a++ {
+		if got := mul(byte(a), div(1, byte(a))); got != 1 {
+			t.Fatalf("%d * 1/%d = %d", a, a, got)
+		}
+	}
+}
+
+// TestSplitCombine ensures any 3 of 5 shares recover the secret, and that 2
+// don't.
+func TestSplitCombine(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	shares, err := Split(secret, 5, 3)
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	if len(shares) != 5 || len(shares[0]) != len(secret)+1 {
+		t.Fatalf("got %d shares of %d bytes", len(shares), len(shares[0]))
+	}
+
+	subsets := [][]int{{0, 1, 2}, {2, 3, 4}, {4, 0, 2}, {1, 3, 4}, {0, 1, 2, 3, 4}}
+	for _, subset := range subsets {
+		var parts [][]byte
+		for _, i := range subset {
+			parts = append(parts, shares[i])
+		}
+		got, err := Combine(parts)
+		if err != nil {
+			t.Fatalf("Combine %v: %v", subset, err)
+		}
+		if !bytes.Equal(got, secret) {
+			t.Errorf("Combine %v: got %x, want %x", subset, got, secret)
+		}
+	}
+
+	got, err := Combine(shares[:2])
+	if err != nil {
+		t.Fatalf("Combine of 2 shares: %v", err)
+	}
+	if bytes.Equal(got, secret) {
+		t.Error("2 shares recovered a secret of threshold 3")
+	}
+}
+
+func TestSplitCombineErrors(t *testing.T) {
+	secret := []byte{0x01, 0x02}
+	for _, test := range []struct{ shares, threshold int }{
+		{5, 1}, {2, 3}, {256, 3},
+	} {
+		if _, err := Split(secret, test.shares, test.threshold); err == nil {
+			t.Errorf("Split %d of %d accepted", test.threshold,
+				test.shares)
+		}
+	}
+	if _, err := Split(nil, 5, 3); err == nil {
+		t.Error("empty secret split")
+	}
+
+	shares, _ := Split(secret, 3, 2)
+	if _, err := Combine([][]byte{shares[0], shares[0]}); err == nil {
+		t.Error("duplicate shares combined")
+	}
+	if _, err := Combine([][]byte{shares[0], shares[1][1:]}); err == nil {
+		t.Error("shares of different lengths combined")
+	}
+	if _, err := Combine(shares[:1]); err == nil {
+		t.Error("single share combined")
+	}
+}
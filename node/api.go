@@ -6,11 +6,14 @@
 package node
 
 import (
+	"context"
 	"fmt"
+	"github.com/Qitmeer/qitmeer/common/marshal"
 	"github.com/Qitmeer/qitmeer/core/blockdag"
 	"github.com/Qitmeer/qitmeer/core/json"
 	"github.com/Qitmeer/qitmeer/core/message"
 	"github.com/Qitmeer/qitmeer/core/protocol"
+	"github.com/Qitmeer/qitmeer/core/types"
 	"github.com/Qitmeer/qitmeer/core/types/pow"
 	"github.com/Qitmeer/qitmeer/params"
 	"github.com/Qitmeer/qitmeer/rpc"
@@ -154,6 +157,26 @@ func (api *PublicBlockChainAPI) GetRpcInfo() (interface{}, error) {
 	return jrs, nil
 }
 
+// NewMempoolTransactions pushes a notification to the subscriber whenever a
+// transaction is accepted to the mempool, with the decoded transaction when
+// verbose.  Subscriptions are only supported over websockets.
+func (api *PublicBlockChainAPI) NewMempoolTransactions(ctx context.Context, verbose *bool) (*rpc.Subscription, error) {
+	decoded := verbose != nil && *verbose
+	chainParams := api.node.node.Params
+	return api.node.node.rpcServer.SubscribeMempoolTxs(ctx, func(tx *types.Tx) (interface{}, error) {
+		ntfn := &json.MempoolTxNotification{Txid: tx.Hash().String()}
+		if decoded {
+			txr, err := marshal.NewTxRawResult(tx, chainParams, "", 0, 0)
+			if err != nil {
+				return nil, err
+			}
+			txr.InMempool = true
+			ntfn.Tx = txr
+		}
+		return ntfn, nil
+	})
+}
+
 func getGraphStateResult(gs *blockdag.GraphState) *json.GetGraphStateResult {
 	if gs != nil {
 		mainTip := gs.GetMainChainTip()
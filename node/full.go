@@ -140,6 +140,7 @@ func newQitmeerFullNode(node *Node) (*QitmeerFull, error) {
 	}
 	qm.txManager = tm
 	bm.GetChain().SetTxManager(tm)
+	bm.SetFeeEstimator(tm.FeeEstimator())
 	// prepare peerServer
 	node.peerServer.BlockManager = bm
 	node.peerServer.TimeSource = qm.timeSource
@@ -157,6 +158,22 @@ func newQitmeerFullNode(node *Node) (*QitmeerFull, error) {
 		StandardVerifyFlags: func() (txscript.ScriptFlags, error) {
 			return common.StandardScriptVerifyFlags()
 		}, //TODO, duplicated config item with mem-pool
+		SigOpCache:                  mining.NewSigOpCache(),
+		DifficultyCache:             mining.NewDifficultyCache(mining.DefaultDifficultyCacheBucket),
+		FeeEstimator:                tm.FeeEstimator(),
+		IncludePrevOuts:             cfg.TemplatePrevOuts,
+		MaxBlockSigOps:              cfg.BlockMaxSigOps,
+		RejectNonStandard:           cfg.BlockRejectNonStd,
+		OmitCoinbaseOpReturn:        cfg.NoCoinbaseOpReturn,
+		RegtestMode:                 cfg.RegtestMode,
+		MaxBlockParents:             cfg.BlockMaxParents,
+		MaxPackageSize:              cfg.BlockMaxPackageSize,
+		CoinbaseExtraNonceSize:      cfg.ExtraNonceSize,
+		VerifyTemplateSerialization: cfg.VerifyTemplates,
+	}
+	if cfg.CoinbaseReuseWindow > 0 {
+		policy.CoinbaseReuse = mining.NewCoinbaseReuseTracker(
+			cfg.CoinbaseReuseWindow, cfg.RejectCoinbaseReuse)
 	}
 	// defaultNumWorkers is the default number of workers to use for mining
 	// and is based on the number of processor cores.  This helps ensure the
@@ -25,6 +25,11 @@ type MessageListeners struct {
 	// OnAddr is invoked when a peer receives an addr wire message.
 	OnAddr func(p *Peer, msg *message.MsgAddr)
 
+	// OnAddrV2 is invoked when a peer receives an addrv2 wire message,
+	// which is only decoded from peers of the AddrV2Version protocol
+	// version or later.
+	OnAddrV2 func(p *Peer, msg *message.MsgAddrV2)
+
 	// OnPing is invoked when a peer receives a ping wire message.
 	OnPing func(p *Peer, msg *message.MsgPing)
 
@@ -101,6 +106,10 @@ type MessageListeners struct {
 
 	// OnSyncPoint
 	OnSyncPoint func(p *Peer, msg *message.MsgSyncPoint)
+
+	// OnGetCmpctBlock is invoked when a peer receives a getcmpctblk wire
+	// message.
+	OnGetCmpctBlock func(p *Peer, msg *message.MsgGetCmpctBlock)
 	/*
 		// OnSendHeaders is invoked when a peer receives a sendheaders message.
 		OnSendHeaders func(p *Peer, msg *message.MsgSendHeaders)
@@ -258,9 +258,10 @@ func (p *Peer) VerAckReceived() bool {
 }
 
 // PushRejectMsg sends a reject message for the provided command, reject code,
-// reject reason, and hash.  The hash will only be used when the command is a tx
-// or block and should be nil in other cases.  The wait parameter will cause the
-// function to block until the reject message has actually been sent.
+// reject reason, and hash.  The hash must be set when the command is a tx or
+// block, and is optional in other cases, where it's only sent to peers of
+// protocol version RejectHashVersion or later.  The wait parameter will cause
+// the function to block until the reject message has actually been sent.
 //
 // This function is safe for concurrent access.
 func (p *Peer) PushRejectMsg(command string, code message.RejectCode, reason string, h *hash.Hash, wait bool) {
@@ -272,6 +273,8 @@ func (p *Peer) PushRejectMsg(command string, code message.RejectCode, reason str
 				"but does not", command)
 			h = &hash.ZeroHash
 		}
+	}
+	if h != nil {
 		msg.Hash = *h
 	}
 
@@ -91,6 +91,11 @@ out:
 				p.cfg.Listeners.OnAddr(p, msg)
 			}
 
+		case *message.MsgAddrV2:
+			if p.cfg.Listeners.OnAddrV2 != nil {
+				p.cfg.Listeners.OnAddrV2(p, msg)
+			}
+
 		case *message.MsgPing:
 			p.handlePingMsg(msg)
 			if p.cfg.Listeners.OnPing != nil {
@@ -172,6 +177,11 @@ out:
 			if p.cfg.Listeners.OnSyncPoint != nil {
 				p.cfg.Listeners.OnSyncPoint(p, msg)
 			}
+
+		case *message.MsgGetCmpctBlock:
+			if p.cfg.Listeners.OnGetCmpctBlock != nil {
+				p.cfg.Listeners.OnGetCmpctBlock(p, msg)
+			}
 		/*
 			case *message.MsgHeaders:
 				if p.cfg.Listeners.OnHeaders != nil {
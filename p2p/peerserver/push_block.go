@@ -3,6 +3,7 @@ package peerserver
 import (
 	"github.com/Qitmeer/qitmeer/common/hash"
 	"github.com/Qitmeer/qitmeer/core/message"
+	"github.com/Qitmeer/qitmeer/core/serialization"
 	"github.com/Qitmeer/qitmeer/log"
 )
 
@@ -32,3 +33,21 @@ func (s *PeerServer) pushBlockMsg(sp *serverPeer, hash *hash.Hash, doneChan chan
 
 	return nil
 }
+
+// pushCmpctBlockMsg sends a compact block message for the provided block hash
+// to the connected peer, with a random nonce keying its short ids.  An error is
+// returned if the block hash is not known.
+func (s *PeerServer) pushCmpctBlockMsg(sp *serverPeer, hash *hash.Hash) error {
+	block, err := sp.server.BlockManager.GetChain().FetchBlockByHash(hash)
+	if err != nil {
+		log.Trace("Unable to fetch requested block hash", "hash", hash,
+			"error", err)
+		return err
+	}
+	nonce, err := serialization.RandomUint64()
+	if err != nil {
+		return err
+	}
+	sp.QueueMessage(message.NewMsgCmpctBlock(block.Block(), nonce), nil)
+	return nil
+}
@@ -189,10 +189,33 @@ func (sp *serverPeer) OnAddr(p *peer.Peer, msg *message.MsgAddr) {
 	sp.server.addrManager.AddAddresses(msg.AddrList, p.NA())
 }
 
+// OnAddrV2 is invoked when a peer receives an addrv2 wire message.  The
+// address manager only knows IP addresses, so those of other types are
+// ignored and the IP ones are handled as an addr message.
+func (sp *serverPeer) OnAddrV2(p *peer.Peer, msg *message.MsgAddrV2) {
+	addrMsg := message.NewMsgAddr()
+	for _, na := range msg.AddrList {
+		ip := na.IP()
+		if ip == nil {
+			continue
+		}
+		addrMsg.AddAddress(types.NewNetAddressTimestamp(na.Timestamp,
+			na.Services, ip, na.Port))
+	}
+	if len(msg.AddrList) != 0 && len(addrMsg.AddrList) == 0 {
+		return
+	}
+	sp.OnAddr(p, addrMsg)
+}
+
 // OnRead is invoked when a peer receives a message and it is used to update
-// the bytes received by the server.
+// the bytes received by the server, and to increase the ban score of a peer
+// sending corrupted messages.
 func (sp *serverPeer) OnRead(p *peer.Peer, bytesRead int, msg message.Message, err error) {
 	sp.server.AddBytesReceived(uint64(bytesRead))
+	if err == message.ErrChecksum {
+		sp.addBanScore(0, connmgr.SeriousScore, "checksum")
+	}
 }
 
 // OnWrite is invoked when a peer sends a message and it is used to update
@@ -523,3 +546,20 @@ func (sp *serverPeer) OnMemPool(_ *peer.Peer, msg *message.MsgMemPool) {
 		sp.QueueMessage(invMsg, nil)
 	}
 }
+
+// OnGetCmpctBlock is invoked when a peer receives a getcmpctblk wire message.
+// It responds with the compact block of the requested block, or a notfound
+// message when the block is unknown.
+func (sp *serverPeer) OnGetCmpctBlock(p *peer.Peer, msg *message.MsgGetCmpctBlock) {
+	if msg.Version == 0 || msg.Version > message.CmpctBlockVersion {
+		log.Debug(fmt.Sprintf("Ignoring getcmpctblk of unsupported version "+
+			"%d from %v", msg.Version, p))
+		return
+	}
+	err := sp.server.pushCmpctBlockMsg(sp, &msg.Hash)
+	if err != nil {
+		notFound := message.NewMsgNotFound()
+		notFound.AddInvVect(message.NewInvVect(message.InvTypeBlock, &msg.Hash))
+		p.QueueMessage(notFound, nil)
+	}
+}
@@ -156,6 +156,7 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 			OnVersion:        sp.OnVersion,
 			OnGetAddr:        sp.OnGetAddr,
 			OnAddr:           sp.OnAddr,
+			OnAddrV2:         sp.OnAddrV2,
 			OnRead:           sp.OnRead,
 			OnWrite:          sp.OnWrite,
 			OnGetBlocks:      sp.OnGetBlocks,
@@ -171,6 +172,7 @@ func newPeerConfig(sp *serverPeer) *peer.Config {
 			OnSyncResult:     sp.OnSyncResult,
 			OnSyncDAG:        sp.OnSyncDAG,
 			OnSyncPoint:      sp.OnSyncPoint,
+			OnGetCmpctBlock:  sp.OnGetCmpctBlock,
 			//OnHeaders:        sp.OnHeaders,
 			//OnGetCFilter:     sp.OnGetCFilter,
 			//OnGetCFHeaders:   sp.OnGetCFHeaders,
@@ -98,6 +98,10 @@ type Params struct {
 	// GenesisHash is the starting block hash.
 	GenesisHash *hash.Hash
 
+	// DefaultPowType is the proof of work algorithm of the block templates
+	// generated when no algorithm is requested.
+	DefaultPowType pow.PowType
+
 	// PowConfig defines the highest allowed proof of work value for a block or lowest difficulty for a block
 	PowConfig *pow.PowConfig
 
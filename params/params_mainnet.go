@@ -33,8 +33,9 @@ var MainNetParams = Params{
 	},
 
 	// Chain parameters
-	GenesisBlock: &genesisBlock,
-	GenesisHash:  &genesisHash,
+	GenesisBlock:   &genesisBlock,
+	GenesisHash:    &genesisHash,
+	DefaultPowType: pow.CUCKAROO,
 	PowConfig: &pow.PowConfig{
 		Blake2bdPowLimit:             mainPowLimit,
 		Blake2bdPowLimitBits:         0x1d00ffff,
@@ -39,6 +39,7 @@ var MixNetParams = Params{
 	ReduceMinDifficulty:  false,
 	MinDiffReductionTime: 0, // Does not apply since ReduceMinDifficulty false
 	GenerateSupported:    true,
+	DefaultPowType:       pow.CUCKAROO,
 	PowConfig: &pow.PowConfig{
 		Blake2bdPowLimit:             testMixNetPowLimit,
 		Blake2bdPowLimitBits:         0x1e00ffff,
@@ -35,8 +35,9 @@ var PrivNetParams = Params{
 	DNSSeeds:    []DNSSeed{}, // NOTE: There must NOT be any seeds.
 
 	// Chain parameters
-	GenesisBlock: &privNetGenesisBlock,
-	GenesisHash:  &privNetGenesisHash,
+	GenesisBlock:   &privNetGenesisBlock,
+	GenesisHash:    &privNetGenesisHash,
+	DefaultPowType: pow.CUCKAROOM,
 	PowConfig: &pow.PowConfig{
 		Blake2bdPowLimit:             privNetPowLimit,
 		Blake2bdPowLimitBits:         0x207fffff,
@@ -36,8 +36,9 @@ var TestNetParams = Params{
 	},
 
 	// Chain parameters
-	GenesisBlock: &testNetGenesisBlock,
-	GenesisHash:  &testNetGenesisHash,
+	GenesisBlock:   &testNetGenesisBlock,
+	GenesisHash:    &testNetGenesisHash,
+	DefaultPowType: pow.CUCKAROOM,
 	PowConfig: &pow.PowConfig{
 		Blake2bdPowLimit:             testNetPowLimit,
 		Blake2bdPowLimitBits:         0x1b7fffff, // compact from of testNetPowLimit (2^215-1)
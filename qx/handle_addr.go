@@ -1,11 +1,15 @@
 package qx
 
 import (
+	"bytes"
 	"encoding/hex"
 	"fmt"
 	"github.com/Qitmeer/qitmeer/common/encode/base58"
 	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/address"
+	"github.com/Qitmeer/qitmeer/engine/txscript"
 	"github.com/Qitmeer/qitmeer/params"
+	"sort"
 )
 
 func EcPubKeyToAddress(version string, pubkey string) (string, error) {
@@ -78,3 +82,79 @@ func EcPubKeyToAddressSTDO(version []byte, pubkey string) {
 	address := base58.QitmeerCheckEncode(h, version[:])
 	fmt.Printf("%s\n", address)
 }
+
+// MultisigAddress builds the m-of-n multisig redeem script of the passed hex
+// public keys, and returns the P2SH address of the script on the network with
+// the hex script, which the signers need to spend from the address.  When
+// bip67 is set, the keys are sorted as in BIP67, which requires them to be
+// compressed, so that the same keys always give the same address.
+func MultisigAddress(network string, m int, pubkeys []string, bip67 bool) (string, string, error) {
+	var param *params.Params
+	switch network {
+	case "mainnet":
+		param = &params.MainNetParams
+	case "testnet":
+		param = &params.TestNetParams
+	case "privnet":
+		param = &params.PrivNetParams
+	case "mixnet":
+		param = &params.MixNetParams
+	default:
+		return "", "", fmt.Errorf("unknown network %s", network)
+	}
+	n := len(pubkeys)
+	if m < 1 || m > n || n > txscript.MaxPubKeysPerMultiSig {
+		return "", "", fmt.Errorf("invalid %d-of-%d multisig, should be "+
+			"1 <= m <= n <= %d", m, n, txscript.MaxPubKeysPerMultiSig)
+	}
+
+	keys := make([][]byte, n)
+	for i, pubkey := range pubkeys {
+		data, err := hex.DecodeString(pubkey)
+		if err != nil {
+			return "", "", err
+		}
+		if bip67 && len(data) != 33 {
+			return "", "", fmt.Errorf("public key %s is not compressed, "+
+				"which BIP67 sorting requires", pubkey)
+		}
+		keys[i] = data
+	}
+	if bip67 {
+		sortPubKeys(keys)
+	}
+	addrs := make([]*address.SecpPubKeyAddress, n)
+	for i, key := range keys {
+		addr, err := address.NewSecpPubKeyAddress(key, param)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid public key %x : %v", key, err)
+		}
+		addrs[i] = addr
+	}
+
+	script, err := txscript.MultiSigScript(addrs, m)
+	if err != nil {
+		return "", "", err
+	}
+	addr, err := address.NewAddressScriptHashFromHash(hash.Hash160(script), param)
+	if err != nil {
+		return "", "", err
+	}
+	return addr.Encode(), hex.EncodeToString(script), nil
+}
+
+// sortPubKeys sorts the passed serialized public keys in lexicographic order,
+// as in BIP67.
+func sortPubKeys(keys [][]byte) {
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i], keys[j]) < 0
+	})
+}
+
+func MultisigAddressSTDO(network string, m int, pubkeys []string, bip67 bool) {
+	addr, script, err := MultisigAddress(network, m, pubkeys, bip67)
+	if err != nil {
+		ErrExit(err)
+	}
+	fmt.Printf("%s\n%s\n", addr, script)
+}
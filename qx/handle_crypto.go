@@ -1,16 +1,23 @@
 package qx
 
 import (
+	"bytes"
+	"crypto/rand"
 	"encoding/hex"
 	"fmt"
 	"github.com/Qitmeer/qitmeer/common/encode/base58"
 	"github.com/Qitmeer/qitmeer/common/hash"
 	"github.com/Qitmeer/qitmeer/crypto/bip32"
+	"github.com/Qitmeer/qitmeer/crypto/bip38"
 	"github.com/Qitmeer/qitmeer/crypto/bip39"
 	"github.com/Qitmeer/qitmeer/crypto/ecc"
 	"github.com/Qitmeer/qitmeer/crypto/seed"
+	"github.com/Qitmeer/qitmeer/crypto/shamir"
+	"github.com/Qitmeer/qitmeer/params"
 	"github.com/Qitmeer/qitmeer/wallet"
+	"math/big"
 	"strconv"
+	"strings"
 )
 
 func NewEntropy(size uint) (string, error) {
@@ -21,21 +28,37 @@ func NewEntropy(size uint) (string, error) {
 	return fmt.Sprintf("%x", s), nil
 }
 
+// SupportedCurves lists the curves EcNew can derive a private key on.  The
+// key is the BIP32 master key of the entropy, which is only defined on
+// secp256k1.
+var SupportedCurves = []string{"secp256k1"}
+
+// EcNew returns the hex private key derived from the passed hex entropy on the
+// passed curve, which must be one of SupportedCurves.  The entropy is a BIP32
+// seed, from 16 to 256 bytes long.
 func EcNew(curve string, entropyStr string) (string, error) {
+	supported := false
+	for _, c := range SupportedCurves {
+		supported = supported || c == curve
+	}
+	if !supported {
+		return "", fmt.Errorf("unknown curve : %s, supported curves : %s",
+			curve, strings.Join(SupportedCurves, ", "))
+	}
 	entropy, err := hex.DecodeString(entropyStr)
 	if err != nil {
 		return "", err
 	}
-	switch curve {
-	case "secp256k1":
-		masterKey, err := bip32.NewMasterKey(entropy)
-		if err != nil {
-			return "", err
-		}
-		return fmt.Sprintf("%x", masterKey.Key[:]), nil
-	default:
-		return "", fmt.Errorf("unknown curve : %s", curve)
+	if len(entropy) < seed.MinSeedBytes || len(entropy) > seed.MaxSeedBytes {
+		return "", fmt.Errorf("entropy of %d bytes for %s, should be "+
+			"from %d to %d bytes", len(entropy), curve, seed.MinSeedBytes,
+			seed.MaxSeedBytes)
 	}
+	masterKey, err := bip32.NewMasterKey(entropy)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", masterKey.Key[:]), nil
 }
 
 func EcPrivateKeyToEcPublicKey(uncompressed bool, privateKeyStr string) (string, error) {
@@ -179,6 +202,73 @@ func HdDerive(hard bool, index uint32, path wallet.DerivationPath, version bip32
 	fmt.Printf("%s\n", childKey)
 }
 
+// hdNetworkParams returns the params and the HD (BIP32) version of the passed
+// network.
+func hdNetworkParams(network string) (*params.Params, bip32.Bip32Version, error) {
+	switch network {
+	case "mainnet":
+		return &params.MainNetParams, QitmeerMainnetBip32Version, nil
+	case "testnet":
+		return &params.TestNetParams, QitmeerTestnetBip32Version, nil
+	case "privnet":
+		return &params.PrivNetParams, QitmeerPrivnetBip32Version, nil
+	case "mixnet":
+		return &params.MixNetParams, QitmeerMixnetBip32Version, nil
+	default:
+		return nil, bip32.Bip32Version{}, fmt.Errorf("unknown network %s", network)
+	}
+}
+
+// HdAccountPublicKey derives the BIP44 account key m/44'/coin'/account' of the
+// passed HD master private key, the coin type being the one of the network,
+// and returns its public key, from which the addresses of the account can be
+// derived without the private keys.
+func HdAccountPublicKey(network string, account uint32, masterKeyStr string) (string, error) {
+	param, version, err := hdNetworkParams(network)
+	if err != nil {
+		return "", err
+	}
+	if account >= bip32.FirstHardenedChild {
+		return "", fmt.Errorf("invalid account index %d", account)
+	}
+	data := base58.Decode(masterKeyStr)
+	if len(data) != bip32_ByteSize {
+		return "", fmt.Errorf("invalid bip32 key size (%d), the size hould be %d", len(data), bip32_ByteSize)
+	}
+	masterKey, err := bip32.Deserialize2(data, version)
+	if err != nil {
+		return "", err
+	}
+	path := wallet.DerivationPath{
+		bip32.FirstHardenedChild + 44,
+		bip32.FirstHardenedChild + param.HDCoinType,
+		bip32.FirstHardenedChild + account,
+	}
+	// The hardened derivations need the private key.
+	if !masterKey.IsPrivate {
+		return "", fmt.Errorf("the account path %s is hardened, which can't be derived from the HD public key %s", path, masterKeyStr)
+	}
+	if masterKey.Depth != 0 {
+		return "", fmt.Errorf("%s is not a HD master key, its depth is %d", masterKeyStr, masterKey.Depth)
+	}
+	accountKey := masterKey
+	for _, i := range path {
+		accountKey, err = accountKey.NewChildKey(i)
+		if err != nil {
+			return "", err
+		}
+	}
+	return accountKey.PublicKey().String(), nil
+}
+
+func HdAccountPublicKeySTDO(network string, account uint32, masterKeyStr string) {
+	key, err := HdAccountPublicKey(network, account, masterKeyStr)
+	if err != nil {
+		ErrExit(err)
+	}
+	fmt.Printf("%s\n", key)
+}
+
 func MnemonicNew(entropyStr string) {
 	entropy, err := hex.DecodeString(entropyStr)
 	if err != nil {
@@ -191,6 +281,96 @@ func MnemonicNew(entropyStr string) {
 	fmt.Printf("%s\n", mnemonic)
 }
 
+// entropySplitIDSize is the size of the random identifier of a split
+// prefixed to its entropy shares.
+const entropySplitIDSize = 4
+
+// checksummedEntropy returns the passed entropy followed by the checksum of
+// its BIP39 mnemonic, as the mnemonic words encode it.
+func checksummedEntropy(entropy []byte) ([]byte, error) {
+	mnemonic, err := bip39.NewMnemonic(entropy)
+	if err != nil {
+		return nil, err
+	}
+	return bip39.MnemonicToByteArray(mnemonic)
+}
+
+// EntropySplit splits the passed hex entropy into the passed number of hex
+// shares with Shamir's secret sharing, any threshold of which recover it with
+// EntropyCombine.  The secret which is split is the entropy along with the
+// checksum of its BIP39 mnemonic, so that a recovered entropy can be checked,
+// and each share starts with a random identifier of the split so that the
+// shares of different splits don't mix.
+func EntropySplit(entropyStr string, shares, threshold int) ([]string, error) {
+	entropy, err := hex.DecodeString(entropyStr)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := checksummedEntropy(entropy)
+	if err != nil {
+		return nil, err
+	}
+	parts, err := shamir.Split(secret, shares, threshold)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, entropySplitIDSize)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	result := make([]string, len(parts))
+	for i, part := range parts {
+		result[i] = fmt.Sprintf("%x%x", id, part)
+	}
+	return result, nil
+}
+
+// EntropyCombine recovers the hex entropy of the passed hex shares made by
+// EntropySplit, and ensures it matches the checksum of its BIP39 mnemonic.
+// The checksum is 4 to 8 bits long depending on the entropy size, so fewer
+// shares than the threshold are detected with the corresponding probability.
+func EntropyCombine(shareStrs []string) (string, error) {
+	var id []byte
+	parts := make([][]byte, len(shareStrs))
+	for i, s := range shareStrs {
+		share, err := hex.DecodeString(s)
+		if err != nil {
+			return "", err
+		}
+		if len(share) <= entropySplitIDSize+1 {
+			return "", fmt.Errorf("share %d is too short", i)
+		}
+		if id == nil {
+			id = share[:entropySplitIDSize]
+		} else if !bytes.Equal(id, share[:entropySplitIDSize]) {
+			return "", fmt.Errorf("share %d is of another split", i)
+		}
+		parts[i] = share[entropySplitIDSize:]
+	}
+	secret, err := shamir.Combine(parts)
+	if err != nil {
+		return "", err
+	}
+
+	// The secret is the entropy shifted left by the checksum bits, one
+	// per 4 bytes of entropy, followed by the checksum.
+	entropySize := len(secret) - 1
+	checksumBits := uint(entropySize / 4)
+	entropy := new(big.Int).Rsh(new(big.Int).SetBytes(secret), checksumBits).Bytes()
+	if len(entropy) > entropySize {
+		return "", fmt.Errorf("the recovered entropy is invalid, the " +
+			"shares are fewer than the threshold or corrupted")
+	}
+	entropy = append(make([]byte, entropySize-len(entropy)), entropy...)
+	checksummed, err := checksummedEntropy(entropy)
+	if err != nil || !bytes.Equal(checksummed, secret) {
+		return "", fmt.Errorf("the recovered entropy doesn't match its " +
+			"mnemonic checksum, the shares are fewer than the threshold " +
+			"or corrupted")
+	}
+	return fmt.Sprintf("%x", entropy), nil
+}
+
 func MnemonicToEntropy(mnemonicStr string) {
 	entropy, err := bip39.EntropyFromMnemonic(mnemonicStr)
 	if err != nil {
@@ -224,10 +404,30 @@ func EcPrivateKeyToEcPublicKeySTDO(uncompressed bool, privateKeyStr string) {
 }
 
 func EcPrivateKeyToWif(uncompressed bool, privateKeyStr string) {
-	data, err := hex.DecodeString(privateKeyStr)
+	encoded, err := EncodeWIF(uncompressed, privateKeyStr, nil)
 	if err != nil {
 		ErrExit(err)
 	}
+	fmt.Printf("%s\n", encoded)
+}
+
+// wifChecksumFunc returns the passed WIF checksum function, or the default
+// double SHA256 one when it is nil.  The checksum is 4 bytes long.
+func wifChecksumFunc(cksumfunc func([]byte) []byte) func([]byte) []byte {
+	if cksumfunc == nil {
+		return base58.DoubleHashChecksumFunc(hash.GetHasher(hash.SHA256), 4)
+	}
+	return cksumfunc
+}
+
+// EncodeWIF encodes the passed hex private key to the wallet import format,
+// with the passed 4 bytes checksum function, or the double SHA256 one when it
+// is nil.  DecodeWIF must be passed the same function.
+func EncodeWIF(uncompressed bool, privateKeyStr string, cksumfunc func([]byte) []byte) (string, error) {
+	data, err := hex.DecodeString(privateKeyStr)
+	if err != nil {
+		return "", err
+	}
 	privkey, _ := ecc.Secp256k1.PrivKeyFromBytes(data)
 	var key []byte
 	if uncompressed {
@@ -236,22 +436,22 @@ func EcPrivateKeyToWif(uncompressed bool, privateKeyStr string) {
 		key = privkey.Serialize()
 		key = append(key, []byte{0x01}...)
 	}
-	cksumfunc := base58.DoubleHashChecksumFunc(hash.GetHasher(hash.SHA256), 4)
-	encoded := base58.CheckEncode(key, []byte{0x80}, 4, cksumfunc)
-	fmt.Printf("%s\n", encoded)
+	return base58.CheckEncode(key, []byte{0x80}, 4, wifChecksumFunc(cksumfunc)), nil
 }
 
 func WifToEcPrivateKey(wif string) {
-	decoded, _, err := DecodeWIF(wif)
+	decoded, _, err := DecodeWIF(wif, nil)
 	if err != nil {
 		ErrExit(err)
 	}
 	fmt.Printf("%x\n", decoded)
 }
 
-func DecodeWIF(wif string) ([]byte, bool, error) {
-	cksumfunc := base58.DoubleHashChecksumFunc(hash.GetHasher(hash.SHA256), 4)
-	decoded, version, err := base58.CheckDecode(wif, 1, 4, cksumfunc)
+// DecodeWIF decodes the passed wallet import format private key, checked with
+// the passed 4 bytes checksum function, or the double SHA256 one when it is
+// nil, and returns whether it is for a compressed public key.
+func DecodeWIF(wif string, cksumfunc func([]byte) []byte) ([]byte, bool, error) {
+	decoded, version, err := base58.CheckDecode(wif, 1, 4, wifChecksumFunc(cksumfunc))
 	compressed := false
 	if err != nil {
 		return nil, compressed, err
@@ -270,7 +470,7 @@ func DecodeWIF(wif string) ([]byte, bool, error) {
 }
 
 func WifToEcPubkey(uncompressed bool, wif string) {
-	decoded, _, err := DecodeWIF(wif)
+	decoded, _, err := DecodeWIF(wif, nil)
 	if err != nil {
 		ErrExit(err)
 	}
@@ -283,3 +483,60 @@ func WifToEcPubkey(uncompressed bool, wif string) {
 	}
 	fmt.Printf("%x\n", key[:])
 }
+
+// bip38Address returns the function computing the address a BIP38 key is
+// encrypted for on the passed network, which is a network name or a hex
+// address version.
+func bip38Address(network string) (bip38.AddressFunc, error) {
+	if _, err := EcPubKeyToAddress(network, ""); err != nil {
+		return nil, err
+	}
+	return func(pubKey []byte) string {
+		addr, _ := EcPubKeyToAddress(network, hex.EncodeToString(pubKey))
+		return addr
+	}, nil
+}
+
+// EcPrivateKeyToBip38 encrypts the passed hex private key with the passphrase
+// to a BIP38 key, for its address on the passed network.
+func EcPrivateKeyToBip38(network string, uncompressed bool, privateKeyStr string, passphrase string) (string, error) {
+	data, err := hex.DecodeString(privateKeyStr)
+	if err != nil {
+		return "", err
+	}
+	address, err := bip38Address(network)
+	if err != nil {
+		return "", err
+	}
+	return bip38.Encrypt(data, passphrase, !uncompressed, address)
+}
+
+// Bip38ToEcPrivateKey decrypts the passed BIP38 key with the passphrase to a
+// hex private key, and returns whether it is for a compressed public key.
+func Bip38ToEcPrivateKey(network string, encrypted string, passphrase string) (string, bool, error) {
+	address, err := bip38Address(network)
+	if err != nil {
+		return "", false, err
+	}
+	key, compressed, err := bip38.Decrypt(encrypted, passphrase, address)
+	if err != nil {
+		return "", false, err
+	}
+	return hex.EncodeToString(key), compressed, nil
+}
+
+func EcPrivateKeyToBip38STDO(network string, uncompressed bool, privateKeyStr string, passphrase string) {
+	encrypted, err := EcPrivateKeyToBip38(network, uncompressed, privateKeyStr, passphrase)
+	if err != nil {
+		ErrExit(err)
+	}
+	fmt.Printf("%s\n", encrypted)
+}
+
+func Bip38ToEcPrivateKeySTDO(network string, encrypted string, passphrase string) {
+	key, _, err := Bip38ToEcPrivateKey(network, encrypted, passphrase)
+	if err != nil {
+		ErrExit(err)
+	}
+	fmt.Printf("%s\n", key)
+}
@@ -3,6 +3,7 @@ package qx
 import (
 	"bytes"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"github.com/Qitmeer/qitmeer/common/encode/base58"
 	"github.com/Qitmeer/qitmeer/common/hash"
@@ -41,17 +42,31 @@ func DecodeAddr(mode string, addrStr string) ([]byte, error) {
 	}
 }
 func VerifyMsgSignature(mode string, addrStr string, signStr string, msgStr string) {
+	ok, err := verifyMessage(mode, addrStr, signStr, msgStr)
+	if err != nil {
+		ErrExit(err)
+	}
+	fmt.Printf("%v\n", ok)
+}
 
+// VerifyMessage returns whether the passed base64 compact signature of the
+// message, prefixed with the qitmeer message magic, is signed by the key of
+// the passed address.
+func VerifyMessage(addrStr string, signStr string, msgStr string) (bool, error) {
+	return verifyMessage("qx", addrStr, signStr, msgStr)
+}
+
+func verifyMessage(mode string, addrStr string, signStr string, msgStr string) (bool, error) {
 	msgHash := BuildMsgHash(mode, msgStr)
 
 	addrHash160, err := DecodeAddr(mode, addrStr)
 	if err != nil {
-		ErrExit(err)
+		return false, err
 	}
 
 	sign_c, err := base64.StdEncoding.DecodeString(signStr)
 	if err != nil {
-		ErrExit(err)
+		return false, err
 	}
 
 	// the recovery mode of btc
@@ -63,7 +78,7 @@ func VerifyMsgSignature(mode string, addrStr string, signStr string, msgStr stri
 	// 31 + recId (compressed pubkey)
 	pubKey, compressed, err := ecc.Secp256k1.RecoverCompact(sign_c, msgHash)
 	if err != nil {
-		ErrExit(err)
+		return false, err
 	}
 
 	var data []byte
@@ -73,7 +88,7 @@ func VerifyMsgSignature(mode string, addrStr string, signStr string, msgStr stri
 		data = pubKey.SerializeUncompressed()
 	}
 
-	fmt.Printf("%v\n", reflect.DeepEqual(CalcHash160(mode, data), addrHash160))
+	return reflect.DeepEqual(CalcHash160(mode, data), addrHash160), nil
 }
 
 func CalcHash160(mode string, data []byte) []byte {
@@ -104,7 +119,7 @@ func BuildMsgHash(mode string, msg string) []byte {
 }
 
 func MsgSign(mode string, showSignDetail bool, wif string, msg string, showDetails bool) {
-	decoded, compressed, err := DecodeWIF(wif)
+	decoded, compressed, err := DecodeWIF(wif, nil)
 	if err != nil {
 		ErrExit(err)
 	}
@@ -119,7 +134,7 @@ func MsgSign(mode string, showSignDetail bool, wif string, msg string, showDetai
 	// got the der signature
 	sigHex := ecc.Secp256k1.NewSignature(r, s).Serialize()
 
-	sign_c, err := secp256k1.SignCompact(secp256k1.NewPrivateKey(privateKey.GetD()), msgHash, compressed)
+	sign_c, err := signCompact(privateKey, msgHash, compressed)
 	if err != nil {
 		ErrExit(err)
 	}
@@ -140,3 +155,23 @@ func MsgSign(mode string, showSignDetail bool, wif string, msg string, showDetai
 	}
 
 }
+
+// SignMessage signs the message, prefixed with the qitmeer message magic, with
+// the passed hex private key, and returns the base64 compact signature, which
+// recovers the compressed public key.
+func SignMessage(privateKeyStr string, msg string) (string, error) {
+	data, err := hex.DecodeString(privateKeyStr)
+	if err != nil {
+		return "", err
+	}
+	privateKey, _ := ecc.Secp256k1.PrivKeyFromBytes(data)
+	sign_c, err := signCompact(privateKey, BuildMsgHash("qx", msg), true)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sign_c), nil
+}
+
+func signCompact(privateKey ecc.PrivateKey, msgHash []byte, compressed bool) ([]byte, error) {
+	return secp256k1.SignCompact(secp256k1.NewPrivateKey(privateKey.GetD()), msgHash, compressed)
+}
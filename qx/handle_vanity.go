@@ -0,0 +1,248 @@
+// Copyright 2017-2018 The qitmeer developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+package qx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/Qitmeer/qitmeer/common/encode/base58"
+	"github.com/Qitmeer/qitmeer/crypto/seed"
+	"math"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// base58Alphabet is the alphabet of the base58 encoded addresses.
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// VanityResult is the key found by VanitySearch.
+type VanityResult struct {
+	PrivateKey string
+	Address    string
+	Attempts   uint64
+	Elapsed    time.Duration
+}
+
+// vanityAddressRange returns the lowest and the highest addresses of the
+// passed network, which are those of the zero and the all ones hashes.
+func vanityAddressRange(network string) (string, string, error) {
+	addr, err := EcPubKeyToAddress(network, "")
+	if err != nil {
+		return "", "", err
+	}
+	// The address is the version, the 20 bytes hash and the 4 bytes
+	// checksum.
+	decoded := base58.Decode(addr)
+	ver := decoded[:len(decoded)-24]
+	minAddr := base58.QitmeerCheckEncode(make([]byte, 20), ver)
+	maxAddr := base58.QitmeerCheckEncode(bytes.Repeat([]byte{0xff}, 20), ver)
+	return minAddr, maxAddr, nil
+}
+
+// base58Value returns the number the passed base58 string encodes.
+func base58Value(s string) *big.Int {
+	return new(big.Int).SetBytes(base58.Decode(s))
+}
+
+// vanityMatches returns whether the address starts with the prefix.
+func vanityMatches(address, prefix string, ignoreCase bool) bool {
+	if len(address) < len(prefix) {
+		return false
+	}
+	if ignoreCase {
+		return strings.EqualFold(address[:len(prefix)], prefix)
+	}
+	return strings.HasPrefix(address, prefix)
+}
+
+// VanityDifficulty returns the expected number of keys to generate before
+// finding an address of the passed network starting with the prefix.  It is an
+// error for the prefix to have characters out of the base58 alphabet, or to
+// be out of the range of the addresses of the network, whose leading
+// characters are set by the address version.
+func VanityDifficulty(network string, prefix string, ignoreCase bool) (float64, error) {
+	if prefix == "" {
+		return 0, errors.New("empty vanity prefix")
+	}
+	// The prefixes matching with the case ignored.
+	prefixes := []string{""}
+	for _, c := range prefix {
+		var candidates []string
+		for _, a := range base58Alphabet {
+			if a == c || (ignoreCase && strings.EqualFold(string(a), string(c))) {
+				candidates = append(candidates, string(a))
+			}
+		}
+		if len(candidates) == 0 {
+			return 0, fmt.Errorf("invalid vanity prefix character %q, "+
+				"should be one of %s", c, base58Alphabet)
+		}
+		var next []string
+		for _, p := range prefixes {
+			for _, c := range candidates {
+				next = append(next, p+c)
+			}
+		}
+		prefixes = next
+	}
+
+	minAddr, maxAddr, err := vanityAddressRange(network)
+	if err != nil {
+		return 0, err
+	}
+	if len(prefix) > len(minAddr) || len(minAddr) != len(maxAddr) {
+		return 0, fmt.Errorf("vanity prefix %s is longer than the "+
+			"addresses of %s", prefix, network)
+	}
+	// The addresses of the same length are ordered as the numbers they
+	// encode, so those starting with a prefix are the range between the
+	// prefix padded with the lowest and the highest characters.
+	minValue, maxValue := base58Value(minAddr), base58Value(maxAddr)
+	total := new(big.Int).Sub(maxValue, minValue)
+	matching := new(big.Int)
+	for _, p := range prefixes {
+		pad := len(minAddr) - len(p)
+		lo := base58Value(p + strings.Repeat(base58Alphabet[:1], pad))
+		hi := base58Value(p + strings.Repeat(base58Alphabet[57:], pad))
+		if lo.Cmp(minValue) < 0 {
+			lo = minValue
+		}
+		if hi.Cmp(maxValue) > 0 {
+			hi = maxValue
+		}
+		if hi.Cmp(lo) > 0 {
+			matching.Add(matching, new(big.Int).Sub(hi, lo))
+		}
+	}
+	if matching.Sign() == 0 {
+		return 0, fmt.Errorf("the addresses of %s are from %s to %s, "+
+			"which can't match the vanity prefix %s", network, minAddr,
+			maxAddr, prefix)
+	}
+	difficulty, _ := new(big.Float).Quo(new(big.Float).SetInt(total),
+		new(big.Float).SetInt(matching)).Float64()
+	return difficulty, nil
+}
+
+// vanityKey generates a random private key and returns it with its compressed
+// address on the passed network.
+func vanityKey(network string) (string, string, error) {
+	entropy, err := NewEntropy(seed.DefaultSeedBytes)
+	if err != nil {
+		return "", "", err
+	}
+	key, err := EcNew("secp256k1", entropy)
+	if err != nil {
+		return "", "", err
+	}
+	pubKey, err := EcPrivateKeyToEcPublicKey(false, key)
+	if err != nil {
+		return "", "", err
+	}
+	address, err := EcPubKeyToAddress(network, pubKey)
+	if err != nil {
+		return "", "", err
+	}
+	return key, address, nil
+}
+
+// VanitySearch generates random keys with the passed number of workers until
+// the address of one on the network starts with the prefix.  The progress
+// function, when not nil, is called every second with the attempts so far.
+func VanitySearch(network string, prefix string, ignoreCase bool, workers int,
+	progress func(attempts uint64, elapsed time.Duration)) (*VanityResult, error) {
+	if workers < 1 {
+		return nil, fmt.Errorf("invalid number of workers %d", workers)
+	}
+	if _, err := VanityDifficulty(network, prefix, ignoreCase); err != nil {
+		return nil, err
+	}
+
+	var (
+		attempts uint64
+		once     sync.Once
+		wg       sync.WaitGroup
+		result   *VanityResult
+		err      error
+	)
+	start := time.Now()
+	quit := make(chan struct{})
+	stop := func(r *VanityResult, e error) {
+		once.Do(func() {
+			result, err = r, e
+			close(quit)
+		})
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-quit:
+					return
+				default:
+				}
+				key, address, e := vanityKey(network)
+				if e != nil {
+					stop(nil, e)
+					return
+				}
+				n := atomic.AddUint64(&attempts, 1)
+				if vanityMatches(address, prefix, ignoreCase) {
+					stop(&VanityResult{
+						PrivateKey: key,
+						Address:    address,
+						Attempts:   n,
+						Elapsed:    time.Since(start),
+					}, nil)
+					return
+				}
+			}
+		}()
+	}
+
+	if progress != nil {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+	out:
+		for {
+			select {
+			case <-ticker.C:
+				progress(atomic.LoadUint64(&attempts), time.Since(start))
+			case <-quit:
+				break out
+			}
+		}
+	}
+	wg.Wait()
+	return result, err
+}
+
+func VanitySearchSTDO(network string, prefix string, ignoreCase bool, workers int) {
+	difficulty, err := VanityDifficulty(network, prefix, ignoreCase)
+	if err != nil {
+		ErrExit(err)
+	}
+	fmt.Fprintf(os.Stderr, "difficulty: %.0f\n", difficulty)
+	result, err := VanitySearch(network, prefix, ignoreCase, workers,
+		func(attempts uint64, elapsed time.Duration) {
+			rate := float64(attempts) / elapsed.Seconds()
+			estimated := time.Duration(math.MaxInt64)
+			if rate > 0 && difficulty/rate < time.Duration(math.MaxInt64).Seconds() {
+				estimated = time.Duration(difficulty / rate * float64(time.Second))
+			}
+			fmt.Fprintf(os.Stderr, "attempts: %d, %.0f/s, estimated time: %v\n",
+				attempts, rate, estimated.Round(time.Second))
+		})
+	if err != nil {
+		ErrExit(err)
+	}
+	fmt.Printf("%s\n%s\n", result.PrivateKey, result.Address)
+}
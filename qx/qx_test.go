@@ -1,8 +1,15 @@
 package qx
 
 import (
+	"encoding/hex"
 	"fmt"
+	"github.com/Qitmeer/qitmeer/common/encode/base58"
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/crypto/bip32"
+	"github.com/Qitmeer/qitmeer/crypto/bip38"
+	"github.com/Qitmeer/qitmeer/params"
 	"github.com/stretchr/testify/assert"
+	"strings"
 	"testing"
 	"time"
 )
@@ -41,6 +48,72 @@ func TestEcNew(t *testing.T) {
 	assert.Equal(t, s, "dbae6e0b3174330ad24be8d952307e95106eb8d573defdc1f393ef2abf2e7b9c")
 }
 
+func TestEcNewCurve(t *testing.T) {
+	entropy := "7686a4df8171ebf04ede968167d0593fd4fbd8ee9feb07d453e768e06cc5e51d"
+	_, err := EcNew("secp256k1", entropy)
+	assert.NoError(t, err)
+
+	_, err = EcNew("p256", entropy)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "secp256k1")
+
+	// 8 bytes is shorter than a BIP32 seed.
+	_, err = EcNew("secp256k1", entropy[:16])
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "8 bytes")
+}
+
+func TestEntropySplitCombine(t *testing.T) {
+	entropy := "7686a4df8171ebf04ede968167d0593fd4fbd8ee9feb07d453e768e06cc5e51d"
+	shares, err := EntropySplit(entropy, 5, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, len(shares), 5)
+
+	for _, subset := range [][]int{{0, 1, 2}, {1, 3, 4}, {4, 2, 0}, {0, 1, 2, 3, 4}} {
+		var parts []string
+		for _, i := range subset {
+			parts = append(parts, shares[i])
+		}
+		combined, err := EntropyCombine(parts)
+		assert.NoError(t, err)
+		assert.Equal(t, combined, entropy)
+	}
+
+	// Fewer shares than the threshold don't recover the entropy.  The
+	// checksum only detects it most of the time.
+	combined, err := EntropyCombine(shares[:2])
+	if err == nil {
+		assert.NotEqual(t, combined, entropy)
+	}
+
+	// The shares don't reveal the entropy or its mnemonic, unlike a
+	// fingerprint of the mnemonic would.
+	again, _ := EntropySplit(entropy, 5, 3)
+	assert.NotEqual(t, shares[0][:2*entropySplitIDSize],
+		again[0][:2*entropySplitIDSize])
+
+	// Shares of another split don't mix, even of the same entropy.
+	_, err = EntropyCombine([]string{shares[0], shares[1], again[2]})
+	assert.Error(t, err)
+	others, _ := EntropySplit(entropy[:32], 5, 3)
+	_, err = EntropyCombine([]string{shares[0], shares[1], others[2]})
+	assert.Error(t, err)
+
+	// A corrupted share is detected by the checksum most of the time, and
+	// never recovers the entropy.
+	corrupted := []byte(shares[2])
+	if corrupted[len(corrupted)-1] == '0' {
+		corrupted[len(corrupted)-1] = '1'
+	} else {
+		corrupted[len(corrupted)-1] = '0'
+	}
+	combined, err = EntropyCombine([]string{shares[0], shares[1],
+		string(corrupted)})
+	if err == nil {
+		assert.NotEqual(t, combined, entropy)
+	}
+}
+
 func TestEcPrivateKeyToEcPublicKey(t *testing.T) {
 	s, _ := EcPrivateKeyToEcPublicKey(false, "dbae6e0b3174330ad24be8d952307e95106eb8d573defdc1f393ef2abf2e7b9c")
 	assert.Equal(t, s, "02addd806e8813f85fad05b97541915eb3a1f27528d3156f2ef8166823d6722b58")
@@ -51,6 +124,155 @@ func TestEcPubKeyToAddress(t *testing.T) {
 	assert.Equal(t, s, "TmgMiXziDuFiyLc159zagcCnmVxhReojytr")
 }
 
+func TestWIFChecksumFunc(t *testing.T) {
+	k := "dbae6e0b3174330ad24be8d952307e95106eb8d573defdc1f393ef2abf2e7b9c"
+	blake := base58.DoubleHashChecksumFunc(hash.GetHasher(hash.Blake2b_256), 4)
+	for _, uncompressed := range []bool{false, true} {
+		wif, err := EncodeWIF(uncompressed, k, blake)
+		assert.NoError(t, err)
+		defaultWif, _ := EncodeWIF(uncompressed, k, nil)
+		assert.NotEqual(t, wif, defaultWif)
+
+		decoded, compressed, err := DecodeWIF(wif, blake)
+		assert.NoError(t, err)
+		assert.Equal(t, fmt.Sprintf("%x", decoded), k)
+		assert.Equal(t, compressed, !uncompressed)
+
+		// The checksum of another function doesn't match.
+		_, _, err = DecodeWIF(wif, nil)
+		assert.Error(t, err)
+		_, _, err = DecodeWIF(defaultWif, blake)
+		assert.Error(t, err)
+	}
+}
+
+func TestBip38(t *testing.T) {
+	k := "dbae6e0b3174330ad24be8d952307e95106eb8d573defdc1f393ef2abf2e7b9c"
+	encrypted, err := EcPrivateKeyToBip38("testnet", false, k, "passphrase")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encrypted, "6P"))
+
+	decrypted, compressed, err := Bip38ToEcPrivateKey("testnet", encrypted, "passphrase")
+	assert.NoError(t, err)
+	assert.Equal(t, decrypted, k)
+	assert.True(t, compressed)
+
+	// The address hash doesn't match with another passphrase or network.
+	_, _, err = Bip38ToEcPrivateKey("testnet", encrypted, "wrong")
+	assert.Equal(t, err, bip38.ErrWrongPassphrase)
+	_, _, err = Bip38ToEcPrivateKey("mainnet", encrypted, "passphrase")
+	assert.Equal(t, err, bip38.ErrWrongPassphrase)
+}
+
+func TestSignVerifyMessage(t *testing.T) {
+	k := "dbae6e0b3174330ad24be8d952307e95106eb8d573defdc1f393ef2abf2e7b9c"
+	addr := "TmgMiXziDuFiyLc159zagcCnmVxhReojytr"
+	signature, err := SignMessage(k, "hello qitmeer")
+	assert.NoError(t, err)
+
+	ok, err := VerifyMessage(addr, signature, "hello qitmeer")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// The key recovered from a tampered message isn't the one of the address.
+	ok, _ = VerifyMessage(addr, signature, "hello qitmeer!")
+	assert.False(t, ok)
+
+	_, err = VerifyMessage(addr, "not base64", "hello qitmeer")
+	assert.Error(t, err)
+}
+
+func TestVanitySearch(t *testing.T) {
+	// All the testnet addresses start with Tm, from TmN to Tmn.
+	d, err := VanityDifficulty("testnet", "T", false)
+	assert.NoError(t, err)
+	assert.Equal(t, d, 1.0)
+	d, err = VanityDifficulty("testnet", "tmg", true)
+	assert.NoError(t, err)
+	assert.True(t, d > 1 && d < 58)
+
+	for _, prefix := range []string{"T", "Tmg"} {
+		result, err := VanitySearch("testnet", prefix, false, 4, nil)
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(result.Address, prefix))
+		p, _ := EcPrivateKeyToEcPublicKey(false, result.PrivateKey)
+		a, _ := EcPubKeyToAddress("testnet", p)
+		assert.Equal(t, a, result.Address)
+	}
+
+	for _, prefix := range []string{"", "Tm0", "Nm", "Tmz"} {
+		_, err := VanitySearch("testnet", prefix, false, 4, nil)
+		assert.Error(t, err, prefix)
+	}
+	_, err = VanitySearch("testnet", "T", false, 0, nil)
+	assert.Error(t, err)
+}
+
+func TestHdAccountPublicKey(t *testing.T) {
+	entropy, _ := hex.DecodeString("7686a4df8171ebf04ede968167d0593fd4fbd8ee9feb07d453e768e06cc5e51d")
+	master, err := bip32.NewMasterKey2(entropy, QitmeerTestnetBip32Version)
+	assert.NoError(t, err)
+
+	xpub, err := HdAccountPublicKey("testnet", 1, master.String())
+	assert.NoError(t, err)
+	accountKey, err := bip32.B58Deserialize(xpub, QitmeerTestnetBip32Version)
+	assert.NoError(t, err)
+	assert.False(t, accountKey.IsPrivate)
+
+	// The child public keys of the account key are those of the private
+	// derivation of m/44'/223'/1'/0/i.
+	for i := uint32(0); i < 3; i++ {
+		key := master
+		for _, c := range []uint32{bip32.FirstHardenedChild + 44,
+			bip32.FirstHardenedChild + params.TestNetParams.HDCoinType,
+			bip32.FirstHardenedChild + 1, 0, i} {
+			key, err = key.NewChildKey(c)
+			assert.NoError(t, err)
+		}
+		child, err := accountKey.NewChildKey(0)
+		assert.NoError(t, err)
+		child, err = child.NewChildKey(i)
+		assert.NoError(t, err)
+		assert.Equal(t, child.Key, key.PublicKey().Key)
+	}
+
+	// The hardened path can't be derived from a public key.
+	_, err = HdAccountPublicKey("testnet", 1, master.PublicKey().String())
+	assert.Error(t, err)
+	// Nor from a key of another network.
+	_, err = HdAccountPublicKey("mainnet", 1, master.String())
+	assert.Error(t, err)
+}
+
+func TestMultisigAddress(t *testing.T) {
+	pubkeys := []string{
+		"02addd806e8813f85fad05b97541915eb3a1f27528d3156f2ef8166823d6722b58",
+		"0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798",
+		"02c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5",
+	}
+	addr, script, err := MultisigAddress("testnet", 2, pubkeys, false)
+	assert.NoError(t, err)
+	assert.Equal(t, script, "52"+"21"+pubkeys[0]+"21"+pubkeys[1]+"21"+pubkeys[2]+"53ae")
+	assert.Equal(t, addr, "TS7ehyHeEhNDA3NSBcmW8ZQMGRLCTTA3THq")
+
+	// The BIP67 order doesn't depend on the order of the keys.
+	for _, keys := range [][]string{pubkeys, {pubkeys[2], pubkeys[1], pubkeys[0]}} {
+		addr, script, err = MultisigAddress("testnet", 2, keys, true)
+		assert.NoError(t, err)
+		assert.Equal(t, script, "52"+"21"+pubkeys[1]+"21"+pubkeys[0]+"21"+pubkeys[2]+"53ae")
+		assert.Equal(t, addr, "TS6pPPiwxgaiZK7D5yJqNi2okJfJr7h6JtK")
+	}
+
+	_, _, err = MultisigAddress("testnet", 4, pubkeys, false)
+	assert.Error(t, err)
+	_, _, err = MultisigAddress("testnet", 0, pubkeys, false)
+	assert.Error(t, err)
+	// Not a point of the curve.
+	_, _, err = MultisigAddress("testnet", 2, append(pubkeys[:2:2],
+		"02ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff"), false)
+	assert.Error(t, err)
+}
+
 func TestCreateAddress(t *testing.T) {
 	s, _ := NewEntropy(32)
 	k, _ := EcNew("secp256k1", s)
@@ -0,0 +1,149 @@
+// Copyright (c) 2017-2019 The qitmeer developers
+//
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/Qitmeer/qitmeer/log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// JsonHealthStatus describes the health of the RPC server as reported on the
+// admin listener.
+type JsonHealthStatus struct {
+	NumClients    int32  `json:"numclients"`
+	Started       bool   `json:"started"`
+	Shutdown      bool   `json:"shutdown"`
+	TotalRequests uint64 `json:"totalrequests"`
+	Uptime        int64  `json:"uptime"`
+}
+
+// HealthStatus returns the current health of the RPC server.
+//
+// This function is safe for concurrent access.
+func (s *RpcServer) HealthStatus() *JsonHealthStatus {
+	hs := JsonHealthStatus{
+		NumClients:    atomic.LoadInt32(&s.numClients),
+		Started:       atomic.LoadInt32(&s.run) == 1,
+		Shutdown:      atomic.LoadInt32(&s.shutdown) == 1,
+		TotalRequests: atomic.LoadUint64(&s.totalRequests),
+	}
+	if startTime := atomic.LoadInt64(&s.startTime); startTime != 0 {
+		hs.Uptime = time.Now().Unix() - startTime
+	}
+	return &hs
+}
+
+// handleHealth reports the health of the RPC server as JSON, or in the
+// Prometheus text exposition format when the client asks for plain text.
+func (s *RpcServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	hs := s.HealthStatus()
+	if strings.Contains(r.Header.Get("Accept"), "text/plain") {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writePromGauge(w, "qitmeer_rpc_clients", "Number of connected RPC clients.", int64(hs.NumClients))
+		writePromGauge(w, "qitmeer_rpc_started", "Whether the RPC server is started.", boolToInt(hs.Started))
+		writePromGauge(w, "qitmeer_rpc_shutdown", "Whether the RPC server is shut down.", boolToInt(hs.Shutdown))
+		writePromCounter(w, "qitmeer_rpc_requests_total", "Total RPC requests served.", hs.TotalRequests)
+		writePromGauge(w, "qitmeer_rpc_uptime_seconds", "Uptime of the RPC server.", hs.Uptime)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	if err := json.NewEncoder(w).Encode(hs); err != nil {
+		log.Error("Failed to write RPC health", "error", err)
+	}
+}
+
+// newAdminServeMux returns the handlers served on the admin listeners, which
+// include the pprof ones when profiling is enabled.
+func (s *RpcServer) newAdminServeMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", s.handleHealth)
+	if s.config.RPCAdminProfile {
+		mux.HandleFunc("/debug/pprof/", s.whileStarted(pprof.Index))
+		mux.HandleFunc("/debug/pprof/cmdline", s.whileStarted(pprof.Cmdline))
+		mux.HandleFunc("/debug/pprof/profile", s.whileStarted(pprof.Profile))
+		mux.HandleFunc("/debug/pprof/symbol", s.whileStarted(pprof.Symbol))
+		mux.HandleFunc("/debug/pprof/trace", s.whileStarted(pprof.Trace))
+	}
+	return mux
+}
+
+// whileStarted returns the passed handler refusing the requests with a 503
+// service unavailable unless the RPC server is started, so that profiling
+// follows the lifecycle of the server.
+func (s *RpcServer) whileStarted(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&s.run) != 1 || atomic.LoadInt32(&s.shutdown) == 1 {
+			s.HTTPError(w, http.StatusServiceUnavailable,
+				"RPC server not started")
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// startAdmin starts serving the admin handlers on the passed addresses.  The
+// admin listener is disabled unless addresses are explicitly configured, and
+// its connections don't count against the RPC clients.
+func (s *RpcServer) startAdmin(listenAddrs []string) error {
+	if len(listenAddrs) == 0 {
+		return nil
+	}
+	listeners := make([]net.Listener, 0, len(listenAddrs))
+	for _, addr := range listenAddrs {
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return err
+		}
+		listeners = append(listeners, listener)
+	}
+
+	s.adminServer = &http.Server{
+		Handler:     s.newAdminServeMux(),
+		ReadTimeout: time.Second * rpcAuthTimeoutSeconds,
+	}
+	for _, listener := range listeners {
+		s.wg.Add(1)
+		go func(listener net.Listener) {
+			log.Info("RPC admin listening on ", "addr", listener.Addr())
+			s.adminServer.Serve(listener)
+			log.Trace("RPC admin listener done", "addr", listener.Addr())
+			s.wg.Done()
+		}(listener)
+	}
+	return nil
+}
+
+// stopAdmin closes the admin listeners, if any.
+func (s *RpcServer) stopAdmin() {
+	if s.adminServer != nil {
+		s.adminServer.Close()
+	}
+}
+
+func writePromGauge(w http.ResponseWriter, name, help string, value int64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %d\n", name, help, name, name, value)
+}
+
+func writePromCounter(w http.ResponseWriter, name, help string, value uint64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, value)
+}
+
+func boolToInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,99 @@
+// Copyright (c) 2017-2019 The qitmeer developers
+//
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"encoding/json"
+	"github.com/Qitmeer/qitmeer/config"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// TestHealthHandler ensures the health handler reports the expected JSON
+// shape and the Prometheus text exposition on request.
+func TestHealthHandler(t *testing.T) {
+	s, err := NewRPCServer(&config.Config{})
+	if err != nil {
+		t.Fatalf("NewRPCServer: %v", err)
+	}
+	s.incrementClients()
+
+	rec := httptest.NewRecorder()
+	s.newAdminServeMux().ServeHTTP(rec, httptest.NewRequest("GET", "/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d", rec.Code)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &fields); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	for _, key := range []string{"numclients", "started", "shutdown", "totalrequests", "uptime"} {
+		if _, ok := fields[key]; !ok {
+			t.Errorf("missing field %q", key)
+		}
+	}
+	if len(fields) != 5 {
+		t.Errorf("unexpected fields %v", fields)
+	}
+	if fields["numclients"] != float64(1) {
+		t.Errorf("numclients: got %v, want 1", fields["numclients"])
+	}
+
+	req := httptest.NewRequest("GET", "/health", nil)
+	req.Header.Set("Accept", "text/plain")
+	rec = httptest.NewRecorder()
+	s.newAdminServeMux().ServeHTTP(rec, req)
+	if !strings.Contains(rec.Body.String(), "qitmeer_rpc_clients 1\n") {
+		t.Errorf("unexpected text exposition:\n%s", rec.Body.String())
+	}
+}
+
+// TestHealthDisabledByDefault ensures no admin listener is started unless
+// one is explicitly configured.
+func TestHealthDisabledByDefault(t *testing.T) {
+	s, err := NewRPCServer(&config.Config{})
+	if err != nil {
+		t.Fatalf("NewRPCServer: %v", err)
+	}
+	if err := s.startAdmin(s.config.RPCAdminListeners); err != nil {
+		t.Fatalf("startAdmin: %v", err)
+	}
+	if s.adminServer != nil {
+		t.Fatal("admin listener started without being configured")
+	}
+}
+
+// TestAdminProfile ensures the pprof index is served on the admin listener of
+// a started server only when profiling is enabled.
+func TestAdminProfile(t *testing.T) {
+	tests := []struct {
+		profile bool
+		started bool
+		want    int
+	}{
+		{true, true, http.StatusOK},
+		{true, false, http.StatusServiceUnavailable},
+		{false, true, http.StatusNotFound},
+	}
+	for _, test := range tests {
+		s, err := NewRPCServer(&config.Config{RPCAdminProfile: test.profile})
+		if err != nil {
+			t.Fatalf("NewRPCServer: %v", err)
+		}
+		if test.started {
+			atomic.StoreInt32(&s.run, 1)
+		}
+		rec := httptest.NewRecorder()
+		s.newAdminServeMux().ServeHTTP(rec, httptest.NewRequest("GET", "/debug/pprof/", nil))
+		if rec.Code != test.want {
+			t.Errorf("profile %v, started %v: got status %d, want %d",
+				test.profile, test.started, rec.Code, test.want)
+		}
+	}
+}
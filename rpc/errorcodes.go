@@ -0,0 +1,103 @@
+// Copyright (c) 2017-2019 The qitmeer developers
+//
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpc
+
+// The codes of the RPC errors, which clients can rely on as they are stable
+// across releases: a code is never reused for another kind of error.  The
+// codes of the invalid requests are the standard JSON-RPC ones, and the
+// others are in the range reserved to the servers.
+const (
+	// ErrCodeParse is the code of a message which isn't valid JSON.
+	ErrCodeParse = -32700
+	// ErrCodeInvalidRequest is the code of an invalid request object.
+	ErrCodeInvalidRequest = -32600
+	// ErrCodeMethodNotFound is the code of a call of an unknown method.
+	ErrCodeMethodNotFound = -32601
+	// ErrCodeInvalidParams is the code of a call with invalid params.
+	ErrCodeInvalidParams = -32602
+	// ErrCodeInternal is the code of an internal error of the node.
+	ErrCodeInternal = -32603
+
+	// ErrCodeCallback is the code of a method which failed for a reason
+	// without a more specific code, and of a server shutting down.
+	ErrCodeCallback = -32000
+	// ErrCodeRateLimited is the code of a call exceeding the allowance of
+	// the client for the method.
+	ErrCodeRateLimited = -32005
+	// ErrCodeMethodNotAllowed is the code of a call of a method denied by
+	// the method filter.
+	ErrCodeMethodNotAllowed = -32006
+	// ErrCodeTimeout is the code of a call exceeding the request timeout.
+	ErrCodeTimeout = -32007
+
+	// ErrCodeInvalidParameter is the code of a param with an invalid value.
+	ErrCodeInvalidParameter = -32010
+	// ErrCodeNoTxInfo is the code of a transaction which isn't known.
+	ErrCodeNoTxInfo = -32011
+	// ErrCodeDecodeHex is the code of a param which isn't valid hex.
+	ErrCodeDecodeHex = -32012
+	// ErrCodeDeserialization is the code of a param which can't be
+	// deserialized, such as a raw transaction.
+	ErrCodeDeserialization = -32013
+	// ErrCodeDuplicateTx is the code of a transaction which is already
+	// known.
+	ErrCodeDuplicateTx = -32014
+	// ErrCodeRule is the code of a block or transaction violating a rule.
+	// The data of the rule errors having a rule code, such as the mining
+	// ones, is the name of their code.
+	ErrCodeRule = -32015
+	// ErrCodeAddressOrKey is the code of an invalid address or key.
+	ErrCodeAddressOrKey = -32016
+	// ErrCodeInInitialDownload is the code of a method unavailable while
+	// the node is downloading the chain.
+	ErrCodeInInitialDownload = -32017
+)
+
+// RPCError is an error of a method with a code and optional data, which are
+// returned in the error object of the response.
+type RPCError struct {
+	Code    int
+	Message string
+	Data    interface{}
+}
+
+// Error returns the message of the error.
+func (e *RPCError) Error() string { return e.Message }
+
+// ErrorCode returns the code of the error.
+func (e *RPCError) ErrorCode() int { return e.Code }
+
+// ErrorData returns the data of the error, if any.
+func (e *RPCError) ErrorData() interface{} { return e.Data }
+
+// dataError is implemented by the errors with data for their error object.
+type dataError interface {
+	ErrorData() interface{}
+}
+
+// ruleCodeError is implemented by the rule errors identified by a rule code,
+// such as the mining rule errors, which can't be imported here.
+type ruleCodeError interface {
+	error
+	RuleCode() string
+}
+
+// toRPCError returns the error returned by a method as an RPC error, with the
+// code of its kind:
+//   - the errors with a code, such as the RPCErrors, keep it
+//   - the rule errors with a rule code are ErrCodeRule errors, whose data is
+//     the rule code
+//   - the other errors are ErrCodeCallback errors
+func toRPCError(err error) Error {
+	switch e := err.(type) {
+	case Error:
+		return e
+	case ruleCodeError:
+		return &RPCError{Code: ErrCodeRule, Message: e.Error(), Data: e.RuleCode()}
+	default:
+		return &callbackError{err.Error()}
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2017-2019 The qitmeer developers
+//
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// testRuleError is a rule error with a rule code, as the mining ones.
+type testRuleError struct{}
+
+func (testRuleError) Error() string    { return "fees overflow" }
+func (testRuleError) RuleCode() string { return "ErrFeesOverflow" }
+
+// TestErrorCodes ensures the errors of the methods are returned with their
+// documented code and data.
+func TestErrorCodes(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+		data interface{}
+	}{
+		{"callback", errors.New("failed"), ErrCodeCallback, nil},
+		{"invalid parameter", RpcInvalidError("bad %d", 1), ErrCodeInvalidParameter, nil},
+		{"no tx info", RpcNoTxInfoError(nil), ErrCodeNoTxInfo, nil},
+		{"decode hex", RpcDecodeHexError("zz"), ErrCodeDecodeHex, nil},
+		{"deserialization", RpcDeserializationError("bad tx"), ErrCodeDeserialization, nil},
+		{"duplicate tx", RpcDuplicateTxError("dup"), ErrCodeDuplicateTx, nil},
+		{"rule", RpcRuleError("bad"), ErrCodeRule, nil},
+		{"address or key", RpcAddressKeyError("bad"), ErrCodeAddressOrKey, nil},
+		{"internal", RpcInternalError("failed", "context"), ErrCodeInternal, nil},
+		{"initial download", RPCClientInInitialDownloadError("syncing", "context"),
+			ErrCodeInInitialDownload, nil},
+		{"mining rule", testRuleError{}, ErrCodeRule, "ErrFeesOverflow"},
+		{"custom", &RPCError{Code: -32099, Message: "custom", Data: "info"}, -32099, "info"},
+	}
+
+	codec := NewJSONCodec(&httpReadWriteNopCloser{&bytes.Buffer{}, &bytes.Buffer{}})
+	for _, test := range tests {
+		resp := codec.CreateErrorResponse(1, toRPCError(test.err))
+		b, err := json.Marshal(resp)
+		if err != nil {
+			t.Fatalf("%s: Marshal: %v", test.name, err)
+		}
+		var got struct {
+			Error struct {
+				Code    int         `json:"code"`
+				Message string      `json:"message"`
+				Data    interface{} `json:"data"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(b, &got); err != nil {
+			t.Fatalf("%s: Unmarshal: %v", test.name, err)
+		}
+		if got.Error.Code != test.code || got.Error.Data != test.data ||
+			got.Error.Message != test.err.Error() {
+			t.Errorf("%s: got %s, want code %d and data %v", test.name, b,
+				test.code, test.data)
+		}
+	}
+}
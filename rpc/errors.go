@@ -16,7 +16,7 @@ type methodNotFoundError struct {
 	method  string
 }
 
-func (e *methodNotFoundError) ErrorCode() int { return -32601 }
+func (e *methodNotFoundError) ErrorCode() int { return ErrCodeMethodNotFound }
 
 func (e *methodNotFoundError) Error() string {
 	if e.service == DefaultServiceNameSpace {
@@ -28,34 +28,59 @@ func (e *methodNotFoundError) Error() string {
 // received message isn't a valid request
 type invalidRequestError struct{ message string }
 
-func (e *invalidRequestError) ErrorCode() int { return -32600 }
+func (e *invalidRequestError) ErrorCode() int { return ErrCodeInvalidRequest }
 
 func (e *invalidRequestError) Error() string { return e.message }
 
 // received message is invalid
 type invalidMessageError struct{ message string }
 
-func (e *invalidMessageError) ErrorCode() int { return -32700 }
+func (e *invalidMessageError) ErrorCode() int { return ErrCodeParse }
 
 func (e *invalidMessageError) Error() string { return e.message }
 
 // unable to decode supplied params, or an invalid number of parameters
 type invalidParamsError struct{ message string }
 
-func (e *invalidParamsError) ErrorCode() int { return -32602 }
+func (e *invalidParamsError) ErrorCode() int { return ErrCodeInvalidParams }
 
 func (e *invalidParamsError) Error() string { return e.message }
 
 // logic error, callback returned an error
 type callbackError struct{ message string }
 
-func (e *callbackError) ErrorCode() int { return -32000 }
+func (e *callbackError) ErrorCode() int { return ErrCodeCallback }
 
 func (e *callbackError) Error() string { return e.message }
 
 // issued when a request is received after the server is issued to stop.
 type shutdownError struct{}
 
-func (e *shutdownError) ErrorCode() int { return -32000 }
+func (e *shutdownError) ErrorCode() int { return ErrCodeCallback }
 
 func (e *shutdownError) Error() string { return "server is shutting down" }
+
+// issued when a request handler exceeds the request timeout.
+type requestTimeoutError struct{}
+
+func (e *requestTimeoutError) ErrorCode() int { return ErrCodeTimeout }
+
+func (e *requestTimeoutError) Error() string { return "request timed out" }
+
+// issued when a method is not allowed by the method filter.
+type methodNotAllowedError struct{ method string }
+
+func (e *methodNotAllowedError) ErrorCode() int { return ErrCodeMethodNotAllowed }
+
+func (e *methodNotAllowedError) Error() string {
+	return fmt.Sprintf("method not allowed: %s", e.method)
+}
+
+// issued when a client exceeds its request allowance for a method.
+type rateLimitedError struct{ method string }
+
+func (e *rateLimitedError) ErrorCode() int { return ErrCodeRateLimited }
+
+func (e *rateLimitedError) Error() string {
+	return fmt.Sprintf("rate limited: too many %s requests, try again later", e.method)
+}
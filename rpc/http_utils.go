@@ -6,6 +6,8 @@ import (
 	"errors"
 	"fmt"
 	"github.com/Qitmeer/qitmeer/core/types"
+	"io"
+	"io/ioutil"
 	"mime"
 	"net/http"
 )
@@ -16,13 +18,13 @@ const (
 )
 
 // validateRequest returns a non-zero response code and error message if the
-// request is invalid.
-func validateRequest(r *http.Request) (int, error) {
+// request is invalid, such as larger than maxSize.
+func validateRequest(r *http.Request, maxSize int64) (int, error) {
 	if r.Method == http.MethodPut || r.Method == http.MethodDelete {
 		return http.StatusMethodNotAllowed, errors.New("method not allowed")
 	}
-	if r.ContentLength > maxRequestContentLength {
-		err := fmt.Errorf("content length too large (%d>%d)", r.ContentLength, maxRequestContentLength)
+	if r.ContentLength > maxSize {
+		err := fmt.Errorf("content length too large (%d>%d)", r.ContentLength, maxSize)
 		return http.StatusRequestEntityTooLarge, err
 	}
 	mt, _, err := mime.ParseMediaType(r.Header.Get("content-type"))
@@ -33,6 +35,22 @@ func validateRequest(r *http.Request) (int, error) {
 	return 0, nil
 }
 
+// readRequestBody reads the body of the request, which is checked against
+// maxSize as it is read since its content length may be unknown.  It returns
+// a non-zero response code and error message when it can't be read or is too
+// large.
+func readRequestBody(r *http.Request, maxSize int64) ([]byte, int, error) {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxSize+1))
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if int64(len(body)) > maxSize {
+		err := fmt.Errorf("request body too large (>%d)", maxSize)
+		return nil, http.StatusRequestEntityTooLarge, err
+	}
+	return body, 0, nil
+}
+
 func emptyRequest(r *http.Request) bool {
 	return r.Method == http.MethodGet && r.ContentLength == 0 && r.URL.RawQuery == ""
 }
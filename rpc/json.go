@@ -80,6 +80,15 @@ type JsonRequestStatus struct {
 	RunningNum  int    `json:"runningnum"`
 }
 
+// StreamedResult is a result which can be written to the connection piece by
+// piece instead of being marshalled as a whole first, which bounds the memory
+// used by large results.  It must marshal to the same JSON as it streams,
+// since it is marshalled normally by the transports and batches which can't
+// stream.
+type StreamedResult interface {
+	StreamJSON(w io.Writer) error
+}
+
 // jsonCodec reads and writes JSON-RPC messages to the underlying connection. It
 // also has support for parsing arguments and serializing (result) objects.
 type jsonCodec struct {
@@ -90,6 +99,7 @@ type jsonCodec struct {
 	encMu  sync.Mutex                // guards the encoder
 	encode func(v interface{}) error // encoder to allow multiple transports
 	rw     io.ReadWriteCloser        // connection
+	stream io.Writer                 // streamed results destination, if any
 }
 
 func (err *jsonError) Error() string {
@@ -128,6 +138,15 @@ func NewJSONCodec(rwc io.ReadWriteCloser) ServerCodec {
 	}
 }
 
+// newStreamingJSONCodec creates a JSON-RPC 2.0 codec which streams the
+// StreamedResult results to the connection.  It is only suitable for stream
+// transports, on which a message may be split over several writes.
+func newStreamingJSONCodec(rwc io.ReadWriteCloser) ServerCodec {
+	codec := NewJSONCodec(rwc).(*jsonCodec)
+	codec.stream = rwc
+	return codec
+}
+
 // isBatch returns true when the first non-whitespace characters is '['
 func isBatch(msg json.RawMessage) bool {
 	for _, c := range msg {
@@ -186,17 +205,30 @@ func parseRequest(incomingMsg json.RawMessage) ([]rpcRequest, bool, Error) {
 		}
 		in = append(in, re)
 	}
+	if batch && len(in) == 0 {
+		return nil, batch, &invalidRequestError{"empty batch"}
+	}
 	requests := make([]rpcRequest, len(in))
 	for i, r := range in {
-		if err := checkReqId(r.Id); err != nil {
-			return nil, batch, &invalidMessageError{err.Error()}
+		// A request without id is a notification, which isn't answered.
+		notification := len(r.Id) == 0
+		if !notification {
+			if err := checkReqId(r.Id); err != nil {
+				// An invalid element of a batch is answered with an
+				// error, without failing the others.
+				if batch {
+					requests[i] = rpcRequest{err: &invalidMessageError{err.Error()}}
+					continue
+				}
+				return nil, batch, &invalidMessageError{err.Error()}
+			}
 		}
 
 		id := &in[i].Id
 
 		// subscribe are special, they will always use `subscriptionMethod` as first param in the payload
 		if strings.HasSuffix(r.Method, subscribeMethodSuffix) {
-			requests[i] = rpcRequest{id: id, isPubSub: true}
+			requests[i] = rpcRequest{id: id, notification: notification, isPubSub: true}
 			if len(r.Payload) > 0 {
 				// first param must be subscription name
 				var subscribeMethod [1]string
@@ -214,14 +246,14 @@ func parseRequest(incomingMsg json.RawMessage) ([]rpcRequest, bool, Error) {
 		}
 
 		if strings.HasSuffix(r.Method, unsubscribeMethodSuffix) {
-			requests[i] = rpcRequest{id: id, isPubSub: true, method: r.Method, params: r.Payload}
+			requests[i] = rpcRequest{id: id, notification: notification, isPubSub: true, method: r.Method, params: r.Payload}
 			continue
 		}
 
 		if len(r.Payload) == 0 {
-			requests[i] = rpcRequest{id: id, params: nil}
+			requests[i] = rpcRequest{id: id, notification: notification, params: nil}
 		} else {
-			requests[i] = rpcRequest{id: id, params: r.Payload}
+			requests[i] = rpcRequest{id: id, notification: notification, params: r.Payload}
 		}
 		if elem := strings.Split(r.Method, serviceMethodSeparator); len(elem) == 2 {
 			requests[i].service, requests[i].method = elem[0], elem[1]
@@ -288,9 +320,14 @@ func (c *jsonCodec) CreateResponse(id interface{}, reply interface{}) interface{
 	return &jsonSuccessResponse{Version: jsonrpcVersion, Id: id, Result: reply}
 }
 
-// CreateErrorResponse will create a JSON-RPC error response with the given id and error.
+// CreateErrorResponse will create a JSON-RPC error response with the given id and error,
+// including the data of the error if any.
 func (c *jsonCodec) CreateErrorResponse(id interface{}, err Error) interface{} {
-	return &jsonErrResponse{Version: jsonrpcVersion, Id: id, Error: jsonError{Code: err.ErrorCode(), Message: err.Error()}}
+	var data interface{}
+	if de, ok := err.(dataError); ok {
+		data = de.ErrorData()
+	}
+	return &jsonErrResponse{Version: jsonrpcVersion, Id: id, Error: jsonError{Code: err.ErrorCode(), Message: err.Error(), Data: data}}
 }
 
 // CreateErrorResponseWithInfo will create a JSON-RPC error response with the given id and error.
@@ -311,9 +348,43 @@ func (c *jsonCodec) Write(res interface{}) error {
 	c.encMu.Lock()
 	defer c.encMu.Unlock()
 
+	if resp, ok := res.(*jsonSuccessResponse); ok && c.stream != nil {
+		if result, ok := resp.Result.(StreamedResult); ok {
+			return writeStreamedResponse(c.stream, resp, result)
+		}
+	}
 	return c.encode(res)
 }
 
+// writeStreamedResponse writes the passed success response to w as the
+// encoder would, except that its result is streamed.
+func writeStreamedResponse(w io.Writer, resp *jsonSuccessResponse, result StreamedResult) error {
+	var head bytes.Buffer
+	head.WriteString(`{"jsonrpc":`)
+	version, err := json.Marshal(resp.Version)
+	if err != nil {
+		return err
+	}
+	head.Write(version)
+	if resp.Id != nil {
+		id, err := json.Marshal(resp.Id)
+		if err != nil {
+			return err
+		}
+		head.WriteString(`,"id":`)
+		head.Write(id)
+	}
+	head.WriteString(`,"result":`)
+	if _, err := w.Write(head.Bytes()); err != nil {
+		return err
+	}
+	if err := result.StreamJSON(w); err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, "}\n")
+	return err
+}
+
 // Close the underlying connection
 func (c *jsonCodec) Close() {
 	c.closer.Do(func() {
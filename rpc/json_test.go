@@ -0,0 +1,50 @@
+// Copyright (c) 2017-2019 The qitmeer developers
+//
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+)
+
+// testStream is a streamed list of numbers.
+type testStream []int
+
+func (s testStream) StreamJSON(w io.Writer) error {
+	sep := "["
+	for _, n := range s {
+		if _, err := fmt.Fprintf(w, "%s%d", sep, n); err != nil {
+			return err
+		}
+		sep = ","
+	}
+	_, err := io.WriteString(w, "]")
+	return err
+}
+
+// TestStreamedResponse ensures streamed results are written as the encoder
+// writes them.
+func TestStreamedResponse(t *testing.T) {
+	id := json.RawMessage(`"req-1"`)
+	for _, id := range []interface{}{&id, nil} {
+		var want, got bytes.Buffer
+		codec := NewJSONCodec(&httpReadWriteNopCloser{nil, &want})
+		streaming := newStreamingJSONCodec(&httpReadWriteNopCloser{nil, &got})
+		result := testStream{1, 2, 3}
+		if err := codec.Write(codec.CreateResponse(id, result)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		if err := streaming.Write(streaming.CreateResponse(id, result)); err != nil {
+			t.Fatalf("streamed Write: %v", err)
+		}
+		if !bytes.Equal(got.Bytes(), want.Bytes()) {
+			t.Errorf("streamed response %q, want %q", got.Bytes(), want.Bytes())
+		}
+	}
+}
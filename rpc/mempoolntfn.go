@@ -0,0 +1,100 @@
+// Copyright (c) 2017-2019 The qitmeer developers
+//
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"context"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"github.com/Qitmeer/qitmeer/log"
+	"github.com/Qitmeer/qitmeer/metrics"
+	"sync/atomic"
+)
+
+// mempoolTxQueueSize is the number of accepted transactions queued for a
+// subscriber which is not keeping up, beyond which they are dropped.
+const mempoolTxQueueSize = 256
+
+// droppedMempoolTxCounter counts the accepted transactions dropped for the
+// subscribers which were not keeping up.
+var droppedMempoolTxCounter = metrics.NewRegisteredCounter("rpc/mempooltx/dropped", nil)
+
+// mempoolTxSub is a subscriber to the transactions accepted to the mempool.
+type mempoolTxSub struct {
+	queue chan *types.Tx
+
+	// dropped must only be used atomically.
+	dropped uint64
+}
+
+// NotifyMempoolTx queues the passed transaction accepted to the mempool for
+// every subscriber.  It doesn't block, as the transaction is dropped for the
+// subscribers whose queue is full.
+//
+// This function is safe for concurrent access.
+func (s *RpcServer) NotifyMempoolTx(tx *types.Tx) {
+	s.mempoolTxSubsMtx.Lock()
+	defer s.mempoolTxSubsMtx.Unlock()
+
+	for sub := range s.mempoolTxSubs {
+		select {
+		case sub.queue <- tx:
+		default:
+			atomic.AddUint64(&sub.dropped, 1)
+			droppedMempoolTxCounter.Inc(1)
+		}
+	}
+}
+
+// SubscribeMempoolTxs creates a subscription of the connection of ctx to the
+// transactions accepted to the mempool, each of which is notified as the
+// result of the passed function.  The subscription ends when the client
+// unsubscribes, the connection is closed or the server is stopped.
+func (s *RpcServer) SubscribeMempoolTxs(ctx context.Context,
+	notification func(tx *types.Tx) (interface{}, error)) (*Subscription, error) {
+	notifier, supported := NotifierFromContext(ctx)
+	if !supported {
+		return nil, ErrNotificationsUnsupported
+	}
+	rpcSub := notifier.CreateSubscription()
+
+	sub := &mempoolTxSub{queue: make(chan *types.Tx, mempoolTxQueueSize)}
+	s.mempoolTxSubsMtx.Lock()
+	s.mempoolTxSubs[sub] = struct{}{}
+	s.mempoolTxSubsMtx.Unlock()
+
+	s.wg.Wrap(func() {
+		defer func() {
+			s.mempoolTxSubsMtx.Lock()
+			delete(s.mempoolTxSubs, sub)
+			s.mempoolTxSubsMtx.Unlock()
+			if dropped := atomic.LoadUint64(&sub.dropped); dropped > 0 {
+				log.Debug("Mempool transactions dropped for a slow subscriber",
+					"subscription", rpcSub.ID, "dropped", dropped)
+			}
+		}()
+		for {
+			select {
+			case tx := <-sub.queue:
+				data, err := notification(tx)
+				if err != nil {
+					log.Warn("Unable to notify a mempool transaction",
+						"tx", tx.Hash(), "err", err)
+					continue
+				}
+				if err := notifier.Notify(rpcSub.ID, data); err != nil {
+					return
+				}
+			case <-rpcSub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			case <-s.quit:
+				return
+			}
+		}
+	})
+	return rpcSub, nil
+}
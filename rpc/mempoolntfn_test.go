@@ -0,0 +1,81 @@
+// Copyright (c) 2017-2019 The qitmeer developers
+//
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/Qitmeer/qitmeer/config"
+	"github.com/Qitmeer/qitmeer/core/types"
+)
+
+// TestMempoolTxSubscription ensures a subscriber is notified of the hash of a
+// transaction accepted to the mempool, and is removed once it unsubscribes.
+func TestMempoolTxSubscription(t *testing.T) {
+	s, err := NewRPCServer(&config.Config{})
+	if err != nil {
+		t.Fatalf("NewRPCServer: %v", err)
+	}
+	r, w := io.Pipe()
+	codec := NewJSONCodec(&httpReadWriteNopCloser{&bytes.Buffer{}, w})
+	notifier := newNotifier(codec)
+	ctx := context.WithValue(context.Background(), notifierKey{}, notifier)
+
+	sub, err := s.SubscribeMempoolTxs(ctx, func(tx *types.Tx) (interface{}, error) {
+		return tx.Hash().String(), nil
+	})
+	if err != nil {
+		t.Fatalf("SubscribeMempoolTxs: %v", err)
+	}
+	notifier.activate(sub.ID, DefaultServiceNameSpace)
+
+	tx := types.NewTx(types.NewTransaction())
+	s.NotifyMempoolTx(tx)
+
+	received := make(chan jsonNotification, 1)
+	go func() {
+		var ntfn jsonNotification
+		if err := json.NewDecoder(r).Decode(&ntfn); err == nil {
+			received <- ntfn
+		}
+	}()
+	select {
+	case ntfn := <-received:
+		if ntfn.Params.Subscription != string(sub.ID) ||
+			ntfn.Params.Result != tx.Hash().String() {
+			t.Errorf("got notification %v, want tx %v", ntfn, tx.Hash())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("accepted tx not notified")
+	}
+
+	if err := notifier.unsubscribe(sub.ID); err != nil {
+		t.Fatalf("unsubscribe: %v", err)
+	}
+	s.wg.Wait()
+	if n := len(s.mempoolTxSubs); n != 0 {
+		t.Errorf("%d subscribers left after unsubscribing", n)
+	}
+}
+
+// TestMempoolTxSubscriptionUnsupported ensures a subscription requires a
+// connection supporting notifications.
+func TestMempoolTxSubscriptionUnsupported(t *testing.T) {
+	s, err := NewRPCServer(&config.Config{})
+	if err != nil {
+		t.Fatalf("NewRPCServer: %v", err)
+	}
+	_, err = s.SubscribeMempoolTxs(context.Background(),
+		func(tx *types.Tx) (interface{}, error) { return nil, nil })
+	if err != ErrNotificationsUnsupported {
+		t.Errorf("got error %v, want %v", err, ErrNotificationsUnsupported)
+	}
+}
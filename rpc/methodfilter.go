@@ -0,0 +1,57 @@
+// Copyright (c) 2017-2019 The qitmeer developers
+//
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"errors"
+)
+
+// errMethodFilterConflict is returned when both an allow-list and a deny-list
+// of methods are configured.
+var errMethodFilterConflict = errors.New("the rpcallow and rpcdeny options " +
+	"can't be used together")
+
+// methodFilter restricts the methods which may be called, either to those of
+// an allow-list or to all but those of a deny-list.  Methods are named as by
+// requestMethod, such as getBlockCount or miner_generate.
+//
+// This type is safe for concurrent access, as it's not modified once created.
+type methodFilter struct {
+	methods map[string]struct{}
+	allow   bool // whether methods is an allow-list rather than a deny-list
+}
+
+// newMethodFilter returns a filter of the passed allowed or denied methods,
+// or nil when neither is configured.  Configuring both is an error.
+func newMethodFilter(allowed, denied []string) (*methodFilter, error) {
+	if len(allowed) > 0 && len(denied) > 0 {
+		return nil, errMethodFilterConflict
+	}
+	methods := denied
+	if len(allowed) > 0 {
+		methods = allowed
+	}
+	if len(methods) == 0 {
+		return nil, nil
+	}
+	mf := &methodFilter{
+		methods: make(map[string]struct{}, len(methods)),
+		allow:   len(allowed) > 0,
+	}
+	for _, method := range methods {
+		mf.methods[method] = struct{}{}
+	}
+	return mf, nil
+}
+
+// allowed reports whether the method may be called.
+func (mf *methodFilter) allowed(method string) bool {
+	if mf == nil {
+		return true
+	}
+	_, listed := mf.methods[method]
+	return listed == mf.allow
+}
@@ -0,0 +1,59 @@
+// Copyright (c) 2017-2019 The qitmeer developers
+//
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Qitmeer/qitmeer/config"
+)
+
+// TestMethodFilter ensures the methods denied by an allow-list or a deny-list
+// are refused before being called, and the others are called.
+func TestMethodFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      *config.Config
+		method   string
+		wantCode int
+	}{
+		{"allow-list allowed", &config.Config{RPCAllowMethods: []string{"echo"}}, "echo", 0},
+		{"allow-list denied", &config.Config{RPCAllowMethods: []string{"echo"}}, "importKey", -32006},
+		{"deny-list allowed", &config.Config{RPCDenyMethods: []string{"importKey"}}, "echo", 0},
+		{"deny-list denied", &config.Config{RPCDenyMethods: []string{"importKey"}}, "importKey", -32006},
+		{"no filter", &config.Config{}, "echo", 0},
+	}
+	for _, test := range tests {
+		request := `{"jsonrpc":"2.0","id":1,"method":"` + test.method + `","params":["a"]}`
+		out := serveTestRequest(t, test.cfg, request)
+		var resp struct {
+			Result string     `json:"result"`
+			Error  *jsonError `json:"error"`
+		}
+		if err := json.Unmarshal([]byte(out), &resp); err != nil {
+			t.Fatalf("%s: invalid response %q: %v", test.name, out, err)
+		}
+		switch {
+		case test.wantCode == 0 && (resp.Error != nil || resp.Result != "a"):
+			t.Errorf("%s: got %q, want the method called", test.name, out)
+		case test.wantCode != 0 && (resp.Error == nil || resp.Error.Code != test.wantCode):
+			t.Errorf("%s: got %q, want error %d", test.name, out, test.wantCode)
+		}
+	}
+}
+
+// TestMethodFilterConflict ensures an allow-list and a deny-list can't be
+// configured together.
+func TestMethodFilterConflict(t *testing.T) {
+	_, err := NewRPCServer(&config.Config{
+		RPCAllowMethods: []string{"echo"},
+		RPCDenyMethods:  []string{"importKey"},
+	})
+	if err != errMethodFilterConflict {
+		t.Errorf("got error %v, want %v", err, errMethodFilterConflict)
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2017-2019 The qitmeer developers
+//
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// rateLimitWindow is the period over which a method limit is measured.
+	// A limit of N allows a burst of N requests which then refills at N
+	// requests per window.
+	rateLimitWindow = time.Second
+)
+
+// tokenBucket tracks the remaining allowance of one client for one method.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter enforces a per-method, per-client token bucket on incoming
+// requests.  Methods without a limit, or with a limit of zero, are unlimited.
+//
+// This type is safe for concurrent access.
+type rateLimiter struct {
+	mtx     sync.Mutex
+	limits  map[string]int
+	window  time.Duration
+	buckets map[string]map[string]*tokenBucket // client -> method -> bucket
+	now     func() time.Time
+}
+
+// newRateLimiter returns a rate limiter for the passed method limits, or nil
+// when no method is limited.
+func newRateLimiter(limits map[string]int, window time.Duration) *rateLimiter {
+	ls := make(map[string]int, len(limits))
+	for method, limit := range limits {
+		if limit > 0 {
+			ls[method] = limit
+		}
+	}
+	if len(ls) == 0 {
+		return nil
+	}
+	return &rateLimiter{
+		limits:  ls,
+		window:  window,
+		buckets: make(map[string]map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// refill tops up the bucket for the time elapsed since it was last used.
+func (rl *rateLimiter) refill(b *tokenBucket, limit int, now time.Time) {
+	elapsed := now.Sub(b.last)
+	if elapsed <= 0 {
+		return
+	}
+	b.tokens += float64(limit) * float64(elapsed) / float64(rl.window)
+	if b.tokens > float64(limit) {
+		b.tokens = float64(limit)
+	}
+	b.last = now
+}
+
+// allow reports whether the client may invoke the method now, consuming one
+// token of its allowance when it may.
+func (rl *rateLimiter) allow(client, method string) bool {
+	if rl == nil {
+		return true
+	}
+	limit, ok := rl.limits[method]
+	if !ok {
+		return true
+	}
+
+	rl.mtx.Lock()
+	defer rl.mtx.Unlock()
+
+	now := rl.now()
+	cbs, ok := rl.buckets[client]
+	if !ok {
+		cbs = make(map[string]*tokenBucket)
+		rl.buckets[client] = cbs
+	}
+	b, ok := cbs[method]
+	if !ok {
+		b = &tokenBucket{tokens: float64(limit), last: now}
+		cbs[method] = b
+	}
+	rl.refill(b, limit, now)
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// release drops the state held for a disconnected client.  Buckets which have
+// not refilled yet are kept, otherwise reconnecting would reset the allowance.
+func (rl *rateLimiter) release(client string) {
+	if rl == nil {
+		return
+	}
+	rl.mtx.Lock()
+	defer rl.mtx.Unlock()
+
+	cbs, ok := rl.buckets[client]
+	if !ok {
+		return
+	}
+	now := rl.now()
+	for method, b := range cbs {
+		limit := rl.limits[method]
+		rl.refill(b, limit, now)
+		if b.tokens >= float64(limit) {
+			delete(cbs, method)
+		}
+	}
+	if len(cbs) == 0 {
+		delete(rl.buckets, client)
+	}
+}
+
+// clientKey returns the key used to identify a client by its remote address.
+// The port is dropped so that every connection of a host shares the limits.
+func clientKey(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
+
+// requestMethod returns the method name of the request as it is called by
+// clients, which is the key of the rate limits.
+func requestMethod(req *serverRequest) string {
+	name := formatName(req.callb.method.Name)
+	if req.svcname == DefaultServiceNameSpace {
+		return name
+	}
+	return req.svcname + serviceMethodSeparator + name
+}
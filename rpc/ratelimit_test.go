@@ -0,0 +1,77 @@
+// Copyright (c) 2017-2019 The qitmeer developers
+//
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"testing"
+	"time"
+)
+
+// TestRateLimiter drives a limited method past its allowance and ensures it is
+// rejected, then allowed again once the window has passed.
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	rl := newRateLimiter(map[string]int{
+		"getBlockTemplate": 2,
+		"getBlockCount":    0,
+	}, time.Second)
+	rl.now = func() time.Time { return now }
+
+	const client = "127.0.0.1"
+	for i := 0; i < 2; i++ {
+		if !rl.allow(client, "getBlockTemplate") {
+			t.Fatalf("request %d rejected within the allowance", i)
+		}
+	}
+	if rl.allow(client, "getBlockTemplate") {
+		t.Fatal("request beyond the allowance was not rejected")
+	}
+
+	// Other clients and unlimited methods are unaffected.
+	if !rl.allow("127.0.0.2", "getBlockTemplate") {
+		t.Fatal("other client was rejected")
+	}
+	for i := 0; i < 10; i++ {
+		if !rl.allow(client, "getBlockCount") {
+			t.Fatal("unlimited method was rejected")
+		}
+	}
+
+	// Disconnecting must not reset an exhausted allowance.
+	rl.release(client)
+	if rl.allow(client, "getBlockTemplate") {
+		t.Fatal("allowance was reset by a reconnect")
+	}
+
+	// Half a window refills one request.
+	now = now.Add(500 * time.Millisecond)
+	if !rl.allow(client, "getBlockTemplate") {
+		t.Fatal("request rejected after the allowance refilled")
+	}
+	if rl.allow(client, "getBlockTemplate") {
+		t.Fatal("allowance refilled beyond the elapsed time")
+	}
+
+	// Once the window has passed the state of the client can be released.
+	now = now.Add(time.Second)
+	rl.release(client)
+	if _, ok := rl.buckets[client]; ok {
+		t.Fatal("state of a released client was not cleaned up")
+	}
+}
+
+// TestRateLimiterUnlimited ensures no limiter is created when every method is
+// unlimited.
+func TestRateLimiterUnlimited(t *testing.T) {
+	if rl := newRateLimiter(map[string]int{"getBlockCount": 0}, time.Second); rl != nil {
+		t.Fatal("expected no limiter for zero limits")
+	}
+	var rl *rateLimiter
+	if !rl.allow("127.0.0.1", "getBlockCount") {
+		t.Fatal("nil limiter rejected a request")
+	}
+	rl.release("127.0.0.1")
+}
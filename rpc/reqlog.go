@@ -0,0 +1,83 @@
+// Copyright (c) 2017-2019 The qitmeer developers
+//
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"github.com/Qitmeer/qitmeer/log"
+	"time"
+)
+
+// defaultRedactedMethods are the methods whose params are never logged, as
+// they are private keys.
+var defaultRedactedMethods = []string{
+	TestNameSpace + serviceMethodSeparator + "txSign",
+}
+
+// requestLogger logs each handled request with its method, client, duration
+// and status.  The params are elided unless their logging is enabled, and even
+// then for the redacted methods, since any of them may be sensitive.
+//
+// This type is safe for concurrent access, as it's not modified once created.
+type requestLogger struct {
+	logParams bool
+	redacted  map[string]struct{}
+	log       func(msg string, ctx ...interface{})
+}
+
+// newRequestLogger returns a request logger, which logs the params when
+// logParams is set except those of the passed methods and of the default ones,
+// or nil when it's not enabled.
+func newRequestLogger(enabled bool, logParams bool, redacted []string) *requestLogger {
+	if !enabled {
+		return nil
+	}
+	rl := &requestLogger{
+		logParams: logParams,
+		redacted:  make(map[string]struct{}),
+		log:       log.Info,
+	}
+	for _, method := range defaultRedactedMethods {
+		rl.redacted[method] = struct{}{}
+	}
+	for _, method := range redacted {
+		rl.redacted[method] = struct{}{}
+	}
+	return rl
+}
+
+// logRequest logs the request handled in the passed duration with the
+// response.
+func (rl *requestLogger) logRequest(ctx context.Context, req *serverRequest,
+	elapsed time.Duration, response interface{}) {
+	client, _ := ctx.Value("remote").(string)
+	method := "unsubscribe"
+	if req.callb != nil {
+		method = requestMethod(req)
+	}
+
+	var params interface{} = "[redacted]"
+	if _, ok := rl.redacted[method]; rl.logParams && !ok {
+		args := make([]interface{}, len(req.args))
+		for i, arg := range req.args {
+			args[i] = arg.Interface()
+		}
+		params = args
+	}
+	rl.log("RPC request", "method", method, "client", client,
+		"params", params, "duration", elapsed, "status",
+		responseStatus(response))
+}
+
+// responseStatus returns the status of a response, which is ok unless it is
+// an error response.
+func responseStatus(response interface{}) string {
+	if resp, ok := response.(*jsonErrResponse); ok {
+		return fmt.Sprintf("error %d", resp.Error.Code)
+	}
+	return "ok"
+}
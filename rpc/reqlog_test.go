@@ -0,0 +1,175 @@
+// Copyright (c) 2017-2019 The qitmeer developers
+//
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/Qitmeer/qitmeer/config"
+)
+
+type logTestService struct{}
+
+func (logTestService) Echo(s string) string {
+	return s
+}
+
+func (logTestService) ImportKey(key string) error {
+	return errors.New("invalid key")
+}
+
+// logEntry is a logged request.
+type logEntry map[string]interface{}
+
+// TestRequestLogger ensures requests are logged with their params when
+// enabled, except those of the redacted methods.
+func TestRequestLogger(t *testing.T) {
+	s, err := NewRPCServer(&config.Config{
+		RPCLogRequests: true,
+		RPCLogParams:   true,
+		RPCLogRedact:   []string{"importKey"},
+	})
+	if err != nil {
+		t.Fatalf("NewRPCServer: %v", err)
+	}
+	if err := s.RegisterService(DefaultServiceNameSpace, logTestService{}); err != nil {
+		t.Fatalf("RegisterService: %v", err)
+	}
+	var (
+		mtx     sync.Mutex
+		entries []logEntry
+	)
+	s.reqLogger.log = func(msg string, ctx ...interface{}) {
+		entry := logEntry{}
+		for i := 0; i+1 < len(ctx); i += 2 {
+			entry[ctx[i].(string)] = ctx[i+1]
+		}
+		mtx.Lock()
+		entries = append(entries, entry)
+		mtx.Unlock()
+	}
+
+	codec := NewJSONCodec(&httpReadWriteNopCloser{&bytes.Buffer{}, &bytes.Buffer{}})
+	ctx := context.WithValue(context.Background(), "remote", "127.0.0.1:1234")
+	call := func(method, param string) {
+		callb := s.rpcSvcRegistry[DefaultServiceNameSpace].callbacks[method]
+		s.handle(ctx, codec, &serverRequest{
+			id:      1,
+			svcname: DefaultServiceNameSpace,
+			callb:   callb,
+			args:    []reflect.Value{reflect.ValueOf(param)},
+		})
+	}
+
+	// The requests are logged concurrently.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			call("echo", "hello")
+		}()
+		go func() {
+			defer wg.Done()
+			call("importKey", "secret")
+		}()
+	}
+	wg.Wait()
+
+	if len(entries) != 16 {
+		t.Fatalf("logged %d requests, want 16", len(entries))
+	}
+	for _, entry := range entries {
+		if entry["client"] != "127.0.0.1:1234" || entry["duration"] == nil {
+			t.Errorf("logged %v", entry)
+		}
+		switch entry["method"] {
+		case "echo":
+			if !reflect.DeepEqual(entry["params"], []interface{}{"hello"}) ||
+				entry["status"] != "ok" {
+				t.Errorf("logged %v, want the params and ok", entry)
+			}
+		case "importKey":
+			if entry["params"] != "[redacted]" ||
+				entry["status"] != "error -32000" {
+				t.Errorf("logged %v, want redacted params and an error", entry)
+			}
+		default:
+			t.Errorf("logged %v", entry)
+		}
+	}
+}
+
+// signTestService has a method taking a private key like the one of the test
+// namespace.
+type signTestService struct{}
+
+func (signTestService) TxSign(privkey string, rawTx string) string {
+	return rawTx
+}
+
+// TestRequestLoggerParams ensures the params of every request are elided
+// unless their logging is enabled, and that those of the methods taking
+// private keys are always elided.
+func TestRequestLoggerParams(t *testing.T) {
+	s, err := NewRPCServer(&config.Config{})
+	if err != nil {
+		t.Fatalf("NewRPCServer: %v", err)
+	}
+	if err := s.RegisterService(DefaultServiceNameSpace, logTestService{}); err != nil {
+		t.Fatalf("RegisterService: %v", err)
+	}
+	if err := s.RegisterService(TestNameSpace, signTestService{}); err != nil {
+		t.Fatalf("RegisterService: %v", err)
+	}
+
+	tests := []struct {
+		logParams bool
+		svcname   string
+		method    string
+		want      interface{}
+	}{
+		{false, DefaultServiceNameSpace, "echo", "[redacted]"},
+		{true, DefaultServiceNameSpace, "echo", []interface{}{"hello"}},
+		{true, TestNameSpace, "txSign", "[redacted]"},
+	}
+	for _, test := range tests {
+		rl := newRequestLogger(true, test.logParams, nil)
+		var params interface{}
+		rl.log = func(msg string, ctx ...interface{}) {
+			for i := 0; i+1 < len(ctx); i += 2 {
+				if ctx[i] == "params" {
+					params = ctx[i+1]
+				}
+			}
+		}
+		callb := s.rpcSvcRegistry[test.svcname].callbacks[test.method]
+		rl.logRequest(context.Background(), &serverRequest{
+			svcname: test.svcname,
+			callb:   callb,
+			args:    []reflect.Value{reflect.ValueOf("hello")},
+		}, 0, nil)
+		if !reflect.DeepEqual(params, test.want) {
+			t.Errorf("%s with logParams %v: logged params %v, want %v",
+				test.method, test.logParams, params, test.want)
+		}
+	}
+}
+
+func TestRequestLoggerDisabled(t *testing.T) {
+	s, err := NewRPCServer(&config.Config{})
+	if err != nil {
+		t.Fatalf("NewRPCServer: %v", err)
+	}
+	if s.reqLogger != nil {
+		t.Errorf("request logger enabled by default")
+	}
+}
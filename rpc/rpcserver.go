@@ -3,6 +3,7 @@
 package rpc
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/base64"
@@ -17,6 +18,7 @@ import (
 	"net/http"
 	"reflect"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -32,11 +34,20 @@ type API struct {
 
 // RpcServer provides a concurrent safe RPC server to a chain server.
 type RpcServer struct {
+	// The following variables must only be used atomically.
+	totalRequests uint64
+	startTime     int64
+	shutdown      int32
+
 	run        int32
 	wg         util.WaitGroupWrapper
 	quit       chan int
 	statusLock sync.RWMutex
 
+	// runMtx guards the stop of the server against the websockets being
+	// added to wg, so that none is added once it stops.
+	runMtx sync.Mutex
+
 	config *config.Config
 
 	rpcSvcRegistry serviceRegistry
@@ -46,11 +57,22 @@ type RpcServer struct {
 
 	authsha                [sha256.Size]byte
 	numClients             int32
+	numWebsockets          int32
+	clientsMtx             sync.Mutex
+	clientsFreed           chan struct{}
 	statusLines            map[int]string
 	requestProcessShutdown chan struct{}
 
 	ReqStatus     map[string]*RequestStatus
 	reqStatusLock sync.RWMutex
+
+	rateLimiter  *rateLimiter
+	methodFilter *methodFilter
+	reqLogger    *requestLogger
+	adminServer  *http.Server
+
+	mempoolTxSubsMtx sync.Mutex
+	mempoolTxSubs    map[*mempoolTxSub]struct{}
 }
 
 // service represents a registered object
@@ -82,17 +104,19 @@ type subscriptions map[string]*callback
 
 // rpcRequest represents a raw incoming RPC request
 type rpcRequest struct {
-	service  string
-	method   string
-	id       interface{}
-	isPubSub bool
-	params   interface{}
-	err      Error // invalid batch element
+	service      string
+	method       string
+	id           interface{}
+	notification bool // request without id, which isn't answered
+	isPubSub     bool
+	params       interface{}
+	err          Error // invalid batch element
 }
 
 // serverRequest is an incoming request
 type serverRequest struct {
 	id            interface{}
+	notification  bool
 	svcname       string
 	callb         *callback
 	args          []reflect.Value
@@ -103,6 +127,10 @@ type serverRequest struct {
 
 // newRPCServer returns a new instance of the rpcServer struct.
 func NewRPCServer(cfg *config.Config) (*RpcServer, error) {
+	filter, err := newMethodFilter(cfg.RPCAllowMethods, cfg.RPCDenyMethods)
+	if err != nil {
+		return nil, err
+	}
 	rpc := RpcServer{
 
 		config: cfg,
@@ -113,7 +141,12 @@ func NewRPCServer(cfg *config.Config) (*RpcServer, error) {
 		statusLines:            make(map[int]string),
 		requestProcessShutdown: make(chan struct{}),
 		quit:                   make(chan int),
+		clientsFreed:           make(chan struct{}),
 		ReqStatus:              map[string]*RequestStatus{},
+		rateLimiter:            newRateLimiter(cfg.RPCRateLimits, rateLimitWindow),
+		methodFilter:           filter,
+		reqLogger:              newRequestLogger(cfg.RPCLogRequests, cfg.RPCLogParams, cfg.RPCLogRedact),
+		mempoolTxSubs:          make(map[*mempoolTxSub]struct{}),
 	}
 
 	if cfg.RPCUser != "" && cfg.RPCPass != "" {
@@ -130,15 +163,25 @@ func (s *RpcServer) Start() error {
 	if err := s.startHTTP(s.config.RPCListeners); err != nil {
 		return err
 	}
-	s.run = 1
+	if err := s.startAdmin(s.config.RPCAdminListeners); err != nil {
+		return err
+	}
+	atomic.StoreInt64(&s.startTime, time.Now().Unix())
+	atomic.StoreInt32(&s.run, 1)
 	return nil
 }
 
 // Stop will stop reading new requests, wait for stopPendingRequestTimeout to allow pending requests to finish,
 // close all codecs which will cancel pending requests/subscriptions.
 func (s *RpcServer) Stop() {
-	if atomic.CompareAndSwapInt32(&s.run, 1, 0) {
+	s.runMtx.Lock()
+	stopping := atomic.CompareAndSwapInt32(&s.run, 1, 0)
+	s.runMtx.Unlock()
+	if stopping {
 		log.Debug("RPC Server is stopping")
+		atomic.StoreInt32(&s.shutdown, 1)
+		close(s.quit)
+		s.stopAdmin()
 		s.codecsMu.Lock()
 		defer s.codecsMu.Unlock()
 		s.codecs.Each(func(c interface{}) bool {
@@ -178,14 +221,16 @@ func (s *RpcServer) startHTTP(listenAddrs []string) error {
 		// Keep track of the number of connected clients.
 		s.incrementClients()
 		defer s.decrementClients()
+		defer s.rateLimiter.release(clientKey(r.RemoteAddr))
 		_, err := s.checkAuth(r, true)
 		if err != nil {
-			jsonAuthFail(w)
+			s.jsonAuthFail(w)
 			return
 		}
 		// Read and respond to the request.
 		s.jsonRPCRead(w, r)
 	})
+	rpcServeMux.HandleFunc(websocketPath, s.handleWebsocket)
 	listeners, err := parseListeners(s.config, listenAddrs)
 	if err != nil {
 		return err
@@ -194,7 +239,7 @@ func (s *RpcServer) startHTTP(listenAddrs []string) error {
 		s.wg.Add(1)
 		go func(listener net.Listener) {
 			log.Info("RPC server listening on ", "addr", listener.Addr())
-			httpServer.Serve(listener)
+			httpServer.Serve(s.throttleListener(listener))
 			log.Trace("RPC listener done for %s", listener.Addr())
 			s.wg.Done()
 		}(listener)
@@ -210,8 +255,8 @@ func (s *RpcServer) limitConnections(w http.ResponseWriter, remoteAddr string) b
 	if int(atomic.LoadInt32(&s.numClients)+1) > s.config.RPCMaxClients {
 		log.Info("RPC clients exceeded", "max", s.config.RPCMaxClients,
 			"client", remoteAddr)
-		http.Error(w, "503 Too busy.  Try again later.",
-			http.StatusServiceUnavailable)
+		s.HTTPError(w, http.StatusServiceUnavailable,
+			"Too busy.  Try again later.")
 		return true
 	}
 	return false
@@ -226,13 +271,15 @@ func (s *RpcServer) incrementClients() {
 	atomic.AddInt32(&s.numClients, 1)
 }
 
-// decrementClients subtracts one from the number of connected RPC clients.
-// Note this only applies to standard clients.  Websocket clients have their
-// own limits and are tracked separately.
+// decrementClients subtracts one from the number of connected RPC clients,
+// and signals the listeners waiting for client capacity.  Note this only
+// applies to standard clients.  Websocket clients have their own limits and
+// are tracked separately.
 //
 // This function is safe for concurrent access.
 func (s *RpcServer) decrementClients() {
 	atomic.AddInt32(&s.numClients, -1)
+	s.signalClientFreed()
 }
 
 // TODO, repalace Basic Authentication
@@ -267,9 +314,66 @@ func (s *RpcServer) checkAuth(r *http.Request, require bool) (bool, error) {
 }
 
 // jsonAuthFail sends a message back to the client if the http auth is rejected.
-func jsonAuthFail(w http.ResponseWriter) {
+func (s *RpcServer) jsonAuthFail(w http.ResponseWriter) {
 	w.Header().Add("WWW-Authenticate", `Basic realm="qitmeer RPC"`)
-	http.Error(w, "401 Unauthorized.", http.StatusUnauthorized)
+	s.HTTPError(w, http.StatusUnauthorized, "")
+}
+
+// statusLine returns a response Status-Line (RFC 2616 Section 6.1) for the
+// given status code.  Lines of known codes are formatted once and then served
+// from the statusLines cache.  Codes which are neither standard nor registered
+// with RegisterStatus get a generic text and are not cached.
+//
+// This function is safe for concurrent access.
+func (s *RpcServer) statusLine(code int) string {
+	s.statusLock.RLock()
+	line, ok := s.statusLines[code]
+	s.statusLock.RUnlock()
+	if ok {
+		return line
+	}
+
+	text := http.StatusText(code)
+	if text == "" {
+		return formatStatusLine(code, "status code "+strconv.Itoa(code))
+	}
+	line = formatStatusLine(code, text)
+	s.statusLock.Lock()
+	s.statusLines[code] = line
+	s.statusLock.Unlock()
+	return line
+}
+
+// RegisterStatus registers the text of a status code, so that RPC extensions
+// can reply with HTTPError with meaningful statuses for non-standard codes.  Registering a
+// standard code replaces its text.
+//
+// This function is safe for concurrent access.
+func (s *RpcServer) RegisterStatus(code int, text string) {
+	line := formatStatusLine(code, text)
+	s.statusLock.Lock()
+	s.statusLines[code] = line
+	s.statusLock.Unlock()
+}
+
+// HTTPError replies to the request with the passed HTTP code and error detail,
+// like http.Error, but the reply starts with the code and its text as the
+// Status-Line has them, so the texts registered with RegisterStatus reach the
+// clients even though the standard library only knows the standard ones.
+//
+// This function is safe for concurrent access.
+func (s *RpcServer) HTTPError(w http.ResponseWriter, code int, detail string) {
+	status := strings.TrimSuffix(strings.TrimPrefix(s.statusLine(code),
+		"HTTP/1.1 "), "\r\n")
+	if detail != "" {
+		status += ": " + detail
+	}
+	http.Error(w, status, code)
+}
+
+// formatStatusLine formats a HTTP/1.1 Status-Line.
+func formatStatusLine(code int, text string) string {
+	return "HTTP/1.1 " + strconv.Itoa(code) + " " + text + "\r\n"
 }
 
 // CodecOption specifies which type of messages this codec supports
@@ -294,8 +398,14 @@ func (s *RpcServer) jsonRPCRead(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// validate request
-	if code, err := validateRequest(r); err != nil {
-		http.Error(w, err.Error(), code)
+	maxSize := s.maxRequestSize()
+	if code, err := validateRequest(r, maxSize); err != nil {
+		s.HTTPError(w, code, err.Error())
+		return
+	}
+	body, code, err := readRequestBody(r, maxSize)
+	if err != nil {
+		s.HTTPError(w, code, err.Error())
 		return
 	}
 	// All checks passed, create a codec that reads direct from the request body
@@ -306,16 +416,23 @@ func (s *RpcServer) jsonRPCRead(w http.ResponseWriter, r *http.Request) {
 	ctx = context.WithValue(ctx, "scheme", r.Proto)
 	ctx = context.WithValue(ctx, "local", r.Host)
 
-	// Read and close the JSON-RPC request body from the caller.
-	body := io.LimitReader(r.Body, maxRequestContentLength)
-	codec := NewJSONCodec(&httpReadWriteNopCloser{body, w})
+	codec := newStreamingJSONCodec(&httpReadWriteNopCloser{bytes.NewReader(body), w})
 	defer codec.Close()
 
-	log.Trace("jsonRPCRead", "body", body, "codec", codec)
+	log.Trace("jsonRPCRead", "size", len(body), "codec", codec)
 
 	s.ServeSingleRequest(ctx, codec, OptionMethodInvocation)
 }
 
+// maxRequestSize returns the max size of a request body, which is the max
+// block payload unless configured.
+func (s *RpcServer) maxRequestSize() int64 {
+	if s.config.RPCMaxRequestSize > 0 {
+		return s.config.RPCMaxRequestSize
+	}
+	return maxRequestContentLength
+}
+
 // ServeSingleRequest reads and processes a single RPC request from the given codec. It will not
 // close the codec unless a non-recoverable error has occurred. Note, this method will return after
 // a single request has been processed!
@@ -426,6 +543,14 @@ func (t *httpReadWriteNopCloser) Close() error {
 	return nil
 }
 
+// Flush sends the data written so far to the client, when the writer supports
+// it, so that streamed results reach the client as they are written.
+func (t *httpReadWriteNopCloser) Flush() {
+	if f, ok := t.Writer.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
 // readRequest requests the next (batch) request from the codec. It will return the collection
 // of requests, an indication if the request was a batch, the invalid request identifier and an
 // error when the request could not be read/parsed.
@@ -495,34 +620,78 @@ func (s *RpcServer) readRequest(codec ServerCodec) ([]*serverRequest, bool, Erro
 
 		requests[i] = &serverRequest{id: r.id, err: &methodNotFoundError{r.service, r.method}}
 	}
+	for i, r := range reqs {
+		requests[i].notification = r.notification
+	}
 
 	return requests, batch, nil
 }
 
 // execBatch executes the given requests and writes the result back using the codec.
-// It will only write the response back when the last request is processed.
+// It will only write the response back when the last request is processed.  The
+// requests are executed concurrently when configured, and the responses are in
+// the order of the requests either way, except for the notifications which
+// aren't answered.  The concurrent requests are executed by at most one
+// worker per processor.
 func (s *RpcServer) execBatch(ctx context.Context, codec ServerCodec, requests []*serverRequest) {
 	responses := make([]interface{}, len(requests))
-	var callbacks []func()
-	for i, req := range requests {
+	callbacks := make([]func(), len(requests))
+	execRequest := func(i int) {
+		req := requests[i]
 		if req.err != nil {
 			responses[i] = codec.CreateErrorResponse(&req.id, req.err)
 		} else {
-			var callback func()
-			if responses[i], callback = s.handle(ctx, codec, req); callback != nil {
-				callbacks = append(callbacks, callback)
-			}
+			responses[i], callbacks[i] = s.handle(ctx, codec, req)
+		}
+	}
+	if s.config.RPCBatchConcurrent {
+		// A fixed number of workers executes the requests, so that a
+		// large batch doesn't start a goroutine per request.
+		workers := runtime.NumCPU()
+		if workers > len(requests) {
+			workers = len(requests)
+		}
+		indexes := make(chan int)
+		var wg sync.WaitGroup
+		wg.Add(workers)
+		for w := 0; w < workers; w++ {
+			go func() {
+				defer wg.Done()
+				for i := range indexes {
+					execRequest(i)
+				}
+			}()
+		}
+		for i := range requests {
+			indexes <- i
+		}
+		close(indexes)
+		wg.Wait()
+	} else {
+		for i := range requests {
+			execRequest(i)
 		}
 	}
 
-	if err := codec.Write(responses); err != nil {
-		log.Error(fmt.Sprintf("%v\n", err))
-		codec.Close()
+	// A batch of notifications only isn't answered at all.
+	var answers []interface{}
+	for i, req := range requests {
+		if !req.notification {
+			answers = append(answers, responses[i])
+		}
+	}
+	if len(answers) > 0 {
+		if err := codec.Write(answers); err != nil {
+			log.Error(fmt.Sprintf("%v\n", err))
+			codec.Close()
+		}
 	}
 
 	// when request holds one of more subscribe requests this allows these subscriptions to be activated
 	for _, c := range callbacks {
-		c()
+		if c != nil {
+			c()
+		}
 	}
 }
 
@@ -536,9 +705,12 @@ func (s *RpcServer) exec(ctx context.Context, codec ServerCodec, req *serverRequ
 		response, callback = s.handle(ctx, codec, req)
 	}
 
-	if err := codec.Write(response); err != nil {
-		log.Error(fmt.Sprintf("%v\n", err))
-		codec.Close()
+	// A notification isn't answered.
+	if !req.notification {
+		if err := codec.Write(response); err != nil {
+			log.Error(fmt.Sprintf("%v\n", err))
+			codec.Close()
+		}
 	}
 
 	// when request was a subscribe request this allows these subscriptions to be actived
@@ -547,8 +719,21 @@ func (s *RpcServer) exec(ctx context.Context, codec ServerCodec, req *serverRequ
 	}
 }
 
-// handle executes a request and returns the response from the callback.
+// handle executes a request and returns the response from the callback,
+// logging the request when enabled.
 func (s *RpcServer) handle(ctx context.Context, codec ServerCodec, req *serverRequest) (interface{}, func()) {
+	if s.reqLogger == nil {
+		return s.handleRequest(ctx, codec, req)
+	}
+	start := time.Now()
+	response, callback := s.handleRequest(ctx, codec, req)
+	s.reqLogger.logRequest(ctx, req, time.Since(start), response)
+	return response, callback
+}
+
+// handleRequest executes a request and returns the response from the callback.
+func (s *RpcServer) handleRequest(ctx context.Context, codec ServerCodec, req *serverRequest) (interface{}, func()) {
+	atomic.AddUint64(&s.totalRequests, 1)
 	if req.err != nil {
 		return codec.CreateErrorResponse(&req.id, req.err), nil
 	}
@@ -570,10 +755,20 @@ func (s *RpcServer) handle(ctx context.Context, codec ServerCodec, req *serverRe
 		return codec.CreateErrorResponse(&req.id, &invalidParamsError{"Expected subscription id as first argument"}), nil
 	}
 
+	method := requestMethod(req)
+	if !s.methodFilter.allowed(method) {
+		return codec.CreateErrorResponse(&req.id, &methodNotAllowedError{method}), nil
+	}
+	if remote, ok := ctx.Value("remote").(string); ok {
+		if !s.rateLimiter.allow(clientKey(remote), method) {
+			return codec.CreateErrorResponse(&req.id, &rateLimitedError{method}), nil
+		}
+	}
+
 	if req.callb.isSubscribe {
 		subid, err := s.createSubscription(ctx, codec, req)
 		if err != nil {
-			return codec.CreateErrorResponse(&req.id, &callbackError{err.Error()}), nil
+			return codec.CreateErrorResponse(&req.id, toRPCError(err)), nil
 		}
 
 		// active the subscription after the sub id was successfully sent to the client
@@ -593,6 +788,15 @@ func (s *RpcServer) handle(ctx context.Context, codec ServerCodec, req *serverRe
 		return codec.CreateErrorResponse(&req.id, rpcErr), nil
 	}
 
+	// The request is answered with a timeout error beyond the timeout, and
+	// its context is cancelled.  The handlers don't honour the context
+	// yet, so it is disabled by default.
+	if timeout := s.config.RPCRequestTimeout; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
 	arguments := []reflect.Value{req.callb.receiver}
 	if req.callb.hasCtx {
 		arguments = append(arguments, reflect.ValueOf(ctx))
@@ -602,22 +806,73 @@ func (s *RpcServer) handle(ctx context.Context, codec ServerCodec, req *serverRe
 	}
 
 	s.AddRequstStatus(req)
-	// execute RPC method and return result
-	reply := req.callb.method.Func.Call(arguments)
-	s.RemoveRequstStatus(req)
+	// execute RPC method and return result, the request status is removed
+	// once the handler returns, which may be after its timeout.
+	reply, err := callHandler(ctx, req.callb, arguments, func() {
+		s.RemoveRequstStatus(req)
+	})
+	if err != nil {
+		return codec.CreateErrorResponse(&req.id, err), nil
+	}
 	if len(reply) == 0 {
 		return codec.CreateResponse(req.id, nil), nil
 	}
 	if req.callb.errPos >= 0 { // test if method returned an error
 		if !reply[req.callb.errPos].IsNil() {
 			e := reply[req.callb.errPos].Interface().(error)
-			res := codec.CreateErrorResponse(&req.id, &callbackError{e.Error()})
+			res := codec.CreateErrorResponse(&req.id, toRPCError(e))
 			return res, nil
 		}
 	}
 	return codec.CreateResponse(req.id, reply[0].Interface()), nil
 }
 
+// handlerResult is the result of a handler call, or the value it panicked with.
+type handlerResult struct {
+	reply []reflect.Value
+	panic interface{}
+}
+
+// callHandler calls the passed callback with the arguments and returns its
+// reply, and calls returned once the callback returns.  When the context has a
+// deadline, the callback runs in its own goroutine so that an error is
+// returned as soon as the context is done, even if the callback ignores it.  A
+// panic of the callback is propagated to the caller.
+//
+// The callback isn't stopped at the deadline though, so the handlers must
+// honour the context they are passed, or else a timed out request keeps its
+// goroutine until the handler returns, and shows in the request status until
+// then.
+func callHandler(ctx context.Context, callb *callback, arguments []reflect.Value, returned func()) ([]reflect.Value, Error) {
+	if _, ok := ctx.Deadline(); !ok {
+		defer returned()
+		return callb.method.Func.Call(arguments), nil
+	}
+
+	done := make(chan handlerResult, 1)
+	go func() {
+		defer returned()
+		defer func() {
+			if err := recover(); err != nil {
+				done <- handlerResult{panic: err}
+			}
+		}()
+		done <- handlerResult{reply: callb.method.Func.Call(arguments)}
+	}()
+	select {
+	case result := <-done:
+		if result.panic != nil {
+			panic(result.panic)
+		}
+		return result.reply, nil
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, &requestTimeoutError{}
+		}
+		return nil, &callbackError{ctx.Err().Error()}
+	}
+}
+
 // createSubscription will call the subscription callback and returns the subscription id or error.
 func (s *RpcServer) createSubscription(ctx context.Context, c ServerCodec, req *serverRequest) (ID, error) {
 	// subscription have as first argument the context following optional arguments
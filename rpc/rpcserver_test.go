@@ -0,0 +1,305 @@
+// Copyright (c) 2017-2019 The qitmeer developers
+//
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/Qitmeer/qitmeer/config"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestStatusLine ensures status lines are formatted for standard, registered
+// and unknown codes.
+func TestStatusLine(t *testing.T) {
+	s, err := NewRPCServer(&config.Config{})
+	if err != nil {
+		t.Fatalf("NewRPCServer: %v", err)
+	}
+
+	tests := []struct {
+		code int
+		want string
+	}{
+		{http.StatusOK, "HTTP/1.1 200 OK\r\n"},
+		{http.StatusServiceUnavailable, "HTTP/1.1 503 Service Unavailable\r\n"},
+		{599, "HTTP/1.1 599 status code 599\r\n"},
+	}
+	for _, test := range tests {
+		if got := s.statusLine(test.code); got != test.want {
+			t.Errorf("statusLine(%d): got %q, want %q", test.code, got, test.want)
+		}
+	}
+
+	s.RegisterStatus(599, "Chain Not Synced")
+	if got, want := s.statusLine(599), "HTTP/1.1 599 Chain Not Synced\r\n"; got != want {
+		t.Errorf("registered statusLine: got %q, want %q", got, want)
+	}
+}
+
+// TestStatusLineConcurrent hammers statusLine from many goroutines while
+// registering codes to ensure the cache is accessed safely.
+func TestStatusLineConcurrent(t *testing.T) {
+	s, err := NewRPCServer(&config.Config{})
+	if err != nil {
+		t.Fatalf("NewRPCServer: %v", err)
+	}
+
+	codes := []int{http.StatusOK, http.StatusNotFound, http.StatusUnauthorized, 598, 599}
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				code := codes[(i+j)%len(codes)]
+				if j%100 == 0 && code >= 598 {
+					s.RegisterStatus(code, "Custom")
+				}
+				if s.statusLine(code) == "" {
+					t.Errorf("empty status line for %d", code)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got, want := s.statusLine(http.StatusNotFound), "HTTP/1.1 404 Not Found\r\n"; got != want {
+		t.Errorf("statusLine: got %q, want %q", got, want)
+	}
+}
+
+// TestHTTPError ensures the HTTP errors of the server start with the
+// Status-Line text of their code, including the registered ones.
+func TestHTTPError(t *testing.T) {
+	s := newTestServer(t, &config.Config{})
+	s.RegisterStatus(599, "Chain Not Synced")
+
+	tests := []struct {
+		code   int
+		detail string
+		want   string
+	}{
+		{http.StatusServiceUnavailable, "Too busy.",
+			"503 Service Unavailable: Too busy.\n"},
+		{http.StatusUnauthorized, "", "401 Unauthorized\n"},
+		{599, "Try again later.", "599 Chain Not Synced: Try again later.\n"},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		s.HTTPError(w, test.code, test.detail)
+		if w.Code != test.code || w.Body.String() != test.want {
+			t.Errorf("got %d %q, want %d %q", w.Code, w.Body.String(),
+				test.code, test.want)
+		}
+	}
+}
+
+type timeoutTestService struct {
+	cancelled chan struct{}
+}
+
+// Wait returns once its context is cancelled.
+func (svc *timeoutTestService) Wait(ctx context.Context) error {
+	<-ctx.Done()
+	close(svc.cancelled)
+	return ctx.Err()
+}
+
+// newTestServer returns a running server with the test services.
+func newTestServer(t *testing.T, cfg *config.Config) *RpcServer {
+	s, err := NewRPCServer(cfg)
+	if err != nil {
+		t.Fatalf("NewRPCServer: %v", err)
+	}
+	if err := s.RegisterService(DefaultServiceNameSpace, logTestService{}); err != nil {
+		t.Fatalf("RegisterService: %v", err)
+	}
+	atomic.StoreInt32(&s.run, 1)
+	return s
+}
+
+// serveTestRequest serves the passed request with a new server and returns
+// the response written.
+func serveTestRequest(t *testing.T, cfg *config.Config, request string) string {
+	s := newTestServer(t, cfg)
+
+	var out bytes.Buffer
+	codec := NewJSONCodec(&httpReadWriteNopCloser{strings.NewReader(request), &out})
+	s.ServeSingleRequest(context.Background(), codec, OptionMethodInvocation)
+	return out.String()
+}
+
+// TestBatchRequest ensures the elements of a batch are answered in order with
+// their ids, except for the notifications, and that an error in one element
+// doesn't fail the others.
+func TestBatchRequest(t *testing.T) {
+	const batch = `[
+		{"jsonrpc":"2.0","id":1,"method":"echo","params":["hello"]},
+		{"jsonrpc":"2.0","id":"two","method":"importKey","params":["secret"]},
+		{"jsonrpc":"2.0","method":"echo","params":["notification"]},
+		{"jsonrpc":"2.0","id":{},"method":"echo","params":["bad id"]},
+		{"jsonrpc":"2.0","id":3,"method":"unknown"},
+		{"jsonrpc":"2.0","id":4,"method":"echo","params":["world"]}
+	]`
+	want := []map[string]interface{}{
+		{"jsonrpc": "2.0", "id": 1.0, "result": "hello"},
+		{"jsonrpc": "2.0", "id": "two", "error": map[string]interface{}{
+			"code": -32000.0, "message": "invalid key"}},
+		{"jsonrpc": "2.0", "id": nil, "error": map[string]interface{}{
+			"code": -32700.0, "message": "invalid request id"}},
+		{"jsonrpc": "2.0", "id": 3.0, "error": map[string]interface{}{
+			"code": -32601.0, "message": "The method unknown does not exist/is not available"}},
+		{"jsonrpc": "2.0", "id": 4.0, "result": "world"},
+	}
+
+	for _, concurrent := range []bool{false, true} {
+		out := serveTestRequest(t, &config.Config{RPCBatchConcurrent: concurrent}, batch)
+		var got []map[string]interface{}
+		if err := json.Unmarshal([]byte(out), &got); err != nil {
+			t.Fatalf("concurrent %v: invalid response %q: %v", concurrent, out, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("concurrent %v: got %v, want %v", concurrent, got, want)
+		}
+	}
+}
+
+// TestNotificationRequest ensures notifications aren't answered, alone or in
+// a batch.
+func TestNotificationRequest(t *testing.T) {
+	requests := []string{
+		`{"jsonrpc":"2.0","method":"echo","params":["hello"]}`,
+		`[{"jsonrpc":"2.0","method":"echo","params":["hello"]},
+		  {"jsonrpc":"2.0","method":"importKey","params":["secret"]}]`,
+	}
+	for _, request := range requests {
+		if out := serveTestRequest(t, &config.Config{}, request); out != "" {
+			t.Errorf("notification %s answered with %q", request, out)
+		}
+	}
+}
+
+// TestRequestTooLarge ensures a request body larger than the max size is
+// refused with a 413, whether its content length is known or not.
+func TestRequestTooLarge(t *testing.T) {
+	const maxSize = 64
+	s := newTestServer(t, &config.Config{RPCMaxRequestSize: maxSize})
+
+	request := `{"jsonrpc":"2.0","id":1,"method":"echo","params":["hello"]}`
+	large := `{"jsonrpc":"2.0","id":1,"method":"echo","params":["` +
+		strings.Repeat("a", maxSize) + `"]}`
+	tests := []struct {
+		body          string
+		contentLength int64
+		want          int
+	}{
+		{request, int64(len(request)), http.StatusOK},
+		{large, int64(len(large)), http.StatusRequestEntityTooLarge},
+		{large, -1, http.StatusRequestEntityTooLarge},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(test.body))
+		r.Header.Set("Content-Type", contentType)
+		r.ContentLength = test.contentLength
+		w := httptest.NewRecorder()
+		s.jsonRPCRead(w, r)
+		if w.Code != test.want {
+			t.Errorf("body of %d bytes, content length %d: got status %d, want %d",
+				len(test.body), test.contentLength, w.Code, test.want)
+		}
+	}
+}
+
+// TestRequestTimeout ensures a handler exceeding the request timeout is
+// answered with a timeout error and has its context cancelled.
+func TestRequestTimeout(t *testing.T) {
+	s := newTestServer(t, &config.Config{RPCRequestTimeout: 50 * time.Millisecond})
+	svc := &timeoutTestService{cancelled: make(chan struct{})}
+	if err := s.RegisterService(TestNameSpace, svc); err != nil {
+		t.Fatalf("RegisterService: %v", err)
+	}
+
+	var out bytes.Buffer
+	request := `{"jsonrpc":"2.0","id":1,"method":"test_wait","params":[]}`
+	codec := NewJSONCodec(&httpReadWriteNopCloser{strings.NewReader(request), &out})
+	s.ServeSingleRequest(context.Background(), codec, OptionMethodInvocation)
+
+	var resp jsonErrResponse
+	if err := json.Unmarshal(out.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response %q: %v", out.String(), err)
+	}
+	if resp.Error.Message != (&requestTimeoutError{}).Error() {
+		t.Errorf("got error %v, want a timeout", resp.Error)
+	}
+	select {
+	case <-svc.cancelled:
+	case <-time.After(5 * time.Second):
+		t.Errorf("handler context not cancelled")
+	}
+}
+
+// TestAcceptWebsocketAfterStop ensures no websocket is added to the wait group
+// of the server once it stops, so that waiting for it can't race with them.
+func TestAcceptWebsocketAfterStop(t *testing.T) {
+	s := newTestServer(t, &config.Config{})
+	if !s.acceptWebsocket() {
+		t.Fatal("websocket refused by a running server")
+	}
+	s.wg.Done()
+
+	s.Stop()
+	if s.acceptWebsocket() {
+		s.wg.Done()
+		t.Error("websocket accepted by a stopped server")
+	}
+	s.wg.Wait()
+}
+
+// TestLimitWebsockets ensures concurrent websockets can't reserve more slots
+// than allowed, and that a refused upgrade releases its slot.
+func TestLimitWebsockets(t *testing.T) {
+	const maxWebsockets = 3
+	s := newTestServer(t, &config.Config{RPCMaxWebsockets: maxWebsockets})
+
+	var wg sync.WaitGroup
+	var accepted int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !s.limitWebsockets(httptest.NewRecorder(), "127.0.0.1:1") {
+				atomic.AddInt32(&accepted, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if accepted != maxWebsockets || s.numWebsockets != maxWebsockets {
+		t.Fatalf("got %d websockets accepted and %d counted, want %d",
+			accepted, s.numWebsockets, maxWebsockets)
+	}
+	atomic.AddInt32(&s.numWebsockets, -1)
+
+	// The unauthenticated websocket is refused after it reserved the last
+	// slot, which it releases.
+	w := httptest.NewRecorder()
+	s.handleWebsocket(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("got status %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+	if n := atomic.LoadInt32(&s.numWebsockets); n != maxWebsockets-1 {
+		t.Errorf("got %d websockets counted, want %d", n, maxWebsockets-1)
+	}
+}
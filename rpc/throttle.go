@@ -0,0 +1,80 @@
+// Copyright (c) 2017-2019 The qitmeer developers
+//
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"errors"
+	"net"
+	"sync/atomic"
+)
+
+// errListenerStopped is returned by the accept of a throttled listener when
+// the server is stopped.
+var errListenerStopped = errors.New("rpc server stopped")
+
+// throttledListener is a net.Listener which doesn't accept connections while
+// the server has its max number of standard clients, so that they wait in the
+// backlog instead of being accepted only to be refused.  Accepting resumes
+// when a client disconnects.
+//
+// The clients are counted once their request is handled, so a burst of
+// connections can still exceed the max, which limitConnections then refuses.
+type throttledListener struct {
+	net.Listener
+	server *RpcServer
+}
+
+// throttleListener returns the passed listener throttled by the number of
+// clients of the server.
+func (s *RpcServer) throttleListener(l net.Listener) net.Listener {
+	return &throttledListener{Listener: l, server: s}
+}
+
+// Accept waits for the server to have less than its max clients, and then for
+// the next connection.  It returns errListenerStopped when the server is
+// stopped while waiting.
+func (l *throttledListener) Accept() (net.Conn, error) {
+	if !l.server.waitClientCapacity() {
+		return nil, errListenerStopped
+	}
+	return l.Listener.Accept()
+}
+
+// waitClientCapacity waits until the number of standard clients is less than
+// the max, without polling, as decrementClients signals each disconnection.
+// It returns false when the server is stopped while waiting.
+//
+// This function is safe for concurrent access.
+func (s *RpcServer) waitClientCapacity() bool {
+	max := s.config.RPCMaxClients
+	for {
+		s.clientsMtx.Lock()
+		if max <= 0 || int(atomic.LoadInt32(&s.numClients)) < max {
+			s.clientsMtx.Unlock()
+			return true
+		}
+		// The channel is taken with the number of clients checked, so
+		// that a disconnection after the check closes it.
+		freed := s.clientsFreed
+		s.clientsMtx.Unlock()
+
+		select {
+		case <-freed:
+		case <-s.quit:
+			return false
+		}
+	}
+}
+
+// signalClientFreed wakes up the listeners waiting for client capacity.
+//
+// This function is safe for concurrent access.
+func (s *RpcServer) signalClientFreed() {
+	s.clientsMtx.Lock()
+	close(s.clientsFreed)
+	s.clientsFreed = make(chan struct{})
+	s.clientsMtx.Unlock()
+}
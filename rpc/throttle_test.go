@@ -0,0 +1,98 @@
+// Copyright (c) 2017-2019 The qitmeer developers
+//
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/Qitmeer/qitmeer/config"
+)
+
+// acceptResult is the result of an Accept of a throttled listener.
+type acceptResult struct {
+	conn net.Conn
+	err  error
+}
+
+func acceptAsync(l net.Listener) <-chan acceptResult {
+	c := make(chan acceptResult, 1)
+	go func() {
+		conn, err := l.Accept()
+		c <- acceptResult{conn, err}
+	}()
+	return c
+}
+
+// TestThrottledListener ensures a connection isn't accepted while the server
+// has its max clients, and is once a client disconnects.
+func TestThrottledListener(t *testing.T) {
+	const maxClients = 2
+	s, err := NewRPCServer(&config.Config{RPCMaxClients: maxClients})
+	if err != nil {
+		t.Fatalf("NewRPCServer: %v", err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer ln.Close()
+	l := s.throttleListener(ln)
+
+	for i := 0; i < maxClients; i++ {
+		s.incrementClients()
+	}
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial: %v", err)
+	}
+	defer client.Close()
+
+	accepted := acceptAsync(l)
+	select {
+	case r := <-accepted:
+		t.Fatalf("accepted with %d clients: %v", maxClients, r.err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	s.decrementClients()
+	select {
+	case r := <-accepted:
+		if r.err != nil {
+			t.Fatalf("Accept: %v", r.err)
+		}
+		r.conn.Close()
+	case <-time.After(5 * time.Second):
+		t.Fatalf("not accepted once a client disconnected")
+	}
+}
+
+// TestThrottledListenerStop ensures a listener waiting for client capacity
+// stops when the server stops.
+func TestThrottledListenerStop(t *testing.T) {
+	s, err := NewRPCServer(&config.Config{RPCMaxClients: 1})
+	if err != nil {
+		t.Fatalf("NewRPCServer: %v", err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer ln.Close()
+
+	s.incrementClients()
+	accepted := acceptAsync(s.throttleListener(ln))
+	close(s.quit)
+	select {
+	case r := <-accepted:
+		if r.err != errListenerStopped {
+			t.Errorf("got error %v, want %v", r.err, errListenerStopped)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Accept didn't return once the server stopped")
+	}
+}
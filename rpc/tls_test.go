@@ -0,0 +1,140 @@
+// Copyright (c) 2017-2019 The qitmeer developers
+//
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Qitmeer/qitmeer/config"
+)
+
+// testCert returns a certificate of the passed name signed by the parent, or
+// self-signed when the parent is nil.
+func testCert(t *testing.T, name string, isCA bool, parent *tls.Certificate) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if isCA {
+		template.KeyUsage |= x509.KeyUsageCertSign
+	}
+	signer, signerKey := template, interface{}(key)
+	if parent != nil {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer,
+		&key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// TestClientCertAuth ensures that with client authentication, only the clients
+// with a certificate signed by the client CA reach the handler.
+func TestClientCertAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rpctls")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	cfg := &config.Config{
+		RPCCert:       filepath.Join(dir, "rpc.cert"),
+		RPCKey:        filepath.Join(dir, "rpc.key"),
+		RPCClientCA:   filepath.Join(dir, "ca.cert"),
+		RPCClientAuth: true,
+	}
+	if err := genCertPair(cfg.RPCCert, cfg.RPCKey); err != nil {
+		t.Fatalf("genCertPair: %v", err)
+	}
+	ca := testCert(t, "ca", true, nil)
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE",
+		Bytes: ca.Certificate[0]})
+	if err := ioutil.WriteFile(cfg.RPCClientCA, caPEM, 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	tlsConfig, err := rpcTLSConfig(cfg)
+	if err != nil {
+		t.Fatalf("rpcTLSConfig: %v", err)
+	}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	var handled int32
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&handled, 1)
+		}),
+	}
+	go server.Serve(ln)
+	defer server.Close()
+
+	get := func(certs ...tls.Certificate) error {
+		client := &http.Client{
+			Timeout: 5 * time.Second,
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{
+				Certificates:       certs,
+				InsecureSkipVerify: true,
+			}},
+		}
+		resp, err := client.Get("https://" + ln.Addr().(*net.TCPAddr).String())
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+
+	if err := get(testCert(t, "client", false, &ca)); err != nil {
+		t.Errorf("client signed by the CA: %v", err)
+	}
+	if err := get(testCert(t, "untrusted", false, nil)); err == nil {
+		t.Errorf("untrusted client succeeded")
+	}
+	if err := get(); err == nil {
+		t.Errorf("client without certificate succeeded")
+	}
+	if n := atomic.LoadInt32(&handled); n != 1 {
+		t.Errorf("handled %d requests, want 1", n)
+	}
+
+	// Client authentication requires a CA.
+	cfg.RPCClientCA = ""
+	if _, err := rpcTLSConfig(cfg); err == nil {
+		t.Errorf("rpcTLSConfig without client CA succeeded")
+	}
+}
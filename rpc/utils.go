@@ -16,6 +16,7 @@ import (
 	"crypto/elliptic"
 	crand "crypto/rand"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
@@ -251,19 +252,14 @@ func parseListeners(cfg *config.Config, addrs []string) ([]net.Listener, error)
 				return nil, err
 			}
 		}
-		keypair, err := tls.LoadX509KeyPair(cfg.RPCCert, cfg.RPCKey)
+		tlsConfig, err := rpcTLSConfig(cfg)
 		if err != nil {
 			return nil, err
 		}
 
-		tlsConfig := tls.Config{
-			Certificates: []tls.Certificate{keypair},
-			MinVersion:   tls.VersionTLS12,
-		}
-
 		// Change the standard net.Listen function to the tls one.
 		listenFunc = func(net string, laddr string) (net.Listener, error) {
-			return tls.Listen(net, laddr, &tlsConfig)
+			return tls.Listen(net, laddr, tlsConfig)
 		}
 	}
 	listeners := make([]net.Listener, 0, len(ipListenAddrs))
@@ -284,6 +280,43 @@ func parseListeners(cfg *config.Config, addrs []string) ([]net.Listener, error)
 }
 
 // genCertPair generates a key/cert pair to the paths provided.
+// rpcTLSConfig returns the TLS config of the RPC listeners.  When a client CA
+// file is configured, the certificates of the clients are verified against
+// it during the handshake, so that the connections of untrusted clients fail
+// before any request is handled, and a certificate is required with
+// RPCClientAuth.
+func rpcTLSConfig(cfg *config.Config) (*tls.Config, error) {
+	keypair, err := tls.LoadX509KeyPair(cfg.RPCCert, cfg.RPCKey)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{keypair},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if cfg.RPCClientCA == "" {
+		if cfg.RPCClientAuth {
+			return nil, fmt.Errorf("rpcclientauth requires rpcclientca")
+		}
+		return tlsConfig, nil
+	}
+	pem, err := ioutil.ReadFile(cfg.RPCClientCA)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig.ClientCAs = x509.NewCertPool()
+	if !tlsConfig.ClientCAs.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificate in the RPC client CA "+
+			"file %s", cfg.RPCClientCA)
+	}
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	if cfg.RPCClientAuth {
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
 func genCertPair(certFile, keyFile string) error {
 	log.Info("Generating TLS certificates...")
 
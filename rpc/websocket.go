@@ -0,0 +1,116 @@
+// Copyright (c) 2017-2019 The qitmeer developers
+//
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+// The parts code inspired by
+// https://github.com/ethereum/go-ethereum/rpc
+
+package rpc
+
+import (
+	"context"
+	"github.com/Qitmeer/qitmeer/log"
+	"golang.org/x/net/websocket"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// websocketPath is the path of the websocket endpoint on the RPC listeners.
+const websocketPath = "/ws"
+
+// handleWebsocket upgrades an authenticated request to a websocket connection
+// which serves method calls and subscriptions until the client disconnects or
+// the server stops.
+func (s *RpcServer) handleWebsocket(w http.ResponseWriter, r *http.Request) {
+	// Limit the number of websockets to max allowed.  The slot is
+	// reserved before the upgrade, and released once it fails or the
+	// websocket disconnects.
+	if s.limitWebsockets(w, r.RemoteAddr) {
+		return
+	}
+	defer atomic.AddInt32(&s.numWebsockets, -1)
+	defer s.rateLimiter.release(clientKey(r.RemoteAddr))
+	if _, err := s.checkAuth(r, true); err != nil {
+		s.jsonAuthFail(w)
+		return
+	}
+
+	// The websocket is added to the wait group before the upgrade, unless
+	// the server is stopping.
+	if !s.acceptWebsocket() {
+		s.HTTPError(w, http.StatusServiceUnavailable,
+			"Server is stopping.")
+		return
+	}
+	defer s.wg.Done()
+
+	ws := websocket.Server{
+		// The request is authenticated, so any origin is accepted.
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(conn *websocket.Conn) {
+			// Websockets are long lived, so clear the deadline of the
+			// initial handshake.
+			conn.SetDeadline(time.Time{})
+
+			ctx := context.WithValue(r.Context(), "remote", r.RemoteAddr)
+			ctx = context.WithValue(ctx, "scheme", "ws")
+			ctx = context.WithValue(ctx, "local", r.Host)
+
+			// Closing the codec stops serving it, which cancels every
+			// subscription of the connection.
+			codec := NewJSONCodec(conn)
+			defer codec.Close()
+			go func() {
+				select {
+				case <-s.quit:
+					codec.Close()
+				case <-codec.Closed():
+				}
+			}()
+
+			log.Debug("RPC websocket connected", "from", r.RemoteAddr)
+			s.serveRequest(ctx, codec, false, OptionMethodInvocation|OptionSubscriptions)
+			log.Debug("RPC websocket disconnected", "from", r.RemoteAddr)
+		},
+	}
+	ws.ServeHTTP(w, r)
+}
+
+// acceptWebsocket adds a websocket to the wait group of the server and returns
+// true, unless the server is stopping.  The check and the addition are guarded
+// against Stop, so no websocket is added to the wait group once it stops.
+//
+// This function is safe for concurrent access.
+func (s *RpcServer) acceptWebsocket() bool {
+	s.runMtx.Lock()
+	defer s.runMtx.Unlock()
+	if atomic.LoadInt32(&s.run) != 1 {
+		return false
+	}
+	s.wg.Add(1)
+	return true
+}
+
+// limitWebsockets responds with a 503 service unavailable and returns true if
+// adding another websocket client would exceed the maximum allowed.  Otherwise
+// the websocket is counted, so concurrent upgrades can't exceed the maximum,
+// and the caller must release it by decrementing numWebsockets.
+//
+// This function is safe for concurrent access.
+func (s *RpcServer) limitWebsockets(w http.ResponseWriter, remoteAddr string) bool {
+	for {
+		n := atomic.LoadInt32(&s.numWebsockets)
+		if int(n+1) > s.config.RPCMaxWebsockets {
+			log.Info("RPC websocket clients exceeded", "max",
+				s.config.RPCMaxWebsockets, "client", remoteAddr)
+			s.HTTPError(w, http.StatusServiceUnavailable,
+				"Too busy.  Try again later.")
+			return true
+		}
+		if atomic.CompareAndSwapInt32(&s.numWebsockets, n, n+1) {
+			return false
+		}
+	}
+}
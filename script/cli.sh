@@ -257,6 +257,18 @@ function get_block_template(){
   get_result "$data"
 }
 
+function get_block_template_delta(){
+  local templateid=$1
+  local data='{"jsonrpc":"2.0","method":"getBlockTemplateDelta","params":[[],"'$templateid'"],"id":1}'
+  get_result "$data"
+}
+
+function decode_block_template(){
+  local template=$1
+  local data=$(jq -nc --arg t "$template" '{"jsonrpc":"2.0","method":"decodeBlockTemplate","params":[$t],"id":1}')
+  get_result "$data"
+}
+
 function get_mainchain_height(){
   local data='{"jsonrpc":"2.0","method":"getMainChainHeight","params":[],"id":1}'
   get_result "$data"
@@ -482,6 +494,8 @@ function usage(){
   echo "  getutxo <tx_id> <index> <include_mempool,default=true>"
   echo "miner  :"
   echo "  template"
+  echo "  templatedelta <templateid>"
+  echo "  decodetemplate <template_json|block_hex>"
   echo "  generate <num>"
 }
 
@@ -754,6 +768,14 @@ elif [ "$1" == "template" ]; then
     shift
     get_block_template $1 | jq .
 
+elif [ "$1" == "templatedelta" ]; then
+    shift
+    get_block_template_delta $1 | jq .
+
+elif [ "$1" == "decodetemplate" ]; then
+    shift
+    decode_block_template "$1" | jq .
+
 elif [ "$1" == "mainHeight" ]; then
     shift
     get_mainchain_height
@@ -73,6 +73,11 @@ type BlockManager struct {
 	//block template cache
 	cachedCurrentTemplate *types.BlockTemplate
 	cachedParentTemplate  *types.BlockTemplate
+	templateSubs          map[chan *types.BlockTemplate]struct{}
+	templateSubsMtx       sync.Mutex
+
+	// fee estimator fed with the transactions of connected blocks
+	feeEstimator *mempool.FeeEstimator
 
 	lastProgressTime time.Time
 
@@ -104,6 +109,7 @@ func NewBlockManager(ntmgr notify.Notify, indexManager blockchain.IndexManager,
 		msgChan:           make(chan interface{}, cfg.MaxPeers*3),
 		headerList:        list.New(),
 		quit:              make(chan struct{}),
+		templateSubs:      make(map[chan *types.BlockTemplate]struct{}),
 	}
 
 	// Create a new block chain instance with the appropriate configuration.
@@ -248,6 +254,8 @@ func (b *BlockManager) handleNotifyMsg(notification *blockchain.Notification) {
 			}
 		*/
 
+		b.feeEstimator.ObserveBlock(uint64(block.Height()), block.Transactions()[1:])
+
 		b.zmqNotify.BlockConnected(block)
 
 	// A block has been disconnected from the main block chain.
@@ -258,10 +266,15 @@ func (b *BlockManager) handleNotifyMsg(notification *blockchain.Notification) {
 			log.Warn("Chain disconnected notification is not a block slice.")
 			break
 		}
+		// The cached templates may build on the disconnected block.
+		b.invalidateTemplateCache()
 		b.zmqNotify.BlockDisconnected(block)
 	// The blockchain is reorganizing.
 	case blockchain.Reorganization:
 		log.Trace("Chain reorganization notification")
+		// Drop the mining templates from the old chain, since they will
+		// be no longer valid.
+		b.invalidateTemplateCache()
 		/*
 			rd, ok := notification.Data.(*blockchain.ReorganizationNotifyData)
 			if !ok {
@@ -273,10 +286,6 @@ func (b *BlockManager) handleNotifyMsg(notification *blockchain.Notification) {
 			if r := b.server.rpcServer; r != nil {
 				r.ntfnMgr.NotifyReorganization(rd)
 			}
-
-			// Drop the associated mining template from the old chain, since it
-			// will be no longer valid.
-			b.cachedCurrentTemplate = nil
 		*/
 	}
 }
@@ -601,27 +610,36 @@ out:
 				*/
 			case getCurrentTemplateMsg:
 				log.Trace("blkmgr msgChan getCurrentTemplateMsg", "msg", msg)
-				cur := deepCopyBlockTemplate(b.cachedCurrentTemplate)
+				cur := DeepCopyBlockTemplate(b.cachedCurrentTemplate)
 				msg.reply <- getCurrentTemplateResponse{
 					Template: cur,
 				}
 
 			case setCurrentTemplateMsg:
 				log.Trace("blkmgr msgChan setCurrentTemplateMsg", "msg", msg)
-				b.cachedCurrentTemplate = deepCopyBlockTemplate(msg.Template)
+				isNew := isNewTemplate(b.cachedCurrentTemplate, msg.Template)
+				b.cachedCurrentTemplate = DeepCopyBlockTemplate(msg.Template)
+				if isNew {
+					b.notifyTemplate(msg.Template)
+				}
 				msg.reply <- setCurrentTemplateResponse{}
 
 			case getParentTemplateMsg:
 				log.Trace("blkmgr msgChan getParentTemplateMsg", "msg", msg)
-				par := deepCopyBlockTemplate(b.cachedParentTemplate)
+				par := DeepCopyBlockTemplate(b.cachedParentTemplate)
 				msg.reply <- getParentTemplateResponse{
 					Template: par,
 				}
 
 			case setParentTemplateMsg:
 				log.Trace("blkmgr msgChan setParentTemplateMsg", "msg", msg)
-				b.cachedParentTemplate = deepCopyBlockTemplate(msg.Template)
+				b.cachedParentTemplate = DeepCopyBlockTemplate(msg.Template)
 				msg.reply <- setParentTemplateResponse{}
+
+			case invalidateTemplateCacheMsg:
+				log.Trace("blkmgr msgChan invalidateTemplateCacheMsg", "msg", msg)
+				b.invalidateTemplateCache()
+				msg.reply <- struct{}{}
 			default:
 				log.Error("Unknown message type", "msg", msg)
 			}
@@ -1032,6 +1050,12 @@ func (b *BlockManager) updateSyncPeer(dcSyncPeer bool) {
 	b.startSync()
 }
 
+// SetFeeEstimator sets the fee estimator which observes the transactions of
+// the blocks connected to the main chain.
+func (b *BlockManager) SetFeeEstimator(fe *mempool.FeeEstimator) {
+	b.feeEstimator = fe
+}
+
 // Return chain params
 func (b *BlockManager) ChainParams() *params.Params {
 	return b.params
@@ -1,7 +1,9 @@
 package blkmgr
 
 import (
+	"github.com/Qitmeer/qitmeer/common/hash"
 	"github.com/Qitmeer/qitmeer/core/types"
+	"sync"
 )
 
 // getCurrentTemplateMsg handles a request for the current mining block template.
@@ -51,6 +53,12 @@ type setParentTemplateMsg struct {
 type setParentTemplateResponse struct {
 }
 
+// invalidateTemplateCacheMsg handles a request to drop the cached mining block
+// templates.
+type invalidateTemplateCacheMsg struct {
+	reply chan struct{}
+}
+
 // GetCurrentTemplate gets the current block template for mining.
 func (b *BlockManager) GetCurrentTemplate() *types.BlockTemplate {
 	reply := make(chan getCurrentTemplateResponse)
@@ -81,12 +89,90 @@ func (b *BlockManager) SetParentTemplate(bt *types.BlockTemplate) {
 	<-reply
 }
 
-// deepCopyBlockTemplate returns a deeply copied block template that copies all
+// InvalidateTemplateCache drops the current and parent block templates, so
+// that GetCurrentTemplate and GetParentTemplate return nil until a fresh
+// template is set.  It is called when the chain changes under the cached
+// templates, which would otherwise keep miners working on stale parents.
+func (b *BlockManager) InvalidateTemplateCache() {
+	reply := make(chan struct{})
+	b.msgChan <- invalidateTemplateCacheMsg{reply: reply}
+	<-reply
+}
+
+// invalidateTemplateCache drops the cached block templates.  It must be
+// called from the block handler goroutine, such as by the chain notification
+// handler, which InvalidateTemplateCache would block.
+func (b *BlockManager) invalidateTemplateCache() {
+	if b.cachedCurrentTemplate != nil || b.cachedParentTemplate != nil {
+		log.Debug("Invalidating the cached block templates")
+	}
+	b.cachedCurrentTemplate = nil
+	b.cachedParentTemplate = nil
+}
+
+// SubscribeTemplates registers for notifications of new current block
+// templates, which are the ones stored by SetCurrentTemplate for a higher
+// height or a different set of parents than the template they replace.  Only
+// the latest template is kept for a subscriber which is not keeping up.  The
+// returned function must be called to unsubscribe.
+func (b *BlockManager) SubscribeTemplates() (<-chan *types.BlockTemplate, func()) {
+	c := make(chan *types.BlockTemplate, 1)
+	b.templateSubsMtx.Lock()
+	b.templateSubs[c] = struct{}{}
+	b.templateSubsMtx.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.templateSubsMtx.Lock()
+			delete(b.templateSubs, c)
+			b.templateSubsMtx.Unlock()
+		})
+	}
+	return c, unsubscribe
+}
+
+// notifyTemplate sends the passed template to the template subscribers,
+// replacing any template they haven't received yet.
+func (b *BlockManager) notifyTemplate(bt *types.BlockTemplate) {
+	b.templateSubsMtx.Lock()
+	defer b.templateSubsMtx.Unlock()
+
+	for c := range b.templateSubs {
+		select {
+		case <-c:
+		default:
+		}
+		c <- DeepCopyBlockTemplate(bt)
+	}
+}
+
+// isNewTemplate returns whether the template cur replaces prev for a higher
+// height or a different set of parents.
+func isNewTemplate(prev *types.BlockTemplate, cur *types.BlockTemplate) bool {
+	if cur == nil {
+		return false
+	}
+	if prev == nil || cur.Height > prev.Height {
+		return true
+	}
+	if len(prev.Block.Parents) != len(cur.Block.Parents) {
+		return true
+	}
+	for i, p := range prev.Block.Parents {
+		if !p.IsEqual(cur.Block.Parents[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// DeepCopyBlockTemplate returns a deeply copied block template that copies all
 // data except a block's references to transactions, which are kept as pointers
 // in the block. This is considered safe because transaction data is generally
 // immutable, with the exception of coinbases which we alternatively also
 // deep copy.
-func deepCopyBlockTemplate(blockTemplate *types.BlockTemplate) *types.BlockTemplate {
+func DeepCopyBlockTemplate(blockTemplate *types.BlockTemplate) *types.BlockTemplate {
 	if blockTemplate == nil {
 		return nil
 	}
@@ -108,8 +194,15 @@ func deepCopyBlockTemplate(blockTemplate *types.BlockTemplate) *types.BlockTempl
 		}
 	}
 
+	parentsCopy := make([]*hash.Hash, len(blockTemplate.Block.Parents))
+	for i, p := range blockTemplate.Block.Parents {
+		pc := *p
+		parentsCopy[i] = &pc
+	}
+
 	msgBlockCopy := &types.Block{
 		Header:       headerCopy,
+		Parents:      parentsCopy,
 		Transactions: transactionsCopy,
 	}
 
@@ -119,12 +212,42 @@ func deepCopyBlockTemplate(blockTemplate *types.BlockTemplate) *types.BlockTempl
 	sigOps := make([]int64, len(blockTemplate.SigOpCounts))
 	copy(sigOps, blockTemplate.SigOpCounts)
 
+	// The hashes are never modified, so only the slices are copied.
+	blueSet := make([]*hash.Hash, len(blockTemplate.BlueSet))
+	copy(blueSet, blockTemplate.BlueSet)
+	redSet := make([]*hash.Hash, len(blockTemplate.RedSet))
+	copy(redSet, blockTemplate.RedSet)
+
+	var commitment []byte
+	if blockTemplate.CoinbaseCommitment != nil {
+		commitment = make([]byte, len(blockTemplate.CoinbaseCommitment))
+		copy(commitment, blockTemplate.CoinbaseCommitment)
+	}
+
+	// The prevouts are never modified, so only the map is copied.
+	var prevOuts map[types.TxOutPoint]*types.TemplatePrevOut
+	if blockTemplate.PrevOuts != nil {
+		prevOuts = make(map[types.TxOutPoint]*types.TemplatePrevOut,
+			len(blockTemplate.PrevOuts))
+		for outpoint, prevOut := range blockTemplate.PrevOuts {
+			prevOuts[outpoint] = prevOut
+		}
+	}
+
 	return &types.BlockTemplate{
-		Block:           msgBlockCopy,
-		Fees:            fees,
-		SigOpCounts:     sigOps,
-		Height:          blockTemplate.Height,
-		Blues:           blockTemplate.Blues,
-		ValidPayAddress: blockTemplate.ValidPayAddress,
+		Block:              msgBlockCopy,
+		Fees:               fees,
+		SigOpCounts:        sigOps,
+		Height:             blockTemplate.Height,
+		Blues:              blockTemplate.Blues,
+		BlueSet:            blueSet,
+		RedSet:             redSet,
+		Subsidy:            blockTemplate.Subsidy,
+		ExtraNonceOffset:   blockTemplate.ExtraNonceOffset,
+		ExtraNonceSize:     blockTemplate.ExtraNonceSize,
+		CoinbaseCommitment: commitment,
+		ValidPayAddress:    blockTemplate.ValidPayAddress,
+		PowDiffData:        blockTemplate.PowDiffData,
+		PrevOuts:           prevOuts,
 	}
 }
@@ -0,0 +1,64 @@
+package blkmgr
+
+import (
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/blockchain"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"testing"
+)
+
+// TestInvalidateTemplateCache ensures the cached templates are dropped by a
+// chain reorganization or an explicit invalidation until fresh ones are set.
+func TestInvalidateTemplateCache(t *testing.T) {
+	b := &BlockManager{
+		msgChan: make(chan interface{}),
+		quit:    make(chan struct{}),
+	}
+	b.wg.Add(1)
+	go b.blockHandler()
+	defer func() {
+		close(b.quit)
+		b.wg.Wait()
+	}()
+
+	coinbase := types.NewTransaction()
+	coinbase.AddTxIn(&types.TxInput{
+		PreviousOut: *types.NewOutPoint(&hash.ZeroHash, types.MaxPrevOutIndex),
+		Sequence:    types.MaxTxInSequenceNum,
+	})
+	coinbase.AddTxOut(&types.TxOutput{Amount: 1})
+	template := &types.BlockTemplate{
+		Block: &types.Block{
+			Parents:      []*hash.Hash{{0x01}},
+			Transactions: []*types.Transaction{coinbase},
+		},
+		Height: 5,
+	}
+	setTemplates := func() {
+		b.SetCurrentTemplate(template)
+		b.SetParentTemplate(template)
+		if b.GetCurrentTemplate() == nil || b.GetParentTemplate() == nil {
+			t.Fatal("templates weren't cached")
+		}
+	}
+
+	// The chain notifies a reorganization while the block handler
+	// processes a block, so it is idle in between as it is here.
+	setTemplates()
+	b.handleNotifyMsg(&blockchain.Notification{
+		Type: blockchain.Reorganization,
+		Data: &blockchain.ReorganizationNotifyData{},
+	})
+	if b.GetCurrentTemplate() != nil || b.GetParentTemplate() != nil {
+		t.Fatal("templates still cached after a reorganization")
+	}
+
+	setTemplates()
+	b.InvalidateTemplateCache()
+	if b.GetCurrentTemplate() != nil || b.GetParentTemplate() != nil {
+		t.Fatal("templates still cached after InvalidateTemplateCache")
+	}
+
+	// A fresh template is cached again.
+	setTemplates()
+}
@@ -9,6 +9,9 @@ import (
 	"github.com/Qitmeer/qitmeer/common/util"
 	"github.com/Qitmeer/qitmeer/config"
 	"github.com/Qitmeer/qitmeer/core/address"
+	"github.com/Qitmeer/qitmeer/core/blockchain"
+	"github.com/Qitmeer/qitmeer/core/protocol"
+	"github.com/Qitmeer/qitmeer/core/types"
 	"github.com/Qitmeer/qitmeer/log"
 	"github.com/Qitmeer/qitmeer/p2p/peer"
 	"github.com/Qitmeer/qitmeer/params"
@@ -20,6 +23,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 )
 
 const (
@@ -33,6 +37,10 @@ const (
 	defaultBlockMinSize           = 0
 	defaultBlockMaxSize           = 375000
 	defaultMaxRPCClients          = 10
+	defaultMaxRPCWebsockets       = 25
+	defaultRPCMaxRequestSize      = types.MaxBlockPayload
+	defaultRPCRequestTimeout      = 0 // disabled
+	defaultRPCAdminPort           = "6060"
 	defaultMaxPeers               = 125
 	defaultMiningStateSync        = false
 	defaultMaxInboundPeersPerHost = 10 // The default max total of inbound peer for host
@@ -42,7 +50,10 @@ const (
 	defaultSigCacheMaxSize = 100000
 )
 const (
-	defaultMaxOrphanTxSize = 5000
+	defaultMaxOrphanTxSize  = 5000
+	defaultMaxOrphanTxs     = 100
+	defaultMaxOrphanTxBytes = defaultMaxOrphanTxs * defaultMaxOrphanTxSize
+	defaultOrphanTxExpiry   = 15 * time.Minute
 )
 
 var (
@@ -62,27 +73,37 @@ func LoadConfig() (*config.Config, []string, error) {
 
 	// Default config.
 	cfg := config.Config{
-		HomeDir:           defaultHomeDir,
-		ConfigFile:        defaultConfigFile,
-		DebugLevel:        defaultLogLevel,
-		DebugPrintOrigins: defaultDebugPrintOrigins,
-		DataDir:           defaultDataDir,
-		LogDir:            defaultLogDir,
-		DbType:            defaultDbType,
-		RPCKey:            defaultRPCKeyFile,
-		RPCCert:           defaultRPCCertFile,
-		RPCMaxClients:     defaultMaxRPCClients,
-		Generate:          defaultGenerate,
-		MaxPeers:          defaultMaxPeers,
-		MinTxFee:          mempool.DefaultMinRelayTxFee,
-		BlockMinSize:      defaultBlockMinSize,
-		BlockMaxSize:      defaultBlockMaxSize,
-		SigCacheMaxSize:   defaultSigCacheMaxSize,
-		MiningStateSync:   defaultMiningStateSync,
-		DAGType:           defaultDAGType,
-		Banning:           false,
-		MaxInbound:        defaultMaxInboundPeersPerHost,
-		TrickleInterval:   defaultTrickleInterval,
+		HomeDir:            defaultHomeDir,
+		ConfigFile:         defaultConfigFile,
+		DebugLevel:         defaultLogLevel,
+		DebugPrintOrigins:  defaultDebugPrintOrigins,
+		DataDir:            defaultDataDir,
+		LogDir:             defaultLogDir,
+		DbType:             defaultDbType,
+		RPCKey:             defaultRPCKeyFile,
+		RPCCert:            defaultRPCCertFile,
+		RPCMaxClients:      defaultMaxRPCClients,
+		RPCMaxWebsockets:   defaultMaxRPCWebsockets,
+		RPCMaxRequestSize:  defaultRPCMaxRequestSize,
+		RPCRequestTimeout:  defaultRPCRequestTimeout,
+		Generate:           defaultGenerate,
+		MaxPeers:           defaultMaxPeers,
+		MaxOrphanTxs:       defaultMaxOrphanTxs,
+		MaxOrphanTxBytes:   defaultMaxOrphanTxBytes,
+		OrphanTxExpiry:     defaultOrphanTxExpiry,
+		MinTxFee:           mempool.DefaultMinRelayTxFee,
+		MaxAncestorCount:   mempool.DefaultMaxAncestorCount,
+		MaxAncestorSize:    mempool.DefaultMaxAncestorSize,
+		MaxDescendantCount: mempool.DefaultMaxDescendantCount,
+		MaxDescendantSize:  mempool.DefaultMaxDescendantSize,
+		BlockMinSize:       defaultBlockMinSize,
+		BlockMaxSize:       defaultBlockMaxSize,
+		SigCacheMaxSize:    defaultSigCacheMaxSize,
+		MiningStateSync:    defaultMiningStateSync,
+		DAGType:            defaultDAGType,
+		Banning:            false,
+		MaxInbound:         defaultMaxInboundPeersPerHost,
+		TrickleInterval:    defaultTrickleInterval,
 	}
 
 	// Pre-parse the command line options to see if an alternative config
@@ -263,6 +284,58 @@ func LoadConfig() (*config.Config, []string, error) {
 		}
 	}
 
+	// Profiling is only served on localhost unless admin listeners are
+	// configured.
+	if cfg.RPCAdminProfile && len(cfg.RPCAdminListeners) == 0 {
+		cfg.RPCAdminListeners = []string{
+			net.JoinHostPort("127.0.0.1", defaultRPCAdminPort),
+		}
+	}
+
+	// The RPC requests are limited in size, and optionally in duration.
+	if cfg.RPCMaxRequestSize <= 0 {
+		str := "%s: the rpcmaxrequestsize option must be positive"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.RPCRequestTimeout < 0 {
+		str := "%s: the rpcrequesttimeout option can't be negative"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Methods are either allowed or denied.
+	if len(cfg.RPCAllowMethods) > 0 && len(cfg.RPCDenyMethods) > 0 {
+		str := "%s: the rpcallow and rpcdeny options can't be used together"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// Client certificates are verified by TLS against a CA.
+	if cfg.RPCClientAuth && cfg.RPCClientCA == "" {
+		str := "%s: the rpcclientauth option requires rpcclientca"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.RPCClientCA != "" && cfg.DisableTLS {
+		str := "%s: the rpcclientca option requires TLS -- remove notls"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+	if cfg.RPCClientCA != "" {
+		cfg.RPCClientCA = util.CleanAndExpandPath(cfg.RPCClientCA)
+	}
+
 	// Append the network type to the data directory so it is "namespaced"
 	// per network.  In addition to the block database, there are other
 	// pieces of data that are saved to disk such as address manager state.
@@ -297,6 +370,94 @@ func LoadConfig() (*config.Config, []string, error) {
 		log.PrintOrigins(true)
 	}
 
+	// The limits of the orphan pool can't be negative.
+	if cfg.MaxOrphanTxs < 0 || cfg.MaxOrphanTxBytes < 0 || cfg.OrphanTxExpiry < 0 {
+		str := "%s: the maxorphantx, maxorphantxbytes and orphantxexpiry " +
+			"options can't be negative"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// The expiry of the mempool can't be negative.
+	if cfg.MempoolExpiry < 0 {
+		str := "%s: the mempoolexpiry option can't be negative"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// The min relay fee of the mempool can't be negative.
+	if cfg.MinTxFee < 0 {
+		str := "%s: the mintxfee option can't be negative"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// The sigop cap of the block templates can't exceed the consensus max.
+	if cfg.BlockMaxSigOps < 0 || cfg.BlockMaxSigOps > blockchain.MaxSigOpsPerBlock {
+		str := "%s: the blockmaxsigops option must be in the range " +
+			"0 to %d"
+		err := fmt.Errorf(str, funcName, blockchain.MaxSigOpsPerBlock)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// The parents of the block templates can't exceed the consensus max.
+	if cfg.BlockMaxParents < 0 || cfg.BlockMaxParents > types.MaxParentsPerBlock {
+		str := "%s: the blockmaxparents option must be in the range " +
+			"0 to %d"
+		err := fmt.Errorf(str, funcName, types.MaxParentsPerBlock)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// The package size of the block templates can't be negative.
+	if cfg.BlockMaxPackageSize < 0 {
+		str := "%s: the blockmaxpackagesize option can't be negative"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// The extra nonce has to fit in the coinbase script.
+	if cfg.ExtraNonceSize < 0 || cfg.ExtraNonceSize > blockchain.MaxCoinbaseScriptLen {
+		str := "%s: the extranoncesize option must be in the range " +
+			"0 to %d"
+		err := fmt.Errorf(str, funcName, blockchain.MaxCoinbaseScriptLen)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// The regtest mode of the block templates is refused on mainnet.
+	if cfg.RegtestMode && params.ActiveNetParams.Net == protocol.MainNet {
+		str := "%s: the regtestmode option is not allowed on mainnet"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
+	// The package limits of the mempool can't be negative.
+	if cfg.MaxAncestorCount < 0 || cfg.MaxAncestorSize < 0 ||
+		cfg.MaxDescendantCount < 0 || cfg.MaxDescendantSize < 0 {
+		str := "%s: the limitancestorcount, limitancestorsize, " +
+			"limitdescendantcount and limitdescendantsize options " +
+			"can't be negative"
+		err := fmt.Errorf(str, funcName)
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Fprintln(os.Stderr, usageMessage)
+		return nil, nil, err
+	}
+
 	// --addrindex and --dropaddrindex do not mix.
 	if cfg.AddrIndex && cfg.DropAddrIndex {
 		err := fmt.Errorf("%s: the --addrindex and --dropaddrindex "+
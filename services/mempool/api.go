@@ -1,6 +1,8 @@
 package mempool
 
 import (
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/json"
 	"github.com/Qitmeer/qitmeer/log"
 	"github.com/Qitmeer/qitmeer/rpc"
 	"sort"
@@ -35,3 +37,46 @@ func (api *PublicMempoolAPI) GetMempool(txType *string, verbose bool) (interface
 	sort.Strings(hashStrings)
 	return hashStrings, nil
 }
+
+// GetMempoolPackageLimits returns the limits of the chains of unconfirmed
+// transactions the mempool accepts.
+func (api *PublicMempoolAPI) GetMempoolPackageLimits() (interface{}, error) {
+	return api.txPool.PackageLimits(), nil
+}
+
+// GetMempoolFeeHistogram returns the number and the size of the transactions
+// of the mempool by fee rate.
+func (api *PublicMempoolAPI) GetMempoolFeeHistogram() (interface{}, error) {
+	return api.txPool.FeeHistogram(), nil
+}
+
+// GetMempoolEntry returns the fee, the priority and the unconfirmed ancestors
+// and descendants of the passed transaction of the mempool.
+func (api *PublicMempoolAPI) GetMempoolEntry(txHash hash.Hash) (interface{}, error) {
+	entry, err := api.txPool.TxEntry(&txHash)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, rpc.RpcNoTxInfoError(&txHash)
+	}
+	depends := make([]string, 0, len(entry.Depends))
+	for _, parentHash := range entry.Depends {
+		depends = append(depends, parentHash.String())
+	}
+	sort.Strings(depends)
+	return &json.GetMempoolEntryResult{
+		Size:             int32(entry.Tx.Transaction().SerializeSize()),
+		Fee:              entry.Fee,
+		FeeRate:          entry.FeePerKB,
+		Time:             entry.Added.Unix(),
+		Height:           entry.Height,
+		StartingPriority: entry.StartingPriority,
+		CurrentPriority:  entry.CurrentPriority,
+		AncestorCount:    entry.AncestorCount,
+		AncestorSize:     entry.AncestorSize,
+		DescendantCount:  entry.DescendantCount,
+		DescendantSize:   entry.DescendantSize,
+		Depends:          depends,
+	}, nil
+}
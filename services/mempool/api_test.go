@@ -0,0 +1,84 @@
+package mempool
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/json"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"github.com/Qitmeer/qitmeer/engine/txscript"
+	"github.com/Qitmeer/qitmeer/rpc"
+)
+
+// TestGetMempoolEntry ensures the entries of the mempool report the fee and
+// the packages of their transactions.
+func TestGetMempoolEntry(t *testing.T) {
+	funding := types.NewTransaction()
+	funding.AddTxIn(&types.TxInput{
+		PreviousOut: *types.NewOutPoint(&hash.Hash{0x05}, 0),
+		Sequence:    types.MaxTxInSequenceNum,
+	})
+	funding.AddTxOut(&types.TxOutput{
+		Amount:   1000,
+		PkScript: []byte{txscript.OP_TRUE},
+	})
+	fundingTx := types.NewTx(funding)
+	parent := newPersistTestTx(900, types.NewOutPoint(fundingTx.Hash(), 0))
+	child := newPersistTestTx(750, types.NewOutPoint(parent.Hash(), 0))
+
+	mp := newPersistTestPool(fundingTx, []uint32{0})
+	for _, tx := range []*types.Tx{parent, child} {
+		if _, err := mp.ProcessTransaction(tx, false, false, true); err != nil {
+			t.Fatalf("ProcessTransaction: %v", err)
+		}
+	}
+	api := NewPublicMempoolAPI(mp)
+
+	parentSize := int64(parent.Transaction().SerializeSize())
+	childSize := int64(child.Transaction().SerializeSize())
+	tests := []struct {
+		tx   *types.Tx
+		want *json.GetMempoolEntryResult
+	}{
+		{parent, &json.GetMempoolEntryResult{
+			Size:            int32(parentSize),
+			Fee:             100,
+			FeeRate:         100 * 1000 / parentSize,
+			Time:            mp.pool[*parent.Hash()].Added.Unix(),
+			Height:          2,
+			AncestorCount:   1,
+			AncestorSize:    parentSize,
+			DescendantCount: 2,
+			DescendantSize:  parentSize + childSize,
+			Depends:         []string{},
+		}},
+		{child, &json.GetMempoolEntryResult{
+			Size:            int32(childSize),
+			Fee:             150,
+			FeeRate:         150 * 1000 / childSize,
+			Time:            mp.pool[*child.Hash()].Added.Unix(),
+			Height:          2,
+			AncestorCount:   2,
+			AncestorSize:    parentSize + childSize,
+			DescendantCount: 1,
+			DescendantSize:  childSize,
+			Depends:         []string{parent.Hash().String()},
+		}},
+	}
+	for _, test := range tests {
+		result, err := api.GetMempoolEntry(*test.tx.Hash())
+		if err != nil {
+			t.Fatalf("GetMempoolEntry: %v", err)
+		}
+		if !reflect.DeepEqual(result, test.want) {
+			t.Errorf("got entry %+v, want %+v", result, test.want)
+		}
+	}
+
+	// The transactions not in the pool aren't found.
+	_, err := api.GetMempoolEntry(*fundingTx.Hash())
+	if rerr, ok := err.(*rpc.RPCError); !ok || rerr.Code != rpc.ErrCodeNoTxInfo {
+		t.Errorf("got error %v, want no tx info", err)
+	}
+}
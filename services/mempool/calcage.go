@@ -21,15 +21,29 @@ func minInt(a, b int) int {
 	return b
 }
 
-// calcInputValueAge is a helper function used to calculate the input age of
-// a transaction.  The input age for a txin is the number of confirmations
-// since the referenced txout multiplied by its output value.  The total input
-// age is the sum of this value for each txin.  Any inputs to the transaction
-// which are currently in the mempool and hence not mined into a block yet,
-// contribute no additional input age to the transaction.
-func calcInputValueAge(tx *types.Transaction, utxoView *blockchain.UtxoViewpoint, nextBlockHeight uint64, bd *blockdag.BlockDAG) float64 {
-	var totalInputAge float64
-	for _, txIn := range tx.TxIn {
+// InputPriority describes the contribution of a transaction input to the
+// priority of the transaction, as returned by CalcPriorityDetailed.
+type InputPriority struct {
+	PreviousOut types.TxOutPoint
+	Value       uint64
+	Age         uint64
+	ValueAge    float64
+	Priority    float64
+}
+
+// calcInputsValueAge is a helper function used to calculate the input age of
+// each input of a transaction.  The input age for a txin is the number of
+// confirmations since the referenced txout multiplied by its output value.
+// Any inputs to the transaction which are currently in the mempool and hence
+// not mined into a block yet, or whose referenced txout doesn't exist,
+// contribute no input age to the transaction.  Nil is returned when the block
+// of a referenced txout is unknown, in which case the transaction has no
+// input age at all.
+func calcInputsValueAge(tx *types.Transaction, utxoView *blockchain.UtxoViewpoint, nextBlockHeight uint64, bd *blockdag.BlockDAG) []InputPriority {
+	inputs := make([]InputPriority, len(tx.TxIn))
+	for i, txIn := range tx.TxIn {
+		inputs[i].PreviousOut = txIn.PreviousOut
+
 		// Don't attempt to accumulate the total input age if the
 		// referenced transaction output doesn't exist.
 		txEntry := utxoView.LookupEntry(txIn.PreviousOut)
@@ -44,24 +58,38 @@ func calcInputValueAge(tx *types.Transaction, utxoView *blockchain.UtxoViewpoint
 			} else {
 				block := bd.GetBlock(txEntry.BlockHash())
 				if block == nil {
-					return 0
+					return nil
 				}
 				inputAge = nextBlockHeight - uint64(block.GetHeight())
 			}
-			// Sum the input value times age.
+			// The input value times age.
 			inputValue := txEntry.Amount()
-			totalInputAge += float64(inputValue * inputAge)
+			inputs[i].Value = inputValue
+			inputs[i].Age = inputAge
+			inputs[i].ValueAge = float64(inputValue * inputAge)
 		}
 	}
 
+	return inputs
+}
+
+// calcInputValueAge is a helper function used to calculate the input age of
+// a transaction.  The total input age is the sum of the input age of each
+// txin, as calculated by calcInputsValueAge.
+func calcInputValueAge(tx *types.Transaction, utxoView *blockchain.UtxoViewpoint, nextBlockHeight uint64, bd *blockdag.BlockDAG) float64 {
+	var totalInputAge float64
+	for _, input := range calcInputsValueAge(tx, utxoView, nextBlockHeight, bd) {
+		totalInputAge += input.ValueAge
+	}
+
 	return totalInputAge
 }
 
-// CalcPriority returns a transaction priority given a transaction and the sum
-// of each of its input values multiplied by their age (# of confirmations).
-// Thus, the final formula for the priority is:
-// sum(inputValue * inputAge) / adjustedTxSize
-func CalcPriority(tx *types.Transaction, utxoView *blockchain.UtxoViewpoint, nextBlockHeight uint64, bd *blockdag.BlockDAG) float64 {
+// calcAdjustedTxSize returns the size of a transaction the priority is
+// calculated over, which is its serialized size without the overhead of its
+// inputs.  A result which isn't positive means the transaction has no
+// priority.
+func calcAdjustedTxSize(tx *types.Transaction) int {
 	// In order to encourage spending multiple old unspent transaction
 	// outputs thereby reducing the total set, don't count the constant
 	// overhead for each input as well as enough bytes of the signature
@@ -88,11 +116,39 @@ func CalcPriority(tx *types.Transaction, utxoView *blockchain.UtxoViewpoint, nex
 		overhead += 41 + minInt(110, len(txIn.SignScript))
 	}
 
-	serializedTxSize := tx.SerializeSize()
-	if overhead >= serializedTxSize {
+	return tx.SerializeSize() - overhead
+}
+
+// CalcPriority returns a transaction priority given a transaction and the sum
+// of each of its input values multiplied by their age (# of confirmations).
+// Thus, the final formula for the priority is:
+// sum(inputValue * inputAge) / adjustedTxSize
+func CalcPriority(tx *types.Transaction, utxoView *blockchain.UtxoViewpoint, nextBlockHeight uint64, bd *blockdag.BlockDAG) float64 {
+	adjustedTxSize := calcAdjustedTxSize(tx)
+	if adjustedTxSize <= 0 {
 		return 0.0
 	}
 
 	inputValueAge := calcInputValueAge(tx, utxoView, nextBlockHeight, bd)
-	return inputValueAge / float64(serializedTxSize-overhead)
+	return inputValueAge / float64(adjustedTxSize)
+}
+
+// CalcPriorityDetailed returns the same priority as CalcPriority along with
+// the contribution of each input of the transaction to it, which is meant to
+// explain why a transaction was or wasn't prioritized.  The breakdown is nil
+// when the transaction has no priority because the block of one of its
+// inputs is unknown.
+func CalcPriorityDetailed(tx *types.Transaction, utxoView *blockchain.UtxoViewpoint, nextBlockHeight uint64, bd *blockdag.BlockDAG) (float64, []InputPriority) {
+	inputs := calcInputsValueAge(tx, utxoView, nextBlockHeight, bd)
+	adjustedTxSize := calcAdjustedTxSize(tx)
+	if adjustedTxSize <= 0 {
+		return 0.0, inputs
+	}
+
+	var inputValueAge float64
+	for i := range inputs {
+		inputValueAge += inputs[i].ValueAge
+		inputs[i].Priority = inputs[i].ValueAge / float64(adjustedTxSize)
+	}
+	return inputValueAge / float64(adjustedTxSize), inputs
 }
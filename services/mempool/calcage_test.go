@@ -0,0 +1,101 @@
+package mempool
+
+import (
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/blockchain"
+	"github.com/Qitmeer/qitmeer/core/blockdag"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"testing"
+)
+
+// priorityTestBlock is a block of the chain built by TestCalcPriorityDetailed.
+type priorityTestBlock struct {
+	hash    hash.Hash
+	parents []uint
+}
+
+func (b *priorityTestBlock) GetHash() *hash.Hash { return &b.hash }
+func (b *priorityTestBlock) GetParents() []uint  { return b.parents }
+func (b *priorityTestBlock) GetTimestamp() int64 { return 0 }
+func (b *priorityTestBlock) GetWeight() uint64   { return 1 }
+
+// TestCalcPriorityDetailed ensures the per input breakdown of the priority of
+// a transaction adds up to its priority.
+func TestCalcPriorityDetailed(t *testing.T) {
+	// A chain of 3 blocks, whose heights are 0, 1 and 2.
+	ids := make(map[hash.Hash]uint)
+	dag := &blockdag.BlockDAG{}
+	dag.Init("phantom", func(int64) int64 { return 1 }, -1,
+		func(h *hash.Hash) uint {
+			if id, ok := ids[*h]; ok {
+				return id
+			}
+			return blockdag.MaxId
+		})
+	var blockHashes []hash.Hash
+	for i := 0; i < 3; i++ {
+		block := &priorityTestBlock{hash: hash.Hash{0x20, byte(i)}}
+		if i > 0 {
+			block.parents = []uint{ids[blockHashes[i-1]]}
+		}
+		if _, ib := dag.AddBlock(block); ib == nil {
+			t.Fatalf("AddBlock %d failed", i)
+		} else {
+			ids[block.hash] = ib.GetID()
+		}
+		blockHashes = append(blockHashes, block.hash)
+	}
+
+	// The funding transaction pays 1000 and 3000 in blocks 1 and 2.
+	funding := types.NewTransaction()
+	funding.AddTxIn(&types.TxInput{
+		PreviousOut: *types.NewOutPoint(&hash.Hash{0x01}, 0),
+		Sequence:    types.MaxTxInSequenceNum,
+	})
+	funding.AddTxOut(&types.TxOutput{Amount: 1000, PkScript: []byte{0x51}})
+	funding.AddTxOut(&types.TxOutput{Amount: 3000, PkScript: []byte{0x51}})
+	fundingTx := types.NewTx(funding)
+	utxos := blockchain.NewUtxoViewpoint()
+	utxos.AddTxOut(fundingTx, 0, &blockHashes[1])
+	utxos.AddTxOut(fundingTx, 1, &blockHashes[2])
+
+	spend := types.NewTransaction()
+	for i := uint32(0); i < 2; i++ {
+		spend.AddTxIn(&types.TxInput{
+			PreviousOut: *types.NewOutPoint(fundingTx.Hash(), i),
+			SignScript:  make([]byte, 20),
+			Sequence:    types.MaxTxInSequenceNum,
+		})
+	}
+	spend.AddTxOut(&types.TxOutput{Amount: 4000, PkScript: make([]byte, 400)})
+
+	// In a block at height 10, the inputs are 9 and 8 blocks old.
+	const height = 10
+	priority, inputs := CalcPriorityDetailed(spend, utxos, height, dag)
+	if want := CalcPriority(spend, utxos, height, dag); priority != want {
+		t.Fatalf("priority %v, want the CalcPriority one %v", priority, want)
+	}
+	if len(inputs) != 2 {
+		t.Fatalf("got %d inputs, want 2", len(inputs))
+	}
+	wants := []struct {
+		value, age uint64
+	}{{1000, 9}, {3000, 8}}
+	var sum float64
+	for i, input := range inputs {
+		if input.PreviousOut != spend.TxIn[i].PreviousOut {
+			t.Errorf("input %d: wrong previous outpoint", i)
+		}
+		if input.Value != wants[i].value || input.Age != wants[i].age {
+			t.Errorf("input %d: value %d age %d, want %d and %d", i,
+				input.Value, input.Age, wants[i].value, wants[i].age)
+		}
+		if input.ValueAge != float64(input.Value*input.Age) {
+			t.Errorf("input %d: value age %v", i, input.ValueAge)
+		}
+		sum += input.Priority
+	}
+	if diff := sum - priority; diff > 1e-9 || diff < -1e-9 {
+		t.Errorf("breakdown sums to %v, want %v", sum, priority)
+	}
+}
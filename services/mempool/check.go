@@ -129,6 +129,37 @@ func checkTransactionStandard(tx *types.Tx, height uint64,
 	return nil
 }
 
+// CheckStandard returns an error when the passed transaction isn't standard
+// under the passed policy, as the mempool checks before relaying it when it
+// doesn't accept non-standard transactions: its version, finality and size,
+// the size and the form of its signature scripts, the forms of its output
+// scripts and of the scripts it spends from the passed view, its dust outputs
+// and its number of signature operations.  The outputs it spends must be in
+// the view.
+func CheckStandard(tx *types.Tx, utxoView *blockchain.UtxoViewpoint,
+	height uint64, medianTime time.Time, policy *Policy) error {
+
+	err := checkTransactionStandard(tx, height, medianTime,
+		policy.MinRelayTxFee, policy.MaxTxVersion)
+	if err != nil {
+		return err
+	}
+	if err := checkInputsStandard(tx, utxoView); err != nil {
+		return err
+	}
+	numSigOps, err := blockchain.CountP2SHSigOps(tx, false, utxoView)
+	if err != nil {
+		return err
+	}
+	numSigOps += blockchain.CountSigOps(tx)
+	if numSigOps > policy.MaxSigOpsPerTx {
+		str := fmt.Sprintf("transaction has too many sigops: %d > %d",
+			numSigOps, policy.MaxSigOpsPerTx)
+		return txRuleError(message.RejectNonstandard, str)
+	}
+	return nil
+}
+
 // checkPkScriptStandard performs a series of checks on a transaction output
 // script (public key script) to ensure it is a "standard" public key script.
 // A standard public key script is one that is a recognized form, and for
@@ -0,0 +1,284 @@
+// Copyright (c) 2017-2018 The qitmeer developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"io"
+	"sort"
+	"sync"
+)
+
+const (
+	// EstimateFeeMaxConfTarget is the highest confirmation target, in
+	// blocks, fees can be estimated for.
+	EstimateFeeMaxConfTarget = 25
+
+	// DefaultFeeEstimatorWindow is the default number of recent blocks
+	// whose inclusions are used to estimate fees.
+	DefaultFeeEstimatorWindow = 288
+
+	// DefaultFeeEstimatorMinObservations is the default number of
+	// inclusions needed within a confirmation target to estimate its fee.
+	DefaultFeeEstimatorMinObservations = 10
+
+	// estimateFeeSuccessRate is the share of the transactions paying at
+	// least the estimate of a confirmation target which must have been
+	// included within it.  It is high so the estimate is conservative.
+	estimateFeeSuccessRate = 0.85
+
+	// feeEstimatorVersion is the version of the serialized fee estimator.
+	feeEstimatorVersion = 1
+)
+
+// ErrInsufficientFeeData is returned by EstimateFee when not enough
+// transactions were included within the requested confirmation target to
+// estimate its fee.
+var ErrInsufficientFeeData = errors.New("insufficient data to estimate fee")
+
+// feeObservation is the fee rate paid by a transaction which was included in
+// a block, along with how many blocks it waited for it.
+type feeObservation struct {
+	height   uint64
+	feePerKB int64
+	waited   uint32
+}
+
+// pendingFee is the fee rate of a transaction offered to a block template
+// which wasn't included in a block yet.
+type pendingFee struct {
+	height   uint64
+	feePerKB int64
+}
+
+// FeeEstimator estimates the fee rate a transaction needs to pay to be
+// included within a number of blocks.  The transactions offered to block
+// templates are observed along with the height of the template, and once they
+// are included in a block the number of blocks they waited is recorded.  Only
+// the inclusions of a rolling window of recent blocks are considered.
+//
+// This type is safe for concurrent access.
+type FeeEstimator struct {
+	mtx             sync.Mutex
+	window          uint32
+	minObservations uint32
+	lastHeight      uint64
+	pending         map[hash.Hash]pendingFee
+	observations    []feeObservation
+}
+
+// NewFeeEstimator returns a fee estimator considering the inclusions of the
+// passed number of recent blocks, which needs the passed number of inclusions
+// within a confirmation target to estimate its fee.
+func NewFeeEstimator(window uint32, minObservations uint32) *FeeEstimator {
+	return &FeeEstimator{
+		window:          window,
+		minObservations: minObservations,
+		pending:         make(map[hash.Hash]pendingFee),
+	}
+}
+
+// ObserveTransaction records the fee rate of a transaction offered to the
+// block template at the passed height.  Only the first offer of a
+// transaction is recorded.  A nil estimator does nothing.
+func (fe *FeeEstimator) ObserveTransaction(txHash *hash.Hash, feePerKB int64, height uint64) {
+	if fe == nil {
+		return
+	}
+	fe.mtx.Lock()
+	defer fe.mtx.Unlock()
+	if _, exists := fe.pending[*txHash]; exists {
+		return
+	}
+	fe.pending[*txHash] = pendingFee{height: height, feePerKB: feePerKB}
+}
+
+// ObserveBlock records the inclusion of the observed transactions in the
+// block at the passed height, and drops the data which fell out of the
+// rolling window.  A nil estimator does nothing.
+func (fe *FeeEstimator) ObserveBlock(height uint64, txns []*types.Tx) {
+	if fe == nil {
+		return
+	}
+	fe.mtx.Lock()
+	defer fe.mtx.Unlock()
+
+	for _, tx := range txns {
+		pending, exists := fe.pending[*tx.Hash()]
+		if !exists {
+			continue
+		}
+		delete(fe.pending, *tx.Hash())
+
+		// A transaction offered to the template at the height it is
+		// included at waited for a single block.
+		waited := uint32(1)
+		if height > pending.height {
+			waited += uint32(height - pending.height)
+		}
+		fe.observations = append(fe.observations, feeObservation{
+			height:   height,
+			feePerKB: pending.feePerKB,
+			waited:   waited,
+		})
+	}
+	if height > fe.lastHeight {
+		fe.lastHeight = height
+	}
+	fe.prune()
+}
+
+// prune drops the observations and the pending transactions which fell out
+// of the rolling window.
+//
+// This function MUST be called with the estimator lock held.
+func (fe *FeeEstimator) prune() {
+	if fe.lastHeight < uint64(fe.window) {
+		return
+	}
+	minHeight := fe.lastHeight - uint64(fe.window)
+
+	kept := fe.observations[:0]
+	for _, o := range fe.observations {
+		if o.height > minHeight {
+			kept = append(kept, o)
+		}
+	}
+	fe.observations = kept
+
+	for txHash, pending := range fe.pending {
+		if pending.height <= minHeight {
+			delete(fe.pending, txHash)
+		}
+	}
+}
+
+// EstimateFee returns the fee rate per kilobyte a transaction needs to pay to
+// be included within the passed number of blocks.  Estimates never increase
+// with the confirmation target.  ErrInsufficientFeeData is returned when not
+// enough transactions were included within the target recently.
+func (fe *FeeEstimator) EstimateFee(confTarget int) (int64, error) {
+	if confTarget < 1 || confTarget > EstimateFeeMaxConfTarget {
+		return 0, fmt.Errorf("confirmation target %d is out of range "+
+			"[1, %d]", confTarget, EstimateFeeMaxConfTarget)
+	}
+
+	fe.mtx.Lock()
+	defer fe.mtx.Unlock()
+
+	// Walk the observations from the highest fee rate down, and return the
+	// lowest fee rate for which enough of the transactions paying at least
+	// as much were included within the target.  Since a longer target only
+	// counts more of them as included, the estimates never increase with
+	// the target.
+	observations := make([]feeObservation, len(fe.observations))
+	copy(observations, fe.observations)
+	sort.Slice(observations, func(i, j int) bool {
+		return observations[i].feePerKB > observations[j].feePerKB
+	})
+	var total, included uint32
+	estimate := int64(-1)
+	for i, o := range observations {
+		total++
+		if int(o.waited) <= confTarget {
+			included++
+		}
+
+		// Only consider a fee rate once all the observations paying it
+		// were counted.
+		if i+1 < len(observations) && observations[i+1].feePerKB == o.feePerKB {
+			continue
+		}
+		if total >= fe.minObservations &&
+			float64(included) >= float64(total)*estimateFeeSuccessRate {
+			estimate = o.feePerKB
+		}
+	}
+	if estimate < 0 {
+		return 0, ErrInsufficientFeeData
+	}
+	return estimate, nil
+}
+
+// Save writes the state of the estimator to the passed writer so that it can
+// be restored by RestoreFeeEstimator.
+func (fe *FeeEstimator) Save(w io.Writer) error {
+	fe.mtx.Lock()
+	defer fe.mtx.Unlock()
+
+	header := []interface{}{uint32(feeEstimatorVersion), fe.window,
+		fe.minObservations, fe.lastHeight, uint32(len(fe.observations)),
+		uint32(len(fe.pending))}
+	for _, field := range header {
+		if err := binary.Write(w, binary.LittleEndian, field); err != nil {
+			return err
+		}
+	}
+	for _, o := range fe.observations {
+		for _, field := range []interface{}{o.height, o.feePerKB, o.waited} {
+			if err := binary.Write(w, binary.LittleEndian, field); err != nil {
+				return err
+			}
+		}
+	}
+	for txHash, pending := range fe.pending {
+		if _, err := w.Write(txHash[:]); err != nil {
+			return err
+		}
+		for _, field := range []interface{}{pending.height, pending.feePerKB} {
+			if err := binary.Write(w, binary.LittleEndian, field); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RestoreFeeEstimator returns the estimator saved to the passed reader.
+func RestoreFeeEstimator(r io.Reader) (*FeeEstimator, error) {
+	var version, window, minObservations, numObservations, numPending uint32
+	var lastHeight uint64
+	header := []interface{}{&version, &window, &minObservations,
+		&lastHeight, &numObservations, &numPending}
+	for _, field := range header {
+		if err := binary.Read(r, binary.LittleEndian, field); err != nil {
+			return nil, err
+		}
+	}
+	if version != feeEstimatorVersion {
+		return nil, fmt.Errorf("unsupported fee estimator version %d",
+			version)
+	}
+
+	fe := NewFeeEstimator(window, minObservations)
+	fe.lastHeight = lastHeight
+	for i := uint32(0); i < numObservations; i++ {
+		var o feeObservation
+		for _, field := range []interface{}{&o.height, &o.feePerKB, &o.waited} {
+			if err := binary.Read(r, binary.LittleEndian, field); err != nil {
+				return nil, err
+			}
+		}
+		fe.observations = append(fe.observations, o)
+	}
+	for i := uint32(0); i < numPending; i++ {
+		var txHash hash.Hash
+		if _, err := io.ReadFull(r, txHash[:]); err != nil {
+			return nil, err
+		}
+		var pending pendingFee
+		for _, field := range []interface{}{&pending.height, &pending.feePerKB} {
+			if err := binary.Read(r, binary.LittleEndian, field); err != nil {
+				return nil, err
+			}
+		}
+		fe.pending[txHash] = pending
+	}
+	return fe, nil
+}
@@ -0,0 +1,106 @@
+package mempool
+
+import (
+	"bytes"
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"testing"
+)
+
+// newEstimateFeeTestTx returns a transaction made unique by the passed index.
+func newEstimateFeeTestTx(index uint32) *types.Tx {
+	tx := types.NewTransaction()
+	tx.AddTxIn(&types.TxInput{
+		PreviousOut: *types.NewOutPoint(&hash.Hash{0x01}, index),
+		Sequence:    types.MaxTxInSequenceNum,
+	})
+	tx.AddTxOut(&types.TxOutput{Amount: 1})
+	return types.NewTx(tx)
+}
+
+// feeHistory drives the passed estimator through blocks from the passed
+// height, each including transactions paying feePerKB after waiting
+// for the passed number of blocks.
+func feeHistory(fe *FeeEstimator, height uint64, blocks int, perBlock int,
+	feePerKB int64, waited uint64, index *uint32) {
+
+	for i := 0; i < blocks; i++ {
+		blockHeight := height + uint64(i)
+		txns := make([]*types.Tx, 0, perBlock)
+		for j := 0; j < perBlock; j++ {
+			tx := newEstimateFeeTestTx(*index)
+			*index++
+			fe.ObserveTransaction(tx.Hash(), feePerKB+int64(j),
+				blockHeight+1-waited)
+			txns = append(txns, tx)
+		}
+		fe.ObserveBlock(blockHeight, txns)
+	}
+}
+
+func TestEstimateFee(t *testing.T) {
+	fe := NewFeeEstimator(100, 10)
+	if _, err := fe.EstimateFee(1); err != ErrInsufficientFeeData {
+		t.Fatalf("EstimateFee without data: got %v, want %v", err,
+			ErrInsufficientFeeData)
+	}
+	for _, target := range []int{0, EstimateFeeMaxConfTarget + 1} {
+		if _, err := fe.EstimateFee(target); err == nil {
+			t.Errorf("EstimateFee(%d) accepted an invalid target", target)
+		}
+	}
+
+	// High fee transactions are included right away, low fee ones after
+	// waiting a few blocks.
+	var index uint32
+	feeHistory(fe, 10, 5, 4, 5000, 1, &index)
+	feeHistory(fe, 15, 5, 4, 1000, 3, &index)
+	feeHistory(fe, 20, 5, 4, 200, 6, &index)
+
+	high, err := fe.EstimateFee(1)
+	if err != nil {
+		t.Fatalf("EstimateFee(1): %v", err)
+	}
+	if high < 5000 {
+		t.Errorf("EstimateFee(1): got %d, want at least 5000", high)
+	}
+	prev := high
+	for target := 2; target <= EstimateFeeMaxConfTarget; target++ {
+		feePerKB, err := fe.EstimateFee(target)
+		if err != nil {
+			t.Fatalf("EstimateFee(%d): %v", target, err)
+		}
+		if feePerKB > prev {
+			t.Errorf("EstimateFee(%d) = %d is above EstimateFee(%d) = %d",
+				target, feePerKB, target-1, prev)
+		}
+		prev = feePerKB
+	}
+	if prev >= high {
+		t.Errorf("estimates don't decrease with the target: %d", prev)
+	}
+
+	// The estimator is restored with the same estimates.
+	var buf bytes.Buffer
+	if err := fe.Save(&buf); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	restored, err := RestoreFeeEstimator(&buf)
+	if err != nil {
+		t.Fatalf("RestoreFeeEstimator: %v", err)
+	}
+	for target := 1; target <= EstimateFeeMaxConfTarget; target++ {
+		want, _ := fe.EstimateFee(target)
+		if got, _ := restored.EstimateFee(target); got != want {
+			t.Errorf("restored EstimateFee(%d): got %d, want %d",
+				target, got, want)
+		}
+	}
+
+	// Inclusions falling out of the rolling window are forgotten.
+	fe.ObserveBlock(200, nil)
+	if _, err := fe.EstimateFee(EstimateFeeMaxConfTarget); err != ErrInsufficientFeeData {
+		t.Fatalf("EstimateFee after the window: got %v, want %v", err,
+			ErrInsufficientFeeData)
+	}
+}
@@ -0,0 +1,160 @@
+// Copyright (c) 2017-2018 The qitmeer developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Qitmeer/qitmeer/core/types"
+	"github.com/Qitmeer/qitmeer/log"
+)
+
+// txEventQueueSize is the max number of events queued for the handlers, past
+// which new events are dropped until the handlers catch up.
+const txEventQueueSize = 1000
+
+// TxEventReason is the reason of an event of a transaction of the pool.
+type TxEventReason int
+
+const (
+	// TxAccepted is the reason of a transaction accepted to the pool.
+	TxAccepted TxEventReason = iota
+
+	// TxRemoved is the reason of a transaction removed by a caller of
+	// RemoveTransaction, such as when it's mined in a block.
+	TxRemoved
+
+	// TxDoubleSpent is the reason of a transaction spending an output also
+	// spent by a mined transaction.
+	TxDoubleSpent
+
+	// TxExpired is the reason of a transaction which expired.
+	TxExpired
+
+	// TxParentRemoved is the reason of a transaction spending an output of
+	// a removed transaction.
+	TxParentRemoved
+)
+
+// Map of TxEventReason values back to their names for pretty printing.
+var txEventReasonStrings = map[TxEventReason]string{
+	TxAccepted:      "accepted",
+	TxRemoved:       "removed",
+	TxDoubleSpent:   "double-spent",
+	TxExpired:       "expired",
+	TxParentRemoved: "parent-removed",
+}
+
+// String returns the TxEventReason as a human-readable name.
+func (r TxEventReason) String() string {
+	if s := txEventReasonStrings[r]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Unknown TxEventReason (%d)", int(r))
+}
+
+// TxEvent is the acceptance or the eviction of a transaction of the pool.
+type TxEvent struct {
+	Tx     *types.Tx
+	Reason TxEventReason
+}
+
+// Accepted returns whether the event is the acceptance of the transaction.
+func (e *TxEvent) Accepted() bool {
+	return e.Reason == TxAccepted
+}
+
+// TxEventHandler is a callback of the events of the transactions of a pool.
+type TxEventHandler func(event *TxEvent)
+
+// txEventBus delivers the events of the transactions of a pool to the
+// registered handlers.  The events are queued while the pool is locked, and
+// the handlers are called in order by a dedicated goroutine, without the
+// lock, so that they may use the pool.
+type txEventBus struct {
+	mtx      sync.RWMutex
+	handlers map[int]TxEventHandler
+	nextID   int
+	queue    chan *TxEvent
+
+	// dropped is the number of events dropped as the queue was full.  It's
+	// guarded by the lock of the pool, which publish is called with.
+	dropped uint64
+}
+
+// subscribe registers the passed handler, and returns the function to call
+// to unregister it.  The goroutine calling the handlers is started with the
+// first subscription, and lasts as long as the pool.
+func (b *txEventBus) subscribe(handler TxEventHandler) func() {
+	b.mtx.Lock()
+	if b.handlers == nil {
+		b.handlers = make(map[int]TxEventHandler)
+		b.queue = make(chan *TxEvent, txEventQueueSize)
+		go b.dispatch(b.queue)
+	}
+	id := b.nextID
+	b.nextID++
+	b.handlers[id] = handler
+	b.mtx.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mtx.Lock()
+			delete(b.handlers, id)
+			b.mtx.Unlock()
+		})
+	}
+}
+
+// publish queues the event of the passed transaction, unless no handler is
+// registered.  The event is dropped when the queue is full, so that the pool
+// never waits for the handlers.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (b *txEventBus) publish(tx *types.Tx, reason TxEventReason) {
+	b.mtx.RLock()
+	subscribed := len(b.handlers) > 0
+	b.mtx.RUnlock()
+	if !subscribed {
+		return
+	}
+	select {
+	case b.queue <- &TxEvent{Tx: tx, Reason: reason}:
+	default:
+		b.dropped++
+		log.Warn("Dropped mempool transaction event", "tx", tx.Hash(),
+			"reason", reason, "dropped", b.dropped)
+	}
+}
+
+// dispatch calls the registered handlers for every queued event.  It must be
+// run as a goroutine.
+func (b *txEventBus) dispatch(queue <-chan *TxEvent) {
+	for event := range queue {
+		b.mtx.RLock()
+		handlers := make([]TxEventHandler, 0, len(b.handlers))
+		for _, handler := range b.handlers {
+			handlers = append(handlers, handler)
+		}
+		b.mtx.RUnlock()
+
+		for _, handler := range handlers {
+			handler(event)
+		}
+	}
+}
+
+// SubscribeTxEvents registers the passed handler, which is called for every
+// transaction accepted to or evicted from the pool, and returns the function
+// to call to unregister it.  The handlers are called in order by a dedicated
+// goroutine, without the pool locked.  Events are dropped rather than waited
+// for when the handlers don't keep up, so they should return quickly.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) SubscribeTxEvents(handler TxEventHandler) func() {
+	return mp.events.subscribe(handler)
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2017-2018 The qitmeer developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+// DefaultFeeBuckets are the default lower bounds, in atoms per 1000 bytes, of
+// the buckets of the fee histogram of the pool, which are log-scaled around
+// the default min relay fee.
+var DefaultFeeBuckets = []int64{0, 1e3, 2e3, 5e3, 1e4, 2e4, 5e4, 1e5, 2e5,
+	5e5, 1e6, 2e6, 5e6, 1e7}
+
+// FeeBucket is a range of fee rates of the fee histogram of the pool, from its
+// min fee rate up to the one of the next bucket.
+type FeeBucket struct {
+	// MinFeePerKB is the lowest fee rate in atoms per 1000 bytes of the
+	// bucket.
+	MinFeePerKB int64 `json:"minfeerate"`
+
+	// Count and Size are the number and the total size in bytes of the
+	// transactions of the bucket.
+	Count int   `json:"count"`
+	Size  int64 `json:"size"`
+
+	// CumulativeSize is the total size in bytes of the transactions of the
+	// bucket and of the buckets of higher fee rates, which is the size of
+	// the pool paying at least the min fee rate of the bucket.
+	CumulativeSize int64 `json:"cumulativesize"`
+}
+
+// FeeHistogram returns the distribution of the fee rates of the transactions
+// of the pool, in buckets of increasing fee rates whose lower bounds are the
+// FeeBuckets of the policy, or DefaultFeeBuckets when not set.  Every bucket
+// is returned, even when empty, and the transactions paying less than the
+// lowest bound are counted in the first one.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) FeeHistogram() []FeeBucket {
+	bounds := mp.cfg.Policy.FeeBuckets
+	if len(bounds) == 0 {
+		bounds = DefaultFeeBuckets
+	}
+	buckets := make([]FeeBucket, len(bounds))
+	for i, bound := range bounds {
+		buckets[i].MinFeePerKB = bound
+	}
+
+	mp.mtx.RLock()
+	for _, desc := range mp.pool {
+		i := len(buckets) - 1
+		for i > 0 && desc.FeePerKB < buckets[i].MinFeePerKB {
+			i--
+		}
+		buckets[i].Count++
+		buckets[i].Size += int64(desc.Tx.Transaction().SerializeSize())
+	}
+	mp.mtx.RUnlock()
+
+	var cumulativeSize int64
+	for i := len(buckets) - 1; i >= 0; i-- {
+		cumulativeSize += buckets[i].Size
+		buckets[i].CumulativeSize = cumulativeSize
+	}
+	return buckets
+}
@@ -0,0 +1,51 @@
+package mempool
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/types"
+)
+
+// TestFeeHistogram ensures the transactions of the pool are counted in the
+// buckets of their fee rates, and the sizes accumulated from the highest fee
+// rates.
+func TestFeeHistogram(t *testing.T) {
+	mp := New(&Config{Policy: Policy{FeeBuckets: []int64{1000, 5000, 10000}}})
+	feeRates := []int64{0, 1000, 4999, 5000, 20000, 20000}
+	var size int64
+	for i, feeRate := range feeRates {
+		tx := newPersistTestTx(uint64(i+1),
+			types.NewOutPoint(&hash.Hash{0x06}, uint32(i)))
+		mp.pool[*tx.Hash()] = &TxDesc{
+			TxDesc: types.TxDesc{Tx: tx, FeePerKB: feeRate},
+		}
+		size = int64(tx.Transaction().SerializeSize())
+	}
+
+	// The transactions only differ by their amounts and outpoints, so
+	// they have the same size.
+	want := []FeeBucket{
+		{MinFeePerKB: 1000, Count: 3, Size: 3 * size, CumulativeSize: 6 * size},
+		{MinFeePerKB: 5000, Count: 1, Size: size, CumulativeSize: 3 * size},
+		{MinFeePerKB: 10000, Count: 2, Size: 2 * size, CumulativeSize: 2 * size},
+	}
+	if got := mp.FeeHistogram(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got histogram %+v, want %+v", got, want)
+	}
+
+	// The default buckets are log-scaled and returned even when empty.
+	mp = New(&Config{})
+	got := mp.FeeHistogram()
+	if len(got) != len(DefaultFeeBuckets) {
+		t.Fatalf("got %d default buckets, want %d", len(got),
+			len(DefaultFeeBuckets))
+	}
+	for i, bucket := range got {
+		if bucket != (FeeBucket{MinFeePerKB: DefaultFeeBuckets[i]}) {
+			t.Errorf("got default bucket %+v, want an empty bucket from %d",
+				bucket, DefaultFeeBuckets[i])
+		}
+	}
+}
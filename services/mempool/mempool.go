@@ -29,10 +29,21 @@ type TxPool struct {
 	mtx           sync.RWMutex
 	cfg           Config
 	pool          map[hash.Hash]*TxDesc
-	orphans       map[hash.Hash]*types.Tx
+	orphans       map[hash.Hash]*orphanTx
 	orphansByPrev map[hash.Hash]map[hash.Hash]*types.Tx
 	outpoints     map[types.TxOutPoint]*types.Tx
 
+	// orphanBytes is the total serialized size of the orphans.
+	orphanBytes int64
+
+	// snapshot is the snapshot of the pool returned by Snapshot until the
+	// pool changes.
+	snapshot *TxSnapshot
+
+	// events delivers the acceptances and the evictions of transactions
+	// to the subscribed handlers.
+	events txEventBus
+
 	pennyTotal    float64 // exponentially decaying total for penny spends.
 	lastPennyUnix int64   // unix time of last ``penny spend''
 }
@@ -43,12 +54,24 @@ func New(cfg *Config) *TxPool {
 	return &TxPool{
 		cfg:           *cfg,
 		pool:          make(map[hash.Hash]*TxDesc),
-		orphans:       make(map[hash.Hash]*types.Tx),
+		orphans:       make(map[hash.Hash]*orphanTx),
 		orphansByPrev: make(map[hash.Hash]map[hash.Hash]*types.Tx),
 		outpoints:     make(map[types.TxOutPoint]*types.Tx),
 	}
 }
 
+// orphanTx is a transaction of the orphan pool, whose inputs aren't all known
+// yet.
+type orphanTx struct {
+	tx    *types.Tx
+	size  int64
+	added time.Time
+
+	// expiration is the time after which the orphan is evicted, or zero if
+	// it doesn't expire.
+	expiration time.Time
+}
+
 // TxDesc is a descriptor containing a transaction in the mempool along with
 // additional metadata.
 type TxDesc struct {
@@ -80,7 +103,7 @@ func (mp *TxPool) TxDescs() []*TxDesc {
 // RemoveTransaction.  See the comment for RemoveTransaction for more details.
 //
 // This function MUST be called with the mempool lock held (for writes).
-func (mp *TxPool) removeTransaction(theTx *types.Tx, removeRedeemers bool) {
+func (mp *TxPool) removeTransaction(theTx *types.Tx, removeRedeemers bool, reason TxEventReason) {
 	tx := theTx.Transaction()
 	txHash := theTx.Hash()
 	if removeRedeemers {
@@ -88,7 +111,7 @@ func (mp *TxPool) removeTransaction(theTx *types.Tx, removeRedeemers bool) {
 		for i := uint32(0); i < uint32(len(tx.TxOut)); i++ {
 			outpoint := types.NewOutPoint(txHash, i)
 			if txRedeemer, exists := mp.outpoints[*outpoint]; exists {
-				mp.removeTransaction(txRedeemer, true)
+				mp.removeTransaction(txRedeemer, true, TxParentRemoved)
 			}
 		}
 	}
@@ -107,7 +130,9 @@ func (mp *TxPool) removeTransaction(theTx *types.Tx, removeRedeemers bool) {
 			delete(mp.outpoints, txIn.PreviousOut)
 		}
 		delete(mp.pool, *txHash)
+		mp.snapshot = nil
 		atomic.StoreInt64(&mp.lastUpdated, time.Now().Unix())
+		mp.events.publish(theTx, reason)
 	}
 }
 
@@ -120,7 +145,7 @@ func (mp *TxPool) removeTransaction(theTx *types.Tx, removeRedeemers bool) {
 func (mp *TxPool) RemoveTransaction(tx *types.Tx, removeRedeemers bool) {
 	// Protect concurrent access.
 	mp.mtx.Lock()
-	mp.removeTransaction(tx, removeRedeemers)
+	mp.removeTransaction(tx, removeRedeemers, TxRemoved)
 	mp.mtx.Unlock()
 }
 
@@ -137,13 +162,47 @@ func (mp *TxPool) RemoveDoubleSpends(tx *types.Tx) {
 	for _, txIn := range tx.Transaction().TxIn {
 		if txRedeemer, ok := mp.outpoints[txIn.PreviousOut]; ok {
 			if !txRedeemer.Hash().IsEqual(tx.Hash()) {
-				mp.removeTransaction(txRedeemer, true)
+				mp.removeTransaction(txRedeemer, true, TxDoubleSpent)
 			}
 		}
 	}
 	mp.mtx.Unlock()
 }
 
+// ConflictsWith returns the hashes of the transactions in the pool which spend
+// any of the inputs of the passed transaction, which is the set of
+// transactions it would replace.  The passed transaction itself is never
+// returned.
+//
+// This is part of the mining.TxSource interface implementation and is safe for
+// concurrent access as required by the interface contract.
+func (mp *TxPool) ConflictsWith(tx *types.Tx) []*hash.Hash {
+	mp.mtx.RLock()
+	conflicts := conflictsWith(tx, mp.outpoints)
+	mp.mtx.RUnlock()
+	return conflicts
+}
+
+// conflictsWith returns the hashes of the transactions of the passed outpoint
+// index which spend any of the inputs of the passed transaction, other than
+// itself, once each in the order of its inputs.
+func conflictsWith(tx *types.Tx, outpoints map[types.TxOutPoint]*types.Tx) []*hash.Hash {
+	var conflicts []*hash.Hash
+	seen := make(map[hash.Hash]struct{})
+	for _, txIn := range tx.Tx.TxIn {
+		txRedeemer, ok := outpoints[txIn.PreviousOut]
+		if !ok || txRedeemer.Hash().IsEqual(tx.Hash()) {
+			continue
+		}
+		if _, ok := seen[*txRedeemer.Hash()]; ok {
+			continue
+		}
+		seen[*txRedeemer.Hash()] = struct{}{}
+		conflicts = append(conflicts, txRedeemer.Hash())
+	}
+	return conflicts
+}
+
 // addTransaction adds the passed transaction to the memory pool.  It should
 // not be called directly as it doesn't perform any validation.  This is a
 // helper for maybeAcceptTransaction.
@@ -167,6 +226,7 @@ func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint,
 	for _, txIn := range msgTx.TxIn {
 		mp.outpoints[txIn.PreviousOut] = tx
 	}
+	mp.snapshot = nil
 	atomic.StoreInt64(&mp.lastUpdated, time.Now().Unix())
 
 	// Add unconfirmed address index entries associated with the transaction
@@ -177,6 +237,7 @@ func (mp *TxPool) addTransaction(utxoView *blockchain.UtxoViewpoint,
 	if mp.cfg.ExistsAddrIndex != nil {
 		mp.cfg.ExistsAddrIndex.AddUnconfirmedTx(msgTx)
 	}
+	mp.events.publish(tx, TxAccepted)
 }
 
 //Call addTransaction
@@ -391,6 +452,13 @@ func (mp *TxPool) maybeAcceptTransaction(tx *types.Tx, isNew, rateLimit, allowHi
 		return nil, txRuleError(message.RejectNonstandard, str)
 	}
 
+	// Don't allow transactions making chains of unconfirmed transactions
+	// exceed the package limits.
+	err = mp.checkPackageLimits(tx)
+	if err != nil {
+		return nil, err
+	}
+
 	// Don't allow transactions with fees too low to get into a mined block.
 	serializedSize := int64(msgTx.SerializeSize())
 	minFee := calcMinRequiredTxRelayFee(serializedSize,
@@ -635,10 +703,11 @@ func (mp *TxPool) removeOrphan(txHash *hash.Hash) {
 	log.Trace(fmt.Sprintf("Removing orphan transaction %v", txHash))
 
 	// Nothing to do if passed tx is not an orphan.
-	tx, exists := mp.orphans[*txHash]
+	otx, exists := mp.orphans[*txHash]
 	if !exists {
 		return
 	}
+	tx := otx.tx
 
 	// Remove the reference from the previous orphan index.
 	for _, txIn := range tx.Transaction().TxIn {
@@ -656,6 +725,8 @@ func (mp *TxPool) removeOrphan(txHash *hash.Hash) {
 
 	// Remove the transaction from the orphan pool.
 	delete(mp.orphans, *txHash)
+	mp.orphanBytes -= otx.size
+	mp.snapshot = nil
 }
 
 // RemoveOrphan removes the passed orphan transaction from the orphan pool and
@@ -753,36 +824,88 @@ func (mp *TxPool) processOrphans(h *hash.Hash) []*types.Tx {
 	return acceptedTxns
 }
 
-// addOrphan adds an orphan transaction to the orphan pool.
+// addOrphan adds an orphan transaction to the orphan pool.  The expired
+// orphans are evicted first, then the orphans needed to fit the new one in the
+// limits of the pool.
 //
 // This function MUST be called with the mempool lock held (for writes).
 func (mp *TxPool) addOrphan(tx *types.Tx) {
-	// TODO addOrphan
-	/*
-		// Nothing to do if no orphans are allowed.
-		if mp.cfg.Policy.MaxOrphanTxs <= 0 {
-			return
-		}
+	// Nothing to do if no orphans are allowed.
+	if mp.cfg.Policy.MaxOrphanTxs <= 0 {
+		return
+	}
 
-		// Limit the number orphan transactions to prevent memory exhaustion.  A
-		// random orphan is evicted to make room if needed.
-		mp.limitNumOrphans()
+	now := time.Now()
+	size := int64(tx.Transaction().SerializeSize())
+	mp.expireOrphans(now)
+	mp.limitOrphans(size)
 
-		mp.orphans[*tx.Hash()] = tx
-		for _, txIn := range tx.MsgTx().TxIn {
-			originTxHash := txIn.PreviousOutPoint.Hash
-			if _, exists := mp.orphansByPrev[originTxHash]; !exists {
-				mp.orphansByPrev[originTxHash] =
-					make(map[chainhash.Hash]*dcrutil.Tx)
-			}
-			mp.orphansByPrev[originTxHash][*tx.Hash()] = tx
+	otx := &orphanTx{tx: tx, size: size, added: now}
+	if mp.cfg.Policy.OrphanTxExpiry > 0 {
+		otx.expiration = now.Add(mp.cfg.Policy.OrphanTxExpiry)
+	}
+	mp.orphans[*tx.Hash()] = otx
+	mp.orphanBytes += size
+	for _, txIn := range tx.Transaction().TxIn {
+		originTxHash := txIn.PreviousOut.Hash
+		if _, exists := mp.orphansByPrev[originTxHash]; !exists {
+			mp.orphansByPrev[originTxHash] =
+				make(map[hash.Hash]*types.Tx)
 		}
-	*/
+		mp.orphansByPrev[originTxHash][*tx.Hash()] = tx
+	}
+	mp.snapshot = nil
 
 	log.Debug(fmt.Sprintf("Stored orphan transaction %v (total: %d)", tx.Hash(),
 		len(mp.orphans)))
 }
 
+// limitOrphans evicts orphans until one more orphan of the passed size fits
+// in the limits of the orphan pool.  The oldest orphans are evicted while the
+// pool holds the max number of orphans, then the largest ones while their
+// total size would exceed the max.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) limitOrphans(size int64) {
+	for len(mp.orphans) > 0 && len(mp.orphans) >= mp.cfg.Policy.MaxOrphanTxs {
+		var oldest *orphanTx
+		for _, otx := range mp.orphans {
+			if oldest == nil || otx.added.Before(oldest.added) {
+				oldest = otx
+			}
+		}
+		log.Debug(fmt.Sprintf("Evicting oldest orphan transaction %v",
+			oldest.tx.Hash()))
+		mp.removeOrphan(oldest.tx.Hash())
+	}
+
+	maxBytes := mp.cfg.Policy.MaxOrphanTxBytes
+	for len(mp.orphans) > 0 && maxBytes > 0 && mp.orphanBytes+size > maxBytes {
+		var largest *orphanTx
+		for _, otx := range mp.orphans {
+			if largest == nil || otx.size > largest.size {
+				largest = otx
+			}
+		}
+		log.Debug(fmt.Sprintf("Evicting largest orphan transaction %v",
+			largest.tx.Hash()))
+		mp.removeOrphan(largest.tx.Hash())
+	}
+}
+
+// expireOrphans evicts the orphans which expired at the passed time.
+//
+// This function MUST be called with the mempool lock held (for writes).
+func (mp *TxPool) expireOrphans(now time.Time) {
+	for txHash, otx := range mp.orphans {
+		if !otx.expiration.IsZero() && now.After(otx.expiration) {
+			log.Debug(fmt.Sprintf("Evicting expired orphan transaction %v",
+				otx.tx.Hash()))
+			mp.removeOrphan(&txHash)
+		}
+	}
+}
+
 // ProcessOrphans determines if there are any orphans which depend on the passed
 // transaction hash (it is possible that they are no longer orphans) and
 // potentially accepts them to the memory pool.  It repeats the process for the
@@ -933,19 +1056,24 @@ func (mp *TxPool) MiningDescs() []*types.TxDesc {
 }
 
 // pruneExpiredTx prunes expired transactions from the mempool that may no longer
-// be able to be included into a block.
+// be able to be included into a block, or which are older than the expiry of
+// the mempool, along with the expired orphans.
 //
 // This function MUST be called with the mempool lock held (for writes).
 func (mp *TxPool) pruneExpiredTx() {
 	nextBlockHeight := mp.cfg.BestHeight() + 1
+	now := time.Now()
+	expiry := mp.cfg.Policy.MempoolExpiry
 
 	for _, tx := range mp.pool {
-		if blockchain.IsExpired(tx.Tx, nextBlockHeight) {
+		if blockchain.IsExpired(tx.Tx, nextBlockHeight) ||
+			(expiry > 0 && now.Sub(tx.Added) > expiry) {
 			log.Debug(fmt.Sprintf("Pruning expired transaction %v from the mempool",
 				tx.Tx.Hash()))
-			mp.removeTransaction(tx.Tx, true)
+			mp.removeTransaction(tx.Tx, true, TxExpired)
 		}
 	}
+	mp.expireOrphans(now)
 }
 
 // PruneExpiredTx prunes expired transactions from the mempool that may no longer
@@ -0,0 +1,187 @@
+package mempool
+
+import (
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/blockchain"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestConflictsWith ensures the pool and its snapshots report the transactions
+// spending the same inputs as a replacement, once each.
+func TestConflictsWith(t *testing.T) {
+	spend := func(indexes ...uint32) *types.Tx {
+		tx := types.NewTransaction()
+		for _, index := range indexes {
+			tx.AddTxIn(&types.TxInput{
+				PreviousOut: *types.NewOutPoint(&hash.Hash{0x01}, index),
+				Sequence:    types.MaxTxInSequenceNum,
+			})
+		}
+		tx.AddTxOut(&types.TxOutput{Amount: uint64(len(indexes))})
+		return types.NewTx(tx)
+	}
+	mp := New(&Config{})
+	tx1 := spend(0, 1)
+	tx2 := spend(2)
+	tx3 := spend(3)
+	for _, tx := range []*types.Tx{tx1, tx2, tx3} {
+		mp.AddTransaction(blockchain.NewUtxoViewpoint(), tx, 1, 1000)
+	}
+
+	tests := []struct {
+		name string
+		tx   *types.Tx
+		want []*hash.Hash
+	}{
+		{"no overlap", spend(4), nil},
+		{"in the pool", tx1, nil},
+		{"one input", spend(1), []*hash.Hash{tx1.Hash()}},
+		{"both inputs", spend(0, 1), []*hash.Hash{tx1.Hash()}},
+		{"two transactions", spend(2, 4, 0),
+			[]*hash.Hash{tx2.Hash(), tx1.Hash()}},
+	}
+	snapshot := mp.Snapshot()
+	for _, test := range tests {
+		if got := mp.ConflictsWith(test.tx); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: pool conflicts %v, want %v", test.name, got,
+				test.want)
+		}
+		if got := snapshot.ConflictsWith(test.tx); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: snapshot conflicts %v, want %v", test.name,
+				got, test.want)
+		}
+	}
+
+	// Removed transactions no longer conflict.
+	mp.RemoveTransaction(tx1, false)
+	if got := mp.ConflictsWith(spend(0)); got != nil {
+		t.Errorf("removed transaction conflicts: %v", got)
+	}
+}
+
+// TestTxEvents ensures the subscribed handlers are called for the accepted and
+// the evicted transactions, with the reason of their eviction.
+func TestTxEvents(t *testing.T) {
+	spend := func(index uint32, amount uint64) *types.Tx {
+		tx := types.NewTransaction()
+		tx.AddTxIn(&types.TxInput{
+			PreviousOut: *types.NewOutPoint(&hash.Hash{0x01}, index),
+			Sequence:    types.MaxTxInSequenceNum,
+		})
+		tx.AddTxOut(&types.TxOutput{Amount: amount})
+		return types.NewTx(tx)
+	}
+	mp := New(&Config{})
+	events := make(chan *TxEvent, 10)
+	unsubscribe := mp.SubscribeTxEvents(func(event *TxEvent) {
+		// The pool isn't locked while the handlers are called.
+		mp.HaveTransaction(event.Tx.Hash())
+		events <- event
+	})
+	expect := func(tx *types.Tx, reason TxEventReason) {
+		t.Helper()
+		select {
+		case event := <-events:
+			if event.Tx != tx || event.Reason != reason {
+				t.Errorf("got event %v of %v, want %v of %v", event.Reason,
+					event.Tx.Hash(), reason, tx.Hash())
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no event %v of %v", reason, tx.Hash())
+		}
+	}
+
+	tx1 := spend(0, 1)
+	tx2 := spend(1, 1)
+	mp.AddTransaction(blockchain.NewUtxoViewpoint(), tx1, 1, 1000)
+	expect(tx1, TxAccepted)
+	mp.AddTransaction(blockchain.NewUtxoViewpoint(), tx2, 1, 1000)
+	expect(tx2, TxAccepted)
+
+	mp.RemoveTransaction(tx1, false)
+	expect(tx1, TxRemoved)
+	mp.RemoveDoubleSpends(spend(1, 2))
+	expect(tx2, TxDoubleSpent)
+
+	// No event is delivered once unsubscribed.
+	unsubscribe()
+	mp.AddTransaction(blockchain.NewUtxoViewpoint(), tx1, 1, 1000)
+	select {
+	case event := <-events:
+		t.Errorf("got event %v after unsubscribing", event.Reason)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// TestOrphanLimits ensures the orphans are evicted by age past the max number
+// of orphans, by size past their max total size, and once expired.
+func TestOrphanLimits(t *testing.T) {
+	orphan := func(index uint32, outputs int) *types.Tx {
+		tx := types.NewTransaction()
+		tx.AddTxIn(&types.TxInput{
+			PreviousOut: *types.NewOutPoint(&hash.Hash{0x02}, index),
+			Sequence:    types.MaxTxInSequenceNum,
+		})
+		for i := 0; i < outputs; i++ {
+			tx.AddTxOut(&types.TxOutput{Amount: 1})
+		}
+		return types.NewTx(tx)
+	}
+	have := func(mp *TxPool, txs ...*types.Tx) {
+		t.Helper()
+		if len(mp.orphans) != len(txs) {
+			t.Fatalf("got %d orphans, want %d", len(mp.orphans), len(txs))
+		}
+		var size int64
+		for _, tx := range txs {
+			if !mp.isOrphanInPool(tx.Hash()) {
+				t.Fatalf("orphan %v not in the pool", tx.Hash())
+			}
+			size += int64(tx.Transaction().SerializeSize())
+		}
+		if mp.orphanBytes != size {
+			t.Fatalf("got %d orphan bytes, want %d", mp.orphanBytes, size)
+		}
+	}
+	tx1, tx2, tx3 := orphan(1, 1), orphan(2, 1), orphan(3, 1)
+	big := orphan(4, 40)
+	small := int64(tx1.Transaction().SerializeSize())
+	bigSize := int64(big.Transaction().SerializeSize())
+
+	// The oldest orphan is evicted past the max number of orphans.
+	mp := New(&Config{Policy: Policy{MaxOrphanTxs: 2}})
+	mp.addOrphan(tx1)
+	mp.orphans[*tx1.Hash()].added = time.Now().Add(-time.Second)
+	mp.addOrphan(tx2)
+	mp.addOrphan(tx3)
+	have(mp, tx2, tx3)
+	if _, ok := mp.orphansByPrev[hash.Hash{0x02}][*tx1.Hash()]; ok {
+		t.Errorf("evicted orphan still indexed by its parent")
+	}
+
+	// The largest orphan is evicted past the max total size.
+	mp = New(&Config{Policy: Policy{
+		MaxOrphanTxs:     10,
+		MaxOrphanTxBytes: 2*small + bigSize - 1,
+	}})
+	mp.addOrphan(tx1)
+	mp.addOrphan(big)
+	have(mp, tx1, big)
+	mp.addOrphan(tx2)
+	have(mp, tx1, tx2)
+
+	// The orphans are evicted once expired.
+	mp = New(&Config{Policy: Policy{
+		MaxOrphanTxs:   10,
+		OrphanTxExpiry: time.Minute,
+	}})
+	mp.addOrphan(tx1)
+	mp.addOrphan(tx2)
+	mp.expireOrphans(time.Now())
+	have(mp, tx1, tx2)
+	mp.expireOrphans(time.Now().Add(2 * time.Minute))
+	have(mp)
+}
@@ -0,0 +1,304 @@
+// Copyright (c) 2017-2018 The qitmeer developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"fmt"
+
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/types"
+)
+
+const (
+	// DefaultMaxAncestorCount is the default max number of transactions of
+	// the package of a transaction and its unconfirmed ancestors.
+	DefaultMaxAncestorCount = 25
+
+	// DefaultMaxAncestorSize is the default max size in bytes of the
+	// package of a transaction and its unconfirmed ancestors.
+	DefaultMaxAncestorSize = 101000
+
+	// DefaultMaxDescendantCount is the default max number of transactions
+	// of the package of a transaction and its unconfirmed descendants.
+	DefaultMaxDescendantCount = 25
+
+	// DefaultMaxDescendantSize is the default max size in bytes of the
+	// package of a transaction and its unconfirmed descendants.
+	DefaultMaxDescendantSize = 101000
+)
+
+// PackageLimit identifies a limit of the packages of unconfirmed transactions
+// of the pool.
+type PackageLimit int
+
+const (
+	// AncestorCountLimit is the limit of the number of transactions of a
+	// transaction and its ancestors.
+	AncestorCountLimit PackageLimit = iota
+
+	// AncestorSizeLimit is the limit of the size of a transaction and its
+	// ancestors.
+	AncestorSizeLimit
+
+	// DescendantCountLimit is the limit of the number of transactions of a
+	// transaction and its descendants.
+	DescendantCountLimit
+
+	// DescendantSizeLimit is the limit of the size of a transaction and its
+	// descendants.
+	DescendantSizeLimit
+)
+
+// Map of PackageLimit values back to their names for pretty printing.
+var packageLimitStrings = map[PackageLimit]string{
+	AncestorCountLimit:   "ancestor count",
+	AncestorSizeLimit:    "ancestor size",
+	DescendantCountLimit: "descendant count",
+	DescendantSizeLimit:  "descendant size",
+}
+
+// String returns the PackageLimit as a human-readable name.
+func (l PackageLimit) String() string {
+	if s := packageLimitStrings[l]; s != "" {
+		return s
+	}
+	return fmt.Sprintf("Unknown PackageLimit (%d)", int(l))
+}
+
+// PackageLimits are the limits of the packages of unconfirmed transactions
+// the pool accepts.  A transaction is rejected when, along with its
+// unconfirmed ancestors, it exceeds the ancestor limits, or when it makes one
+// of its ancestors exceed the descendant limits along with its unconfirmed
+// descendants.  The counts and the sizes include the transaction the package
+// is of.  A zero limit is no limit.
+type PackageLimits struct {
+	MaxAncestorCount   int   `json:"maxancestorcount"`
+	MaxAncestorSize    int64 `json:"maxancestorsize"`
+	MaxDescendantCount int   `json:"maxdescendantcount"`
+	MaxDescendantSize  int64 `json:"maxdescendantsize"`
+}
+
+// PackageLimitError identifies a transaction rejected because of the limits of
+// the packages of unconfirmed transactions.
+type PackageLimitError struct {
+	// Limit is the limit which was exceeded.
+	Limit PackageLimit
+
+	// TxHash is the hash of the rejected transaction.
+	TxHash hash.Hash
+
+	// Ancestor is the hash of the ancestor whose package would exceed the
+	// descendant limits, or nil for the ancestor limits.
+	Ancestor *hash.Hash
+
+	// Value is the count or the size of the package with the transaction,
+	// and Max the limit it exceeds.
+	Value int64
+	Max   int64
+}
+
+// Error satisfies the error interface and prints human-readable errors.
+func (e PackageLimitError) Error() string {
+	if e.Ancestor != nil {
+		return fmt.Sprintf("transaction %v exceeds the %v limit of its "+
+			"ancestor %v: %d > %d", e.TxHash, e.Limit, e.Ancestor,
+			e.Value, e.Max)
+	}
+	return fmt.Sprintf("transaction %v exceeds the %v limit: %d > %d",
+		e.TxHash, e.Limit, e.Value, e.Max)
+}
+
+// packageLimitError creates an underlying PackageLimitError with the given a
+// set of arguments and returns a RuleError that encapsulates it.
+func packageLimitError(limit PackageLimit, txHash *hash.Hash,
+	ancestor *hash.Hash, value, max int64) RuleError {
+
+	return RuleError{
+		Err: PackageLimitError{
+			Limit:    limit,
+			TxHash:   *txHash,
+			Ancestor: ancestor,
+			Value:    value,
+			Max:      max,
+		},
+	}
+}
+
+// txAncestors returns the transactions of the pool the passed transaction
+// depends on, directly or not.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) txAncestors(tx *types.Tx) map[hash.Hash]*TxDesc {
+	ancestors := make(map[hash.Hash]*TxDesc)
+	queue := []*types.Tx{tx}
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		for _, txIn := range next.Transaction().TxIn {
+			parentHash := txIn.PreviousOut.Hash
+			if _, ok := ancestors[parentHash]; ok {
+				continue
+			}
+			if parent, ok := mp.pool[parentHash]; ok {
+				ancestors[parentHash] = parent
+				queue = append(queue, parent.Tx)
+			}
+		}
+	}
+	return ancestors
+}
+
+// txDescendants returns the transactions of the pool which depend on the
+// passed transaction, directly or not.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) txDescendants(tx *types.Tx) map[hash.Hash]*types.Tx {
+	descendants := make(map[hash.Hash]*types.Tx)
+	queue := []*types.Tx{tx}
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		numOutputs := uint32(len(next.Transaction().TxOut))
+		for i := uint32(0); i < numOutputs; i++ {
+			outpoint := types.NewOutPoint(next.Hash(), i)
+			child, ok := mp.outpoints[*outpoint]
+			if !ok {
+				continue
+			}
+			if _, ok := descendants[*child.Hash()]; ok {
+				continue
+			}
+			descendants[*child.Hash()] = child
+			queue = append(queue, child)
+		}
+	}
+	return descendants
+}
+
+// checkPackageLimits returns an error when accepting the passed transaction
+// would exceed the package limits of the pool.
+//
+// This function MUST be called with the mempool lock held (for reads).
+func (mp *TxPool) checkPackageLimits(tx *types.Tx) error {
+	limits := mp.cfg.Policy.PackageLimits
+	txSize := int64(tx.Transaction().SerializeSize())
+	ancestors := mp.txAncestors(tx)
+
+	count := int64(len(ancestors) + 1)
+	if max := int64(limits.MaxAncestorCount); max > 0 && count > max {
+		return packageLimitError(AncestorCountLimit, tx.Hash(), nil,
+			count, max)
+	}
+	size := txSize
+	for _, ancestor := range ancestors {
+		size += int64(ancestor.Tx.Transaction().SerializeSize())
+	}
+	if max := limits.MaxAncestorSize; max > 0 && size > max {
+		return packageLimitError(AncestorSizeLimit, tx.Hash(), nil,
+			size, max)
+	}
+
+	// Every ancestor gets the transaction as one more descendant.
+	if limits.MaxDescendantCount <= 0 && limits.MaxDescendantSize <= 0 {
+		return nil
+	}
+	for ancestorHash, ancestor := range ancestors {
+		descendants := mp.txDescendants(ancestor.Tx)
+		count := int64(len(descendants) + 2)
+		if max := int64(limits.MaxDescendantCount); max > 0 && count > max {
+			ancestorHash := ancestorHash
+			return packageLimitError(DescendantCountLimit, tx.Hash(),
+				&ancestorHash, count, max)
+		}
+		size := int64(ancestor.Tx.Transaction().SerializeSize()) + txSize
+		for _, descendant := range descendants {
+			size += int64(descendant.Transaction().SerializeSize())
+		}
+		if max := limits.MaxDescendantSize; max > 0 && size > max {
+			ancestorHash := ancestorHash
+			return packageLimitError(DescendantSizeLimit, tx.Hash(),
+				&ancestorHash, size, max)
+		}
+	}
+	return nil
+}
+
+// PackageLimits returns the limits of the packages of unconfirmed
+// transactions the pool accepts, so that chains of transactions exceeding
+// them can be avoided.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) PackageLimits() PackageLimits {
+	return mp.cfg.Policy.PackageLimits
+}
+
+// TxEntry is a transaction of the pool along with its current priority and
+// the packages of its unconfirmed ancestors and descendants.  The counts and
+// the sizes of the packages include the transaction itself.
+type TxEntry struct {
+	TxDesc
+
+	// CurrentPriority is the priority of the transaction for the next
+	// block.
+	CurrentPriority float64
+
+	AncestorCount   int
+	AncestorSize    int64
+	DescendantCount int
+	DescendantSize  int64
+
+	// Depends are the hashes of the transactions of the pool the
+	// transaction spends outputs of.
+	Depends []*hash.Hash
+}
+
+// TxEntry returns the entry of the passed transaction of the pool, or nil if
+// the transaction isn't in the pool.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) TxEntry(txHash *hash.Hash) (*TxEntry, error) {
+	mp.mtx.RLock()
+	defer mp.mtx.RUnlock()
+
+	desc, exists := mp.pool[*txHash]
+	if !exists {
+		return nil, nil
+	}
+	tx := desc.Tx
+	utxoView, err := mp.fetchInputUtxos(tx)
+	if err != nil {
+		return nil, err
+	}
+	txSize := int64(tx.Transaction().SerializeSize())
+	entry := &TxEntry{
+		TxDesc: *desc,
+		CurrentPriority: CalcPriority(tx.Transaction(), utxoView,
+			mp.cfg.BestHeight()+1, mp.cfg.BD),
+		AncestorCount:   1,
+		AncestorSize:    txSize,
+		DescendantCount: 1,
+		DescendantSize:  txSize,
+	}
+	for _, ancestor := range mp.txAncestors(tx) {
+		entry.AncestorCount++
+		entry.AncestorSize += int64(ancestor.Tx.Transaction().SerializeSize())
+	}
+	for _, descendant := range mp.txDescendants(tx) {
+		entry.DescendantCount++
+		entry.DescendantSize += int64(descendant.Transaction().SerializeSize())
+	}
+	seen := make(map[hash.Hash]struct{})
+	for _, txIn := range tx.Transaction().TxIn {
+		parentHash := txIn.PreviousOut.Hash
+		if _, ok := seen[parentHash]; ok {
+			continue
+		}
+		if _, ok := mp.pool[parentHash]; ok {
+			seen[parentHash] = struct{}{}
+			entry.Depends = append(entry.Depends, &parentHash)
+		}
+	}
+	return entry, nil
+}
@@ -0,0 +1,130 @@
+package mempool
+
+import (
+	"testing"
+
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/blockchain"
+	"github.com/Qitmeer/qitmeer/core/message"
+	"github.com/Qitmeer/qitmeer/core/types"
+)
+
+// TestPackageLimits ensures the transactions exceeding each of the package
+// limits are rejected with an error identifying the limit.
+func TestPackageLimits(t *testing.T) {
+	// spend returns a transaction with the passed number of outputs which
+	// spends the passed output.
+	spend := func(parent *hash.Hash, index uint32, outputs int) *types.Tx {
+		tx := types.NewTransaction()
+		tx.AddTxIn(&types.TxInput{
+			PreviousOut: *types.NewOutPoint(parent, index),
+			Sequence:    types.MaxTxInSequenceNum,
+		})
+		for i := 0; i < outputs; i++ {
+			tx.AddTxOut(&types.TxOutput{Amount: 1})
+		}
+		return types.NewTx(tx)
+	}
+	size := func(txs ...*types.Tx) int64 {
+		var size int64
+		for _, tx := range txs {
+			size += int64(tx.Transaction().SerializeSize())
+		}
+		return size
+	}
+
+	// A chain of transactions, and two children of the first one.
+	tx0 := spend(&hash.Hash{0x03}, 0, 2)
+	tx1 := spend(tx0.Hash(), 0, 1)
+	tx2 := spend(tx1.Hash(), 0, 1)
+	tx3 := spend(tx0.Hash(), 1, 1)
+
+	tests := []struct {
+		name     string
+		limits   PackageLimits
+		pool     []*types.Tx
+		tx       *types.Tx
+		limit    PackageLimit
+		ancestor *hash.Hash
+	}{
+		{
+			name:   "ancestor count",
+			limits: PackageLimits{MaxAncestorCount: 2},
+			pool:   []*types.Tx{tx0, tx1},
+			tx:     tx2,
+			limit:  AncestorCountLimit,
+		},
+		{
+			name:   "ancestor size",
+			limits: PackageLimits{MaxAncestorSize: size(tx0, tx1) - 1},
+			pool:   []*types.Tx{tx0},
+			tx:     tx1,
+			limit:  AncestorSizeLimit,
+		},
+		{
+			name:     "descendant count",
+			limits:   PackageLimits{MaxDescendantCount: 2},
+			pool:     []*types.Tx{tx0, tx1},
+			tx:       tx3,
+			limit:    DescendantCountLimit,
+			ancestor: tx0.Hash(),
+		},
+		{
+			name:     "descendant size",
+			limits:   PackageLimits{MaxDescendantSize: size(tx0, tx1, tx3) - 1},
+			pool:     []*types.Tx{tx0, tx1},
+			tx:       tx3,
+			limit:    DescendantSizeLimit,
+			ancestor: tx0.Hash(),
+		},
+	}
+	for _, test := range tests {
+		mp := New(&Config{Policy: Policy{PackageLimits: test.limits}})
+		if mp.PackageLimits() != test.limits {
+			t.Errorf("%s: got limits %+v, want %+v", test.name,
+				mp.PackageLimits(), test.limits)
+		}
+		for _, tx := range test.pool {
+			if err := mp.checkPackageLimits(tx); err != nil {
+				t.Fatalf("%s: pool transaction rejected: %v", test.name,
+					err)
+			}
+			mp.AddTransaction(blockchain.NewUtxoViewpoint(), tx, 1, 1000)
+		}
+
+		err := mp.checkPackageLimits(test.tx)
+		rerr, ok := err.(RuleError)
+		if !ok {
+			t.Errorf("%s: got error %v, want a rule error", test.name, err)
+			continue
+		}
+		perr, ok := rerr.Err.(PackageLimitError)
+		if !ok {
+			t.Errorf("%s: got error %v, want a package limit error",
+				test.name, err)
+			continue
+		}
+		if perr.Limit != test.limit || perr.TxHash != *test.tx.Hash() {
+			t.Errorf("%s: got %v limit of %v, want %v of %v", test.name,
+				perr.Limit, perr.TxHash, test.limit, test.tx.Hash())
+		}
+		if (perr.Ancestor == nil) != (test.ancestor == nil) ||
+			(perr.Ancestor != nil && *perr.Ancestor != *test.ancestor) {
+			t.Errorf("%s: got ancestor %v, want %v", test.name,
+				perr.Ancestor, test.ancestor)
+		}
+		if code, _ := ErrToRejectErr(err); code != message.RejectNonstandard {
+			t.Errorf("%s: got reject code %v, want %v", test.name, code,
+				message.RejectNonstandard)
+		}
+
+		// The transaction is accepted without the limits.
+		mp = New(&Config{})
+		for _, tx := range test.pool {
+			mp.AddTransaction(blockchain.NewUtxoViewpoint(), tx, 1, 1000)
+		}
+		if err := mp.checkPackageLimits(test.tx); err != nil {
+			t.Errorf("%s: rejected without limits: %v", test.name, err)
+		}
+	}
+}
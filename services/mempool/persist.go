@@ -0,0 +1,208 @@
+// Copyright (c) 2017-2018 The qitmeer developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"github.com/Qitmeer/qitmeer/log"
+)
+
+const (
+	// mempoolVersion is the version of the serialized mempool.
+	mempoolVersion = 1
+)
+
+// savedTx is a transaction of a saved mempool.
+type savedTx struct {
+	tx    *types.Tx
+	added time.Time
+	fee   int64
+}
+
+// Save writes the transactions of the pool to the passed writer, along with
+// the time they were added and their fee, so that they can be loaded back by
+// Load.  The parents are written before their children, so that they can be
+// accepted again in order.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) Save(w io.Writer) error {
+	mp.mtx.RLock()
+	descs := make([]*TxDesc, 0, len(mp.pool))
+	for _, desc := range mp.pool {
+		descs = append(descs, desc)
+	}
+	mp.mtx.RUnlock()
+	descs = sortByDependency(descs)
+
+	header := []interface{}{uint32(mempoolVersion), uint32(len(descs))}
+	for _, field := range header {
+		if err := binary.Write(w, binary.LittleEndian, field); err != nil {
+			return err
+		}
+	}
+	for _, desc := range descs {
+		serialized, err := desc.Tx.Transaction().Serialize()
+		if err != nil {
+			return err
+		}
+		fields := []interface{}{desc.Added.UnixNano(), desc.Fee,
+			uint32(len(serialized))}
+		for _, field := range fields {
+			if err := binary.Write(w, binary.LittleEndian, field); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(serialized); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sortByDependency returns the passed transactions ordered so that every
+// transaction comes after the ones it spends from.  The time the transactions
+// were added doesn't guarantee it, since the transactions of disconnected
+// blocks are added back after their children, so it only orders the rest,
+// along with their hash.
+func sortByDependency(descs []*TxDesc) []*TxDesc {
+	sort.Slice(descs, func(i, j int) bool {
+		if !descs[i].Added.Equal(descs[j].Added) {
+			return descs[i].Added.Before(descs[j].Added)
+		}
+		return bytes.Compare(descs[i].Tx.Hash()[:], descs[j].Tx.Hash()[:]) < 0
+	})
+	byHash := make(map[hash.Hash]*TxDesc, len(descs))
+	for _, desc := range descs {
+		byHash[*desc.Tx.Hash()] = desc
+	}
+
+	sorted := make([]*TxDesc, 0, len(descs))
+	visited := make(map[hash.Hash]struct{}, len(descs))
+	var visit func(desc *TxDesc)
+	visit = func(desc *TxDesc) {
+		txHash := *desc.Tx.Hash()
+		if _, ok := visited[txHash]; ok {
+			return
+		}
+		visited[txHash] = struct{}{}
+		for _, txIn := range desc.Tx.Tx.TxIn {
+			if parent, ok := byHash[txIn.PreviousOut.Hash]; ok {
+				visit(parent)
+			}
+		}
+		sorted = append(sorted, desc)
+	}
+	for _, desc := range descs {
+		visit(desc)
+	}
+	return sorted
+}
+
+// readSavedTxs returns the transactions of the mempool saved to the passed
+// reader.
+func readSavedTxs(r io.Reader) ([]*savedTx, error) {
+	var version, numTxs uint32
+	for _, field := range []interface{}{&version, &numTxs} {
+		if err := binary.Read(r, binary.LittleEndian, field); err != nil {
+			return nil, err
+		}
+	}
+	if version != mempoolVersion {
+		return nil, fmt.Errorf("unsupported mempool version %d", version)
+	}
+
+	txs := make([]*savedTx, 0, numTxs)
+	for i := uint32(0); i < numTxs; i++ {
+		var added, fee int64
+		var size uint32
+		for _, field := range []interface{}{&added, &fee, &size} {
+			if err := binary.Read(r, binary.LittleEndian, field); err != nil {
+				return nil, err
+			}
+		}
+		if size > types.MaxBlockPayload {
+			return nil, fmt.Errorf("saved transaction of %d bytes is "+
+				"too big", size)
+		}
+		serialized := make([]byte, size)
+		if _, err := io.ReadFull(r, serialized); err != nil {
+			return nil, err
+		}
+		tx := types.NewTransaction()
+		if err := tx.Deserialize(bytes.NewReader(serialized)); err != nil {
+			return nil, err
+		}
+		txs = append(txs, &savedTx{
+			tx:    types.NewTx(tx),
+			added: time.Unix(0, added),
+			fee:   fee,
+		})
+	}
+	return txs, nil
+}
+
+// Load accepts again into the pool the transactions saved to the passed
+// reader by Save, and returns the number of transactions restored and
+// dropped.  Every transaction is validated against the current state of the
+// chain, and dropped when it's no longer valid, or when it was added longer
+// ago than the expiry of the pool.  The restored transactions keep the time
+// they were first added.  Nothing is loaded when the saved mempool can't be
+// read.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) Load(r io.Reader) (int, int, error) {
+	txs, err := readSavedTxs(r)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	mp.mtx.Lock()
+	defer mp.mtx.Unlock()
+
+	now := time.Now()
+	expiry := mp.cfg.Policy.MempoolExpiry
+	var restored, dropped int
+	for _, saved := range txs {
+		txHash := saved.tx.Hash()
+		if expiry > 0 && now.Sub(saved.added) > expiry {
+			log.Debug("Dropping expired saved transaction", "tx", txHash)
+			dropped++
+			continue
+		}
+
+		// The transactions were already accepted once, so they're exempt
+		// from the priority and rate limits like the ones added back
+		// after a reorganization.
+		missingParents, err := mp.maybeAcceptTransaction(saved.tx, false,
+			false, true)
+		if err == nil && len(missingParents) > 0 {
+			err = fmt.Errorf("missing parent %v", missingParents[0])
+		}
+		if err != nil {
+			log.Debug("Dropping invalid saved transaction", "tx", txHash,
+				"err", err)
+			dropped++
+			continue
+		}
+
+		desc := mp.pool[*txHash]
+		desc.Added = saved.added
+		if desc.Fee != saved.fee {
+			log.Debug("Fee of saved transaction changed", "tx", txHash,
+				"saved", saved.fee, "fee", desc.Fee)
+		}
+		restored++
+	}
+	mp.snapshot = nil
+	return restored, dropped, nil
+}
@@ -0,0 +1,138 @@
+package mempool
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/blockchain"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"github.com/Qitmeer/qitmeer/engine/txscript"
+	"github.com/Qitmeer/qitmeer/params"
+)
+
+// newPersistTestTx returns a transaction paying the passed amount to an
+// anyone-can-spend script, which spends the passed outputs.
+func newPersistTestTx(amount uint64, prevOuts ...*types.TxOutPoint) *types.Tx {
+	tx := types.NewTransaction()
+	for _, prevOut := range prevOuts {
+		tx.AddTxIn(&types.TxInput{
+			PreviousOut: *prevOut,
+			Sequence:    types.MaxTxInSequenceNum,
+		})
+	}
+	tx.AddTxOut(&types.TxOutput{
+		Amount:   amount,
+		PkScript: []byte{txscript.OP_TRUE},
+	})
+	return types.NewTx(tx)
+}
+
+// newPersistTestPool returns a pool validating the transactions against a
+// chain whose unspent outputs are the passed outputs of the passed
+// transaction.
+func newPersistTestPool(funding *types.Tx, unspent []uint32) *TxPool {
+	return New(&Config{
+		Policy: Policy{
+			AcceptNonStd:   true,
+			MaxSigOpsPerTx: blockchain.MaxSigOpsPerBlock,
+			MempoolExpiry:  time.Hour,
+			StandardVerifyFlags: func() (txscript.ScriptFlags, error) {
+				return 0, nil
+			},
+		},
+		ChainParams: &params.PrivNetParams,
+		FetchUtxoView: func(tx *types.Tx) (*blockchain.UtxoViewpoint, error) {
+			view := blockchain.NewUtxoViewpoint()
+			for _, index := range unspent {
+				view.AddTxOut(funding, index, &hash.ZeroHash)
+			}
+			return view, nil
+		},
+		BestHeight:     func() uint64 { return 1 },
+		PastMedianTime: time.Now,
+		CalcSequenceLock: func(*types.Tx, *blockchain.UtxoViewpoint) (*blockchain.SequenceLock, error) {
+			return &blockchain.SequenceLock{BlockHeight: -1, Time: -1}, nil
+		},
+		BC: &blockchain.BlockChain{},
+	})
+}
+
+// TestSaveLoad ensures the transactions of a saved pool are loaded back into
+// a new pool when they're still valid, and dropped otherwise.
+func TestSaveLoad(t *testing.T) {
+	funding := types.NewTransaction()
+	funding.AddTxIn(&types.TxInput{
+		PreviousOut: *types.NewOutPoint(&hash.Hash{0x04}, 0),
+		Sequence:    types.MaxTxInSequenceNum,
+	})
+	for i := 0; i < 3; i++ {
+		funding.AddTxOut(&types.TxOutput{
+			Amount:   1000,
+			PkScript: []byte{txscript.OP_TRUE},
+		})
+	}
+	fundingTx := types.NewTx(funding)
+	fundingHash := fundingTx.Hash()
+
+	// A parent and its child, a transaction whose input gets spent by the
+	// chain, and a transaction which expires.
+	parent := newPersistTestTx(900, types.NewOutPoint(fundingHash, 0))
+	child := newPersistTestTx(800, types.NewOutPoint(parent.Hash(), 0))
+	spent := newPersistTestTx(900, types.NewOutPoint(fundingHash, 1))
+	old := newPersistTestTx(900, types.NewOutPoint(fundingHash, 2))
+
+	mp := newPersistTestPool(fundingTx, []uint32{0, 1, 2})
+	for _, tx := range []*types.Tx{parent, child, spent, old} {
+		if _, err := mp.ProcessTransaction(tx, false, false, true); err != nil {
+			t.Fatalf("ProcessTransaction: %v", err)
+		}
+	}
+	added := mp.pool[*parent.Hash()].Added
+	// The child is saved after its parent even if it was added before, as
+	// when the parent is added back from a disconnected block.
+	mp.pool[*child.Hash()].Added = added.Add(-time.Minute)
+	mp.pool[*old.Hash()].Added = time.Now().Add(-2 * time.Hour)
+
+	var buf bytes.Buffer
+	if err := mp.Save(&buf); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	mp = newPersistTestPool(fundingTx, []uint32{0, 2})
+	restored, dropped, err := mp.Load(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if restored != 2 || dropped != 2 {
+		t.Errorf("got %d restored and %d dropped, want 2 and 2", restored,
+			dropped)
+	}
+	for _, tx := range []*types.Tx{parent, child} {
+		if !mp.IsTransactionInPool(tx.Hash()) {
+			t.Errorf("transaction %v not restored", tx.Hash())
+		}
+	}
+	for _, tx := range []*types.Tx{spent, old} {
+		if mp.IsTransactionInPool(tx.Hash()) {
+			t.Errorf("transaction %v restored", tx.Hash())
+		}
+	}
+	if got := mp.pool[*parent.Hash()].Added; !got.Equal(added) {
+		t.Errorf("restored transaction added at %v, want %v", got, added)
+	}
+	if got := mp.pool[*child.Hash()].Fee; got != 100 {
+		t.Errorf("restored transaction fee %d, want 100", got)
+	}
+
+	// A corrupt mempool isn't loaded.
+	mp = newPersistTestPool(fundingTx, []uint32{0, 1, 2})
+	if _, _, err := mp.Load(bytes.NewReader(buf.Bytes()[:buf.Len()-1])); err == nil {
+		t.Errorf("Load of a truncated mempool succeeded")
+	}
+	if len(mp.pool) != 0 {
+		t.Errorf("got %d transactions from a truncated mempool",
+			len(mp.pool))
+	}
+}
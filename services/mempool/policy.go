@@ -7,8 +7,10 @@
 package mempool
 
 import (
+	"github.com/Qitmeer/qitmeer/core/blockchain"
 	"github.com/Qitmeer/qitmeer/core/types"
 	"github.com/Qitmeer/qitmeer/engine/txscript"
+	"time"
 )
 
 const (
@@ -54,6 +56,15 @@ const (
 	// 10000 Atoms/kB (aka. 0.0001 Qitmeer/kB)
 	DefaultMinRelayTxFee = int64(1e4)
 
+	// DefaultMaxTxVersion is the default max transaction version which is
+	// standard.
+	DefaultMaxTxVersion = 2
+
+	// DefaultMaxSigOpsPerTx is the default max number of signature
+	// operations of a standard transaction.  It's less than the max of a
+	// block since the coinbase can contain signature operations too.
+	DefaultMaxSigOpsPerTx = blockchain.MaxSigOpsPerBlock / 5
+
 	// maxRelayFeeMultiplier is the factor that we disallow fees / kB above the
 	// minimum tx fee.  At the current default minimum relay fee of 0.0001
 	// Qitmeer/kB (aka. 10000 Atom Qitmeer/kB), this results in a maximum allowed
@@ -126,6 +137,22 @@ type Policy struct {
 	// of big orphans.
 	MaxOrphanTxSize int
 
+	// MaxOrphanTxBytes is the maximum total size of the orphan
+	// transactions which can be queued, or 0 for no limit.
+	MaxOrphanTxBytes int64
+
+	// OrphanTxExpiry is the duration after which an orphan transaction is
+	// evicted, or 0 for orphans which don't expire.
+	OrphanTxExpiry time.Duration
+
+	// MempoolExpiry is the duration after which a transaction is evicted
+	// from the mempool, or 0 for transactions which don't expire.
+	MempoolExpiry time.Duration
+
+	// PackageLimits are the limits of the packages of a transaction and its
+	// unconfirmed ancestors or descendants.
+	PackageLimits
+
 	// MaxSigOpsPerTx is the maximum number of signature operations
 	// in a single transaction we will relay or mine.  It is a fraction
 	// of the max signature operations for a block.
@@ -134,6 +161,11 @@ type Policy struct {
 	// MinRelayTxFee defines the minimum transaction fee in AtomQitmeer/kB
 	MinRelayTxFee types.Amount
 
+	// FeeBuckets are the increasing lower bounds, in atoms per 1000 bytes,
+	// of the buckets of the fee histogram of the pool.  When empty,
+	// DefaultFeeBuckets are used.
+	FeeBuckets []int64
+
 	// StandardVerifyFlags defines the function to retrieve the flags to
 	// use for verifying scripts for the block after the current best block.
 	// It must set the verification flags properly depending on the result
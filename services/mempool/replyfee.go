@@ -30,3 +30,13 @@ func calcMinRequiredTxRelayFee(serializedSize int64, minRelayTxFee types.Amount)
 
 	return minFee
 }
+
+// MinRelayTxFee returns the minimum fee rate in atoms per 1000 bytes of the
+// transactions the pool accepts, which peers can be told with a feefilter
+// message not to announce cheaper transactions.  The transactions below it are
+// rejected at acceptance, so they never reach the block templates.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) MinRelayTxFee() types.Amount {
+	return mp.cfg.Policy.MinRelayTxFee
+}
@@ -0,0 +1,51 @@
+package mempool
+
+import (
+	"testing"
+
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/message"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"github.com/Qitmeer/qitmeer/engine/txscript"
+)
+
+// TestMinRelayTxFee ensures the transactions paying less than the min relay
+// fee are rejected at acceptance, so they're never mined, while the others
+// are accepted.
+func TestMinRelayTxFee(t *testing.T) {
+	funding := types.NewTransaction()
+	funding.AddTxIn(&types.TxInput{
+		PreviousOut: *types.NewOutPoint(&hash.Hash{0x07}, 0),
+		Sequence:    types.MaxTxInSequenceNum,
+	})
+	for i := 0; i < 2; i++ {
+		funding.AddTxOut(&types.TxOutput{
+			Amount:   100000,
+			PkScript: []byte{txscript.OP_TRUE},
+		})
+	}
+	fundingTx := types.NewTx(funding)
+	cheap := newPersistTestTx(99999, types.NewOutPoint(fundingTx.Hash(), 0))
+	paying := newPersistTestTx(90000, types.NewOutPoint(fundingTx.Hash(), 1))
+
+	mp := newPersistTestPool(fundingTx, []uint32{0, 1})
+	mp.cfg.Policy.MinRelayTxFee = types.Amount(DefaultMinRelayTxFee)
+	if got := mp.MinRelayTxFee(); got != types.Amount(DefaultMinRelayTxFee) {
+		t.Errorf("got min relay fee %v, want %v", got, DefaultMinRelayTxFee)
+	}
+
+	_, err := mp.ProcessTransaction(cheap, false, false, true)
+	if code, ok := extractRejectCode(err); !ok ||
+		code != message.RejectInsufficientFee {
+		t.Errorf("got error %v, want insufficient fee", err)
+	}
+	if _, err := mp.ProcessTransaction(paying, false, false, true); err != nil {
+		t.Fatalf("ProcessTransaction: %v", err)
+	}
+
+	descs := mp.MiningDescs()
+	if len(descs) != 1 || *descs[0].Tx.Hash() != *paying.Hash() {
+		t.Errorf("got %d mining descriptors, want only %v", len(descs),
+			paying.Hash())
+	}
+}
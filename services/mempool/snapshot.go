@@ -0,0 +1,146 @@
+// Copyright (c) 2017-2018 The qitmeer developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mempool
+
+import (
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"time"
+)
+
+// TxView is a read-only view of the transactions of a pool, which a block
+// template is built from.  Both TxPool and the snapshots it returns are views,
+// and other transaction sources can provide their own.
+//
+// The methods of a view must be safe for concurrent access.
+type TxView interface {
+	// LastUpdated returns the last time a transaction was added to or
+	// removed from the pool.
+	LastUpdated() time.Time
+
+	// MiningDescs returns a slice of mining descriptors for all the
+	// transactions in the pool.
+	MiningDescs() []*types.TxDesc
+
+	// HaveTransaction returns whether or not the passed transaction hash
+	// exists in the pool.
+	HaveTransaction(hash *hash.Hash) bool
+
+	// HaveAllTransactions returns whether or not all of the passed
+	// transaction hashes exist in the pool.
+	HaveAllTransactions(hashes []hash.Hash) bool
+
+	// ConflictsWith returns the hashes of the transactions in the pool
+	// which spend any of the inputs of the passed transaction.
+	ConflictsWith(tx *types.Tx) []*hash.Hash
+}
+
+// TxSnapshot is a frozen view of the transactions of the pool, as returned by
+// TxPool.Snapshot.  It implements the mining.TxSource interface, so that a
+// block template can be built from a consistent set of transactions while the
+// pool keeps changing.
+//
+// A snapshot is never modified, so it is safe for concurrent access.
+type TxSnapshot struct {
+	descs       []*types.TxDesc
+	txs         map[hash.Hash]struct{}
+	outpoints   map[types.TxOutPoint]*types.Tx
+	lastUpdated time.Time
+}
+
+// NewTxSnapshot returns a snapshot of the passed transaction descriptors along
+// with the passed additional transactions, such as orphans, which are only
+// reported by HaveTransaction.
+func NewTxSnapshot(descs []*types.TxDesc, others []*hash.Hash, lastUpdated time.Time) *TxSnapshot {
+	txs := make(map[hash.Hash]struct{}, len(descs)+len(others))
+	outpoints := make(map[types.TxOutPoint]*types.Tx)
+	for _, desc := range descs {
+		txs[*desc.Tx.Hash()] = struct{}{}
+		for _, txIn := range desc.Tx.Tx.TxIn {
+			outpoints[txIn.PreviousOut] = desc.Tx
+		}
+	}
+	for _, h := range others {
+		txs[*h] = struct{}{}
+	}
+	return &TxSnapshot{
+		descs:       descs,
+		txs:         txs,
+		outpoints:   outpoints,
+		lastUpdated: lastUpdated,
+	}
+}
+
+// LastUpdated returns the last time a transaction was added to or removed
+// from the pool before the snapshot was taken.
+func (s *TxSnapshot) LastUpdated() time.Time {
+	return s.lastUpdated
+}
+
+// MiningDescs returns a slice of mining descriptors for all the transactions
+// in the snapshot.
+func (s *TxSnapshot) MiningDescs() []*types.TxDesc {
+	descs := make([]*types.TxDesc, len(s.descs))
+	copy(descs, s.descs)
+	return descs
+}
+
+// HaveTransaction returns whether or not the passed transaction was in the
+// main pool or in the orphan pool when the snapshot was taken.
+func (s *TxSnapshot) HaveTransaction(hash *hash.Hash) bool {
+	_, ok := s.txs[*hash]
+	return ok
+}
+
+// HaveAllTransactions returns whether or not all of the passed transactions
+// were in the pool when the snapshot was taken.
+func (s *TxSnapshot) HaveAllTransactions(hashes []hash.Hash) bool {
+	for _, h := range hashes {
+		if _, ok := s.txs[h]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// ConflictsWith returns the hashes of the transactions of the snapshot which
+// spend any of the inputs of the passed transaction, other than itself.
+func (s *TxSnapshot) ConflictsWith(tx *types.Tx) []*hash.Hash {
+	return conflictsWith(tx, s.outpoints)
+}
+
+// Snapshot returns the snapshot itself, which is already frozen.
+func (s *TxSnapshot) Snapshot() TxView {
+	return s
+}
+
+// Snapshot returns a frozen view of the transactions of the pool.  The
+// snapshot is shared until the pool changes, so taking one again without any
+// change in between is free.
+//
+// This function is safe for concurrent access.
+func (mp *TxPool) Snapshot() TxView {
+	mp.mtx.RLock()
+	snapshot := mp.snapshot
+	mp.mtx.RUnlock()
+	if snapshot != nil {
+		return snapshot
+	}
+
+	mp.mtx.Lock()
+	defer mp.mtx.Unlock()
+	if mp.snapshot == nil {
+		descs := make([]*types.TxDesc, 0, len(mp.pool))
+		for _, desc := range mp.pool {
+			descs = append(descs, &desc.TxDesc)
+		}
+		orphans := make([]*hash.Hash, 0, len(mp.orphans))
+		for _, otx := range mp.orphans {
+			orphans = append(orphans, otx.tx.Hash())
+		}
+		mp.snapshot = NewTxSnapshot(descs, orphans, mp.LastUpdated())
+	}
+	return mp.snapshot
+}
@@ -0,0 +1,45 @@
+package mempool
+
+import (
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/blockchain"
+	"testing"
+)
+
+// TestSnapshot ensures a snapshot isn't affected by the pool changing after
+// it was taken, and that it is shared until the pool changes.
+func TestSnapshot(t *testing.T) {
+	mp := New(&Config{})
+	tx1 := newEstimateFeeTestTx(0)
+	tx2 := newEstimateFeeTestTx(1)
+	mp.AddTransaction(blockchain.NewUtxoViewpoint(), tx1, 1, 1000)
+
+	snapshot := mp.Snapshot()
+	if mp.Snapshot() != snapshot {
+		t.Fatal("unchanged pool returned a new snapshot")
+	}
+
+	// The pool changes in the middle of a build.
+	mp.AddTransaction(blockchain.NewUtxoViewpoint(), tx2, 1, 1000)
+	mp.RemoveTransaction(tx1, false)
+
+	descs := snapshot.MiningDescs()
+	if len(descs) != 1 || descs[0].Tx != tx1 {
+		t.Fatalf("snapshot descriptors changed: %v", descs)
+	}
+	if !snapshot.HaveTransaction(tx1.Hash()) {
+		t.Error("snapshot lost a removed transaction")
+	}
+	if snapshot.HaveTransaction(tx2.Hash()) {
+		t.Error("snapshot has an added transaction")
+	}
+
+	current := mp.Snapshot()
+	if current == snapshot {
+		t.Fatal("changed pool returned the previous snapshot")
+	}
+	if !current.HaveAllTransactions([]hash.Hash{*tx2.Hash()}) ||
+		current.HaveTransaction(tx1.Hash()) {
+		t.Error("new snapshot doesn't reflect the pool")
+	}
+}
@@ -3,21 +3,28 @@
 package miner
 
 import (
+	"context"
 	"encoding/hex"
+	ejson "encoding/json"
 	"fmt"
 	"github.com/Qitmeer/qitmeer/core/blockdag"
 	"github.com/Qitmeer/qitmeer/core/types/pow"
 	"github.com/Qitmeer/qitmeer/engine/txscript"
 	"math/rand"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/common/marshal"
+	"github.com/Qitmeer/qitmeer/core/address"
 	"github.com/Qitmeer/qitmeer/core/blockchain"
 	"github.com/Qitmeer/qitmeer/core/json"
 	"github.com/Qitmeer/qitmeer/core/types"
 	"github.com/Qitmeer/qitmeer/rpc"
+	"github.com/Qitmeer/qitmeer/services/blkmgr"
 	"github.com/Qitmeer/qitmeer/services/mining"
 )
 
@@ -33,6 +40,20 @@ const gbtNonceRange = "00000000ffffffff"
 // in the memory pool.
 const gbtRegenerateSeconds = 60
 
+const (
+	// gbtLongPollTimeout is the max duration of a long poll, after which
+	// the current template is returned even if unchanged.
+	gbtLongPollTimeout = 30 * time.Second
+
+	// gbtLongPollMargin is the time kept before the deadline of a long
+	// poll request to return the template before the request times out.
+	gbtLongPollMargin = time.Second
+
+	// gbtLongPollCheckInterval is the interval at which a long poll checks
+	// whether the template changed, such as for a new block.
+	gbtLongPollCheckInterval = time.Second
+)
+
 func (c *CPUMiner) APIs() []rpc.API {
 	return []rpc.API{
 		{
@@ -52,11 +73,13 @@ type PublicMinerAPI struct {
 	miner          *CPUMiner
 	gbtWorkState   *gbtWorkState
 	gbtCoinbaseAux *json.GetBlockTemplateResultAux
+	gbtTemplates   *templateResultCache
 }
 
 func NewPublicMinerAPI(c *CPUMiner) *PublicMinerAPI {
 	pmAPI := &PublicMinerAPI{miner: c}
 	pmAPI.gbtWorkState = &gbtWorkState{timeSource: c.timeSource}
+	pmAPI.gbtTemplates = newTemplateResultCache(gbtTemplateCacheSize)
 
 	pmAPI.gbtCoinbaseAux = &json.GetBlockTemplateResultAux{
 		Flags: hex.EncodeToString(builderScript(txscript.NewScriptBuilder().
@@ -66,13 +89,20 @@ func NewPublicMinerAPI(c *CPUMiner) *PublicMinerAPI {
 }
 
 //func (api *PublicMinerAPI) GetBlockTemplate(request *mining.TemplateRequest) (interface{}, error){
-func (api *PublicMinerAPI) GetBlockTemplate(capabilities []string) (interface{}, error) {
+
+// GetBlockTemplate returns a block template for the passed capabilities.
+// When a long poll id is passed, the request is held until the template of
+// the id is stale, or for at most gbtLongPollTimeout, as described by BIP22.
+func (api *PublicMinerAPI) GetBlockTemplate(ctx context.Context, capabilities []string, longPollID *string) (interface{}, error) {
 	// Set the default mode and override it if supplied.
 	mode := "template"
 	request := json.TemplateRequest{Mode: mode, Capabilities: capabilities}
+	if longPollID != nil {
+		request.LongPollID = *longPollID
+	}
 	switch mode {
 	case "template":
-		return handleGetBlockTemplateRequest(api, &request)
+		return handleGetBlockTemplateRequest(ctx, api, &request)
 	case "proposal":
 		//TODO LL, will be added
 		//return handleGetBlockTemplateProposal(s, request)
@@ -80,9 +110,69 @@ func (api *PublicMinerAPI) GetBlockTemplate(capabilities []string) (interface{},
 	return nil, rpc.RpcInvalidError("Invalid mode")
 }
 
+// GetBlockTemplateDelta returns the changes of the current block template
+// from the template of the passed id served before, which miners polling
+// often apply to their copy of that template instead of fetching the full
+// template again.  The full template is returned instead when the template of
+// the id is unknown, such as when it was evicted from the cache of the served
+// templates, or when the current template is for other parents.
+func (api *PublicMinerAPI) GetBlockTemplateDelta(ctx context.Context, capabilities []string, baseID string) (interface{}, error) {
+	request := json.TemplateRequest{Mode: "template", Capabilities: capabilities}
+	result, err := handleGetBlockTemplateRequest(ctx, api, &request)
+	if err != nil {
+		return nil, err
+	}
+	cur := result.(*json.GetBlockTemplateResult)
+	base := api.gbtTemplates.Get(baseID)
+	if base == nil {
+		return cur, nil
+	}
+	if delta := json.TemplateDelta(base, cur); delta != nil {
+		return delta, nil
+	}
+	return cur, nil
+}
+
+// DecodeBlockTemplate decodes the passed block template, as encoded to JSON,
+// or the passed hex of a solved block, into a view with every transaction
+// decoded and annotated by its fee and signature operations, and the coinbase
+// broken down, to debug the mismatches between the templates the node served
+// and the blocks the miners submitted.
+func (api *PublicMinerAPI) DecodeBlockTemplate(data string) (interface{}, error) {
+	var template types.BlockTemplate
+	data = strings.TrimSpace(data)
+	if strings.HasPrefix(data, "{") {
+		if err := ejson.Unmarshal([]byte(data), &template); err != nil {
+			return nil, rpc.RpcDeserializationError("Block template decode failed: %s",
+				err.Error())
+		}
+	} else {
+		if len(data)%2 != 0 {
+			data = "0" + data
+		}
+		serializedBlock, err := hex.DecodeString(data)
+		if err != nil {
+			return nil, rpc.RpcDecodeHexError(data)
+		}
+		block, err := types.NewBlockFromBytes(serializedBlock)
+		if err != nil {
+			return nil, rpc.RpcDeserializationError("Block decode failed: %s", err.Error())
+		}
+		template.Block = block.Block()
+	}
+	result, err := marshal.MarshalJsonBlockTemplate(&template, api.miner.params)
+	if err != nil {
+		return nil, rpc.RpcDeserializationError("Block template decode failed: %s",
+			err.Error())
+	}
+	return result, nil
+}
+
 //LL
 //Attempts to submit new block to network.
 //See https://en.bitcoin.it/wiki/BIP_0022 for full specification
+// A rejected block is returned as a rule error whose data is the reject
+// reason, such as "high-hash" or "stale-prevblk".
 func (api *PublicMinerAPI) SubmitBlock(hexBlock string) (interface{}, error) {
 	// Deserialize the hexBlock.
 	m := api.miner
@@ -102,57 +192,62 @@ func (api *PublicMinerAPI) SubmitBlock(hexBlock string) (interface{}, error) {
 		return nil, rpc.RpcDeserializationError("Block decode failed: %s", err.Error())
 	}
 
-	// Because it's asynchronous, so you must ensure that all tips are referenced
-	parents := blockdag.NewIdSet()
-	for _, v := range block.Block().Parents {
-		parents.Add(api.miner.blockManager.GetChain().BlockIndex().GetDAGBlockID(v))
-	}
-	height, ok := api.miner.blockManager.GetChain().BlockDAG().CheckSubMainChainTip(parents.List())
-	if !ok {
-		return fmt.Sprintf("The tips of block is expired."), nil
+	chain := &submitChain{
+		BlockChain: m.blockManager.GetChain(),
+		bm:         m.blockManager,
 	}
-	block.SetHeight(height)
-	// Process this block using the same rules as blocks coming from other
-	// nodes.  This will in turn relay it to the network like normal.
-	isOrphan, err := api.miner.blockManager.ProcessBlock(block, blockchain.BFNone)
+	accepted, err := mining.SubmitBlock(chain, block.Block(), m.params)
 	if err != nil {
-		// Anything other than a rule violation is an unexpected error,
-		// so log that error as an internal error.
-		rErr, ok := err.(blockchain.RuleError)
-		if !ok {
-			return fmt.Sprintf("Unexpected error while processing "+
-				"block submitted via miner: %s", err.Error()), nil
-		}
-		// Occasionally errors are given out for timing errors with
-		// ReduceMinDifficulty and high block works that is above
-		// the target. Feed these to debug.
-		if api.miner.params.ReduceMinDifficulty &&
-			rErr.ErrorCode == blockchain.ErrHighHash {
-			return fmt.Sprintf("Block submitted via miner rejected "+
-				"because of ReduceMinDifficulty time sync failure: %s", err.Error()), nil
+		reason := mining.ProposalRejectReason(err)
+		if rerr, ok := err.(mining.ProposalRejectError); ok && rerr.Err != nil {
+			err = rerr.Err
 		}
-
-		if rErr.ErrorCode == blockchain.ErrDuplicateBlock {
-			return fmt.Sprintf(rErr.Description, err.Error()), nil
+		return nil, &rpc.RPCError{
+			Code:    rpc.ErrCodeRule,
+			Message: fmt.Sprintf("Block submitted via miner rejected: %v", err),
+			Data:    reason,
 		}
-		// Other rule errors should be reported.
-		return fmt.Sprintf("Block submitted via miner rejected: %s", err.Error()), nil
-	}
-
-	if isOrphan {
-		return fmt.Sprintf("Block submitted via miner is an orphan building " +
-			"on parent"), nil
 	}
 
 	// The block was accepted.
-	coinbaseTxOuts := block.Block().Transactions[0].TxOut
+	coinbaseTxOuts := accepted.Block().Transactions[0].TxOut
 	coinbaseTxGenerated := uint64(0)
 	for _, out := range coinbaseTxOuts {
 		coinbaseTxGenerated += out.Amount
 	}
-	return fmt.Sprintf("Block submitted accepted  hash %s, height %d, order %s amount %d", block.Hash().String(),
-		block.Height(), blockdag.GetOrderLogStr(uint(block.Order())), coinbaseTxGenerated), nil
+	return fmt.Sprintf("Block submitted accepted  hash %s, height %d, order %s amount %d", accepted.Hash().String(),
+		accepted.Height(), blockdag.GetOrderLogStr(uint(accepted.Order())), coinbaseTxGenerated), nil
+
+}
+
+// submitChain is the chain and the block manager of the miner, which solved
+// blocks are submitted to.
+type submitChain struct {
+	*blockchain.BlockChain
+	bm *blkmgr.BlockManager
+}
+
+// ProcessBlock processes the block with the block manager, which relays it to
+// the network, at the height of its parents.
+func (c *submitChain) ProcessBlock(block *types.SerializedBlock, flags blockchain.BehaviorFlags) (bool, error) {
+	// Because it's asynchronous, so you must ensure that all tips are referenced
+	parents := blockdag.NewIdSet()
+	for _, v := range block.Block().Parents {
+		parents.Add(c.BlockIndex().GetDAGBlockID(v))
+	}
+	height, ok := c.BlockDAG().CheckSubMainChainTip(parents.List())
+	if !ok {
+		str := "The tips of block is expired."
+		return false, blockchain.RuleError{ErrorCode: blockchain.ErrPrevBlockNotBest, Description: str}
+	}
+	block.SetHeight(height)
+	return c.bm.ProcessBlock(block, flags)
+}
 
+// InvalidateTemplateCache invalidates the templates cached by the block
+// manager.
+func (c *submitChain) InvalidateTemplateCache() {
+	c.bm.InvalidateTemplateCache()
 }
 
 //LL
@@ -162,7 +257,7 @@ func (api *PublicMinerAPI) SubmitBlock(hexBlock string) (interface{}, error) {
 // in regards to whether or not it supports creating its own coinbase (the
 // coinbasetxn and coinbasevalue capabilities) and modifies the returned block
 // template accordingly.
-func handleGetBlockTemplateRequest(api *PublicMinerAPI, request *json.TemplateRequest) (interface{}, error) {
+func handleGetBlockTemplateRequest(ctx context.Context, api *PublicMinerAPI, request *json.TemplateRequest) (interface{}, error) {
 	// Extract the relevant passed capabilities and restrict the result to
 	// either a coinbase value or a coinbase transaction object depending on
 	// the request.  Default to only providing a coinbase value.
@@ -201,6 +296,28 @@ func handleGetBlockTemplateRequest(api *PublicMinerAPI, request *json.TemplateRe
 
 	// Protect concurrent access when updating block templates.
 	state := api.gbtWorkState
+
+	// Hold a long poll until its template is stale.
+	if request != nil && request.LongPollID != "" {
+		// Subscribe before checking the template, so that a new one
+		// isn't missed in between.
+		templates, unsubscribe := api.miner.blockManager.SubscribeTemplates()
+		defer unsubscribe()
+		currentID := func() (string, error) {
+			state.Lock()
+			defer state.Unlock()
+			if err := state.updateBlockTemplate(api, useCoinbaseValue); err != nil {
+				return "", err
+			}
+			return encodeTemplateID(state.template), nil
+		}
+		err := waitTemplateChange(ctx, templates, request.LongPollID, currentID,
+			longPollWait(ctx), gbtLongPollCheckInterval)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	state.Lock()
 	defer state.Unlock()
 
@@ -212,14 +329,84 @@ func handleGetBlockTemplateRequest(api *PublicMinerAPI, request *json.TemplateRe
 	if err := state.updateBlockTemplate(api, useCoinbaseValue); err != nil {
 		return nil, err
 	}
-	return state.blockTemplateResult(api, useCoinbaseValue, nil)
+	result, err := state.blockTemplateResult(api, useCoinbaseValue, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep the served template for the deltas requested against it.
+	id, err := templateResultID(result)
+	if err != nil {
+		return nil, rpc.RpcInternalError(err.Error(), "Failed to encode template")
+	}
+	result.TemplateID = id
+	api.gbtTemplates.Add(result)
+	return result, nil
+}
+
+// templateResultID returns the id of the passed template result, which is the
+// hash of its encoding, so that the same template served twice has the same
+// id.
+func templateResultID(result *json.GetBlockTemplateResult) (string, error) {
+	unidentified := *result
+	unidentified.TemplateID = ""
+	encoded, err := ejson.Marshal(&unidentified)
+	if err != nil {
+		return "", err
+	}
+	return hash.HashH(encoded).String(), nil
 }
 
 //LL
-// encodeTemplateID encodes the passed details into an ID that can be used to
-// uniquely identify a block template.
-func encodeTemplateID(prevHash hash.Hash, lastGenerated time.Time) string {
-	return fmt.Sprintf("%s-%d", prevHash.String(), lastGenerated.Unix())
+// encodeTemplateID encodes the parents and the height of the passed template
+// into the long poll id of the template, which a long poll waits to change.
+// The parents are identified by their merkle root.
+func encodeTemplateID(template *types.BlockTemplate) string {
+	return fmt.Sprintf("%s-%d", template.Block.Header.ParentRoot.String(),
+		template.Height)
+}
+
+// longPollWait returns the max duration of a long poll of the passed request
+// context, which must return before the request times out.
+func longPollWait(ctx context.Context) time.Duration {
+	wait := gbtLongPollTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		if d := time.Until(deadline) - gbtLongPollMargin; d < wait {
+			wait = d
+		}
+	}
+	return wait
+}
+
+// waitTemplateChange waits until the id of the current template, as returned
+// by currentID, differs from the passed long poll id, or until wait elapses.
+// The id is checked whenever a new template is notified on templates, and at
+// every check interval.  It returns the error of the context when it's done
+// first.
+func waitTemplateChange(ctx context.Context, templates <-chan *types.BlockTemplate,
+	longPollID string, currentID func() (string, error), wait,
+	checkInterval time.Duration) error {
+	timeout := time.NewTimer(wait)
+	defer timeout.Stop()
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		id, err := currentID()
+		if err != nil {
+			return err
+		}
+		if id != longPollID {
+			return nil
+		}
+		select {
+		case <-templates:
+		case <-ticker.C:
+		case <-timeout.C:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
 }
 
 // gbtWorkState houses state that is used in between multiple RPC invocations to
@@ -287,7 +474,7 @@ func (state *gbtWorkState) updateBlockTemplate(api *PublicMinerAPI, useCoinbaseV
 		// block template doesn't include the coinbase, so the caller
 		// will ultimately create their own coinbase which pays to the
 		// appropriate address(es).
-		template, err := mining.NewBlockTemplate(m.policy, m.params, m.sigCache, m.txSource, m.timeSource, m.blockManager, payToAddr, nil, pow.QITMEERKECCAK256)
+		template, err := m.newBlockTemplate(payToAddr, pow.QITMEERKECCAK256)
 		if err != nil {
 			return rpc.RpcInvalidError("Failed to create new block template: %s", err.Error())
 		}
@@ -298,7 +485,7 @@ func (state *gbtWorkState) updateBlockTemplate(api *PublicMinerAPI, useCoinbaseV
 		// Get the minimum allowed timestamp for the block based on the
 		// median timestamp of the last several blocks per the chain
 		// consensus rules.
-		minTimestamp := mining.MinimumMedianTime(m.blockManager.GetChain())
+		minTimestamp := mining.MinAllowedTimestamp(m.blockManager.GetChain())
 
 		// Update work state to ensure another block template isn't
 		// generated until needed.
@@ -308,6 +495,10 @@ func (state *gbtWorkState) updateBlockTemplate(api *PublicMinerAPI, useCoinbaseV
 		state.parentsSet.AddList(msgBlock.Parents)
 		state.minTimestamp = minTimestamp
 
+		// Cache the template, which notifies the template subscribers
+		// such as the long polls when it's for new parents or height.
+		m.blockManager.SetCurrentTemplate(template)
+
 		log.Debug(fmt.Sprintf("Generated block template (timestamp %v, "+
 			"target %s, merkle root %s)",
 			msgBlock.Header.Timestamp, targetDifficulty,
@@ -382,6 +573,9 @@ func (state *gbtWorkState) blockTemplateResult(api *PublicMinerAPI, useCoinbaseV
 		for idx := range dependsMap {
 			depends = append(depends, idx)
 		}
+		sort.Slice(depends, func(i, j int) bool {
+			return depends[i] < depends[j]
+		})
 
 		// Serialize the transaction for later conversion to hex.
 		txBuf, err := tx.Serialize()
@@ -426,23 +620,14 @@ func (state *gbtWorkState) blockTemplateResult(api *PublicMinerAPI, useCoinbaseV
 		"time", "transactions/add", "prevblock", "coinbase/append",
 	}
 	gbtCapabilities := []string{"proposal"}
-	blake2bdBig := pow.CompactToBig(template.PowDiffData.Blake2bDTarget)
-	x16rv3big := pow.CompactToBig(template.PowDiffData.X16rv3DTarget)
-	x8r16big := pow.CompactToBig(template.PowDiffData.X8r16DTarget)
-	keccak256big := pow.CompactToBig(template.PowDiffData.QitmeerKeccak256Target)
-	targetBlake2bDDifficulty := fmt.Sprintf("%064x", blake2bdBig)
-	x16rv3iDifficulty := fmt.Sprintf("%064x", x16rv3big)
-	x8r16Difficulty := fmt.Sprintf("%064x", x8r16big)
-	keccak256Difficulty := fmt.Sprintf("%064x", keccak256big)
-	targetCuckarooDDifficulty := template.PowDiffData.CuckarooBaseDiff
-	targetCuckaroomDifficulty := template.PowDiffData.CuckaroomBaseDiff
-	targetCuckatooDDifficulty := template.PowDiffData.CuckatooBaseDiff
-	longPollID := encodeTemplateID(template.Block.Header.ParentRoot, state.lastGenerated)
+	longPollID := encodeTemplateID(template)
 	reply := json.GetBlockTemplateResult{
 		StateRoot:    template.Block.Header.StateRoot.String(),
 		CurTime:      template.Block.Header.Timestamp.Unix(),
 		Height:       int64(template.Height),
 		Blues:        template.Blues,
+		BlueSet:      hashStrings(template.BlueSet),
+		RedSet:       hashStrings(template.RedSet),
 		PreviousHash: template.Block.Header.ParentRoot.String(),
 		WeightLimit:  types.MaxBlockWeight,
 		SigOpLimit:   types.MaxBlockSigOpsCost,
@@ -454,25 +639,10 @@ func (state *gbtWorkState) blockTemplateResult(api *PublicMinerAPI, useCoinbaseV
 		Version:      template.Block.Header.Version,
 		LongPollID:   longPollID,
 		//TODO, submitOld
-		SubmitOld: submitOld,
-		PowDiffReference: json.PowDiffReference{
-			Blake2bDBits: strconv.FormatInt(int64(template.PowDiffData.Blake2bDTarget), 16),
-			//blake2bd hash diff compare target
-			Blake2bTarget:          targetBlake2bDDifficulty,
-			X16rv3Bits:             strconv.FormatInt(int64(template.PowDiffData.X16rv3DTarget), 16),
-			X16rv3Target:           x16rv3iDifficulty,
-			X8r16Bits:              strconv.FormatInt(int64(template.PowDiffData.X8r16DTarget), 16),
-			X8r16Target:            x8r16Difficulty,
-			QitmeerKeccak256Bits:   strconv.FormatInt(int64(template.PowDiffData.QitmeerKeccak256Target), 16),
-			QitmeerKeccak256Target: keccak256Difficulty,
-			//cuckoo mining min diff
-			CuckarooMinDiff:  targetCuckarooDDifficulty,
-			CuckaroomMinDiff: targetCuckaroomDifficulty,
-			CuckatooMinDiff:  targetCuckatooDDifficulty,
-			//cuckoo hash calc diff scale
-		},
-		MinTime: state.minTimestamp.Unix(),
-		MaxTime: maxTime.Unix(),
+		SubmitOld:        submitOld,
+		PowDiffReference: powDiffReference(&template.PowDiffData),
+		MinTime:          state.minTimestamp.Unix(),
+		MaxTime:          maxTime.Unix(),
 		// gbtMutableFields
 		Mutable:    gbtMutableFields,
 		NonceRange: gbtNonceRange,
@@ -513,6 +683,87 @@ func (state *gbtWorkState) blockTemplateResult(api *PublicMinerAPI, useCoinbaseV
 	return &reply, nil
 }
 
+// hashStrings returns the string encodings of the passed hashes.
+func hashStrings(hashes []*hash.Hash) []string {
+	strs := make([]string, len(hashes))
+	for i, h := range hashes {
+		strs[i] = h.String()
+	}
+	return strs
+}
+
+// powDiffReference returns the difficulty targets of every algorithm in the
+// passed pow diff data as they are reported to miners.
+func powDiffReference(pd *types.PowDiffStandard) json.PowDiffReference {
+	blake2bdBig := pow.CompactToBig(pd.Blake2bDTarget)
+	x16rv3big := pow.CompactToBig(pd.X16rv3DTarget)
+	x8r16big := pow.CompactToBig(pd.X8r16DTarget)
+	keccak256big := pow.CompactToBig(pd.QitmeerKeccak256Target)
+	return json.PowDiffReference{
+		Blake2bDBits: strconv.FormatInt(int64(pd.Blake2bDTarget), 16),
+		//blake2bd hash diff compare target
+		Blake2bTarget:          fmt.Sprintf("%064x", blake2bdBig),
+		X16rv3Bits:             strconv.FormatInt(int64(pd.X16rv3DTarget), 16),
+		X16rv3Target:           fmt.Sprintf("%064x", x16rv3big),
+		X8r16Bits:              strconv.FormatInt(int64(pd.X8r16DTarget), 16),
+		X8r16Target:            fmt.Sprintf("%064x", x8r16big),
+		QitmeerKeccak256Bits:   strconv.FormatInt(int64(pd.QitmeerKeccak256Target), 16),
+		QitmeerKeccak256Target: fmt.Sprintf("%064x", keccak256big),
+		//cuckoo mining min diff
+		CuckarooMinDiff:  pd.CuckarooBaseDiff,
+		CuckaroomMinDiff: pd.CuckaroomBaseDiff,
+		CuckatooMinDiff:  pd.CuckatooBaseDiff,
+		//cuckoo graph edge bits range and proof size
+		CuckarooMinEdgeBits:  pd.CuckarooMinEdgeBits,
+		CuckarooMaxEdgeBits:  pd.CuckarooMaxEdgeBits,
+		CuckaroomMinEdgeBits: pd.CuckaroomMinEdgeBits,
+		CuckaroomMaxEdgeBits: pd.CuckaroomMaxEdgeBits,
+		CuckatooMinEdgeBits:  pd.CuckatooMinEdgeBits,
+		CuckatooMaxEdgeBits:  pd.CuckatooMaxEdgeBits,
+		CuckarooProofSize:    pd.CuckarooProofSize,
+		CuckaroomProofSize:   pd.CuckaroomProofSize,
+		CuckatooProofSize:    pd.CuckatooProofSize,
+		//cuckoo hash calc diff scale
+	}
+}
+
+// NewBlockTemplates pushes a notification to the subscriber whenever the node
+// caches a new block template, which is one for a higher height or a different
+// set of parents.  Subscriptions are only supported over websockets.
+func (api *PublicMinerAPI) NewBlockTemplates(ctx context.Context) (*rpc.Subscription, error) {
+	notifier, supported := rpc.NotifierFromContext(ctx)
+	if !supported {
+		return nil, rpc.ErrNotificationsUnsupported
+	}
+	sub := notifier.CreateSubscription()
+	templates, unsubscribe := api.miner.blockManager.SubscribeTemplates()
+	go func() {
+		defer unsubscribe()
+		for {
+			select {
+			case template := <-templates:
+				parents := make([]string, 0, len(template.Block.Parents))
+				for _, p := range template.Block.Parents {
+					parents = append(parents, p.String())
+				}
+				err := notifier.Notify(sub.ID, &json.BlockTemplateNotification{
+					Height:           int64(template.Height),
+					Parents:          parents,
+					PowDiffReference: powDiffReference(&template.PowDiffData),
+				})
+				if err != nil {
+					return
+				}
+			case <-sub.Err():
+				return
+			case <-notifier.Closed():
+				return
+			}
+		}
+	}()
+	return sub, nil
+}
+
 // PrivateMinerAPI provides private RPC methods to control the miner.
 type PrivateMinerAPI struct {
 	miner *CPUMiner
@@ -556,6 +807,34 @@ func (api *PrivateMinerAPI) Generate(numBlocks uint32, powType pow.PowType) ([]s
 	return reply, nil
 }
 
+// GenerateToAddress generates the passed number of blocks paying to the
+// passed address, which extend the current mining tips, and returns their
+// hashes.  It is only available on the test networks, where the blocks are
+// solved at once.
+func (api *PrivateMinerAPI) GenerateToAddress(numBlocks int, addr string) ([]string, error) {
+	if numBlocks <= 0 || numBlocks > 3000 {
+		return nil, rpc.RpcInvalidError("Invalid number of blocks %d",
+			numBlocks)
+	}
+	payToAddr, err := address.DecodeAddress(addr)
+	if err != nil {
+		return nil, rpc.RpcAddressKeyError("Invalid address: %v", err)
+	}
+	if !address.IsForNetwork(payToAddr, api.miner.params) {
+		return nil, rpc.RpcAddressKeyError("Wrong network: %v", addr)
+	}
+	blockHashes, err := api.miner.GenerateToAddress(numBlocks, payToAddr)
+	if err != nil {
+		return nil, rpc.RpcInternalError("Could not generate blocks,"+err.Error(),
+			"miner")
+	}
+	reply := make([]string, len(blockHashes))
+	for i, hash := range blockHashes {
+		reply[i] = hash.String()
+	}
+	return reply, nil
+}
+
 func builderScript(builder *txscript.ScriptBuilder) []byte {
 	script, err := builder.Script()
 	if err != nil {
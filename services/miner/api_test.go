@@ -0,0 +1,59 @@
+// Copyright (c) 2017-2018 The qitmeer developers
+
+package miner
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Qitmeer/qitmeer/core/types"
+)
+
+// TestWaitTemplateChange ensures a long poll is held while its template is
+// current, and is released by a new template.
+func TestWaitTemplateChange(t *testing.T) {
+	var stale int32
+	currentID := func() (string, error) {
+		if atomic.LoadInt32(&stale) == 1 {
+			return "new", nil
+		}
+		return "old", nil
+	}
+	templates := make(chan *types.BlockTemplate, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- waitTemplateChange(context.Background(), templates, "old",
+			currentID, time.Hour, time.Hour)
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("long poll of the current template returned: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	atomic.StoreInt32(&stale, 1)
+	templates <- &types.BlockTemplate{}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("waitTemplateChange: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("long poll not released by a new template")
+	}
+
+	// A stale id returns at once, and a cancelled request with its error.
+	if err := waitTemplateChange(context.Background(), templates, "old",
+		currentID, time.Hour, time.Hour); err != nil {
+		t.Errorf("waitTemplateChange of a stale id: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := waitTemplateChange(ctx, templates, "new", currentID, time.Hour,
+		time.Hour); err != context.Canceled {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+}
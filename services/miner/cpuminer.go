@@ -72,6 +72,7 @@ type CPUMiner struct {
 	started           bool
 	discreteMining    bool
 	submitBlockLock   sync.Mutex
+	templateGroup     mining.TemplateGroup
 	wg                sync.WaitGroup
 	workerWg          sync.WaitGroup
 	updateNumWorkers  chan struct{}
@@ -174,7 +175,7 @@ func (m *CPUMiner) GenerateNBlocks(n uint32, powType pow.PowType) ([]*hash.Hash,
 		// in the memory pool as a source of transactions to potentially
 		// include in the block.
 		// TODO, refactor NewBlockTemplate input dependencies
-		template, err := mining.NewBlockTemplate(m.policy, m.params, m.sigCache, m.txSource, m.timeSource, m.blockManager, payToAddr, nil, powType)
+		template, err := mining.NewBlockTemplate(m.policy, m.params, m.sigCache, m.txSource, m.timeSource, m.blockManager, payToAddr, nil, powType, nil, nil, nil)
 		m.submitBlockLock.Unlock()
 		if err != nil {
 			errStr := fmt.Sprintf("template: %v", err)
@@ -721,7 +722,7 @@ out:
 		// Create a new block template using the available transactions
 		// in the memory pool as a source of transactions to potentially
 		// include in the block.
-		template, err := mining.NewBlockTemplate(m.policy, m.params, m.sigCache, m.txSource, m.timeSource, m.blockManager, payToAddr, nil, pow.QITMEERKECCAK256)
+		template, err := m.newBlockTemplate(payToAddr, pow.QITMEERKECCAK256)
 		m.submitBlockLock.Unlock()
 		if err != nil {
 			errStr := fmt.Sprintf("template: %v", err)
@@ -765,6 +766,21 @@ out:
 	log.Trace("Generate blocks worker done")
 }
 
+// newBlockTemplate returns a new block template on the current mining tips.
+// The concurrent builds of the same template, such as by the miner workers
+// and the getblocktemplate calls, are shared, and every caller gets its own
+// copy.
+func (m *CPUMiner) newBlockTemplate(payToAddr types.Address, powType pow.PowType) (*types.BlockTemplate, error) {
+	chain := m.blockManager.GetChain()
+	height := uint64(chain.BlockDAG().GetMainChainTip().GetHeight() + 1)
+	key := mining.TemplateKey(chain.GetMiningTips(), height, powType, payToAddr)
+	return m.templateGroup.Do(key, func() (*types.BlockTemplate, error) {
+		return mining.NewBlockTemplate(m.policy, m.params, m.sigCache,
+			m.txSource, m.timeSource, m.blockManager, payToAddr, nil, powType,
+			nil, nil, nil)
+	})
+}
+
 func (m *CPUMiner) updateExtraNonce(msgBlock *types.Block, extraNonce uint64) error {
 	// TODO, decided if need extra nonce for coinbase-tx
 	// do nothing for now
@@ -855,7 +871,7 @@ func (m *CPUMiner) GenerateBlockByParents(parents []*hash.Hash) (*hash.Hash, err
 		// include in the block.
 		// TODO, refactor NewBlockTemplate input dependencies
 		template, err := mining.NewBlockTemplate(m.policy, m.params,
-			m.sigCache, m.txSource, m.timeSource, m.blockManager, payToAddr, parents, pow.QITMEERKECCAK256)
+			m.sigCache, m.txSource, m.timeSource, m.blockManager, payToAddr, parents, pow.QITMEERKECCAK256, nil, nil, nil)
 		m.submitBlockLock.Unlock()
 		if err != nil {
 			errStr := fmt.Sprintf("template: %v", err)
@@ -0,0 +1,126 @@
+// Copyright (c) 2017-2018 The qitmeer developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package miner
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/protocol"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"github.com/Qitmeer/qitmeer/core/types/pow"
+	"github.com/Qitmeer/qitmeer/params"
+	"github.com/Qitmeer/qitmeer/services/mining"
+)
+
+// errGenerateMainNet is returned when blocks are generated to an address on
+// mainnet, whose difficulty makes it pointless.
+var errGenerateMainNet = errors.New("generatetoaddress is not available on mainnet")
+
+// blockGenerator is the part of the miner the blocks of generatetoaddress are
+// built with and connected to.
+type blockGenerator interface {
+	// NewBlockTemplate returns a template built on the current mining tips
+	// which pays to the passed address.
+	NewBlockTemplate(payToAddress types.Address) (*types.BlockTemplate, error)
+
+	// SubmitBlock connects the passed solved block to the chain.
+	SubmitBlock(block *types.Block) (*types.SerializedBlock, error)
+}
+
+// generateToAddress generates n blocks paying to the passed address, each one
+// built on the tips left by the previous one, and returns their hashes.  The
+// blocks are solved with the blake2bd algorithm at the target of their
+// template, which on the test networks is easy enough to be found at once.
+func generateToAddress(gen blockGenerator, n int, payToAddress types.Address,
+	params *params.Params) ([]*hash.Hash, error) {
+
+	if params.Net == protocol.MainNet {
+		return nil, errGenerateMainNet
+	}
+
+	blockHashes := make([]*hash.Hash, 0, n)
+	for len(blockHashes) < n {
+		template, err := gen.NewBlockTemplate(payToAddress)
+		if err != nil {
+			return nil, err
+		}
+		block := template.Block
+		block.Header.Difficulty = template.PowDiffData.Blake2bDTarget
+		if !solveGeneratedBlock(block, params) {
+			return nil, fmt.Errorf("no solution of block at height %d",
+				template.Height)
+		}
+		accepted, err := gen.SubmitBlock(block)
+		if err != nil {
+			return nil, err
+		}
+		blockHashes = append(blockHashes, accepted.Hash())
+	}
+	return blockHashes, nil
+}
+
+// solveGeneratedBlock searches the nonces of the passed block for a solution,
+// which is set in its header, and returns whether one was found.
+func solveGeneratedBlock(block *types.Block, params *params.Params) bool {
+	for nonce := uint32(0); ; nonce++ {
+		block.Header.Pow.SetNonce(nonce)
+		if mining.VerifyBlockPow(block, params) == nil {
+			return true
+		}
+		if nonce == maxNonce {
+			return false
+		}
+	}
+}
+
+// templateGenerator is the block generator of a CPU miner, which builds the
+// templates of the miner and submits the blocks to its block manager.
+type templateGenerator struct {
+	miner *CPUMiner
+}
+
+// NewBlockTemplate returns a blake2bd template of the miner.
+func (g *templateGenerator) NewBlockTemplate(payToAddress types.Address) (*types.BlockTemplate, error) {
+	m := g.miner
+	m.submitBlockLock.Lock()
+	defer m.submitBlockLock.Unlock()
+	return m.newBlockTemplate(payToAddress, pow.BLAKE2BD)
+}
+
+// SubmitBlock submits the block to the block manager of the miner.
+func (g *templateGenerator) SubmitBlock(block *types.Block) (*types.SerializedBlock, error) {
+	m := g.miner
+	m.submitBlockLock.Lock()
+	defer m.submitBlockLock.Unlock()
+	chain := &submitChain{
+		BlockChain: m.blockManager.GetChain(),
+		bm:         m.blockManager,
+	}
+	return mining.SubmitBlock(chain, block, m.params)
+}
+
+// GenerateToAddress generates n blocks paying to the passed address, and
+// returns their hashes.  It fails on mainnet and while the miner is running.
+func (m *CPUMiner) GenerateToAddress(n int, payToAddress types.Address) ([]*hash.Hash, error) {
+	m.Lock()
+	if m.started || m.discreteMining {
+		m.Unlock()
+		return nil, errors.New("server is already CPU mining. Please call " +
+			"`setgenerate 0` before calling discrete `generate` commands.")
+	}
+	m.discreteMining = true
+	m.Unlock()
+
+	defer func() {
+		m.Lock()
+		m.discreteMining = false
+		m.Unlock()
+	}()
+	log.Trace("Generating blocks", "num", n, "address", payToAddress)
+	return generateToAddress(&templateGenerator{miner: m}, n, payToAddress,
+		m.params)
+}
@@ -0,0 +1,90 @@
+// Copyright (c) 2017-2018 The qitmeer developers
+
+package miner
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"github.com/Qitmeer/qitmeer/core/types/pow"
+	"github.com/Qitmeer/qitmeer/engine/txscript"
+	"github.com/Qitmeer/qitmeer/params"
+	"github.com/Qitmeer/qitmeer/services/mining"
+)
+
+// generateTestChain is a chain of a single tip which connects the solved
+// blocks extending it.
+type generateTestChain struct {
+	params *params.Params
+	height uint64
+	tip    hash.Hash
+}
+
+func (c *generateTestChain) NewBlockTemplate(payToAddress types.Address) (*types.BlockTemplate, error) {
+	height := c.height + 1
+	script, err := txscript.NewScriptBuilder().AddInt64(int64(height)).
+		AddData([]byte(mining.CoinbaseFlags)).Script()
+	if err != nil {
+		return nil, err
+	}
+	coinbase := types.NewTransaction()
+	coinbase.AddTxIn(types.NewTxInput(types.NewOutPoint(&hash.Hash{},
+		types.MaxPrevOutIndex), script))
+	coinbase.AddTxOut(types.NewTxOutput(1, nil))
+
+	tip := c.tip
+	block := &types.Block{
+		Header: types.BlockHeader{
+			Timestamp: time.Unix(1577836800+int64(height), 0),
+			Pow:       pow.GetInstance(pow.BLAKE2BD, 0, []byte{}),
+		},
+		Parents: []*hash.Hash{&tip},
+	}
+	if err := block.AddTransaction(coinbase); err != nil {
+		return nil, err
+	}
+	return &types.BlockTemplate{
+		Block:       block,
+		Height:      height,
+		PowDiffData: types.PowDiffStandard{Blake2bDTarget: c.params.PowConfig.Blake2bdPowLimitBits},
+	}, nil
+}
+
+func (c *generateTestChain) SubmitBlock(block *types.Block) (*types.SerializedBlock, error) {
+	if err := mining.VerifyBlockPow(block, c.params); err != nil {
+		return nil, err
+	}
+	if len(block.Parents) != 1 || *block.Parents[0] != c.tip {
+		return nil, fmt.Errorf("block doesn't extend the tip")
+	}
+	sblock := types.NewBlock(block)
+	c.height++
+	c.tip = *sblock.Hash()
+	return sblock, nil
+}
+
+// TestGenerateToAddress ensures the generated blocks are solved and extend
+// the chain one after the other, and that blocks aren't generated on mainnet.
+func TestGenerateToAddress(t *testing.T) {
+	p := &params.PrivNetParams
+	chain := &generateTestChain{params: p, height: 5}
+	blockHashes, err := generateToAddress(chain, 3, nil, p)
+	if err != nil {
+		t.Fatalf("generateToAddress: %v", err)
+	}
+	if chain.height != 8 {
+		t.Errorf("chain at height %d, want 8", chain.height)
+	}
+	if len(blockHashes) != 3 || *blockHashes[2] != chain.tip {
+		t.Errorf("got hashes %v, want 3 ending with the tip %v",
+			blockHashes, chain.tip)
+	}
+
+	_, err = generateToAddress(chain, 1, nil, &params.MainNetParams)
+	if err != errGenerateMainNet {
+		t.Errorf("mainnet: got error %v, want %v", err, errGenerateMainNet)
+	}
+}
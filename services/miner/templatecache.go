@@ -0,0 +1,75 @@
+// Copyright (c) 2017-2018 The qitmeer developers
+
+package miner
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/Qitmeer/qitmeer/core/json"
+)
+
+// gbtTemplateCacheSize is the number of templates served by getblocktemplate
+// which are kept to compute the deltas of getblocktemplatedelta against.
+const gbtTemplateCacheSize = 16
+
+// templateResultCache provides a concurrency safe cache of the templates
+// served to the clients by their id, limited to a maximum number of templates
+// with eviction of the least recently used one when the limit is exceeded.
+type templateResultCache struct {
+	mtx       sync.Mutex
+	templates map[string]*list.Element
+	lruList   *list.List
+	limit     int
+}
+
+// newTemplateResultCache returns a new template cache limited to the passed
+// number of templates.
+func newTemplateResultCache(limit int) *templateResultCache {
+	return &templateResultCache{
+		templates: make(map[string]*list.Element),
+		lruList:   list.New(),
+		limit:     limit,
+	}
+}
+
+// Add adds the passed template to the cache, evicting the least recently used
+// template if the cache is full.  Adding a template already cached makes it
+// the most recently used one.
+//
+// This function is safe for concurrent access.
+func (c *templateResultCache) Add(template *json.GetBlockTemplateResult) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if c.limit == 0 {
+		return
+	}
+	if node, ok := c.templates[template.TemplateID]; ok {
+		c.lruList.MoveToFront(node)
+		return
+	}
+	if len(c.templates) >= c.limit {
+		node := c.lruList.Back()
+		lru := node.Value.(*json.GetBlockTemplateResult)
+		delete(c.templates, lru.TemplateID)
+		c.lruList.Remove(node)
+	}
+	c.templates[template.TemplateID] = c.lruList.PushFront(template)
+}
+
+// Get returns the template of the passed id, or nil when it isn't cached,
+// and makes it the most recently used one.
+//
+// This function is safe for concurrent access.
+func (c *templateResultCache) Get(id string) *json.GetBlockTemplateResult {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	node, ok := c.templates[id]
+	if !ok {
+		return nil
+	}
+	c.lruList.MoveToFront(node)
+	return node.Value.(*json.GetBlockTemplateResult)
+}
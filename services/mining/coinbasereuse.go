@@ -0,0 +1,108 @@
+// Copyright (c) 2017-2018 The qitmeer developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Qitmeer/qitmeer/core/types"
+	"github.com/Qitmeer/qitmeer/log"
+)
+
+// maxTrackedCoinbaseAddrs is the max number of addresses a coinbase reuse
+// tracker remembers, past which the least recently used ones are forgotten.
+const maxTrackedCoinbaseAddrs = 1000
+
+// CoinbaseReuseTracker detects the templates whose coinbase pays to an address
+// a template of a recent height already paid to, which harms the privacy of
+// the miner.  It's a policy aid only: the reuse is logged, or rejected when
+// the tracker enforces it, but it's valid for consensus.  The templates
+// rebuilt for the same height don't count as a reuse.
+//
+// The tracker remembers at most the addresses of the window, up to a fixed
+// number of addresses.
+//
+// This type is safe for concurrent access.
+type CoinbaseReuseTracker struct {
+	mtx     sync.Mutex
+	window  uint64
+	reject  bool
+	heights map[string]uint64
+	reuses  uint64
+}
+
+// NewCoinbaseReuseTracker returns a tracker of the addresses reused within the
+// passed number of blocks, which fails the templates reusing one when reject
+// is set, or only warns about them otherwise.
+func NewCoinbaseReuseTracker(window uint64, reject bool) *CoinbaseReuseTracker {
+	return &CoinbaseReuseTracker{
+		window:  window,
+		reject:  reject,
+		heights: make(map[string]uint64),
+	}
+}
+
+// Observe records that a template of the passed height pays to the passed
+// address.  When the address was paid to by a template of a lower height
+// within the window, the reuse is logged, and an error is returned if the
+// tracker rejects reuses.
+func (t *CoinbaseReuseTracker) Observe(payToAddress types.Address, height uint64) error {
+	addr := payToAddress.Encode()
+
+	t.mtx.Lock()
+	lastHeight, seen := t.heights[addr]
+	reused := seen && lastHeight < height && height-lastHeight <= t.window
+	if reused {
+		t.reuses++
+	}
+	if !seen || lastHeight < height {
+		t.heights[addr] = height
+	}
+	t.prune(height)
+	t.mtx.Unlock()
+
+	if !reused {
+		return nil
+	}
+	if t.reject {
+		str := fmt.Sprintf("coinbase address %s was already paid to at "+
+			"height %d, within %d blocks of height %d", addr,
+			lastHeight, t.window, height)
+		return miningRuleError(ErrCoinbaseAddressReuse, str)
+	}
+	log.Warn("Coinbase address reused", "address", addr, "height", height,
+		"previous", lastHeight)
+	return nil
+}
+
+// prune forgets the addresses last paid to out of the window of the passed
+// height, then the least recently used ones past the max number of tracked
+// addresses.
+//
+// This function MUST be called with the tracker lock held.
+func (t *CoinbaseReuseTracker) prune(height uint64) {
+	for addr, lastHeight := range t.heights {
+		if lastHeight+t.window < height {
+			delete(t.heights, addr)
+		}
+	}
+	for len(t.heights) > maxTrackedCoinbaseAddrs {
+		var oldest string
+		for addr, lastHeight := range t.heights {
+			if oldest == "" || lastHeight < t.heights[oldest] {
+				oldest = addr
+			}
+		}
+		delete(t.heights, oldest)
+	}
+}
+
+// Reuses returns the number of reuses the tracker detected.
+func (t *CoinbaseReuseTracker) Reuses() uint64 {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	return t.reuses
+}
@@ -0,0 +1,66 @@
+package mining
+
+import (
+	"testing"
+
+	"github.com/Qitmeer/qitmeer/core/address"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"github.com/Qitmeer/qitmeer/crypto/ecc"
+	"github.com/Qitmeer/qitmeer/params"
+)
+
+// TestCoinbaseReuseTracker ensures the templates paying to an address paid to
+// within the window are detected, and rejected when enforced, while the
+// templates rebuilt for the same height or out of the window aren't.
+func TestCoinbaseReuseTracker(t *testing.T) {
+	newAddr := func(b byte) types.Address {
+		hash160 := make([]byte, 20)
+		hash160[0] = b
+		addr, err := address.NewPubKeyHashAddress(hash160,
+			&params.PrivNetParams, ecc.ECDSA_Secp256k1)
+		if err != nil {
+			t.Fatalf("NewPubKeyHashAddress: %v", err)
+		}
+		return addr
+	}
+	addr1, addr2 := newAddr(1), newAddr(2)
+
+	tracker := NewCoinbaseReuseTracker(10, false)
+	templates := []struct {
+		addr   types.Address
+		height uint64
+		reuses uint64
+	}{
+		{addr1, 100, 0},
+		{addr1, 100, 0}, // rebuilt for the same height
+		{addr2, 101, 0},
+		{addr1, 105, 1}, // within the window
+		{addr2, 120, 1}, // out of the window
+		{addr2, 130, 2}, // at the edge of the window
+	}
+	for i, template := range templates {
+		if err := tracker.Observe(template.addr, template.height); err != nil {
+			t.Fatalf("template %d: Observe: %v", i, err)
+		}
+		if got := tracker.Reuses(); got != template.reuses {
+			t.Errorf("template %d: got %d reuses, want %d", i, got,
+				template.reuses)
+		}
+	}
+
+	// The addresses out of the window are forgotten.
+	if _, ok := tracker.heights[addr1.Encode()]; ok {
+		t.Errorf("address out of the window still tracked")
+	}
+
+	// The reuses fail the templates when enforced.
+	tracker = NewCoinbaseReuseTracker(10, true)
+	if err := tracker.Observe(addr1, 100); err != nil {
+		t.Fatalf("Observe: %v", err)
+	}
+	err := tracker.Observe(addr1, 101)
+	if rerr, ok := err.(MiningRuleError); !ok ||
+		rerr.ErrorCode != ErrCoinbaseAddressReuse {
+		t.Errorf("got error %v, want %v", err, ErrCoinbaseAddressReuse)
+	}
+}
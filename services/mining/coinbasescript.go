@@ -0,0 +1,103 @@
+// Copyright (c) 2017-2018 The qitmeer developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/Qitmeer/qitmeer/engine/txscript"
+)
+
+// maxCoinbaseHeightLen is the max number of bytes of the script number
+// encoding the height in a standard coinbase script.
+const maxCoinbaseHeightLen = 8
+
+// DecodeCoinbaseScript returns the height and the extra nonce a standard
+// coinbase signature script, as made by the node, encodes: the push of the
+// height, the push of the extra nonce, then the push of CoinbaseFlags.  The
+// extra nonce is returned as the bytes it pushes, a small integer opcode
+// pushing the byte of its value.  An error is returned for the scripts
+// without this layout, such as the coinbases of other software.
+func DecodeCoinbaseScript(script []byte) (uint64, []byte, error) {
+	var pushes [][]byte
+	for len(script) > 0 {
+		data, rest, err := nextCoinbasePush(script)
+		if err != nil {
+			return 0, nil, err
+		}
+		pushes = append(pushes, data)
+		script = rest
+	}
+	if len(pushes) != 3 {
+		str := fmt.Sprintf("coinbase script has %d pushes instead of the "+
+			"height, the extra nonce and the flags", len(pushes))
+		return 0, nil, miningRuleError(ErrNonStandardCoinbaseScript, str)
+	}
+	if string(pushes[2]) != CoinbaseFlags {
+		str := fmt.Sprintf("coinbase script has flags %q instead of %q",
+			pushes[2], CoinbaseFlags)
+		return 0, nil, miningRuleError(ErrNonStandardCoinbaseScript, str)
+	}
+
+	// The height is a positive script number, in little endian with the
+	// sign in the high bit of its last byte.
+	heightBytes := pushes[0]
+	if len(heightBytes) > maxCoinbaseHeightLen {
+		str := fmt.Sprintf("coinbase script height is %d bytes, which is "+
+			"above the max of %d", len(heightBytes), maxCoinbaseHeightLen)
+		return 0, nil, miningRuleError(ErrNonStandardCoinbaseScript, str)
+	}
+	if len(heightBytes) > 0 && heightBytes[len(heightBytes)-1]&0x80 != 0 {
+		str := fmt.Sprintf("coinbase script height %x is negative",
+			heightBytes)
+		return 0, nil, miningRuleError(ErrNonStandardCoinbaseScript, str)
+	}
+	var height [8]byte
+	copy(height[:], heightBytes)
+	return binary.LittleEndian.Uint64(height[:]), pushes[1], nil
+}
+
+// nextCoinbasePush returns the data pushed by the first opcode of the passed
+// script, along with the rest of the script.  The small integer opcodes push
+// the byte of their value, as the script number encoding of the value.  An
+// error is returned if the opcode isn't a push or the script is truncated.
+func nextCoinbasePush(script []byte) ([]byte, []byte, error) {
+	op := script[0]
+	script = script[1:]
+	switch {
+	case op == txscript.OP_0:
+		return []byte{}, script, nil
+	case op == txscript.OP_1NEGATE:
+		return []byte{0x81}, script, nil
+	case op >= txscript.OP_1 && op <= txscript.OP_16:
+		return []byte{op - (txscript.OP_1 - 1)}, script, nil
+	}
+
+	var dataLen int
+	switch {
+	case op >= txscript.OP_DATA_1 && op <= txscript.OP_DATA_75:
+		dataLen = int(op)
+	case op == txscript.OP_PUSHDATA1 && len(script) >= 1:
+		dataLen = int(script[0])
+		script = script[1:]
+	case op == txscript.OP_PUSHDATA2 && len(script) >= 2:
+		dataLen = int(binary.LittleEndian.Uint16(script))
+		script = script[2:]
+	case op == txscript.OP_PUSHDATA4 && len(script) >= 4:
+		dataLen = int(binary.LittleEndian.Uint32(script))
+		script = script[4:]
+	default:
+		str := fmt.Sprintf("coinbase script has opcode %#x which isn't "+
+			"a push or is truncated", op)
+		return nil, nil, miningRuleError(ErrNonStandardCoinbaseScript, str)
+	}
+	if dataLen < 0 || dataLen > len(script) {
+		str := fmt.Sprintf("coinbase script push of %d bytes is truncated "+
+			"to %d bytes", dataLen, len(script))
+		return nil, nil, miningRuleError(ErrNonStandardCoinbaseScript, str)
+	}
+	return script[:dataLen], script[dataLen:], nil
+}
@@ -0,0 +1,79 @@
+package mining
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/Qitmeer/qitmeer/engine/txscript"
+)
+
+// TestDecodeCoinbaseScript ensures the height and the extra nonce of the
+// standard coinbase scripts are decoded back, and the other scripts rejected.
+func TestDecodeCoinbaseScript(t *testing.T) {
+	heights := []uint64{0, 1, 16, 17, 127, 128, 255, 256, 65535, 1 << 31,
+		1<<40 + 3}
+	for _, height := range heights {
+		// The extra nonce of unreserved size is a script number.
+		script, _, err := standardCoinbaseScript(height, 5, 0)
+		if err != nil {
+			t.Fatalf("standardCoinbaseScript: %v", err)
+		}
+		gotHeight, extraNonce, err := DecodeCoinbaseScript(script)
+		if err != nil {
+			t.Fatalf("height %d: DecodeCoinbaseScript: %v", height, err)
+		}
+		if gotHeight != height || !bytes.Equal(extraNonce, []byte{5}) {
+			t.Errorf("height %d: got height %d and extra nonce %x", height,
+				gotHeight, extraNonce)
+		}
+
+		// The reserved extra nonce is the little endian nonce, padded.
+		const extraNonceSize = 12
+		script, offset, err := standardCoinbaseScript(height, 0x0102030405,
+			extraNonceSize)
+		if err != nil {
+			t.Fatalf("standardCoinbaseScript: %v", err)
+		}
+		gotHeight, extraNonce, err = DecodeCoinbaseScript(script)
+		if err != nil {
+			t.Fatalf("height %d: DecodeCoinbaseScript: %v", height, err)
+		}
+		want := make([]byte, extraNonceSize)
+		binary.LittleEndian.PutUint64(want, 0x0102030405)
+		if gotHeight != height || !bytes.Equal(extraNonce, want) ||
+			!bytes.Equal(extraNonce, script[offset:offset+extraNonceSize]) {
+			t.Errorf("height %d: got height %d and extra nonce %x, want "+
+				"%x", height, gotHeight, extraNonce, want)
+		}
+	}
+
+	flags := append([]byte{byte(len(CoinbaseFlags))}, CoinbaseFlags...)
+	tests := []struct {
+		name   string
+		script []byte
+	}{
+		{"empty", nil},
+		{"no flags", []byte{txscript.OP_1, txscript.OP_2}},
+		{"other flags", []byte{txscript.OP_1, txscript.OP_2,
+			txscript.OP_DATA_1, 'x'}},
+		{"extra push", append([]byte{txscript.OP_1, txscript.OP_2},
+			append(flags, txscript.OP_3)...)},
+		{"not a push", append([]byte{txscript.OP_1, txscript.OP_CHECKSIG},
+			flags...)},
+		{"truncated", append([]byte{txscript.OP_1, txscript.OP_2},
+			flags[:len(flags)-1]...)},
+		{"negative height", append([]byte{txscript.OP_DATA_1, 0x81,
+			txscript.OP_2}, flags...)},
+		{"height too long", append([]byte{txscript.OP_DATA_9, 1, 2, 3, 4,
+			5, 6, 7, 8, 9, txscript.OP_2}, flags...)},
+	}
+	for _, test := range tests {
+		_, _, err := DecodeCoinbaseScript(test.script)
+		if rerr, ok := err.(MiningRuleError); !ok ||
+			rerr.GetCode() != ErrNonStandardCoinbaseScript {
+			t.Errorf("%s: got %v, want %v", test.name, err,
+				ErrNonStandardCoinbaseScript)
+		}
+	}
+}
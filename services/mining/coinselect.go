@@ -0,0 +1,120 @@
+// Copyright (c) 2017-2018 The qitmeer developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"fmt"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"sort"
+)
+
+// SpendCandidate is an unspent output a wallet can spend, along with the size
+// of the signature script which will redeem it, so that the size of the
+// spending transaction can be estimated before it is signed.
+type SpendCandidate struct {
+	OutPoint       types.TxOutPoint
+	Amount         uint64
+	SignScriptSize int
+}
+
+// InputSelection is the result of SelectInputs.
+type InputSelection struct {
+	// Inputs are the selected candidates, by decreasing amount.
+	Inputs []SpendCandidate
+
+	// Change is the amount paid back to the change script, zero when the
+	// transaction has no change output.
+	Change uint64
+
+	// Fee is the fee the transaction pays in atoms, and FeePerKB the fee
+	// rate of its estimated size, as the mempool computes it.
+	Fee      int64
+	Size     int
+	FeePerKB int64
+
+	// Minable is whether or not the fee rate clears the TxMinFreeFee of
+	// the mining policy, below which block templates skip transactions
+	// once the block is larger than the minimum block size.
+	Minable bool
+}
+
+// SelectInputs selects the candidates a transaction paying the passed outputs
+// spends to pay them with a fee of at least feePerKB atoms per kilobyte, the
+// largest ones first, and reports whether the transaction would be minable
+// under the passed mining policy.  The excess is paid back to the change
+// script, when not nil and when some is left once the change output is paid
+// for, and left as fee otherwise.
+func SelectInputs(policy *Policy, candidates []SpendCandidate,
+	outputs []*types.TxOutput, changePkScript []byte, feePerKB int64) (*InputSelection, error) {
+
+	sorted := make([]SpendCandidate, len(candidates))
+	copy(sorted, candidates)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Amount > sorted[j].Amount
+	})
+	var target uint64
+	for _, txOut := range outputs {
+		target += txOut.Amount
+	}
+
+	tx := types.NewTransaction()
+	for _, txOut := range outputs {
+		tx.AddTxOut(txOut)
+	}
+	var total uint64
+	for _, candidate := range sorted {
+		tx.AddTxIn(&types.TxInput{
+			PreviousOut: candidate.OutPoint,
+			SignScript:  make([]byte, candidate.SignScriptSize),
+			Sequence:    types.MaxTxInSequenceNum,
+		})
+		total += candidate.Amount
+
+		// Pay the excess back when it covers the change output.
+		if changePkScript != nil {
+			tx.AddTxOut(&types.TxOutput{PkScript: changePkScript})
+			size := tx.SerializeSize()
+			fee := requiredFee(size, feePerKB)
+			if total > target+uint64(fee) {
+				return newInputSelection(policy, sorted[:len(tx.TxIn)],
+					total-target-uint64(fee), fee, size), nil
+			}
+			tx.TxOut = tx.TxOut[:len(tx.TxOut)-1]
+		}
+
+		size := tx.SerializeSize()
+		if total >= target+uint64(requiredFee(size, feePerKB)) {
+			return newInputSelection(policy, sorted[:len(tx.TxIn)], 0,
+				int64(total-target), size), nil
+		}
+	}
+
+	str := fmt.Sprintf("candidates paying %d can't pay %d with a fee of "+
+		"%d per kilobyte", total, target, feePerKB)
+	return nil, miningRuleError(ErrInsufficientFunds, str)
+}
+
+// requiredFee returns the fee of a transaction of the passed size paying at
+// least the passed fee per kilobyte, rounded up so that its fee per kilobyte,
+// as the mempool computes it, isn't below the passed one.
+func requiredFee(size int, feePerKB int64) int64 {
+	return (int64(size)*feePerKB + 999) / 1000
+}
+
+// newInputSelection returns the selection of the passed inputs paying the
+// passed change and fee for a transaction of the passed size.
+func newInputSelection(policy *Policy, inputs []SpendCandidate, change uint64,
+	fee int64, size int) *InputSelection {
+
+	feePerKB := fee * 1000 / int64(size)
+	return &InputSelection{
+		Inputs:   inputs,
+		Change:   change,
+		Fee:      fee,
+		Size:     size,
+		FeePerKB: feePerKB,
+		Minable:  feePerKB >= policy.TxMinFreeFee,
+	}
+}
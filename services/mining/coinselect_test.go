@@ -0,0 +1,66 @@
+package mining
+
+import (
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"testing"
+)
+
+// TestSelectInputs ensures the selected inputs pay the requested fee rate, and
+// that a transaction is only reported minable when its fee rate clears the
+// TxMinFreeFee of the policy.
+func TestSelectInputs(t *testing.T) {
+	const minFreeFee = 1000
+	policy := &Policy{TxMinFreeFee: minFreeFee}
+	var candidates []SpendCandidate
+	for i := uint32(0); i < 10; i++ {
+		candidates = append(candidates, SpendCandidate{
+			OutPoint:       *types.NewOutPoint(&hash.Hash{0x01}, i),
+			Amount:         uint64(1000 * (i + 1)),
+			SignScriptSize: 150,
+		})
+	}
+	outputs := []*types.TxOutput{{Amount: 40000, PkScript: make([]byte, 25)}}
+	change := make([]byte, 25)
+
+	tests := []struct {
+		name     string
+		feePerKB int64
+		minable  bool
+	}{
+		{"just clears", minFreeFee, true},
+		{"just misses", minFreeFee - 1, false},
+	}
+	for _, test := range tests {
+		sel, err := SelectInputs(policy, candidates, outputs, change,
+			test.feePerKB)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		// The 5 largest candidates pay 40000, so the sixth pays the fee
+		// and the change.  The transaction is larger than a kilobyte, so
+		// its fee per kilobyte is exactly the requested one.
+		if len(sel.Inputs) != 6 || sel.Inputs[0].Amount != 10000 {
+			t.Fatalf("%s: selected %v", test.name, sel.Inputs)
+		}
+		var total uint64
+		for _, input := range sel.Inputs {
+			total += input.Amount
+		}
+		if total != outputs[0].Amount+sel.Change+uint64(sel.Fee) {
+			t.Errorf("%s: inputs %d don't pay outputs, change %d and "+
+				"fee %d", test.name, total, sel.Change, sel.Fee)
+		}
+		if sel.FeePerKB != test.feePerKB || sel.Minable != test.minable {
+			t.Errorf("%s: fee per kilobyte %d minable %v, want %d %v",
+				test.name, sel.FeePerKB, sel.Minable, test.feePerKB,
+				test.minable)
+		}
+	}
+
+	_, err := SelectInputs(policy, candidates[:5], outputs, change, minFreeFee)
+	if rerr, ok := err.(MiningRuleError); !ok || rerr.ErrorCode != ErrInsufficientFunds {
+		t.Errorf("insufficient candidates: got %v, want ErrInsufficientFunds",
+			err)
+	}
+}
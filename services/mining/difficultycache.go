@@ -0,0 +1,109 @@
+// Copyright (c) 2017-2018 The qitmeer developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/types/pow"
+	"sync"
+	"time"
+)
+
+// DefaultDifficultyCacheBucket is the default span of the timestamp buckets of
+// a difficulty cache.
+const DefaultDifficultyCacheBucket = 15 * time.Second
+
+// DifficultyCache caches the difficulty required of the next block for every
+// proof of work type, keyed by the main chain tip and the bucket of the
+// timestamp, so that templates rebuilt in a rapid succession on the same tip,
+// such as on every mempool change, don't retarget each algorithm again.  The
+// cache holds the difficulties of a single tip and bucket, and drops them when
+// a lookup is for another tip or the bucket rolls over.
+//
+// The difficulty of a timestamp is the one computed for the first timestamp
+// of its bucket looked up, so the buckets must be short enough for networks
+// which reduce the difficulty over time.
+//
+// This type is safe for concurrent access.
+type DifficultyCache struct {
+	sync.Mutex
+	bucketSize time.Duration
+	tip        hash.Hash
+	bucket     int64
+	entries    map[pow.PowType]uint32
+	hits       uint64
+	misses     uint64
+}
+
+// NewDifficultyCache returns an empty difficulty cache with timestamp buckets
+// of the passed span.  A non-positive span uses DefaultDifficultyCacheBucket.
+func NewDifficultyCache(bucketSize time.Duration) *DifficultyCache {
+	if bucketSize <= 0 {
+		bucketSize = DefaultDifficultyCacheBucket
+	}
+	return &DifficultyCache{
+		bucketSize: bucketSize,
+		entries:    make(map[pow.PowType]uint32),
+	}
+}
+
+// CalcNextRequiredDifficulty returns the difficulty the passed chain requires
+// of the next block with the passed timestamp and proof of work type, using
+// the cached difficulty when available.  The passed tip must be the main chain
+// tip the chain computes the difficulty from.  A nil cache always computes.
+func (c *DifficultyCache) CalcNextRequiredDifficulty(chain DifficultyCalculator,
+	tip *hash.Hash, ts time.Time, powType pow.PowType) (uint32, error) {
+
+	if c == nil {
+		return chain.CalcNextRequiredDifficulty(ts, powType)
+	}
+
+	bucket := ts.UnixNano() / int64(c.bucketSize)
+	c.Lock()
+	if !c.tip.IsEqual(tip) || c.bucket != bucket {
+		c.tip = *tip
+		c.bucket = bucket
+		c.entries = make(map[pow.PowType]uint32)
+	}
+	bits, ok := c.entries[powType]
+	if ok {
+		c.hits++
+		c.Unlock()
+		return bits, nil
+	}
+	c.misses++
+	c.Unlock()
+
+	bits, err := chain.CalcNextRequiredDifficulty(ts, powType)
+	if err != nil {
+		return 0, err
+	}
+	c.Lock()
+	// The entries may have moved to another tip or bucket meanwhile.
+	if c.tip.IsEqual(tip) && c.bucket == bucket {
+		c.entries[powType] = bits
+	}
+	c.Unlock()
+	return bits, nil
+}
+
+// HitRate returns the share of lookups answered from the cache since it was
+// created.
+func (c *DifficultyCache) HitRate() float64 {
+	c.Lock()
+	defer c.Unlock()
+	total := c.hits + c.misses
+	if total == 0 {
+		return 0
+	}
+	return float64(c.hits) / float64(total)
+}
+
+// Len returns the number of cached difficulties.
+func (c *DifficultyCache) Len() int {
+	c.Lock()
+	defer c.Unlock()
+	return len(c.entries)
+}
@@ -0,0 +1,100 @@
+package mining
+
+import (
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/types/pow"
+	"testing"
+	"time"
+)
+
+// countingTestChain is a retargetTestChain which counts the difficulty
+// computations.
+type countingTestChain struct {
+	retargetTestChain
+	calls int
+}
+
+func (c *countingTestChain) CalcNextRequiredDifficulty(timestamp time.Time, powType pow.PowType) (uint32, error) {
+	c.calls++
+	return c.retargetTestChain.CalcNextRequiredDifficulty(timestamp, powType)
+}
+
+func TestDifficultyCache(t *testing.T) {
+	chain := &countingTestChain{}
+	c := NewDifficultyCache(time.Minute)
+	tip := hash.Hash{0x01}
+	ts := time.Unix(1577836800, 0)
+
+	want, err := templateDifficulties(chain, nil, &tip, ts)
+	if err != nil {
+		t.Fatalf("templateDifficulties: %v", err)
+	}
+	chain.calls = 0
+	for i := 0; i < 2; i++ {
+		got, err := templateDifficulties(chain, c, &tip, ts.Add(time.Duration(i)*time.Second))
+		if err != nil {
+			t.Fatalf("templateDifficulties: %v", err)
+		}
+		for _, powType := range templatePowTypes {
+			if got[powType] != want[powType] {
+				t.Errorf("%v: got %08x, want %08x", powType, got[powType],
+					want[powType])
+			}
+		}
+	}
+	if chain.calls != len(templatePowTypes) {
+		t.Errorf("got %d computations, want %d", chain.calls,
+			len(templatePowTypes))
+	}
+	if got := c.HitRate(); got != 0.5 {
+		t.Errorf("HitRate: got %v, want 0.5", got)
+	}
+
+	// Another tip and a rolled over bucket are computed again.
+	tests := []struct {
+		name string
+		tip  hash.Hash
+		ts   time.Time
+	}{
+		{"new tip", hash.Hash{0x02}, ts},
+		{"next bucket", hash.Hash{0x02}, ts.Add(time.Minute)},
+	}
+	for _, test := range tests {
+		chain.calls = 0
+		bits, err := c.CalcNextRequiredDifficulty(chain, &test.tip, test.ts,
+			pow.BLAKE2BD)
+		if err != nil {
+			t.Fatalf("%s: CalcNextRequiredDifficulty: %v", test.name, err)
+		}
+		if chain.calls != 1 {
+			t.Errorf("%s: cached difficulty reused", test.name)
+		}
+		if want, _ := chain.retargetTestChain.CalcNextRequiredDifficulty(
+			test.ts, pow.BLAKE2BD); bits != want {
+			t.Errorf("%s: got %08x, want %08x", test.name, bits, want)
+		}
+		if c.Len() != 1 {
+			t.Errorf("%s: Len: got %d, want 1", test.name, c.Len())
+		}
+	}
+}
+
+// BenchmarkDifficultyCache compares computing the difficulty of every proof of
+// work type on every build against reusing the cached difficulties.
+func BenchmarkDifficultyCache(b *testing.B) {
+	chain := &retargetTestChain{}
+	tip := hash.Hash{0x01}
+	ts := time.Unix(1577836800, 0)
+
+	b.Run("cold", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			templateDifficulties(chain, nil, &tip, ts)
+		}
+	})
+	b.Run("warm", func(b *testing.B) {
+		c := NewDifficultyCache(time.Minute)
+		for i := 0; i < b.N; i++ {
+			templateDifficulties(chain, c, &tip, ts)
+		}
+	})
+}
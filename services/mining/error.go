@@ -64,23 +64,93 @@ const (
 
 	// ErrFetchTxStore indicates a transaction store failed to fetch.
 	ErrFetchTxStore
+
+	// ErrMandatoryTransaction indicates that a transaction which must be
+	// included in the block template is invalid or doesn't fit.
+	ErrMandatoryTransaction
+
+	// ErrTemplateSerialization indicates that a block template doesn't
+	// survive a serialization round trip unchanged.
+	ErrTemplateSerialization
+
+	// ErrInvalidCoinbasePayouts indicates that the outputs the coinbase
+	// subsidy is split across are invalid.
+	ErrInvalidCoinbasePayouts
+
+	// ErrInvalidPow indicates that the proof of work of a solved block
+	// doesn't satisfy its header.
+	ErrInvalidPow
+
+	// ErrFeesOverflow indicates that the total fees of a block template
+	// overflow or exceed the maximum amount.
+	ErrFeesOverflow
+
+	// ErrCoinbaseAmount indicates that the outputs of the coinbase of a
+	// block template don't pay its subsidy.
+	ErrCoinbaseAmount
+
+	// ErrRegtestMode indicates that the regtest mode of the mining policy
+	// is enabled on a network which doesn't allow it.
+	ErrRegtestMode
+
+	// ErrWitnessCommitment indicates that the witness commitment of the
+	// coinbase of a block template doesn't match its transactions.
+	ErrWitnessCommitment
+
+	// ErrInsufficientFunds indicates that the spendable outputs passed to
+	// SelectInputs can't pay the outputs and the fee of a transaction.
+	ErrInsufficientFunds
+
+	// ErrStaleParents indicates that the parents of a submitted block are
+	// no longer the tips the next block must build on.
+	ErrStaleParents
+
+	// ErrCoinbaseAddressReuse indicates that the coinbase of a block
+	// template pays to an address already paid to by a recent template.
+	ErrCoinbaseAddressReuse
+
+	// ErrNonStandardCoinbaseScript indicates that the signature script of
+	// a coinbase doesn't have the layout of the standard coinbase scripts.
+	ErrNonStandardCoinbaseScript
+
+	// ErrInvalidMaxBlockSigOps indicates that the max signature operation
+	// cost of the mining policy exceeds the consensus max.
+	ErrInvalidMaxBlockSigOps
+
+	// ErrSequenceLocks indicates that the relative lock times of the
+	// inputs of a transaction aren't met by the block template.
+	ErrSequenceLocks
 )
 
 // Map of MiningErrorCode values back to their constant names for pretty printing.
 var miningErrorCodeStrings = map[MiningErrorCode]string{
-	ErrNotEnoughVoters:        "ErrNotEnoughVoters",
-	ErrFailedToGetGeneration:  "ErrFailedToGetGeneration",
-	ErrGetStakeDifficulty:     "ErrGetStakeDifficulty",
-	ErrGetTopBlock:            "ErrGetTopBlock",
-	ErrCreatingCoinbase:       "ErrCreatingCoinbase",
-	ErrGettingMedianTime:      "ErrGettingMedianTime",
-	ErrGettingDifficulty:      "ErrGettingDifficulty",
-	ErrTransactionAppend:      "ErrTransactionAppend",
-	ErrCheckBlockSanity:       "ErrCheckBlockSanity",
-	ErrCheckConnectBlock:      "ErrCheckConnectBlock",
-	ErrCoinbaseLengthOverflow: "ErrCoinbaseLengthOverflow",
-	ErrFraudProofIndex:        "ErrFraudProofIndex",
-	ErrFetchTxStore:           "ErrFetchTxStore",
+	ErrNotEnoughVoters:           "ErrNotEnoughVoters",
+	ErrFailedToGetGeneration:     "ErrFailedToGetGeneration",
+	ErrGetStakeDifficulty:        "ErrGetStakeDifficulty",
+	ErrGetTopBlock:               "ErrGetTopBlock",
+	ErrCreatingCoinbase:          "ErrCreatingCoinbase",
+	ErrGettingMedianTime:         "ErrGettingMedianTime",
+	ErrGettingDifficulty:         "ErrGettingDifficulty",
+	ErrTransactionAppend:         "ErrTransactionAppend",
+	ErrCheckBlockSanity:          "ErrCheckBlockSanity",
+	ErrCheckConnectBlock:         "ErrCheckConnectBlock",
+	ErrCoinbaseLengthOverflow:    "ErrCoinbaseLengthOverflow",
+	ErrFraudProofIndex:           "ErrFraudProofIndex",
+	ErrFetchTxStore:              "ErrFetchTxStore",
+	ErrMandatoryTransaction:      "ErrMandatoryTransaction",
+	ErrTemplateSerialization:     "ErrTemplateSerialization",
+	ErrInvalidCoinbasePayouts:    "ErrInvalidCoinbasePayouts",
+	ErrInvalidPow:                "ErrInvalidPow",
+	ErrFeesOverflow:              "ErrFeesOverflow",
+	ErrCoinbaseAmount:            "ErrCoinbaseAmount",
+	ErrRegtestMode:               "ErrRegtestMode",
+	ErrWitnessCommitment:         "ErrWitnessCommitment",
+	ErrInsufficientFunds:         "ErrInsufficientFunds",
+	ErrStaleParents:              "ErrStaleParents",
+	ErrCoinbaseAddressReuse:      "ErrCoinbaseAddressReuse",
+	ErrNonStandardCoinbaseScript: "ErrNonStandardCoinbaseScript",
+	ErrInvalidMaxBlockSigOps:     "ErrInvalidMaxBlockSigOps",
+	ErrSequenceLocks:             "ErrSequenceLocks",
 }
 
 // String returns the MiningErrorCode as a human-readable name.
@@ -111,6 +181,12 @@ func (e MiningRuleError) GetCode() MiningErrorCode {
 	return e.ErrorCode
 }
 
+// RuleCode returns the name of the code of the error, which is the data of
+// its RPC error.
+func (e MiningRuleError) RuleCode() string {
+	return e.ErrorCode.String()
+}
+
 // miningRuleError creates an RuleError given a set of arguments.
 func miningRuleError(c MiningErrorCode, desc string) MiningRuleError {
 	return MiningRuleError{ErrorCode: c, Description: desc}
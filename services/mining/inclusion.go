@@ -0,0 +1,75 @@
+// Copyright (c) 2017-2018 The qitmeer developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"github.com/Qitmeer/qitmeer/services/mempool"
+)
+
+// InclusionEstimator estimates how likely a transaction is to be included in
+// the next block template, from the fee histogram of the mempool and the max
+// block size of the mining policy, such as for wallets to tell whether a
+// transaction they just broadcast is likely to be in the next block.
+type InclusionEstimator struct {
+	policy    *Policy
+	histogram func() []mempool.FeeBucket
+}
+
+// NewInclusionEstimator returns an estimator for the templates of the passed
+// policy built from the mempool whose fee histogram is returned by the passed
+// function, such as TxPool.FeeHistogram.
+func NewInclusionEstimator(policy *Policy, histogram func() []mempool.FeeBucket) *InclusionEstimator {
+	return &InclusionEstimator{
+		policy:    policy,
+		histogram: histogram,
+	}
+}
+
+// EstimateInclusionProbability returns the probability that a transaction of
+// the passed size paying the passed fee rate, in atoms per 1000 bytes, makes
+// the next template.  See inclusionProbability for the estimation.
+//
+// This function is safe for concurrent access.
+func (e *InclusionEstimator) EstimateInclusionProbability(feePerKB int64, txSize int) float64 {
+	return inclusionProbability(e.histogram(), e.policy.BlockMaxSize,
+		feePerKB, txSize)
+}
+
+// inclusionProbability returns the probability that a transaction of the
+// passed size paying the passed fee rate fits in a template of the passed max
+// size filled by fee rate from a mempool of the passed fee histogram.  The
+// transactions of the buckets of higher fee rates come first, and the
+// transaction is assumed to be anywhere among the ones of its bucket, so the
+// probability is the share of the positions in its bucket which leave it
+// room.  It is 1 when the whole bucket fits along with the transaction, and 0
+// when the higher buckets already fill the template.  The coinbase and the
+// high-priority area are not accounted for.
+func inclusionProbability(buckets []mempool.FeeBucket, blockMaxSize uint32,
+	feePerKB int64, txSize int) float64 {
+
+	space := int64(blockMaxSize) - blockHeaderOverhead - int64(txSize)
+	if space < 0 {
+		return 0
+	}
+	if len(buckets) == 0 {
+		return 1
+	}
+
+	// As in the histogram, a rate below the lowest bound is in the first
+	// bucket.
+	i := len(buckets) - 1
+	for i > 0 && feePerKB < buckets[i].MinFeePerKB {
+		i--
+	}
+	aheadMax := buckets[i].CumulativeSize
+	aheadMin := aheadMax - buckets[i].Size
+	switch {
+	case aheadMax <= space:
+		return 1
+	case aheadMin > space:
+		return 0
+	}
+	return float64(space-aheadMin) / float64(aheadMax-aheadMin)
+}
@@ -0,0 +1,64 @@
+package mining
+
+import (
+	"github.com/Qitmeer/qitmeer/services/mempool"
+	"testing"
+)
+
+// TestInclusionProbability ensures the inclusion probability of a transaction
+// drops as the transactions paying higher fee rates fill the template.
+func TestInclusionProbability(t *testing.T) {
+	// Templates have room for 10000 bytes of transactions.
+	blockMaxSize := uint32(blockHeaderOverhead + 10000)
+	histogram := func(sizes ...int64) []mempool.FeeBucket {
+		bounds := []int64{1000, 5000, 10000}
+		buckets := make([]mempool.FeeBucket, len(bounds))
+		var cumulativeSize int64
+		for i := len(bounds) - 1; i >= 0; i-- {
+			cumulativeSize += sizes[i]
+			buckets[i] = mempool.FeeBucket{
+				MinFeePerKB:    bounds[i],
+				Size:           sizes[i],
+				CumulativeSize: cumulativeSize,
+			}
+		}
+		return buckets
+	}
+
+	tests := []struct {
+		name     string
+		buckets  []mempool.FeeBucket
+		feePerKB int64
+		txSize   int
+		want     float64
+	}{
+		{"empty mempool", nil, 1000, 250, 1},
+		{"top bucket", histogram(4000, 4000, 4000), 20000, 250, 1},
+		{"room for the bucket", histogram(4000, 4000, 4000), 5000, 250, 1},
+		{"partly full bucket", histogram(4000, 4000, 4000), 2000, 250,
+			1750.0 / 4000},
+		{"below the lowest bound", histogram(4000, 4000, 4000), 0, 250,
+			1750.0 / 4000},
+		{"full top bucket", histogram(4000, 4000, 12000), 20000, 250,
+			9750.0 / 12000},
+		{"filled by higher rates", histogram(4000, 4000, 12000), 5000,
+			250, 0},
+		{"larger than the block", nil, 20000, 20000, 0},
+	}
+	for _, test := range tests {
+		got := inclusionProbability(test.buckets, blockMaxSize,
+			test.feePerKB, test.txSize)
+		if got != test.want {
+			t.Errorf("%s: got probability %v, want %v", test.name, got,
+				test.want)
+		}
+	}
+
+	// The estimator reads the histogram of the mempool.
+	pool := mempool.New(&mempool.Config{})
+	estimator := NewInclusionEstimator(&Policy{BlockMaxSize: blockMaxSize},
+		pool.FeeHistogram)
+	if got := estimator.EstimateInclusionProbability(1000, 250); got != 1 {
+		t.Errorf("got probability %v in an empty mempool, want 1", got)
+	}
+}
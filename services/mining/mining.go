@@ -7,14 +7,23 @@
 package mining
 
 import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
 	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/address"
 	"github.com/Qitmeer/qitmeer/core/blockchain"
 	"github.com/Qitmeer/qitmeer/core/merkle"
 	"github.com/Qitmeer/qitmeer/core/protocol"
 	s "github.com/Qitmeer/qitmeer/core/serialization"
 	"github.com/Qitmeer/qitmeer/core/types"
+	"github.com/Qitmeer/qitmeer/core/types/pow"
+	"github.com/Qitmeer/qitmeer/crypto/cuckoo"
 	"github.com/Qitmeer/qitmeer/engine/txscript"
 	"github.com/Qitmeer/qitmeer/params"
+	"github.com/Qitmeer/qitmeer/services/mempool"
+	"math"
+	"math/big"
 	"time"
 )
 
@@ -52,6 +61,16 @@ const (
 	// for networks other than the main network.
 	// TODO, refactor the location of generatedBlockVersionTestPow const
 	generatedBlockVersionTestMixPow = 18
+
+	// minExtraNonceSize is the minimum number of bytes which can be
+	// reserved for the extra nonce in the coinbase script.  A single byte
+	// push could be encoded as a small integer opcode without data.
+	minExtraNonceSize = 2
+
+	// DefaultPowType can be passed to NewBlockTemplate instead of a proof
+	// of work type to generate a template for the default proof of work of
+	// the network.
+	DefaultPowType pow.PowType = math.MaxUint8
 )
 
 // TxSource represents a source of transactions to consider for inclusion in
@@ -75,28 +94,105 @@ type TxSource interface {
 	// HaveAllTransactions returns whether or not all of the passed
 	// transaction hashes exist in the source pool.
 	HaveAllTransactions(hashes []hash.Hash) bool
+
+	// ConflictsWith returns the hashes of the transactions in the source
+	// pool which spend any of the inputs of the passed transaction, which
+	// are the transactions it would replace.
+	ConflictsWith(tx *types.Tx) []*hash.Hash
+
+	// Snapshot returns a frozen view of the source pool, which a block
+	// template is built from so that it is consistent even if the pool
+	// changes during the build.
+	Snapshot() mempool.TxView
+}
+
+// MinAllowedTimestamp returns the earliest timestamp the node accepts for a
+// block building on the end of the provided best chain, which is one second
+// after its median time past.  External mining software assembling its own
+// header can use it to avoid blocks rejected for their timestamp.
+func MinAllowedTimestamp(bc *blockchain.BlockChain) time.Time {
+	return minAllowedTimestamp(bc.BestSnapshot().MedianTime)
 }
 
-// Allowed timestamp for a block building on the end of the provided best chain.
+// MinimumMedianTime returns the minimum allowed timestamp for a block building
+// on the end of the provided best chain.
+//
+// Deprecated: use MinAllowedTimestamp.
 func MinimumMedianTime(bc *blockchain.BlockChain) time.Time {
-	return bc.BestSnapshot().MedianTime.Add(time.Second)
+	return MinAllowedTimestamp(bc)
+}
+
+// minAllowedTimestamp returns the earliest timestamp allowed after the passed
+// median time past.
+func minAllowedTimestamp(medianTime time.Time) time.Time {
+	return medianTime.Add(time.Second)
 }
 
 // medianAdjustedTime returns the current time adjusted
 func MedianAdjustedTime(bc *blockchain.BlockChain, timeSource blockchain.MedianTimeSource) time.Time {
-	newTimestamp := timeSource.AdjustedTime()
-	minTimestamp := MinimumMedianTime(bc)
-	if newTimestamp.Before(minTimestamp) {
-		newTimestamp = minTimestamp
-	}
+	return clampTimestamp(timeSource.AdjustedTime(), MinAllowedTimestamp(bc))
+}
 
-	return newTimestamp
+// clampTimestamp returns the passed timestamp, or the minimum allowed one if
+// it is earlier.
+func clampTimestamp(timestamp time.Time, minTimestamp time.Time) time.Time {
+	if timestamp.Before(minTimestamp) {
+		return minTimestamp
+	}
+	return timestamp
 }
 
-func standardCoinbaseScript(nextBlockHeight uint64, extraNonce uint64) ([]byte, error) {
-	return txscript.NewScriptBuilder().AddInt64(int64(nextBlockHeight)).
-		AddInt64(int64(extraNonce)).AddData([]byte(CoinbaseFlags)).
-		Script()
+// standardCoinbaseScript returns a coinbase script pushing the passed height,
+// the extra nonce and the coinbase flags.  When extraNonceSize is not zero,
+// the extra nonce is pushed as that many bytes, starting with the passed
+// value, and the offset of those bytes in the script is returned so that
+// miners can overwrite them without touching the height push.
+func standardCoinbaseScript(nextBlockHeight uint64, extraNonce uint64,
+	extraNonceSize int) ([]byte, int, error) {
+
+	if extraNonceSize == 0 {
+		script, err := txscript.NewScriptBuilder().AddInt64(int64(nextBlockHeight)).
+			AddInt64(int64(extraNonce)).AddData([]byte(CoinbaseFlags)).
+			Script()
+		return script, 0, err
+	}
+	if extraNonceSize < minExtraNonceSize ||
+		extraNonceSize > txscript.MaxScriptElementSize {
+		str := fmt.Sprintf("extra nonce size %d is out of range [%d, %d]",
+			extraNonceSize, minExtraNonceSize, txscript.MaxScriptElementSize)
+		return nil, 0, miningRuleError(ErrCoinbaseLengthOverflow, str)
+	}
+
+	var nonce [8]byte
+	binary.LittleEndian.PutUint64(nonce[:], extraNonce)
+	extraNonceData := make([]byte, extraNonceSize)
+	copy(extraNonceData, nonce[:])
+
+	heightPush, err := txscript.NewScriptBuilder().
+		AddInt64(int64(nextBlockHeight)).Script()
+	if err != nil {
+		return nil, 0, err
+	}
+	extraNoncePush, err := txscript.NewScriptBuilder().
+		AddData(extraNonceData).Script()
+	if err != nil {
+		return nil, 0, err
+	}
+	script, err := txscript.NewScriptBuilder().AddInt64(int64(nextBlockHeight)).
+		AddData(extraNonceData).AddData([]byte(CoinbaseFlags)).Script()
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(script) > blockchain.MaxCoinbaseScriptLen {
+		str := fmt.Sprintf("coinbase script with a %d bytes extra nonce "+
+			"is %d bytes, which is above the max of %d", extraNonceSize,
+			len(script), blockchain.MaxCoinbaseScriptLen)
+		return nil, 0, miningRuleError(ErrCoinbaseLengthOverflow, str)
+	}
+
+	// The extra nonce follows the height push and its own push opcode.
+	offset := len(heightPush) + len(extraNoncePush) - extraNonceSize
+	return script, offset, nil
 }
 
 // standardCoinbaseOpReturn creates a standard OP_RETURN output to insert into
@@ -112,13 +208,270 @@ func standardCoinbaseOpReturn(enData []byte) ([]byte, error) {
 	return extraNonceScript, nil
 }
 
+// coinbaseOpReturn returns the OP_RETURN output script of the coinbase of the
+// templates built with the passed policy, which holds the placeholder of the
+// coinbase commitment of the policy, or nil when the coinbase has no such
+// output.  A policy omitting the OP_RETURN output can't have a commitment.
+func coinbaseOpReturn(policy *Policy) ([]byte, error) {
+	if policy.CoinbaseCommitment == nil {
+		return nil, nil
+	}
+	if policy.OmitCoinbaseOpReturn {
+		str := "the coinbase commitment needs the OP_RETURN output the " +
+			"policy omits"
+		return nil, miningRuleError(ErrCreatingCoinbase, str)
+	}
+	data, err := policy.CoinbaseCommitment(nil)
+	if err != nil {
+		return nil, miningRuleError(ErrCreatingCoinbase, err.Error())
+	}
+	return standardCoinbaseOpReturn(data)
+}
+
+// CoinbaseCommitmentFunc returns the commitment embedded in the coinbase over
+// the passed transactions of a block, whose first is the coinbase.  Since the
+// space of the commitment is reserved before the transactions are selected,
+// the commitment must have the same length whatever the transactions, none
+// included, and must not depend on the coinbase.
+type CoinbaseCommitmentFunc func(blockTxns []*types.Tx) ([]byte, error)
+
+// WitnessRootCommitment is a CoinbaseCommitmentFunc which commits to the
+// merkle root of the witness hashes of the transactions, where the coinbase is
+// a zero hash.
+func WitnessRootCommitment(blockTxns []*types.Tx) ([]byte, error) {
+	merkles := merkle.BuildMerkleTreeStore(blockTxns, true)
+	return merkles[len(merkles)-1].Bytes(), nil
+}
+
+// commitCoinbase replaces the commitment placeholder in the OP_RETURN output
+// of the coinbase, which is the first of the passed transactions, with the
+// commitment over them, and returns the commitment.
+func commitCoinbase(blockTxns []*types.Tx, commitment CoinbaseCommitmentFunc) ([]byte, error) {
+	data, err := commitment(blockTxns)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty coinbase commitment")
+	}
+	pkScript, err := standardCoinbaseOpReturn(data)
+	if err != nil {
+		return nil, err
+	}
+
+	// The commitment output is the last one of the coinbase.
+	coinbaseTx := blockTxns[0]
+	txOut := coinbaseTx.Tx.TxOut[len(coinbaseTx.Tx.TxOut)-1]
+	if len(txOut.PkScript) == 0 || txOut.PkScript[0] != txscript.OP_RETURN {
+		return nil, fmt.Errorf("coinbase has no commitment output")
+	}
+	if len(txOut.PkScript) != len(pkScript) {
+		return nil, fmt.Errorf("coinbase commitment is %d bytes, "+
+			"%d were reserved", len(pkScript), len(txOut.PkScript))
+	}
+	txOut.PkScript = pkScript
+	coinbaseTx.RefreshHash()
+	return data, nil
+}
+
+// CoinbaseBuilder builds the coinbase of block templates, so that the payout
+// can be built, and signed, outside of the node, such as by the hardware
+// security module of a pool.  The coinbase must keep the output layout the
+// chain enforces, including the tax output on networks with tax, and its last
+// output must be the commitment placeholder when the policy commits to the
+// transactions of the block.
+//
+// The node doesn't know where a builder puts the extra nonce, so the templates
+// built with one report no extra nonce space: ExtraNonceOffset and
+// ExtraNonceSize are zero, and miners have to vary the nonce of the header or
+// ask for a new template instead.
+type CoinbaseBuilder interface {
+	// BuildCoinbase returns the coinbase of the block at the passed
+	// height, using the passed extra nonce.  Its outputs must pay exactly
+	// subsidy, the total subsidy of the block including the tax.  The
+	// transaction fees are not part of the outputs, since the coinbase
+	// is built before the transactions are selected and the consensus
+	// rules credit the fees to the miner when the coinbase is spent.
+	BuildCoinbase(height, subsidy int64, extraNonce uint64) (*types.Tx, error)
+}
+
+// standardCoinbaseBuilder is the CoinbaseBuilder used when the policy has
+// none, which builds the coinbase with createCoinbaseTx.
+type standardCoinbaseBuilder struct {
+	subsidyCache     *blockchain.SubsidyCache
+	opReturnPkScript []byte
+	blues            int64
+	payToAddress     types.Address
+	payouts          []CoinbaseOutput
+	params           *params.Params
+	extraNonceSize   int
+
+	// extraNonceOffset and reservedExtraNonceSize locate the extra nonce
+	// in the signature script of the last built coinbase.
+	extraNonceOffset       int
+	reservedExtraNonceSize int
+}
+
+// BuildCoinbase builds the coinbase paying to the address or the payouts of
+// the builder.  The subsidy is derived from the blue count of the builder.
+func (b *standardCoinbaseBuilder) BuildCoinbase(height, subsidy int64, extraNonce uint64) (*types.Tx, error) {
+	coinbaseScript, extraNonceOffset, err := standardCoinbaseScript(
+		uint64(height), extraNonce, b.extraNonceSize)
+	if err != nil {
+		return nil, err
+	}
+	coinbaseTx, err := createCoinbaseTx(b.subsidyCache, coinbaseScript,
+		b.opReturnPkScript, b.blues, b.payToAddress, b.payouts, b.params)
+	if err != nil {
+		return nil, err
+	}
+	b.extraNonceOffset = extraNonceOffset
+	b.reservedExtraNonceSize = b.extraNonceSize
+	return coinbaseTx, nil
+}
+
+// templateCoinbase builds the coinbase of a block template at the passed
+// height with the coinbase builder of the policy, or with the passed standard
+// builder when the policy has none.
+func templateCoinbase(policy *Policy, std *standardCoinbaseBuilder, height uint64, subsidy int64, extraNonce uint64) (*types.Tx, error) {
+	var builder CoinbaseBuilder = std
+	if policy.CoinbaseBuilder != nil {
+		builder = policy.CoinbaseBuilder
+	}
+	coinbaseTx, err := builder.BuildCoinbase(int64(height), subsidy, extraNonce)
+	if err != nil {
+		return nil, err
+	}
+	if coinbaseTx == nil || !coinbaseTx.Tx.IsCoinBase() {
+		return nil, miningRuleError(ErrCreatingCoinbase,
+			"coinbase builder returned no coinbase")
+	}
+	if policy.OmitCoinbaseOpReturn {
+		for _, txOut := range coinbaseTx.Tx.TxOut {
+			if txscript.GetScriptClass(txscript.DefaultScriptVersion,
+				txOut.PkScript) == txscript.NullDataTy {
+				return nil, miningRuleError(ErrCreatingCoinbase,
+					"coinbase has an OP_RETURN output the policy omits")
+			}
+		}
+	}
+	return coinbaseTx, nil
+}
+
+// checkCoinbaseAmount returns an error unless the outputs of the passed
+// coinbase pay exactly the passed amount.
+func checkCoinbaseAmount(coinbaseTx *types.Tx, amount int64) error {
+	var total uint64
+	for _, txOut := range coinbaseTx.Tx.TxOut {
+		if txOut.Amount > types.MaxAmount-total {
+			str := "coinbase outputs exceed the maximum amount"
+			return miningRuleError(ErrCoinbaseAmount, str)
+		}
+		total += txOut.Amount
+	}
+	if amount < 0 || total != uint64(amount) {
+		str := fmt.Sprintf("coinbase pays %d instead of %d", total, amount)
+		return miningRuleError(ErrCoinbaseAmount, str)
+	}
+	return nil
+}
+
+// settleCoinbaseFees checks the coinbase of a template whose transactions pay
+// totalFees, and records the fees in the first entry of txFees.  The coinbase
+// pays the subsidy and tax only: the fees aren't part of its outputs, the
+// consensus rules credit them to the miner when the coinbase is spent.
+func settleCoinbaseFees(coinbaseTx *types.Tx, amount int64, totalFees int64, txFees []int64) error {
+	if err := checkCoinbaseAmount(coinbaseTx, amount); err != nil {
+		return err
+	}
+	if totalFees < 0 || totalFees > types.MaxAmount {
+		str := fmt.Sprintf("total fees %d are out of range", totalFees)
+		return miningRuleError(ErrFeesOverflow, str)
+	}
+	txFees[0] = -totalFees
+	return nil
+}
+
+// CoinbaseOutput is an address the coinbase pays Proportion of the miner
+// subsidy to.  Only one payout, of proportion 1, is accepted for now: see
+// maxCoinbasePayouts.
+type CoinbaseOutput struct {
+	Address    types.Address
+	Proportion float64
+}
+
+// coinbasePayoutsEpsilon is how far the proportions of coinbase payouts may
+// sum from 1 due to floating point rounding.
+const coinbasePayoutsEpsilon = 1e-9
+
+// maxCoinbasePayouts is the max number of payouts the miner subsidy is split
+// across.  The consensus rules credit the fees of a block to every spent
+// output of its coinbase, which are all flagged as coinbase outputs, so each
+// extra payout could claim the fees of the block again.  Coinbases with
+// several payouts wait for a consensus change which credits the fees to a
+// single designated output.
+const maxCoinbasePayouts = 1
+
+// splitCoinbaseAmount returns the amounts the passed payouts are paid out of
+// the passed amount.  The amounts sum to the passed amount exactly, the
+// rounding dust being paid to the first payout.  An error is returned unless
+// there are at most maxCoinbasePayouts payouts, every proportion is positive
+// and they sum to 1.
+func splitCoinbaseAmount(amount uint64, payouts []CoinbaseOutput) ([]uint64, error) {
+	if len(payouts) == 0 {
+		return nil, miningRuleError(ErrInvalidCoinbasePayouts,
+			"no coinbase payouts")
+	}
+	if len(payouts) > maxCoinbasePayouts {
+		str := fmt.Sprintf("%d coinbase payouts, the max is %d since "+
+			"every coinbase output would be credited the block fees",
+			len(payouts), maxCoinbasePayouts)
+		return nil, miningRuleError(ErrInvalidCoinbasePayouts, str)
+	}
+	total := 0.0
+	for i, payout := range payouts {
+		if payout.Address == nil {
+			str := fmt.Sprintf("coinbase payout %d has no address", i)
+			return nil, miningRuleError(ErrInvalidCoinbasePayouts, str)
+		}
+		if !(payout.Proportion > 0 && payout.Proportion <= 1) {
+			str := fmt.Sprintf("coinbase payout %d has proportion %v "+
+				"outside of (0, 1]", i, payout.Proportion)
+			return nil, miningRuleError(ErrInvalidCoinbasePayouts, str)
+		}
+		total += payout.Proportion
+	}
+	if math.Abs(total-1) > coinbasePayoutsEpsilon {
+		str := fmt.Sprintf("coinbase payout proportions sum to %v "+
+			"instead of 1", total)
+		return nil, miningRuleError(ErrInvalidCoinbasePayouts, str)
+	}
+
+	// Round every amount but the first down, and pay what remains to the
+	// first payout.
+	amounts := make([]uint64, len(payouts))
+	var paid uint64
+	for i := 1; i < len(payouts); i++ {
+		amounts[i] = uint64(float64(amount) * payouts[i].Proportion)
+		paid += amounts[i]
+	}
+	if paid > amount {
+		str := fmt.Sprintf("coinbase payouts pay %d out of %d", paid, amount)
+		return nil, miningRuleError(ErrInvalidCoinbasePayouts, str)
+	}
+	amounts[0] = amount - paid
+	return amounts, nil
+}
+
 // createCoinbaseTx returns a coinbase transaction paying an appropriate subsidy
 // based on the passed block height to the provided address.  When the address
-// is nil, the coinbase transaction will instead be redeemable by anyone.
+// is nil, the coinbase transaction will instead be redeemable by anyone.  When
+// payouts are passed, the subsidy is paid to them instead, which is limited to
+// maxCoinbasePayouts.
 //
 // See the comment for NewBlockTemplate for more information about why the nil
 // address handling is useful.
-func createCoinbaseTx(subsidyCache *blockchain.SubsidyCache, coinbaseScript []byte, opReturnPkScript []byte, nextBlocks int64, addr types.Address, params *params.Params) (*types.Tx, error) {
+func createCoinbaseTx(subsidyCache *blockchain.SubsidyCache, coinbaseScript []byte, opReturnPkScript []byte, nextBlocks int64, addr types.Address, payouts []CoinbaseOutput, params *params.Params) (*types.Tx, error) {
 	tx := types.NewTransaction()
 	tx.AddTxIn(&types.TxInput{
 		// Coinbase transactions have no inputs, so previous outpoint is
@@ -130,38 +483,49 @@ func createCoinbaseTx(subsidyCache *blockchain.SubsidyCache, coinbaseScript []by
 	})
 
 	// Create a coinbase with correct block subsidy and extranonce.
-	subsidy := blockchain.CalcBlockWorkSubsidy(subsidyCache,
-		nextBlocks, params)
-	tax := blockchain.CalcBlockTaxSubsidy(subsidyCache,
-		nextBlocks, params)
+	subsidy, tax := calcCoinbaseSubsidy(subsidyCache, nextBlocks, params)
 
 	// output
-	// Create the script to pay to the provided payment address if one was
-	// specified.  Otherwise create a script that allows the coinbase to be
+	// Split the subsidy across the payouts if any were specified.
+	// Otherwise create the script to pay to the provided payment address
+	// if one was specified, or a script that allows the coinbase to be
 	// redeemable by anyone.
-	var pksSubsidy []byte
-	var err error
-	if addr != nil {
-		pksSubsidy, err = txscript.PayToAddrScript(addr)
+	if len(payouts) > 0 {
+		amounts, err := splitCoinbaseAmount(subsidy, payouts)
 		if err != nil {
 			return nil, err
 		}
+		for i, payout := range payouts {
+			pkScript, err := coinbasePkScript(payout.Address, params)
+			if err != nil {
+				return nil, err
+			}
+			tx.AddTxOut(&types.TxOutput{
+				Amount:   amounts[i],
+				PkScript: pkScript,
+			})
+		}
 	} else {
-		scriptBuilder := txscript.NewScriptBuilder()
-		pksSubsidy, err = scriptBuilder.AddOp(txscript.OP_TRUE).Script()
-		if err != nil {
-			return nil, err
+		var pksSubsidy []byte
+		var err error
+		if addr != nil {
+			pksSubsidy, err = coinbasePkScript(addr, params)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			scriptBuilder := txscript.NewScriptBuilder()
+			pksSubsidy, err = scriptBuilder.AddOp(txscript.OP_TRUE).Script()
+			if err != nil {
+				return nil, err
+			}
 		}
+		// Subsidy paid to miner.
+		tx.AddTxOut(&types.TxOutput{
+			Amount:   subsidy,
+			PkScript: pksSubsidy,
+		})
 	}
-	if !params.HasTax() {
-		subsidy += uint64(tax)
-		tax = 0
-	}
-	// Subsidy paid to miner.
-	tx.AddTxOut(&types.TxOutput{
-		Amount:   subsidy,
-		PkScript: pksSubsidy,
-	})
 
 	// Tax output.
 	if params.HasTax() {
@@ -180,6 +544,148 @@ func createCoinbaseTx(subsidyCache *blockchain.SubsidyCache, coinbaseScript []by
 	return types.NewTx(tx), nil
 }
 
+// coinbasePkScript returns the script of a coinbase output paying to the
+// passed address, whichever its type: pay-to-pubkey-hash of any signature
+// algorithm, pay-to-script-hash or pay-to-pubkey.  The address must be for the
+// passed network.
+func coinbasePkScript(addr types.Address, params *params.Params) ([]byte, error) {
+	if !address.IsForNetwork(addr, params) {
+		str := fmt.Sprintf("coinbase address %v is not for the %s network",
+			addr.Encode(), params.Name)
+		return nil, miningRuleError(ErrCreatingCoinbase, str)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		str := fmt.Sprintf("coinbase address %v of type %T: %v",
+			addr.Encode(), addr, err)
+		return nil, miningRuleError(ErrCreatingCoinbase, str)
+	}
+	return pkScript, nil
+}
+
+// calcCoinbaseSubsidy returns the subsidy the coinbase of a block with the
+// passed blue count pays to the miner and the tax it pays to the organization.
+// On networks without tax the tax is paid to the miner as well.
+func calcCoinbaseSubsidy(subsidyCache *blockchain.SubsidyCache, nextBlocks int64, params *params.Params) (uint64, uint64) {
+	subsidy := blockchain.CalcBlockWorkSubsidy(subsidyCache,
+		nextBlocks, params)
+	tax := blockchain.CalcBlockTaxSubsidy(subsidyCache,
+		nextBlocks, params)
+	if !params.HasTax() {
+		subsidy += tax
+		tax = 0
+	}
+	return subsidy, tax
+}
+
+// DifficultyCalculator computes the difficulty required of the next block of
+// a chain.  It is implemented by *blockchain.BlockChain.
+type DifficultyCalculator interface {
+	CalcNextRequiredDifficulty(timestamp time.Time, powType pow.PowType) (uint32, error)
+}
+
+// templatePowTypes are the proof of work types block templates carry the
+// difficulty of.
+var templatePowTypes = []pow.PowType{pow.BLAKE2BD, pow.X16RV3, pow.X8R16,
+	pow.QITMEERKECCAK256, pow.CUCKAROO, pow.CUCKAROOM, pow.CUCKATOO}
+
+// PreviewNextDifficulty returns the difficulty the next block of the passed
+// chain would require with the passed timestamp and proof of work type, in
+// compact form and expanded.  The expanded difficulty is the target for the
+// hash based algorithms and the base difficulty for the cuckoo ones.  The chain
+// state is only read, so any timestamp can be previewed.
+func PreviewNextDifficulty(chain DifficultyCalculator, at time.Time,
+	powType pow.PowType) (uint32, *big.Int, error) {
+
+	bits, err := chain.CalcNextRequiredDifficulty(at, powType)
+	if err != nil {
+		return 0, nil, miningRuleError(ErrGettingDifficulty, err.Error())
+	}
+	return bits, pow.CompactToBig(bits), nil
+}
+
+// templateDifficulties returns the compact difficulty required of a block
+// template with the passed timestamp on the passed main chain tip for every
+// proof of work type, looked up in the passed cache.  A nil cache computes
+// every difficulty.
+func templateDifficulties(chain DifficultyCalculator, cache *DifficultyCache,
+	tip *hash.Hash, ts time.Time) (map[pow.PowType]uint32, error) {
+
+	difficulties := make(map[pow.PowType]uint32, len(templatePowTypes))
+	for _, powType := range templatePowTypes {
+		bits, err := cache.CalcNextRequiredDifficulty(chain, tip, ts, powType)
+		if err != nil {
+			return nil, miningRuleError(ErrGettingDifficulty, err.Error())
+		}
+		difficulties[powType] = bits
+	}
+	return difficulties, nil
+}
+
+// SupportedPowTypes returns the proof of work types the passed network accepts
+// for blocks at the passed main height, in the order templates carry their
+// difficulties.  The set is given by the proof of work percentages of the
+// network parameters, so it can change with the main height.
+func SupportedPowTypes(params *params.Params, mainHeight int64) []pow.PowType {
+	var powTypes []pow.PowType
+	for _, powType := range templatePowTypes {
+		instance := pow.GetInstance(powType, 0, []byte{})
+		instance.SetMainHeight(mainHeight)
+		instance.SetParams(params.PowConfig)
+		if instance.CheckAvailable() {
+			powTypes = append(powTypes, powType)
+		}
+	}
+	return powTypes
+}
+
+// templatePowType returns the proof of work type of a block template requested
+// with the passed type, which is the default type of the network parameters for
+// DefaultPowType.
+func templatePowType(powType pow.PowType, params *params.Params) pow.PowType {
+	if powType == DefaultPowType {
+		return params.DefaultPowType
+	}
+	return powType
+}
+
+// VerifyBlockPow ensures the proof of work of the passed solved block satisfies
+// the difficulty of its header for the algorithm of its header, as the chain
+// will check it on submission.  The main height the algorithms depend on is
+// taken from the coinbase.  An ErrInvalidPow rule error is returned when the
+// solution isn't valid.
+func VerifyBlockPow(block *types.Block, params *params.Params) error {
+	if len(block.Transactions) == 0 {
+		return miningRuleError(ErrInvalidPow, "block has no coinbase")
+	}
+	mainHeight, err := blockchain.ExtractCoinbaseHeight(block.Transactions[0])
+	if err != nil {
+		return miningRuleError(ErrInvalidPow, err.Error())
+	}
+
+	header := &block.Header
+	powType := header.Pow.GetPowType()
+	instance := pow.GetInstance(powType, 0, []byte{})
+	instance.SetMainHeight(int64(mainHeight))
+	instance.SetParams(params.PowConfig)
+	if !instance.CheckAvailable() {
+		str := fmt.Sprintf("pow type %d is not available at main height "+
+			"%d", powType, mainHeight)
+		return miningRuleError(ErrInvalidPow, str)
+	}
+
+	header.Pow.SetParams(params.PowConfig)
+	header.Pow.SetMainHeight(int64(mainHeight))
+	err = header.Pow.Verify(header.BlockData(), header.BlockHash(),
+		header.Difficulty)
+	if err != nil {
+		str := fmt.Sprintf("invalid %v proof of work: %v",
+			pow.PowMapString[powType], err)
+		return miningRuleError(ErrInvalidPow, str)
+	}
+	return nil
+}
+
 func BlockVersion(net protocol.Network) uint32 {
 	blockVersion := uint32(GeneratedBlockVersion)
 	if net != protocol.MainNet {
@@ -192,12 +698,69 @@ func BlockVersion(net protocol.Network) uint32 {
 	return blockVersion
 }
 
+// cuckooPowDiffData returns the passed pow diff data with the graph parameters
+// of the cuckoo algorithms, which are the edge bits range and the proof size
+// their verification accepts.
+func cuckooPowDiffData(pd types.PowDiffStandard) types.PowDiffStandard {
+	pd.CuckarooMinEdgeBits = pow.MIN_CUCKAROOEDGEBITS
+	pd.CuckarooMaxEdgeBits = pow.MAX_CUCKAROOEDGEBITS
+	pd.CuckatooMinEdgeBits = pow.MIN_CUCKATOOEDGEBITS
+	pd.CuckatooMaxEdgeBits = pow.MAX_CUCKATOOEDGEBITS
+	pd.CuckaroomMinEdgeBits = pow.MIN_CUCKAROOMMEDGEBITS
+	pd.CuckaroomMaxEdgeBits = pow.MAX_CUCKAROOMMEDGEBITS
+	pd.CuckarooProofSize = cuckoo.ProofSize
+	pd.CuckatooProofSize = cuckoo.ProofSize
+	pd.CuckaroomProofSize = cuckoo.ProofSize
+	return pd
+}
+
 func fillWitnessToCoinBase(blockTxns []*types.Tx) error {
+	blockTxns[0].Tx.TxIn[0].PreviousOut.Hash = witnessCommitment(blockTxns)
+	blockTxns[0].RefreshHash()
+	return nil
+}
+
+// witnessCommitment returns the witness commitment of the coinbase, which is
+// the first of the passed transactions, over the witness merkle root of the
+// transactions and the signature script of the coinbase.
+func witnessCommitment(blockTxns []*types.Tx) hash.Hash {
 	merkles := merkle.BuildMerkleTreeStore(blockTxns, true)
 	txWitnessRoot := merkles[len(merkles)-1]
 	witnessPreimage := append(txWitnessRoot.Bytes(), blockTxns[0].Tx.TxIn[0].SignScript...)
-	witnessCommitment := hash.DoubleHashH(witnessPreimage[:])
-	blockTxns[0].Tx.TxIn[0].PreviousOut.Hash = witnessCommitment
-	blockTxns[0].RefreshHash()
+	return hash.DoubleHashH(witnessPreimage[:])
+}
+
+// checkWitnessCommitment ensures the witness commitment of the coinbase, which
+// is the first of the passed transactions, and the coinbase commitment of the
+// passed function, when not nil, match the witness merkle root of the
+// transactions.  It catches the transactions changed after they were
+// committed to.
+func checkWitnessCommitment(blockTxns []*types.Tx, commitment CoinbaseCommitmentFunc) error {
+	coinbaseTx := blockTxns[0]
+	want := witnessCommitment(blockTxns)
+	if !coinbaseTx.Tx.TxIn[0].PreviousOut.Hash.IsEqual(&want) {
+		str := fmt.Sprintf("coinbase witness commitment %v doesn't match "+
+			"the witness merkle root, want %v",
+			coinbaseTx.Tx.TxIn[0].PreviousOut.Hash, want)
+		return miningRuleError(ErrWitnessCommitment, str)
+	}
+	if commitment == nil {
+		return nil
+	}
+
+	data, err := commitment(blockTxns)
+	if err != nil {
+		return miningRuleError(ErrWitnessCommitment, err.Error())
+	}
+	pkScript, err := standardCoinbaseOpReturn(data)
+	if err != nil {
+		return miningRuleError(ErrWitnessCommitment, err.Error())
+	}
+	txOut := coinbaseTx.Tx.TxOut[len(coinbaseTx.Tx.TxOut)-1]
+	if !bytes.Equal(txOut.PkScript, pkScript) {
+		str := fmt.Sprintf("coinbase commitment %x doesn't match the "+
+			"block transactions, want %x", txOut.PkScript, pkScript)
+		return miningRuleError(ErrWitnessCommitment, str)
+	}
 	return nil
 }
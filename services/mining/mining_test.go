@@ -0,0 +1,1896 @@
+package mining
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/common/marshal"
+	"github.com/Qitmeer/qitmeer/core/address"
+	"github.com/Qitmeer/qitmeer/core/blockchain"
+	"github.com/Qitmeer/qitmeer/core/blockdag"
+	"github.com/Qitmeer/qitmeer/core/merkle"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"github.com/Qitmeer/qitmeer/core/types/pow"
+	"github.com/Qitmeer/qitmeer/crypto/ecc"
+	"github.com/Qitmeer/qitmeer/engine/txscript"
+	"github.com/Qitmeer/qitmeer/params"
+	"github.com/Qitmeer/qitmeer/services/mempool"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCoinbaseSubsidy ensures the subsidy which block templates report is
+// what the generated coinbase pays to the miner, and the tax the rest.
+func TestCoinbaseSubsidy(t *testing.T) {
+	tests := []struct {
+		name   string
+		params *params.Params
+	}{
+		{"mainnet", &params.MainNetParams},
+		{"privnet", &params.PrivNetParams},
+	}
+	for _, test := range tests {
+		subsidyCache := blockchain.NewSubsidyCache(0, test.params)
+		for _, blues := range []int64{1, 2, 100} {
+			coinbaseScript, _, err := standardCoinbaseScript(uint64(blues), 0, 0)
+			if err != nil {
+				t.Fatalf("%s: standardCoinbaseScript: %v", test.name, err)
+			}
+			coinbaseTx, err := createCoinbaseTx(subsidyCache, coinbaseScript,
+				nil, blues, nil, nil, test.params)
+			if err != nil {
+				t.Fatalf("%s: createCoinbaseTx: %v", test.name, err)
+			}
+
+			subsidy, tax := calcCoinbaseSubsidy(subsidyCache, blues, test.params)
+			if got := coinbaseTx.Tx.TxOut[0].Amount; got != subsidy {
+				t.Errorf("%s: blues %d: coinbase pays %d, subsidy %d",
+					test.name, blues, got, subsidy)
+			}
+
+			total := uint64(0)
+			for _, txOut := range coinbaseTx.Tx.TxOut {
+				total += txOut.Amount
+			}
+			if total != subsidy+tax {
+				t.Errorf("%s: blues %d: coinbase outputs %d, subsidy+tax %d",
+					test.name, blues, total, subsidy+tax)
+			}
+			if !test.params.HasTax() && tax != 0 {
+				t.Errorf("%s: unexpected tax %d", test.name, tax)
+			}
+		}
+	}
+}
+
+// TestCoinbaseSubsidyWithFees ensures the coinbase of a template whose
+// transactions pay fees still pays the subsidy it reports, with the fees
+// reported apart for the miner to earn Subsidy plus the total fees.
+func TestCoinbaseSubsidyWithFees(t *testing.T) {
+	p := &params.PrivNetParams
+	subsidyCache := blockchain.NewSubsidyCache(0, p)
+	coinbaseScript, _, err := standardCoinbaseScript(1, 0, 0)
+	if err != nil {
+		t.Fatalf("standardCoinbaseScript: %v", err)
+	}
+	coinbaseTx, err := createCoinbaseTx(subsidyCache, coinbaseScript, nil, 1,
+		nil, nil, p)
+	if err != nil {
+		t.Fatalf("createCoinbaseTx: %v", err)
+	}
+	subsidy, tax := calcCoinbaseSubsidy(subsidyCache, 1, p)
+
+	items := make([]*WeightedRandTx, 3)
+	totalFees := int64(0)
+	for i := range items {
+		fee := int64(10000 * (i + 1))
+		items[i] = &WeightedRandTx{tx: newSigOpTestTx(uint32(i), 1), fee: fee}
+		totalFees += fee
+	}
+	selection := newTestSelection(&Policy{BlockMaxSize: 100000}, items)
+	selection.blockTxns = []*types.Tx{coinbaseTx}
+	selection.txFees = []int64{-1}
+	selection.selectTxs()
+	if selection.totalFees != totalFees {
+		t.Fatalf("got total fees %d, want %d", selection.totalFees, totalFees)
+	}
+
+	err = settleCoinbaseFees(coinbaseTx, int64(subsidy+tax),
+		selection.totalFees, selection.txFees)
+	if err != nil {
+		t.Fatalf("settleCoinbaseFees: %v", err)
+	}
+	reported := int64(subsidy)
+	if got := int64(coinbaseTx.Tx.TxOut[0].Amount); got != reported {
+		t.Errorf("coinbase pays %d, reported subsidy %d", got, reported)
+	}
+	if got := reported - selection.txFees[0]; got != int64(subsidy)+totalFees {
+		t.Errorf("got reward %d, want subsidy+fees %d", got,
+			int64(subsidy)+totalFees)
+	}
+
+	// Fees out of range fail the template.
+	err = settleCoinbaseFees(coinbaseTx, int64(subsidy+tax), -1,
+		selection.txFees)
+	if rerr, ok := err.(MiningRuleError); !ok || rerr.ErrorCode != ErrFeesOverflow {
+		t.Errorf("got %v, want ErrFeesOverflow", err)
+	}
+}
+
+// TestFilterExcluded ensures excluded transactions are dropped along with
+// every transaction spending from them, wherever they are in the pool.
+func TestFilterExcluded(t *testing.T) {
+	parent := newSigOpTestTx(0, 1)
+	spendFrom := func(origin *types.Tx) *types.Tx {
+		tx := types.NewTransaction()
+		tx.AddTxIn(&types.TxInput{
+			PreviousOut: *types.NewOutPoint(origin.Hash(), 0),
+			Sequence:    types.MaxTxInSequenceNum,
+		})
+		tx.AddTxOut(&types.TxOutput{Amount: 1})
+		return types.NewTx(tx)
+	}
+	child := spendFrom(parent)
+	grandchild := spendFrom(child)
+	unrelated := newSigOpTestTx(1, 1)
+
+	// Dependents are listed before the excluded transaction.
+	sourceTxns := []*types.TxDesc{{Tx: grandchild}, {Tx: child},
+		{Tx: unrelated}, {Tx: parent}}
+	if got := filterExcluded(sourceTxns, nil); len(got) != len(sourceTxns) {
+		t.Fatalf("nil exclude set filtered %d transactions",
+			len(sourceTxns)-len(got))
+	}
+
+	exclude := map[hash.Hash]struct{}{*parent.Hash(): {}}
+	got := filterExcluded(sourceTxns, exclude)
+	if len(got) != 1 || got[0].Tx != unrelated {
+		t.Fatalf("unexpected filtered transactions %v", got)
+	}
+}
+
+// TestMinAllowedTimestamp ensures template timestamps are clamped to the
+// boundary reported to external miners.
+func TestMinAllowedTimestamp(t *testing.T) {
+	// A chain whose median time past is known.
+	medianTime := time.Unix(1577836800, 0)
+	minTimestamp := minAllowedTimestamp(medianTime)
+	if want := time.Unix(1577836801, 0); !minTimestamp.Equal(want) {
+		t.Fatalf("minAllowedTimestamp: got %v, want %v", minTimestamp, want)
+	}
+
+	tests := []struct {
+		name string
+		now  time.Time
+		want time.Time
+	}{
+		{"before median", medianTime.Add(-time.Minute), minTimestamp},
+		{"at median", medianTime, minTimestamp},
+		{"at boundary", minTimestamp, minTimestamp},
+		{"after boundary", medianTime.Add(time.Minute), medianTime.Add(time.Minute)},
+	}
+	for _, test := range tests {
+		if got := clampTimestamp(test.now, minTimestamp); !got.Equal(test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+// TestMinAllowedTimestampChain ensures the minimum allowed timestamp after a
+// chain of known block times is one second after the median time past the
+// consensus rules compute, and that template timestamps are clamped to it.
+func TestMinAllowedTimestampChain(t *testing.T) {
+	base := int64(1577836800)
+	tests := []struct {
+		name       string
+		timestamps []int64 // From the tip back.
+		want       int64
+	}{
+		// Only the last 11 blocks count, in any order.
+		{"long chain", []int64{base + 600, base + 300, base + 1200,
+			base + 900, base, base + 1500, base + 1800, base + 2100,
+			base + 2400, base + 2700, base + 3000, base + 9999},
+			base + 1501},
+		// The upper middle timestamp of an even number of blocks.
+		{"short chain", []int64{base + 600, base}, base + 601},
+		{"genesis", []int64{base}, base + 1},
+	}
+	for _, test := range tests {
+		medianTime := blockchain.PastMedianTime(test.timestamps)
+		minTimestamp := minAllowedTimestamp(medianTime)
+		if minTimestamp.Unix() != test.want {
+			t.Errorf("%s: got %d, want %d", test.name, minTimestamp.Unix(),
+				test.want)
+		}
+		got := clampTimestamp(time.Unix(base, 0), minTimestamp)
+		if !got.Equal(minTimestamp) {
+			t.Errorf("%s: template timestamp %v, want %v", test.name, got,
+				minTimestamp)
+		}
+	}
+}
+
+// templateTestBlock is a block of the DAGs of the template tests.
+type templateTestBlock struct {
+	hash    hash.Hash
+	parents []uint
+}
+
+func (b *templateTestBlock) GetHash() *hash.Hash { return &b.hash }
+func (b *templateTestBlock) GetParents() []uint  { return b.parents }
+func (b *templateTestBlock) GetTimestamp() int64 { return 0 }
+func (b *templateTestBlock) GetWeight() uint64   { return 1 }
+
+// TestGenesisSuccessorPosition ensures the first template after the genesis
+// is built on the genesis alone at height 1 and order 1, with the genesis as
+// its only blue block, and that the block connects at that position.
+func TestGenesisSuccessorPosition(t *testing.T) {
+	ids := make(map[hash.Hash]uint)
+	bd := &blockdag.BlockDAG{}
+	bd.Init("phantom", func(int64) int64 { return 1 }, -1,
+		func(h *hash.Hash) uint {
+			if id, ok := ids[*h]; ok {
+				return id
+			}
+			return blockdag.MaxId
+		})
+	connect := func(block *templateTestBlock) blockdag.IBlock {
+		_, ib := bd.AddBlock(block)
+		if ib == nil {
+			t.Fatalf("block %v doesn't connect", block.hash)
+		}
+		ids[block.hash] = ib.GetID()
+		return ib
+	}
+	genesis := &templateTestBlock{hash: hash.Hash{0x01}}
+	connect(genesis)
+
+	position, err := newTemplatePosition(bd, nil, 0)
+	if err != nil {
+		t.Fatalf("newTemplatePosition: %v", err)
+	}
+	if len(position.parents) != 1 || *position.parents[0] != genesis.hash {
+		t.Errorf("got parents %v, want the genesis", position.parents)
+	}
+	if len(position.blueSet) != 1 || *position.blueSet[0] != genesis.hash ||
+		len(position.redSet) != 0 {
+		t.Errorf("got blue set %v and red set %v, want the genesis only",
+			position.blueSet, position.redSet)
+	}
+	tests := []struct {
+		name      string
+		got, want uint64
+	}{
+		{"height", position.height, 1},
+		{"order", position.order, 1},
+		{"blues", uint64(position.blues), 1},
+	}
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("got %s %d, want %d", test.name, test.got, test.want)
+		}
+	}
+
+	// The block of the template connects at its position.
+	ib := connect(&templateTestBlock{
+		hash:    hash.Hash{0x02},
+		parents: bd.GetIdSet(position.parents).List(),
+	})
+	if uint64(ib.GetHeight()) != position.height ||
+		uint64(ib.GetOrder()) != position.order ||
+		int64(ib.(*blockdag.PhantomBlock).GetBlueNum()) != position.blues {
+		t.Errorf("block connected at height %d, order %d with %d blues, "+
+			"want %+v", ib.GetHeight(), ib.GetOrder(),
+			ib.(*blockdag.PhantomBlock).GetBlueNum(), position)
+	}
+
+	// The next template is built on it.
+	position, err = newTemplatePosition(bd, nil, 0)
+	if err != nil {
+		t.Fatalf("newTemplatePosition: %v", err)
+	}
+	if position.height != 2 || position.order != 2 || position.blues != 2 {
+		t.Errorf("got next position %+v, want height 2, order 2 and 2 "+
+			"blues", position)
+	}
+
+	// There's no position without parents or on unknown parents.
+	for _, parents := range [][]*hash.Hash{{}, {{0xff}}} {
+		_, err := newTemplatePosition(bd, parents, 0)
+		if rerr, ok := err.(MiningRuleError); !ok ||
+			rerr.ErrorCode != ErrGetTopBlock {
+			t.Errorf("parents %v: got error %v, want %v", parents, err,
+				ErrGetTopBlock)
+		}
+	}
+}
+
+// TestSelectParents ensures the number of parents of a template on a wide DAG
+// is capped while the main chain tip is kept, and the other tips with the
+// best blockdag.ScoreTips scores are chosen.
+func TestSelectParents(t *testing.T) {
+	// The block rate allows many parents, so the DAG keeps all its tips.
+	ids := make(map[hash.Hash]uint)
+	bd := &blockdag.BlockDAG{}
+	bd.Init("phantom", func(int64) int64 { return 1 }, 1,
+		func(h *hash.Hash) uint {
+			if id, ok := ids[*h]; ok {
+				return id
+			}
+			return blockdag.MaxId
+		})
+	connect := func(h hash.Hash, parents ...*hash.Hash) {
+		_, ib := bd.AddBlock(&templateTestBlock{hash: h,
+			parents: bd.GetIdSet(parents).List()})
+		if ib == nil {
+			t.Fatalf("block %v doesn't connect", h)
+		}
+		ids[h] = ib.GetID()
+	}
+
+	// A main chain of 3 blocks after the genesis, 4 tips on the first
+	// block after the genesis, and 4 lower ones on the genesis.
+	genesis := hash.Hash{0x01}
+	connect(genesis)
+	chain := []*hash.Hash{&genesis}
+	for i := 0; i < 3; i++ {
+		h := hash.Hash{0x02, byte(i)}
+		connect(h, chain[i])
+		chain = append(chain, &h)
+	}
+	high := make(map[hash.Hash]bool)
+	for i := 0; i < 4; i++ {
+		h := hash.Hash{0x03, byte(i)}
+		connect(h, chain[1])
+		high[h] = true
+		connect(hash.Hash{0x04, byte(i)}, &genesis)
+	}
+	tips := bd.GetValidTips()
+	if len(tips) != 9 || *tips[0] != *chain[3] {
+		t.Fatalf("got tips %v, want the main chain tip and 8 others", tips)
+	}
+
+	if got := selectParents(bd, tips, 0); len(got) != len(tips) {
+		t.Fatalf("unbounded: got %d parents, want %d", len(got), len(tips))
+	}
+
+	const maxParents = 4
+	position, err := newTemplatePosition(bd, nil, maxParents)
+	if err != nil {
+		t.Fatalf("newTemplatePosition: %v", err)
+	}
+	got := position.parents
+	if len(got) != maxParents {
+		t.Fatalf("got %d parents, want %d", len(got), maxParents)
+	}
+	if *got[0] != *chain[3] {
+		t.Fatalf("main parent %v wasn't kept", chain[3])
+	}
+	scores := blockdag.ScoreTips(bd, tips[1:])
+	for i, h := range got[1:] {
+		if !high[*h] {
+			t.Errorf("parent %d: %v isn't one of the best scored tips",
+				i+1, h)
+		}
+		if *h != *scores[i].Hash {
+			t.Errorf("parent %d: got %v, want %v", i+1, h,
+				scores[i].Hash)
+		}
+	}
+
+	// The selection doesn't depend on the order of the other tips.
+	reordered := []*hash.Hash{tips[0]}
+	for i := len(tips) - 1; i > 0; i-- {
+		reordered = append(reordered, tips[i])
+	}
+	again := selectParents(bd, reordered, maxParents)
+	for i := range got {
+		if *again[i] != *got[i] {
+			t.Errorf("reordered parent %d: got %v, want %v", i, again[i], got[i])
+		}
+	}
+
+	// The block of the template connects at its position.
+	connect(hash.Hash{0x05}, got...)
+	if ib := bd.GetBlock(&hash.Hash{0x05}); uint64(ib.GetHeight()) != position.height {
+		t.Errorf("block connected at height %d, want %d", ib.GetHeight(),
+			position.height)
+	}
+}
+
+// TestVerifyBlockSerialization ensures a block survives the serialization
+// round trip check unless its header commits to other transactions.
+func TestVerifyBlockSerialization(t *testing.T) {
+	txns := []*types.Tx{newSigOpTestTx(0, 1), newSigOpTestTx(1, 2)}
+	parents := []*hash.Hash{{0x01}, {0x02}}
+	merkles := merkle.BuildMerkleTreeStore(txns, false)
+
+	var block types.Block
+	block.Header = types.BlockHeader{
+		ParentRoot: merkle.ComputeParentsRoot(parents),
+		TxRoot:     *merkles[len(merkles)-1],
+		Timestamp:  time.Unix(1577836800, 0),
+		Pow:        pow.GetInstance(pow.BLAKE2BD, 0, []byte{}),
+	}
+	for _, pb := range parents {
+		if err := block.AddParent(pb); err != nil {
+			t.Fatalf("AddParent: %v", err)
+		}
+	}
+	for _, tx := range txns {
+		if err := block.AddTransaction(tx.Transaction()); err != nil {
+			t.Fatalf("AddTransaction: %v", err)
+		}
+	}
+	if err := verifyBlockSerialization(&block); err != nil {
+		t.Fatalf("verifyBlockSerialization: %v", err)
+	}
+
+	block.Header.TxRoot = hash.Hash{0x03}
+	err := verifyBlockSerialization(&block)
+	rerr, ok := err.(MiningRuleError)
+	if !ok || rerr.GetCode() != ErrTemplateSerialization {
+		t.Fatalf("verifyBlockSerialization: got %v, want %v", err,
+			ErrTemplateSerialization)
+	}
+}
+
+// TestCoinbaseExtraNonceSpace ensures the reserved extra nonce bytes are
+// reported at their offset and can be overwritten without breaking the script.
+func TestCoinbaseExtraNonceSpace(t *testing.T) {
+	const height, extraNonceSize = 1000, 8
+	script, offset, err := standardCoinbaseScript(height, 0x0102, extraNonceSize)
+	if err != nil {
+		t.Fatalf("standardCoinbaseScript: %v", err)
+	}
+	if offset+extraNonceSize > len(script) {
+		t.Fatalf("offset %d is past the script of %d bytes", offset, len(script))
+	}
+	if script[offset] != 0x02 || script[offset+1] != 0x01 {
+		t.Fatalf("extra nonce isn't at offset %d: %x", offset, script)
+	}
+
+	for i := offset; i < offset+extraNonceSize; i++ {
+		script[i] = 0xff
+	}
+	pushes, err := txscript.PushedData(script)
+	if err != nil {
+		t.Fatalf("PushedData: %v", err)
+	}
+	if len(pushes) != 3 || len(pushes[1]) != extraNonceSize {
+		t.Fatalf("unexpected pushes %x", pushes)
+	}
+	heightScript, _, err := standardCoinbaseScript(height, 0, 0)
+	if err != nil {
+		t.Fatalf("standardCoinbaseScript: %v", err)
+	}
+	heightPushes, _ := txscript.PushedData(heightScript)
+	if string(pushes[0]) != string(heightPushes[0]) {
+		t.Fatalf("height push changed: got %x, want %x", pushes[0],
+			heightPushes[0])
+	}
+
+	for _, size := range []int{1, txscript.MaxScriptElementSize + 1,
+		blockchain.MaxCoinbaseScriptLen} {
+		_, _, err := standardCoinbaseScript(height, 0, size)
+		if rerr, ok := err.(MiningRuleError); !ok ||
+			rerr.GetCode() != ErrCoinbaseLengthOverflow {
+			t.Errorf("size %d: got %v, want %v", size, err,
+				ErrCoinbaseLengthOverflow)
+		}
+	}
+}
+
+// TestCoinbasePayouts ensures the coinbase subsidy is paid to a single
+// payout, and that several payouts, which could each claim the block fees,
+// and invalid payouts are rejected.
+func TestCoinbasePayouts(t *testing.T) {
+	p := &params.PrivNetParams
+	newAddr := func(b byte) types.Address {
+		hash160 := make([]byte, 20)
+		hash160[0] = b
+		addr, err := address.NewPubKeyHashAddress(hash160, p,
+			ecc.ECDSA_Secp256k1)
+		if err != nil {
+			t.Fatalf("NewPubKeyHashAddress: %v", err)
+		}
+		return addr
+	}
+	pool, founder, miner := newAddr(1), newAddr(2), newAddr(3)
+
+	subsidyCache := blockchain.NewSubsidyCache(0, p)
+	coinbaseScript, _, err := standardCoinbaseScript(1, 0, 0)
+	if err != nil {
+		t.Fatalf("standardCoinbaseScript: %v", err)
+	}
+	coinbaseTx, err := createCoinbaseTx(subsidyCache, coinbaseScript, nil, 3,
+		nil, []CoinbaseOutput{{pool, 1}}, p)
+	if err != nil {
+		t.Fatalf("createCoinbaseTx: %v", err)
+	}
+	subsidy, _ := calcCoinbaseSubsidy(subsidyCache, 3, p)
+	if got := coinbaseTx.Tx.TxOut[0].Amount; got != subsidy {
+		t.Fatalf("payout pays %d, want %d", got, subsidy)
+	}
+
+	payouts := []CoinbaseOutput{{miner, 0.7}, {pool, 0.2}, {founder, 0.1}}
+	_, err = createCoinbaseTx(subsidyCache, coinbaseScript, nil, 3, nil,
+		payouts, p)
+	if rerr, ok := err.(MiningRuleError); !ok ||
+		rerr.GetCode() != ErrInvalidCoinbasePayouts {
+		t.Errorf("several payouts: got %v, want %v", err,
+			ErrInvalidCoinbasePayouts)
+	}
+
+	invalid := [][]CoinbaseOutput{
+		nil,
+		payouts,
+		{{miner, 0.5}, {pool, 0.5}},
+		{{miner, 0.7}},
+		{{miner, 1.2}},
+		{{nil, 1}},
+	}
+	for i, payouts := range invalid {
+		_, err := splitCoinbaseAmount(subsidy, payouts)
+		if rerr, ok := err.(MiningRuleError); !ok ||
+			rerr.GetCode() != ErrInvalidCoinbasePayouts {
+			t.Errorf("invalid payouts %d: got %v, want %v", i, err,
+				ErrInvalidCoinbasePayouts)
+		}
+	}
+}
+
+// TestTemplatePowType ensures templates requested without a proof of work
+// type use the default one of the network.
+func TestTemplatePowType(t *testing.T) {
+	x16rv3Params := params.PrivNetParams
+	x16rv3Params.DefaultPowType = pow.X16RV3
+	tests := []struct {
+		name    string
+		params  *params.Params
+		powType pow.PowType
+		want    pow.PowType
+	}{
+		{"privnet default", &params.PrivNetParams, DefaultPowType, pow.CUCKAROOM},
+		{"x16rv3 default", &x16rv3Params, DefaultPowType, pow.X16RV3},
+		{"mainnet default", &params.MainNetParams, DefaultPowType, pow.CUCKAROO},
+		{"requested", &x16rv3Params, pow.BLAKE2BD, pow.BLAKE2BD},
+	}
+	for _, test := range tests {
+		got := templatePowType(test.powType, test.params)
+		if got != test.want {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+			continue
+		}
+		if instance := pow.GetInstance(got, 0, []byte{}); instance.GetPowType() != test.want {
+			t.Errorf("%s: header pow type %v, want %v", test.name,
+				instance.GetPowType(), test.want)
+		}
+	}
+}
+
+// TestVerifyBlockPow ensures solved blocks are checked against the algorithm
+// and the difficulty of their header.
+func TestVerifyBlockPow(t *testing.T) {
+	p := &params.PrivNetParams
+	subsidyCache := blockchain.NewSubsidyCache(0, p)
+	coinbaseScript, _, err := standardCoinbaseScript(1, 0, 0)
+	if err != nil {
+		t.Fatalf("standardCoinbaseScript: %v", err)
+	}
+	coinbaseTx, err := createCoinbaseTx(subsidyCache, coinbaseScript, nil, 1,
+		nil, nil, p)
+	if err != nil {
+		t.Fatalf("createCoinbaseTx: %v", err)
+	}
+
+	// A target which about one nonce in 65536 satisfies.
+	const difficulty = 0x2000ffff
+	for _, powType := range []pow.PowType{pow.BLAKE2BD, pow.QITMEERKECCAK256} {
+		var block types.Block
+		block.Header = types.BlockHeader{
+			Timestamp:  time.Unix(1577836800, 0),
+			Difficulty: difficulty,
+			Pow:        pow.GetInstance(powType, 0, []byte{}),
+		}
+		if err := block.AddTransaction(coinbaseTx.Tx); err != nil {
+			t.Fatalf("AddTransaction: %v", err)
+		}
+
+		// Solve the block, and keep a nonce which isn't a solution.
+		goodNonce, badNonce := uint32(0), uint32(0)
+		solved, unsolved := false, false
+		for nonce := uint32(0); nonce < 1<<22 && !solved; nonce++ {
+			block.Header.Pow.SetNonce(nonce)
+			if VerifyBlockPow(&block, p) == nil {
+				solved = true
+				goodNonce = nonce
+			} else if !unsolved {
+				unsolved = true
+				badNonce = nonce
+			}
+		}
+		if !solved || !unsolved {
+			t.Fatalf("%v: failed to find a solution", powType)
+		}
+
+		block.Header.Pow.SetNonce(badNonce)
+		err := VerifyBlockPow(&block, p)
+		if rerr, ok := err.(MiningRuleError); !ok || rerr.GetCode() != ErrInvalidPow {
+			t.Errorf("%v: bad solution: got %v, want %v", powType, err,
+				ErrInvalidPow)
+		}
+
+		// The solution doesn't satisfy a harder difficulty.
+		block.Header.Pow.SetNonce(goodNonce)
+		block.Header.Difficulty = 0x1d00ffff
+		if VerifyBlockPow(&block, p) == nil {
+			t.Errorf("%v: solution accepted for a harder difficulty", powType)
+		}
+	}
+}
+
+// retargetTestChain is a chain whose required difficulty depends on the
+// timestamp and the proof of work type.
+type retargetTestChain struct{}
+
+func (c *retargetTestChain) CalcNextRequiredDifficulty(timestamp time.Time, powType pow.PowType) (uint32, error) {
+	return 0x1d00ffff - uint32(timestamp.Unix()%1000) - uint32(powType)<<8, nil
+}
+
+// TestPreviewNextDifficulty ensures previewing the difficulty at the time of
+// the next block gives the difficulty of the template.
+func TestPreviewNextDifficulty(t *testing.T) {
+	chain := &retargetTestChain{}
+	ts := time.Unix(1577836800, 0)
+	difficulties, err := templateDifficulties(chain, nil, &hash.ZeroHash, ts)
+	if err != nil {
+		t.Fatalf("templateDifficulties: %v", err)
+	}
+	for _, powType := range templatePowTypes {
+		bits, target, err := PreviewNextDifficulty(chain, ts, powType)
+		if err != nil {
+			t.Fatalf("PreviewNextDifficulty: %v", err)
+		}
+		if bits != difficulties[powType] {
+			t.Errorf("%v: preview %08x, template %08x", powType, bits,
+				difficulties[powType])
+		}
+		if target.Cmp(pow.CompactToBig(bits)) != 0 {
+			t.Errorf("%v: target %064x doesn't expand %08x", powType,
+				target, bits)
+		}
+
+		later, _, err := PreviewNextDifficulty(chain, ts.Add(time.Hour), powType)
+		if err != nil {
+			t.Fatalf("PreviewNextDifficulty: %v", err)
+		}
+		if later == bits {
+			t.Errorf("%v: preview ignores the timestamp", powType)
+		}
+	}
+}
+
+// TestPersistentExcludes ensures the persistent excludes of the policy apply
+// to consecutive builds along with the exclude set of each build.
+func TestPersistentExcludes(t *testing.T) {
+	persisted := newSigOpTestTx(0, 1)
+	perCall := newSigOpTestTx(1, 1)
+	other := newSigOpTestTx(2, 1)
+	sourceTxns := []*types.TxDesc{{Tx: persisted}, {Tx: perCall}, {Tx: other}}
+	policy := &Policy{
+		PersistentExcludes: map[hash.Hash]struct{}{*persisted.Hash(): {}},
+	}
+
+	// Two consecutive builds, the first one also excluding a transaction.
+	first := filterExcluded(sourceTxns, mergeExcludes(policy.PersistentExcludes,
+		map[hash.Hash]struct{}{*perCall.Hash(): {}}))
+	if len(first) != 1 || first[0].Tx != other {
+		t.Fatalf("first build: unexpected transactions %v", first)
+	}
+	second := filterExcluded(sourceTxns, mergeExcludes(policy.PersistentExcludes, nil))
+	if len(second) != 2 || second[0].Tx != perCall || second[1].Tx != other {
+		t.Fatalf("second build: unexpected transactions %v", second)
+	}
+	if len(policy.PersistentExcludes) != 1 {
+		t.Fatalf("per-call excludes leaked into the policy: %v",
+			policy.PersistentExcludes)
+	}
+
+	// Policy changes are picked up by the next build.
+	delete(policy.PersistentExcludes, *persisted.Hash())
+	third := filterExcluded(sourceTxns, mergeExcludes(policy.PersistentExcludes, nil))
+	if len(third) != len(sourceTxns) {
+		t.Fatalf("third build: unexpected transactions %v", third)
+	}
+}
+
+// TestEmptyTemplateBlock ensures a coinbase only block commits to its single
+// transaction and its parents.
+func TestEmptyTemplateBlock(t *testing.T) {
+	p := &params.PrivNetParams
+	coinbaseScript, _, err := standardCoinbaseScript(1, 0, 0)
+	if err != nil {
+		t.Fatalf("standardCoinbaseScript: %v", err)
+	}
+	coinbaseTx, err := createCoinbaseTx(blockchain.NewSubsidyCache(0, p),
+		coinbaseScript, nil, 1, nil, nil, p)
+	if err != nil {
+		t.Fatalf("createCoinbaseTx: %v", err)
+	}
+	blockTxns := []*types.Tx{coinbaseTx}
+	if err := fillWitnessToCoinBase(blockTxns); err != nil {
+		t.Fatalf("fillWitnessToCoinBase: %v", err)
+	}
+
+	parents := []*hash.Hash{{0x01}, {0x02}}
+	block, err := newTemplateBlock(types.BlockHeader{
+		Timestamp: time.Unix(1577836800, 0),
+		Pow:       pow.GetInstance(pow.BLAKE2BD, 0, []byte{}),
+	}, parents, blockTxns)
+	if err != nil {
+		t.Fatalf("newTemplateBlock: %v", err)
+	}
+	if len(block.Transactions) != 1 || !block.Transactions[0].IsCoinBase() {
+		t.Fatalf("got %d transactions, want the coinbase only",
+			len(block.Transactions))
+	}
+	if want := *coinbaseTx.Hash(); block.Header.TxRoot != want {
+		t.Errorf("tx root %v, want the coinbase hash %v",
+			block.Header.TxRoot, want)
+	}
+	if want := merkle.ComputeParentsRoot(parents); block.Header.ParentRoot != want {
+		t.Errorf("parent root %v, want %v", block.Header.ParentRoot, want)
+	}
+	if err := verifyBlockSerialization(block); err != nil {
+		t.Fatalf("verifyBlockSerialization: %v", err)
+	}
+}
+
+// TestComputeParentsRoot ensures the header of a template block commits to
+// the merkle root of its parents in the order they are passed, which the block
+// keeps.
+func TestComputeParentsRoot(t *testing.T) {
+	parents := []*hash.Hash{{0x03}, {0x01}, {0x02}}
+	block, err := newTemplateBlock(types.BlockHeader{
+		Timestamp: time.Unix(1577836800, 0),
+		Pow:       pow.GetInstance(pow.BLAKE2BD, 0, []byte{}),
+	}, parents, []*types.Tx{newSigOpTestTx(0, 1)})
+	if err != nil {
+		t.Fatalf("newTemplateBlock: %v", err)
+	}
+	if !reflect.DeepEqual(block.Parents, parents) {
+		t.Fatalf("got parents %v, want %v", block.Parents, parents)
+	}
+
+	// root = h(h(p3 + p1) + h(p2 + p2))
+	branch := func(left, right *hash.Hash) hash.Hash {
+		return hash.DoubleHashH(append(left.Bytes(), right.Bytes()...))
+	}
+	left := branch(parents[0], parents[1])
+	right := branch(parents[2], parents[2])
+	want := branch(&left, &right)
+	if got := merkle.ComputeParentsRoot(parents); got != want {
+		t.Errorf("computed root %v, want %v", got, want)
+	}
+	if block.Header.ParentRoot != want {
+		t.Errorf("header root %v, want %v", block.Header.ParentRoot, want)
+	}
+
+	sorted := []*hash.Hash{parents[1], parents[2], parents[0]}
+	if merkle.ComputeParentsRoot(sorted) == want {
+		t.Errorf("root doesn't commit to the order of the parents")
+	}
+	if got := merkle.ComputeParentsRoot(nil); got != hash.ZeroHash {
+		t.Errorf("root of no parents %v, want the zero hash", got)
+	}
+}
+
+// TestCoinbaseCommitment ensures the coinbase commits to the witness merkle
+// root of the transactions of the block in place of the reserved placeholder.
+func TestCoinbaseCommitment(t *testing.T) {
+	p := &params.PrivNetParams
+	placeholder, err := WitnessRootCommitment(nil)
+	if err != nil {
+		t.Fatalf("WitnessRootCommitment: %v", err)
+	}
+	opReturnPkScript, err := standardCoinbaseOpReturn(placeholder)
+	if err != nil {
+		t.Fatalf("standardCoinbaseOpReturn: %v", err)
+	}
+	coinbaseScript, _, err := standardCoinbaseScript(1, 0, 0)
+	if err != nil {
+		t.Fatalf("standardCoinbaseScript: %v", err)
+	}
+	coinbaseTx, err := createCoinbaseTx(blockchain.NewSubsidyCache(0, p),
+		coinbaseScript, opReturnPkScript, 1, nil, nil, p)
+	if err != nil {
+		t.Fatalf("createCoinbaseTx: %v", err)
+	}
+	tx1 := newSigOpTestTx(0, 1)
+	tx2 := newSigOpTestTx(1, 2)
+	blockTxns := []*types.Tx{coinbaseTx, tx1, tx2}
+
+	commitment, err := commitCoinbase(blockTxns, WitnessRootCommitment)
+	if err != nil {
+		t.Fatalf("commitCoinbase: %v", err)
+	}
+
+	// The witness merkle tree of 3 transactions, whose coinbase is zero.
+	branch := func(left, right hash.Hash) hash.Hash {
+		return hash.DoubleHashH(append(left.Bytes(), right.Bytes()...))
+	}
+	want := branch(branch(hash.ZeroHash, tx1.Tx.TxHashFull()),
+		branch(tx2.Tx.TxHashFull(), tx2.Tx.TxHashFull()))
+	if !bytes.Equal(commitment, want.Bytes()) {
+		t.Fatalf("commitment %x, want %x", commitment, want.Bytes())
+	}
+
+	txOuts := coinbaseTx.Tx.TxOut
+	pushes, err := txscript.PushedData(txOuts[len(txOuts)-1].PkScript)
+	if err != nil {
+		t.Fatalf("PushedData: %v", err)
+	}
+	if len(pushes) != 1 || !bytes.Equal(pushes[0], want.Bytes()) {
+		t.Fatalf("coinbase OP_RETURN pushes %x, want %x", pushes,
+			want.Bytes())
+	}
+	if *coinbaseTx.Hash() != coinbaseTx.Tx.TxHash() {
+		t.Error("coinbase hash not refreshed")
+	}
+
+	// A commitment of another length than the placeholder doesn't fit.
+	short := func([]*types.Tx) ([]byte, error) { return []byte{0x01}, nil }
+	if _, err := commitCoinbase(blockTxns, short); err == nil {
+		t.Error("commitment of the wrong length was accepted")
+	}
+}
+
+// TestCheckWitnessCommitment ensures the witness commitments of a template
+// coinbase match its transactions, and that a witness changed after they were
+// committed to is caught.
+func TestCheckWitnessCommitment(t *testing.T) {
+	p := &params.PrivNetParams
+	placeholder, err := WitnessRootCommitment(nil)
+	if err != nil {
+		t.Fatalf("WitnessRootCommitment: %v", err)
+	}
+	opReturnPkScript, err := standardCoinbaseOpReturn(placeholder)
+	if err != nil {
+		t.Fatalf("standardCoinbaseOpReturn: %v", err)
+	}
+	coinbaseScript, _, err := standardCoinbaseScript(1, 0, 0)
+	if err != nil {
+		t.Fatalf("standardCoinbaseScript: %v", err)
+	}
+	coinbaseTx, err := createCoinbaseTx(blockchain.NewSubsidyCache(0, p),
+		coinbaseScript, opReturnPkScript, 1, nil, nil, p)
+	if err != nil {
+		t.Fatalf("createCoinbaseTx: %v", err)
+	}
+
+	// A transaction with 3 signed inputs.
+	spend := types.NewTransaction()
+	for i := uint32(0); i < 3; i++ {
+		spend.AddTxIn(&types.TxInput{
+			PreviousOut: *types.NewOutPoint(&hash.Hash{0x02}, i),
+			SignScript:  []byte{0x01, byte(i)},
+			Sequence:    types.MaxTxInSequenceNum,
+		})
+	}
+	spend.AddTxOut(&types.TxOutput{Amount: 1, PkScript: []byte{txscript.OP_TRUE}})
+	spendTx := types.NewTx(spend)
+	blockTxns := []*types.Tx{coinbaseTx, newSigOpTestTx(0, 1), spendTx}
+
+	if _, err := commitCoinbase(blockTxns, WitnessRootCommitment); err != nil {
+		t.Fatalf("commitCoinbase: %v", err)
+	}
+	if err := fillWitnessToCoinBase(blockTxns); err != nil {
+		t.Fatalf("fillWitnessToCoinBase: %v", err)
+	}
+	if err := checkWitnessCommitment(blockTxns, WitnessRootCommitment); err != nil {
+		t.Fatalf("checkWitnessCommitment: %v", err)
+	}
+
+	// A signature script tampered after the commitments.
+	spend.TxIn[1].SignScript = []byte{0x01, 0xff}
+	spendTx.RefreshHash()
+	for _, commitment := range []CoinbaseCommitmentFunc{nil, WitnessRootCommitment} {
+		err := checkWitnessCommitment(blockTxns, commitment)
+		rerr, ok := err.(MiningRuleError)
+		if !ok || rerr.ErrorCode != ErrWitnessCommitment {
+			t.Errorf("tampered witness: got %v, want ErrWitnessCommitment",
+				err)
+		}
+	}
+
+	// Only the coinbase commitment is stale.
+	if err := fillWitnessToCoinBase(blockTxns); err != nil {
+		t.Fatalf("fillWitnessToCoinBase: %v", err)
+	}
+	if err := checkWitnessCommitment(blockTxns, nil); err != nil {
+		t.Errorf("refilled witness commitment: %v", err)
+	}
+	if err := checkWitnessCommitment(blockTxns, WitnessRootCommitment); err == nil {
+		t.Error("stale coinbase commitment was accepted")
+	}
+}
+
+// TestAddFee ensures the total fees of a template never wrap nor exceed the
+// maximum amount, whatever the fees of its transactions.
+func TestAddFee(t *testing.T) {
+	const maxInt64 = 1<<63 - 1
+	tests := []struct {
+		name  string
+		fees  []int64
+		total int64
+		added int
+	}{
+		{"small fees", []int64{1000, 2000, 3000}, 6000, 3},
+		{"up to max amount", []int64{types.MaxAmount - 1, 1}, types.MaxAmount, 2},
+		{"above max amount", []int64{types.MaxAmount, 1, 5}, types.MaxAmount, 1},
+		{"int64 overflow", []int64{maxInt64 / 2, maxInt64 / 2, 10, 20}, 30, 2},
+		{"max int64", []int64{7, maxInt64}, 7, 1},
+		{"negative fee", []int64{7, -8}, 7, 1},
+	}
+	for _, test := range tests {
+		total := int64(0)
+		added := 0
+		for _, fee := range test.fees {
+			var ok bool
+			if total, ok = addFee(total, fee); ok {
+				added++
+			}
+			if total < 0 || total > types.MaxAmount {
+				t.Fatalf("%s: total fees %d out of range", test.name, total)
+			}
+		}
+		if total != test.total || added != test.added {
+			t.Errorf("%s: got total %d from %d fees, want %d from %d",
+				test.name, total, added, test.total, test.added)
+		}
+	}
+}
+
+// mockCoinbaseBuilder is a coinbase builder paying the subsidy to a script
+// the node knows nothing about, shifted by skew atoms.
+type mockCoinbaseBuilder struct {
+	skew       int64
+	calls      int
+	height     int64
+	subsidy    int64
+	extraNonce uint64
+	coinbase   *types.Tx
+}
+
+func (b *mockCoinbaseBuilder) BuildCoinbase(height, subsidy int64, extraNonce uint64) (*types.Tx, error) {
+	b.calls++
+	b.height, b.subsidy, b.extraNonce = height, subsidy, extraNonce
+	coinbaseScript, _, err := standardCoinbaseScript(uint64(height), extraNonce, 0)
+	if err != nil {
+		return nil, err
+	}
+	tx := types.NewTransaction()
+	tx.AddTxIn(&types.TxInput{
+		PreviousOut: *types.NewOutPoint(&hash.Hash{}, types.MaxPrevOutIndex),
+		Sequence:    types.MaxTxInSequenceNum,
+		SignScript:  coinbaseScript,
+	})
+	tx.AddTxOut(&types.TxOutput{
+		Amount:   uint64(subsidy + b.skew),
+		PkScript: []byte{txscript.OP_TRUE},
+	})
+	b.coinbase = types.NewTx(tx)
+	return b.coinbase, nil
+}
+
+// TestCoinbaseBuilder ensures the coinbase builder of the policy builds the
+// coinbase of templates, and that its coinbase must pay the subsidy.
+func TestCoinbaseBuilder(t *testing.T) {
+	p := &params.PrivNetParams
+	subsidyCache := blockchain.NewSubsidyCache(0, p)
+	subsidy, tax := calcCoinbaseSubsidy(subsidyCache, 1, p)
+	std := &standardCoinbaseBuilder{
+		subsidyCache: subsidyCache,
+		blues:        1,
+		params:       p,
+	}
+
+	// Without a builder, the standard coinbase is used.
+	coinbaseTx, err := templateCoinbase(&Policy{}, std, 5, int64(subsidy+tax), 9)
+	if err != nil {
+		t.Fatalf("templateCoinbase: %v", err)
+	}
+	if err := checkCoinbaseAmount(coinbaseTx, int64(subsidy+tax)); err != nil {
+		t.Errorf("standard coinbase: %v", err)
+	}
+
+	// The standard builder isn't used along with the builder of the
+	// policy, so the template reserves no extra nonce space.
+	builder := &mockCoinbaseBuilder{}
+	policy := &Policy{CoinbaseBuilder: builder}
+	std = &standardCoinbaseBuilder{
+		subsidyCache:   subsidyCache,
+		blues:          1,
+		params:         p,
+		extraNonceSize: 8,
+	}
+	coinbaseTx, err = templateCoinbase(policy, std, 5, int64(subsidy+tax), 9)
+	if err != nil {
+		t.Fatalf("templateCoinbase: %v", err)
+	}
+	if builder.calls != 1 || coinbaseTx != builder.coinbase {
+		t.Fatalf("builder called %d times, coinbase used: %v", builder.calls,
+			coinbaseTx == builder.coinbase)
+	}
+	if builder.height != 5 || builder.subsidy != int64(subsidy+tax) ||
+		builder.extraNonce != 9 {
+		t.Errorf("builder called with height %d subsidy %d extra nonce %d",
+			builder.height, builder.subsidy, builder.extraNonce)
+	}
+	if err := checkCoinbaseAmount(coinbaseTx, int64(subsidy+tax)); err != nil {
+		t.Errorf("builder coinbase: %v", err)
+	}
+	if std.extraNonceOffset != 0 || std.reservedExtraNonceSize != 0 {
+		t.Errorf("got extra nonce offset %d size %d, want none",
+			std.extraNonceOffset, std.reservedExtraNonceSize)
+	}
+
+	// A coinbase paying more than the subsidy is refused.
+	builder.skew = 1
+	coinbaseTx, err = templateCoinbase(policy, std, 5, int64(subsidy+tax), 9)
+	if err != nil {
+		t.Fatalf("templateCoinbase: %v", err)
+	}
+	err = checkCoinbaseAmount(coinbaseTx, int64(subsidy+tax))
+	if rerr, ok := err.(MiningRuleError); !ok || rerr.ErrorCode != ErrCoinbaseAmount {
+		t.Errorf("overpaying coinbase: got %v, want ErrCoinbaseAmount", err)
+	}
+}
+
+// fixedCoinbaseBuilder is a CoinbaseBuilder returning the same coinbase.
+type fixedCoinbaseBuilder struct {
+	coinbase *types.Tx
+}
+
+func (b *fixedCoinbaseBuilder) BuildCoinbase(height, subsidy int64, extraNonce uint64) (*types.Tx, error) {
+	return b.coinbase, nil
+}
+
+// TestOmitCoinbaseOpReturn ensures the coinbase of templates has the OP_RETURN
+// output of the commitment unless the policy omits it, which can't be combined
+// with a commitment nor with a coinbase builder adding one.
+func TestOmitCoinbaseOpReturn(t *testing.T) {
+	p := &params.PrivNetParams
+	subsidyCache := blockchain.NewSubsidyCache(0, p)
+	subsidy, tax := calcCoinbaseSubsidy(subsidyCache, 1, p)
+	buildCoinbase := func(policy *Policy) (*types.Tx, error) {
+		opReturnPkScript, err := coinbaseOpReturn(policy)
+		if err != nil {
+			return nil, err
+		}
+		std := &standardCoinbaseBuilder{
+			subsidyCache:     subsidyCache,
+			opReturnPkScript: opReturnPkScript,
+			blues:            1,
+			params:           p,
+		}
+		return templateCoinbase(policy, std, 1, int64(subsidy+tax), 0)
+	}
+	nullData := func(tx *types.Tx) int {
+		count := 0
+		for _, txOut := range tx.Tx.TxOut {
+			if txscript.GetScriptClass(txscript.DefaultScriptVersion,
+				txOut.PkScript) == txscript.NullDataTy {
+				count++
+			}
+		}
+		return count
+	}
+
+	with, err := buildCoinbase(&Policy{CoinbaseCommitment: WitnessRootCommitment})
+	if err != nil {
+		t.Fatalf("coinbase with OP_RETURN: %v", err)
+	}
+	without, err := buildCoinbase(&Policy{OmitCoinbaseOpReturn: true})
+	if err != nil {
+		t.Fatalf("coinbase without OP_RETURN: %v", err)
+	}
+	if nullData(with) != 1 || nullData(without) != 0 {
+		t.Errorf("got %d and %d OP_RETURN outputs, want 1 and 0",
+			nullData(with), nullData(without))
+	}
+	if len(without.Tx.TxOut) != len(with.Tx.TxOut)-1 {
+		t.Errorf("got %d outputs without OP_RETURN, want %d",
+			len(without.Tx.TxOut), len(with.Tx.TxOut)-1)
+	}
+	if without.Tx.SerializeSize() >= with.Tx.SerializeSize() {
+		t.Errorf("coinbase without OP_RETURN isn't smaller: %d bytes, "+
+			"%d with", without.Tx.SerializeSize(), with.Tx.SerializeSize())
+	}
+	if err := checkCoinbaseAmount(without, int64(subsidy+tax)); err != nil {
+		t.Errorf("coinbase without OP_RETURN: %v", err)
+	}
+
+	// The commitment needs the OP_RETURN output.
+	_, err = buildCoinbase(&Policy{CoinbaseCommitment: WitnessRootCommitment,
+		OmitCoinbaseOpReturn: true})
+	if rerr, ok := err.(MiningRuleError); !ok || rerr.ErrorCode != ErrCreatingCoinbase {
+		t.Errorf("commitment without OP_RETURN: got %v, want "+
+			"ErrCreatingCoinbase", err)
+	}
+
+	// So does the coinbase of a builder adding one.
+	_, err = buildCoinbase(&Policy{CoinbaseBuilder: &fixedCoinbaseBuilder{with},
+		OmitCoinbaseOpReturn: true})
+	if rerr, ok := err.(MiningRuleError); !ok || rerr.ErrorCode != ErrCreatingCoinbase {
+		t.Errorf("builder coinbase with OP_RETURN: got %v, want "+
+			"ErrCreatingCoinbase", err)
+	}
+}
+
+// TestSupportedPowTypes ensures the proof of work types of each network are
+// the ones its parameters give a share of the blocks to.
+func TestSupportedPowTypes(t *testing.T) {
+	tests := []struct {
+		name       string
+		params     *params.Params
+		mainHeight int64
+		want       []pow.PowType
+	}{
+		{"mainnet", &params.MainNetParams, 0, []pow.PowType{pow.BLAKE2BD,
+			pow.QITMEERKECCAK256, pow.CUCKAROO, pow.CUCKATOO}},
+		{"testnet", &params.TestNetParams, 0, []pow.PowType{
+			pow.QITMEERKECCAK256, pow.CUCKAROOM}},
+		{"mixnet", &params.MixNetParams, 0, []pow.PowType{pow.BLAKE2BD,
+			pow.QITMEERKECCAK256, pow.CUCKAROO, pow.CUCKATOO}},
+		{"privnet", &params.PrivNetParams, 0, []pow.PowType{pow.BLAKE2BD,
+			pow.X16RV3, pow.X8R16, pow.QITMEERKECCAK256, pow.CUCKAROO,
+			pow.CUCKAROOM, pow.CUCKATOO}},
+		{"privnet height 50", &params.PrivNetParams, 50, []pow.PowType{
+			pow.X16RV3, pow.QITMEERKECCAK256, pow.CUCKAROO,
+			pow.CUCKAROOM}},
+		{"privnet height 100", &params.PrivNetParams, 100, []pow.PowType{
+			pow.QITMEERKECCAK256, pow.CUCKAROOM}},
+	}
+	for _, test := range tests {
+		got := SupportedPowTypes(test.params, test.mainHeight)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+// TestRegtestMode ensures the regtest mode drops the coinbase maturity of the
+// inputs checks only, and is refused on mainnet.
+func TestRegtestMode(t *testing.T) {
+	p := &params.PrivNetParams
+	inputParams, err := templateInputParams(&Policy{}, p)
+	if err != nil || inputParams != p {
+		t.Fatalf("templateInputParams: got %v, %v, want the network "+
+			"parameters", inputParams, err)
+	}
+
+	inputParams, err = templateInputParams(&Policy{RegtestMode: true}, p)
+	if err != nil {
+		t.Fatalf("templateInputParams: %v", err)
+	}
+	if inputParams.CoinbaseMaturity != 0 {
+		t.Errorf("regtest coinbase maturity %d, want 0",
+			inputParams.CoinbaseMaturity)
+	}
+	if p.CoinbaseMaturity == 0 {
+		t.Error("regtest mode changed the network parameters")
+	}
+
+	_, err = templateInputParams(&Policy{RegtestMode: true}, &params.MainNetParams)
+	if rerr, ok := err.(MiningRuleError); !ok || rerr.ErrorCode != ErrRegtestMode {
+		t.Errorf("mainnet regtest mode: got %v, want ErrRegtestMode", err)
+	}
+}
+
+// TestCuckooPowDiffData ensures the cuckoo graph parameters of the pow diff
+// data are the ones the verification of each algorithm accepts.
+func TestCuckooPowDiffData(t *testing.T) {
+	pd := cuckooPowDiffData(types.PowDiffStandard{CuckarooBaseDiff: 1})
+	if pd.CuckarooBaseDiff != 1 {
+		t.Fatal("cuckooPowDiffData changed the base difficulty")
+	}
+	tests := []struct {
+		powType          pow.PowType
+		minBits, maxBits uint8
+		proofSize        int
+	}{
+		{pow.CUCKAROO, pd.CuckarooMinEdgeBits, pd.CuckarooMaxEdgeBits, pd.CuckarooProofSize},
+		{pow.CUCKATOO, pd.CuckatooMinEdgeBits, pd.CuckatooMaxEdgeBits, pd.CuckatooProofSize},
+		{pow.CUCKAROOM, pd.CuckaroomMinEdgeBits, pd.CuckaroomMaxEdgeBits, pd.CuckaroomProofSize},
+	}
+	header := make([]byte, 2*pow.PROOF_DATA_CIRCLE_NONCE_END)
+	for _, test := range tests {
+		verify := func(edgeBits uint8) error {
+			instance := pow.GetInstance(test.powType, 0, []byte{})
+			instance.(interface{ SetEdgeBits(uint8) }).SetEdgeBits(edgeBits)
+			return instance.Verify(header, hash.Hash{}, 0)
+		}
+		isEdgeBitsErr := func(err error) bool {
+			return err != nil && strings.Contains(err.Error(), "edge bits")
+		}
+		if !isEdgeBitsErr(verify(test.minBits - 1)) {
+			t.Errorf("%v: edge bits %d below the minimum accepted",
+				test.powType, test.minBits-1)
+		}
+		if !isEdgeBitsErr(verify(test.maxBits + 1)) {
+			t.Errorf("%v: edge bits %d above the maximum accepted",
+				test.powType, test.maxBits+1)
+		}
+		for _, edgeBits := range []uint8{test.minBits, test.maxBits} {
+			if isEdgeBitsErr(verify(edgeBits)) {
+				t.Errorf("%v: edge bits %d rejected", test.powType,
+					edgeBits)
+			}
+		}
+		var proof pow.Cuckoo
+		if want := len(proof.GetCircleNonces()); test.proofSize != want {
+			t.Errorf("%v: proof size %d, want %d", test.powType,
+				test.proofSize, want)
+		}
+	}
+}
+
+// TestMaxPackageSize ensures a chain of dependent transactions is truncated at
+// the maximum package size of the policy, and left whole when it is zero.
+func TestMaxPackageSize(t *testing.T) {
+	// A chain of 6 transactions, each depending on the previous one.
+	const chainLen = 6
+	newChain := func() ([]*WeightedRandTx, map[hash.Hash]map[hash.Hash]*WeightedRandTx) {
+		items := make([]*WeightedRandTx, chainLen)
+		dependers := make(map[hash.Hash]map[hash.Hash]*WeightedRandTx)
+		for i := range items {
+			items[i] = &WeightedRandTx{tx: newSigOpTestTx(uint32(i), 1)}
+			if i == 0 {
+				continue
+			}
+			parent := *items[i-1].tx.Hash()
+			items[i].dependsOn = map[hash.Hash]struct{}{parent: {}}
+			dependers[parent] = map[hash.Hash]*WeightedRandTx{
+				*items[i].tx.Hash(): items[i],
+			}
+		}
+		return items, dependers
+	}
+
+	for _, maxPackageSize := range []int{0, 1, 3, chainLen, chainLen + 1} {
+		policy := &Policy{MaxPackageSize: maxPackageSize}
+		items, dependers := newChain()
+		queue := newWeightedRandQueue(chainLen)
+		queue.Push(items[0])
+		var selected []*WeightedRandTx
+		for queue.Len() > 0 {
+			item := queue.Pop()
+			selected = append(selected, item)
+			pushDependers(policy, item, dependers[*item.tx.Hash()],
+				dependers, queue)
+		}
+
+		want := chainLen
+		if maxPackageSize > 0 && maxPackageSize < chainLen {
+			want = maxPackageSize
+		}
+		if len(selected) != want {
+			t.Errorf("max package size %d: selected %d transactions, "+
+				"want %d", maxPackageSize, len(selected), want)
+			continue
+		}
+		for i, item := range selected {
+			if item != items[i] || item.depth != i {
+				t.Errorf("max package size %d: transaction %d out of "+
+					"order or at depth %d", maxPackageSize, i,
+					item.depth)
+			}
+		}
+	}
+}
+
+// TestCyclicDependers ensures the selection of a source pool with a dependency
+// cycle terminates without the cyclic transactions, which are detected apart
+// from the ones depending on a skipped transaction.
+func TestCyclicDependers(t *testing.T) {
+	// a and b depend on each other, c depends on a, e on the ready d, and
+	// g on f, which is skipped.
+	items := make([]*WeightedRandTx, 7)
+	for i := range items {
+		items[i] = &WeightedRandTx{tx: newSigOpTestTx(uint32(i), 1)}
+	}
+	a, b, c, d, e, f, g := items[0], items[1], items[2], items[3], items[4],
+		items[5], items[6]
+	dependers := make(map[hash.Hash]map[hash.Hash]*WeightedRandTx)
+	depend := func(item, on *WeightedRandTx) {
+		if item.dependsOn == nil {
+			item.dependsOn = make(map[hash.Hash]struct{})
+		}
+		item.dependsOn[*on.tx.Hash()] = struct{}{}
+		deps, ok := dependers[*on.tx.Hash()]
+		if !ok {
+			deps = make(map[hash.Hash]*WeightedRandTx)
+			dependers[*on.tx.Hash()] = deps
+		}
+		deps[*item.tx.Hash()] = item
+	}
+	depend(a, b)
+	depend(b, a)
+	depend(c, a)
+	depend(e, d)
+	depend(g, f)
+
+	queue := newWeightedRandQueue(len(items))
+	queue.Push(d)
+	queue.Push(f)
+	selected := make(map[*WeightedRandTx]struct{})
+	for queue.Len() > 0 {
+		item := queue.Pop()
+		if item == f {
+			continue
+		}
+		selected[item] = struct{}{}
+		pushDependers(&Policy{}, item, dependers[*item.tx.Hash()],
+			dependers, queue)
+	}
+	if len(selected) != 2 {
+		t.Errorf("selected %d transactions, want d and e", len(selected))
+	}
+	for _, item := range []*WeightedRandTx{d, e} {
+		if _, ok := selected[item]; !ok {
+			t.Errorf("ready transaction %v not selected", item.tx.Hash())
+		}
+	}
+
+	got := make(map[hash.Hash]struct{})
+	for _, tx := range cyclicDependers(dependers) {
+		got[*tx.Hash()] = struct{}{}
+	}
+	want := map[hash.Hash]struct{}{
+		*a.tx.Hash(): {},
+		*b.tx.Hash(): {},
+		*c.tx.Hash(): {},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got cyclic transactions %v, want a, b and c", got)
+	}
+}
+
+// csvTestChain computes the sequence locks of transactions from the utxo view
+// as blockchain.CalcSequenceLock does, for the outputs of blocks of known
+// heights and past median times.
+type csvTestChain struct {
+	heights     map[hash.Hash]int64
+	medianTimes map[hash.Hash]time.Time
+}
+
+func (c *csvTestChain) CalcSequenceLock(tx *types.Tx, view *blockchain.UtxoViewpoint) (*blockchain.SequenceLock, error) {
+	lock := &blockchain.SequenceLock{BlockHeight: -1, Time: -1}
+	if tx.Tx.Version < 2 {
+		return lock, nil
+	}
+	for _, txIn := range tx.Tx.TxIn {
+		if txIn.Sequence&types.SequenceLockTimeDisabled != 0 {
+			continue
+		}
+		entry := view.LookupEntry(txIn.PreviousOut)
+		if entry == nil {
+			return nil, fmt.Errorf("missing output %v", txIn.PreviousOut)
+		}
+		relativeLock := int64(txIn.Sequence & types.SequenceLockTimeMask)
+		if txIn.Sequence&types.SequenceLockTimeIsSeconds != 0 {
+			minTime := c.medianTimes[*entry.BlockHash()].Unix() +
+				relativeLock<<types.SequenceLockTimeGranularity - 1
+			if minTime > lock.Time {
+				lock.Time = minTime
+			}
+			continue
+		}
+		minHeight := c.heights[*entry.BlockHash()] + relativeLock - 1
+		if minHeight > lock.BlockHeight {
+			lock.BlockHeight = minHeight
+		}
+	}
+	return lock, nil
+}
+
+// TestSequenceLocks ensures a transaction whose inputs are locked by relative
+// lock times is skipped until the lock times of all of them are met.
+func TestSequenceLocks(t *testing.T) {
+	// The outputs spent by the transaction, in blocks at heights 100 and
+	// 105, and a block whose past median time is medianTime.
+	medianTime := time.Unix(1577836800, 0)
+	blocks := []hash.Hash{{0x01}, {0x02}, {0x03}}
+	chain := &csvTestChain{
+		heights:     map[hash.Hash]int64{blocks[0]: 100, blocks[1]: 105},
+		medianTimes: map[hash.Hash]time.Time{blocks[2]: medianTime},
+	}
+	view := blockchain.NewUtxoViewpoint()
+	tx := types.NewTransaction()
+	tx.Version = 2
+	locks := []struct {
+		isSeconds bool
+		lockTime  uint32
+	}{{false, 10}, {false, 3}, {true, 1024}}
+	for i, lock := range locks {
+		funding := newSigOpTestTx(uint32(i), 1)
+		view.AddTxOuts(funding, &blocks[i])
+		sequence, err := blockchain.LockTimeToSequence(lock.isSeconds,
+			lock.lockTime)
+		if err != nil {
+			t.Fatalf("LockTimeToSequence: %v", err)
+		}
+		tx.AddTxIn(&types.TxInput{
+			PreviousOut: *types.NewOutPoint(funding.Hash(), 0),
+			Sequence:    sequence,
+		})
+	}
+	spend := types.NewTx(tx)
+
+	// The height locks are met from heights 110 and 108, and the time lock
+	// 1024 seconds after medianTime.
+	tests := []struct {
+		height     uint64
+		medianTime time.Time
+		mature     bool
+	}{
+		{100, medianTime.Add(time.Hour), false},
+		{108, medianTime.Add(time.Hour), false},
+		{109, medianTime.Add(time.Hour), false},
+		{110, medianTime.Add(time.Hour), true},
+		{110, medianTime.Add(1023 * time.Second), false},
+		{110, medianTime.Add(1024 * time.Second), true},
+		{200, medianTime.Add(time.Hour), true},
+	}
+	for _, test := range tests {
+		err := checkSequenceLocks(chain, spend, view, test.height,
+			test.medianTime)
+		if test.mature {
+			if err != nil {
+				t.Errorf("height %d: mature tx skipped: %v", test.height,
+					err)
+			}
+			continue
+		}
+		if rerr, ok := err.(MiningRuleError); !ok || rerr.ErrorCode != ErrSequenceLocks {
+			t.Errorf("height %d, time %v: got %v, want ErrSequenceLocks",
+				test.height, test.medianTime, err)
+		}
+	}
+
+	// Inputs with disabled relative lock times are never locked.
+	for _, txIn := range tx.TxIn {
+		txIn.Sequence = types.MaxTxInSequenceNum
+	}
+	err := checkSequenceLocks(chain, types.NewTx(tx), view, 100, medianTime)
+	if err != nil {
+		t.Errorf("tx without relative lock time skipped: %v", err)
+	}
+
+	// A missing input is an error of the chain.
+	tx.TxIn[0].Sequence = 0
+	tx.TxIn[0].PreviousOut = *types.NewOutPoint(&hash.Hash{0x04}, 0)
+	err = checkSequenceLocks(chain, types.NewTx(tx), view, 200, medianTime)
+	if _, ok := err.(MiningRuleError); ok || err == nil {
+		t.Errorf("missing input: got %v, want the chain error", err)
+	}
+}
+
+// TestTemplatePrevOuts ensures every input of the transactions of a template
+// other than the coinbase has the output it spends in the prevouts, including
+// the outputs of the transactions of the block itself.
+func TestTemplatePrevOuts(t *testing.T) {
+	p := &params.PrivNetParams
+	coinbaseScript, _, err := standardCoinbaseScript(1, 0, 0)
+	if err != nil {
+		t.Fatalf("standardCoinbaseScript: %v", err)
+	}
+	coinbaseTx, err := createCoinbaseTx(blockchain.NewSubsidyCache(0, p),
+		coinbaseScript, nil, 1, nil, nil, p)
+	if err != nil {
+		t.Fatalf("createCoinbaseTx: %v", err)
+	}
+	funding := newSigOpTestTx(0, 2)
+	parent := types.NewTransaction()
+	for i := uint32(0); i < 2; i++ {
+		parent.AddTxIn(&types.TxInput{
+			PreviousOut: *types.NewOutPoint(funding.Hash(), i),
+			Sequence:    types.MaxTxInSequenceNum,
+		})
+	}
+	parent.AddTxOut(&types.TxOutput{Amount: 2, PkScript: []byte{txscript.OP_TRUE}})
+	parentTx := types.NewTx(parent)
+	child := types.NewTransaction()
+	child.AddTxIn(&types.TxInput{
+		PreviousOut: *types.NewOutPoint(parentTx.Hash(), 0),
+		Sequence:    types.MaxTxInSequenceNum,
+	})
+	child.AddTxOut(&types.TxOutput{Amount: 1, PkScript: []byte{txscript.OP_TRUE}})
+	childTx := types.NewTx(child)
+
+	// The view is spent by the block as the builder does.
+	view := blockchain.NewUtxoViewpoint()
+	view.AddTxOuts(funding, &hash.ZeroHash)
+	blockTxns := []*types.Tx{coinbaseTx, parentTx, childTx}
+	for _, tx := range blockTxns[1:] {
+		if err := spendTransaction(view, tx, &hash.ZeroHash); err != nil {
+			t.Fatalf("spendTransaction: %v", err)
+		}
+	}
+
+	prevOuts, err := templatePrevOuts(blockTxns, view)
+	if err != nil {
+		t.Fatalf("templatePrevOuts: %v", err)
+	}
+	if len(prevOuts) != 3 {
+		t.Errorf("got %d prevouts, want 3", len(prevOuts))
+	}
+	for _, tx := range blockTxns[1:] {
+		for _, txIn := range tx.Tx.TxIn {
+			prevOut, ok := prevOuts[txIn.PreviousOut]
+			if !ok {
+				t.Errorf("no prevout for input %v", txIn.PreviousOut)
+				continue
+			}
+			var spent *types.TxOutput
+			for _, origin := range []*types.Tx{funding, parentTx} {
+				if *origin.Hash() == txIn.PreviousOut.Hash {
+					spent = origin.Tx.TxOut[txIn.PreviousOut.OutIndex]
+				}
+			}
+			if prevOut.Amount != spent.Amount ||
+				!bytes.Equal(prevOut.PkScript, spent.PkScript) {
+				t.Errorf("input %v: got prevout %v, want %v",
+					txIn.PreviousOut, prevOut, spent)
+			}
+		}
+	}
+
+	// An input missing from the view fails the template.
+	orphan := newSigOpTestTx(5, 1)
+	_, err = templatePrevOuts([]*types.Tx{coinbaseTx, orphan}, view)
+	if rerr, ok := err.(MiningRuleError); !ok || rerr.GetCode() != ErrFetchTxStore {
+		t.Errorf("got %v, want %v", err, ErrFetchTxStore)
+	}
+}
+
+// TestCoinbaseAddressTypes ensures the coinbase pays to every type of address
+// with the script of its type, for the same amounts, and that the addresses of
+// other networks are rejected.
+func TestCoinbaseAddressTypes(t *testing.T) {
+	p := &params.PrivNetParams
+	hash160 := bytes.Repeat([]byte{0x01}, 20)
+	newPubKeyHash := func(p *params.Params, ecType ecc.EcType) types.Address {
+		addr, err := address.NewPubKeyHashAddress(hash160, p, ecType)
+		if err != nil {
+			t.Fatalf("NewPubKeyHashAddress: %v", err)
+		}
+		return addr
+	}
+	scriptHash, err := address.NewAddressScriptHashFromHash(hash160, p)
+	if err != nil {
+		t.Fatalf("NewAddressScriptHashFromHash: %v", err)
+	}
+	// The generator point of secp256k1.
+	pubKey, _ := hex.DecodeString("0279be667ef9dcbbac55a06295ce870b07029b" +
+		"fcdb2dce28d959f2815b16f81798")
+	secpPubKey, err := address.NewSecpPubKeyAddress(pubKey, p)
+	if err != nil {
+		t.Fatalf("NewSecpPubKeyAddress: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		addr  types.Address
+		class txscript.ScriptClass
+	}{
+		{"p2pkh", newPubKeyHash(p, ecc.ECDSA_Secp256k1), txscript.PubKeyHashTy},
+		{"ed25519 p2pkh", newPubKeyHash(p, ecc.EdDSA_Ed25519),
+			txscript.PubkeyHashAltTy},
+		{"schnorr p2pkh", newPubKeyHash(p, ecc.ECDSA_SecpSchnorr),
+			txscript.PubkeyHashAltTy},
+		{"p2sh", scriptHash, txscript.ScriptHashTy},
+		{"p2pk", secpPubKey, txscript.PubKeyTy},
+	}
+	subsidyCache := blockchain.NewSubsidyCache(0, p)
+	coinbaseScript, _, err := standardCoinbaseScript(1, 0, 0)
+	if err != nil {
+		t.Fatalf("standardCoinbaseScript: %v", err)
+	}
+	anyone, err := createCoinbaseTx(subsidyCache, coinbaseScript, nil, 2,
+		nil, nil, p)
+	if err != nil {
+		t.Fatalf("createCoinbaseTx: %v", err)
+	}
+	for _, test := range tests {
+		for _, payouts := range [][]CoinbaseOutput{nil, {{test.addr, 1}}} {
+			addr := test.addr
+			if payouts != nil {
+				addr = nil
+			}
+			coinbaseTx, err := createCoinbaseTx(subsidyCache,
+				coinbaseScript, nil, 2, addr, payouts, p)
+			if err != nil {
+				t.Fatalf("%s: createCoinbaseTx: %v", test.name, err)
+			}
+			txOut := coinbaseTx.Tx.TxOut[0]
+			script := marshal.ClassifyScript(txOut.PkScript, p)
+			if script.Type != test.class.String() {
+				t.Errorf("%s: got script of type %s, want %s",
+					test.name, script.Type, test.class)
+			}
+			if txOut.Amount != anyone.Tx.TxOut[0].Amount ||
+				len(coinbaseTx.Tx.TxOut) != len(anyone.Tx.TxOut) {
+				t.Errorf("%s: coinbase pays %d in %d outputs, want "+
+					"%d in %d", test.name, txOut.Amount,
+					len(coinbaseTx.Tx.TxOut), anyone.Tx.TxOut[0].Amount,
+					len(anyone.Tx.TxOut))
+			}
+		}
+	}
+
+	// The addresses of other networks are rejected.
+	mainNetAddr := newPubKeyHash(&params.MainNetParams, ecc.ECDSA_Secp256k1)
+	_, err = createCoinbaseTx(subsidyCache, coinbaseScript, nil, 2,
+		mainNetAddr, nil, p)
+	if rerr, ok := err.(MiningRuleError); !ok ||
+		rerr.GetCode() != ErrCreatingCoinbase {
+		t.Errorf("got %v, want %v", err, ErrCreatingCoinbase)
+	}
+}
+
+// TestMaxBlockSigOps ensures the transactions which would exceed the sigop cap
+// of the policy are skipped for it, although the consensus max isn't hit, and
+// that the cap can't exceed the consensus max.
+func TestMaxBlockSigOps(t *testing.T) {
+	for _, test := range []struct {
+		max  int64
+		want int64
+		ok   bool
+	}{
+		{0, blockchain.MaxSigOpsPerBlock, true},
+		{5, 5, true},
+		{blockchain.MaxSigOpsPerBlock, blockchain.MaxSigOpsPerBlock, true},
+		{blockchain.MaxSigOpsPerBlock + 1, 0, false},
+		{-1, 0, false},
+	} {
+		got, err := templateMaxSigOps(&Policy{MaxBlockSigOps: test.max})
+		if !test.ok {
+			if rerr, ok := err.(MiningRuleError); !ok ||
+				rerr.ErrorCode != ErrInvalidMaxBlockSigOps {
+				t.Errorf("max %d: got error %v, want %v", test.max,
+					err, ErrInvalidMaxBlockSigOps)
+			}
+			continue
+		}
+		if err != nil || got != test.want {
+			t.Errorf("max %d: got %d (%v), want %d", test.max, got, err,
+				test.want)
+		}
+	}
+
+	// The transactions are selected in order under a cap of 5 sigops.
+	maxBlockSigOps, err := templateMaxSigOps(&Policy{MaxBlockSigOps: 5})
+	if err != nil {
+		t.Fatalf("templateMaxSigOps: %v", err)
+	}
+	txns := []struct {
+		tx     *types.Tx
+		reason string
+	}{
+		{newSigOpTestTx(0, 2), ""},
+		{newSigOpTestTx(1, 4), "policy max sigops per block"},
+		{newSigOpTestTx(2, 3), ""},
+		{newSigOpTestTx(3, 1), "policy max sigops per block"},
+	}
+	blockSigOpCost := int64(0)
+	for i, txn := range txns {
+		sigOpCost := int64(blockchain.CountSigOps(txn.tx))
+		reason := sigOpsSkipReason(blockSigOpCost, sigOpCost, maxBlockSigOps)
+		if reason != txn.reason {
+			t.Errorf("tx %d: got reason %q, want %q", i, reason, txn.reason)
+		}
+		if reason == "" {
+			blockSigOpCost += sigOpCost
+		}
+	}
+	if blockSigOpCost != 5 {
+		t.Errorf("got %d block sigops, want 5", blockSigOpCost)
+	}
+
+	// The consensus max is reported as such.
+	reason := sigOpsSkipReason(blockchain.MaxSigOpsPerBlock-1, 2,
+		maxBlockSigOps)
+	if reason != "max sigops per block" {
+		t.Errorf("got reason %q, want the consensus max", reason)
+	}
+}
+
+// TestRejectNonStandard ensures the transactions which aren't standard for
+// relay are skipped by the templates when the policy rejects them, and only
+// then.
+func TestRejectNonStandard(t *testing.T) {
+	pkhScript, err := txscript.NewScriptBuilder().AddOp(txscript.OP_DUP).
+		AddOp(txscript.OP_HASH160).AddData(make([]byte, 20)).
+		AddOp(txscript.OP_EQUALVERIFY).AddOp(txscript.OP_CHECKSIG).Script()
+	if err != nil {
+		t.Fatalf("Script: %v", err)
+	}
+	builder := txscript.NewScriptBuilder().AddOp(txscript.OP_1)
+	for i := 0; i < 4; i++ {
+		builder.AddData(bytes.Repeat([]byte{byte(i + 2)}, 33))
+	}
+	multiSigScript, err := builder.AddOp(txscript.OP_4).
+		AddOp(txscript.OP_CHECKMULTISIG).Script()
+	if err != nil {
+		t.Fatalf("Script: %v", err)
+	}
+
+	funding := types.NewTransaction()
+	funding.AddTxIn(&types.TxInput{
+		PreviousOut: *types.NewOutPoint(&hash.Hash{0x07}, 0),
+		Sequence:    types.MaxTxInSequenceNum,
+	})
+	funding.AddTxOut(&types.TxOutput{Amount: 1e9, PkScript: pkhScript})
+	fundingTx := types.NewTx(funding)
+	view := blockchain.NewUtxoViewpoint()
+	view.AddTxOuts(fundingTx, &hash.ZeroHash)
+
+	// newTx returns a transaction spending the funding output with the
+	// passed signature script to the passed outputs.
+	newTx := func(signScript []byte, outputs ...*types.TxOutput) *types.Tx {
+		tx := types.NewTransaction()
+		tx.AddTxIn(&types.TxInput{
+			PreviousOut: *types.NewOutPoint(fundingTx.Hash(), 0),
+			Sequence:    types.MaxTxInSequenceNum,
+			SignScript:  signScript,
+		})
+		for _, output := range outputs {
+			tx.AddTxOut(output)
+		}
+		return types.NewTx(tx)
+	}
+	manySigOps := make([]*types.TxOutput, mempool.DefaultMaxSigOpsPerTx+1)
+	for i := range manySigOps {
+		manySigOps[i] = &types.TxOutput{Amount: 1e6, PkScript: pkhScript}
+	}
+	tests := []struct {
+		name     string
+		tx       *types.Tx
+		standard bool
+	}{
+		{"standard", newTx(nil, &types.TxOutput{Amount: 1e8,
+			PkScript: pkhScript}), true},
+		{"bare multisig", newTx(nil, &types.TxOutput{Amount: 1e8,
+			PkScript: multiSigScript}), false},
+		{"oversized signature script", newTx(bytes.Repeat(
+			[]byte{txscript.OP_1}, 1651), &types.TxOutput{Amount: 1e8,
+			PkScript: pkhScript}), false},
+		{"too many sigops", newTx(nil, manySigOps...), false},
+		{"dust", newTx(nil, &types.TxOutput{Amount: 1,
+			PkScript: pkhScript}), false},
+	}
+	for _, test := range tests {
+		for _, reject := range []bool{false, true} {
+			policy := &Policy{
+				TxMinFreeFee:      mempool.DefaultMinRelayTxFee,
+				RejectNonStandard: reject,
+			}
+			err := checkTemplateStandard(policy, test.tx, view, 1,
+				time.Now())
+			if skipped := err != nil; skipped != (reject && !test.standard) {
+				t.Errorf("%s: reject %v: got error %v", test.name,
+					reject, err)
+			}
+		}
+	}
+}
+
+// TestDecodeBlockTemplate ensures a template round trips through its JSON
+// encoding and decodes to the view of its transactions and coinbase, and that
+// the block of the template decodes alone.
+func TestDecodeBlockTemplate(t *testing.T) {
+	p := &params.MainNetParams
+	subsidyCache := blockchain.NewSubsidyCache(0, p)
+	coinbaseScript, _, err := standardCoinbaseScript(1, 0, 0)
+	if err != nil {
+		t.Fatalf("standardCoinbaseScript: %v", err)
+	}
+	opReturn, err := txscript.NewScriptBuilder().AddOp(txscript.OP_RETURN).
+		AddData([]byte{0xab, 0xcd}).Script()
+	if err != nil {
+		t.Fatalf("Script: %v", err)
+	}
+	coinbaseTx, err := createCoinbaseTx(subsidyCache, coinbaseScript,
+		opReturn, 1, nil, nil, p)
+	if err != nil {
+		t.Fatalf("createCoinbaseTx: %v", err)
+	}
+	spend := newSigOpTestTx(0, 2)
+	blockTxns := []*types.Tx{coinbaseTx, spend}
+	if err := fillWitnessToCoinBase(blockTxns); err != nil {
+		t.Fatalf("fillWitnessToCoinBase: %v", err)
+	}
+	block, err := newTemplateBlock(types.BlockHeader{
+		Timestamp: time.Unix(1577836800, 0),
+		Pow:       pow.GetInstance(pow.BLAKE2BD, 0, []byte{}),
+	}, []*hash.Hash{{0x01}}, blockTxns)
+	if err != nil {
+		t.Fatalf("newTemplateBlock: %v", err)
+	}
+	subsidy, tax := calcCoinbaseSubsidy(subsidyCache, 1, p)
+	template := &types.BlockTemplate{
+		Block:       block,
+		Fees:        []int64{-50, 50},
+		SigOpCounts: []int64{int64(blockchain.CountSigOps(coinbaseTx)), 2},
+		Height:      1,
+		Blues:       1,
+		BlueSet:     []*hash.Hash{{0x01}},
+		Subsidy:     int64(subsidy),
+	}
+
+	encoded, err := json.Marshal(template)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var decoded types.BlockTemplate
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	result, err := marshal.MarshalJsonBlockTemplate(&decoded, p)
+	if err != nil {
+		t.Fatalf("MarshalJsonBlockTemplate: %v", err)
+	}
+	if want := block.BlockHash().String(); result.Hash != want {
+		t.Errorf("got hash %s, want %s", result.Hash, want)
+	}
+	if result.Height != 1 || len(result.BlueSet) != 1 {
+		t.Errorf("got height %d and blue set %v", result.Height,
+			result.BlueSet)
+	}
+	if len(result.Transactions) != 2 {
+		t.Fatalf("got %d transactions, want 2", len(result.Transactions))
+	}
+	tx := result.Transactions[1]
+	if want := spend.Hash().String(); tx.Txid != want {
+		t.Errorf("got txid %s, want %s", tx.Txid, want)
+	}
+	if tx.Fee == nil || *tx.Fee != 50 || tx.SigOps == nil || *tx.SigOps != 2 {
+		t.Errorf("got fee %v and sigops %v, want 50 and 2", tx.Fee, tx.SigOps)
+	}
+	coinbase := result.Coinbase
+	if coinbase.Subsidy == nil || *coinbase.Subsidy != int64(subsidy) {
+		t.Errorf("got subsidy %v, want %d", coinbase.Subsidy, subsidy)
+	}
+	if coinbase.Fees == nil || *coinbase.Fees != 50 {
+		t.Errorf("got fees %v, want 50", coinbase.Fees)
+	}
+	if coinbase.Reward != subsidy || coinbase.Tax != tax {
+		t.Errorf("got reward %d and tax %d, want %d and %d",
+			coinbase.Reward, coinbase.Tax, subsidy, tax)
+	}
+	if want := []string{hex.EncodeToString(opReturn)}; !reflect.DeepEqual(coinbase.Commitments, want) {
+		t.Errorf("got commitments %v, want %v", coinbase.Commitments, want)
+	}
+
+	// The solved block has no template annotations.
+	result, err = marshal.MarshalJsonBlockTemplate(
+		&types.BlockTemplate{Block: decoded.Block}, p)
+	if err != nil {
+		t.Fatalf("MarshalJsonBlockTemplate: %v", err)
+	}
+	if result.Transactions[1].Fee != nil || result.Coinbase.Subsidy != nil ||
+		result.Height != 0 {
+		t.Errorf("got template annotations for a block: %+v", result)
+	}
+	if result.Coinbase.Reward != subsidy || result.Coinbase.Tax != tax {
+		t.Errorf("got block reward %d and tax %d, want %d and %d",
+			result.Coinbase.Reward, result.Coinbase.Tax, subsidy, tax)
+	}
+}
@@ -1,11 +1,13 @@
 package mining
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/Qitmeer/qitmeer/common/hash"
 	"github.com/Qitmeer/qitmeer/core/blockchain"
 	"github.com/Qitmeer/qitmeer/core/blockdag"
 	"github.com/Qitmeer/qitmeer/core/merkle"
+	"github.com/Qitmeer/qitmeer/core/protocol"
 	s "github.com/Qitmeer/qitmeer/core/serialization"
 	"github.com/Qitmeer/qitmeer/core/types"
 	"github.com/Qitmeer/qitmeer/core/types/pow"
@@ -14,6 +16,8 @@ import (
 	"github.com/Qitmeer/qitmeer/params"
 	"github.com/Qitmeer/qitmeer/services/blkmgr"
 	"github.com/Qitmeer/qitmeer/services/mempool"
+	"sort"
+	"time"
 )
 
 // NewBlockTemplate returns a new block template that is ready to be solved
@@ -56,6 +60,25 @@ import (
 // policy setting, exceed the maximum allowed signature operations per block, or
 // otherwise cause the block to be invalid are skipped.
 //
+// Transactions whose hash is in the passed exclude set or in the
+// PersistentExcludes policy setting are skipped before any priority or fee
+// calculation, along with every transaction depending on them.
+//
+// The passed mandatory transactions are placed in order right after the
+// coinbase, ahead of any source transaction regardless of their fees.  They
+// may spend the outputs of earlier mandatory transactions, and an error is
+// returned when any of them is invalid or doesn't fit in the block.
+//
+// When DefaultPowType is passed as the proof of work type, the template is
+// generated for the default proof of work of the network parameters.
+//
+// When payouts are passed, the subsidy paid to the miner is paid to them
+// instead of the passed address.  Only one payout is accepted for now, see
+// CoinbaseOutput.
+//
+// The transactions are selected from a snapshot of the source pool taken at
+// the start of the build, so changes to the pool during the build are ignored.
+//
 // Given the above, a block generated by this function is of the following form:
 //
 //   -----------------------------------  --  --
@@ -85,13 +108,25 @@ import (
 
 func NewBlockTemplate(policy *Policy, params *params.Params,
 	sigCache *txscript.SigCache, txSource TxSource, timeSource blockchain.MedianTimeSource,
-	blockManager *blkmgr.BlockManager, payToAddress types.Address, parents []*hash.Hash, powType pow.PowType) (*types.BlockTemplate, error) {
+	blockManager *blkmgr.BlockManager, payToAddress types.Address, parents []*hash.Hash, powType pow.PowType,
+	exclude map[hash.Hash]struct{}, mustInclude []*types.Tx,
+	payouts []CoinbaseOutput) (*types.BlockTemplate, error) {
 	subsidyCache := blockManager.GetChain().FetchSubsidyCache()
 
-	best := blockManager.GetChain().BestSnapshot()
-	nextBlockHeight := uint64(0)
-	nextBlockOrder := uint64(best.GraphState.GetTotal())
-	//nextBlockLayer:=uint64(best.GraphState.GetLayer()+1)
+	// The inputs of the transactions are checked against the parameters
+	// the regtest mode relaxes, if enabled.
+	inputParams, err := templateInputParams(policy, params)
+	if err != nil {
+		return nil, err
+	}
+
+	maxBlockSigOps, err := templateMaxSigOps(policy)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build the whole template from a frozen view of the source pool.
+	txView := txSource.Snapshot()
 
 	// All transaction scripts are verified using the more strict standarad
 	// flags.
@@ -108,33 +143,45 @@ func NewBlockTemplate(policy *Policy, params *params.Params,
 		return nil, err
 	}
 
-	parentsSet := blockdag.NewHashSet()
-	if parents == nil {
-		parents = blockManager.GetChain().GetMiningTips()
-		parentsSet.AddList(parents)
-		nextBlockHeight = uint64(blockManager.GetChain().BlockDAG().GetMainChainTip().GetHeight() + 1)
-	} else {
-		parentsSet.AddList(parents)
-		mainp := blockManager.GetChain().BlockDAG().GetMainParent(blockManager.GetChain().BlockDAG().GetIdSet(parents))
-		nextBlockHeight = uint64(mainp.GetHeight() + 1)
-	}
-
-	coinbaseScript, err := standardCoinbaseScript(nextBlockHeight, extraNonce)
+	bd := blockManager.GetChain().BlockDAG()
+	position, err := newTemplatePosition(bd, parents, policy.MaxBlockParents)
 	if err != nil {
 		return nil, err
 	}
-	opReturnPkScript, err := standardCoinbaseOpReturn([]byte{})
+	parents = position.parents
+	nextBlockHeight := position.height
+	nextBlockOrder := position.order
+
+	// Detect the reuse of the coinbase address of a recent template, unless
+	// the coinbase isn't built by the node.
+	if policy.CoinbaseReuse != nil && policy.CoinbaseBuilder == nil &&
+		payToAddress != nil {
+		err := policy.CoinbaseReuse.Observe(payToAddress, nextBlockHeight)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Reserve the commitment output of the coinbase with a placeholder,
+	// which is replaced once the transactions are selected.
+	opReturnPkScript, err := coinbaseOpReturn(policy)
 	if err != nil {
 		return nil, err
 	}
 
-	blues := int64(blockManager.GetChain().BlockDAG().GetBlues(blockManager.GetChain().BlockDAG().GetIdSet(parents)))
-	coinbaseTx, err := createCoinbaseTx(subsidyCache,
-		coinbaseScript,
-		opReturnPkScript,
-		blues,
-		payToAddress,
-		params)
+	blues, blueSet, redSet := position.blues, position.blueSet, position.redSet
+	subsidy, tax := calcCoinbaseSubsidy(subsidyCache, blues, params)
+	stdBuilder := &standardCoinbaseBuilder{
+		subsidyCache:     subsidyCache,
+		opReturnPkScript: opReturnPkScript,
+		blues:            blues,
+		payToAddress:     payToAddress,
+		payouts:          payouts,
+		params:           params,
+		extraNonceSize:   policy.CoinbaseExtraNonceSize,
+	}
+	coinbaseTx, err := templateCoinbase(policy, stdBuilder, nextBlockHeight,
+		int64(subsidy+tax), extraNonce)
 	if err != nil {
 		return nil, err
 	}
@@ -146,9 +193,14 @@ func NewBlockTemplate(policy *Policy, params *params.Params,
 	// number of items that are available for the priority queue.  Also,
 	// choose the initial sort order for the priority queue based on whether
 	// or not there is an area allocated for high-priority transactions.
-	sourceTxns := txSource.MiningDescs()
+	sourceTxns := txView.MiningDescs()
+	policy.SigOpCache.Prune(sourceTxns)
+	sourceTxns = filterExcluded(sourceTxns,
+		mergeExcludes(policy.PersistentExcludes, exclude))
 	sortedByFee := policy.BlockPrioritySize == 0
 	weightedRandQueue := newWeightedRandQueue(len(sourceTxns))
+	weightedRandQueue.SetSortedByPriority(!sortedByFee)
+	weightedRandQueue.RecordPops(policy.RecordQueuePops)
 	// Create a slice to hold the transactions to be included in the
 	// generated block with reserved space.  Also create a utxo view to
 	// house all of the input transactions so multiple lookups can be
@@ -174,6 +226,109 @@ func NewBlockTemplate(policy *Policy, params *params.Params,
 	txFees = append(txFees, -1) // Updated once known
 	txSigOpCosts = append(txSigOpCosts, coinbaseSigOpCost)
 
+	blockSize := uint32(blockHeaderOverhead) + uint32(coinbaseTx.Transaction().SerializeSize())
+
+	blockSigOpCost := coinbaseSigOpCost
+	totalFees := int64(0)
+
+	// Add the mandatory transactions right after the coinbase.  Unlike the
+	// source transactions they are never skipped, so any failure aborts
+	// the template.
+	mandatory := make(map[hash.Hash]struct{}, len(mustInclude))
+	mandatorySpent := make(map[types.TxOutPoint]struct{})
+	for _, tx := range mustInclude {
+		if tx.Tx.IsCoinBase() {
+			str := fmt.Sprintf("mandatory tx %s is a coinbase", tx.Hash())
+			return nil, miningRuleError(ErrMandatoryTransaction, str)
+		}
+		if _, ok := mandatory[*tx.Hash()]; ok {
+			str := fmt.Sprintf("mandatory tx %s is duplicated", tx.Hash())
+			return nil, miningRuleError(ErrMandatoryTransaction, str)
+		}
+		if !blockchain.IsFinalizedTransaction(tx, nextBlockHeight,
+			timeSource.AdjustedTime()) {
+
+			str := fmt.Sprintf("mandatory tx %s is not finalized", tx.Hash())
+			return nil, miningRuleError(ErrMandatoryTransaction, str)
+		}
+
+		// Inputs spending earlier mandatory transactions are already
+		// in the block utxo view, so only the others are fetched.
+		utxos, err := blockManager.GetChain().FetchUtxoView(tx)
+		if err != nil {
+			str := fmt.Sprintf("unable to fetch utxo view for mandatory "+
+				"tx %s: %v", tx.Hash(), err)
+			return nil, miningRuleError(ErrFetchTxStore, str)
+		}
+		for _, txIn := range tx.Tx.TxIn {
+			if _, ok := mandatory[txIn.PreviousOut.Hash]; ok {
+				delete(utxos.Entries(), txIn.PreviousOut)
+			}
+		}
+		mergeUtxoView(blockUtxos, utxos)
+
+		txSize := uint32(tx.Transaction().SerializeSize())
+		blockPlusTxSize := blockSize + txSize
+		if blockPlusTxSize < blockSize || blockPlusTxSize >= policy.BlockMaxSize {
+			str := fmt.Sprintf("mandatory tx %s (size %v) would exceed "+
+				"the max block size", tx.Hash(), txSize)
+			return nil, miningRuleError(ErrMandatoryTransaction, str)
+		}
+		sigOpCost := policy.SigOpCache.CountSigOps(tx)
+		if blockSigOpCost+int64(sigOpCost) < blockSigOpCost ||
+			blockSigOpCost+int64(sigOpCost) > blockchain.MaxSigOpsPerBlock {
+			str := fmt.Sprintf("mandatory tx %s would exceed the maximum "+
+				"sigops per block", tx.Hash())
+			return nil, miningRuleError(ErrMandatoryTransaction, str)
+		}
+
+		fee, err := blockchain.CheckTransactionInputs(tx, blockUtxos,
+			inputParams, blockManager.GetChain())
+		if err != nil {
+			str := fmt.Sprintf("mandatory tx %s has invalid inputs: %v",
+				tx.Hash(), err)
+			return nil, miningRuleError(ErrMandatoryTransaction, str)
+		}
+		err = blockchain.ValidateTransactionScripts(tx, blockUtxos,
+			scriptFlags, sigCache)
+		if err != nil {
+			str := fmt.Sprintf("mandatory tx %s has invalid scripts: %v",
+				tx.Hash(), err)
+			return nil, miningRuleError(ErrMandatoryTransaction, str)
+		}
+
+		newTotalFees, ok := addFee(totalFees, fee)
+		if !ok {
+			str := fmt.Sprintf("mandatory tx %s fee %d would overflow "+
+				"the total fees %d", tx.Hash(), fee, totalFees)
+			return nil, miningRuleError(ErrFeesOverflow, str)
+		}
+
+		err = spendTransaction(blockUtxos, tx, &hash.ZeroHash)
+		if err != nil {
+			log.Warn("Unable to spend transaction in the preliminary "+
+				"UTXO view for the block template", "txhash", tx.Hash(),
+				"err", err)
+		}
+		for _, txIn := range tx.Tx.TxIn {
+			mandatorySpent[txIn.PreviousOut] = struct{}{}
+		}
+		mandatory[*tx.Hash()] = struct{}{}
+
+		blockTxns = append(blockTxns, tx)
+		blockSize += txSize
+		blockSigOpCost += int64(sigOpCost)
+		totalFees = newTotalFees
+		txFees = append(txFees, fee)
+		txSigOpCosts = append(txSigOpCosts, int64(sigOpCost))
+
+		log.Trace("Adding mandatory tx", "txhash", tx.Hash(), "fee", fee)
+	}
+
+	// The relative lock times are met against the past median time of
+	// the chain tip, as in the mempool.
+	medianTime := blockManager.GetChain().BestSnapshot().MedianTime
+
 	log.Debug("Inclusion to new block", "transactions", len(sourceTxns))
 mempoolLoop:
 	for _, txDesc := range sourceTxns {
@@ -181,13 +336,17 @@ mempoolLoop:
 		// non-finalized transactions.
 		tx := txDesc.Tx
 		if tx.Tx.IsCoinBase() {
-			log.Trace(fmt.Sprintf("Skipping coinbase tx %s", tx.Hash()))
+			log.Trace("Skipping tx", "txhash", tx.Hash(), "reason", "coinbase")
+			continue
+		}
+		if _, ok := mandatory[*tx.Hash()]; ok {
 			continue
 		}
 		if !blockchain.IsFinalizedTransaction(tx, nextBlockHeight,
 			timeSource.AdjustedTime()) {
 
-			log.Trace(fmt.Sprintf("Skipping non-finalized tx %s", tx.Hash()))
+			log.Trace("Skipping tx", "txhash", tx.Hash(),
+				"reason", "not finalized", "height", nextBlockHeight)
 			continue
 		}
 
@@ -198,8 +357,8 @@ mempoolLoop:
 		// dependencies in the final generated block.
 		utxos, err := blockManager.GetChain().FetchUtxoView(tx)
 		if err != nil {
-			log.Warn(fmt.Sprintf("Unable to fetch utxo view for tx %s: %v",
-				tx.Hash(), err))
+			log.Warn("Unable to fetch utxo view", "txhash", tx.Hash(),
+				"err", err)
 			continue
 		}
 
@@ -209,13 +368,23 @@ mempoolLoop:
 		weirandItem := &WeightedRandTx{tx: tx}
 		for _, txIn := range tx.Tx.TxIn {
 			originHash := &txIn.PreviousOut.Hash
+			if _, ok := mandatorySpent[txIn.PreviousOut]; ok {
+				log.Trace("Skipping tx", "txhash", tx.Hash(),
+					"reason", "double spends a mandatory tx",
+					"outpoint", txIn.PreviousOut)
+				continue mempoolLoop
+			}
+			if _, ok := mandatory[*originHash]; ok {
+				// The output is in the block utxo view already.
+				delete(utxos.Entries(), txIn.PreviousOut)
+				continue
+			}
 			entry := utxos.LookupEntry(txIn.PreviousOut)
 			if entry == nil || entry.IsSpent() {
-				if !txSource.HaveTransaction(originHash) {
-					log.Trace(fmt.Sprintf("Skipping tx %s because it "+
-						"references unspent output %v "+
-						"which is not available",
-						tx.Hash(), txIn.PreviousOut))
+				if !txView.HaveTransaction(originHash) {
+					log.Trace("Skipping tx", "txhash", tx.Hash(),
+						"reason", "unavailable output",
+						"outpoint", txIn.PreviousOut)
 					continue mempoolLoop
 				}
 
@@ -243,13 +412,25 @@ mempoolLoop:
 		// Calculate the final transaction priority using the input
 		// value age sum as well as the adjusted transaction size.  The
 		// formula is: sum(inputValue * inputAge) / adjustedTxSize
-		weirandItem.priority = mempool.CalcPriority(tx.Tx, utxos,
-			nextBlockHeight, blockManager.GetChain().BlockDAG())
+		weirandItem.priority = CalcTxPriority(tx, utxos, nextBlockHeight, bd)
 
 		// Calculate the fee in Satoshi/kB.
 		weirandItem.feePerKB = txDesc.FeePerKB
 		weirandItem.fee = txDesc.Fee
 
+		// Skip the transactions whose inputs are still locked by their
+		// relative lock times before validating their scripts.  The
+		// ones with dependencies are checked once they are ready.
+		if weirandItem.dependsOn == nil {
+			err := checkSequenceLocks(blockManager.GetChain(), tx, utxos,
+				nextBlockHeight, medianTime)
+			if err != nil {
+				log.Trace("Skipping tx", "txhash", tx.Hash(),
+					"reason", "sequence locks not met", "err", err)
+				continue
+			}
+		}
+
 		// Add the transaction to the priority queue to mark it ready
 		// for inclusion in the block unless it has dependencies.
 		if weirandItem.dependsOn == nil {
@@ -262,213 +443,144 @@ mempoolLoop:
 		mergeUtxoView(blockUtxos, utxos)
 	}
 
-	log.Trace(fmt.Sprintf("Weighted random queue len %d, dependers len %d",
-		weightedRandQueue.Len(), len(dependers)))
-
-	blockSize := uint32(blockHeaderOverhead) + uint32(coinbaseTx.Transaction().SerializeSize())
-
-	blockSigOpCost := coinbaseSigOpCost
-	totalFees := int64(0)
+	log.Trace("Weighted random queue", "len", weightedRandQueue.Len(),
+		"dependers", len(dependers))
 
 	// Choose which transactions make it into the block.
-	for weightedRandQueue.Len() > 0 {
-		// Grab the highest priority (or highest fee per kilobyte
-		// depending on the sort order) transaction.
-		weirandItem := weightedRandQueue.Pop()
-		tx := weirandItem.tx
-
-		// Grab any transactions which depend on this one.
-		deps := dependers[*tx.Hash()]
+	selection := &txSelection{
+		policy:         policy,
+		queue:          weightedRandQueue,
+		dependers:      dependers,
+		maxBlockSigOps: maxBlockSigOps,
+		sortedByFee:    sortedByFee,
+		batchSize:      txSelectionBatch,
+		height:         nextBlockHeight,
+		checkInputs: func(item *WeightedRandTx) (string, error) {
+			// The relative lock times of the transactions with
+			// dependencies are checked against the outputs of the
+			// block.
+			if item.dependsOn != nil {
+				err := checkSequenceLocks(blockManager.GetChain(),
+					item.tx, blockUtxos, nextBlockHeight, medianTime)
+				if err != nil {
+					return "sequence locks not met", err
+				}
+			}
+			_, err := blockchain.CheckTransactionInputs(item.tx, blockUtxos,
+				inputParams, blockManager.GetChain())
+			if err != nil {
+				return "CheckTransactionInputs", err
+			}
 
-		// Enforce maximum block size.  Also check for overflow.
-		txSize := uint32(tx.Transaction().SerializeSize())
-		blockPlusTxSize := blockSize + txSize
-		if blockPlusTxSize < blockSize || blockPlusTxSize >= policy.BlockMaxSize {
-			log.Trace(fmt.Sprintf("Skipping tx %s (size %v) because it "+
-				"would exceed the max block size; cur block "+
-				"size %v, cur num tx %v", tx.Hash(), txSize,
-				blockSize, len(blockTxns)))
-			logSkippedDeps(tx, deps)
-			continue
-		}
+			// Skip the non-standard transactions if the policy
+			// rejects them.
+			err = checkTemplateStandard(policy, item.tx, blockUtxos,
+				nextBlockHeight, timeSource.AdjustedTime())
+			if err != nil {
+				return "non-standard", err
+			}
+			return "", nil
+		},
+		validateScripts: func(txs []*types.Tx) []error {
+			return blockchain.ValidateTransactionsScripts(txs, blockUtxos,
+				scriptFlags, sigCache)
+		},
+		connectTx: func(tx *types.Tx) {
+			err := spendTransaction(blockUtxos, tx, &hash.ZeroHash)
+			if err != nil {
+				log.Warn("Unable to spend transaction in the "+
+					"preliminary UTXO view for the block template",
+					"txhash", tx.Hash(), "err", err)
+			}
+		},
+		blockSize:      blockSize,
+		blockSigOpCost: blockSigOpCost,
+		totalFees:      totalFees,
+		blockTxns:      blockTxns,
+		txFees:         txFees,
+		txSigOpCosts:   txSigOpCosts,
+	}
+	selection.selectTxs()
+	blockTxns, txFees, txSigOpCosts = selection.blockTxns, selection.txFees,
+		selection.txSigOpCosts
+	blockSize, blockSigOpCost = selection.blockSize, selection.blockSigOpCost
+	totalFees = selection.totalFees
 
-		// Enforce maximum signature operation cost per block.  Also
-		// check for overflow.
-		sigOpCost := blockchain.CountSigOps(tx)
-		if blockSigOpCost+int64(sigOpCost) < blockSigOpCost ||
-			blockSigOpCost+int64(sigOpCost) > blockchain.MaxSigOpsPerBlock {
-			log.Trace(fmt.Sprintf("Skipping tx %s because it would "+
-				"exceed the maximum sigops per block", tx.Hash()))
-			logSkippedDeps(tx, deps)
-			continue
-		}
+	if policy.RecordQueuePops {
+		logQueuePops(weightedRandQueue.Pops())
+	}
 
-		// Skip free transactions once the block is larger than the
-		// minimum block size.
-		if sortedByFee &&
-			weirandItem.feePerKB < int64(policy.TxMinFreeFee) &&
-			(blockPlusTxSize >= policy.BlockMinSize) {
-			log.Trace(fmt.Sprintf("Skipping tx %s with feePerKB %.2d "+
-				"< TxMinFreeFee %d and block size %d >= "+
-				"minBlockSize %d", tx.Hash(), weirandItem.feePerKB,
-				policy.TxMinFreeFee, blockPlusTxSize,
-				policy.BlockMinSize))
-			logSkippedDeps(tx, deps)
-			continue
+	// The transactions of a dependency cycle, which a valid source pool
+	// never has, are never ready, so they are left out of the block.
+	if cyclic := cyclicDependers(dependers); len(cyclic) > 0 {
+		hashes := make([]string, len(cyclic))
+		for i, tx := range cyclic {
+			hashes[i] = tx.Hash().String()
 		}
+		log.Warn("Skipping transactions with cyclic dependencies",
+			"count", len(cyclic), "txhashes", hashes)
+	}
 
-		// Ensure the transaction inputs pass all of the necessary
-		// preconditions before allowing it to be added to the block.
-		_, err = blockchain.CheckTransactionInputs(tx, blockUtxos, params, blockManager.GetChain())
-		if err != nil {
-			log.Trace(fmt.Sprintf("Skipping tx %s due to error in "+
-				"CheckTransactionInputs: %v", tx.Hash(), err))
-			logSkippedDeps(tx, deps)
-			continue
-		}
-		err = blockchain.ValidateTransactionScripts(tx, blockUtxos,
-			scriptFlags, sigCache)
-		if err != nil {
-			log.Trace(fmt.Sprintf("Skipping tx %s due to error in "+
-				"ValidateTransactionScripts: %v", tx.Hash(), err))
-			logSkippedDeps(tx, deps)
-			continue
-		}
+	// The fees are not paid by the coinbase, which pays the subsidy only.
+	err = settleCoinbaseFees(coinbaseTx, int64(subsidy+tax), totalFees, txFees)
+	if err != nil {
+		return nil, err
+	}
 
-		// Spend the transaction inputs in the block utxo view and add
-		// an entry for it to ensure any transactions which reference
-		// this one have it available as an input and can ensure they
-		// aren't double spending.
-		err = spendTransaction(blockUtxos, tx, &hash.ZeroHash)
+	var commitment []byte
+	if policy.CoinbaseCommitment != nil {
+		commitment, err = commitCoinbase(blockTxns, policy.CoinbaseCommitment)
 		if err != nil {
-			log.Warn(fmt.Sprintf("Unable to spend transaction %v in the preliminary "+
-				"UTXO view for the block template: %v",
-				tx.Hash(), err))
-		}
-		// Add the transaction to the block, increment counters, and
-		// save the fees and signature operation counts to the block
-		// template.
-		blockTxns = append(blockTxns, tx)
-		blockSize += txSize
-		blockSigOpCost += int64(sigOpCost)
-		totalFees += weirandItem.fee
-		txFees = append(txFees, weirandItem.fee)
-		txSigOpCosts = append(txSigOpCosts, int64(sigOpCost))
-
-		log.Trace(fmt.Sprintf("Adding tx %s (priority %.2f, feePerKB %.2d)",
-			weirandItem.tx.Hash(), weirandItem.priority, weirandItem.feePerKB))
-
-		// Add transactions which depend on this one (and also do not
-		// have any other unsatisified dependencies) to the priority
-		// queue.
-		for _, item := range deps {
-			// Add the transaction to the priority queue if there
-			// are no more dependencies after this one.
-			delete(item.dependsOn, *tx.Hash())
-			if len(item.dependsOn) == 0 {
-				weightedRandQueue.Push(item)
-			}
+			return nil, miningRuleError(ErrCreatingCoinbase, err.Error())
 		}
 	}
 
-	//coinbaseTx.Tx.TxOut[0].Amount += uint64(totalFees)
-	txFees[0] = -totalFees
-
 	// Fill witness
 	err = fillWitnessToCoinBase(blockTxns)
 	if err != nil {
 		return nil, miningRuleError(ErrCreatingCoinbase, err.Error())
 	}
-
-	ts := MedianAdjustedTime(blockManager.GetChain(), timeSource)
-
-	//
-	reqBlake2bDDifficulty, err := blockManager.GetChain().CalcNextRequiredDifficulty(ts, pow.BLAKE2BD)
-	if err != nil {
-		return nil, miningRuleError(ErrGettingDifficulty, err.Error())
-	}
-
-	//
-	reqX16rv3Difficulty, err := blockManager.GetChain().CalcNextRequiredDifficulty(ts, pow.X16RV3)
-	if err != nil {
-		return nil, miningRuleError(ErrGettingDifficulty, err.Error())
-	}
-
-	//
-	reqX8r16Difficulty, err := blockManager.GetChain().CalcNextRequiredDifficulty(ts, pow.X8R16)
+	err = checkWitnessCommitment(blockTxns, policy.CoinbaseCommitment)
 	if err != nil {
-		return nil, miningRuleError(ErrGettingDifficulty, err.Error())
+		return nil, err
 	}
 
-	//
-	keccak256Difficulty, err := blockManager.GetChain().CalcNextRequiredDifficulty(ts, pow.QITMEERKECCAK256)
-	if err != nil {
-		return nil, miningRuleError(ErrGettingDifficulty, err.Error())
-	}
-	reqCuckarooDifficulty, err := blockManager.GetChain().CalcNextRequiredDifficulty(ts, pow.CUCKAROO)
-	if err != nil {
-		return nil, miningRuleError(ErrGettingDifficulty, err.Error())
-	}
-	reqCuckaroomDifficulty, err := blockManager.GetChain().CalcNextRequiredDifficulty(ts, pow.CUCKAROOM)
-	if err != nil {
-		return nil, miningRuleError(ErrGettingDifficulty, err.Error())
-	}
-	reqCuckatooDifficulty, err := blockManager.GetChain().CalcNextRequiredDifficulty(ts, pow.CUCKATOO)
+	ts := MedianAdjustedTime(blockManager.GetChain(), timeSource)
 
+	reqDifficulties, err := templateDifficulties(blockManager.GetChain(),
+		policy.DifficultyCache, &blockManager.GetChain().BestSnapshot().Hash, ts)
 	if err != nil {
-		return nil, miningRuleError(ErrGettingDifficulty, err.Error())
+		return nil, err
 	}
 
 	// Choose the block version to generate based on the network.
 	blockVersion := BlockVersion(params.Net)
 
 	// Create a new block ready to be solved.
-	merkles := merkle.BuildMerkleTreeStore(blockTxns, false)
-
-	paMerkles := merkle.BuildParentsMerkleTreeStore(parents)
-	var block types.Block
-	var reqDiff uint32
-	switch powType {
-	case pow.BLAKE2BD:
-		reqDiff = reqBlake2bDDifficulty
-	case pow.CUCKAROO:
-		reqDiff = reqCuckarooDifficulty
-	case pow.CUCKATOO:
-		reqDiff = reqCuckatooDifficulty
-	case pow.CUCKAROOM:
-		reqDiff = reqCuckaroomDifficulty
-	case pow.X16RV3:
-		reqDiff = reqX16rv3Difficulty
-	case pow.X8R16:
-		reqDiff = reqX8r16Difficulty
-	case pow.QITMEERKECCAK256:
-		reqDiff = keccak256Difficulty
-	}
-	block.Header = types.BlockHeader{
+	powType = templatePowType(powType, params)
+	block, err := newTemplateBlock(types.BlockHeader{
 		Version:    blockVersion,
-		ParentRoot: *paMerkles[len(paMerkles)-1],
-		TxRoot:     *merkles[len(merkles)-1],
 		StateRoot:  hash.Hash{}, //TODO, state root
 		Timestamp:  ts,
-		Difficulty: reqDiff,
+		Difficulty: reqDifficulties[powType],
 		Pow:        pow.GetInstance(powType, 0, []byte{}),
 		// Size declared below
+	}, parents, blockTxns)
+	if err != nil {
+		return nil, err
 	}
-	for _, pb := range parents {
-		if err := block.AddParent(pb); err != nil {
+
+	if policy.VerifyTemplateSerialization {
+		if err := verifyBlockSerialization(block); err != nil {
 			return nil, err
 		}
 	}
-	for _, tx := range blockTxns {
-		if err := block.AddTransaction(tx.Transaction()); err != nil {
-			return nil, miningRuleError(ErrTransactionAppend, err.Error())
-		}
-	}
 
-	sblock := types.NewBlock(&block)
+	sblock := types.NewBlock(block)
 	sblock.SetOrder(nextBlockOrder)
 	sblock.SetHeight(uint(nextBlockHeight))
-	err = blockManager.GetChain().CheckConnectBlockTemplate(sblock)
+	err = blockManager.GetChain().CheckConnectBlockTemplateWithParams(sblock,
+		inputParams)
 	if err != nil {
 		str := fmt.Sprintf("failed to do final check for check connect "+
 			"block when making new block template: %v",
@@ -476,34 +588,71 @@ mempoolLoop:
 		return nil, miningRuleError(ErrCheckConnectBlock, str)
 	}
 
+	var prevOuts map[types.TxOutPoint]*types.TemplatePrevOut
+	if policy.IncludePrevOuts {
+		prevOuts, err = templatePrevOuts(blockTxns, blockUtxos)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	log.Debug("Created new block template",
 		"transactions", len(block.Transactions),
 		"expect fees", totalFees,
 		"signOp", blockSigOpCost,
 		"bytes", blockSize,
+		"sigOpCacheHitRate", sigOpCacheHitRate(policy.SigOpCache),
+		"difficultyCacheHitRate", difficultyCacheHitRate(policy.DifficultyCache),
 		"target",
 		fmt.Sprintf("%064x", pow.CompactToBig(block.Header.Difficulty)))
 
 	blockTemplate := &types.BlockTemplate{
-		Block:           &block,
-		Fees:            txFees,
-		SigOpCounts:     txSigOpCosts,
-		Height:          nextBlockHeight,
-		Blues:           blues,
-		ValidPayAddress: payToAddress != nil,
-		PowDiffData: types.PowDiffStandard{
-			Blake2bDTarget:         reqBlake2bDDifficulty,
-			X16rv3DTarget:          reqX16rv3Difficulty,
-			X8r16DTarget:           reqX8r16Difficulty,
-			QitmeerKeccak256Target: keccak256Difficulty,
-			CuckarooBaseDiff:       pow.CompactToBig(reqCuckarooDifficulty).Uint64(),
-			CuckaroomBaseDiff:      pow.CompactToBig(reqCuckaroomDifficulty).Uint64(),
-			CuckatooBaseDiff:       pow.CompactToBig(reqCuckatooDifficulty).Uint64(),
-		},
+		Block:              block,
+		Fees:               txFees,
+		SigOpCounts:        txSigOpCosts,
+		Height:             nextBlockHeight,
+		Blues:              blues,
+		BlueSet:            blueSet,
+		RedSet:             redSet,
+		Subsidy:            int64(subsidy),
+		ExtraNonceOffset:   stdBuilder.extraNonceOffset,
+		ExtraNonceSize:     stdBuilder.reservedExtraNonceSize,
+		CoinbaseCommitment: commitment,
+		ValidPayAddress: payToAddress != nil || len(payouts) > 0 ||
+			policy.CoinbaseBuilder != nil,
+		PowDiffData: cuckooPowDiffData(types.PowDiffStandard{
+			Blake2bDTarget:         reqDifficulties[pow.BLAKE2BD],
+			X16rv3DTarget:          reqDifficulties[pow.X16RV3],
+			X8r16DTarget:           reqDifficulties[pow.X8R16],
+			QitmeerKeccak256Target: reqDifficulties[pow.QITMEERKECCAK256],
+			CuckarooBaseDiff:       pow.CompactToBig(reqDifficulties[pow.CUCKAROO]).Uint64(),
+			CuckaroomBaseDiff:      pow.CompactToBig(reqDifficulties[pow.CUCKAROOM]).Uint64(),
+			CuckatooBaseDiff:       pow.CompactToBig(reqDifficulties[pow.CUCKATOO]).Uint64(),
+		}),
+		PrevOuts: prevOuts,
 	}
 	return handleCreatedBlockTemplate(blockTemplate, blockManager)
 }
 
+// NewEmptyBlockTemplate returns a new block template whose only transaction is
+// the coinbase, paying to the passed address as NewBlockTemplate does.  The
+// source pool isn't looked at, so it is fast regardless of the size of the
+// mempool, while the template is otherwise built and checked like any other.
+func NewEmptyBlockTemplate(policy *Policy, params *params.Params,
+	sigCache *txscript.SigCache, timeSource blockchain.MedianTimeSource,
+	blockManager *blkmgr.BlockManager, payToAddress types.Address,
+	parents []*hash.Hash, powType pow.PowType) (*types.BlockTemplate, error) {
+
+	// The caches and the estimator of the policy are fed by the source
+	// transactions, so they are left alone.
+	emptyPolicy := *policy
+	emptyPolicy.SigOpCache = nil
+	emptyPolicy.FeeEstimator = nil
+	return NewBlockTemplate(&emptyPolicy, params, sigCache,
+		mempool.NewTxSnapshot(nil, nil, time.Time{}), timeSource,
+		blockManager, payToAddress, parents, powType, nil, nil, nil)
+}
+
 // UpdateBlockTime updates the timestamp in the header of the passed block to
 // the current time while taking into account the median time of the last
 // several blocks to ensure the new time is after that time per the chain
@@ -533,6 +682,334 @@ func UpdateBlockTime(msgBlock *types.Block, chain *blockchain.BlockChain, timeSo
 	return nil
 }
 
+// verifyBlockSerialization serializes the passed block, parses it back and
+// ensures the parsed block has the same transactions and parents, as
+// committed to by the merkle roots of the header.
+func verifyBlockSerialization(block *types.Block) error {
+	var buf bytes.Buffer
+	if err := block.Serialize(&buf); err != nil {
+		str := fmt.Sprintf("failed to serialize block template: %v", err)
+		return miningRuleError(ErrTemplateSerialization, str)
+	}
+	var parsed types.Block
+	if err := parsed.Deserialize(&buf); err != nil {
+		str := fmt.Sprintf("failed to parse serialized block template: %v", err)
+		return miningRuleError(ErrTemplateSerialization, str)
+	}
+
+	if len(parsed.Transactions) != len(block.Transactions) {
+		str := fmt.Sprintf("serialized block template has %d transactions "+
+			"instead of %d", len(parsed.Transactions), len(block.Transactions))
+		return miningRuleError(ErrTemplateSerialization, str)
+	}
+	if len(parsed.Parents) != len(block.Parents) {
+		str := fmt.Sprintf("serialized block template has %d parents "+
+			"instead of %d", len(parsed.Parents), len(block.Parents))
+		return miningRuleError(ErrTemplateSerialization, str)
+	}
+
+	txns := make([]*types.Tx, len(parsed.Transactions))
+	for i, tx := range parsed.Transactions {
+		txns[i] = types.NewTx(tx)
+	}
+	merkles := merkle.BuildMerkleTreeStore(txns, false)
+	if txRoot := merkles[len(merkles)-1]; !txRoot.IsEqual(&block.Header.TxRoot) {
+		str := fmt.Sprintf("serialized block template has transaction "+
+			"merkle root %v instead of %v", txRoot, block.Header.TxRoot)
+		return miningRuleError(ErrTemplateSerialization, str)
+	}
+	if parentRoot := merkle.ComputeParentsRoot(parsed.Parents); !parentRoot.IsEqual(&block.Header.ParentRoot) {
+		str := fmt.Sprintf("serialized block template has parents "+
+			"merkle root %v instead of %v", parentRoot, block.Header.ParentRoot)
+		return miningRuleError(ErrTemplateSerialization, str)
+	}
+	return nil
+}
+
+// templatePosition is the position in the DAG of a block built on the parents
+// of a template.
+type templatePosition struct {
+	parents []*hash.Hash
+	height  uint64
+	order   uint64
+	blues   int64
+	blueSet []*hash.Hash
+	redSet  []*hash.Hash
+}
+
+// newTemplatePosition returns the position of a template built on the passed
+// parents, or on at most maxParents of the mining tips of the DAG when parents
+// is nil.  The height is the one of the main parent plus one, and the order
+// the number of blocks of the DAG.  An error is returned when there are no
+// parents or when a parent isn't in the DAG, which the DAG would fail to
+// position the template after.
+//
+// When the DAG only has the genesis block, the template is the first block
+// after it, built on the genesis alone at height 1, with the genesis as its
+// only blue block.
+func newTemplatePosition(bd *blockdag.BlockDAG, parents []*hash.Hash, maxParents int) (*templatePosition, error) {
+	if parents == nil {
+		tips := bd.GetValidTips()
+		if len(tips) == 0 {
+			return nil, miningRuleError(ErrGetTopBlock,
+				"the block DAG has no tips to build on")
+		}
+		parents = selectParents(bd, tips, maxParents)
+	}
+	if len(parents) == 0 {
+		return nil, miningRuleError(ErrGetTopBlock,
+			"a block template needs at least one parent")
+	}
+	for _, h := range parents {
+		if bd.GetBlock(h) == nil {
+			str := fmt.Sprintf("parent %v of the block template isn't "+
+				"in the block DAG", h)
+			return nil, miningRuleError(ErrGetTopBlock, str)
+		}
+	}
+
+	position := &templatePosition{
+		parents: parents,
+		order:   uint64(bd.GetBlockTotal()),
+	}
+	parentIds := bd.GetIdSet(parents)
+	if len(parents) == 1 && bd.GetBlockTotal() == 1 {
+		// The first block after the genesis, whose only parent, and blue
+		// block, is the genesis.
+		position.height = 1
+		position.blues = 1
+		position.blueSet = []*hash.Hash{parents[0]}
+		position.redSet = []*hash.Hash{}
+		return position, nil
+	}
+	position.height = uint64(bd.GetMainParent(parentIds).GetHeight() + 1)
+	blueCount, blueSet, redSet := bd.GetBlueSets(parentIds)
+	position.blues = int64(blueCount)
+	position.blueSet = blueSet
+	position.redSet = redSet
+	return position, nil
+}
+
+// selectParents returns at most max of the passed tips of the DAG, or all of
+// them when max is zero.  The first tip is the main chain tip and is always
+// kept, so it stays the main parent, and the rest are the other tips ranked
+// first by blockdag.ScoreTips.
+func selectParents(bd *blockdag.BlockDAG, tips []*hash.Hash, max int) []*hash.Hash {
+	if max <= 0 || len(tips) <= max {
+		return tips
+	}
+
+	selected := make([]*hash.Hash, 0, max)
+	selected = append(selected, tips[0])
+	for _, score := range blockdag.ScoreTips(bd, tips[1:]) {
+		if len(selected) == max {
+			break
+		}
+		selected = append(selected, score.Hash)
+	}
+	return selected
+}
+
+// newTemplateBlock returns a block with the passed header, parents and
+// transactions, whose header commits to its parents and transactions.
+func newTemplateBlock(header types.BlockHeader, parents []*hash.Hash,
+	blockTxns []*types.Tx) (*types.Block, error) {
+
+	merkles := merkle.BuildMerkleTreeStore(blockTxns, false)
+	header.ParentRoot = merkle.ComputeParentsRoot(parents)
+	header.TxRoot = *merkles[len(merkles)-1]
+
+	block := &types.Block{Header: header}
+	for _, pb := range parents {
+		if err := block.AddParent(pb); err != nil {
+			return nil, err
+		}
+	}
+	for _, tx := range blockTxns {
+		if err := block.AddTransaction(tx.Transaction()); err != nil {
+			return nil, miningRuleError(ErrTransactionAppend, err.Error())
+		}
+	}
+	return block, nil
+}
+
+// templateInputParams returns the network parameters the inputs of template
+// transactions are checked against.  In regtest mode they are a copy of the
+// passed parameters without coinbase maturity, which is refused on mainnet.
+func templateInputParams(policy *Policy, params *params.Params) (*params.Params, error) {
+	if !policy.RegtestMode {
+		return params, nil
+	}
+	if params.Net == protocol.MainNet {
+		return nil, miningRuleError(ErrRegtestMode,
+			"regtest mode is not allowed on mainnet")
+	}
+	relaxed := *params
+	relaxed.CoinbaseMaturity = 0
+	return &relaxed, nil
+}
+
+// templateMaxSigOps returns the max signature operation cost of the
+// transactions selected for templates by the passed policy, which is the
+// consensus max unless the policy sets a lower one.
+func templateMaxSigOps(policy *Policy) (int64, error) {
+	max := policy.MaxBlockSigOps
+	if max < 0 || max > blockchain.MaxSigOpsPerBlock {
+		str := fmt.Sprintf("max block sigops %d of the policy isn't "+
+			"within the consensus max %d", max,
+			blockchain.MaxSigOpsPerBlock)
+		return 0, miningRuleError(ErrInvalidMaxBlockSigOps, str)
+	}
+	if max == 0 {
+		return blockchain.MaxSigOpsPerBlock, nil
+	}
+	return max, nil
+}
+
+// sigOpsSkipReason returns why a transaction of the passed signature
+// operation cost can't be added to a block of the passed cost, with the
+// consensus max checked before the passed max of the policy, or the empty
+// string if it can be added.
+func sigOpsSkipReason(blockSigOpCost, sigOpCost, maxBlockSigOps int64) string {
+	total := blockSigOpCost + sigOpCost
+	if total < blockSigOpCost || total > blockchain.MaxSigOpsPerBlock {
+		return "max sigops per block"
+	}
+	if total > maxBlockSigOps {
+		return "policy max sigops per block"
+	}
+	return ""
+}
+
+// checkTemplateStandard returns an error when the passed policy rejects the
+// non-standard transactions and the passed transaction, whose inputs are in
+// the passed view, isn't standard for relay at the passed height and time.
+func checkTemplateStandard(policy *Policy, tx *types.Tx,
+	utxoView *blockchain.UtxoViewpoint, height uint64, medianTime time.Time) error {
+
+	if !policy.RejectNonStandard {
+		return nil
+	}
+	stdPolicy := mempool.Policy{
+		MaxTxVersion:   mempool.DefaultMaxTxVersion,
+		MaxSigOpsPerTx: mempool.DefaultMaxSigOpsPerTx,
+		MinRelayTxFee:  types.Amount(policy.TxMinFreeFee),
+	}
+	return mempool.CheckStandard(tx, utxoView, height, medianTime, &stdPolicy)
+}
+
+// SequenceLockCalculator computes the relative lock times of the inputs of
+// transactions.  It is implemented by *blockchain.BlockChain.
+type SequenceLockCalculator interface {
+	CalcSequenceLock(tx *types.Tx, view *blockchain.UtxoViewpoint) (*blockchain.SequenceLock, error)
+}
+
+// checkSequenceLocks returns an error unless the relative lock times of the
+// inputs of the passed transaction, which are in the passed view, are met by
+// a block at the passed height whose past median time is the passed time, as
+// the mempool requires of the transactions it accepts.
+func checkSequenceLocks(chain SequenceLockCalculator, tx *types.Tx,
+	utxoView *blockchain.UtxoViewpoint, height uint64, medianTime time.Time) error {
+
+	lock, err := chain.CalcSequenceLock(tx, utxoView)
+	if err != nil {
+		return err
+	}
+	if !blockchain.SequenceLockActive(lock, int64(height), medianTime) {
+		str := fmt.Sprintf("sequence locks of tx %s not met: min height "+
+			"%d, min time %d", tx.Hash(), lock.BlockHeight, lock.Time)
+		return miningRuleError(ErrSequenceLocks, str)
+	}
+	return nil
+}
+
+// addFee returns the total fees of a block after adding the passed fee of one
+// of its transactions, and whether it is valid.  Negative fees and totals
+// which would exceed the maximum amount, and so int64, are invalid, in which
+// case the total is returned unchanged.
+func addFee(totalFees, fee int64) (int64, bool) {
+	if fee < 0 || fee > types.MaxAmount-totalFees {
+		return totalFees, false
+	}
+	return totalFees + fee, true
+}
+
+// leavesPriorityArea returns whether adding the passed transaction, which
+// makes the block blockPlusTxSize bytes, ends the high-priority area of the
+// block, either because the area is full or because the priority of the
+// transaction isn't high enough.
+func leavesPriorityArea(policy *Policy, item *WeightedRandTx, blockPlusTxSize uint32) bool {
+	return blockPlusTxSize >= policy.BlockPrioritySize ||
+		item.priority <= mempool.MinHighPriority
+}
+
+// mergeExcludes returns the union of the passed exclude sets, without
+// modifying them.
+func mergeExcludes(persistent, exclude map[hash.Hash]struct{}) map[hash.Hash]struct{} {
+	if len(persistent) == 0 {
+		return exclude
+	}
+	if len(exclude) == 0 {
+		return persistent
+	}
+	merged := make(map[hash.Hash]struct{}, len(persistent)+len(exclude))
+	for txHash := range persistent {
+		merged[txHash] = struct{}{}
+	}
+	for txHash := range exclude {
+		merged[txHash] = struct{}{}
+	}
+	return merged
+}
+
+// filterExcluded returns the passed source transactions without the ones in
+// the exclude set and every transaction depending on them, directly or through
+// other source transactions.
+func filterExcluded(sourceTxns []*types.TxDesc, exclude map[hash.Hash]struct{}) []*types.TxDesc {
+	if len(exclude) == 0 {
+		return sourceTxns
+	}
+
+	// Index the source transactions by the transactions they spend from,
+	// so the dependents of the excluded transactions can be walked.
+	dependents := make(map[hash.Hash][]*types.Tx)
+	for _, txDesc := range sourceTxns {
+		for _, txIn := range txDesc.Tx.Tx.TxIn {
+			origin := txIn.PreviousOut.Hash
+			dependents[origin] = append(dependents[origin], txDesc.Tx)
+		}
+	}
+
+	excluded := make(map[hash.Hash]struct{}, len(exclude))
+	pending := make([]hash.Hash, 0, len(exclude))
+	for txHash := range exclude {
+		excluded[txHash] = struct{}{}
+		pending = append(pending, txHash)
+	}
+	for len(pending) > 0 {
+		txHash := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		for _, dep := range dependents[txHash] {
+			if _, ok := excluded[*dep.Hash()]; ok {
+				continue
+			}
+			log.Trace("Skipping tx", "txhash", dep.Hash(),
+				"reason", "depends on excluded tx", "depends", txHash)
+			excluded[*dep.Hash()] = struct{}{}
+			pending = append(pending, *dep.Hash())
+		}
+	}
+
+	filtered := make([]*types.TxDesc, 0, len(sourceTxns))
+	for _, txDesc := range sourceTxns {
+		if _, ok := excluded[*txDesc.Tx.Hash()]; ok {
+			continue
+		}
+		filtered = append(filtered, txDesc)
+	}
+	return filtered
+}
+
 // mergeUtxoView adds all of the entries in view to viewA.  The result is that
 // viewA will contain all of its original entries plus all of the entries
 // in viewB.  It will replace any entries in viewB which also exist in viewA
@@ -548,6 +1025,24 @@ func mergeUtxoView(viewA *blockchain.UtxoViewpoint, viewB *blockchain.UtxoViewpo
 	}
 }
 
+// sigOpCacheHitRate returns the hit rate of the passed signature operation
+// cache for logging, or zero when there is no cache.
+func sigOpCacheHitRate(c *SigOpCache) float64 {
+	if c == nil {
+		return 0
+	}
+	return c.HitRate()
+}
+
+// difficultyCacheHitRate returns the hit rate of the passed difficulty cache
+// for logging, or zero when there is no cache.
+func difficultyCacheHitRate(c *DifficultyCache) float64 {
+	if c == nil {
+		return 0
+	}
+	return c.HitRate()
+}
+
 // TODO, move the log logic
 // logSkippedDeps logs any dependencies which are also skipped as a result of
 // skipping a transaction while generating a block template at the trace level.
@@ -557,11 +1052,111 @@ func logSkippedDeps(tx *types.Tx, deps map[hash.Hash]*WeightedRandTx) {
 	}
 
 	for _, item := range deps {
-		log.Trace(fmt.Sprintf("Skipping tx %s since it depends on %s\n",
-			item.tx.Hash(), tx.Hash()))
+		log.Trace("Skipping tx", "txhash", item.tx.Hash(),
+			"reason", "depends on skipped tx", "depends", tx.Hash())
 	}
 }
 
+// logQueuePops logs the order in which the passed items were popped from the
+// weighted random queue, with their weights.
+func logQueuePops(pops []WeightedRandPop) {
+	for i, pop := range pops {
+		var txHash string
+		if pop.Tx != nil {
+			txHash = pop.Tx.Hash().String()
+		}
+		log.Debug("Weighted random queue pop", "index", i, "txhash", txHash,
+			"fee", pop.Fee, "priority", pop.Priority, "weight", pop.Weight,
+			"totalweight", pop.TotalWeight)
+	}
+}
+
+// pushDependers adds the passed transactions which depend on the passed
+// included one to the queue once they have no other unsatisfied dependency,
+// unless they are deeper than the maximum package size of the policy.  The
+// transactions depending on any of them are indexed by dependers.
+func pushDependers(policy *Policy, included *WeightedRandTx,
+	deps map[hash.Hash]*WeightedRandTx,
+	dependers map[hash.Hash]map[hash.Hash]*WeightedRandTx,
+	queue *WeightedRandQueue) {
+
+	for _, item := range deps {
+		delete(item.dependsOn, *included.tx.Hash())
+		if item.depth < included.depth+1 {
+			item.depth = included.depth + 1
+		}
+
+		// Add the transaction to the priority queue if there are no
+		// more dependencies after this one.
+		if len(item.dependsOn) != 0 {
+			continue
+		}
+		if policy.MaxPackageSize > 0 && item.depth >= policy.MaxPackageSize {
+			log.Trace("Skipping tx", "txhash", item.tx.Hash(),
+				"reason", "max package size", "depth", item.depth+1,
+				"maxPackageSize", policy.MaxPackageSize)
+			logSkippedDeps(item.tx, dependers[*item.tx.Hash()])
+			continue
+		}
+		queue.Push(item)
+	}
+}
+
+// cyclicDependers returns the transactions indexed by dependers which are part
+// of a cycle of unsatisfied dependencies, or depend on one, ordered by hash.
+// Such transactions never become ready for inclusion, unlike the ones which
+// depend on a skipped transaction, and can only come from a malformed source
+// pool.
+func cyclicDependers(dependers map[hash.Hash]map[hash.Hash]*WeightedRandTx) []*types.Tx {
+	items := make(map[hash.Hash]*WeightedRandTx)
+	for _, deps := range dependers {
+		for txHash, item := range deps {
+			items[txHash] = item
+		}
+	}
+
+	// Walk the unsatisfied dependencies depth first, where reaching a
+	// transaction being visited closes a cycle.
+	const (
+		visiting = iota + 1
+		visited
+	)
+	state := make(map[hash.Hash]int, len(items))
+	cyclic := make(map[hash.Hash]bool)
+	var visit func(txHash hash.Hash) bool
+	visit = func(txHash hash.Hash) bool {
+		item, ok := items[txHash]
+		if !ok || len(item.dependsOn) == 0 {
+			return false
+		}
+		switch state[txHash] {
+		case visiting:
+			return true
+		case visited:
+			return cyclic[txHash]
+		}
+		state[txHash] = visiting
+		for dep := range item.dependsOn {
+			if visit(dep) {
+				cyclic[txHash] = true
+			}
+		}
+		state[txHash] = visited
+		return cyclic[txHash]
+	}
+
+	var txns []*types.Tx
+	for txHash, item := range items {
+		if visit(txHash) {
+			txns = append(txns, item.tx)
+		}
+	}
+	sort.Slice(txns, func(i, j int) bool {
+		return bytes.Compare(txns[i].Hash()[:], txns[j].Hash()[:]) < 0
+	})
+	return txns
+}
+
 // spendTransaction updates the passed view by marking the inputs to the passed
 // transaction as spent.  It also adds all outputs in the passed transaction
 // which are not provably unspendable as available unspent transaction outputs.
@@ -578,6 +1173,36 @@ func spendTransaction(utxoView *blockchain.UtxoViewpoint, tx *types.Tx, blockHas
 	return nil
 }
 
+// templatePrevOuts returns the outputs spent by the passed transactions of a
+// block other than the coinbase, keyed by the outpoint of the input spending
+// them, from the utxo view the block was validated against.  The outputs of
+// the view are looked up even when spent by the block, so the view must be the
+// one the transactions were spent in.
+func templatePrevOuts(blockTxns []*types.Tx,
+	view *blockchain.UtxoViewpoint) (map[types.TxOutPoint]*types.TemplatePrevOut, error) {
+
+	prevOuts := make(map[types.TxOutPoint]*types.TemplatePrevOut)
+	for _, tx := range blockTxns {
+		if tx.Tx.IsCoinBase() {
+			continue
+		}
+		for _, txIn := range tx.Transaction().TxIn {
+			entry := view.LookupEntry(txIn.PreviousOut)
+			if entry == nil {
+				str := fmt.Sprintf("output %v spent by transaction %v "+
+					"is missing from the block utxo view",
+					txIn.PreviousOut, tx.Hash())
+				return nil, miningRuleError(ErrFetchTxStore, str)
+			}
+			prevOuts[txIn.PreviousOut] = &types.TemplatePrevOut{
+				Amount:   entry.Amount(),
+				PkScript: entry.PkScript(),
+			}
+		}
+	}
+	return prevOuts, nil
+}
+
 // txIndexFromTxList returns a transaction's index in a list, or -1 if it
 // can not be found.
 func txIndexFromTxList(hash hash.Hash, list []*types.Tx) int {
@@ -602,10 +1227,8 @@ func handleCreatedBlockTemplate(blockTemplate *types.BlockTemplate, bm *blkmgr.B
 	nextBlockHeight := blockTemplate.Height
 
 	// Overwrite the old cached block if it's out of date.
-	if curTemplate != nil {
-		if curTemplate.Height == nextBlockHeight {
-			bm.SetCurrentTemplate(blockTemplate)
-		}
+	if curTemplate == nil || curTemplate.Height <= nextBlockHeight {
+		bm.SetCurrentTemplate(blockTemplate)
 	}
 
 	return blockTemplate, nil
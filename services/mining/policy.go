@@ -7,7 +7,9 @@
 package mining
 
 import (
+	"github.com/Qitmeer/qitmeer/common/hash"
 	"github.com/Qitmeer/qitmeer/engine/txscript"
+	"github.com/Qitmeer/qitmeer/services/mempool"
 )
 
 // Policy houses the policy (configuration parameters) which is used to control
@@ -38,4 +40,110 @@ type Policy struct {
 	//
 	// This function must be safe for concurrent access.
 	StandardVerifyFlags func() (txscript.ScriptFlags, error)
+
+	// SigOpCache caches the signature operation counts of the source
+	// transactions across template builds.  When nil, the counts are
+	// computed on every build.
+	SigOpCache *SigOpCache
+
+	// DifficultyCache caches the difficulties required of the templates
+	// built on the same main chain tip within a short span of time.  When
+	// nil, the difficulty of every proof of work type is computed on every
+	// build.
+	DifficultyCache *DifficultyCache
+
+	// MaxBlockParents is the maximum number of tips used as parents when
+	// the parents of a template aren't given.  The main chain tip is always
+	// kept, and the others are ranked with blockdag.ScoreTips to fill the
+	// rest: by blue score, then height, then hash.  Zero means no limit.
+	MaxBlockParents int
+
+	// CoinbaseExtraNonceSize is the number of bytes reserved for the extra
+	// nonce in the coinbase script, which miners can roll.  Zero keeps the
+	// default extra nonce, which has no fixed size.
+	CoinbaseExtraNonceSize int
+
+	// VerifyTemplateSerialization makes every template be serialized and
+	// parsed back before being returned, to catch encoding regressions at
+	// generation time.  It is off by default since it costs a full
+	// serialization of the block.
+	VerifyTemplateSerialization bool
+
+	// CoinbaseCommitment computes a commitment over the transactions of
+	// each template, which is embedded in an OP_RETURN output of the
+	// coinbase and reported by the template, such as
+	// WitnessRootCommitment for merged mining.  When nil, the coinbase
+	// commits to nothing.
+	CoinbaseCommitment CoinbaseCommitmentFunc
+
+	// OmitCoinbaseOpReturn keeps the coinbase of the templates free of
+	// OP_RETURN outputs, for the networks which don't expect them.  It
+	// can't be combined with CoinbaseCommitment, whose commitment is held
+	// by such an output, and the coinbases of a CoinbaseBuilder are
+	// rejected when they have one.
+	OmitCoinbaseOpReturn bool
+
+	// CoinbaseBuilder builds the coinbase of each template in place of
+	// the node, which then only checks that it pays the block subsidy.
+	// When nil, the coinbase pays to the address or the payouts passed to
+	// NewBlockTemplate.
+	CoinbaseBuilder CoinbaseBuilder
+
+	// RegtestMode lets templates spend immature coinbase outputs, so that
+	// test scenarios on a private chain can chain-spend them quickly.
+	// The templates are fully checked with the relaxed maturity, but the
+	// nodes enforcing the coinbase maturity of their network, this one
+	// included, reject the blocks which spend immature outputs.  Building
+	// a template fails when it is enabled on mainnet.
+	RegtestMode bool
+
+	// MaxPackageSize limits the length, in transactions, of the chains of
+	// dependent source pool transactions a template includes, counting the
+	// first one which only spends confirmed outputs.  The transactions
+	// deeper in a longer chain are skipped, which bounds the cost of
+	// building a template from a pool full of long chains.  Zero means
+	// unlimited.
+	MaxPackageSize int
+
+	// PersistentExcludes holds the hashes of transactions which are never
+	// included in templates, in addition to the exclude set passed to each
+	// build.  Changes are picked up by the next build, but the map must not
+	// be modified while a build is running.
+	PersistentExcludes map[hash.Hash]struct{}
+
+	// FeeEstimator observes the fee rates of the transactions selected for
+	// templates.  When nil, nothing is observed.
+	FeeEstimator *mempool.FeeEstimator
+
+	// CoinbaseReuse tracks the addresses the coinbases of the templates
+	// pay to, and warns about or rejects the templates reusing a recent
+	// one.  When nil, the addresses aren't tracked.
+	CoinbaseReuse *CoinbaseReuseTracker
+
+	// IncludePrevOuts makes the templates carry the outputs spent by their
+	// transactions, for the stateless signers which can't look them up.
+	// It is off by default since it bloats the templates.
+	IncludePrevOuts bool
+
+	// MaxBlockSigOps is a soft cap on the signature operation cost of the
+	// transactions selected for templates, below the consensus max, which
+	// keeps the verification of the blocks cheaper for the relaying peers.
+	// The mandatory transactions are only held to the consensus max.  Zero
+	// means the consensus max, and building a template fails when it
+	// exceeds the consensus max.
+	MaxBlockSigOps int64
+
+	// RejectNonStandard makes the templates skip the transactions which
+	// aren't standard for relay, such as the ones a mempool accepting
+	// non-standard transactions holds, so that the mined blocks only have
+	// standard transactions.  The transactions are checked as the mempool
+	// does with its default limits and TxMinFreeFee as the min relay fee.
+	// The mandatory transactions aren't checked.
+	RejectNonStandard bool
+
+	// RecordQueuePops logs, at the debug level, the order in which each
+	// build pops the transactions from its weighted random queue, with
+	// their weights, to check the fairness of the selection.  It is meant
+	// for debugging only since recording has a cost.
+	RecordQueuePops bool
 }
@@ -0,0 +1,152 @@
+// Copyright (c) 2017-2018 The qitmeer developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"github.com/Qitmeer/qitmeer/core/blockchain"
+	"github.com/Qitmeer/qitmeer/core/types"
+)
+
+// ProposalChain is the part of the chain block proposals are validated
+// against.  It is implemented by *blockchain.BlockChain.
+type ProposalChain interface {
+	BestSnapshot() *blockchain.BestState
+	CheckConnectBlockTemplate(block *types.SerializedBlock) error
+}
+
+// ProposalRejectError is returned by ProposeBlock for a rejected proposal.
+// Its reason is one of the reject reasons of the getblocktemplate proposal
+// mode described by BIP 0022 and BIP 0023, such as "bad-txns-missinginput" or
+// "inconclusive".
+type ProposalRejectError struct {
+	Reason string
+	Err    error
+}
+
+// Error satisfies the error interface and returns the reject reason.
+func (e ProposalRejectError) Error() string {
+	return e.Reason
+}
+
+// blockRejectReasons maps the chain rule errors to the reject reasons of
+// block proposals.
+var blockRejectReasons = map[blockchain.ErrorCode]string{
+	blockchain.ErrDuplicateBlock:        "duplicate",
+	blockchain.ErrBlockTooBig:           "bad-blk-length",
+	blockchain.ErrWrongBlockSize:        "bad-blk-length",
+	blockchain.ErrBlockVersionTooOld:    "bad-version",
+	blockchain.ErrInvalidTime:           "bad-time",
+	blockchain.ErrTimeTooOld:            "time-too-old",
+	blockchain.ErrTimeTooNew:            "time-too-new",
+	blockchain.ErrDifficultyTooLow:      "bad-diffbits",
+	blockchain.ErrUnexpectedDifficulty:  "bad-diffbits",
+	blockchain.ErrHighHash:              "high-hash",
+	blockchain.ErrInvalidPow:            "high-hash",
+	blockchain.ErrInValidPowType:        "bad-pow-type",
+	blockchain.ErrBadMerkleRoot:         "bad-txnmrklroot",
+	blockchain.ErrBadParentsMerkleRoot:  "bad-parentsmrklroot",
+	blockchain.ErrBadCheckpoint:         "bad-checkpoint",
+	blockchain.ErrForkTooOld:            "fork-too-old",
+	blockchain.ErrCheckpointTimeTooOld:  "checkpoint-time-too-old",
+	blockchain.ErrNoTransactions:        "bad-txns-none",
+	blockchain.ErrNoParents:             "bad-parents-none",
+	blockchain.ErrDuplicateParent:       "bad-parents-duplicate",
+	blockchain.ErrTooManyTransactions:   "bad-txns-toomany",
+	blockchain.ErrNoTxInputs:            "bad-txns-noinputs",
+	blockchain.ErrNoTxOutputs:           "bad-txns-nooutputs",
+	blockchain.ErrTxTooBig:              "bad-txns-size",
+	blockchain.ErrInvalidTxOutValue:     "bad-txns-outputvalue",
+	blockchain.ErrDuplicateTxInputs:     "bad-txns-dupinputs",
+	blockchain.ErrInvalidTxInput:        "bad-txns-badinput",
+	blockchain.ErrMissingTxOut:          "bad-txns-missinginput",
+	blockchain.ErrUnfinalizedTx:         "bad-txns-unfinalizedtx",
+	blockchain.ErrDuplicateTx:           "bad-txns-duplicate",
+	blockchain.ErrOverwriteTx:           "bad-txns-overwrite",
+	blockchain.ErrImmatureSpend:         "bad-txns-maturity",
+	blockchain.ErrSpendTooHigh:          "bad-txns-highspend",
+	blockchain.ErrBadFees:               "bad-txns-fees",
+	blockchain.ErrExpiredTx:             "bad-txns-expired",
+	blockchain.ErrTooManySigOps:         "high-sigops",
+	blockchain.ErrFirstTxNotCoinbase:    "bad-txns-nocoinbase",
+	blockchain.ErrMultipleCoinbases:     "bad-txns-multicoinbase",
+	blockchain.ErrBadCoinbaseScriptLen:  "bad-cb-length",
+	blockchain.ErrBadCoinbaseValue:      "bad-cb-value",
+	blockchain.ErrCoinbaseHeight:        "bad-cb-height",
+	blockchain.ErrMissingCoinbaseHeight: "bad-cb-height",
+	blockchain.ErrBadBlockHeight:        "bad-cb-height",
+	blockchain.ErrScriptMalformed:       "bad-script-malformed",
+	blockchain.ErrScriptValidation:      "bad-script-validate",
+	blockchain.ErrPrevBlockNotBest:      "inconclusive-not-best-prvblk",
+	blockchain.ErrMissingParent:         "inconclusive-missing-parent",
+	blockchain.ErrParentsBlockUnknown:   "inconclusive-missing-parent",
+}
+
+// miningRejectReasons maps the mining rule errors to the reject reasons of
+// block proposals.  The errors which don't depend on the block are
+// inconclusive.
+var miningRejectReasons = map[MiningErrorCode]string{
+	ErrGetTopBlock:            "inconclusive",
+	ErrGettingMedianTime:      "inconclusive",
+	ErrGettingDifficulty:      "inconclusive",
+	ErrFetchTxStore:           "inconclusive",
+	ErrCreatingCoinbase:       "bad-cb",
+	ErrCoinbaseLengthOverflow: "bad-cb-length",
+	ErrInvalidCoinbasePayouts: "bad-cb-value",
+	ErrTransactionAppend:      "bad-txns",
+	ErrMandatoryTransaction:   "bad-txns",
+	ErrCheckBlockSanity:       "rejected",
+	ErrCheckConnectBlock:      "rejected",
+	ErrInvalidPow:             "high-hash",
+	ErrFeesOverflow:           "bad-txns-fees",
+	ErrCoinbaseAmount:         "bad-cb-value",
+	ErrWitnessCommitment:      "bad-witness-merkle-match",
+	ErrStaleParents:           "stale-prevblk",
+}
+
+// ProposalRejectReason returns the getblocktemplate proposal reject reason of
+// the passed validation error.  Rule errors without a specific reason are
+// "rejected", and other errors, which don't tell whether the block is valid,
+// are "inconclusive".
+func ProposalRejectReason(err error) string {
+	switch e := err.(type) {
+	case ProposalRejectError:
+		return e.Reason
+	case blockchain.RuleError:
+		if reason, ok := blockRejectReasons[e.ErrorCode]; ok {
+			return reason
+		}
+		return "rejected"
+	case MiningRuleError:
+		if reason, ok := miningRejectReasons[e.ErrorCode]; ok {
+			return reason
+		}
+		return "rejected"
+	}
+	return "inconclusive"
+}
+
+// ProposeBlock validates the passed externally built block as a block proposal
+// would be, with every consensus rule but the proof of work, against the
+// current state of the passed chain.  Nil is returned when the block would be
+// accepted, and a ProposalRejectError with the reject reason otherwise.
+func ProposeBlock(chain ProposalChain, block *types.Block) error {
+	if len(block.Transactions) == 0 {
+		err := miningRuleError(ErrCheckBlockSanity, "block has no coinbase")
+		return ProposalRejectError{Reason: "bad-txns-none", Err: err}
+	}
+	height, err := blockchain.ExtractCoinbaseHeight(block.Transactions[0])
+	if err != nil {
+		return ProposalRejectError{Reason: ProposalRejectReason(err), Err: err}
+	}
+
+	sblock := types.NewBlock(block)
+	sblock.SetOrder(uint64(chain.BestSnapshot().GraphState.GetTotal()))
+	sblock.SetHeight(uint(height))
+	err = chain.CheckConnectBlockTemplate(sblock)
+	if err != nil {
+		return ProposalRejectError{Reason: ProposalRejectReason(err), Err: err}
+	}
+	return nil
+}
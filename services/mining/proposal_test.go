@@ -0,0 +1,110 @@
+package mining
+
+import (
+	"github.com/Qitmeer/qitmeer/core/blockchain"
+	"github.com/Qitmeer/qitmeer/core/blockdag"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"github.com/Qitmeer/qitmeer/params"
+	"testing"
+)
+
+// proposalTestChain is a chain whose only rule is that the inputs of the
+// transactions of a block are unspent outputs.
+type proposalTestChain struct {
+	best      *blockchain.BestState
+	unspent   map[types.TxOutPoint]struct{}
+	lastBlock *types.SerializedBlock
+}
+
+func (c *proposalTestChain) BestSnapshot() *blockchain.BestState {
+	return c.best
+}
+
+func (c *proposalTestChain) CheckConnectBlockTemplate(block *types.SerializedBlock) error {
+	c.lastBlock = block
+	spent := make(map[types.TxOutPoint]struct{})
+	for _, tx := range block.Transactions()[1:] {
+		for _, txIn := range tx.Tx.TxIn {
+			_, unspent := c.unspent[txIn.PreviousOut]
+			if _, ok := spent[txIn.PreviousOut]; ok || !unspent {
+				return blockchain.RuleError{
+					ErrorCode:   blockchain.ErrMissingTxOut,
+					Description: "output already spent",
+				}
+			}
+			spent[txIn.PreviousOut] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// TestProposeBlock ensures valid proposals are accepted and double spends are
+// rejected with the standard reject reason.
+func TestProposeBlock(t *testing.T) {
+	gs := blockdag.NewGraphState()
+	gs.SetTotal(42)
+	chain := &proposalTestChain{
+		best:    &blockchain.BestState{GraphState: gs},
+		unspent: make(map[types.TxOutPoint]struct{}),
+	}
+	spend1 := newSigOpTestTx(0, 1)
+	spend2 := newSigOpTestTx(1, 1)
+	for _, tx := range []*types.Tx{spend1, spend2} {
+		chain.unspent[tx.Tx.TxIn[0].PreviousOut] = struct{}{}
+	}
+
+	p := &params.PrivNetParams
+	coinbaseScript, _, err := standardCoinbaseScript(7, 0, 0)
+	if err != nil {
+		t.Fatalf("standardCoinbaseScript: %v", err)
+	}
+	coinbaseTx, err := createCoinbaseTx(blockchain.NewSubsidyCache(0, p),
+		coinbaseScript, nil, 1, nil, nil, p)
+	if err != nil {
+		t.Fatalf("createCoinbaseTx: %v", err)
+	}
+	newBlock := func(txns ...*types.Tx) *types.Block {
+		var block types.Block
+		for _, tx := range append([]*types.Tx{coinbaseTx}, txns...) {
+			if err := block.AddTransaction(tx.Tx); err != nil {
+				t.Fatalf("AddTransaction: %v", err)
+			}
+		}
+		return &block
+	}
+
+	if err := ProposeBlock(chain, newBlock(spend1, spend2)); err != nil {
+		t.Fatalf("valid proposal rejected: %v", err)
+	}
+	if chain.lastBlock.Height() != 7 || chain.lastBlock.Order() != 42 {
+		t.Errorf("proposal checked at height %d order %d, want 7 and 42",
+			chain.lastBlock.Height(), chain.lastBlock.Order())
+	}
+
+	// A block spending the same output twice.
+	doubleSpend := types.NewTransaction()
+	doubleSpend.AddTxIn(spend1.Tx.TxIn[0])
+	doubleSpend.AddTxOut(&types.TxOutput{Amount: 2})
+	err = ProposeBlock(chain, newBlock(spend1, types.NewTx(doubleSpend)))
+	rerr, ok := err.(ProposalRejectError)
+	if !ok || rerr.Reason != "bad-txns-missinginput" {
+		t.Fatalf("double spend proposal: got %v, want bad-txns-missinginput",
+			err)
+	}
+
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{miningRuleError(ErrGettingDifficulty, ""), "inconclusive"},
+		{miningRuleError(ErrInvalidPow, ""), "high-hash"},
+		{miningRuleError(ErrNotEnoughVoters, ""), "rejected"},
+		{blockchain.RuleError{ErrorCode: blockchain.ErrBadMerkleRoot}, "bad-txnmrklroot"},
+		{blockchain.RuleError{ErrorCode: blockchain.ErrNoViewpoint}, "rejected"},
+	}
+	for _, test := range tests {
+		if got := ProposalRejectReason(test.err); got != test.want {
+			t.Errorf("%v: got %q, want %q", test.err, got, test.want)
+		}
+	}
+}
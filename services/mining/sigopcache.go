@@ -0,0 +1,98 @@
+// Copyright (c) 2017-2018 The qitmeer developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/blockchain"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"sync"
+)
+
+// SigOpCache caches the signature operation count of transactions by hash so
+// that rebuilding templates over an overlapping mempool doesn't walk the
+// scripts of the same transactions again.  Between two builds only the
+// transactions which entered the mempool miss the cache, so the hit rate of a
+// build approaches the share of the mempool it has in common with the
+// previous build.
+//
+// This type is safe for concurrent access.
+type SigOpCache struct {
+	sync.Mutex
+	entries map[hash.Hash]int
+	hits    uint64
+	misses  uint64
+}
+
+// NewSigOpCache returns an empty signature operation cache.
+func NewSigOpCache() *SigOpCache {
+	return &SigOpCache{
+		entries: make(map[hash.Hash]int),
+	}
+}
+
+// CountSigOps returns the number of signature operations of the passed
+// transaction, as blockchain.CountSigOps does, using the cached count when
+// available.  A nil cache always counts.
+func (c *SigOpCache) CountSigOps(tx *types.Tx) int {
+	if c == nil {
+		return blockchain.CountSigOps(tx)
+	}
+
+	c.Lock()
+	count, ok := c.entries[*tx.Hash()]
+	if ok {
+		c.hits++
+		c.Unlock()
+		return count
+	}
+	c.misses++
+	c.Unlock()
+
+	count = blockchain.CountSigOps(tx)
+	c.Lock()
+	c.entries[*tx.Hash()] = count
+	c.Unlock()
+	return count
+}
+
+// Prune removes the entries of every transaction which isn't in the passed
+// source transactions, which are the ones that left the mempool.
+func (c *SigOpCache) Prune(sourceTxns []*types.TxDesc) {
+	if c == nil {
+		return
+	}
+	keep := make(map[hash.Hash]struct{}, len(sourceTxns))
+	for _, txDesc := range sourceTxns {
+		keep[*txDesc.Tx.Hash()] = struct{}{}
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	for txHash := range c.entries {
+		if _, ok := keep[txHash]; !ok {
+			delete(c.entries, txHash)
+		}
+	}
+}
+
+// HitRate returns the share of lookups answered from the cache since it was
+// created.
+func (c *SigOpCache) HitRate() float64 {
+	c.Lock()
+	defer c.Unlock()
+	total := c.hits + c.misses
+	if total == 0 {
+		return 0
+	}
+	return float64(c.hits) / float64(total)
+}
+
+// Len returns the number of cached transactions.
+func (c *SigOpCache) Len() int {
+	c.Lock()
+	defer c.Unlock()
+	return len(c.entries)
+}
@@ -0,0 +1,80 @@
+package mining
+
+import (
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/blockchain"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"github.com/Qitmeer/qitmeer/engine/txscript"
+	"testing"
+)
+
+// newSigOpTestTx returns a transaction with the passed number of
+// OP_CHECKSIG outputs, made unique by the passed index.
+func newSigOpTestTx(index uint32, checkSigs int) *types.Tx {
+	tx := types.NewTransaction()
+	tx.AddTxIn(&types.TxInput{
+		PreviousOut: *types.NewOutPoint(&hash.Hash{0x01}, index),
+		Sequence:    types.MaxTxInSequenceNum,
+	})
+	for i := 0; i < checkSigs; i++ {
+		pkScript, _ := txscript.NewScriptBuilder().AddOp(txscript.OP_CHECKSIG).Script()
+		tx.AddTxOut(&types.TxOutput{Amount: 1, PkScript: pkScript})
+	}
+	return types.NewTx(tx)
+}
+
+func TestSigOpCache(t *testing.T) {
+	c := NewSigOpCache()
+	tx1 := newSigOpTestTx(0, 2)
+	tx2 := newSigOpTestTx(1, 3)
+
+	for i := 0; i < 2; i++ {
+		if got, want := c.CountSigOps(tx1), blockchain.CountSigOps(tx1); got != want {
+			t.Fatalf("CountSigOps: got %d, want %d", got, want)
+		}
+	}
+	c.CountSigOps(tx2)
+	if got := c.HitRate(); got != 1.0/3 {
+		t.Errorf("HitRate: got %v, want %v", got, 1.0/3)
+	}
+
+	// tx1 left the mempool.
+	c.Prune([]*types.TxDesc{{Tx: tx2}})
+	if c.Len() != 1 {
+		t.Fatalf("Len after prune: got %d, want 1", c.Len())
+	}
+	if _, ok := c.entries[*tx1.Hash()]; ok {
+		t.Fatal("pruned transaction is still cached")
+	}
+
+	// A nil cache counts directly.
+	var nc *SigOpCache
+	if got, want := nc.CountSigOps(tx2), blockchain.CountSigOps(tx2); got != want {
+		t.Fatalf("nil CountSigOps: got %d, want %d", got, want)
+	}
+}
+
+// BenchmarkSigOpCache compares counting the signature operations of a
+// mempool on every build against reusing the cached counts.
+func BenchmarkSigOpCache(b *testing.B) {
+	txns := make([]*types.Tx, 1000)
+	for i := range txns {
+		txns[i] = newSigOpTestTx(uint32(i), 1+i%5)
+	}
+
+	b.Run("cold", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, tx := range txns {
+				blockchain.CountSigOps(tx)
+			}
+		}
+	})
+	b.Run("warm", func(b *testing.B) {
+		c := NewSigOpCache()
+		for i := 0; i < b.N; i++ {
+			for _, tx := range txns {
+				c.CountSigOps(tx)
+			}
+		}
+	})
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2017-2018 The qitmeer developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"fmt"
+
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/blockchain"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"github.com/Qitmeer/qitmeer/params"
+)
+
+// SubmitChain is the part of the chain and of the block manager solved blocks
+// are submitted to.
+type SubmitChain interface {
+	ProposalChain
+
+	// GetMiningTips returns the tips the next block must build on.
+	GetMiningTips() []*hash.Hash
+
+	// ProcessBlock connects the block to the chain, and returns whether it
+	// is an orphan.
+	ProcessBlock(block *types.SerializedBlock, flags blockchain.BehaviorFlags) (bool, error)
+
+	// InvalidateTemplateCache drops the cached block templates, which are
+	// built on stale parents once a block is connected.
+	InvalidateTemplateCache()
+}
+
+// SubmitBlock checks the proof of work of the passed solved block, which was
+// built from a prior template, and that its parents are still tips of the
+// chain, then validates it as ProposeBlock and connects it.  The template
+// cache is invalidated once the block is accepted.  A ProposalRejectError is
+// returned for a rejected block, whose reason is "stale-prevblk" when the
+// tips changed since the template was built and "orphan" when the block
+// can't be connected yet.
+func SubmitBlock(chain SubmitChain, block *types.Block, params *params.Params) (*types.SerializedBlock, error) {
+	if err := VerifyBlockPow(block, params); err != nil {
+		return nil, ProposalRejectError{Reason: ProposalRejectReason(err), Err: err}
+	}
+
+	tips := make(map[hash.Hash]struct{})
+	for _, tip := range chain.GetMiningTips() {
+		tips[*tip] = struct{}{}
+	}
+	for _, parent := range block.Parents {
+		if _, ok := tips[*parent]; !ok {
+			str := fmt.Sprintf("parent %v of block %v is no longer a tip",
+				parent, block.BlockHash())
+			err := miningRuleError(ErrStaleParents, str)
+			return nil, ProposalRejectError{Reason: ProposalRejectReason(err), Err: err}
+		}
+	}
+
+	if err := ProposeBlock(chain, block); err != nil {
+		return nil, err
+	}
+
+	sblock := types.NewBlock(block)
+	isOrphan, err := chain.ProcessBlock(sblock, blockchain.BFNone)
+	if err != nil {
+		return nil, ProposalRejectError{Reason: ProposalRejectReason(err), Err: err}
+	}
+	if isOrphan {
+		err := fmt.Errorf("block %v is an orphan", sblock.Hash())
+		return nil, ProposalRejectError{Reason: "orphan", Err: err}
+	}
+	chain.InvalidateTemplateCache()
+	return sblock, nil
+}
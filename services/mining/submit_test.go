@@ -0,0 +1,129 @@
+package mining
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/blockchain"
+	"github.com/Qitmeer/qitmeer/core/blockdag"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"github.com/Qitmeer/qitmeer/core/types/pow"
+	"github.com/Qitmeer/qitmeer/params"
+)
+
+// submitTestChain is a proposal test chain which records the processed
+// blocks and the invalidations of the template cache.
+type submitTestChain struct {
+	proposalTestChain
+	tips        []*hash.Hash
+	processed   []*types.SerializedBlock
+	invalidated int
+}
+
+func (c *submitTestChain) GetMiningTips() []*hash.Hash {
+	return c.tips
+}
+
+func (c *submitTestChain) ProcessBlock(block *types.SerializedBlock, flags blockchain.BehaviorFlags) (bool, error) {
+	c.processed = append(c.processed, block)
+	return false, nil
+}
+
+func (c *submitTestChain) InvalidateTemplateCache() {
+	c.invalidated++
+}
+
+// TestSubmitBlock ensures solved blocks are connected, and blocks with a bad
+// proof of work or stale parents are rejected with their reject reason.
+func TestSubmitBlock(t *testing.T) {
+	p := &params.PrivNetParams
+	coinbaseScript, _, err := standardCoinbaseScript(1, 0, 0)
+	if err != nil {
+		t.Fatalf("standardCoinbaseScript: %v", err)
+	}
+	coinbaseTx, err := createCoinbaseTx(blockchain.NewSubsidyCache(0, p),
+		coinbaseScript, nil, 1, nil, nil, p)
+	if err != nil {
+		t.Fatalf("createCoinbaseTx: %v", err)
+	}
+
+	parent := hash.HashH([]byte("parent"))
+	other := hash.HashH([]byte("other"))
+	block := &types.Block{
+		Header: types.BlockHeader{
+			Timestamp:  time.Unix(1577836800, 0),
+			Difficulty: 0x2000ffff,
+			Pow:        pow.GetInstance(pow.BLAKE2BD, 0, []byte{}),
+		},
+		Parents: []*hash.Hash{&parent},
+	}
+	if err := block.AddTransaction(coinbaseTx.Tx); err != nil {
+		t.Fatalf("AddTransaction: %v", err)
+	}
+	goodNonce, badNonce := uint32(0), uint32(0)
+	solved, unsolved := false, false
+	for nonce := uint32(0); nonce < 1<<22 && !solved; nonce++ {
+		block.Header.Pow.SetNonce(nonce)
+		if VerifyBlockPow(block, p) == nil {
+			solved = true
+			goodNonce = nonce
+		} else if !unsolved {
+			unsolved = true
+			badNonce = nonce
+		}
+	}
+	if !solved || !unsolved {
+		t.Fatalf("failed to find a solution")
+	}
+
+	newChain := func(tips ...*hash.Hash) *submitTestChain {
+		gs := blockdag.NewGraphState()
+		gs.SetTotal(1)
+		return &submitTestChain{
+			proposalTestChain: proposalTestChain{
+				best:    &blockchain.BestState{GraphState: gs},
+				unspent: make(map[types.TxOutPoint]struct{}),
+			},
+			tips: tips,
+		}
+	}
+
+	// A valid submission.
+	chain := newChain(&parent, &other)
+	block.Header.Pow.SetNonce(goodNonce)
+	accepted, err := SubmitBlock(chain, block, p)
+	if err != nil {
+		t.Fatalf("valid block rejected: %v", err)
+	}
+	if len(chain.processed) != 1 || chain.processed[0] != accepted ||
+		*accepted.Hash() != block.BlockHash() {
+		t.Errorf("valid block not processed")
+	}
+	if chain.invalidated != 1 {
+		t.Errorf("template cache invalidated %d times, want 1",
+			chain.invalidated)
+	}
+
+	tests := []struct {
+		name  string
+		nonce uint32
+		tips  []*hash.Hash
+		want  string
+	}{
+		{"bad pow", badNonce, []*hash.Hash{&parent}, "high-hash"},
+		{"stale parents", goodNonce, []*hash.Hash{&other}, "stale-prevblk"},
+	}
+	for _, test := range tests {
+		chain := newChain(test.tips...)
+		block.Header.Pow.SetNonce(test.nonce)
+		_, err := SubmitBlock(chain, block, p)
+		rerr, ok := err.(ProposalRejectError)
+		if !ok || rerr.Reason != test.want {
+			t.Errorf("%s: got %v, want %s", test.name, err, test.want)
+		}
+		if len(chain.processed) != 0 || chain.invalidated != 0 {
+			t.Errorf("%s: rejected block processed", test.name)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2017-2018 The qitmeer developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"github.com/Qitmeer/qitmeer/core/types/pow"
+	"github.com/Qitmeer/qitmeer/services/blkmgr"
+)
+
+// errTemplateBuildAborted is returned to the callers waiting for a template
+// build which panicked.
+var errTemplateBuildAborted = errors.New("block template build aborted")
+
+// templateCall is a build of a block template, in flight or completed.
+type templateCall struct {
+	wg       sync.WaitGroup
+	template *types.BlockTemplate
+	err      error
+
+	// dups is the number of callers waiting for the build in addition to
+	// the one running it.
+	dups int
+}
+
+// TemplateGroup coordinates the builds of block templates of the miners and
+// of the getblocktemplate calls, so that only one build runs at a time for a
+// given key, and the concurrent callers asking for the same key wait for it
+// and share its result.  Every caller gets its own copy of the template,
+// which it's free to modify, such as by solving it.
+//
+// The zero value is ready to use, and it's safe for concurrent access.
+type TemplateGroup struct {
+	mtx   sync.Mutex
+	calls map[string]*templateCall
+}
+
+// Do returns a copy of the template built by build for the passed key.  The
+// template is built unless a build for the same key is in flight, in which
+// case its result is awaited instead.
+func (g *TemplateGroup) Do(key string, build func() (*types.BlockTemplate, error)) (*types.BlockTemplate, error) {
+	g.mtx.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*templateCall)
+	}
+	if call, ok := g.calls[key]; ok {
+		call.dups++
+		g.mtx.Unlock()
+		call.wg.Wait()
+		return blkmgr.DeepCopyBlockTemplate(call.template), call.err
+	}
+	call := &templateCall{}
+	call.wg.Add(1)
+	g.calls[key] = call
+	g.mtx.Unlock()
+
+	// The waiters are released even if the build panics, with an error.
+	call.err = errTemplateBuildAborted
+	func() {
+		defer func() {
+			g.mtx.Lock()
+			delete(g.calls, key)
+			g.mtx.Unlock()
+			call.wg.Done()
+		}()
+		call.template, call.err = build()
+	}()
+	return blkmgr.DeepCopyBlockTemplate(call.template), call.err
+}
+
+// TemplateKey returns the key of the builds of templates on the passed mining
+// tips at the passed main height, with the passed proof of work and paying to
+// the passed address, which is nil for an anyone-can-redeem coinbase.  The
+// order of the tips doesn't matter.
+func TemplateKey(tips []*hash.Hash, height uint64, powType pow.PowType,
+	payToAddress types.Address) string {
+
+	sorted := make([]*hash.Hash, len(tips))
+	copy(sorted, tips)
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i][:], sorted[j][:]) < 0
+	})
+
+	var key bytes.Buffer
+	fmt.Fprintf(&key, "%d-%d", height, powType)
+	if payToAddress != nil {
+		fmt.Fprintf(&key, "-%s", payToAddress.Encode())
+	}
+	for _, tip := range sorted {
+		fmt.Fprintf(&key, "-%v", tip)
+	}
+	return key.String()
+}
@@ -0,0 +1,93 @@
+package mining
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"github.com/Qitmeer/qitmeer/core/types/pow"
+)
+
+// TestTemplateGroup ensures concurrent requests of the same template wait for
+// a single build, and get their own copy of it.
+func TestTemplateGroup(t *testing.T) {
+	const callers = 50
+	tip := hash.HashH([]byte("tip"))
+	key := TemplateKey([]*hash.Hash{&tip}, 7, pow.BLAKE2BD, nil)
+
+	var g TemplateGroup
+	var builds int32
+	release := make(chan struct{})
+	build := func() (*types.BlockTemplate, error) {
+		atomic.AddInt32(&builds, 1)
+		<-release
+		coinbase := newSigOpTestTx(0, 1).Tx
+		return &types.BlockTemplate{
+			Block: &types.Block{
+				Parents:      []*hash.Hash{&tip},
+				Transactions: []*types.Transaction{coinbase},
+			},
+			Height: 7,
+		}, nil
+	}
+
+	var wg sync.WaitGroup
+	templates := make(chan *types.BlockTemplate, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			template, err := g.Do(key, build)
+			if err != nil {
+				t.Errorf("Do: %v", err)
+			}
+			templates <- template
+		}()
+	}
+
+	// Release the build once every other caller waits for it.
+	for waiting := 0; waiting < callers-1; {
+		time.Sleep(time.Millisecond)
+		g.mtx.Lock()
+		if call, ok := g.calls[key]; ok {
+			waiting = call.dups
+		}
+		g.mtx.Unlock()
+	}
+	close(release)
+	wg.Wait()
+	close(templates)
+
+	if builds != 1 {
+		t.Errorf("template built %d times, want 1", builds)
+	}
+	seen := make(map[*types.Block]struct{})
+	for template := range templates {
+		if template == nil || template.Height != 7 {
+			t.Fatalf("got template %v, want the built one", template)
+		}
+		if _, ok := seen[template.Block]; ok {
+			t.Errorf("template shared by two callers")
+		}
+		seen[template.Block] = struct{}{}
+	}
+
+	// The build is over, so a new request builds again.
+	if _, err := g.Do(key, build); err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	if builds != 2 {
+		t.Errorf("template built %d times, want 2", builds)
+	}
+
+	// The keys don't depend on the order of the tips.
+	other := hash.HashH([]byte("other"))
+	key1 := TemplateKey([]*hash.Hash{&tip, &other}, 7, pow.BLAKE2BD, nil)
+	key2 := TemplateKey([]*hash.Hash{&other, &tip}, 7, pow.BLAKE2BD, nil)
+	if key1 != key2 || key1 == key {
+		t.Errorf("got keys %q and %q for the same tips", key1, key2)
+	}
+}
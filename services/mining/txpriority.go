@@ -3,9 +3,30 @@ package mining
 import (
 	"container/heap"
 	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/blockchain"
+	"github.com/Qitmeer/qitmeer/core/blockdag"
 	"github.com/Qitmeer/qitmeer/core/types"
+	"github.com/Qitmeer/qitmeer/services/mempool"
 )
 
+// CalcTxPriority returns the mining priority of the passed transaction in a
+// block at the passed height, as used by NewBlockTemplate to order the
+// transactions of the high-priority area.  The formula is:
+//
+//	sum(inputValue * inputAge) / (serializedSize - overhead)
+//
+// where inputAge is the number of blocks since the block the spent output was
+// included in, or zero when that output is only in the view because its
+// transaction is still in the mempool.  The overhead discounts 41 bytes plus up
+// to 110 bytes of the signature script for each input so that spending
+// additional inputs doesn't lower the priority.  A transaction no larger than
+// the overhead has a zero priority.
+func CalcTxPriority(tx *types.Tx, utxos *blockchain.UtxoViewpoint, height uint64,
+	dag *blockdag.BlockDAG) float64 {
+
+	return mempool.CalcPriority(tx.Tx, utxos, height, dag)
+}
+
 // txPrioItem houses a transaction along with extra information that allows the
 // transaction to be prioritized and track dependencies on other transactions
 // which have not been mined into a block yet.
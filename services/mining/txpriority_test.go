@@ -0,0 +1,88 @@
+package mining
+
+import (
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/blockchain"
+	"github.com/Qitmeer/qitmeer/core/blockdag"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"github.com/Qitmeer/qitmeer/engine/txscript"
+	"testing"
+)
+
+// priorityTestBlock is a block of the chain built by TestCalcTxPriority.
+type priorityTestBlock struct {
+	hash    hash.Hash
+	parents []uint
+}
+
+func (b *priorityTestBlock) GetHash() *hash.Hash { return &b.hash }
+func (b *priorityTestBlock) GetParents() []uint  { return b.parents }
+func (b *priorityTestBlock) GetTimestamp() int64 { return 0 }
+func (b *priorityTestBlock) GetWeight() uint64   { return 1 }
+
+// TestCalcTxPriority ensures the priority of a transaction is its input value
+// age over its size without the input overhead.
+func TestCalcTxPriority(t *testing.T) {
+	// A chain of 3 blocks, whose heights are 0, 1 and 2.
+	ids := make(map[hash.Hash]uint)
+	dag := &blockdag.BlockDAG{}
+	dag.Init("phantom", func(int64) int64 { return 1 }, -1,
+		func(h *hash.Hash) uint {
+			if id, ok := ids[*h]; ok {
+				return id
+			}
+			return blockdag.MaxId
+		})
+	var blockHashes []hash.Hash
+	for i := 0; i < 3; i++ {
+		block := &priorityTestBlock{hash: hash.Hash{0x10, byte(i)}}
+		if i > 0 {
+			block.parents = []uint{ids[blockHashes[i-1]]}
+		}
+		if _, ib := dag.AddBlock(block); ib == nil {
+			t.Fatalf("AddBlock %d failed", i)
+		} else {
+			ids[block.hash] = ib.GetID()
+		}
+		blockHashes = append(blockHashes, block.hash)
+	}
+
+	// The funding transaction pays 1000 and 3000 in blocks 1 and 2, and
+	// 5000 from the mempool.
+	pkScript, _ := txscript.NewScriptBuilder().AddOp(txscript.OP_CHECKSIG).Script()
+	funding := types.NewTransaction()
+	funding.AddTxIn(&types.TxInput{
+		PreviousOut: *types.NewOutPoint(&hash.Hash{0x01}, 0),
+		Sequence:    types.MaxTxInSequenceNum,
+	})
+	for _, amount := range []uint64{1000, 3000, 5000} {
+		funding.AddTxOut(&types.TxOutput{Amount: amount, PkScript: pkScript})
+	}
+	fundingTx := types.NewTx(funding)
+	utxos := blockchain.NewUtxoViewpoint()
+	utxos.AddTxOut(fundingTx, 0, &blockHashes[1])
+	utxos.AddTxOut(fundingTx, 1, &blockHashes[2])
+	utxos.AddTxOut(fundingTx, 2, &hash.ZeroHash)
+
+	spend := types.NewTransaction()
+	for i := uint32(0); i < 3; i++ {
+		spend.AddTxIn(&types.TxInput{
+			PreviousOut: *types.NewOutPoint(fundingTx.Hash(), i),
+			SignScript:  make([]byte, 20),
+			Sequence:    types.MaxTxInSequenceNum,
+		})
+	}
+	spend.AddTxOut(&types.TxOutput{Amount: 8000, PkScript: make([]byte, 400)})
+	tx := types.NewTx(spend)
+
+	// In a block at height 10, the inputs are 9 and 8 blocks old and the
+	// unconfirmed one doesn't count, while each input discounts 41 bytes
+	// plus its 20 bytes signature script.
+	const height = 10
+	inputValueAge := float64(1000*9 + 3000*8)
+	adjustedSize := float64(spend.SerializeSize() - 3*(41+20))
+	want := inputValueAge / adjustedSize
+	if got := CalcTxPriority(tx, utxos, height, dag); got != want {
+		t.Fatalf("CalcTxPriority: got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,310 @@
+// Copyright (c) 2017-2018 The qitmeer developers
+// Use of this source code is governed by an ISC
+// license that can be found in the LICENSE file.
+
+package mining
+
+import (
+	"fmt"
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"github.com/Qitmeer/qitmeer/log"
+	"github.com/Qitmeer/qitmeer/services/mempool"
+)
+
+// txSelectionBatch is the max number of transactions whose scripts are
+// validated together by the template selection.  It is fixed rather than
+// derived from the number of processors so that the selected transactions
+// don't depend on the machine building the template.
+const txSelectionBatch = 32
+
+// txSelection selects the source pool transactions of a block template from
+// its weighted random queue, and appends them to the block after the
+// coinbase and the mandatory transactions.
+//
+// The transactions are selected by batches: the popped transactions which
+// fit in the block and pass the checks of their inputs are the candidates
+// of the batch, as if the previous candidates were all added, then the
+// scripts of the candidates are validated together, and the valid ones are
+// added in order.  So only the scripts of the transactions which would be
+// added are validated, and each one once.  The transactions which depend on
+// the added ones are pushed once their batch is added, and the transactions
+// popped after a candidate with invalid scripts are pushed back, since they
+// were checked against a block with it.
+type txSelection struct {
+	policy         *Policy
+	queue          *WeightedRandQueue
+	dependers      map[hash.Hash]map[hash.Hash]*WeightedRandTx
+	maxBlockSigOps int64
+	sortedByFee    bool
+
+	// batchSize is the max number of candidates of a batch.
+	batchSize int
+
+	// height is the height of the block, at which the fee rates of the
+	// selected transactions are observed.
+	height uint64
+
+	// checkInputs checks the inputs of a transaction which fits in the
+	// block against the chain and the block utxo view, and returns the
+	// reason it is skipped along with the error, if any.
+	checkInputs func(item *WeightedRandTx) (string, error)
+
+	// validateScripts validates the scripts of the passed transactions,
+	// and returns the error of each one, nil when its scripts are valid.
+	validateScripts func(txs []*types.Tx) []error
+
+	// validScripts is the set of the transactions whose scripts are
+	// valid, so the candidates pushed back after a candidate with invalid
+	// scripts aren't validated again.
+	validScripts map[hash.Hash]struct{}
+
+	// connectTx spends the inputs of a selected transaction in the block
+	// utxo view and adds its outputs.
+	connectTx func(tx *types.Tx)
+
+	// The block the transactions are appended to.
+	blockSize      uint32
+	blockSigOpCost int64
+	totalFees      int64
+	blockTxns      []*types.Tx
+	txFees         []int64
+	txSigOpCosts   []int64
+}
+
+// txCandidate is a transaction of a batch of the template selection, which
+// is added unless its scripts are invalid.
+type txCandidate struct {
+	item      *WeightedRandTx
+	txSize    uint32
+	sigOpCost int
+	deps      map[hash.Hash]*WeightedRandTx
+
+	// popped is the number of transactions popped for the batch up to
+	// this one included.
+	popped int
+}
+
+// selectTxs pops the transactions of the queue until it is empty, appends
+// the ones which fit in the block and pass the checks, and pushes their
+// dependers once they have no other dependency.
+func (s *txSelection) selectTxs() {
+	for s.queue.Len() > 0 {
+		s.selectBatch()
+	}
+}
+
+// selectBatch pops the transactions of a batch, validates the scripts of its
+// candidates together, and adds the valid ones.
+func (s *txSelection) selectBatch() {
+	policy := s.policy
+	blockSize, blockSigOpCost, totalFees := s.blockSize, s.blockSigOpCost,
+		s.totalFees
+	var popped []*WeightedRandTx
+	var candidates []*txCandidate
+	spent := make(map[types.TxOutPoint]struct{})
+	for (len(candidates) == 0 || len(candidates) < s.batchSize) &&
+		s.queue.Len() > 0 {
+		// Grab the highest priority (or highest fee per kilobyte
+		// depending on the sort order) transaction.
+		weirandItem := s.queue.Pop()
+		tx := weirandItem.tx
+
+		// Grab any transactions which depend on this one.
+		deps := s.dependers[*tx.Hash()]
+
+		// Enforce maximum block size.  Also check for overflow.
+		txSize := uint32(tx.Transaction().SerializeSize())
+		blockPlusTxSize := blockSize + txSize
+		if blockPlusTxSize < blockSize || blockPlusTxSize >= policy.BlockMaxSize {
+			log.Trace("Skipping tx", "txhash", tx.Hash(),
+				"reason", "max block size", "size", txSize,
+				"blocksize", blockSize, "blocktxns",
+				len(s.blockTxns)+len(candidates))
+			logSkippedDeps(tx, deps)
+			popped = append(popped, weirandItem)
+			continue
+		}
+
+		// Enforce maximum signature operation cost per block, of the
+		// consensus and of the policy.  Also check for overflow.
+		sigOpCost := policy.SigOpCache.CountSigOps(tx)
+		reason := sigOpsSkipReason(blockSigOpCost, int64(sigOpCost),
+			s.maxBlockSigOps)
+		if reason != "" {
+			log.Trace("Skipping tx", "txhash", tx.Hash(),
+				"reason", reason, "sigops", sigOpCost,
+				"blocksigops", blockSigOpCost, "maxsigops",
+				s.maxBlockSigOps)
+			logSkippedDeps(tx, deps)
+			popped = append(popped, weirandItem)
+			continue
+		}
+
+		// Once the high-priority area (if configured) has been filled
+		// with transactions, or the priority falls below what is
+		// considered high-priority, change to sorting by fees.
+		if !s.sortedByFee && leavesPriorityArea(policy, weirandItem, blockPlusTxSize) {
+			log.Trace("Switching to sort by fees per kilobyte",
+				"blocksize", blockSize, "blockPrioritySize",
+				policy.BlockPrioritySize, "priority", weirandItem.priority)
+			s.sortedByFee = true
+			s.queue.SetSortedByPriority(false)
+
+			// Put the transaction back into the queue and skip it
+			// so it is picked by fee if it won't fit into the
+			// high-priority area or its priority is too low.
+			// Otherwise it is the last one of the high-priority
+			// area, so it is added now.
+			if blockPlusTxSize > policy.BlockPrioritySize ||
+				weirandItem.priority < mempool.MinHighPriority {
+				s.queue.Push(weirandItem)
+				continue
+			}
+		}
+
+		// Skip free transactions once the block is larger than the
+		// minimum block size.
+		if s.sortedByFee &&
+			weirandItem.feePerKB < int64(policy.TxMinFreeFee) &&
+			(blockPlusTxSize >= policy.BlockMinSize) {
+			log.Trace("Skipping tx", "txhash", tx.Hash(),
+				"reason", "fee below TxMinFreeFee",
+				"feePerKB", weirandItem.feePerKB,
+				"minFreeFee", policy.TxMinFreeFee,
+				"blocksize", blockPlusTxSize,
+				"minBlockSize", policy.BlockMinSize)
+			logSkippedDeps(tx, deps)
+			popped = append(popped, weirandItem)
+			continue
+		}
+
+		// Skip transactions whose fee would overflow the total fees,
+		// which the coinbase accounts for.
+		newTotalFees, ok := addFee(totalFees, weirandItem.fee)
+		if !ok {
+			log.Trace("Skipping tx", "txhash", tx.Hash(),
+				"reason", "total fees overflow", "fee", weirandItem.fee,
+				"totalFees", totalFees)
+			logSkippedDeps(tx, deps)
+			popped = append(popped, weirandItem)
+			continue
+		}
+
+		// Ensure the transaction inputs pass all of the necessary
+		// preconditions before allowing it to be added to the block.
+		// The candidates of the batch aren't spent in the block utxo
+		// view yet, so their double spends are detected here.
+		reason, err := s.checkInputs(weirandItem)
+		if err == nil {
+			if err = checkBatchSpends(tx, spent); err != nil {
+				reason = "double spend in batch"
+			}
+		}
+		if err != nil {
+			log.Trace("Skipping tx", "txhash", tx.Hash(),
+				"reason", reason, "err", err)
+			logSkippedDeps(tx, deps)
+			popped = append(popped, weirandItem)
+			continue
+		}
+
+		popped = append(popped, weirandItem)
+		candidates = append(candidates, &txCandidate{
+			item:      weirandItem,
+			txSize:    txSize,
+			sigOpCost: sigOpCost,
+			deps:      deps,
+			popped:    len(popped),
+		})
+		blockSize = blockPlusTxSize
+		blockSigOpCost += int64(sigOpCost)
+		totalFees = newTotalFees
+	}
+	if len(candidates) == 0 {
+		return
+	}
+
+	// Validate the scripts of the candidates which weren't validated by a
+	// previous batch.
+	var txns []*types.Tx
+	for _, c := range candidates {
+		if _, ok := s.validScripts[*c.item.tx.Hash()]; !ok {
+			txns = append(txns, c.item.tx)
+		}
+	}
+	scriptErrs := make(map[hash.Hash]error)
+	if len(txns) > 0 {
+		errs := s.validateScripts(txns)
+		for i, tx := range txns {
+			if errs[i] != nil {
+				scriptErrs[*tx.Hash()] = errs[i]
+				continue
+			}
+			if s.validScripts == nil {
+				s.validScripts = make(map[hash.Hash]struct{})
+			}
+			s.validScripts[*tx.Hash()] = struct{}{}
+		}
+	}
+
+	for _, c := range candidates {
+		if err := scriptErrs[*c.item.tx.Hash()]; err != nil {
+			log.Trace("Skipping tx", "txhash", c.item.tx.Hash(),
+				"reason", "ValidateTransactionScripts", "err", err)
+			logSkippedDeps(c.item.tx, c.deps)
+
+			// The transactions popped after this one are checked
+			// again against the block without it.
+			for _, item := range popped[c.popped:] {
+				s.queue.Push(item)
+			}
+			return
+		}
+		s.addTx(c)
+	}
+}
+
+// addTx adds the passed candidate to the block and pushes its dependers.
+func (s *txSelection) addTx(c *txCandidate) {
+	weirandItem, tx := c.item, c.item.tx
+
+	// Spend the transaction inputs in the block utxo view and add an entry
+	// for it to ensure any transactions which reference this one have it
+	// available as an input and can ensure they aren't double spending.
+	s.connectTx(tx)
+
+	// Add the transaction to the block, increment counters, and save the
+	// fees and signature operation counts to the block template.
+	s.blockTxns = append(s.blockTxns, tx)
+	s.blockSize += c.txSize
+	s.blockSigOpCost += int64(c.sigOpCost)
+	s.totalFees += weirandItem.fee
+	s.txFees = append(s.txFees, weirandItem.fee)
+	s.txSigOpCosts = append(s.txSigOpCosts, int64(c.sigOpCost))
+
+	log.Trace("Adding tx", "txhash", tx.Hash(),
+		"priority", weirandItem.priority, "feePerKB", weirandItem.feePerKB)
+	s.policy.FeeEstimator.ObserveTransaction(tx.Hash(), weirandItem.feePerKB,
+		s.height)
+
+	// Add transactions which depend on this one (and also do not have any
+	// other unsatisified dependencies) to the priority queue.
+	pushDependers(s.policy, weirandItem, c.deps, s.dependers, s.queue)
+}
+
+// checkBatchSpends returns an error if the passed transaction spends an output
+// of the passed set, the outputs spent by the candidates of a batch, or else
+// adds its outputs to the set.
+func checkBatchSpends(tx *types.Tx, spent map[types.TxOutPoint]struct{}) error {
+	for _, txIn := range tx.Tx.TxIn {
+		if _, ok := spent[txIn.PreviousOut]; ok {
+			return fmt.Errorf("output %v is spent by another candidate",
+				txIn.PreviousOut)
+		}
+	}
+	for _, txIn := range tx.Tx.TxIn {
+		spent[txIn.PreviousOut] = struct{}{}
+	}
+	return nil
+}
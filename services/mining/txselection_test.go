@@ -0,0 +1,252 @@
+package mining
+
+import (
+	"bytes"
+	"errors"
+	"github.com/Qitmeer/qitmeer/common/hash"
+	"github.com/Qitmeer/qitmeer/core/address"
+	"github.com/Qitmeer/qitmeer/core/blockchain"
+	"github.com/Qitmeer/qitmeer/core/types"
+	"github.com/Qitmeer/qitmeer/crypto/ecc"
+	"github.com/Qitmeer/qitmeer/engine/txscript"
+	"github.com/Qitmeer/qitmeer/params"
+	"github.com/Qitmeer/qitmeer/services/mempool"
+	"testing"
+)
+
+// newTestSelection returns a selection of the passed ready items for an empty
+// block, whose transactions all pass the checks against the chain.
+func newTestSelection(policy *Policy, items []*WeightedRandTx) *txSelection {
+	queue := newWeightedRandQueue(len(items))
+	sortedByFee := policy.BlockPrioritySize == 0
+	queue.SetSortedByPriority(!sortedByFee)
+	for _, item := range items {
+		queue.Push(item)
+	}
+	return &txSelection{
+		policy:         policy,
+		queue:          queue,
+		maxBlockSigOps: blockchain.MaxSigOpsPerBlock,
+		sortedByFee:    sortedByFee,
+		batchSize:      txSelectionBatch,
+		checkInputs: func(item *WeightedRandTx) (string, error) {
+			return "", nil
+		},
+		validateScripts: func(txs []*types.Tx) []error {
+			return make([]error, len(txs))
+		},
+		connectTx: func(tx *types.Tx) {},
+	}
+}
+
+// TestPriorityThenFeeOrder ensures the high-priority transactions fill the
+// high-priority area before the transactions which only pay high fees.
+func TestPriorityThenFeeOrder(t *testing.T) {
+	items := make([]*WeightedRandTx, 5)
+	for i := range items {
+		items[i] = &WeightedRandTx{tx: newSigOpTestTx(uint32(i), 1)}
+	}
+	txSize := uint32(items[0].tx.Transaction().SerializeSize())
+	highPriority, highFee := items[:3], items[3:]
+	highPriority[0].priority = 2 * mempool.MinHighPriority
+	highPriority[1].priority = 3 * mempool.MinHighPriority
+	highPriority[1].feePerKB = 10
+	highPriority[2].priority = 3 * mempool.MinHighPriority
+	highFee[0].priority, highFee[0].fee, highFee[0].feePerKB = 1, 50000, 500000
+	highFee[1].priority, highFee[1].fee, highFee[1].feePerKB = 2, 90000, 900000
+
+	policy := &Policy{
+		BlockMaxSize:      100 * txSize,
+		BlockPrioritySize: 3 * txSize,
+	}
+	selection := newTestSelection(policy, items)
+	selection.selectTxs()
+
+	selected := selection.blockTxns
+	if len(selected) != len(items) {
+		t.Fatalf("selected %d transactions, want %d", len(selected),
+			len(items))
+	}
+	// By priority, then fee per kilobyte.
+	wantPriority := []*WeightedRandTx{highPriority[1], highPriority[2],
+		highPriority[0]}
+	for i, want := range wantPriority {
+		if selected[i] != want.tx {
+			t.Errorf("transaction %d isn't the one of priority %v", i,
+				want.priority)
+		}
+	}
+	for _, tx := range selected[len(highPriority):] {
+		if tx != highFee[0].tx && tx != highFee[1].tx {
+			t.Errorf("high-priority transaction %v selected after the "+
+				"high-priority area", tx.Hash())
+		}
+	}
+	if !selection.sortedByFee {
+		t.Error("selection not sorted by fee after the high-priority area")
+	}
+	if want := int64(50000 + 90000); selection.totalFees != want {
+		t.Errorf("got total fees %d, want %d", selection.totalFees, want)
+	}
+	if want := uint32(len(items)) * txSize; selection.blockSize != want {
+		t.Errorf("got block size %d, want %d", selection.blockSize, want)
+	}
+}
+
+// TestTxSelectionBatch ensures only the scripts of the transactions which fit
+// in the block are validated, that the transactions popped after one with
+// invalid scripts are checked again without it, and that a batch doesn't
+// double spend.
+func TestTxSelectionBatch(t *testing.T) {
+	items := make([]*WeightedRandTx, 6)
+	for i := range items {
+		items[i] = &WeightedRandTx{tx: newSigOpTestTx(uint32(i), 1),
+			fee: int64(1000 * (i + 1))}
+	}
+	// The last one double spends the first one.
+	items[5].tx = newSigOpTestTx(0, 2)
+	txSize := uint32(items[0].tx.Transaction().SerializeSize())
+
+	// newSelection returns a selection of the first 5 items for a block
+	// with room for 3 of them, which records the validated transactions.
+	newSelection := func(invalid *types.Tx) (*txSelection, map[*types.Tx]int) {
+		policy := &Policy{BlockMaxSize: 3*txSize + 1}
+		selection := newTestSelection(policy, items[:5])
+		validated := make(map[*types.Tx]int)
+		selection.validateScripts = func(txs []*types.Tx) []error {
+			errs := make([]error, len(txs))
+			for i, tx := range txs {
+				validated[tx]++
+				if tx == invalid {
+					errs[i] = errors.New("invalid scripts")
+				}
+			}
+			return errs
+		}
+		return selection, validated
+	}
+
+	// Only the scripts of the selected transactions are validated.
+	selection, validated := newSelection(nil)
+	selection.selectTxs()
+	if len(selection.blockTxns) != 3 || len(validated) != 3 {
+		t.Fatalf("selected %d transactions and validated %d, want 3",
+			len(selection.blockTxns), len(validated))
+	}
+	for _, tx := range selection.blockTxns {
+		if validated[tx] != 1 {
+			t.Errorf("scripts of transaction %v validated %d times",
+				tx.Hash(), validated[tx])
+		}
+	}
+
+	// The block is filled without the transaction with invalid scripts,
+	// and no scripts are validated twice.
+	invalid := items[2].tx
+	selection, validated = newSelection(invalid)
+	selection.selectTxs()
+	if len(selection.blockTxns) != 3 {
+		t.Fatalf("selected %d transactions, want 3",
+			len(selection.blockTxns))
+	}
+	for _, tx := range selection.blockTxns {
+		if tx == invalid {
+			t.Fatal("transaction with invalid scripts selected")
+		}
+	}
+	for tx, count := range validated {
+		if count != 1 {
+			t.Errorf("scripts of transaction %v validated %d times",
+				tx.Hash(), count)
+		}
+	}
+
+	// Only one of two transactions spending the same output is a
+	// candidate.
+	policy := &Policy{BlockMaxSize: 10 * txSize}
+	selection = newTestSelection(policy, []*WeightedRandTx{items[0], items[5]})
+	selection.selectTxs()
+	if len(selection.blockTxns) != 1 {
+		t.Errorf("selected %d double spending transactions, want 1",
+			len(selection.blockTxns))
+	}
+}
+
+// BenchmarkValidateTemplateScripts compares the validation of the scripts of
+// the template transactions one at a time, each one with its own pool of
+// goroutines, to their validation by batches of the selection sharing one
+// pool.
+func BenchmarkValidateTemplateScripts(b *testing.B) {
+	privKey, pubKey := ecc.Secp256k1.PrivKeyFromBytes(bytes.Repeat([]byte{0x01}, 32))
+	addr, err := address.NewPubKeyHashAddress(
+		hash.Hash160(pubKey.SerializeCompressed()), &params.PrivNetParams,
+		ecc.ECDSA_Secp256k1)
+	if err != nil {
+		b.Fatalf("NewPubKeyHashAddress: %v", err)
+	}
+	pkScript, err := txscript.PayToAddrScript(addr)
+	if err != nil {
+		b.Fatalf("PayToAddrScript: %v", err)
+	}
+
+	// Transactions spending the outputs of a funding transaction.
+	const numTxs = 256
+	funding := types.NewTransaction()
+	funding.AddTxIn(&types.TxInput{
+		PreviousOut: *types.NewOutPoint(&hash.Hash{0x01}, 0),
+		Sequence:    types.MaxTxInSequenceNum,
+	})
+	for i := 0; i < numTxs; i++ {
+		funding.AddTxOut(&types.TxOutput{Amount: 1e8, PkScript: pkScript})
+	}
+	fundingTx := types.NewTx(funding)
+	view := blockchain.NewUtxoViewpoint()
+	view.AddTxOuts(fundingTx, &hash.Hash{0x02})
+	txs := make([]*types.Tx, numTxs)
+	for i := range txs {
+		tx := types.NewTransaction()
+		tx.AddTxIn(&types.TxInput{
+			PreviousOut: *types.NewOutPoint(fundingTx.Hash(), uint32(i)),
+			Sequence:    types.MaxTxInSequenceNum,
+		})
+		tx.AddTxOut(&types.TxOutput{Amount: 1e8 - 1e4, PkScript: pkScript})
+		sigScript, err := txscript.SignatureScript(tx, 0, pkScript,
+			txscript.SigHashAll, privKey, true)
+		if err != nil {
+			b.Fatalf("SignatureScript: %v", err)
+		}
+		tx.TxIn[0].SignScript = sigScript
+		txs[i] = types.NewTx(tx)
+	}
+	flags := mempool.BaseStandardVerifyFlags
+
+	b.Run("serial", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			for _, tx := range txs {
+				err := blockchain.ValidateTransactionScripts(tx, view,
+					flags, nil)
+				if err != nil {
+					b.Fatalf("ValidateTransactionScripts: %v", err)
+				}
+			}
+		}
+	})
+	b.Run("batch", func(b *testing.B) {
+		for n := 0; n < b.N; n++ {
+			for i := 0; i < len(txs); i += txSelectionBatch {
+				batch := txs[i:]
+				if len(batch) > txSelectionBatch {
+					batch = batch[:txSelectionBatch]
+				}
+				errs := blockchain.ValidateTransactionsScripts(batch,
+					view, flags, nil)
+				for _, err := range errs {
+					if err != nil {
+						b.Fatalf("ValidateTransactionsScripts: %v",
+							err)
+					}
+				}
+			}
+		}
+	})
+}
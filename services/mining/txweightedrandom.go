@@ -1,9 +1,11 @@
 package mining
 
 import (
+	"bytes"
 	"github.com/Qitmeer/qitmeer/common/hash"
 	"github.com/Qitmeer/qitmeer/core/types"
 	"math/rand"
+	"sort"
 	"time"
 )
 
@@ -15,12 +17,75 @@ type WeightedRandTx struct {
 	feePerKB int64
 
 	dependsOn map[hash.Hash]struct{}
+
+	// depth is the number of transactions of the longest chain of source
+	// pool transactions the transaction depends on.
+	depth int
 }
 
-// The Queue for weighted rand tx
+// The Queue for weighted rand tx.  Items are popped at random weighted by
+// their fee, or by highest priority (then fee per kilobyte) while the queue is
+// sorted by priority.
+//
+// Ties are broken deterministically by transaction hash, the lower hash
+// compared byte by byte first.  The items are kept in that order, by fee then
+// priority then hash, so that the same random draw picks the same item
+// whatever the order they were pushed in, and items of equal priority and fee
+// per kilobyte are popped in hash order while sorted by priority.
 type WeightedRandQueue struct {
-	totalFee int64
-	items    []*WeightedRandTx
+	totalFee         int64
+	items            []*WeightedRandTx
+	sortedByPriority bool
+
+	// pops records the popped items while recordPops is set.
+	recordPops bool
+	pops       []WeightedRandPop
+}
+
+// WeightedRandPop records an item popped from a WeightedRandQueue, to inspect
+// the realized selection order when debugging.
+type WeightedRandPop struct {
+	Tx       *types.Tx
+	Fee      int64
+	Priority float64
+
+	// Weight is the weight of the random draw which popped the item, out of
+	// TotalWeight, the total weight of the items in the queue at the time.
+	// Both are zero when the item was popped by priority.
+	Weight      int64
+	TotalWeight int64
+}
+
+// RecordPops sets whether the popped items are recorded, which is off by
+// default to avoid the overhead.  Enabling it clears the previous record.
+func (wq *WeightedRandQueue) RecordPops(record bool) {
+	wq.recordPops = record
+	wq.pops = nil
+}
+
+// Pops returns the items popped, in order, since the recording was enabled.
+func (wq *WeightedRandQueue) Pops() []WeightedRandPop {
+	return wq.pops
+}
+
+// recordPop records the passed popped item if the recording is enabled.
+func (wq *WeightedRandQueue) recordPop(item *WeightedRandTx, weight, totalWeight int64) {
+	if !wq.recordPops {
+		return
+	}
+	wq.pops = append(wq.pops, WeightedRandPop{
+		Tx:          item.tx,
+		Fee:         item.fee,
+		Priority:    item.priority,
+		Weight:      weight,
+		TotalWeight: totalWeight,
+	})
+}
+
+// SetSortedByPriority sets whether the next items are popped by priority
+// instead of at random weighted by fee.
+func (wq *WeightedRandQueue) SetSortedByPriority(sortedByPriority bool) {
+	wq.sortedByPriority = sortedByPriority
 }
 
 // The length of WeightedRandQueue
@@ -30,32 +95,87 @@ func (wq *WeightedRandQueue) Len() int {
 
 // Push item to WeightedRandQueue
 func (wq *WeightedRandQueue) Push(tx *WeightedRandTx) {
-	wq.items = append(wq.items, tx)
+	index := sort.Search(len(wq.items), func(i int) bool {
+		return queueOrderLess(tx, wq.items[i])
+	})
+	wq.items = append(wq.items, nil)
+	copy(wq.items[index+1:], wq.items[index:])
+	wq.items[index] = tx
 	wq.totalFee += tx.fee + 1
 }
 
-// Pop item from WeightedRandQueue
+// queueOrderLess returns whether item a is kept before item b in the queue,
+// which is by highest fee, then highest priority, then lowest hash.
+func queueOrderLess(a, b *WeightedRandTx) bool {
+	if a.fee != b.fee {
+		return a.fee > b.fee
+	}
+	if a.priority != b.priority {
+		return a.priority > b.priority
+	}
+	return txHashLess(a, b)
+}
+
+// txHashLess returns whether the transaction of item a has a lower hash than
+// the one of item b.  Items without transaction are never less.
+func txHashLess(a, b *WeightedRandTx) bool {
+	if a.tx == nil || b.tx == nil {
+		return false
+	}
+	return bytes.Compare(a.tx.Hash()[:], b.tx.Hash()[:]) < 0
+}
+
+// Pop item from WeightedRandQueue.  Each item is drawn with a weight of its
+// fee plus one, so that items without fee can still be drawn.
 func (wq *WeightedRandQueue) Pop() *WeightedRandTx {
 	if wq.Len() <= 0 {
 		return nil
 	}
+	if wq.sortedByPriority {
+		return wq.popHighestPriority()
+	}
 	factor := rand.Int63n(wq.totalFee)
 
 	total := int64(0)
 	index := int(0)
 	var item *WeightedRandTx
 	for index, item = range wq.items {
-		total += item.fee
-		if total >= factor {
+		total += item.fee + 1
+		if total > factor {
 			break
 		}
 	}
 	wq.items = append(wq.items[:index], wq.items[index+1:]...)
-	total = total - item.fee - 1
+	wq.recordPop(item, item.fee+1, wq.totalFee)
+	wq.totalFee -= item.fee + 1
 
 	return item
 }
 
+// popHighestPriority removes and returns the item with the highest priority,
+// breaking ties by the highest fee per kilobyte, then the lowest hash.
+func (wq *WeightedRandQueue) popHighestPriority() *WeightedRandTx {
+	index := 0
+	for i, item := range wq.items[1:] {
+		best := wq.items[index]
+		if item.priority != best.priority {
+			if item.priority > best.priority {
+				index = i + 1
+			}
+			continue
+		}
+		if item.feePerKB > best.feePerKB ||
+			(item.feePerKB == best.feePerKB && txHashLess(item, best)) {
+			index = i + 1
+		}
+	}
+	item := wq.items[index]
+	wq.items = append(wq.items[:index], wq.items[index+1:]...)
+	wq.recordPop(item, 0, 0)
+	wq.totalFee -= item.fee + 1
+	return item
+}
+
 // Build WeightedRandQueue
 func newWeightedRandQueue(reserve int) *WeightedRandQueue {
 	rand.Seed(time.Now().Unix())
@@ -2,6 +2,7 @@ package mining
 
 import (
 	"fmt"
+	"math/rand"
 	"testing"
 )
 
@@ -18,3 +19,107 @@ func Test_TXWeightedRandom(t *testing.T) {
 		fmt.Println(item.fee)
 	}
 }
+
+// TestWeightedRandQueueTieBreak ensures items of equal weight are popped in
+// the same order whatever the order they were pushed in.
+func TestWeightedRandQueueTieBreak(t *testing.T) {
+	var items []*WeightedRandTx
+	for i := uint32(0); i < 4; i++ {
+		items = append(items, &WeightedRandTx{tx: newSigOpTestTx(i, 1),
+			fee: 1000, feePerKB: 10000, priority: 5})
+	}
+	popAll := func(sortedByPriority bool, order []int) []*WeightedRandTx {
+		queue := newWeightedRandQueue(len(order))
+		queue.SetSortedByPriority(sortedByPriority)
+		for _, i := range order {
+			queue.Push(items[i])
+		}
+		rand.Seed(7)
+		var popped []*WeightedRandTx
+		for queue.Len() > 0 {
+			popped = append(popped, queue.Pop())
+		}
+		return popped
+	}
+
+	for _, sortedByPriority := range []bool{true, false} {
+		forward := popAll(sortedByPriority, []int{0, 1, 2, 3})
+		backward := popAll(sortedByPriority, []int{3, 2, 1, 0})
+		for i := range forward {
+			if forward[i] != backward[i] {
+				t.Fatalf("sorted by priority %v: pop %d differs with the "+
+					"push order", sortedByPriority, i)
+			}
+		}
+		if !sortedByPriority {
+			continue
+		}
+		for i := 1; i < len(forward); i++ {
+			if !txHashLess(forward[i-1], forward[i]) {
+				t.Errorf("pop %d isn't in hash order", i)
+			}
+		}
+	}
+}
+
+// TestWeightedRandQueueFairness ensures that over many builds the items of
+// higher weight are popped earlier on average, and that the recorded weights
+// add up.
+func TestWeightedRandQueueFairness(t *testing.T) {
+	const builds = 2000
+	fees := []int64{0, 100, 200, 400, 800}
+	var wantTotal int64
+	for _, fee := range fees {
+		wantTotal += fee + 1
+	}
+
+	positions := make(map[int64]int)
+	for build := 0; build < builds; build++ {
+		queue := newWeightedRandQueue(len(fees))
+		rand.Seed(int64(build))
+		queue.RecordPops(true)
+		for i, fee := range fees {
+			queue.Push(&WeightedRandTx{tx: newSigOpTestTx(uint32(i), 1),
+				fee: fee})
+		}
+		for queue.Len() > 0 {
+			queue.Pop()
+		}
+
+		pops := queue.Pops()
+		if len(pops) != len(fees) {
+			t.Fatalf("build %d: recorded %d pops, want %d", build,
+				len(pops), len(fees))
+		}
+		total := pops[0].TotalWeight
+		if total != wantTotal {
+			t.Fatalf("build %d: total weight %d, want %d", build, total,
+				wantTotal)
+		}
+		for i, pop := range pops {
+			if pop.TotalWeight != total {
+				t.Fatalf("build %d: pop %d has total weight %d, want %d",
+					build, i, pop.TotalWeight, total)
+			}
+			total -= pop.Weight
+			positions[pop.Fee] += i
+		}
+	}
+
+	for i := 1; i < len(fees); i++ {
+		lower, higher := fees[i-1], fees[i]
+		if positions[higher] >= positions[lower] {
+			t.Errorf("fee %d popped at %.2f on average, not before fee "+
+				"%d at %.2f", higher, float64(positions[higher])/builds,
+				lower, float64(positions[lower])/builds)
+		}
+	}
+
+	// Nothing is recorded unless enabled.
+	queue := newWeightedRandQueue(1)
+	queue.Push(&WeightedRandTx{fee: 1})
+	queue.Pop()
+	if pops := queue.Pops(); pops != nil {
+		t.Errorf("recorded %d pops while disabled", len(pops))
+	}
+}
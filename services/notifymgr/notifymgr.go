@@ -29,10 +29,10 @@ func (ntmgr *NotifyMgr) AnnounceNewTransactions(newTxs []*types.Tx) {
 		ntmgr.RelayInventory(iv, tx)
 		// reply to rpc
 		if ntmgr.RpcServer != nil {
-			//TODO reply to rpc layer (if websockect long connection or gbt long poll)
 			// Notify websocket clients about mempool transactions.
-			//qitmeer.node.rpcServer.ntfnMgr.NotifyMempoolTx(tx, true)
-			//
+			ntmgr.RpcServer.NotifyMempoolTx(tx)
+
+			//TODO reply to gbt long poll
 			// Potentially notify any getblocktemplate long poll clients
 			// about stale block templates due to the new transaction.
 			//qitmeer.node.rpcServer.gbtWorkState.NotifyMempoolTx(
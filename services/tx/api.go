@@ -322,7 +322,12 @@ func (api *PublicTxAPI) GetRawTransaction(txHash hash.Hash, verbose bool) (inter
 	if tx != nil {
 		confirmations = 0
 	}
-	return marshal.MarshalJsonTransaction(mtx, api.txManager.bm.ChainParams(), blkHashStr, confirmations, coinbaseAmout)
+	txr, err := marshal.MarshalJsonTransaction(mtx, api.txManager.bm.ChainParams(), blkHashStr, confirmations, coinbaseAmout)
+	if err != nil {
+		return nil, err
+	}
+	txr.InMempool = tx != nil
+	return txr, nil
 }
 
 // Returns information about an unspent transaction output
@@ -343,6 +348,8 @@ func (api *PublicTxAPI) GetRawTransaction(txHash hash.Hash, verbose bool) (inter
 //  "addresses": ["value",...], (array of string) The qitmeer addresses associated with this script
 // },
 // "coinbase": true|false,      (boolean)         Whether or not the transaction is a coinbase
+// "spendable": true|false,     (boolean)         Whether or not the output can be spent
+// "maturityheight": n,         (numeric)         The best height from which the output can be spent
 //}
 func (api *PublicTxAPI) GetUtxo(txHash hash.Hash, vout uint32, includeMempool *bool) (interface{}, error) {
 
@@ -354,6 +361,7 @@ func (api *PublicTxAPI) GetUtxo(txHash hash.Hash, vout uint32, includeMempool *b
 	var amount uint64
 	var pkScript []byte
 	var isCoinbase bool
+	var height uint64
 
 	// by default try to search mempool tx
 	includeMempoolTx := true
@@ -402,6 +410,7 @@ func (api *PublicTxAPI) GetUtxo(txHash hash.Hash, vout uint32, includeMempool *b
 				confirmations = 0
 			} else {
 				confirmations = int64(best.GraphState.GetLayer() - block.GetLayer())
+				height = uint64(block.GetHeight())
 			}
 			amount += uint64(api.txManager.bm.GetChain().GetFees(block.GetHash()))
 		}
@@ -410,38 +419,50 @@ func (api *PublicTxAPI) GetUtxo(txHash hash.Hash, vout uint32, includeMempool *b
 		isCoinbase = entry.IsCoinBase()
 	}
 
-	// Disassemble script into single line printable format.  The
-	// disassembled string will contain [error] inline if the script
-	// doesn't fully parse, so ignore the error here.
-	script := pkScript
-	disbuf, _ := txscript.DisasmString(script)
-
-	// Get further info about the script.  Ignore the error here since an
-	// error means the script couldn't parse and there is no additional
-	// information about it anyways.
-	scriptClass, addrs, reqSigs, _ := txscript.ExtractPkScriptAddrs(script, api.txManager.bm.ChainParams())
-	addresses := make([]string, len(addrs))
-	for i, addr := range addrs {
-		addresses[i] = addr.Encode()
-	}
+	chainParams := api.txManager.bm.ChainParams()
+	bestHeight := uint64(api.txManager.bm.GetChain().BlockDAG().GetMainChainTip().GetHeight())
+	spendable, maturityHeight := utxoMaturity(isCoinbase, height, bestHeight,
+		chainParams.CoinbaseMaturity)
 
 	txOutReply := &json.GetUtxoResult{
-		BestBlock:     bestBlockHash,
-		Confirmations: confirmations,
-		Amount:        types.Amount(amount).ToUnit(types.AmountCoin),
-		Version:       int32(txVersion),
-		ScriptPubKey: json.ScriptPubKeyResult{
-			Asm:       disbuf,
-			Hex:       hex.EncodeToString(pkScript),
-			ReqSigs:   int32(reqSigs),
-			Type:      scriptClass.String(),
-			Addresses: addresses,
-		},
-		Coinbase: isCoinbase,
+		BestBlock:      bestBlockHash,
+		Confirmations:  confirmations,
+		Amount:         types.Amount(amount).ToUnit(types.AmountCoin),
+		Version:        int32(txVersion),
+		ScriptPubKey:   marshal.ClassifyScript(pkScript, chainParams),
+		Coinbase:       isCoinbase,
+		Spendable:      spendable,
+		MaturityHeight: maturityHeight,
 	}
 	return txOutReply, nil
 }
 
+// utxoMaturity returns whether an unspent output created at the passed
+// height can be spent by a block building on the passed best height, along
+// with the best height from which it can be spent.  Only coinbase outputs have to
+// wait for the coinbase maturity, other outputs are available right away.
+func utxoMaturity(isCoinbase bool, height uint64, bestHeight uint64,
+	coinbaseMaturity uint16) (bool, uint64) {
+
+	if !isCoinbase {
+		return true, height
+	}
+	maturityHeight := height + uint64(coinbaseMaturity)
+	return bestHeight >= maturityHeight, maturityHeight
+}
+
+// Returns the fee per kilobyte a transaction needs to pay to be included
+// within the passed number of blocks, estimated from the transactions included
+// in recent blocks.
+// 1. conftarget (numeric, required) The number of blocks, up to 25
+func (api *PublicTxAPI) EstimateFee(confTarget int) (interface{}, error) {
+	feePerKB, err := api.txManager.feeEstimator.EstimateFee(confTarget)
+	if err != nil {
+		return nil, err
+	}
+	return feePerKB, nil
+}
+
 // handleSearchRawTransactions implements the searchrawtransactions command.
 func (api *PublicTxAPI) GetRawTransactions(addre string, vinext *bool, count *uint, skip *uint, revers *bool, verbose *bool, filterAddrs *[]string) (interface{}, error) {
 	addrIndex := api.txManager.addrIndex
@@ -649,7 +670,8 @@ func (api *PublicTxAPI) GetRawTransactions(addre string, vinext *bool, count *ui
 		}
 	}
 
-	return srtList, nil
+	// The list may hold the whole history of the address, so stream it.
+	return json.GetRawTransactionsStream(srtList), nil
 }
 
 func (api *PublicTxAPI) fetchMempoolTxnsForAddress(addr types.Address, numToSkip, numRequested uint32) ([]*types.Tx, uint32) {
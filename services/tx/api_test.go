@@ -0,0 +1,37 @@
+package tx
+
+import (
+	"testing"
+)
+
+// TestUtxoMaturity ensures coinbase outputs are only reported spendable once
+// they reach the coinbase maturity.
+func TestUtxoMaturity(t *testing.T) {
+	const coinbaseMaturity = 16
+	tests := []struct {
+		name           string
+		isCoinbase     bool
+		height         uint64
+		bestHeight     uint64
+		spendable      bool
+		maturityHeight uint64
+	}{
+		{"immature coinbase", true, 100, 115, false, 116},
+		{"just matured coinbase", true, 100, 116, true, 116},
+		{"mature coinbase", true, 100, 500, true, 116},
+		{"non-coinbase", false, 100, 100, true, 100},
+		{"mempool", false, 0, 100, true, 0},
+	}
+	for _, test := range tests {
+		spendable, maturityHeight := utxoMaturity(test.isCoinbase,
+			test.height, test.bestHeight, coinbaseMaturity)
+		if spendable != test.spendable {
+			t.Errorf("%s: spendable got %v, want %v", test.name,
+				spendable, test.spendable)
+		}
+		if maturityHeight != test.maturityHeight {
+			t.Errorf("%s: maturity height got %d, want %d", test.name,
+				maturityHeight, test.maturityHeight)
+		}
+	}
+}
@@ -1,10 +1,12 @@
 package tx
 
 import (
+	"bytes"
 	"github.com/Qitmeer/qitmeer/common/hash"
 	"github.com/Qitmeer/qitmeer/config"
 	"github.com/Qitmeer/qitmeer/core/blockchain"
 	"github.com/Qitmeer/qitmeer/core/blockdag"
+	"github.com/Qitmeer/qitmeer/core/dbnamespace"
 	"github.com/Qitmeer/qitmeer/core/types"
 	"github.com/Qitmeer/qitmeer/database"
 	"github.com/Qitmeer/qitmeer/engine/txscript"
@@ -13,9 +15,14 @@ import (
 	"github.com/Qitmeer/qitmeer/services/common"
 	"github.com/Qitmeer/qitmeer/services/index"
 	"github.com/Qitmeer/qitmeer/services/mempool"
+	"os"
+	"path/filepath"
 	"time"
 )
 
+// mempoolFilename is the name of the file the mempool is saved to on shutdown.
+const mempoolFilename = "mempool.dat"
+
 type TxManager struct {
 	bm *blkmgr.BlockManager
 	// tx index
@@ -34,15 +41,47 @@ type TxManager struct {
 
 	//invalidTx hash->block hash
 	invalidTx map[hash.Hash]*blockdag.HashSet
+
+	// fee estimator
+	feeEstimator *mempool.FeeEstimator
+
+	// mempoolFile is the file the mempool is saved to on shutdown and
+	// loaded from on startup, or empty if the mempool isn't persisted.
+	mempoolFile string
 }
 
 func (tm *TxManager) Start() error {
 	log.Info("Starting tx manager")
+
+	// Restore the mempool saved by the previous run.
+	if tm.mempoolFile != "" {
+		tm.loadMempool()
+	}
 	return nil
 }
 
 func (tm *TxManager) Stop() error {
 	log.Info("Stopping tx manager")
+
+	// Save the mempool so that it's restored on startup.
+	if tm.mempoolFile != "" {
+		if err := tm.saveMempool(); err != nil {
+			log.Error("Unable to save mempool", "err", err)
+		}
+	}
+
+	// Save the fee estimator state so it doesn't start from scratch.
+	var buf bytes.Buffer
+	if err := tm.feeEstimator.Save(&buf); err != nil {
+		log.Error("Unable to save fee estimator", "err", err)
+		return nil
+	}
+	err := tm.db.Update(func(dbTx database.Tx) error {
+		return dbTx.Metadata().Put(dbnamespace.FeeEstimatorKeyName, buf.Bytes())
+	})
+	if err != nil {
+		log.Error("Unable to save fee estimator", "err", err)
+	}
 	return nil
 }
 
@@ -50,6 +89,10 @@ func (tm *TxManager) MemPool() blockchain.TxPool {
 	return tm.txMemPool
 }
 
+func (tm *TxManager) FeeEstimator() *mempool.FeeEstimator {
+	return tm.feeEstimator
+}
+
 func (tm *TxManager) IsInvalidTx(txh *hash.Hash) bool {
 	_, ok := tm.invalidTx[*txh]
 	return ok
@@ -102,14 +145,23 @@ func NewTxManager(bm *blkmgr.BlockManager, txIndex *index.TxIndex,
 	// mem-pool
 	txC := mempool.Config{
 		Policy: mempool.Policy{
-			MaxTxVersion:         2,
+			MaxTxVersion:         mempool.DefaultMaxTxVersion,
 			DisableRelayPriority: cfg.NoRelayPriority,
 			AcceptNonStd:         cfg.AcceptNonStd,
 			FreeTxRelayLimit:     cfg.FreeTxRelayLimit,
 			MaxOrphanTxs:         cfg.MaxOrphanTxs,
 			MaxOrphanTxSize:      mempool.DefaultMaxOrphanTxSize,
-			MaxSigOpsPerTx:       blockchain.MaxSigOpsPerBlock / 5,
-			MinRelayTxFee:        types.Amount(cfg.MinTxFee),
+			MaxOrphanTxBytes:     cfg.MaxOrphanTxBytes,
+			OrphanTxExpiry:       cfg.OrphanTxExpiry,
+			MempoolExpiry:        cfg.MempoolExpiry,
+			PackageLimits: mempool.PackageLimits{
+				MaxAncestorCount:   cfg.MaxAncestorCount,
+				MaxAncestorSize:    cfg.MaxAncestorSize,
+				MaxDescendantCount: cfg.MaxDescendantCount,
+				MaxDescendantSize:  cfg.MaxDescendantSize,
+			},
+			MaxSigOpsPerTx: mempool.DefaultMaxSigOpsPerTx,
+			MinRelayTxFee:  types.Amount(cfg.MinTxFee),
 			StandardVerifyFlags: func() (txscript.ScriptFlags, error) {
 				return common.StandardScriptVerifyFlags()
 			},
@@ -129,5 +181,74 @@ func NewTxManager(bm *blkmgr.BlockManager, txIndex *index.TxIndex,
 	}
 	txMemPool := mempool.New(&txC)
 	invalidTx := make(map[hash.Hash]*blockdag.HashSet)
-	return &TxManager{bm, txIndex, addrIndex, txMemPool, ntmgr, db, invalidTx}, nil
+	var mempoolFile string
+	if !cfg.NoPersistMempool {
+		mempoolFile = filepath.Join(cfg.DataDir, mempoolFilename)
+	}
+	return &TxManager{bm, txIndex, addrIndex, txMemPool, ntmgr, db, invalidTx,
+		loadFeeEstimator(db), mempoolFile}, nil
+}
+
+// loadMempool accepts again into the mempool the transactions saved by the
+// previous run.  The file is removed once loaded, so that it can't be
+// loaded twice.
+func (tm *TxManager) loadMempool() {
+	f, err := os.Open(tm.mempoolFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Warn("Unable to load mempool", "err", err)
+		}
+		return
+	}
+	restored, dropped, err := tm.txMemPool.Load(f)
+	f.Close()
+	if err != nil {
+		log.Warn("Unable to load mempool", "err", err)
+	} else {
+		log.Info("Loaded mempool", "restored", restored, "dropped", dropped)
+	}
+	if err := os.Remove(tm.mempoolFile); err != nil {
+		log.Warn("Unable to remove mempool file", "err", err)
+	}
+}
+
+// saveMempool writes the transactions of the mempool to its file.  They are
+// written to a temporary file first, which then replaces the file, so that
+// a failure never leaves a partial mempool to load.
+func (tm *TxManager) saveMempool() error {
+	tmpFile := tm.mempoolFile + ".new"
+	f, err := os.Create(tmpFile)
+	if err != nil {
+		return err
+	}
+	err = tm.txMemPool.Save(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpFile)
+		return err
+	}
+	return os.Rename(tmpFile, tm.mempoolFile)
+}
+
+// loadFeeEstimator restores the fee estimator saved by a previous run, or
+// returns a new one if there is none.
+func loadFeeEstimator(db database.DB) *mempool.FeeEstimator {
+	var serialized []byte
+	err := db.View(func(dbTx database.Tx) error {
+		// The value is only valid during the transaction, so copy it.
+		value := dbTx.Metadata().Get(dbnamespace.FeeEstimatorKeyName)
+		serialized = append(serialized, value...)
+		return nil
+	})
+	if err == nil && len(serialized) > 0 {
+		fe, err := mempool.RestoreFeeEstimator(bytes.NewReader(serialized))
+		if err == nil {
+			return fe
+		}
+		log.Warn("Unable to restore fee estimator", "err", err)
+	}
+	return mempool.NewFeeEstimator(mempool.DefaultFeeEstimatorWindow,
+		mempool.DefaultFeeEstimatorMinObservations)
 }